@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,8 +14,9 @@ import (
 	"github.com/benjamincozon/feedenrich/internal/api"
 	"github.com/benjamincozon/feedenrich/internal/config"
 	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/retention"
 	_ "github.com/lib/pq"
-	"github.com/pressly/goose/v3"
 )
 
 func main() {
@@ -29,26 +32,55 @@ func main() {
 	}
 
 	// Connect to database
-	ctx := context.Background()
-	pool, err := db.Connect(ctx, cfg.Database.URL)
+	// Cancelled on shutdown to stop the background loops (retention, scheduler)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	pool, err := db.Connect(ctx, cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer pool.Close()
 
-	queries := db.New(pool)
+	queries := db.New(pool, cfg)
+	if err := queries.CheckSchema(ctx); err != nil {
+		log.Fatalf("Failed to check database schema: %v", err)
+	}
+	// Provider keys stored before ORG_KEYS_ENCRYPTION_KEY was set are encrypted now
+	if n, err := queries.SealOrganizationKeys(ctx); errors.Is(err, db.ErrNoEncryptionKey) {
+		log.Printf("Warning: %d organizations have provider keys stored in clear, set ORG_KEYS_ENCRYPTION_KEY", n)
+	} else if err != nil {
+		log.Fatalf("Failed to encrypt organization provider keys: %v", err)
+	} else if n > 0 {
+		log.Printf("Encrypted the provider keys of %d organizations", n)
+	}
+
+	// Monthly audit partitions are kept ahead even when retention is off or can't archive
+	go retention.KeepPartitions(ctx, queries)
+
+	// Audit retention: archive and purge old partitions
+	if svc, err := retention.New(cfg, queries); err != nil {
+		log.Printf("Warning: Retention disabled: %v", err)
+	} else {
+		go svc.Start(ctx)
+	}
+
+	// Expired idempotency keys, cached responses and cached images, whatever retention does
+	go purgeExpired(ctx, cfg, queries)
 
 	// Create and start server
 	server := api.NewServer(cfg, queries)
 
-	// Graceful shutdown
+	// Graceful shutdown: stop accepting requests, then drain in-flight enrichment
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 
-		log.Println("Shutting down...")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		log.Printf("Shutting down, waiting up to %s for in-flight enrichment...", cfg.Server.ShutdownTimeout)
+		stop()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 		defer cancel()
 
 		if err := server.Shutdown(shutdownCtx); err != nil {
@@ -57,26 +89,62 @@ func main() {
 	}()
 
 	log.Printf("Starting server on port %s", cfg.Server.Port)
-	if err := server.Start(ctx); err != nil {
+	err = server.Start(ctx)
+	if err != nil {
 		log.Printf("Server stopped: %v", err)
 	}
+	if errors.Is(err, http.ErrServerClosed) {
+		// Start returns as soon as the listener closes; exit once draining is done
+		<-shutdownDone
+	}
 }
 
-func runMigrations(databaseURL string) error {
-	db, err := sql.Open("postgres", databaseURL)
-	if err != nil {
-		return err
+// purgeInterval is how often entries past their TTL are deleted
+const purgeInterval = time.Hour
+
+// purgeExpired deletes expired idempotency keys, cached model responses and cached images
+// now, then every purgeInterval until ctx is cancelled
+func purgeExpired(ctx context.Context, cfg *config.Config, queries *db.Queries) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := queries.PurgeExpiredIdempotencyKeys(ctx); err != nil {
+			log.Printf("Idempotency key purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d expired idempotency keys", n)
+		}
+		if n, err := queries.PurgeExpiredResponses(ctx); err != nil {
+			log.Printf("Response cache purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d expired cached responses", n)
+		}
+		if n, err := imageproxy.PurgeExpired(cfg); err != nil {
+			log.Printf("Image cache purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d expired cached images", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-	defer db.Close()
+}
 
-	if err := goose.SetDialect("postgres"); err != nil {
+func runMigrations(databaseURL string) error {
+	conn, err := sql.Open("postgres", databaseURL)
+	if err != nil {
 		return err
 	}
+	defer conn.Close()
 
 	log.Println("Running database migrations...")
-	if err := goose.Up(db, "migrations"); err != nil {
+	applied, err := db.Migrate(context.Background(), conn)
+	if err != nil {
 		return err
 	}
-	log.Println("Migrations completed")
+	log.Printf("Migrations completed (%d applied)", applied)
 	return nil
 }
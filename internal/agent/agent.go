@@ -4,31 +4,56 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/productdata"
 	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/retry"
+	"github.com/benjamincozon/feedenrich/internal/taxonomy"
+	"github.com/benjamincozon/feedenrich/internal/websearch"
 	"github.com/google/uuid"
-	openai "github.com/sashabaranov/go-openai"
 )
 
-// TokenTracker interface for recording token usage
+// TokenTracker records token usage and reports spend for budget checks (see WithBudget)
 type TokenTracker interface {
 	RecordTokenUsage(ctx context.Context, model string, promptTokens, completionTokens int, costUSD float64) error
+	RecordTokenAttribution(ctx context.Context, a models.TokenAttribution, promptTokens, completionTokens int, costUSD float64) error
+	GetDatasetBudget(ctx context.Context, datasetID uuid.UUID) (*models.Budget, error)
+	GetDatasetOrganization(ctx context.Context, datasetID uuid.UUID) (*models.Organization, error)
+	GetBudgetSpend(ctx context.Context, datasetID uuid.UUID) (total, org, dataset models.BudgetSpend, err error)
 }
 
 // Agent is the main enrichment agent that reasons and uses tools
 type Agent struct {
 	config       *config.Config
-	client       *openai.Client
+	client       llm.Client
 	toolbox      *tools.Toolbox
 	callbacks    Callbacks
 	tokenTracker TokenTracker
+	scorer       *tools.QualityScorer
+	images       *imageproxy.Proxy // downscales images before vision calls, measures them
+	vision       *visionCache
+	responses    ResponseCache // optimization answers by input hash; nil = off
+	pricing      *pricing
+	running      *runningSessions // resumable tools sessions of this process
+	taxonomy     *taxonomy.Taxonomy // google_product_category mapping
+	feedback      FeedbackSource  // review decisions told to the prompts; nil = off
+	feedbackCache *feedbackCache
+	brands        BrandSource // brand dictionaries of the datasets; nil = off
+	brandCache    *brandCache
+	trust         TrustSource // trusted domains of the datasets; nil = none
+	trustCache    *trustCache
+	imageHashes   ImageHashStore // perceptual hashes of the dataset's images; nil = no placeholder check
+	imageChecks   ImageCheckSource // last checks of the products' image_link; nil = not read
+	model        string // candidate model of a shadow variant, replaces the routed models; empty = routed
+	instructions string // extra system prompt instructions (shadow variants)
 }
 
 // Callbacks for streaming agent events
@@ -53,6 +78,21 @@ type Session struct {
 	Sources   []models.Source
 	Status    string
 	StartedAt time.Time
+	Thresholds models.Thresholds // confidence/risk thresholds the run used
+	HumanReviews []models.HumanReview // items escalated to a reviewer
+	Model        string               // model that served the optimization call
+	Group        OptimizationGroup    // optimization group (module) of the run
+	Engine       Engine               // execution path of the run, see RunEngine
+	PipelineRun  *models.PipelineRun  // stages, rejections and evidence of a pipeline engine run
+	Cached       bool                 // the optimization answer came from the response cache
+	LLMCalls     []models.LLMCall     // call log, when LLM_CALL_LOG is on
+	Checkpoint   *models.SessionCheckpoint // set when a tools run pauses, see RunSteps
+	usage        *UsageMeter          // LLM calls made during the run
+}
+
+// Usage returns the tokens and cost of the LLM calls made during the run
+func (s Session) Usage() models.RunUsage {
+	return s.usage.Usage()
 }
 
 // SessionSummary is returned when the agent completes
@@ -153,12 +193,81 @@ func GetAllGroups() []GroupInfo {
 
 // New creates a new Agent
 func New(cfg *config.Config, toolbox *tools.Toolbox) *Agent {
-	client := openai.NewClient(cfg.OpenAI.APIKey)
+	client := llm.New(cfg)
+	var judge llm.Client
+	if cfg.Quality.JudgeEnabled {
+		judge = client
+	}
+	for group := range cfg.Agent.GroupModels {
+		if !knownGroup(OptimizationGroup(group)) {
+			fmt.Printf("Agent: AGENT_GROUP_MODELS has unknown group %q, ignored\n", group)
+		}
+	}
 	return &Agent{
 		config:  cfg,
 		client:  client,
 		toolbox: toolbox,
+		scorer:  tools.NewQualityScorer(judge, cfg.Quality.JudgeModel),
+		images:  imageproxy.New(cfg),
+		vision:  newVisionCache(),
+		pricing: newPricing(),
+		running: newRunningSessions(),
+		taxonomy: taxonomy.Open(cfg.Agent.TaxonomyFile),
+	}
+}
+
+// knownGroup reports whether a group can be routed to a model
+func knownGroup(group OptimizationGroup) bool {
+	if group == GroupAll {
+		return true
+	}
+	for _, g := range GetAllGroups() {
+		if g.ID == group {
+			return true
+		}
 	}
+	return false
+}
+
+// modelFor returns the model handling a group's optimization call
+func (a *Agent) modelFor(group OptimizationGroup) string {
+	if a.model != "" {
+		return a.model
+	}
+	return a.config.GroupModel(string(group))
+}
+
+// visionModelFor returns the model handling image analysis
+func (a *Agent) visionModelFor() string {
+	if a.model != "" {
+		return a.model
+	}
+	return a.config.Agent.VisionModel
+}
+
+// Variant returns a copy of the agent using another model for every call (instead
+// of the routed ones) and/or extra instructions, without callbacks. Used for shadow evaluation.
+func (a *Agent) Variant(model, instructions string) *Agent {
+	v := *a
+	v.callbacks = Callbacks{}
+	if model != "" {
+		v.model = model
+	}
+	v.instructions = instructions
+	return &v
+}
+
+// Model returns the model used for optimization calls of all groups
+func (a *Agent) Model() string {
+	return a.modelFor(GroupAll)
+}
+
+// withInstructions appends variant instructions to a system prompt
+func (a *Agent) withInstructions(systemPrompt string) string {
+	if a.instructions == "" {
+		return systemPrompt
+	}
+	return systemPrompt + "\n\n=== ADDITIONAL INSTRUCTIONS ===\n" + a.instructions
 }
 
 // SetCallbacks sets the event callbacks
@@ -172,26 +281,43 @@ func (a *Agent) SetTokenTracker(tracker TokenTracker) {
 }
 
 // recordUsage records token usage to the database
-func (a *Agent) recordUsage(ctx context.Context, model string, usage openai.Usage) {
-	if a.tokenTracker == nil {
+func (a *Agent) recordUsage(ctx context.Context, model string, usage llm.Usage) {
+	a.recordUsageCost(ctx, model, usage, a.costUSD(ctx, model, usage.PromptTokens, usage.CompletionTokens))
+}
+
+// recordUsageCost records token usage at a known cost (discounted batch calls)
+func (a *Agent) recordUsageCost(ctx context.Context, model string, usage llm.Usage, cost float64) {
+	if m := usageMeterFrom(ctx); m != nil {
+		m.add(usage.PromptTokens, usage.CompletionTokens, cost)
+	}
+	if session := sessionFrom(ctx); session != nil {
+		session.usage.add(usage.PromptTokens, usage.CompletionTokens, cost)
+	}
+	// Simulated usage is an estimate, not spend
+	if a.tokenTracker == nil || llm.Simulated(ctx) {
 		return
 	}
-	
-	// Calculate cost based on model
-	// GPT-4o-mini pricing (as of 2024): $0.15/1M input, $0.60/1M output
-	// GPT-4o pricing: $2.50/1M input, $10.00/1M output
-	var costUSD float64
-	switch model {
-	case openai.GPT4oMini, openai.GPT4oMini20240718:
-		costUSD = float64(usage.PromptTokens)*0.00000015 + float64(usage.CompletionTokens)*0.0000006
-	case openai.GPT4o, openai.GPT4o20240513:
-		costUSD = float64(usage.PromptTokens)*0.0000025 + float64(usage.CompletionTokens)*0.00001
-	default:
-		// Default to GPT-4o-mini pricing
-		costUSD = float64(usage.PromptTokens)*0.00000015 + float64(usage.CompletionTokens)*0.0000006
+	_ = a.tokenTracker.RecordTokenUsage(ctx, model, usage.PromptTokens, usage.CompletionTokens, cost)
+	if attr, ok := attributionFrom(ctx, model); ok {
+		_ = a.tokenTracker.RecordTokenAttribution(ctx, attr, usage.PromptTokens, usage.CompletionTokens, cost)
 	}
-	
-	_ = a.tokenTracker.RecordTokenUsage(ctx, model, usage.PromptTokens, usage.CompletionTokens, costUSD)
+}
+
+// attributionFrom attributes a call to the session, product and module of the run it
+// is part of, and to its dataset; false when ctx carries neither
+func attributionFrom(ctx context.Context, model string) (models.TokenAttribution, bool) {
+	attr := models.TokenAttribution{Model: model}
+	known := false
+	if session := sessionFrom(ctx); session != nil {
+		attr.SessionID, attr.ProductID, attr.Module = &session.ID, &session.ProductID, string(session.Group)
+		if session.Product != nil {
+			attr.DatasetID, known = session.Product.DatasetID, true
+		}
+	}
+	if s := budgetFrom(ctx); s != nil {
+		attr.DatasetID, known = s.datasetID, true
+	}
+	return attr, known
 }
 
 // Run starts the agent on a product - uses FAST mode by default (single API call)
@@ -201,46 +327,82 @@ func (a *Agent) Run(ctx context.Context, product *models.Product, goal string) (
 
 // RunWithGroup starts the agent on a product with a specific optimization group
 func (a *Agent) RunWithGroup(ctx context.Context, product *models.Product, goal string, group OptimizationGroup) (*Session, error) {
-	session := &Session{
-		ID:        uuid.New(),
-		ProductID: product.ID,
-		Goal:      goal,
-		Product:   product,
-		Traces:    []models.AgentTrace{},
-		Proposals: []models.Proposal{},
-		Sources:   []models.Source{},
-		Status:    "running",
-		StartedAt: time.Now(),
-	}
+	return a.RunGroups(ctx, product, goal, []OptimizationGroup{group})
+}
 
-	// Use group-specific optimization
-	proposals, err := a.runGroupOptimization(ctx, product, group)
+// RunGroups runs several optimization groups on a product in one session, one
+// optimization call per group in the given order (none = all). Each proposal records its
+// group as its module, and each group gets its own trace.
+func (a *Agent) RunGroups(ctx context.Context, product *models.Product, goal string, groups []OptimizationGroup) (*Session, error) {
+	if len(groups) == 0 {
+		groups = []OptimizationGroup{GroupAll}
+	}
+	session := a.newSession(ctx, product, goal)
+	session.Group = groups[0]
+	ctx, retries := retry.WithCounter(ctx)
+	ctx = withSession(ctx, session)
+	if a.config.LLM.CallLog {
+		calls := &llm.CallLog{}
+		ctx = llm.WithCallLog(ctx, calls)
+		defer session.keepCalls(calls)
+	}
+	ctx, err := a.WithBudget(ctx, product.DatasetID)
 	if err != nil {
-		if a.callbacks.OnError != nil {
-			a.callbacks.OnError(err)
-		}
 		session.Status = "failed"
 		return session, err
 	}
 
-	session.Proposals = proposals
-	session.Status = "completed"
+	allCached := true
+	for i, group := range groups {
+		// The session's group attributes token usage to the module being run
+		session.Group = group
+		session.Cached = false
+		started, usageBefore, retriesBefore := time.Now(), session.Usage(), retries.Value()
 
-	// Single trace for the execution
-	session.Traces = append(session.Traces, models.AgentTrace{
-		ID:         uuid.New(),
-		SessionID:  session.ID,
-		StepNumber: 1,
-		Thought:    fmt.Sprintf("Group %s: analyzed product and generated %d proposals", group, len(proposals)),
-		ToolName:   string(group),
-		DurationMs: int(time.Since(session.StartedAt).Milliseconds()),
-		CreatedAt:  time.Now(),
-	})
+		// Use group-specific optimization
+		proposals, err := a.runGroupOptimization(ctx, product, group)
+		if err != nil {
+			if a.callbacks.OnError != nil {
+				a.callbacks.OnError(err)
+			}
+			session.Status = "failed"
+			return session, err
+		}
+		for j := range proposals {
+			proposals[j].Module = string(group)
+		}
+		a.ScoreProposals(ctx, product, proposals)
+		session.Proposals = append(session.Proposals, proposals...)
+		allCached = allCached && session.Cached
+
+		// One trace per group
+		usage := session.Usage()
+		tokens := usage.PromptTokens + usage.CompletionTokens - usageBefore.PromptTokens - usageBefore.CompletionTokens
+		thought := fmt.Sprintf("Group %s: analyzed product and generated %d proposals", group, len(proposals))
+		if session.Cached {
+			thought = fmt.Sprintf("Group %s: product data unchanged, reused the cached answer (%d proposals)", group, len(proposals))
+		}
+		session.Traces = append(session.Traces, models.AgentTrace{
+			ID:         uuid.New(),
+			SessionID:  session.ID,
+			StepNumber: i + 1,
+			Thought:    thought,
+			ToolName:   string(group),
+			DurationMs: int(time.Since(started).Milliseconds()),
+			TokensUsed: tokens,
+			Retries:    retries.Value() - retriesBefore,
+			Model:      session.Model,
+			CreatedAt:  time.Now(),
+		})
+	}
+	session.Cached = allCached
+	session.Status = "completed"
 
 	if a.callbacks.OnComplete != nil {
+		usage := session.Usage()
 		summary := SessionSummary{
-			TotalSteps:       1,
-			TokensUsed:       0,
+			TotalSteps:       len(session.Traces),
+			TokensUsed:       usage.PromptTokens + usage.CompletionTokens,
 			DurationMs:       time.Since(session.StartedAt).Milliseconds(),
 			ProposalsCreated: len(session.Proposals),
 		}
@@ -250,6 +412,54 @@ func (a *Agent) RunWithGroup(ctx context.Context, product *models.Product, goal
 	return session, nil
 }
 
+// newSession starts a session on a product with the thresholds of ctx
+func (a *Agent) newSession(ctx context.Context, product *models.Product, goal string) *Session {
+	return &Session{
+		ID:        uuid.New(),
+		ProductID: product.ID,
+		Goal:      goal,
+		Product:   product,
+		Traces:    []models.AgentTrace{},
+		Proposals: []models.Proposal{},
+		Sources:   []models.Source{},
+		Status:    "running",
+		StartedAt: time.Now(),
+		Thresholds: a.thresholdsFrom(ctx),
+		Engine:    EngineAgent,
+		usage:     &UsageMeter{},
+	}
+}
+
+// ScoreProposals sets the quality score of each proposal in place
+func (a *Agent) ScoreProposals(ctx context.Context, product *models.Product, proposals []models.Proposal) {
+	if len(proposals) == 0 {
+		return
+	}
+	candidates := make([]tools.ProposalCandidate, len(proposals))
+	for i, p := range proposals {
+		c := tools.ProposalCandidate{Field: p.Field, After: p.AfterValue, Rationale: p.Rationale, Confidence: p.Confidence}
+		if p.BeforeValue != nil {
+			c.Before = *p.BeforeValue
+		}
+		json.Unmarshal(p.Sources, &c.Sources)
+		candidates[i] = c
+	}
+
+	data := product.CurrentData
+	if len(data) == 0 {
+		data = product.RawData
+	}
+	scores, judge := a.scorer.Score(ctx, data, candidates)
+	if judge != nil {
+		a.recordUsage(ctx, judge.Model, judge.Usage)
+	}
+	for i, score := range scores {
+		value := score.Score
+		proposals[i].QualityScore = &value
+		proposals[i].QualityBreakdown, _ = json.Marshal(score)
+	}
+}
+
 // runGroupOptimization runs optimization for a specific group
 func (a *Agent) runGroupOptimization(ctx context.Context, product *models.Product, group OptimizationGroup) ([]models.Proposal, error) {
 	if group == GroupAll {
@@ -262,6 +472,28 @@ func (a *Agent) runGroupOptimization(ctx context.Context, product *models.Produc
 
 // runFastMode executes optimization in a single API call
 func (a *Agent) runFastMode(ctx context.Context, product *models.Product) ([]models.Proposal, error) {
+	return a.optimize(ctx, product, GroupAll, a.fastModeRequest, a.fastModeProposals)
+}
+
+// fastModeRequest gathers the image and web context of a product and builds the
+// optimization call of the fast mode
+func (a *Agent) fastModeRequest(ctx context.Context, product *models.Product) llm.Request {
+	userPrompt := fmt.Sprintf("%s\n\nGenerate optimization proposals.", a.fastModeContext(ctx, product))
+
+	return llm.Request{
+		Model: a.modelFor(GroupAll),
+		Stage: llm.StageFastMode,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: a.withFeedback(ctx, product.DatasetID, a.withInstructions(fastModeSystemPrompt))},
+			{Role: llm.RoleUser, Content: userPrompt},
+		},
+		JSON: true,
+	}
+}
+
+// fastModeContext gathers the image and web context of a product and returns it with
+// the product data, as the fast mode prompt presents it
+func (a *Agent) fastModeContext(ctx context.Context, product *models.Product) string {
 	var imageContext string
 	var webContext string
 	
@@ -272,60 +504,14 @@ func (a *Agent) runFastMode(ctx context.Context, product *models.Product) ([]mod
 		if a.callbacks.OnLog != nil {
 			a.callbacks.OnLog("⚠️ No image URL - skipping image analysis")
 		}
-	} else {
-		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog("👁️ Analyzing product image...")
-		}
-		
-		// Full image analysis - extract ALL visual attributes
-		imgResp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-			Model: openai.GPT4oMini,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role: openai.ChatMessageRoleUser,
-					MultiContent: []openai.ChatMessagePart{
-						{
-							Type: openai.ChatMessagePartTypeText,
-							Text: `Analyze this product image. Extract ALL visible attributes:
-{
-  "color": "main color(s)",
-  "material": "visible material (cotton, leather, metal, etc.)",
-  "pattern": "pattern if any (solid, striped, floral, etc.)",
-  "gender": "target gender if obvious (male/female/unisex)",
-  "product_type": "what type of product",
-  "style": "style description",
-  "observations": ["list of additional visual details"]
-}
-Use null for attributes not clearly visible. Be precise and factual.`,
-						},
-						{
-							Type:     openai.ChatMessagePartTypeImageURL,
-							ImageURL: &openai.ChatMessageImageURL{URL: imageURL},
-						},
-					},
-				},
-			},
-			ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
-			MaxTokens:      250,
-			Temperature:    0.1,
-		})
-		
-		if err != nil {
-			if a.callbacks.OnLog != nil {
-				a.callbacks.OnLog(fmt.Sprintf("❌ Image analysis failed: %v", err))
-			}
-		} else if len(imgResp.Choices) > 0 {
-			imageContext = "\n\n=== IMAGE ANALYSIS ===\n" + imgResp.Choices[0].Message.Content
-			a.recordUsage(ctx, openai.GPT4oMini, imgResp.Usage)
-			
-			if a.callbacks.OnLog != nil {
-				a.callbacks.OnLog(fmt.Sprintf("✅ Image: %s", imgResp.Choices[0].Message.Content))
-			}
-		}
+	} else if a.brokenImage(ctx, product, imageURL) == "" {
+		// Full image analysis - ALL visual attributes, image quality and printed identifiers
+		imageContext = a.imageContext(ctx, imageURL, visionAttributes, visionQuality, visionIdentifiers)
 	}
+	imageContext += a.imageCodeContext(a.imageBarcodes(ctx, product))
 	
-	// === 2. WEB SEARCH (if GTIN/EAN or brand+title available) ===
-	webContext = a.runWebSearch(ctx, product)
+	// === 2. GTIN REGISTRY, then WEB SEARCH (if GTIN/EAN or brand+title available) ===
+	webContext = a.gtinRegistryContext(ctx, product) + a.runWebSearch(ctx, product)
 	
 	// Log source aggregation
 	sources := []string{"Feed"}
@@ -339,8 +525,11 @@ Use null for attributes not clearly visible. Be precise and factual.`,
 		a.callbacks.OnLog(fmt.Sprintf("🔄 Combining sources: %s", strings.Join(sources, " + ")))
 	}
 
-	// Main optimization call
-	systemPrompt := `You are a GMC (Google Merchant Center) product data optimizer. Analyze and generate optimization proposals.
+	return fmt.Sprintf("Product Data:\n%s%s%s", productdata.ForPrompt(a.config, product.RawData), imageContext, webContext)
+}
+
+// fastModeSystemPrompt instructs the fast mode optimization call
+const fastModeSystemPrompt = `You are a GMC (Google Merchant Center) product data optimizer. Analyze and generate optimization proposals.
 
 === MULTILINGUAL FIELD NAMES ===
 Product data may contain fields in French or other languages. Common mappings:
@@ -437,26 +626,12 @@ OPTIONAL BUT VALUABLE:
    STRONGLY RECOMMENDED (MUST fill if empty):
    - condition: Default "new" unless indicated otherwise → ALWAYS PROPOSE IF EMPTY
    - product_type: Build hierarchy from category/title (e.g., "Apparel > Women > Dresses")
-   - google_product_category: Map to Google taxonomy ID
+   - google_product_category: mapped in code from the Google taxonomy, do NOT propose it
    
    SIZE DETAILS (IMPORTANT for apparel):
-   - size_system: Infer from MULTIPLE signals (priority order):
-     1. SIZE VALUE PATTERNS:
-        * Numeric 34-50 (e.g., "38", "42", "44/46") → "EU"
-        * Letters S/M/L/XL/XXL → "US" or "UK" (check link domain)
-        * UK sizes 6-20 (women) or 34-48 (men) → "UK"
-        * US sizes 0-16 (women) or 28-44 (men) → "US"
-        * Shoe sizes 35-48 → "EU", 5-15 → US/UK
-     2. LINK/URL DOMAIN:
-        * .fr, .de, .it, .es, .eu → "EU"
-        * .co.uk → "UK"
-        * .com (with USD) → "US"
-     3. CURRENCY in price field:
-        * EUR/€ → "EU"
-        * GBP/£ → "UK"
-        * USD/$ → "US"
-     4. Default → "EU" for European merchants
-     → ALWAYS PROPOSE IF EMPTY FOR APPAREL
+   - size_system: inferred in code when the size value, link domain or currency decide it
+     (e.g. "52" → EU, .co.uk → UK). Propose it only when they leave it ambiguous, from
+     other evidence (brand origin, size charts in the description)
    - size_type: "regular" by default, "plus"/"petite"/"tall"/"maternity" if indicated
    
    VISUAL ATTRIBUTES (from image):
@@ -521,29 +696,13 @@ ALWAYS combine feed + image for title and description:
 - NO INVENTION: Only use facts from feed data or image analysis
 - Be GENEROUS: Propose improvements that could be rejected rather than miss opportunities
 - Generate AT LEAST 3-5 proposals for any product with room for improvement
-- ALWAYS fill these if empty: condition (→"new"), age_group (→"adult"), size_system (→infer from currency)
+- ALWAYS fill these if empty: condition (→"new"), age_group (→"adult")
 - For APPAREL: ALWAYS check AND PROPOSE: color, gender, age_group, size, size_system, condition
 - DO NOT skip fields just because they seem "optional" - GMC rewards completeness
 - ALWAYS specify the source in your proposal: "feed", "image", or "inferred"`
 
-	userPrompt := fmt.Sprintf("Product Data:\n%s%s%s\n\nGenerate optimization proposals.", string(product.RawData), imageContext, webContext)
-
-	resp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: openai.GPT4oMini,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-			{Role: openai.ChatMessageRoleUser, Content: userPrompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
-		Temperature:    0.3,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("optimization call failed: %w", err)
-	}
-
-	// Track main optimization tokens
-	a.recordUsage(ctx, openai.GPT4oMini, resp.Usage)
-
+// fastModeProposals parses the answer of the fast mode optimization call
+func (a *Agent) fastModeProposals(ctx context.Context, product *models.Product, resp *llm.Response) ([]models.Proposal, error) {
 	// Parse response
 	var output struct {
 		Score     float64 `json:"score"`
@@ -558,18 +717,24 @@ ALWAYS combine feed + image for title and description:
 		} `json:"proposals"`
 	}
 
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &output); err != nil {
+	if err := json.Unmarshal([]byte(resp.Content), &output); err != nil {
 		return nil, fmt.Errorf("parse response: %w", err)
 	}
 
 	// Convert to models.Proposal
+	thresholds := a.thresholdsFrom(ctx)
+	code := a.codeProposals(ctx, product, GroupAll)
 	var proposals []models.Proposal
 	for _, p := range output.Proposals {
+		p.After = normalizeModelValue(p.Field, p.After)
 		// Skip invalid proposals
 		if p.After == "" || p.After == p.Before {
 			continue
 		}
-		if p.Confidence < 0.3 {
+		if !a.keepProposal(thresholds, p.Field, p.Confidence, p.RiskLevel) {
+			continue
+		}
+		if a.dropModelProposal(ctx, GroupAll, p.Field, p.After, code) {
 			continue
 		}
 		
@@ -582,7 +747,7 @@ ALWAYS combine feed + image for title and description:
 		}
 
 		beforeValue := p.Before
-		sourceJSON, _ := json.Marshal([]models.Source{{Type: p.Source, Confidence: p.Confidence}})
+		sourceJSON, _ := json.Marshal([]models.Source{{Type: p.Source, Confidence: p.Confidence, Verified: p.Confidence >= thresholds.AutoVerifyConfidence}})
 
 		proposal := models.Proposal{
 			ID:          uuid.New(),
@@ -603,6 +768,8 @@ ALWAYS combine feed + image for title and description:
 			a.callbacks.OnProposal(proposal)
 		}
 	}
+	proposals = append(proposals, code...)
+	proposals = append(proposals, a.cleanupProposals(product, GroupAll, proposals)...)
 
 	return proposals, nil
 }
@@ -612,7 +779,17 @@ func (a *Agent) runFocusedMode(ctx context.Context, product *models.Product, gro
 	if a.callbacks.OnLog != nil {
 		a.callbacks.OnLog(fmt.Sprintf("🎯 Running focused optimization: %s", group))
 	}
-	
+	request := func(ctx context.Context, product *models.Product) llm.Request {
+		return a.focusedModeRequest(ctx, product, group)
+	}
+	parse := func(ctx context.Context, product *models.Product, resp *llm.Response) ([]models.Proposal, error) {
+		return a.focusedModeProposals(ctx, product, group, resp)
+	}
+	return a.optimize(ctx, product, group, request, parse)
+}
+
+// focusedModeRequest gathers the context a group needs and builds its optimization call
+func (a *Agent) focusedModeRequest(ctx context.Context, product *models.Product, group OptimizationGroup) llm.Request {
 	// Get group-specific context
 	var imageContext string
 	var webContext string
@@ -620,36 +797,46 @@ func (a *Agent) runFocusedMode(ctx context.Context, product *models.Product, gro
 	// Only run image analysis for visual-related groups
 	if group == GroupRecommendedAttrs || group == GroupImageAnalysis || group == GroupTitleOptimization {
 		imageURL := extractImageURL(product.RawData)
-		if imageURL != "" {
+		if imageURL != "" && a.brokenImage(ctx, product, imageURL) == "" {
 			imageContext = a.runImageAnalysisForGroup(ctx, imageURL, group)
 		}
 	}
+	if measuresImages(group) {
+		imageContext += a.imageMeasurementContext(ctx, product)
+	}
 	
 	// Only run web search for specific groups
 	if group == GroupRequiredAttributes || group == GroupRecommendedAttrs {
-		webContext = a.runWebSearch(ctx, product)
+		webContext = a.gtinRegistryContext(ctx, product) + a.runWebSearch(ctx, product)
+	}
+
+	if readsImageCodes(group) {
+		webContext += a.imageCodeContext(a.imageCodes(ctx, product))
+	}
+
+	// GTIN validity is checked here, not by the model
+	if group == GroupCriticalErrors {
+		webContext += gtinContext(product.RawData)
 	}
 	
 	// Get the group-specific prompt
 	systemPrompt := getGroupPrompt(group)
 	userPrompt := fmt.Sprintf("Product Data:\n%s%s%s\n\nGenerate optimization proposals for %s only.", 
-		string(product.RawData), imageContext, webContext, group)
+		productdata.ForPrompt(a.config, product.RawData), imageContext, webContext, group)
 	
-	resp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: openai.GPT4oMini,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-			{Role: openai.ChatMessageRoleUser, Content: userPrompt},
+	return llm.Request{
+		Model: a.modelFor(group),
+		Stage: llm.StageFocusedMode,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: a.withFeedback(ctx, product.DatasetID, a.withInstructions(systemPrompt))},
+			{Role: llm.RoleUser, Content: userPrompt},
 		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
-		Temperature:    0.3,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("optimization call failed: %w", err)
+		JSON: true,
 	}
-	
-	a.recordUsage(ctx, openai.GPT4oMini, resp.Usage)
-	
+}
+
+// focusedModeProposals parses the answer of a group's optimization call
+func (a *Agent) focusedModeProposals(ctx context.Context, product *models.Product, group OptimizationGroup, resp *llm.Response) ([]models.Proposal, error) {
 	// Parse response (same structure as runFastMode)
 	var output struct {
 		Score     float64 `json:"score"`
@@ -670,24 +857,34 @@ func (a *Agent) runFocusedMode(ctx context.Context, product *models.Product, gro
 		} `json:"proposals"`
 	}
 	
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &output); err != nil {
+	if err := json.Unmarshal([]byte(resp.Content), &output); err != nil {
 		return nil, fmt.Errorf("parse response: %w", err)
 	}
 	
-	// Log issues if any
-	if len(output.Issues) > 0 && a.callbacks.OnLog != nil {
-		for _, issue := range output.Issues {
+	// Log issues if any; the serious ones go to the human review queue
+	for _, issue := range output.Issues {
+		if a.callbacks.OnLog != nil {
 			a.callbacks.OnLog(fmt.Sprintf("⚠️ %s: %s - %s", issue.Severity, issue.Field, issue.Description))
 		}
+		if checksPrices(group) && isPriceField(issue.Field) {
+			continue // priceProposals queues the price problems
+		}
+		escalateIssue(ctx, issue.Field, issue.Severity, issue.Description)
 	}
 	
 	// Convert to models.Proposal
+	thresholds := a.thresholdsFrom(ctx)
+	code := a.codeProposals(ctx, product, group)
 	var proposals []models.Proposal
 	for _, p := range output.Proposals {
+		p.After = normalizeModelValue(p.Field, p.After)
 		if p.After == "" || p.After == p.Before {
 			continue
 		}
-		if p.Confidence < 0.3 {
+		if !a.keepProposal(thresholds, p.Field, p.Confidence, p.RiskLevel) {
+			continue
+		}
+		if a.dropModelProposal(ctx, group, p.Field, p.After, code) {
 			continue
 		}
 		
@@ -700,7 +897,7 @@ func (a *Agent) runFocusedMode(ctx context.Context, product *models.Product, gro
 		}
 		
 		beforeValue := p.Before
-		sourceJSON, _ := json.Marshal([]models.Source{{Type: p.Source, Confidence: p.Confidence}})
+		sourceJSON, _ := json.Marshal([]models.Source{{Type: p.Source, Confidence: p.Confidence, Verified: p.Confidence >= thresholds.AutoVerifyConfidence}})
 		
 		proposal := models.Proposal{
 			ID:          uuid.New(),
@@ -721,6 +918,8 @@ func (a *Agent) runFocusedMode(ctx context.Context, product *models.Product, gro
 			a.callbacks.OnProposal(proposal)
 		}
 	}
+	proposals = append(proposals, code...)
+	proposals = append(proposals, a.cleanupProposals(product, group, proposals)...)
 	
 	if a.callbacks.OnLog != nil {
 		a.callbacks.OnLog(fmt.Sprintf("✅ Generated %d proposals for %s", len(proposals), group))
@@ -789,74 +988,17 @@ func isDescriptionNotValue(value string, field string) bool {
 	return false
 }
 
-// runImageAnalysisForGroup runs group-specific image analysis
+// runImageAnalysisForGroup returns the part of the image analysis relevant to a group
 func (a *Agent) runImageAnalysisForGroup(ctx context.Context, imageURL string, group OptimizationGroup) string {
-	if a.callbacks.OnLog != nil {
-		a.callbacks.OnLog("👁️ Analyzing product image...")
-	}
-	
-	var prompt string
 	switch group {
 	case GroupImageAnalysis:
-		prompt = `Analyze this product image for QUALITY and COMPLIANCE:
-{
-  "resolution": "estimated width x height",
-  "aspect_ratio": "1:1 or other ratio",
-  "background": "white/transparent/colored/lifestyle",
-  "product_fill": "percentage of frame (ideal 75-90%)",
-  "lighting": "professional/amateur/poor",
-  "shadows": true/false,
-  "watermarks": true/false,
-  "text_overlay": true/false,
-  "quality_score": 0-100,
-  "issues": ["list of issues found"],
-  "recommendations": ["suggested improvements"]
-}`
+		// Quality and compliance; dimensions, size and format are measured, see measureImages
+		return a.imageContext(ctx, imageURL, visionQuality)
 	case GroupTitleOptimization, GroupRecommendedAttrs:
-		prompt = `Extract visual attributes for product enrichment:
-{
-  "color": "main color(s)",
-  "material": "visible material",
-  "pattern": "pattern if any",
-  "gender": "target gender if obvious",
-  "product_type": "what type of product",
-  "style": "style description"
-}`
+		return a.imageContext(ctx, imageURL, visionAttributes)
 	default:
 		return ""
 	}
-	
-	imgResp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: openai.GPT4oMini,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role: openai.ChatMessageRoleUser,
-				MultiContent: []openai.ChatMessagePart{
-					{Type: openai.ChatMessagePartTypeText, Text: prompt},
-					{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: imageURL}},
-				},
-			},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
-		MaxTokens:      300,
-		Temperature:    0.1,
-	})
-	
-	if err != nil {
-		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog(fmt.Sprintf("❌ Image analysis failed: %v", err))
-		}
-		return ""
-	}
-	
-	if len(imgResp.Choices) > 0 {
-		a.recordUsage(ctx, openai.GPT4oMini, imgResp.Usage)
-		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog(fmt.Sprintf("✅ Image analyzed"))
-		}
-		return "\n\n=== IMAGE ANALYSIS ===\n" + imgResp.Choices[0].Message.Content
-	}
-	return ""
 }
 
 // getGroupPrompt returns the system prompt for a specific optimization group
@@ -923,14 +1065,13 @@ Focus ONLY on detecting these issues:
 - → Add to issues, cannot auto-fix
 
 💰 PRICE MISMATCH
-- price field format issues (missing currency, wrong format)
-- sale_price > price (error)
-- → Only propose if you can fix FORMAT (e.g., "29.99" → "29.99 EUR")
+- price, sale_price and sale_price_effective_date formats are fixed in code, and sale_price >= price is flagged in code
+- → Do NOT propose changes to these fields
 - → Cannot know the "correct" price - add to issues for human review
 
 📦 AVAILABILITY MISMATCH  
-- Invalid availability value
-- → Can propose valid values: in_stock, out_of_stock, preorder, backorder
+- Vendor availability values ("en stock", "1", "true") are mapped to GMC values in code
+- → Do NOT propose availability; add values that are not availabilities to issues
 
 🔗 INVALID URLs
 - Malformed URLs (missing http/https, invalid characters)
@@ -938,10 +1079,9 @@ Focus ONLY on detecting these issues:
 - → Cannot verify if URL works - add to issues
 
 🏷️ INVALID GTIN
-- Wrong number of digits
-- Invalid checksum
-- Placeholder values (0000000000000)
-- → Add to issues, cannot invent valid GTIN
+- Checked deterministically before this call (length, check digit, placeholder, GS1 prefix):
+  the result is given as "GTIN check" with the product data. Do NOT judge GTINs yourself.
+- → If the check says INVALID, add it to issues as given, cannot invent valid GTIN
 
 🖼️ IMAGE POLICY VIOLATIONS
 - URL format issues
@@ -956,7 +1096,7 @@ If a field exists and is valid, leave it alone. Content optimization is a separa
 
 What you CAN propose:
 ✅ condition: "new" if field is EMPTY (default value)
-✅ brand: Fix CAPITALIZATION only if brand exists (e.g., "nike" → "Nike")
+✅ brand: casing and spelling are fixed in code from the catalog's brand dictionary - do NOT propose them
 ✅ title: ONLY if EMPTY or too short (<10 chars) - add basic info from other fields
 ✅ description: ONLY if EMPTY or too short (<50 chars)
 
@@ -989,9 +1129,9 @@ What you CAN propose (only for EMPTY fields):
 ✅ pattern: From image - only if field is empty
 ✅ gender: From context - only if field is empty (male/female/unisex)
 ✅ age_group: "adult" as default - only if field is empty
-✅ size_system: Infer from currency/domain - only if field is empty
+✅ size_system: inferred in code from size/domain/currency - only propose if they are ambiguous
 ✅ product_type: Build from title - only if field is empty
-✅ google_product_category: Map to taxonomy - only if field is empty
+✅ google_product_category: mapped in code from the Google taxonomy - do NOT propose it
 
 CRITICAL RULES:
 ❌ If color="blue" exists → DO NOT propose a different color
@@ -1000,7 +1140,7 @@ CRITICAL RULES:
 ❌ Only propose for EMPTY/MISSING fields
 
 CHECK THESE FIELDS:
-📂 google_product_category - Empty? → Propose taxonomy mapping
+📂 google_product_category - Mapped in code, skip it
 🗂️ product_type - Empty? → Build hierarchy from title
 🎨 color - Empty? → Extract from image
 📏 size - Empty? → Extract from title if present
@@ -1100,11 +1240,12 @@ You CANNOT provide replacement image URLs, so:
 
 === IMAGE REQUIREMENTS TO CHECK ===
 
-📐 TECHNICAL SPECS
-- Minimum: 800x800 pixels
-- Recommended: 1200x1200 or higher
+📐 TECHNICAL SPECS (measured by the system, see IMAGE MEASUREMENTS)
+- Minimum: 100x100 pixels (250x250 for apparel), recommended 800x800 or higher
 - Max file size: 16MB
-- Formats: JPEG, PNG, GIF, WebP
+- Formats: JPEG, PNG, GIF, WebP, BMP, TIFF
+- Do NOT estimate resolution, file size, format, aspect ratio, background or product fill:
+  use the measured values and do not repeat the issues already listed with them
 
 📷 PRIMARY IMAGE RULES
 - Product on white or transparent background
@@ -1135,34 +1276,22 @@ NOTE: proposals MUST be empty - you cannot invent image URLs. All findings go to
 
 ⚠️ IMPORTANT - WHAT YOU CAN AND CANNOT DO:
 
-✅ CAN PROPOSE (format fixes only):
-- Add missing currency: "29.99" → "29.99 EUR"
-- Fix format: "29,99 EUR" → "29.99 EUR"
-- Remove sale_price if sale_price > price
+✅ ALREADY DONE IN CODE (do not propose):
+- Price and sale_price format: "29,99€" → "29.99 EUR"
+- sale_price_effective_date format: ISO 8601 start/end
+- sale_price >= price, currency mismatch and invalid date ranges are flagged for review
+
+✅ CAN PROPOSE:
+- promotion_id fixes backed by the feed
 
 ❌ CANNOT PROPOSE (add to issues instead):
 - The "correct" price (you don't know it)
 - Price from landing page (you can't scrape it)
 - Whether price matches landing page (flag as issue for human)
 
-=== VALIDATION RULES ===
-
-💰 PRICE FORMAT
-- Valid format: "29.99 EUR" or "USD 29.99"
-- Must include currency code (EUR, USD, GBP, etc.)
-- Use decimal point, not comma
-- If price is "29.99" without currency → propose "29.99 EUR" (infer from context)
-
-💸 SALE_PRICE LOGIC
-- If sale_price > price → add to issues (this is an error)
-- If sale_price exists, it must have same currency as price
-
-📅 DATE FORMAT
-- ISO 8601: 2024-01-15T00:00:00+01:00/2024-01-31T23:59:59+01:00
-
 === OUTPUT ===
-- Propose FORMAT fixes only (adding currency, fixing decimal)
-- Add to issues: price mismatches, invalid sale prices, expired dates
+- No proposals on price, sale_price or sale_price_effective_date
+- Add to issues: price mismatches with the landing page, expired promotions
 ` + baseOutput
 
 	default:
@@ -1215,12 +1344,17 @@ func extractImageURL(data json.RawMessage) string {
 	return ""
 }
 
-// runWebSearch searches for product info using Brave Search API
+// runWebSearch searches for product info with the configured search provider
 func (a *Agent) runWebSearch(ctx context.Context, product *models.Product) string {
-	// Check if Brave API key is configured
-	if a.config.WebSearch.APIKey == "" {
+	// Estimates skip the search: its results are not known without running it
+	if llm.Simulated(ctx) {
+		return ""
+	}
+	// Check if the search provider's API key is configured
+	search := websearch.For(a.config)
+	if !search.EnabledFor(ctx) {
 		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog("⚠️ Brave API key not configured - skipping web search")
+			a.callbacks.OnLog(fmt.Sprintf("⚠️ %s API key not configured - skipping web search", a.config.WebSearch.Provider))
 		}
 		return ""
 	}
@@ -1275,77 +1409,86 @@ func (a *Agent) runWebSearch(ctx context.Context, product *models.Product) strin
 		return ""
 	}
 	
-	// Call Brave Search API
-	searchURL := fmt.Sprintf("https://api.search.brave.com/res/v1/web/search?q=%s&count=3&extra_snippets=true",
-		url.QueryEscape(query))
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	// Call the search provider (cached per query, see WEBSEARCH_CACHE_TTL)
+	results, err := search.Search(ctx, query, 3)
 	if err != nil {
 		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog(fmt.Sprintf("❌ Web search request error: %v", err))
+			a.callbacks.OnLog(fmt.Sprintf("❌ Web search failed: %s", truncateString(err.Error(), 150)))
 		}
 		return ""
 	}
 	
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("X-Subscription-Token", a.config.WebSearch.APIKey)
-	
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
+	if len(results) == 0 {
 		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog(fmt.Sprintf("❌ Web search failed: %v", err))
+			a.callbacks.OnLog("⚠️ No web results found")
 		}
 		return ""
 	}
-	defer resp.Body.Close()
 	
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog(fmt.Sprintf("❌ Brave API error %d: %s", resp.StatusCode, truncateString(string(body), 100)))
+	// Build web context, results on the dataset's trusted domains first
+	trusted := a.trustedDomains(ctx, product.DatasetID)
+	results = slices.Clone(results) // shared with the search cache
+	isTrusted := func(r websearch.Result) bool { return trusted.Trusts(r.URL, brand) }
+	slices.SortStableFunc(results, func(x, y websearch.Result) int {
+		switch {
+		case isTrusted(x) && !isTrusted(y):
+			return -1
+		case isTrusted(y) && !isTrusted(x):
+			return 1
 		}
-		return ""
+		return 0
+	})
+	var webResults []string
+	for i, r := range results {
+		if i >= 3 {
+			break
+		}
+		source := r.URL
+		if isTrusted(r) {
+			source += " (trusted domain: prefer its facts)"
+		}
+		webResults = append(webResults, fmt.Sprintf("- %s\n  %s\n  Source: %s", r.Title, r.Description, source))
 	}
 	
-	var braveResp struct {
-		Web struct {
-			Results []struct {
-				Title       string `json:"title"`
-				URL         string `json:"url"`
-				Description string `json:"description"`
-			} `json:"results"`
-		} `json:"web"`
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog(fmt.Sprintf("✅ Web: Found %d results", len(webResults)))
 	}
 	
-	if err := json.NewDecoder(resp.Body).Decode(&braveResp); err != nil {
-		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog(fmt.Sprintf("❌ Parse Brave response: %v", err))
-		}
+	return "\n\n=== WEB SEARCH RESULTS ===\n" + strings.Join(webResults, "\n\n")
+}
+
+// gtinRegistryContext gives the prompt what the GTIN registries (GTIN_REGISTRIES) know of
+// the product's GTIN, "" when it has no valid GTIN or no registry knows it
+func (a *Agent) gtinRegistryContext(ctx context.Context, product *models.Product) string {
+	search := websearch.For(a.config)
+	if llm.Simulated(ctx) || !search.RegistriesEnabled() {
 		return ""
 	}
-	
-	if len(braveResp.Web.Results) == 0 {
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+	gtin := tools.NormalizeGTIN(getFieldValueFromMap(fields, "gtin"))
+	if gtin == "" || tools.CheckGTIN(gtin) != nil {
+		return ""
+	}
+	found, err := search.LookupGTIN(ctx, gtin)
+	if err != nil {
 		if a.callbacks.OnLog != nil {
-			a.callbacks.OnLog("⚠️ No web results found")
+			a.callbacks.OnLog(fmt.Sprintf("❌ GTIN registry lookup failed: %s", truncateString(err.Error(), 150)))
 		}
 		return ""
 	}
-	
-	// Build web context
-	var webResults []string
-	for i, r := range braveResp.Web.Results {
-		if i >= 3 {
-			break
-		}
-		webResults = append(webResults, fmt.Sprintf("- %s\n  %s\n  Source: %s", r.Title, r.Description, r.URL))
+	if found == nil {
+		return ""
+	}
+	known := found.Fields()
+	lines := make([]string, 0, len(known))
+	for _, field := range slices.Sorted(maps.Keys(known)) {
+		lines = append(lines, fmt.Sprintf("- %s: %s", field, known[field]))
 	}
-	
 	if a.callbacks.OnLog != nil {
-		a.callbacks.OnLog(fmt.Sprintf("✅ Web: Found %d results", len(webResults)))
+		a.callbacks.OnLog(fmt.Sprintf("✅ GTIN registry: %s knows %s", found.Registry, gtin))
 	}
-	
-	return "\n\n=== WEB SEARCH RESULTS ===\n" + strings.Join(webResults, "\n\n")
+	return fmt.Sprintf("\n\n=== GTIN REGISTRY (%s, trusted over web results) ===\n%s\n  Source: %s", found.Registry, strings.Join(lines, "\n"), found.URL)
 }
 
 func truncateString(s string, maxLen int) string {
@@ -1401,6 +1544,21 @@ func checkMissingVisualAttributes(data json.RawMessage) []string {
 	return missing
 }
 
+// gtinContext gives the critical errors group the result of the deterministic GTIN check
+// (tools.CheckGTIN), "" when the product has no GTIN
+func gtinContext(data json.RawMessage) string {
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	gtin := getFieldValueFromMap(fields, "gtin")
+	if gtin == "" {
+		return ""
+	}
+	if err := tools.CheckGTIN(gtin); err != nil {
+		return fmt.Sprintf("\n\nGTIN check: INVALID, %v", err)
+	}
+	return "\n\nGTIN check: valid"
+}
+
 func getFieldValueFromMap(fields map[string]interface{}, key string) string {
 	// Try exact match
 	if val, ok := fields[key]; ok {
@@ -1421,59 +1579,66 @@ func getFieldValueFromMap(fields map[string]interface{}, key string) string {
 
 func (a *Agent) executeStep(ctx context.Context, session *Session, stepNum int) (*models.AgentTrace, int, bool, error) {
 	startTime := time.Now()
+	ctx, retries := retry.WithCounter(ctx)
 
 	// Build messages for this step
-	messages := a.buildMessages(session)
+	messages := a.buildMessages(ctx, session)
 
-	// Call OpenAI with tools
-	resp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    a.config.OpenAI.Model,
+	// Call the model with tools
+	resp, err := a.client.Chat(ctx, llm.Request{
+		Model:    a.config.LLM.Model,
+		Stage:    llm.StageAgentStep,
 		Messages: messages,
-		Tools:    a.toolbox.OpenAITools(),
+		Tools:    a.toolbox.Tools(),
 	})
 	if err != nil {
-		return nil, 0, false, fmt.Errorf("openai call: %w", err)
+		return nil, 0, false, fmt.Errorf("llm call: %w", err)
 	}
+	a.recordUsage(ctx, resp.Model, resp.Usage)
+	session.Model = resp.Model
 
 	tokens := resp.Usage.TotalTokens
-	choice := resp.Choices[0]
 
 	// Check if agent wants to finish
-	if choice.FinishReason == openai.FinishReasonStop {
+	if resp.FinishReason == llm.FinishStop {
 		trace := &models.AgentTrace{
 			ID:         uuid.New(),
 			SessionID:  session.ID,
 			StepNumber: stepNum,
-			Thought:    choice.Message.Content,
+			Thought:    resp.Content,
 			DurationMs: int(time.Since(startTime).Milliseconds()),
 			TokensUsed: tokens,
+			Retries:    retries.Value(),
+		Model:      resp.Model,
 			CreatedAt:  time.Now(),
 		}
 		return trace, tokens, true, nil
 	}
 
 	// Process tool calls
-	if len(choice.Message.ToolCalls) == 0 {
+	if len(resp.ToolCalls) == 0 {
 		// No tool call, agent is thinking/done
 		trace := &models.AgentTrace{
 			ID:         uuid.New(),
 			SessionID:  session.ID,
 			StepNumber: stepNum,
-			Thought:    choice.Message.Content,
+			Thought:    resp.Content,
 			DurationMs: int(time.Since(startTime).Milliseconds()),
 			TokensUsed: tokens,
+			Retries:    retries.Value(),
+		Model:      resp.Model,
 			CreatedAt:  time.Now(),
 		}
-		if a.callbacks.OnThought != nil && choice.Message.Content != "" {
-			a.callbacks.OnThought(choice.Message.Content)
+		if a.callbacks.OnThought != nil && resp.Content != "" {
+			a.callbacks.OnThought(resp.Content)
 		}
 		return trace, tokens, true, nil
 	}
 
 	// Execute the first tool call
-	toolCall := choice.Message.ToolCalls[0]
-	toolName := toolCall.Function.Name
-	toolInput := json.RawMessage(toolCall.Function.Arguments)
+	toolCall := resp.ToolCalls[0]
+	toolName := toolCall.Name
+	toolInput := json.RawMessage(toolCall.Arguments)
 
 	if a.callbacks.OnToolCall != nil {
 		a.callbacks.OnToolCall(toolName, toolInput)
@@ -1495,12 +1660,14 @@ func (a *Agent) executeStep(ctx context.Context, session *Session, stepNum int)
 		ID:         uuid.New(),
 		SessionID:  session.ID,
 		StepNumber: stepNum,
-		Thought:    choice.Message.Content,
+		Thought:    resp.Content,
 		ToolName:   toolName,
 		ToolInput:  toolInput,
 		ToolOutput: toolOutputJSON,
 		DurationMs: int(time.Since(startTime).Milliseconds()),
 		TokensUsed: tokens,
+		Retries:    retries.Value(),
+		Model:      resp.Model,
 		CreatedAt:  time.Now(),
 	}
 
@@ -1512,7 +1679,7 @@ func (a *Agent) executeStep(ctx context.Context, session *Session, stepNum int)
 	return trace, tokens, false, nil
 }
 
-func (a *Agent) buildMessages(session *Session) []openai.ChatCompletionMessage {
+func (a *Agent) buildMessages(ctx context.Context, session *Session) []llm.Message {
 	// System prompt based on Dataïads GMC Feed Optimization Methodology
 	systemPrompt := fmt.Sprintf(`Tu es un agent d'enrichissement de données produit pour Google Merchant Center.
 
@@ -1567,54 +1734,57 @@ FLUX DE PRIORITÉ:
 
 === PROCESSUS ===
 1. analyze_product → évaluer qualité et conformité GMC
-2. web_search/fetch_page → sourcer informations manquantes
+2. lookup_gtin (si GTIN) puis web_search/fetch_page → sourcer informations manquantes
+   (registre GTIN = données du propriétaire de la marque : prioritaire sur le web)
+   (structured_data de fetch_page = JSON-LD/microdata/OpenGraph du marchand : à préférer au
+   texte de la page, evidence = la balise lue, ex. "json-ld Product.gtin13")
+   check_landing_page → écarts prix/disponibilité feed vs page produit → issues (pas de proposition)
 3. analyze_image → confirmer visuellement (couleur, style, matériau)
 4. optimize_field → titres/descriptions avec templates
 5. add_attribute → ajouter attributs avec sources
+   (google_product_category → map_category, prix → normalize_price, jamais à la main;
+   HTML et MAJUSCULES du titre/description → clean_text avant optimize_field)
 6. validate_proposal → vérifier no-invention
 7. commit_changes → finaliser
 
 Sois méthodique, cite toujours tes sources, respecte la hiérarchie des priorités.`, session.Goal)
 
-	messages := []openai.ChatCompletionMessage{
+	messages := []llm.Message{
 		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: systemPrompt,
+			Role:    llm.RoleSystem,
+			Content: a.withFeedback(ctx, session.Product.DatasetID, systemPrompt),
 		},
 	}
 
 	// Add product context
 	productJSON, _ := json.MarshalIndent(session.Product.RawData, "", "  ")
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
+	messages = append(messages, llm.Message{
+		Role:    llm.RoleUser,
 		Content: fmt.Sprintf("Voici le produit à enrichir:\n\n```json\n%s\n```", string(productJSON)),
 	})
 
 	// Add previous steps as context
 	for _, trace := range session.Traces {
 		if trace.Thought != "" {
-			messages = append(messages, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleAssistant,
+			messages = append(messages, llm.Message{
+				Role:    llm.RoleAssistant,
 				Content: trace.Thought,
 			})
 		}
 		if trace.ToolName != "" {
-			messages = append(messages, openai.ChatCompletionMessage{
-				Role:       openai.ChatMessageRoleAssistant,
-				Content:    "",
-				ToolCalls: []openai.ToolCall{{
-					ID:   fmt.Sprintf("call_%d", trace.StepNumber),
-					Type: openai.ToolTypeFunction,
-					Function: openai.FunctionCall{
-						Name:      trace.ToolName,
-						Arguments: string(trace.ToolInput),
-					},
+			messages = append(messages, llm.Message{
+				Role: llm.RoleAssistant,
+				ToolCalls: []llm.ToolCall{{
+					ID:        fmt.Sprintf("call_%d", trace.StepNumber),
+					Name:      trace.ToolName,
+					Arguments: string(trace.ToolInput),
 				}},
 			})
-			messages = append(messages, openai.ChatCompletionMessage{
-				Role:       openai.ChatMessageRoleTool,
+			messages = append(messages, llm.Message{
+				Role:       llm.RoleTool,
 				Content:    string(trace.ToolOutput),
 				ToolCallID: fmt.Sprintf("call_%d", trace.StepNumber),
+				ToolName:   trace.ToolName,
 			})
 		}
 	}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 )
 
 // ProductAuditor is a JUDGE-ONLY agent.
@@ -14,13 +14,13 @@ import (
 // It does NO rewriting, NO suggestions, NO creativity.
 // Only judgment.
 type ProductAuditor struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
 }
 
 func NewProductAuditor(cfg *config.Config) *ProductAuditor {
 	return &ProductAuditor{
-		client: openai.NewClient(cfg.OpenAI.APIKey),
+		client: llm.New(cfg),
 		config: cfg,
 	}
 }
@@ -54,6 +54,9 @@ type AuditOutput struct {
 	Scores     AuditScores `json:"scores"`
 }
 
+// auditSchema constrains the audit answer to AuditOutput
+var auditSchema = llm.SchemaFor("audit", AuditOutput{})
+
 type Violation struct {
 	Field    string `json:"field"`
 	Rule     string `json:"rule"`
@@ -126,21 +129,19 @@ SCORING GUIDELINES:
 
 Return ONLY the JSON, no explanations.`, string(input.ProductData), string(rulesJSON), string(gmcRulesJSON))
 
-	resp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: a.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: prompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	var output AuditOutput
+	resp, err := a.client.Structured(ctx, llm.Request{
+		Model: a.config.LLM.Model,
+		Stage: llm.StageAuditor,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
-	})
-	if err != nil {
+		Schema: auditSchema,
+	}, &output)
+	if resp == nil {
 		return nil, fmt.Errorf("auditor call failed: %w", err)
 	}
-
-	var output AuditOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &output); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("parse audit output: %w", err)
 	}
 
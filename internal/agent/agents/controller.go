@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 )
 
 // ControllerAgent exists ONLY to say "no".
@@ -14,14 +15,16 @@ import (
 // If something smells wrong → REJECT.
 // This agent is what makes enterprises TRUST the system.
 type ControllerAgent struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
+	rules  *tools.HardRuleValidator // deterministic pre-checks, see PreCheck
 }
 
 func NewControllerAgent(cfg *config.Config) *ControllerAgent {
 	return &ControllerAgent{
-		client: openai.NewClient(cfg.OpenAI.APIKey),
+		client: llm.New(cfg),
 		config: cfg,
+		rules:  tools.NewHardRuleValidator(),
 	}
 }
 
@@ -44,6 +47,9 @@ type ControllerOutput struct {
 	Verification VerificationResult `json:"verification"`
 }
 
+// controllerSchema constrains the verification answer to ControllerOutput
+var controllerSchema = llm.SchemaFor("verification", ControllerOutput{})
+
 type Rejection struct {
 	Reason   string `json:"reason"`
 	Severity string `json:"severity"` // critical, major
@@ -132,21 +138,19 @@ BE STRICT. When in doubt, REJECT.
 
 Return ONLY the JSON, no explanations.`, input.Field, input.Before, input.After, input.WriterConfidence, string(factsUsedJSON), string(allowedJSON), string(constraintsJSON))
 
-	resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: c.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: prompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	var output ControllerOutput
+	resp, err := c.client.Structured(ctx, llm.Request{
+		Model: c.config.LLM.Model,
+		Stage: llm.StageController,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
-	})
-	if err != nil {
+		Schema: controllerSchema,
+	}, &output)
+	if resp == nil {
 		return nil, fmt.Errorf("controller call failed: %w", err)
 	}
-
-	var output ControllerOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &output); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("parse controller output: %w", err)
 	}
 
@@ -0,0 +1,188 @@
+package agents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/pdftext"
+	"golang.org/x/net/html"
+)
+
+const (
+	maxDatasheets     = 2     // PDF links followed per page
+	maxDatasheetChars = 15000 // datasheet text sent to the extractor
+)
+
+// datasheetKeywords tell a spec sheet from the other PDFs of a page (terms of sale, catalogs)
+var datasheetKeywords = []string{
+	"datasheet", "data sheet", "data-sheet", "spec", "technical", "technique", "fiche",
+	"notice", "manual", "manuel", "datenblatt", "ficha",
+}
+
+// datasheetFacts reads the needed fields of a PDF datasheet with the LLM extractor. Each
+// fact cites the page it is on: in its evidence ("p. 3: ...") and its URL (#page=3).
+func (a *KnowledgeRetrievalAgent) datasheetFacts(ctx context.Context, body []byte, fieldsNeeded []string, sourceURL string) ([]SourcedFact, error) {
+	pages, err := pdftext.Extract(body)
+	if err != nil {
+		return nil, err
+	}
+	fieldsJSON, _ := json.Marshal(fieldsNeeded)
+
+	prompt := fmt.Sprintf(`You are a FACT EXTRACTOR. Extract ONLY verifiable facts from this product datasheet (PDF text, page by page; table cells are separated by tabs).
+
+CRITICAL CONSTRAINTS:
+- Extract ONLY facts that are EXPLICITLY stated in the document
+- NO inference, NO assumptions
+- Include the EXACT text snippet as evidence and the page it is on ([page N])
+- A datasheet may cover several models: only take values of the model matching the product
+- If a field is not found, do NOT include it
+
+FIELDS TO EXTRACT: %s
+
+DOCUMENT:
+%s
+
+OUTPUT FORMAT (JSON only):
+{
+  "facts": [
+    {
+      "field": "weight",
+      "value": "2.5 kg",
+      "evidence": "Net weight 2.5 kg",
+      "page": 3,
+      "confidence": 0.95
+    }
+  ]
+}
+
+Return ONLY the JSON with facts found. Empty array if nothing found.`, string(fieldsJSON), pdftext.Format(pages, maxDatasheetChars))
+
+	extracted, err := a.runExtractor(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	facts := make([]SourcedFact, 0, len(extracted))
+	for _, f := range extracted {
+		page := citedPage(pages, f.Evidence, f.Page)
+		if page == 0 {
+			continue // the cited page isn't in the document
+		}
+		facts = append(facts, SourcedFact{
+			Field:      f.Field,
+			Value:      f.Value,
+			Source:     "datasheet",
+			URL:        fmt.Sprintf("%s#page=%d", strings.Split(sourceURL, "#")[0], page),
+			Evidence:   fmt.Sprintf("p. %d: %s", page, f.Evidence),
+			Confidence: f.Confidence,
+		})
+	}
+	return facts, nil
+}
+
+// citedPage returns the page the evidence is on, the page the extractor cited when the
+// evidence isn't found as is, 0 when that page doesn't exist
+func citedPage(pages []pdftext.Page, evidence string, cited int) int {
+	if snippet := normalizeSnippet(evidence); snippet != "" {
+		for _, p := range pages {
+			if strings.Contains(normalizeSnippet(p.Text), snippet) {
+				return p.Number
+			}
+		}
+	}
+	if slices.ContainsFunc(pages, func(p pdftext.Page) bool { return p.Number == cited }) {
+		return cited
+	}
+	return 0
+}
+
+func normalizeSnippet(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// linkedDatasheetFacts reads the fields a page lacks from the PDF datasheets it links to
+func (a *KnowledgeRetrievalAgent) linkedDatasheetFacts(ctx context.Context, body []byte, fieldsNeeded []string, pageURL string) []SourcedFact {
+	var facts []SourcedFact
+	for _, link := range datasheetLinks(body, pageURL) {
+		if len(fieldsNeeded) == 0 {
+			break
+		}
+		pdf, err := a.fetchPage(ctx, link)
+		if err != nil || !pdftext.IsPDF(pdf) {
+			continue
+		}
+		found, err := a.datasheetFacts(ctx, pdf, fieldsNeeded, link)
+		if err != nil {
+			continue
+		}
+		facts = append(facts, found...)
+		fieldsNeeded = withoutFacts(fieldsNeeded, found)
+	}
+	return facts
+}
+
+// datasheetLinks returns the PDF links of a page that look like spec sheets (by their link
+// text or file name), at most maxDatasheets
+func datasheetLinks(body []byte, pageURL string) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	var links []string
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if len(links) == maxDatasheets {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, at := range n.Attr {
+				if at.Key != "href" {
+					continue
+				}
+				link, err := base.Parse(strings.TrimSpace(at.Val))
+				if err != nil || (link.Scheme != "http" && link.Scheme != "https") ||
+					!strings.HasSuffix(strings.ToLower(link.Path), ".pdf") {
+					continue
+				}
+				label := strings.ToLower(linkText(n) + " " + link.Path)
+				if slices.ContainsFunc(datasheetKeywords, func(k string) bool { return strings.Contains(label, k) }) &&
+					!slices.Contains(links, link.String()) {
+					links = append(links, link.String())
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+	return links
+}
+
+func linkText(n *html.Node) string {
+	var sb strings.Builder
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(n)
+	for _, at := range n.Attr {
+		if at.Key == "title" || at.Key == "aria-label" {
+			sb.WriteString(" " + at.Val)
+		}
+	}
+	return sb.String()
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 )
 
 // ImageEvidenceAgent extracts visual evidence from product images.
@@ -14,14 +16,16 @@ import (
 // Allowed: detect, confirm, deny, mark uncertainty
 // NO adjectives, NO marketing language - EVIDENCE ONLY
 type ImageEvidenceAgent struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
+	images *imageproxy.Proxy
 }
 
 func NewImageEvidenceAgent(cfg *config.Config) *ImageEvidenceAgent {
 	return &ImageEvidenceAgent{
-		client: openai.NewClient(cfg.OpenAI.APIKey),
+		client: llm.New(cfg),
 		config: cfg,
+		images: imageproxy.New(cfg),
 	}
 }
 
@@ -37,6 +41,9 @@ type ImageEvidenceOutput struct {
 	Observations []ImageObservation `json:"observations"`
 	Uncertain    []string           `json:"uncertain"` // attributes that couldn't be determined
 	ImageQuality ImageQualityCheck  `json:"image_quality"`
+	// Text read in the image, and the GMC image policy violations it makes (checked in Go)
+	Text       []tools.ImageText          `json:"text"`
+	TextPolicy []tools.ImageTextViolation `json:"text_policy,omitempty"`
 }
 
 type ImageObservation struct {
@@ -83,8 +90,16 @@ ALLOWED OBSERVATIONS:
 - Physical attributes (has_pockets: true/false, has_collar: true/false)
 - Product type indicators (appears to be a shirt, appears to be electronics)
 - Visible text/labels
+- Identifiers printed on the product or its packaging: "gtin" (the digits under a barcode, exactly as printed) and "mpn" (model or reference number)
 - Image quality issues
 
+VISIBLE TEXT (OCR):
+- Transcribe EVERY text visible in the image exactly as written, one entry per block of text
+- kind "overlay": added over the photo (banners, badges, prices, discounts, slogans, stickers)
+- kind "watermark": photographer, stock agency, shop name or URL marked over the image
+- kind "label": printed on the product itself or its packaging (brand, model, ingredients)
+- Do not translate or correct the text; return an empty list when there is none
+
 FORBIDDEN:
 - Quality judgments ("premium", "well-made")
 - Material inference (unless clearly labeled in image)
@@ -107,34 +122,39 @@ OUTPUT FORMAT (JSON only):
     "has_text_overlay": false,
     "background_type": "white",
     "confidence": 0.95
-  }
+  },
+  "text": [
+    { "text": "NIKE", "kind": "label", "confidence": 0.97 },
+    { "text": "-30%% SOLDES", "kind": "overlay", "confidence": 0.9 }
+  ]
 }
 
 Return ONLY the JSON, no explanations.`, attributesHint)
 
-	resp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: a.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role: openai.ChatMessageRoleUser,
-				MultiContent: []openai.ChatMessagePart{
-					{Type: openai.ChatMessagePartTypeText, Text: prompt},
-					{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: input.ImageURL}},
-				},
-			},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	// The image is downloaded through the proxy cache: merchant CDNs often block the
+	// provider's fetcher
+	image := llm.Image{URL: input.ImageURL}
+	if !llm.Simulated(ctx) {
+		image.URL, _, _ = a.images.ForModel(ctx, input.ImageURL, a.config.Agent.VisionMaxSize)
+	}
+
+	resp, err := a.client.ChatWithVision(ctx, llm.Request{
+		Model: a.config.LLM.Model,
+		Stage: llm.StageEvidence,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
-	})
+		JSON: true,
+	}, image)
 	if err != nil {
 		return nil, fmt.Errorf("image evidence call failed: %w", err)
 	}
 
 	var output ImageEvidenceOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &output); err != nil {
+	if err := json.Unmarshal([]byte(resp.Content), &output); err != nil {
 		return nil, fmt.Errorf("parse image evidence output: %w", err)
 	}
+	output.TextPolicy = tools.CheckImageText(output.Text)
 
 	return &output, nil
 }
@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 )
 
 // OptimizationPlanner decides WHAT should be optimized, not HOW.
@@ -18,13 +19,13 @@ import (
 // - what requires human validation
 // NO text generation - DECISION LOGIC only
 type OptimizationPlanner struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
 }
 
 func NewOptimizationPlanner(cfg *config.Config) *OptimizationPlanner {
 	return &OptimizationPlanner{
-		client: openai.NewClient(cfg.OpenAI.APIKey),
+		client: llm.New(cfg),
 		config: cfg,
 	}
 }
@@ -33,6 +34,8 @@ func NewOptimizationPlanner(cfg *config.Config) *OptimizationPlanner {
 type PlannerInput struct {
 	ProductData     json.RawMessage `json:"product_data"`
 	AuditResult     *AuditOutput    `json:"audit_result"`
+	Vertical        tools.VerticalProfile `json:"vertical"`
+	Feedback        string          `json:"feedback,omitempty"` // how reviewers decided past proposals of the dataset
 	AvailableEvidence struct {
 		ImageEvidence  *ImageEvidenceOutput `json:"image_evidence,omitempty"`
 		RetrievedFacts *RetrievalOutput     `json:"retrieved_facts,omitempty"`
@@ -47,6 +50,9 @@ type PlannerOutput struct {
 	RequireHuman  []HumanRequired      `json:"require_human"`
 }
 
+// plannerSchema constrains the optimization plan answer to PlannerOutput
+var plannerSchema = llm.SchemaFor("optimization_plan", PlannerOutput{})
+
 type OptimizationAction struct {
 	Field         string   `json:"field"`
 	Objective     string   `json:"objective"`      // e.g., "clarify product type", "add missing color"
@@ -99,11 +105,15 @@ INPUT - AUDIT RESULT:
 INPUT - AVAILABLE EVIDENCE:
 %s
 
+%s
+%s
 DECISION RULES:
 1. If audit shows violation → plan fix with appropriate risk level
 2. If audit shows weakness → plan improvement ONLY if evidence supports it
 3. If no evidence available for a field → DO NOT OPTIMIZE or REQUIRE HUMAN
 4. If change could affect safety/compliance → REQUIRE HUMAN
+5. Plan the key attributes of the PRODUCT VERTICAL, never attributes that don't apply to it
+6. If REVIEWER FEEDBACK shows a field is mostly rejected → DO NOT OPTIMIZE it without explicit evidence
 
 OUTPUT FORMAT (JSON only):
 {
@@ -126,23 +136,21 @@ OUTPUT FORMAT (JSON only):
   ]
 }
 
-Return ONLY the JSON, no explanations.`, string(input.ProductData), string(auditJSON), evidenceJSON)
+Return ONLY the JSON, no explanations.`, string(input.ProductData), string(auditJSON), evidenceJSON, input.Vertical.Prompt(), input.Feedback)
 
-	resp, err := p.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: p.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: prompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	var output PlannerOutput
+	resp, err := p.client.Structured(ctx, llm.Request{
+		Model: p.config.LLM.Model,
+		Stage: llm.StagePlanner,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
-	})
-	if err != nil {
+		Schema: plannerSchema,
+	}, &output)
+	if resp == nil {
 		return nil, fmt.Errorf("planner call failed: %w", err)
 	}
-
-	var output PlannerOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &output); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("parse planner output: %w", err)
 	}
 
@@ -0,0 +1,97 @@
+package agents
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// lengthConstraint reads the planner's length constraints: "max 150 chars", "min 500 characters"
+	lengthConstraint = regexp.MustCompile(`(?i)\b(max|min)\w*\s*(\d+)\s*(?:chars?|characters?|caract[eè]res?)\b`)
+	// priceFormat is the GMC price format: amount and ISO currency ("15.00 EUR")
+	priceFormat = regexp.MustCompile(`^\d+([.,]\d{1,2})?\s?[A-Z]{3}$`)
+)
+
+// priceFields and urlFields have a fixed format a rewrite must keep
+var (
+	priceFields = map[string]bool{"price": true, "sale_price": true}
+	urlFields   = map[string]bool{"link": true, "image_link": true, "mobile_link": true, "additional_image_link": true}
+)
+
+// PreCheck runs the deterministic checks of the controller on a change: GMC hard rules of
+// the field (length, forbidden words), the planner's length constraints, price and URL
+// formats, and that every fact the writer claims is found in the allowed fact it cites.
+// Changes it rejects are obvious violations that don't need the LLM controller.
+func (c *ControllerAgent) PreCheck(input ControllerInput) []Rejection {
+	after := strings.TrimSpace(input.After)
+	if after == "" {
+		return []Rejection{{Reason: "Empty value", Severity: "critical", Evidence: "(empty)"}}
+	}
+	if after == strings.TrimSpace(input.Before) {
+		return []Rejection{{Reason: "No change", Severity: "major", Evidence: after}}
+	}
+
+	var rejections []Rejection
+	for _, v := range c.rules.ValidateField(input.Field, after) {
+		rejections = append(rejections, Rejection{Reason: v.Message, Severity: "critical", Evidence: v.Actual})
+	}
+
+	length := utf8.RuneCountInString(after)
+	for _, constraint := range input.Constraints {
+		m := lengthConstraint.FindStringSubmatch(constraint)
+		if m == nil {
+			continue
+		}
+		limit, _ := strconv.Atoi(m[2])
+		isMax := strings.EqualFold(m[1], "max")
+		if (isMax && length > limit) || (!isMax && length < limit) {
+			rejections = append(rejections, Rejection{
+				Reason:   "Constraint violated: " + constraint,
+				Severity: "major",
+				Evidence: fmt.Sprintf("%d characters", length),
+			})
+		}
+	}
+
+	field := strings.ToLower(input.Field)
+	if priceFields[field] && !priceFormat.MatchString(after) {
+		rejections = append(rejections, Rejection{Reason: "Price must be an amount and an ISO currency (15.00 EUR)", Severity: "critical", Evidence: after})
+	}
+	if urlFields[field] {
+		if u, err := url.Parse(after); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			rejections = append(rejections, Rejection{Reason: "Invalid URL", Severity: "critical", Evidence: after})
+		}
+	}
+
+	for _, f := range input.FactsUsed {
+		if fact := strings.TrimSpace(f.Fact); fact != "" && !factAllowed(fact, f.Source, input) {
+			rejections = append(rejections, Rejection{
+				Reason:   "Fact not in allowed facts",
+				Severity: "critical",
+				Evidence: fmt.Sprintf("%s (source %q)", fact, f.Source),
+			})
+		}
+	}
+	return rejections
+}
+
+// factAllowed reports whether a claimed fact is found in the allowed fact it cites, or in
+// the current value. Either may hold the other ("coton" in "100% coton" and conversely).
+func factAllowed(fact, source string, input ControllerInput) bool {
+	candidates := []string{input.Before}
+	if value, ok := input.AllowedFacts[source]; ok {
+		candidates = append(candidates, value)
+	}
+	fact = strings.ToLower(fact)
+	for _, c := range candidates {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c != "" && (strings.Contains(c, fact) || strings.Contains(fact, c)) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,31 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
 	"strings"
-	"time"
 
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/pdftext"
+	"github.com/benjamincozon/feedenrich/internal/structdata"
+	"github.com/benjamincozon/feedenrich/internal/websearch"
 )
 
 // KnowledgeRetrievalAgent fetches facts from external sources.
 // This is how we ELIMINATE hallucination.
 // Every fact must have a verifiable source.
 type KnowledgeRetrievalAgent struct {
-	client     *openai.Client
-	httpClient *http.Client
-	config     *config.Config
+	client llm.Client
+	config *config.Config
 }
 
 func NewKnowledgeRetrievalAgent(cfg *config.Config) *KnowledgeRetrievalAgent {
 	return &KnowledgeRetrievalAgent{
-		client: openai.NewClient(cfg.OpenAI.APIKey),
-		httpClient: &http.Client{
-			Timeout: 15 * time.Second,
-		},
+		client: llm.New(cfg),
 		config: cfg,
 	}
 }
@@ -41,6 +38,9 @@ type RetrievalInput struct {
 	MPN          string   `json:"mpn,omitempty"`
 	ProductURL   string   `json:"product_url,omitempty"`
 	FieldsNeeded []string `json:"fields_needed"` // e.g., ["material", "dimensions", "weight"]
+	// Sites trusted for the dataset: searched first, and their facts marked trusted with at
+	// least tools.TrustedMinConfidence
+	Trusted *tools.TrustedDomains `json:"-"`
 }
 
 // RetrievalOutput contains sourced facts
@@ -53,14 +53,15 @@ type RetrievalOutput struct {
 type SourcedFact struct {
 	Field      string  `json:"field"`
 	Value      string  `json:"value"`
-	Source     string  `json:"source"`      // "manufacturer_page", "product_page", "feed"
-	URL        string  `json:"url"`         // verifiable URL
-	Evidence   string  `json:"evidence"`    // exact text snippet from source
+	Source     string  `json:"source"`      // "gtin_registry", "manufacturer_page", "product_page", "structured_data", "datasheet", "feed"
+	URL        string  `json:"url"`         // verifiable URL; datasheet URLs end with #page=N
+	Evidence   string  `json:"evidence"`    // exact text snippet from source ("p. 3: ..." in datasheets), or where structured data was read
 	Confidence float64 `json:"confidence"`
+	Trusted    bool    `json:"trusted,omitempty"` // read on a trusted domain
 }
 
 type Source struct {
-	Type string `json:"type"` // "product_page", "manufacturer", "web_search"
+	Type string `json:"type"` // "gtin_registry", "product_page", "manufacturer", "web_search"
 	URL  string `json:"url"`
 	Used bool   `json:"used"`
 }
@@ -73,12 +74,22 @@ func (a *KnowledgeRetrievalAgent) RetrieveFacts(ctx context.Context, input Retri
 		FieldsNotFound: []string{},
 	}
 
+	// 0. A GTIN registry knows the product from its brand owner or a product database:
+	// trusted over pages and searches
+	fieldsNeeded := input.FieldsNeeded
+	if facts, source := a.registryFacts(ctx, input); source != nil {
+		output.Facts = append(output.Facts, facts...)
+		output.SourcesUsed = append(output.SourcesUsed, *source)
+		fieldsNeeded = withoutFacts(fieldsNeeded, facts)
+	}
+
 	// 1. Try product URL first if available
-	if input.ProductURL != "" {
+	if input.ProductURL != "" && len(fieldsNeeded) > 0 {
 		pageContent, err := a.fetchPage(ctx, input.ProductURL)
 		if err == nil {
-			facts, err := a.extractFactsFromPage(ctx, pageContent, input.FieldsNeeded, input.ProductURL)
+			facts, err := a.pageFacts(ctx, pageContent, fieldsNeeded, input.ProductURL)
 			if err == nil {
+				facts = input.markTrusted(facts)
 				output.Facts = append(output.Facts, facts...)
 				output.SourcesUsed = append(output.SourcesUsed, Source{
 					Type: "product_page",
@@ -106,6 +117,9 @@ func (a *KnowledgeRetrievalAgent) RetrieveFacts(ctx context.Context, input Retri
 	if len(missingFields) > 0 && (input.GTIN != "" || input.Brand != "") {
 		searchQuery := a.buildSearchQuery(input, missingFields)
 		searchResults, err := a.webSearch(ctx, searchQuery)
+		if err == nil {
+			searchResults = a.preferTrusted(ctx, input, searchQuery, searchResults)
+		}
 		if err == nil && len(searchResults) > 0 {
 			// Try to fetch and extract from top results
 			for _, result := range searchResults[:min(3, len(searchResults))] {
@@ -114,10 +128,11 @@ func (a *KnowledgeRetrievalAgent) RetrieveFacts(ctx context.Context, input Retri
 					continue
 				}
 
-				facts, err := a.extractFactsFromPage(ctx, pageContent, missingFields, result.URL)
+				facts, err := a.pageFacts(ctx, pageContent, missingFields, result.URL)
 				if err != nil {
 					continue
 				}
+				facts = input.markTrusted(facts)
 
 				output.Facts = append(output.Facts, facts...)
 				output.SourcesUsed = append(output.SourcesUsed, Source{
@@ -144,29 +159,151 @@ func (a *KnowledgeRetrievalAgent) RetrieveFacts(ctx context.Context, input Retri
 	return output, nil
 }
 
-func (a *KnowledgeRetrievalAgent) fetchPage(ctx context.Context, pageURL string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
-	if err != nil {
-		return "", err
+// registryFacts returns the needed fields the GTIN registries (GTIN_REGISTRIES) know of a
+// valid GTIN, with the registry as source; nil source when no registry was asked or knows it
+func (a *KnowledgeRetrievalAgent) registryFacts(ctx context.Context, input RetrievalInput) ([]SourcedFact, *Source) {
+	gtin := tools.NormalizeGTIN(input.GTIN)
+	if gtin == "" || tools.CheckGTIN(gtin) != nil {
+		return nil, nil
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; FeedEnrich/1.0)")
+	product, err := websearch.For(a.config).LookupGTIN(ctx, gtin)
+	if err != nil || product == nil {
+		return nil, nil
+	}
+	known := product.Fields()
+	var facts []SourcedFact
+	for _, field := range input.FieldsNeeded {
+		if value, ok := known[field]; ok {
+			facts = append(facts, SourcedFact{
+				Field:      field,
+				Value:      value,
+				Source:     "gtin_registry",
+				URL:        product.URL,
+				Evidence:   fmt.Sprintf("%s, GTIN %s: %s", product.Registry, gtin, value),
+				Confidence: product.Confidence,
+			})
+		}
+	}
+	return facts, &Source{Type: "gtin_registry", URL: product.URL, Used: len(facts) > 0}
+}
 
-	resp, err := a.httpClient.Do(req)
-	if err != nil {
-		return "", err
+// preferTrusted puts the results on trusted domains first. When there is none, the first
+// domain trusted for the brand is searched on its own and its results come first.
+func (a *KnowledgeRetrievalAgent) preferTrusted(ctx context.Context, input RetrievalInput, query string, results []searchResult) []searchResult {
+	domains := input.Trusted.Domains(input.Brand)
+	if len(domains) == 0 {
+		return results
+	}
+	var trusted, others []searchResult
+	for _, r := range results {
+		if input.Trusted.Trusts(r.URL, input.Brand) {
+			trusted = append(trusted, r)
+		} else {
+			others = append(others, r)
+		}
+	}
+	if len(trusted) == 0 {
+		site, err := a.webSearch(ctx, "site:"+domains[0]+" "+query)
+		if err == nil {
+			for _, r := range site {
+				if input.Trusted.Trusts(r.URL, input.Brand) {
+					trusted = append(trusted, r)
+				}
+			}
+		}
+	}
+	return append(trusted, others...)
+}
+
+// markTrusted marks the facts read on a trusted domain and raises their confidence to
+// tools.TrustedMinConfidence
+func (in RetrievalInput) markTrusted(facts []SourcedFact) []SourcedFact {
+	for i, f := range facts {
+		if in.Trusted.Trusts(f.URL, in.Brand) {
+			facts[i].Trusted = true
+			facts[i].Confidence = max(f.Confidence, tools.TrustedMinConfidence)
+		}
 	}
-	defer resp.Body.Close()
+	return facts
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("page returned status %d", resp.StatusCode)
+// withoutFacts returns the fields no fact was found for
+func withoutFacts(fields []string, facts []SourcedFact) []string {
+	found := make(map[string]bool, len(facts))
+	for _, f := range facts {
+		found[f.Field] = true
 	}
+	var missing []string
+	for _, field := range fields {
+		if !found[field] {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 100*1024)) // 100KB limit
+func (a *KnowledgeRetrievalAgent) fetchPage(ctx context.Context, pageURL string) ([]byte, error) {
+	page, err := websearch.For(a.config).Fetch(ctx, pageURL)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return string(body), nil
+	if page.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned status %d", page.StatusCode)
+	}
+	return page.Body, nil
+}
+
+// pageFacts reads the needed fields of a page: first from its structured data (JSON-LD,
+// microdata, OpenGraph), exact and with the tag it was read from, then the remaining ones
+// from its text with the LLM extractor, and last from the PDF datasheets it links to. A PDF
+// document is read as a datasheet.
+func (a *KnowledgeRetrievalAgent) pageFacts(ctx context.Context, body []byte, fieldsNeeded []string, sourceURL string) ([]SourcedFact, error) {
+	if pdftext.IsPDF(body) {
+		return a.datasheetFacts(ctx, body, fieldsNeeded, sourceURL)
+	}
+	facts := structuredFacts(body, fieldsNeeded, sourceURL)
+	remaining := withoutFacts(fieldsNeeded, facts)
+	if len(remaining) == 0 {
+		return facts, nil
+	}
+
+	content := body
+	if len(content) > 100*1024 { // 100KB limit
+		content = content[:100*1024]
+	}
+	extracted, err := a.extractFactsFromPage(ctx, string(content), remaining, sourceURL)
+	facts = append(facts, extracted...)
+	if remaining = withoutFacts(remaining, extracted); len(remaining) > 0 {
+		facts = append(facts, a.linkedDatasheetFacts(ctx, body, remaining, sourceURL)...)
+	}
+	if err != nil && len(facts) == 0 {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// structuredFacts returns the needed fields a page publishes as structured data
+func structuredFacts(body []byte, fieldsNeeded []string, sourceURL string) []SourcedFact {
+	needed := make(map[string]bool, len(fieldsNeeded))
+	for _, field := range fieldsNeeded {
+		needed[field] = true
+	}
+	var facts []SourcedFact
+	for _, f := range structdata.Extract(body) {
+		if !needed[f.Field] {
+			continue
+		}
+		facts = append(facts, SourcedFact{
+			Field:      f.Field,
+			Value:      f.Value,
+			Source:     "structured_data",
+			URL:        sourceURL,
+			Evidence:   fmt.Sprintf("%s %s: %s", f.Source, f.Path, f.Value),
+			Confidence: structdata.Confidence(f.Source),
+		})
+	}
+	return facts
 }
 
 func (a *KnowledgeRetrievalAgent) extractFactsFromPage(ctx context.Context, content string, fieldsNeeded []string, sourceURL string) ([]SourcedFact, error) {
@@ -204,34 +341,14 @@ OUTPUT FORMAT (JSON only):
 
 Return ONLY the JSON with facts found. Empty array if nothing found.`, string(fieldsJSON), content)
 
-	resp, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: a.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: prompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-		},
-	})
+	extracted, err := a.runExtractor(ctx, prompt)
 	if err != nil {
 		return nil, err
 	}
 
-	var result struct {
-		Facts []struct {
-			Field      string  `json:"field"`
-			Value      string  `json:"value"`
-			Evidence   string  `json:"evidence"`
-			Confidence float64 `json:"confidence"`
-		} `json:"facts"`
-	}
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
-		return nil, err
-	}
-
 	// Convert to SourcedFact with URL
-	facts := make([]SourcedFact, 0, len(result.Facts))
-	for _, f := range result.Facts {
+	facts := make([]SourcedFact, 0, len(extracted))
+	for _, f := range extracted {
 		facts = append(facts, SourcedFact{
 			Field:      f.Field,
 			Value:      f.Value,
@@ -245,58 +362,53 @@ Return ONLY the JSON with facts found. Empty array if nothing found.`, string(fi
 	return facts, nil
 }
 
-type searchResult struct {
-	URL     string
-	Title   string
-	Snippet string
+// extractedFact is a fact as the LLM extractor returns it; Page is set for PDF documents
+type extractedFact struct {
+	Field      string  `json:"field"`
+	Value      string  `json:"value"`
+	Evidence   string  `json:"evidence"`
+	Confidence float64 `json:"confidence"`
+	Page       int     `json:"page,omitempty"`
 }
 
-func (a *KnowledgeRetrievalAgent) webSearch(ctx context.Context, query string) ([]searchResult, error) {
-	// Check if web search is enabled and API key is set
-	if a.config.WebSearch.APIKey == "" {
-		return []searchResult{}, nil
-	}
-
-	// Use Brave Search API
-	searchURL := fmt.Sprintf("https://api.search.brave.com/res/v1/web/search?q=%s&count=5&extra_snippets=true", 
-		url.QueryEscape(query))
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+// runExtractor sends an extraction prompt and parses the facts it answers
+func (a *KnowledgeRetrievalAgent) runExtractor(ctx context.Context, prompt string) ([]extractedFact, error) {
+	resp, err := a.client.Chat(ctx, llm.Request{
+		Model: a.config.LLM.Model,
+		Stage: llm.StageRetrieval,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
+		},
+		JSON: true,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("X-Subscription-Token", a.config.WebSearch.APIKey)
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := a.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("brave search request: %w", err)
+	var result struct {
+		Facts []extractedFact `json:"facts"`
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("brave search error %d: %s", resp.StatusCode, string(body))
+	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
+		return nil, err
 	}
+	return result.Facts, nil
+}
 
-	var braveResp struct {
-		Web struct {
-			Results []struct {
-				Title       string `json:"title"`
-				URL         string `json:"url"`
-				Description string `json:"description"`
-				ExtraSnippets []string `json:"extra_snippets,omitempty"`
-			} `json:"results"`
-		} `json:"web"`
-	}
+type searchResult struct {
+	URL     string
+	Title   string
+	Snippet string
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&braveResp); err != nil {
-		return nil, fmt.Errorf("parse brave response: %w", err)
+func (a *KnowledgeRetrievalAgent) webSearch(ctx context.Context, query string) ([]searchResult, error) {
+	// Configured search provider, cached per query; no results without an API key
+	found, err := websearch.For(a.config).Search(ctx, query, 5)
+	if err != nil {
+		return nil, err
 	}
 
-	var results []searchResult
-	for _, r := range braveResp.Web.Results {
+	results := []searchResult{}
+	for _, r := range found {
 		snippet := r.Description
 		// Include extra snippets for more context
 		if len(r.ExtraSnippets) > 0 {
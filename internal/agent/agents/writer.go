@@ -6,7 +6,7 @@ import (
 	"fmt"
 
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 )
 
 // CopyExecutionAgent is the WRITER agent.
@@ -14,13 +14,13 @@ import (
 // It receives: allowed facts, forbidden facts, hard rules, objective
 // It is NOT allowed to invent.
 type CopyExecutionAgent struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
 }
 
 func NewCopyExecutionAgent(cfg *config.Config) *CopyExecutionAgent {
 	return &CopyExecutionAgent{
-		client: openai.NewClient(cfg.OpenAI.APIKey),
+		client: llm.New(cfg),
 		config: cfg,
 	}
 }
@@ -44,6 +44,9 @@ type WriterOutput struct {
 	Confidence    float64     `json:"confidence"`
 }
 
+// writerSchema constrains the rewrite answer to WriterOutput
+var writerSchema = llm.SchemaFor("rewrite", WriterOutput{})
+
 type FactUsage struct {
 	Fact   string `json:"fact"`
 	Source string `json:"source"` // which allowed_fact key was used
@@ -97,21 +100,19 @@ CONFIDENCE GUIDELINES:
 
 Return ONLY the JSON, no explanations.`, input.Field, input.CurrentValue, input.Objective, string(allowedJSON), string(forbiddenJSON), string(constraintsJSON))
 
-	resp, err := w.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: w.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: prompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	var output WriterOutput
+	resp, err := w.client.Structured(ctx, llm.Request{
+		Model: w.config.LLM.Model,
+		Stage: llm.StageWriter,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
-	})
-	if err != nil {
+		Schema: writerSchema,
+	}, &output)
+	if resp == nil {
 		return nil, fmt.Errorf("writer call failed: %w", err)
 	}
-
-	var output WriterOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &output); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("parse writer output: %w", err)
 	}
 
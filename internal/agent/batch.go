@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// batchDiscount is the share of the list price the provider charges for batch calls
+const batchDiscount = 0.5
+
+// BatchRequest prepares the fast mode run of a product for a batch: image analysis and
+// web search run now, the optimization call is returned to be submitted
+func (a *Agent) BatchRequest(ctx context.Context, product *models.Product) llm.Request {
+	return a.fastModeRequest(ctx, product)
+}
+
+// BatchSession finishes a fast mode run from the batch answer to its BatchRequest,
+// recording the usage at the batch price and caching the answer like a synchronous run
+func (a *Agent) BatchSession(ctx context.Context, product *models.Product, resp *llm.Response) (*Session, error) {
+	session := a.newSession(ctx, product, "Batch: "+string(GroupAll))
+	session.Model = resp.Model
+	session.Group = GroupAll
+	ctx = withSession(ctx, session)
+
+	cost := a.costUSD(ctx, resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens) * batchDiscount
+	a.recordUsageCost(ctx, resp.Model, resp.Usage, cost)
+
+	proposals, err := a.fastModeProposals(ctx, product, resp)
+	if err != nil {
+		session.Status = "failed"
+		return session, err
+	}
+	a.storeResponse(ctx, a.responseKey(ctx, product, GroupAll), GroupAll, resp, cost)
+	for i := range proposals {
+		proposals[i].Module = string(GroupAll)
+	}
+	a.ScoreProposals(ctx, product, proposals)
+	session.Proposals = proposals
+	session.Status = "completed"
+	session.Traces = append(session.Traces, models.AgentTrace{
+		ID:         uuid.New(),
+		SessionID:  session.ID,
+		StepNumber: 1,
+		Thought:    fmt.Sprintf("Group %s (batch): analyzed product and generated %d proposals", GroupAll, len(proposals)),
+		ToolName:   string(GroupAll),
+		TokensUsed: resp.Usage.TotalTokens,
+		Model:      resp.Model,
+		CreatedAt:  time.Now(),
+	})
+	return session, nil
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// BrandSource reads what the brand dictionary of a dataset is built from
+type BrandSource interface {
+	GetDatasetBrands(ctx context.Context, datasetID uuid.UUID) (map[string]int, error)
+	ListBrandAliases(ctx context.Context, datasetID uuid.UUID) ([]models.BrandAlias, error)
+}
+
+// brandTTL is how long the brand dictionary of a dataset is reused before it is built again
+const brandTTL = 5 * time.Minute
+
+type brandEntry struct {
+	dict    *tools.BrandDictionary
+	expires time.Time
+}
+
+// brandCache keeps the brand dictionaries of recent datasets
+type brandCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]brandEntry
+}
+
+// SetBrandSource makes the groups owning brand propose its canonical form from the
+// dataset's brand dictionary
+func (a *Agent) SetBrandSource(source BrandSource) {
+	a.brands = source
+	a.brandCache = &brandCache{entries: map[uuid.UUID]brandEntry{}}
+}
+
+// ReloadBrands makes the next product of a dataset build its brand dictionary again
+// (after its aliases were edited)
+func (a *Agent) ReloadBrands(datasetID uuid.UUID) {
+	if a.brandCache == nil {
+		return
+	}
+	a.brandCache.mu.Lock()
+	defer a.brandCache.mu.Unlock()
+	delete(a.brandCache.entries, datasetID)
+}
+
+// BrandDictionary returns the brand dictionary of a dataset, nil when there is no brand
+// source or it can't be read
+func (a *Agent) BrandDictionary(ctx context.Context, datasetID uuid.UUID) *tools.BrandDictionary {
+	if a.brands == nil {
+		return nil
+	}
+	c := a.brandCache
+	c.mu.Lock()
+	entry, ok := c.entries[datasetID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.dict
+	}
+
+	counts, err := a.brands.GetDatasetBrands(ctx, datasetID)
+	if err != nil {
+		fmt.Printf("Failed to load the brands of dataset %s: %v\n", datasetID, err)
+		return entry.dict
+	}
+	list, err := a.brands.ListBrandAliases(ctx, datasetID)
+	if err != nil {
+		fmt.Printf("Failed to load the brand aliases of dataset %s: %v\n", datasetID, err)
+		return entry.dict
+	}
+	aliases := make(map[string]string, len(list))
+	for _, alias := range list {
+		aliases[alias.Alias] = alias.Brand
+	}
+	dict := tools.NewBrandDictionary(counts, aliases)
+	c.mu.Lock()
+	c.entries[datasetID] = brandEntry{dict: dict, expires: time.Now().Add(brandTTL)}
+	c.mu.Unlock()
+	return dict
+}
+
+// canonicalizesBrands reports whether a group's brand proposals come from the brand
+// dictionary
+func canonicalizesBrands(group OptimizationGroup) bool {
+	return group == GroupAll || group == GroupRequiredAttributes
+}
+
+// brandProposal proposes the canonical form of the feed's brand ("NIKE" → "Nike",
+// "Adiddas" → "Adidas"), nil when it is already canonical or unknown to the dictionary
+func (a *Agent) brandProposal(ctx context.Context, product *models.Product) *models.Proposal {
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+	current := getFieldValueFromMap(fields, "brand")
+	if current == "" {
+		return nil
+	}
+	dict := a.BrandDictionary(ctx, product.DatasetID)
+	if dict == nil {
+		return nil
+	}
+	match, ok := dict.Canonicalize(current)
+	if !ok || match.Brand == current {
+		return nil
+	}
+
+	reason := map[string]string{
+		"alias": "Brand alias set for this catalog",
+		"feed":  fmt.Sprintf("Spelling of the brand on %d products of the catalog", match.Count),
+		"fuzzy": fmt.Sprintf("Misspelling of %s, the brand of %d products of the catalog", match.Brand, match.Count),
+	}[match.Method]
+	if match.Method == "fuzzy" && match.Count == 0 {
+		reason = "Misspelling of " + match.Brand + ", a brand alias set for this catalog"
+	}
+	confidence, risk := 1.0, "low"
+	if match.Method == "fuzzy" {
+		confidence, risk = 0.9, "medium"
+	}
+	source := models.Source{Type: "feed", Reference: "brand", Evidence: current, Confidence: confidence, Verified: match.Method != "fuzzy"}
+	p := a.codeProposal(product, "brand", current, match.Brand, reason, source, risk)
+	return &p
+}
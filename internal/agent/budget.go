@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/websearch"
+	"github.com/google/uuid"
+)
+
+// ErrBudgetExhausted is returned when the daily or monthly budget of a dataset, of its
+// organization or of the deployment (BUDGET_*), is spent
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
+// budgetScope is the dataset a run's usage is attributed to (see attributionFrom) and the
+// caps its calls are checked against
+type budgetScope struct {
+	datasetID uuid.UUID
+	budget    *models.Budget
+	org       *models.Organization
+}
+
+type budgetKey struct{}
+
+func budgetFrom(ctx context.Context) *budgetScope {
+	s, _ := ctx.Value(budgetKey{}).(*budgetScope)
+	return s
+}
+
+// WithBudget returns a context whose usage is attributed to the dataset and whose LLM
+// calls are each checked first against the budgets of the dataset, of its organization
+// and BUDGET_*. It fails fast with ErrBudgetExhausted when one of them is already spent.
+// Calls in flight when the budget runs out still complete, so spend can overshoot by
+// their cost. OpenAI calls and Brave searches use the organization's keys when it has some.
+func (a *Agent) WithBudget(ctx context.Context, datasetID uuid.UUID) (context.Context, error) {
+	if s := budgetFrom(ctx); s != nil && s.datasetID == datasetID {
+		return ctx, nil
+	}
+	// Simulated runs (cost estimates) spend nothing
+	if a.tokenTracker == nil || llm.Simulated(ctx) {
+		return ctx, nil
+	}
+	budget, err := a.tokenTracker.GetDatasetBudget(ctx, datasetID)
+	if err != nil {
+		return ctx, fmt.Errorf("load budget: %w", err)
+	}
+	org, err := a.tokenTracker.GetDatasetOrganization(ctx, datasetID)
+	if err != nil {
+		return ctx, fmt.Errorf("load organization: %w", err)
+	}
+	scope := &budgetScope{datasetID: datasetID, budget: budget, org: org}
+	ctx = withProviderKeys(context.WithValue(ctx, budgetKey{}, scope), org)
+	if !a.capped(scope) {
+		return ctx, nil
+	}
+	if err := a.checkBudget(ctx, scope); err != nil {
+		return ctx, err
+	}
+	return llm.WithGuard(ctx, func(ctx context.Context) error { return a.checkBudget(ctx, scope) }), nil
+}
+
+// WithProviderKeys returns a context whose OpenAI calls and Brave searches use the keys
+// of the dataset's organization, when it has some, without any budget check: for work
+// already paid for, like fetching the results of a batch
+func (a *Agent) WithProviderKeys(ctx context.Context, datasetID uuid.UUID) (context.Context, error) {
+	if a.tokenTracker == nil {
+		return ctx, nil
+	}
+	org, err := a.tokenTracker.GetDatasetOrganization(ctx, datasetID)
+	if err != nil {
+		return ctx, fmt.Errorf("load organization: %w", err)
+	}
+	return withProviderKeys(ctx, org), nil
+}
+
+// withProviderKeys makes the calls of ctx use the organization's own OpenAI and Brave keys,
+// and redacts them from call logs like the configured ones
+func withProviderKeys(ctx context.Context, org *models.Organization) context.Context {
+	if org.OpenAIAPIKey != "" {
+		ctx = llm.WithAPIKey(ctx, org.OpenAIAPIKey)
+	}
+	if org.BraveAPIKey != "" {
+		ctx = websearch.WithAPIKey(ctx, org.BraveAPIKey)
+	}
+	if org.OpenAIAPIKey != "" || org.BraveAPIKey != "" {
+		ctx = llm.WithSecrets(ctx, org.OpenAIAPIKey, org.BraveAPIKey)
+	}
+	return ctx
+}
+
+// CheckBudget checks the budget set up by WithBudget again, e.g. before submitting work
+// prepared with it
+func (a *Agent) CheckBudget(ctx context.Context) error {
+	s := budgetFrom(ctx)
+	if s == nil || !a.capped(s) {
+		return nil
+	}
+	return a.checkBudget(ctx, s)
+}
+
+// capped reports whether any cap applies to the runs of a scope
+func (a *Agent) capped(s *budgetScope) bool {
+	return a.config.Budget.DailyUSD > 0 || a.config.Budget.MonthlyUSD > 0 || s.budget.DailyUSD != nil || s.budget.MonthlyUSD != nil ||
+		s.org.Budget.DailyUSD != nil || s.org.Budget.MonthlyUSD != nil
+}
+
+func (a *Agent) checkBudget(ctx context.Context, s *budgetScope) error {
+	total, org, dataset, err := a.tokenTracker.GetBudgetSpend(ctx, s.datasetID)
+	if err != nil {
+		return fmt.Errorf("check budget: %w", err)
+	}
+	caps := []struct {
+		scope, period string
+		limit, spent  float64
+	}{
+		{"dataset", "daily", deref(s.budget.DailyUSD), dataset.TodayUSD},
+		{"dataset", "monthly", deref(s.budget.MonthlyUSD), dataset.MonthUSD},
+		{"organization", "daily", deref(s.org.Budget.DailyUSD), org.TodayUSD},
+		{"organization", "monthly", deref(s.org.Budget.MonthlyUSD), org.MonthUSD},
+		{"deployment", "daily", a.config.Budget.DailyUSD, total.TodayUSD},
+		{"deployment", "monthly", a.config.Budget.MonthlyUSD, total.MonthUSD},
+	}
+	for _, c := range caps {
+		if c.limit > 0 && c.spent >= c.limit {
+			return fmt.Errorf("%w: %s spent $%.2f of its $%.2f %s budget", ErrBudgetExhausted, c.scope, c.spent, c.limit, c.period)
+		}
+	}
+	return nil
+}
+
+func deref(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// promptVersion identifies the optimization and vision prompts in cache keys. Bump it
+// when they change so answers to the old prompts are no longer reused.
+const promptVersion = 8
+
+// ResponseCache stores optimization answers by the hash of their inputs (the
+// llm_response_cache table)
+type ResponseCache interface {
+	GetCachedResponse(ctx context.Context, key string) (*models.CachedResponse, error)
+	StoreCachedResponse(ctx context.Context, r models.CachedResponse) error
+}
+
+// SetResponseCache makes runs on unchanged products reuse the optimization answer of an
+// earlier run for AGENT_RESPONSE_CACHE_TTL instead of calling the model again
+func (a *Agent) SetResponseCache(cache ResponseCache) {
+	a.responses = cache
+}
+
+// responseKey hashes what the optimization answer of a group depends on: the prompts,
+// the model, variant instructions and review feedback, and the product data
+func (a *Agent) responseKey(ctx context.Context, product *models.Product, group OptimizationGroup) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00%s\x00%s\x00%s\x00%s\x00", promptVersion, group, a.modelFor(group), a.instructions, a.feedbackFor(ctx, product.DatasetID))
+	h.Write(product.RawData)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (a *Agent) responseCacheEnabled() bool {
+	return a.responses != nil && a.config.Agent.ResponseCacheTTL > 0
+}
+
+// cachedResponse returns the stored answer for key, nil when there is none
+func (a *Agent) cachedResponse(ctx context.Context, key string) *llm.Response {
+	if !a.responseCacheEnabled() {
+		return nil
+	}
+	cached, err := a.responses.GetCachedResponse(ctx, key)
+	if err != nil || cached == nil {
+		return nil
+	}
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog("♻️ Reusing the cached answer for unchanged product data")
+	}
+	return &llm.Response{Model: cached.Model, Content: cached.Content, FinishReason: llm.FinishStop}
+}
+
+// storeResponse caches an answer that parsed, with what it cost. Simulated answers are
+// placeholders and never stored.
+func (a *Agent) storeResponse(ctx context.Context, key string, group OptimizationGroup, resp *llm.Response, cost float64) {
+	if !a.responseCacheEnabled() || llm.Simulated(ctx) {
+		return
+	}
+	err := a.responses.StoreCachedResponse(ctx, models.CachedResponse{
+		Key:              key,
+		Group:            string(group),
+		Model:            resp.Model,
+		Content:          resp.Content,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		CostUSD:          cost,
+		ExpiresAt:        time.Now().Add(a.config.Agent.ResponseCacheTTL),
+	})
+	if err != nil {
+		fmt.Printf("Failed to cache %s answer: %v\n", group, err)
+	}
+}
+
+// optimize runs the optimization call of a group, or reuses the cached answer to the
+// same inputs (then no image analysis, web search or model call is made), and parses it
+func (a *Agent) optimize(ctx context.Context, product *models.Product, group OptimizationGroup,
+	request func(context.Context, *models.Product) llm.Request,
+	parse func(context.Context, *models.Product, *llm.Response) ([]models.Proposal, error)) ([]models.Proposal, error) {
+	key := a.responseKey(ctx, product, group)
+	resp := a.cachedResponse(ctx, key)
+	cached := resp != nil
+	cost := 0.0
+	if !cached {
+		var err error
+		resp, err = a.client.Chat(ctx, request(ctx, product))
+		if err != nil {
+			return nil, fmt.Errorf("optimization call failed: %w", err)
+		}
+		cost = a.costUSD(ctx, resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+		a.recordUsageCost(ctx, resp.Model, resp.Usage, cost)
+	}
+	if session := sessionFrom(ctx); session != nil {
+		session.Model = resp.Model
+		session.Cached = cached
+	}
+
+	proposals, err := parse(ctx, product, resp)
+	if err == nil && !cached {
+		a.storeResponse(ctx, key, group, resp, cost)
+	}
+	return proposals, err
+}
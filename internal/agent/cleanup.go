@@ -0,0 +1,33 @@
+package agent
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// cleanupGroups are the groups owning the text fields tools.CheckText cleans
+var cleanupGroups = map[string][]OptimizationGroup{
+	"title":       {GroupAll, GroupRequiredAttributes, GroupTitleOptimization},
+	"description": {GroupAll, GroupRequiredAttributes, GroupDescOptimization},
+}
+
+// cleanupProposals propose the cleaned title and description of a product (HTML stripped,
+// whitespace normalized, descriptions deshouted) for the fields no other proposal of the
+// run changes: a model rewrite is made from the cleaned text already, see productdata.Slim
+func (a *Agent) cleanupProposals(product *models.Product, group OptimizationGroup, made []models.Proposal) []models.Proposal {
+	var proposals []models.Proposal
+	for _, f := range tools.CheckText(product.RawData) {
+		if !slices.Contains(cleanupGroups[f.Field], group) {
+			continue
+		}
+		if slices.ContainsFunc(made, func(p models.Proposal) bool { return strings.EqualFold(p.Field, f.Field) }) {
+			continue
+		}
+		source := models.Source{Type: "feed", Reference: f.Field, Evidence: f.Reason, Confidence: 1, Verified: true}
+		proposals = append(proposals, a.codeProposal(product, f.Field, f.Before, f.After, "Cleanup: "+f.Reason, source, "low"))
+	}
+	return proposals
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// codeProposals are the proposals a group makes without the model: price format fixes
+// (priceProposals), the google_product_category mapping (categoryProposal), the
+// standard form of the feed's color (colorProposal), size fixes (sizeProposals),
+// availability and condition values (enumProposals), the canonical brand
+// (brandProposal) and the GTIN decoded from a barcode of the images (barcodeProposal).
+// They are made before the model's answer is read, see dropModelProposal.
+func (a *Agent) codeProposals(ctx context.Context, product *models.Product, group OptimizationGroup) []models.Proposal {
+	var proposals []models.Proposal
+	if checksPrices(group) {
+		proposals = append(proposals, a.priceProposals(ctx, product)...)
+	}
+	if checksLandingPage(group) {
+		a.landingPageIssues(ctx, product)
+	}
+	if measuresImages(group) {
+		a.imageIssues(ctx, product)
+	}
+	if readsImageText(group) {
+		a.imageTextIssues(ctx, product)
+	}
+	if readsImageCodes(group) {
+		if p := a.barcodeProposal(ctx, product); p != nil {
+			proposals = append(proposals, *p)
+		}
+	}
+	if mapsCategories(group) {
+		if p := a.categoryProposal(product); p != nil {
+			proposals = append(proposals, *p)
+		}
+	}
+	if normalizesColors(group) && !a.colorContradiction(ctx, product) {
+		if p := a.colorProposal(product); p != nil {
+			proposals = append(proposals, *p)
+		}
+	}
+	if normalizesSizes(group) {
+		proposals = append(proposals, a.sizeProposals(product)...)
+	}
+	proposals = append(proposals, a.enumProposals(product, group)...)
+	if canonicalizesBrands(group) {
+		if p := a.brandProposal(ctx, product); p != nil {
+			proposals = append(proposals, *p)
+		}
+	}
+	return proposals
+}
+
+// normalizeModelValue puts a value proposed by the model in the form code would write:
+// colors in GMC standard names ("bleu marine" → "navy"), sizes, size systems and
+// availability and condition values in GMC form ("xl" → "XL", "En stock" → "in_stock").
+// Values it doesn't recognize are kept as proposed.
+func normalizeModelValue(field, value string) string {
+	switch strings.ToLower(strings.TrimSpace(field)) {
+	case "color":
+		if color, ok := tools.NormalizeColor(value); ok {
+			return color.String()
+		}
+	case "size":
+		if size, ok := tools.NormalizeSize(value); ok {
+			return size.Value
+		}
+	case "size_system":
+		if system, ok := tools.NormalizeSizeSystem(value); ok {
+			return system
+		}
+	case "availability":
+		if v, ok := tools.NormalizeAvailability(value); ok {
+			return v
+		}
+	case "condition":
+		if v, ok := tools.NormalizeCondition(value); ok {
+			return v
+		}
+	}
+	return value
+}
+
+// dropModelProposal reports whether a model proposal is left out for code: its field has
+// a code proposal or a contradiction queued for review, it is a price field of a group
+// checking prices, or it names a google_product_category that is not in the taxonomy
+func (a *Agent) dropModelProposal(ctx context.Context, group OptimizationGroup, field, after string, code []models.Proposal) bool {
+	for _, p := range code {
+		if strings.EqualFold(p.Field, field) {
+			return true
+		}
+	}
+	if contradicted(ctx, field) {
+		return true
+	}
+	if checksPrices(group) && isPriceField(field) {
+		return true
+	}
+	if mapsCategories(group) && isCategoryField(field) && !a.knownCategory(after) {
+		if a.callbacks.OnLog != nil {
+			a.callbacks.OnLog(fmt.Sprintf("⚠️ Filtered google_product_category not in the Google taxonomy: '%s'", truncateString(after, 60)))
+		}
+		return true
+	}
+	return false
+}
+
+// codeProposal builds a proposal made by code from its single source, whose confidence
+// it takes, and reports it to OnProposal
+func (a *Agent) codeProposal(product *models.Product, field, before, after, reason string, source models.Source, risk string) models.Proposal {
+	sourceJSON, _ := json.Marshal([]models.Source{source})
+	proposal := models.Proposal{
+		ID:          uuid.New(),
+		ProductID:   product.ID,
+		Field:       field,
+		BeforeValue: &before,
+		AfterValue:  after,
+		Rationale:   []string{reason},
+		Sources:     sourceJSON,
+		Confidence:  source.Confidence,
+		RiskLevel:   risk,
+		Status:      "proposed",
+		CreatedAt:   time.Now(),
+	}
+	if a.callbacks.OnProposal != nil {
+		a.callbacks.OnProposal(proposal)
+	}
+	return proposal
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// normalizesColors reports whether a group proposes the standard form of the feed's color
+func normalizesColors(group OptimizationGroup) bool {
+	return group == GroupAll || group == GroupRecommendedAttrs
+}
+
+// colorProposal proposes the GMC standard form of the feed's color ("Bleu marine" →
+// "navy"); nil when the product has no color, it is already standard or not recognized
+func (a *Agent) colorProposal(product *models.Product) *models.Proposal {
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+	current := getFieldValueFromMap(fields, "color")
+	color, ok := tools.NormalizeColor(current)
+	if !ok || strings.EqualFold(color.String(), current) {
+		return nil
+	}
+
+	source := models.Source{Type: "feed", Reference: "color", Evidence: current, Confidence: 1, Verified: true}
+	proposal := a.codeProposal(product, "color", current, color.String(), "Color in GMC standard names, primary color first", source, "low")
+	return &proposal
+}
+
+// colorContradiction queues a contradiction for review when the vision analysis of the main
+// image sees, with at least the auto-verify confidence, a color the feed's color can't be
+// (see tools.ColorsContradict). Wrong colors drive returns, so the feed's color is then
+// neither normalized nor overwritten: a reviewer decides. Like imageTextIssues, it only reads
+// an analysis still in the vision cache.
+func (a *Agent) colorContradiction(ctx context.Context, product *models.Product) bool {
+	imageURL := extractImageURL(product.RawData)
+	if imageURL == "" || llm.Simulated(ctx) {
+		return false
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+	current := getFieldValueFromMap(fields, "color")
+	feed, ok := tools.NormalizeColor(current)
+	if !ok {
+		return false
+	}
+	analysis := a.vision.get(a.visionModelFor() + "|" + imageURL)
+	if analysis == nil {
+		return false
+	}
+	var attributes struct {
+		Color           string  `json:"color"`
+		ColorConfidence float64 `json:"color_confidence"`
+	}
+	json.Unmarshal(analysis.sections[visionAttributes], &attributes)
+	seen, ok := tools.NormalizeColor(attributes.Color)
+	if !ok || attributes.ColorConfidence < a.thresholdsFrom(ctx).AutoVerifyConfidence || !tools.ColorsContradict(feed, seen) {
+		return false
+	}
+
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog(fmt.Sprintf("🎨 Color contradiction: feed says %s, image shows %s (%.2f)", current, attributes.Color, attributes.ColorConfidence))
+	}
+	s := sessionFrom(ctx)
+	if s == nil {
+		return true
+	}
+	details, _ := json.Marshal(map[string]any{
+		"feed_color":       current,
+		"image_color":      seen.String(),
+		"image_confidence": attributes.ColorConfidence,
+		"image_url":        imageURL,
+	})
+	s.HumanReviews = append(s.HumanReviews, s.newHumanReview(models.HumanReview{
+		Source:    "contradiction",
+		Field:     "color",
+		Question:  fmt.Sprintf("The feed's color is %q but the product image shows %s: which is right?", current, seen.String()),
+		RiskLevel: "high",
+		Context:   details,
+		Options:   []string{"Keep " + current, "Change to " + seen.String(), "The image shows another product or variant"},
+	}))
+	return true
+}
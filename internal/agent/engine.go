@@ -0,0 +1,283 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/agents"
+	"github.com/benjamincozon/feedenrich/internal/agent/pipeline"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// Engine is the execution path of an enrichment run
+type Engine string
+
+const (
+	EngineAgent        Engine = "agent"         // single optimization call per group (fast or focused mode)
+	EnginePipeline     Engine = "pipeline"      // audit → evidence → retrieval → plan → write → control, one call per stage
+	EngineFastPipeline Engine = "fast_pipeline" // one combined call, then deterministic control
+	EngineTools        Engine = "tools"         // tool-calling loop, one call per step (see RunSteps)
+)
+
+// ErrUnknownEngine is returned for an engine name that is not one of the Engine values
+var ErrUnknownEngine = errors.New("unknown engine")
+
+// ErrEngineGroup is returned when a pipeline or tools engine is asked for a single group:
+// they always optimize every field
+var ErrEngineGroup = errors.New("pipeline and tools engines only run the all group")
+
+// ParseEngine validates an engine name; empty returns "" so the caller can fall back
+// to its default
+func ParseEngine(s string) (Engine, error) {
+	switch e := Engine(s); e {
+	case "", EngineAgent, EnginePipeline, EngineFastPipeline, EngineTools:
+		return e, nil
+	}
+	return "", fmt.Errorf("%w: %q (agent, pipeline, fast_pipeline or tools)", ErrUnknownEngine, s)
+}
+
+// RunEngine runs a product through the given engine. The agent engine is RunGroups and
+// the tools engine RunSteps; the pipelines produce the same session (proposals, human reviews, usage, one trace per
+// stage) so callers save them the same way.
+func (a *Agent) RunEngine(ctx context.Context, product *models.Product, goal string, groups []OptimizationGroup, engine Engine) (*Session, error) {
+	if engine == "" || engine == EngineAgent {
+		return a.RunGroups(ctx, product, goal, groups)
+	}
+	if len(groups) > 1 || (len(groups) == 1 && groups[0] != GroupAll) {
+		return nil, ErrEngineGroup
+	}
+	if engine == EngineTools {
+		return a.RunSteps(ctx, product, goal)
+	}
+
+	session := a.newSession(ctx, product, goal)
+	session.Group = GroupAll
+	session.Engine = engine
+	ctx = withSession(ctx, session)
+	// Pipeline calls don't report their usage: it is read from the call log afterwards
+	calls := &llm.CallLog{}
+	ctx = llm.WithCallLog(ctx, calls)
+	if a.config.LLM.CallLog {
+		defer session.keepCalls(calls)
+	}
+	ctx, err := a.WithBudget(ctx, product.DatasetID)
+	if err != nil {
+		session.Status = "failed"
+		return session, err
+	}
+
+	var result *pipeline.PipelineResult
+	feedback := a.feedbackFor(ctx, product.DatasetID)
+	imageProblem := a.brokenImage(ctx, product, extractImageURL(product.RawData))
+	barcodes := a.imageBarcodes(ctx, product)
+	if engine == EnginePipeline {
+		p := pipeline.NewPipeline(a.config)
+		p.SetFeedback(feedback)
+		p.SetTrustedDomains(a.trustedDomains(ctx, product.DatasetID))
+		p.SetImageProblem(imageProblem)
+		p.SetImageCodes(barcodes)
+		p.SetAutoVerifyConfidence(session.Thresholds.AutoVerifyConfidence)
+		result, err = p.Run(ctx, product)
+	} else {
+		p := pipeline.NewFastPipeline(a.config)
+		p.SetFeedback(feedback)
+		p.SetImageProblem(imageProblem)
+		p.SetImageCodes(barcodes)
+		result, err = p.Run(ctx, product)
+	}
+	for _, c := range calls.Calls() {
+		if c.Error == "" {
+			a.recordUsage(ctx, c.Model, c.Usage)
+			session.Model = c.Model
+		}
+	}
+	if err != nil {
+		if a.callbacks.OnError != nil {
+			a.callbacks.OnError(err)
+		}
+		session.Status = "failed"
+		return session, err
+	}
+
+	session.PipelineRun = pipelineRun(session, result)
+	code := a.codeProposals(ctx, product, GroupAll)
+	session.Proposals = a.pipelineProposals(ctx, session, result, code)
+	session.PipelineRun.RetrievedFacts = retrievedFacts(result, session.Proposals)
+	code = append(code, a.cleanupProposals(product, GroupAll, slices.Concat(session.Proposals, code))...)
+	for _, p := range code {
+		p.SessionID = &session.ID
+		p.Module = string(GroupAll)
+		session.Proposals = append(session.Proposals, p)
+	}
+	a.ScoreProposals(ctx, product, session.Proposals)
+	for _, r := range result.HumanReviews(product) {
+		r.SessionID = &session.ID
+		session.HumanReviews = append(session.HumanReviews, r)
+	}
+	for i, stage := range result.Stages {
+		thought := fmt.Sprintf("%s stage %s", engine, stage.Stage)
+		if stage.Error != "" {
+			thought += ": " + stage.Error
+		}
+		session.Traces = append(session.Traces, models.AgentTrace{
+			ID:         uuid.New(),
+			SessionID:  session.ID,
+			StepNumber: i + 1,
+			Thought:    thought,
+			ToolName:   stage.Stage,
+			ToolOutput: stage.Output,
+			DurationMs: int(stage.DurationMs),
+			Retries:    stage.Retries,
+			CreatedAt:  stage.EndedAt,
+		})
+	}
+	session.Status = "completed"
+
+	if a.callbacks.OnComplete != nil {
+		usage := session.Usage()
+		a.callbacks.OnComplete(SessionSummary{
+			TotalSteps:       len(session.Traces),
+			TokensUsed:       usage.PromptTokens + usage.CompletionTokens,
+			DurationMs:       time.Since(session.StartedAt).Milliseconds(),
+			ProposalsCreated: len(session.Proposals),
+		})
+	}
+	return session, nil
+}
+
+// pipelineProposals converts the proposals of a pipeline run, applying the run's
+// thresholds like the agent engine does. The facts the writer used become the sources.
+// Fields with code proposals are left to them, see dropModelProposal.
+func (a *Agent) pipelineProposals(ctx context.Context, session *Session, result *pipeline.PipelineResult, code []models.Proposal) []models.Proposal {
+	proposals := []models.Proposal{}
+	for _, p := range result.Proposals {
+		risk := "medium"
+		if p.Risk != nil {
+			risk = p.Risk.Level
+		}
+		after := normalizeModelValue(p.Field, p.After)
+		if after == "" || after == p.Before || !a.keepProposal(session.Thresholds, p.Field, p.Confidence, risk) {
+			continue
+		}
+		if a.dropModelProposal(ctx, GroupAll, p.Field, after, code) {
+			continue
+		}
+
+		verified := p.Verified && p.Confidence >= session.Thresholds.AutoVerifyConfidence
+		sources := []models.Source{}
+		for _, f := range p.FactsUsed {
+			sources = append(sources, models.Source{Type: string(session.Engine), Reference: f.Source, Evidence: f.Fact, Confidence: p.Confidence, Verified: verified})
+		}
+		if len(sources) == 0 {
+			sources = append(sources, models.Source{Type: string(session.Engine), Confidence: p.Confidence, Verified: verified})
+		}
+		sourceJSON, _ := json.Marshal(sources)
+
+		before := p.Before
+		proposal := models.Proposal{
+			ID:          p.ID,
+			ProductID:   session.ProductID,
+			SessionID:   &session.ID,
+			Field:       p.Field,
+			BeforeValue: &before,
+			AfterValue:  after,
+			Rationale:   []string{p.Objective},
+			Sources:     sourceJSON,
+			Confidence:  p.Confidence,
+			RiskLevel:   risk,
+			Status:      "proposed",
+			Module:      string(GroupAll),
+			CreatedAt:   time.Now(),
+		}
+		proposals = append(proposals, proposal)
+		if a.callbacks.OnProposal != nil {
+			a.callbacks.OnProposal(proposal)
+		}
+	}
+	return proposals
+}
+
+// retrievedFacts lists the facts of the retrieval stage for the pipeline trail, linked to
+// the kept proposals they back: a row per proposal, facts no kept proposal used unlinked
+func retrievedFacts(result *pipeline.PipelineResult, kept []models.Proposal) []models.RetrievedFact {
+	keptIDs := make(map[uuid.UUID]bool, len(kept))
+	for _, p := range kept {
+		keptIDs[p.ID] = true
+	}
+	facts := []models.RetrievedFact{}
+	linked := map[agents.SourcedFact]bool{}
+	for _, p := range result.Proposals {
+		if !keptIDs[p.ID] {
+			continue
+		}
+		for _, f := range p.RetrievedFacts {
+			facts = append(facts, retrievedFact(f, &p.ID))
+			linked[f] = true
+		}
+	}
+	for _, f := range result.RetrievedFacts {
+		if !linked[f] {
+			facts = append(facts, retrievedFact(f, nil))
+		}
+	}
+	return facts
+}
+
+func retrievedFact(f agents.SourcedFact, proposalID *uuid.UUID) models.RetrievedFact {
+	return models.RetrievedFact{
+		ProposalID: proposalID,
+		Field:      f.Field,
+		Value:      f.Value,
+		Source:     f.Source,
+		URL:        f.URL,
+		Evidence:   f.Evidence,
+		Confidence: f.Confidence,
+		Trusted:    f.Trusted,
+	}
+}
+
+// pipelineRun keeps the audit trail of a pipeline run for the session to save
+func pipelineRun(session *Session, result *pipeline.PipelineResult) *models.PipelineRun {
+	run := &models.PipelineRun{
+		ID:            uuid.New(),
+		SessionID:     session.ID,
+		ProductID:     session.ProductID,
+		Engine:        string(session.Engine),
+		Vertical:      string(result.Vertical),
+		StartedAt:     result.StartedAt,
+		CompletedAt:   result.CompletedAt,
+		EvidenceTrail: result.EvidenceTrail,
+		Stages:        []models.PipelineStage{},
+		Rejections:    []models.PipelineRejection{},
+		CreatedAt:     time.Now(),
+	}
+	if result.Summary != nil {
+		run.Summary, _ = json.Marshal(result.Summary)
+	}
+	for _, s := range result.Stages {
+		run.Stages = append(run.Stages, models.PipelineStage{
+			Stage:      s.Stage,
+			StartedAt:  s.StartedAt,
+			EndedAt:    s.EndedAt,
+			DurationMs: s.DurationMs,
+			Retries:    s.Retries,
+			Output:     s.Output,
+			Error:      s.Error,
+		})
+	}
+	for _, r := range result.Rejections {
+		run.Rejections = append(run.Rejections, models.PipelineRejection{
+			Field:    r.Field,
+			Reason:   r.Reason,
+			Evidence: r.Evidence,
+			Stage:    r.Stage,
+		})
+	}
+	return run
+}
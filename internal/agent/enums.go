@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// enumFields are the enum fields normalized in code, with the groups that own them
+var enumFields = []struct {
+	field     string
+	normalize func(string) (string, bool)
+	groups    []OptimizationGroup
+}{
+	{"availability", tools.NormalizeAvailability, []OptimizationGroup{GroupAll, GroupCriticalErrors}},
+	{"condition", tools.NormalizeCondition, []OptimizationGroup{GroupAll, GroupRequiredAttributes}},
+}
+
+// enumProposals propose the GMC value of vendor availability and condition values
+// ("En stock" → "in_stock", "occasion" → "used"); values already valid or unknown get none
+func (a *Agent) enumProposals(product *models.Product, group OptimizationGroup) []models.Proposal {
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+
+	var proposals []models.Proposal
+	for _, e := range enumFields {
+		if !slices.Contains(e.groups, group) {
+			continue
+		}
+		current := getFieldValueFromMap(fields, e.field)
+		value, ok := e.normalize(current)
+		if !ok || value == current {
+			continue
+		}
+		source := models.Source{Type: "feed", Reference: e.field, Evidence: current, Confidence: 1, Verified: true}
+		proposals = append(proposals, a.codeProposal(product, e.field, current, value, "GMC value of the feed's "+e.field, source, "low"))
+	}
+	return proposals
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// FeedbackSource reads the review decisions on the proposals of a dataset
+type FeedbackSource interface {
+	GetReviewFeedback(ctx context.Context, datasetID uuid.UUID, since time.Time, minReviews int) ([]models.FieldFeedback, error)
+}
+
+// feedbackTTL is how long the feedback of a dataset is reused before it is read again, so
+// a job reads it once every few minutes rather than for every product
+const feedbackTTL = 5 * time.Minute
+
+// feedbackRejectRate is the rejection rate from which the prompts are told to hold back
+// proposals on a field
+const feedbackRejectRate = 0.5
+
+type feedbackEntry struct {
+	prompt  string
+	expires time.Time
+}
+
+// feedbackCache keeps the feedback prompt section of recent datasets
+type feedbackCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]feedbackEntry
+}
+
+// SetFeedbackSource makes the optimization prompts of a dataset say how reviewers decided
+// its proposals over AGENT_FEEDBACK_WINDOW, so fields they keep rejecting are held back
+func (a *Agent) SetFeedbackSource(source FeedbackSource) {
+	a.feedback = source
+	a.feedbackCache = &feedbackCache{entries: map[uuid.UUID]feedbackEntry{}}
+}
+
+// feedbackFor returns the feedback prompt section of a dataset, "" when there is no
+// feedback source, the window is 0 or too few proposals were reviewed
+func (a *Agent) feedbackFor(ctx context.Context, datasetID uuid.UUID) string {
+	window := a.config.Agent.FeedbackWindow
+	if a.feedback == nil || window <= 0 {
+		return ""
+	}
+	c := a.feedbackCache
+	c.mu.Lock()
+	entry, ok := c.entries[datasetID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.prompt
+	}
+
+	stats, err := a.feedback.GetReviewFeedback(ctx, datasetID, time.Now().Add(-window), a.config.Agent.FeedbackMinReviews)
+	if err != nil {
+		fmt.Printf("Failed to load review feedback of dataset %s: %v\n", datasetID, err)
+		return entry.prompt
+	}
+	prompt := feedbackPrompt(stats, window)
+	c.mu.Lock()
+	c.entries[datasetID] = feedbackEntry{prompt: prompt, expires: time.Now().Add(feedbackTTL)}
+	c.mu.Unlock()
+	return prompt
+}
+
+// withFeedback appends the feedback of the product's dataset to a system prompt
+func (a *Agent) withFeedback(ctx context.Context, datasetID uuid.UUID, systemPrompt string) string {
+	feedback := a.feedbackFor(ctx, datasetID)
+	if feedback == "" {
+		return systemPrompt
+	}
+	return systemPrompt + "\n\n" + feedback
+}
+
+// feedbackPrompt sums up review decisions by field:
+// "- material: reviewers rejected 80% of 25 proposals"
+func feedbackPrompt(stats []models.FieldFeedback, window time.Duration) string {
+	if len(stats) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	period := window.String()
+	if days := int(window.Hours() / 24); days >= 1 {
+		period = fmt.Sprintf("%d days", days)
+	}
+	fmt.Fprintf(&b, "=== REVIEWER FEEDBACK (this catalog, last %s) ===\n", period)
+
+	var heldBack []string
+	for _, f := range stats {
+		reviewed := f.Accepted + f.Edited + f.Rejected
+		rejected := float64(f.Rejected) / float64(reviewed)
+		if rejected >= feedbackRejectRate {
+			heldBack = append(heldBack, f.Field)
+			fmt.Fprintf(&b, "- %s: reviewers rejected %.0f%% of %d proposals\n", f.Field, rejected*100, reviewed)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: reviewers accepted %.0f%% of %d proposals", f.Field, float64(f.Accepted+f.Edited)/float64(reviewed)*100, reviewed)
+		if f.Edited > 0 {
+			fmt.Fprintf(&b, " (%d after an edit)", f.Edited)
+		}
+		b.WriteString("\n")
+	}
+	if len(heldBack) > 0 {
+		fmt.Fprintf(&b, "Reviewers keep rejecting changes to %s: only propose them with explicit evidence from the feed, otherwise leave them out.\n", strings.Join(heldBack, ", "))
+	}
+	b.WriteString("Favor the kinds of proposals reviewers accept.")
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// ImageAudit is what the image audit found on a product's main image: measured on the
+// downloaded pixels, then read by the vision analysis (its quality section)
+type ImageAudit struct {
+	URL         string
+	Problem     string // the image failed its last check or couldn't be downloaded
+	Width       int    // 0 when the format can't be measured
+	Height      int
+	Background  string // white, transparent, colored or lifestyle; empty when not measured
+	ProductFill int    // 0 on lifestyle backgrounds
+	FillInRange bool   // the product spans what GMC recommends of the image
+	// From the vision analysis, when AGENT_ENABLE_VISION is on
+	Analyzed     bool
+	Watermark    bool
+	TextOverlay  bool
+	PromoOverlay bool // an overlay GMC disapproves, see tools.CheckImageText
+	QualityScore int  // 0-100
+}
+
+// AuditImage measures and analyzes the main image of a product for the image audit,
+// without the optimization call: nothing is proposed. nil when the product has no image.
+// The vision analysis is shared with the enrichment runs through the vision cache.
+func (a *Agent) AuditImage(ctx context.Context, product *models.Product) (*ImageAudit, error) {
+	imageURL := extractImageURL(product.RawData)
+	if imageURL == "" {
+		return nil, nil
+	}
+	audit := &ImageAudit{URL: imageURL}
+	if problem := a.brokenImage(ctx, product, imageURL); problem != "" {
+		audit.Problem = problem
+		return audit, nil
+	}
+
+	if a.images != nil {
+		minSide := gmcMinImageSide
+		if tools.DetectVertical(product.RawData) == tools.VerticalApparel {
+			minSide = gmcMinApparelImageSide
+		}
+		m := a.measureImage(ctx, "image_link", imageURL, minSide)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if m.Error != "" {
+			audit.Problem = m.Error
+			return audit, nil
+		}
+		audit.Width, audit.Height = m.Width, m.Height
+		audit.Background, audit.ProductFill = m.Background, m.ProductFill
+		audit.FillInRange = m.ProductFill >= gmcMinProductFill && m.ProductFill <= gmcMaxProductFill
+	}
+
+	if !a.config.Agent.EnableVision {
+		return audit, nil
+	}
+	ctx, err := a.WithBudget(ctx, product.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+	analysis, err := a.analyzeImage(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+	var quality struct {
+		Watermarks   bool              `json:"watermarks"`
+		TextOverlay  bool              `json:"text_overlay"`
+		Text         []tools.ImageText `json:"text"`
+		QualityScore float64           `json:"quality_score"`
+	}
+	json.Unmarshal(analysis[visionQuality], &quality)
+	audit.Analyzed = true
+	audit.Watermark, audit.TextOverlay = quality.Watermarks, quality.TextOverlay
+	audit.QualityScore = int(math.Round(quality.QualityScore))
+	for _, t := range quality.Text {
+		switch t.Kind {
+		case tools.ImageTextWatermark:
+			audit.Watermark = true
+		case tools.ImageTextOverlay:
+			audit.TextOverlay = true
+		}
+	}
+	for _, v := range tools.CheckImageText(quality.Text) {
+		if v.Match != "" {
+			audit.PromoOverlay = true
+		}
+	}
+	return audit, nil
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ImageCheckSource reads the last check of a product's image_link (see package imagecheck),
+// nil when it was never checked
+type ImageCheckSource interface {
+	GetImageCheck(ctx context.Context, productID uuid.UUID) (*models.ImageCheck, error)
+}
+
+// SetImageCheckSource makes vision skip images their last check found broken
+// (IMAGE_CHECK_SKIP_BROKEN)
+func (a *Agent) SetImageCheckSource(source ImageCheckSource) {
+	a.imageChecks = source
+}
+
+// ImageLink returns the main image URL of a product as the agent analyses it; "" when it
+// has none
+func ImageLink(p *models.Product) string {
+	return extractImageURL(p.RawData)
+}
+
+// brokenImage returns the problem the last check found with a product's image, "" when the
+// image was fine, never checked or checked at another URL
+func (a *Agent) brokenImage(ctx context.Context, product *models.Product, imageURL string) string {
+	if a.imageChecks == nil || !a.config.ImageCheck.SkipBroken || product == nil || imageURL == "" {
+		return ""
+	}
+	check, err := a.imageChecks.GetImageCheck(ctx, product.ID)
+	if err != nil {
+		fmt.Printf("Failed to read the image check of product %s: %v\n", product.ID, err)
+		return ""
+	}
+	if check == nil || check.URL != imageURL || !check.Broken() {
+		return ""
+	}
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog(fmt.Sprintf("⚠️ Image %s failed its last check (%s) - skipping image analysis", imageURL, check.Problem))
+	}
+	return check.Problem
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/barcode"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// Confidence of the identifiers read in product images
+const (
+	barcodeConfidence    = 0.98 // decoded from the bars, check digit verified
+	visionGTINConfidence = 0.9  // digits under a barcode read by the model, check digit verified
+	visionMPNConfidence  = 0.8
+)
+
+// readsImageCodes reports whether a group looks for the GTIN and MPN in the product images
+func readsImageCodes(group OptimizationGroup) bool {
+	return group == GroupAll || group == GroupRequiredAttributes
+}
+
+// imageBarcodes decodes the barcodes of the product's main image and first additional
+// images, downloaded through the image proxy (cached on disk). Only valid GTINs are kept.
+// Estimates don't download images.
+func (a *Agent) imageBarcodes(ctx context.Context, product *models.Product) []tools.ImageCode {
+	if a.images == nil || llm.Simulated(ctx) {
+		return nil
+	}
+	var codes []tools.ImageCode
+	seen := map[string]bool{}
+	for _, image := range productImages(product) {
+		img, err := a.images.Get(ctx, image.url, 0, 0)
+		if err != nil {
+			continue // measureImages reports images that can't be downloaded
+		}
+		for _, gtin := range barcode.Scan(img.Data) {
+			if seen[gtin] || tools.CheckGTIN(gtin) != nil {
+				continue
+			}
+			seen[gtin] = true
+			codes = append(codes, tools.ImageCode{
+				Field: "gtin", Value: gtin, ImageURL: image.url,
+				Method: tools.ImageCodeBarcode, Confidence: barcodeConfidence,
+			})
+		}
+	}
+	return codes
+}
+
+// imageCodes returns the identifiers read in the product images: the decoded barcodes,
+// then what the vision analysis of the main image read on the label when the feed still
+// lacks the gtin or mpn. The vision answer is shared with the other groups.
+func (a *Agent) imageCodes(ctx context.Context, product *models.Product) []tools.ImageCode {
+	codes := a.imageBarcodes(ctx, product)
+
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+	needGTIN := getFieldValueFromMap(fields, "gtin") == "" && len(codes) == 0
+	needMPN := getFieldValueFromMap(fields, "mpn") == ""
+	imageURL := extractImageURL(product.RawData)
+	if !needGTIN && !needMPN || !a.config.Agent.EnableVision || llm.Simulated(ctx) ||
+		imageURL == "" || a.brokenImage(ctx, product, imageURL) != "" {
+		return codes
+	}
+	analysis, err := a.analyzeImage(ctx, imageURL)
+	if err != nil {
+		return codes
+	}
+	var identifiers struct {
+		GTIN string `json:"gtin"`
+		MPN  string `json:"mpn"`
+	}
+	json.Unmarshal(analysis[visionIdentifiers], &identifiers)
+	if gtin := tools.NormalizeGTIN(identifiers.GTIN); needGTIN && gtin != "" && tools.CheckGTIN(gtin) == nil {
+		codes = append(codes, tools.ImageCode{
+			Field: "gtin", Value: gtin, ImageURL: imageURL,
+			Method: tools.ImageCodeVision, Confidence: visionGTINConfidence,
+		})
+	}
+	if mpn := strings.TrimSpace(identifiers.MPN); needMPN && mpn != "" {
+		codes = append(codes, tools.ImageCode{
+			Field: "mpn", Value: mpn, ImageURL: imageURL,
+			Method: tools.ImageCodeVision, Confidence: visionMPNConfidence,
+		})
+	}
+	return codes
+}
+
+// imageCodeContext gives the optimization call the identifiers read in the product images
+func (a *Agent) imageCodeContext(codes []tools.ImageCode) string {
+	if len(codes) == 0 {
+		return ""
+	}
+	content, _ := json.Marshal(codes)
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog(fmt.Sprintf("🏷️ Identifiers read in images: %s", content))
+	}
+	return "\n\n=== IDENTIFIERS READ IN PRODUCT IMAGES (barcodes are decoded and exact; use them for gtin/mpn with their image as source) ===\n" + string(content)
+}
+
+// barcodeProposal proposes the GTIN decoded from the product images when the feed has none,
+// and queues for review a feed GTIN no barcode of the images matches. Several different
+// barcodes (a bundle, or a photo of another product) are left to a reviewer.
+func (a *Agent) barcodeProposal(ctx context.Context, product *models.Product) *models.Proposal {
+	codes := a.imageBarcodes(ctx, product)
+	if len(codes) == 0 {
+		return nil
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+	feed := tools.NormalizeGTIN(getFieldValueFromMap(fields, "gtin"))
+
+	values := make([]string, len(codes))
+	for i, c := range codes {
+		values[i] = c.Value
+		if feed != "" && sameGTIN(feed, c.Value) {
+			return nil
+		}
+	}
+	if feed != "" {
+		escalateIssue(ctx, "gtin", "high", fmt.Sprintf("gtin %s does not match the barcode read in the product images (%s)", feed, strings.Join(values, ", ")))
+		return nil
+	}
+	if len(codes) > 1 {
+		escalateIssue(ctx, "gtin", "high", fmt.Sprintf("the product images show several barcodes (%s): which one is the product's gtin?", strings.Join(values, ", ")))
+		return nil
+	}
+
+	code := codes[0]
+	source := models.Source{
+		Type:       "vision",
+		Reference:  code.ImageURL,
+		Evidence:   fmt.Sprintf("barcode %s decoded from the product image, check digit valid", code.Value),
+		Confidence: code.Confidence,
+		Verified:   code.Confidence >= a.config.Agent.AutoVerifyConfidence,
+	}
+	proposal := a.codeProposal(product, "gtin", "", code.Value, "Decoded from the barcode shown in a product image", source, "medium")
+	return &proposal
+}
+
+// sameGTIN compares GTINs of different lengths: a UPC-A is an EAN-13 with a leading zero
+func sameGTIN(a, b string) bool {
+	return strings.TrimLeft(a, "0") == strings.TrimLeft(b, "0")
+}
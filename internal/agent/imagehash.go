@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// nearDuplicateDistance is the largest hash distance between two images of a product read
+// as the same picture (recompressed, resized, slightly retouched)
+const nearDuplicateDistance = 6
+
+// ImageHashStore keeps the perceptual hashes of the images measured in a dataset
+type ImageHashStore interface {
+	SaveImageHashes(ctx context.Context, productID, datasetID uuid.UUID, itemGroupID string, hashes map[string]int64) error
+	CountSharedImages(ctx context.Context, datasetID, productID uuid.UUID, itemGroupID string, hashes []int64) (map[int64]int, error)
+}
+
+// SetImageHashStore makes the image analysis group flag images shown by many unrelated
+// products of the dataset (AGENT_PLACEHOLDER_MIN_PRODUCTS)
+func (a *Agent) SetImageHashStore(store ImageHashStore) {
+	a.imageHashes = store
+}
+
+// compareImages flags the additional images that repeat an earlier image of the product,
+// and the images other item groups of the dataset show too: a placeholder ("image coming
+// soon", logo) more often than a real photo. Products are compared with those measured
+// before them, so the first products showing a placeholder aren't flagged.
+func (a *Agent) compareImages(ctx context.Context, product *models.Product, measured []imageMeasurement) {
+	for i := range measured {
+		m := &measured[i]
+		if !m.hashed {
+			continue
+		}
+		for _, earlier := range measured[:i] {
+			if earlier.hashed && imageproxy.HashDistance(m.hash, earlier.hash) <= nearDuplicateDistance {
+				m.DuplicateOf = earlier.URL
+				repeated := "the main image"
+				if earlier.Field != "image_link" {
+					repeated = "another additional image"
+				}
+				m.issue("medium", "%s %s repeats %s (%s): additional images should show other views", m.Field, m.URL, repeated, earlier.URL)
+				break
+			}
+		}
+	}
+
+	minProducts := a.config.Agent.PlaceholderMinProducts
+	if a.imageHashes == nil || minProducts <= 0 {
+		return
+	}
+	hashes := map[string]int64{}
+	var list []int64
+	for _, m := range measured {
+		if m.hashed {
+			hashes[m.URL] = int64(m.hash)
+			list = append(list, int64(m.hash))
+		}
+	}
+	if len(list) == 0 {
+		return
+	}
+	group := ItemGroupID(product)
+	if err := a.imageHashes.SaveImageHashes(ctx, product.ID, product.DatasetID, group, hashes); err != nil {
+		fmt.Printf("Failed to save the image hashes of product %s: %v\n", product.ID, err)
+	}
+	shared, err := a.imageHashes.CountSharedImages(ctx, product.DatasetID, product.ID, group, list)
+	if err != nil {
+		fmt.Printf("Failed to count the shared images of product %s: %v\n", product.ID, err)
+		return
+	}
+	for i := range measured {
+		m := &measured[i]
+		if !m.hashed {
+			continue
+		}
+		m.SharedWith = shared[int64(m.hash)]
+		if m.SharedWith < minProducts {
+			continue
+		}
+		severity := "medium"
+		if m.Field == "image_link" {
+			severity = "high" // GMC disapproves products whose main image is a placeholder
+		}
+		m.issue(severity, "%s is the same image as %d unrelated products of the dataset: likely a placeholder, not a photo of this product", m.Field, m.SharedWith)
+	}
+}
@@ -0,0 +1,243 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// GMC image requirements
+const (
+	gmcMinImageSide         = 100 // pixels, 250 for apparel
+	gmcMinApparelImageSide  = 250
+	gmcRecommendedImageSide = 800
+	gmcMaxImageBytes        = 16 << 20
+	gmcMinProductFill       = 75 // percent of the image the product should span
+	gmcMaxProductFill       = 90
+	maxMeasuredExtraImages  = 4 // additional images measured and scanned per product
+)
+
+// gmcImageFormats are the formats GMC accepts, by sniffed content type
+var gmcImageFormats = map[string]string{
+	"image/jpeg": "JPEG", "image/png": "PNG", "image/gif": "GIF", "image/webp": "WebP",
+	"image/bmp": "BMP", "image/tiff": "TIFF",
+}
+
+// imageMeasurement is what downloading an image tells about it: exact numbers the image
+// analysis group would otherwise ask the model to guess
+type imageMeasurement struct {
+	Field       string  `json:"field"`
+	URL         string  `json:"url"`
+	Format      string  `json:"format,omitempty"`
+	Width       int     `json:"width,omitempty"` // 0 when the format can't be measured
+	Height      int     `json:"height,omitempty"`
+	AspectRatio float64 `json:"aspect_ratio,omitempty"` // width / height
+	Bytes       int     `json:"bytes,omitempty"`
+	// Measured on the pixels (see imageproxy.AnalyzeComposition); empty for webp and avif
+	Background           string `json:"background,omitempty"`            // white, transparent, colored or lifestyle
+	BackgroundUniformity int    `json:"background_uniformity,omitempty"` // percent of the border matching the background
+	ProductFill          int    `json:"product_fill,omitempty"`          // percent of the width or height spanned by the product
+	// The earlier image of the product this one repeats, and how many unrelated products
+	// show it too (see compareImages)
+	DuplicateOf string       `json:"duplicate_of,omitempty"`
+	SharedWith  int          `json:"shared_with,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Issues      []imageIssue `json:"issues,omitempty"`
+
+	hash   uint64 // perceptual hash, when hashed
+	hashed bool
+}
+
+type imageIssue struct {
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// measuresImages reports whether a group checks the product images it downloads
+func measuresImages(group OptimizationGroup) bool {
+	return group == GroupImageAnalysis
+}
+
+// measureImages downloads the product's main image and its first additional images
+// through the image proxy (cached on disk), checks them against GMC requirements and
+// compares them by perceptual hash. Estimates don't download images.
+func (a *Agent) measureImages(ctx context.Context, product *models.Product) []imageMeasurement {
+	if a.images == nil || llm.Simulated(ctx) {
+		return nil
+	}
+	minSide := gmcMinImageSide
+	if tools.DetectVertical(product.RawData) == tools.VerticalApparel {
+		minSide = gmcMinApparelImageSide
+	}
+
+	var measured []imageMeasurement
+	for _, image := range productImages(product) {
+		measured = append(measured, a.measureImage(ctx, image.field, image.url, minSide))
+	}
+	a.compareImages(ctx, product, measured)
+	return measured
+}
+
+// productImage is an image of a product and the field it comes from
+type productImage struct {
+	field, url string
+}
+
+// productImages returns the product's main image and its first additional images
+func productImages(product *models.Product) []productImage {
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+	var images []productImage
+	if imageURL := extractImageURL(product.RawData); imageURL != "" {
+		images = append(images, productImage{"image_link", imageURL})
+	}
+	extra := 0
+	for _, imageURL := range additionalImageURLs(fields) {
+		if extra == maxMeasuredExtraImages {
+			break
+		}
+		if len(images) > 0 && imageURL == images[0].url {
+			continue // the main image when image_link is missing
+		}
+		images = append(images, productImage{"additional_image_link", imageURL})
+		extra++
+	}
+	return images
+}
+
+func (a *Agent) measureImage(ctx context.Context, field, imageURL string, minSide int) imageMeasurement {
+	m := imageMeasurement{Field: field, URL: imageURL}
+	// The main image is required: problems with it get the product disapproved
+	severity := "medium"
+	if field == "image_link" {
+		severity = "critical"
+	}
+	img, err := a.images.Get(ctx, imageURL, 0, 0)
+	switch {
+	case errors.Is(err, imageproxy.ErrTooLarge):
+		m.Error = err.Error()
+		if a.config.ImageProxy.MaxBytes >= gmcMaxImageBytes {
+			m.issue(severity, "%s is larger than 16 MB, the GMC maximum", field)
+		} else {
+			m.issue("medium", "%s is larger than %d MB and could not be checked (IMAGE_PROXY_MAX_BYTES)", field, a.config.ImageProxy.MaxBytes>>20)
+		}
+		return m
+	case errors.Is(err, imageproxy.ErrNotImage):
+		m.Error = err.Error()
+		m.issue(severity, "%s does not return an image: %s", field, imageURL)
+		return m
+	case err != nil && ctx.Err() != nil:
+		m.Error = err.Error() // the run was cancelled, not the image
+		return m
+	case err != nil:
+		m.Error = err.Error()
+		m.issue(severity, "%s could not be downloaded (%v): Google can't crawl it either", field, err)
+		return m
+	}
+
+	m.Bytes = len(img.Data)
+	m.hash, m.hashed = imageproxy.PerceptualHash(img.Data)
+	m.Format = gmcImageFormats[img.ContentType]
+	if m.Format == "" {
+		m.Format = strings.TrimPrefix(img.ContentType, "image/")
+		m.issue(severity, "%s is in %s format; GMC accepts JPEG, PNG, GIF, WebP, BMP and TIFF", field, m.Format)
+	}
+	if m.Bytes > gmcMaxImageBytes {
+		m.issue(severity, "%s is %.1f MB, over the 16 MB GMC maximum", field, float64(m.Bytes)/(1<<20))
+	}
+	if c, ok := imageproxy.AnalyzeComposition(img.Data); ok {
+		m.Background, m.BackgroundUniformity, m.ProductFill = c.Background, c.Uniformity, c.ProductFill
+		m.compositionIssues(field)
+	}
+	if img.Width == 0 || img.Height == 0 {
+		return m
+	}
+	m.Width, m.Height = img.Width, img.Height
+	m.AspectRatio = float64(img.Width*100/img.Height) / 100
+	switch side := min(img.Width, img.Height); {
+	case side < minSide:
+		m.issue(severity, "%s is %dx%d pixels, under the %dx%d GMC minimum", field, img.Width, img.Height, minSide, minSide)
+	case side < gmcRecommendedImageSide:
+		m.issue("low", "%s is %dx%d pixels; GMC recommends at least %dx%d", field, img.Width, img.Height, gmcRecommendedImageSide, gmcRecommendedImageSide)
+	}
+	if m.AspectRatio > 2 || m.AspectRatio < 0.5 {
+		m.issue("low", "%s has a %.2f:1 aspect ratio: it will be cropped or letterboxed in ads", field, m.AspectRatio)
+	}
+	return m
+}
+
+// compositionIssues reports a main image off a white or transparent background and
+// products spanning less or more of the image than GMC recommends
+func (m *imageMeasurement) compositionIssues(field string) {
+	if field == "image_link" && (m.Background == imageproxy.BackgroundColored || m.Background == imageproxy.BackgroundLifestyle) {
+		m.issue("low", "image_link has a %s background; GMC recommends a white or transparent background for the main image", m.Background)
+	}
+	switch {
+	case m.ProductFill == 0:
+		// lifestyle background, or nothing but background
+	case m.ProductFill < gmcMinProductFill:
+		m.issue("low", "the product spans %d%% of %s; GMC recommends %d to %d%%", m.ProductFill, field, gmcMinProductFill, gmcMaxProductFill)
+	case m.ProductFill > gmcMaxProductFill:
+		m.issue("low", "the product spans %d%% of %s: it is cropped too tight (GMC recommends %d to %d%%)", m.ProductFill, field, gmcMinProductFill, gmcMaxProductFill)
+	}
+}
+
+func (m *imageMeasurement) issue(severity, format string, args ...any) {
+	m.Issues = append(m.Issues, imageIssue{Severity: severity, Description: fmt.Sprintf(format, args...)})
+}
+
+// additionalImageURLs reads additional_image_link: a list, or URLs separated by commas
+func additionalImageURLs(fields map[string]interface{}) []string {
+	var values []string
+	for _, key := range []string{"additional_image_link", "additional_image_links"} {
+		switch v := fields[key].(type) {
+		case string:
+			values = append(values, strings.Split(v, ",")...)
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					values = append(values, s)
+				}
+			}
+		}
+	}
+	var urls []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") {
+			urls = append(urls, v)
+		}
+	}
+	return urls
+}
+
+// imageMeasurementContext gives the optimization call the measured images, so the model
+// reads dimensions, size and format instead of estimating them
+func (a *Agent) imageMeasurementContext(ctx context.Context, product *models.Product) string {
+	measured := a.measureImages(ctx, product)
+	if len(measured) == 0 {
+		return ""
+	}
+	content, _ := json.Marshal(measured)
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog(fmt.Sprintf("📐 Images measured: %s", content))
+	}
+	return "\n\n=== IMAGE MEASUREMENTS (downloaded, exact; their issues are already reported) ===\n" + string(content)
+}
+
+// imageIssues queues for review the serious problems found by measuring the images. They
+// are checked again when the model's answer comes from the response cache.
+func (a *Agent) imageIssues(ctx context.Context, product *models.Product) {
+	for _, m := range a.measureImages(ctx, product) {
+		for _, issue := range m.Issues {
+			escalateIssue(ctx, m.Field, issue.Severity, issue.Description)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// readsImageText reports whether a group's image analysis asks for the text of the image
+// (the quality section of the vision answer)
+func readsImageText(group OptimizationGroup) bool {
+	return group == GroupAll || group == GroupImageAnalysis
+}
+
+// imageTextIssues checks the text the vision analysis read in the product's main image
+// against GMC's image policy and queues promotional overlays and watermarks for review.
+// Only an analysis still in the vision cache is read: this never calls the model, so an
+// answer served from the response cache is checked while its image analysis is cached.
+func (a *Agent) imageTextIssues(ctx context.Context, product *models.Product) {
+	imageURL := extractImageURL(product.RawData)
+	if imageURL == "" || llm.Simulated(ctx) {
+		return
+	}
+	analysis := a.vision.get(a.visionModelFor() + "|" + imageURL)
+	if analysis == nil {
+		return
+	}
+	var quality struct {
+		Text []tools.ImageText `json:"text"`
+	}
+	if json.Unmarshal(analysis.sections[visionQuality], &quality) != nil || len(quality.Text) == 0 {
+		return
+	}
+	if a.callbacks.OnLog != nil {
+		content, _ := json.Marshal(quality.Text)
+		a.callbacks.OnLog(fmt.Sprintf("🔤 Text in image: %s", content))
+	}
+	for _, v := range tools.CheckImageText(quality.Text) {
+		escalateIssue(ctx, "image_link", v.Severity, v.Reason)
+	}
+}
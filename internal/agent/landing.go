@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// checksLandingPage reports whether a group compares the feed with the product's landing
+// page (when AGENT_CHECK_LANDING_PAGES is on)
+func checksLandingPage(group OptimizationGroup) bool {
+	return group == GroupAll || group == GroupCriticalErrors || group == GroupPricingPromotions
+}
+
+// landingPageIssues queues for review the price and availability mismatches between the
+// feed and the structured data of the product's landing page. Nothing is proposed: the
+// feed or the page must be fixed at the source.
+func (a *Agent) landingPageIssues(ctx context.Context, product *models.Product) {
+	// Estimates don't fetch pages
+	if !a.config.Agent.CheckLandingPages || llm.Simulated(ctx) {
+		return
+	}
+	check := tools.CheckLandingPage(ctx, a.config, product.RawData)
+	if check.Error != "" {
+		if a.callbacks.OnLog != nil {
+			a.callbacks.OnLog(fmt.Sprintf("⚠️ Landing page not checked: %s", check.Error))
+		}
+		return
+	}
+	for _, m := range check.Mismatches {
+		escalateIssue(ctx, m.Field, "high", m.Description)
+	}
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog(fmt.Sprintf("🔗 Landing page: %d structured facts, %d mismatches", len(check.Facts), len(check.Mismatches)))
+	}
+}
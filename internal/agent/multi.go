@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	MinMultiProducts = 2  // below this a multi-product call is a plain fast mode run
+	MaxMultiProducts = 10 // more products per prompt and answers start dropping some
+)
+
+// multiProductPrompt extends the fast mode prompt for several products in one call
+const multiProductPrompt = `
+
+=== SEVERAL PRODUCTS ===
+The request contains several similar products (same brand or category), each introduced by its reference ("=== Product P1 ===").
+Optimize each product on its own, following all the rules above:
+- Only that product's data and image analysis are facts about it: NEVER copy a color, size, GTIN or any other value from one product to another
+- Shared context (brand conventions, category hierarchy, title template) should be applied consistently across them
+
+=== OUTPUT FORMAT FOR SEVERAL PRODUCTS (JSON) ===
+Return one entry per product, with its reference, instead of the single-product format:
+{
+  "products": [
+    {
+      "ref": "P1",
+      "score": 0.65,
+      "missing_attributes": ["color"],
+      "proposals": [ same proposal format as above ]
+    }
+  ]
+}`
+
+// RunMulti runs the fast mode on several similar products (same dataset, brand or
+// category) in a single optimization call: the system prompt and the context they share
+// are paid once instead of per product. It returns one session per product, in order.
+// Products with a cached answer are served from the cache; the usage of the call is split
+// between the others by the size of their part of the prompt and of the answer, and each
+// answer is cached as if the product had been run alone. A product the answer leaves out
+// gets a failed session, to be run on its own. The error is for the call as a whole.
+func (a *Agent) RunMulti(ctx context.Context, products []*models.Product, goal string) ([]*Session, error) {
+	if len(products) > MaxMultiProducts {
+		return nil, fmt.Errorf("multi-product run takes at most %d products, got %d", MaxMultiProducts, len(products))
+	}
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	sessions := make([]*Session, len(products))
+	for i, product := range products {
+		sessions[i] = a.newSession(ctx, product, goal)
+		sessions[i].Group = GroupAll
+	}
+	fail := func() {
+		for _, s := range sessions {
+			if s.Status == "running" {
+				s.Status = "failed"
+			}
+		}
+	}
+	ctx, err := a.WithBudget(ctx, products[0].DatasetID)
+	if err != nil {
+		fail()
+		return sessions, err
+	}
+
+	// Cached answers first, then the image and web context of the others, attributed to their own session
+	started := time.Now()
+	var pending []int
+	contexts := make([]string, len(products))
+	for i, product := range products {
+		sctx := withSession(ctx, sessions[i])
+		if resp := a.cachedResponse(sctx, a.responseKey(sctx, product, GroupAll)); resp != nil {
+			if proposals, err := a.fastModeProposals(sctx, product, resp); err == nil {
+				sessions[i].Model, sessions[i].Cached = resp.Model, true
+				a.completeMulti(sctx, sessions[i], proposals, started, 0,
+					fmt.Sprintf("Group %s: product data unchanged, reused the cached answer (%d proposals)", GroupAll, len(proposals)))
+				continue
+			}
+		}
+		contexts[i] = a.fastModeContext(sctx, product)
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return sessions, nil
+	}
+
+	calls := &llm.CallLog{}
+	if a.config.LLM.CallLog {
+		ctx = llm.WithCallLog(ctx, calls)
+	}
+	resp, err := a.client.Chat(ctx, a.multiRequest(ctx, products[0].DatasetID, pending, contexts))
+	// The shared call is logged on the session of the first product it served
+	sessions[pending[0]].keepCalls(calls)
+	if err != nil {
+		fail()
+		return sessions, fmt.Errorf("multi-product optimization call failed: %w", err)
+	}
+
+	var output struct {
+		Products []json.RawMessage `json:"products"`
+	}
+	if err := json.Unmarshal([]byte(resp.Content), &output); err != nil {
+		// Nothing can be attributed: bill the call to the first product
+		a.recordUsage(withSession(ctx, sessions[pending[0]]), resp.Model, resp.Usage)
+		fail()
+		return sessions, fmt.Errorf("parse multi-product response: %w", err)
+	}
+	answers := map[string]json.RawMessage{}
+	for _, raw := range output.Products {
+		var entry struct {
+			Ref string `json:"ref"`
+		}
+		if json.Unmarshal(raw, &entry) == nil {
+			answers[strings.ToUpper(strings.TrimSpace(entry.Ref))] = raw
+		}
+	}
+
+	// Split the usage: prompt tokens by each product's share of the prompt, completion
+	// tokens by its share of the answer. Remainders go to the last product.
+	promptTotal, answerTotal := 0, 0
+	for n, i := range pending {
+		promptTotal += len(contexts[i])
+		answerTotal += len(answers[multiRef(n)])
+	}
+	promptLeft, completionLeft := resp.Usage.PromptTokens, resp.Usage.CompletionTokens
+	for n, i := range pending {
+		answer := answers[multiRef(n)]
+		usage := llm.Usage{PromptTokens: promptLeft, CompletionTokens: completionLeft}
+		if n < len(pending)-1 {
+			usage.PromptTokens = share(resp.Usage.PromptTokens, len(contexts[i]), promptTotal)
+			usage.CompletionTokens = share(resp.Usage.CompletionTokens, len(answer), answerTotal)
+		}
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		promptLeft -= usage.PromptTokens
+		completionLeft -= usage.CompletionTokens
+
+		session := sessions[i]
+		session.Model = resp.Model
+		sctx := withSession(ctx, session)
+		cost := a.costUSD(sctx, resp.Model, usage.PromptTokens, usage.CompletionTokens)
+		a.recordUsageCost(sctx, resp.Model, usage, cost)
+		if answer == nil {
+			session.Status = "failed"
+			continue
+		}
+
+		part := &llm.Response{Model: resp.Model, Content: string(answer), Usage: usage, FinishReason: resp.FinishReason}
+		proposals, err := a.fastModeProposals(sctx, products[i], part)
+		if err != nil {
+			session.Status = "failed"
+			continue
+		}
+		a.storeResponse(sctx, a.responseKey(sctx, products[i], GroupAll), GroupAll, part, cost)
+		a.completeMulti(sctx, session, proposals, started, usage.TotalTokens,
+			fmt.Sprintf("Group %s (multi-product): analyzed %d similar products in one call, generated %d proposals for this one", GroupAll, len(pending), len(proposals)))
+	}
+	return sessions, nil
+}
+
+// multiRequest builds the optimization call of the pending products from their fast mode
+// context, each under its reference. The products are of one dataset.
+func (a *Agent) multiRequest(ctx context.Context, datasetID uuid.UUID, pending []int, contexts []string) llm.Request {
+	var prompt strings.Builder
+	for n, i := range pending {
+		fmt.Fprintf(&prompt, "=== Product %s ===\n%s\n\n", multiRef(n), contexts[i])
+	}
+	prompt.WriteString("Generate optimization proposals for each product.")
+
+	return llm.Request{
+		Model: a.modelFor(GroupAll),
+		Stage: llm.StageFastMode,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: a.withFeedback(ctx, datasetID, a.withInstructions(fastModeSystemPrompt+multiProductPrompt))},
+			{Role: llm.RoleUser, Content: prompt.String()},
+		},
+		JSON: true,
+	}
+}
+
+// completeMulti scores the proposals of one product of a multi-product run and closes its session
+func (a *Agent) completeMulti(ctx context.Context, session *Session, proposals []models.Proposal, started time.Time, tokens int, thought string) {
+	for i := range proposals {
+		proposals[i].Module = string(GroupAll)
+	}
+	a.ScoreProposals(ctx, session.Product, proposals)
+	session.Proposals = append(session.Proposals, proposals...)
+	session.Status = "completed"
+	session.Traces = append(session.Traces, models.AgentTrace{
+		ID:         uuid.New(),
+		SessionID:  session.ID,
+		StepNumber: 1,
+		Thought:    thought,
+		ToolName:   string(GroupAll),
+		DurationMs: int(time.Since(started).Milliseconds()),
+		TokensUsed: tokens,
+		Model:      session.Model,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// SortSimilar orders products by brand then category, stably, so that consecutive
+// products of a multi-product run share as much context as possible
+func SortSimilar(products []models.Product) {
+	slices.SortStableFunc(products, func(x, y models.Product) int {
+		return strings.Compare(similarityKey(&x), similarityKey(&y))
+	})
+}
+
+// similarityKey groups products of the same brand and category
+func similarityKey(p *models.Product) string {
+	category := productField(p, "product_type")
+	if category == "" {
+		category = productField(p, "google_product_category")
+	}
+	return strings.ToLower(productField(p, "brand") + "\x00" + category)
+}
+
+// multiRef is the reference of the n-th product of a multi-product prompt
+func multiRef(n int) string {
+	return fmt.Sprintf("P%d", n+1)
+}
+
+// share returns part/whole of total, rounded down
+func share(total, part, whole int) int {
+	if whole == 0 {
+		return 0
+	}
+	return total * part / whole
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/agents"
+)
+
+// sampleWriter is the self-consistency check of a high-risk field: besides first, the
+// writer writes the field samples-1 more times, concurrently. It returns the version most
+// samples agree on when they are at least AGENT_SELF_CONSISTENCY_AGREEMENT of all samples
+// (failed samples count as disagreeing), else nil and the disagreement to show a reviewer.
+func (p *Pipeline) sampleWriter(ctx context.Context, input agents.WriterInput, first *agents.WriterOutput, samples int) (*agents.WriterOutput, string) {
+	outputs := make([]*agents.WriterOutput, samples)
+	outputs[0] = first
+
+	var wg sync.WaitGroup
+	for i := 1; i < samples; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outputs[i], _ = p.writer.Execute(ctx, input)
+		}()
+	}
+	wg.Wait()
+
+	counts := map[string]int{}
+	var versions []string // distinct versions in sample order
+	for _, out := range outputs {
+		if out == nil {
+			continue
+		}
+		key := normalizeSample(out.After)
+		if counts[key] == 0 {
+			versions = append(versions, key)
+		}
+		counts[key]++
+	}
+
+	var best string
+	for _, v := range versions {
+		if counts[v] > counts[best] {
+			best = v
+		}
+	}
+	if counts[best] > 0 && float64(counts[best])/float64(samples) >= p.config.Agent.SelfConsistencyAgreement {
+		for _, out := range outputs {
+			if out != nil && normalizeSample(out.After) == best {
+				return out, ""
+			}
+		}
+	}
+
+	written := make([]string, 0, len(versions))
+	for _, v := range versions {
+		written = append(written, fmt.Sprintf("%q ×%d", v, counts[v]))
+	}
+	return nil, fmt.Sprintf("Writer samples disagree: %d of %d agree (%s)", counts[best], samples, strings.Join(written, ", "))
+}
+
+// normalizeSample makes versions that differ only in case, spacing or a final period agree
+func normalizeSample(value string) string {
+	value = strings.Join(strings.Fields(strings.ToLower(value)), " ")
+	return strings.TrimRight(value, ".")
+}
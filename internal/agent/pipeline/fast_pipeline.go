@@ -9,9 +9,12 @@ import (
 
 	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/productdata"
 	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/retry"
 	"github.com/google/uuid"
-	openai "github.com/sashabaranov/go-openai"
 )
 
 // FastPipeline is an optimized version that minimizes API calls
@@ -19,11 +22,15 @@ import (
 // the separation of concerns through structured prompting
 type FastPipeline struct {
 	config    *config.Config
-	client    *openai.Client
+	client    llm.Client
 	validator *tools.HardRuleValidator
 	differ    *tools.DiffEngine
 	risk      *tools.RiskClassifier
+	images    *imageproxy.Proxy
 	callbacks PipelineCallbacks
+	feedback  string // review decisions on the dataset, told to the optimization call
+	imageProblem string // the product's image failed its last check: no image analysis
+	imageCodes []tools.ImageCode // GTIN and MPN read in the product images
 }
 
 // FastProposal is the output format we expect from the LLM
@@ -47,14 +54,17 @@ type FastPipelineOutput struct {
 	Proposals []FastProposal `json:"proposals"`
 }
 
+// fastPipelineSchema constrains the single optimization answer to FastPipelineOutput
+var fastPipelineSchema = llm.SchemaFor("fast_pipeline", FastPipelineOutput{})
+
 func NewFastPipeline(cfg *config.Config) *FastPipeline {
-	clientConfig := openai.DefaultConfig(cfg.OpenAI.APIKey)
 	return &FastPipeline{
 		config:    cfg,
-		client:    openai.NewClientWithConfig(clientConfig),
+		client:    llm.New(cfg),
 		validator: tools.NewHardRuleValidator(),
 		differ:    tools.NewDiffEngine(),
 		risk:      tools.NewRiskClassifier(),
+		images:    imageproxy.New(cfg),
 	}
 }
 
@@ -62,6 +72,23 @@ func (p *FastPipeline) SetCallbacks(cb PipelineCallbacks) {
 	p.callbacks = cb
 }
 
+// SetFeedback sets the reviewer feedback section of the dataset (see agent.SetFeedbackSource)
+func (p *FastPipeline) SetFeedback(feedback string) {
+	p.feedback = feedback
+}
+
+// SetImageCodes gives the optimization call the identifiers read in the product images
+// (see agent.imageCodes)
+func (p *FastPipeline) SetImageCodes(codes []tools.ImageCode) {
+	p.imageCodes = codes
+}
+
+// SetImageProblem skips the image analysis: the product's image failed its last check
+// (see agent.SetImageCheckSource)
+func (p *FastPipeline) SetImageProblem(problem string) {
+	p.imageProblem = problem
+}
+
 // Run executes an optimized pipeline with minimal API calls
 func (p *FastPipeline) Run(ctx context.Context, product *models.Product) (*PipelineResult, error) {
 	result := &PipelineResult{
@@ -72,12 +99,14 @@ func (p *FastPipeline) Run(ctx context.Context, product *models.Product) (*Pipel
 		Rejections:    []*Rejection{},
 		HumanRequired: []*HumanReviewRequest{},
 	}
+	ctx, retries := retry.WithCounter(ctx)
 
 	// Stage 1: Hard Rule Validation (deterministic, instant)
 	if p.callbacks.OnStageStart != nil {
 		p.callbacks.OnStageStart("validate")
 	}
 	validationResult := p.validator.Validate(product.RawData)
+	result.Vertical = validationResult.Vertical
 	result.Stages = append(result.Stages, StageResult{
 		Stage:      "validate",
 		StartedAt:  time.Now(),
@@ -95,7 +124,7 @@ func (p *FastPipeline) Run(ctx context.Context, product *models.Product) (*Pipel
 	var imageErr error
 	
 	imageURL := extractImageURL(product.RawData)
-	if imageURL != "" {
+	if imageURL != "" && p.config.Agent.EnableVision && p.imageProblem == "" {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -122,9 +151,13 @@ func (p *FastPipeline) Run(ctx context.Context, product *models.Product) (*Pipel
 	if imageContext != "" && imageErr == nil {
 		contextInfo = fmt.Sprintf("\n\nImage Analysis Results:\n%s", imageContext)
 	}
+	if len(p.imageCodes) > 0 {
+		codes, _ := json.Marshal(p.imageCodes)
+		contextInfo += fmt.Sprintf("\n\nIdentifiers read in product images (barcodes are decoded, exact):\n%s", codes)
+	}
 
 	// Single combined call
-	output, err := p.runCombinedOptimization(ctx, product.RawData, contextInfo)
+	output, err := p.runCombinedOptimization(ctx, product.RawData, tools.ProfileFor(validationResult.Vertical), contextInfo)
 	if err != nil {
 		result.CompletedAt = time.Now()
 		if p.callbacks.OnError != nil {
@@ -138,6 +171,7 @@ func (p *FastPipeline) Run(ctx context.Context, product *models.Product) (*Pipel
 		StartedAt:  time.Now(),
 		EndedAt:    time.Now(),
 		DurationMs: 100,
+		Retries:    retries.Value(), // image analysis runs concurrently, counted here
 	})
 
 	if p.callbacks.OnStageEnd != nil {
@@ -225,7 +259,9 @@ func (p *FastPipeline) Run(ctx context.Context, product *models.Product) (*Pipel
 	return result, nil
 }
 
-func (p *FastPipeline) runCombinedOptimization(ctx context.Context, productData json.RawMessage, additionalContext string) (*FastPipelineOutput, error) {
+// runCombinedOptimization makes the single optimization call, with the title template and
+// attribute rules of the product's vertical
+func (p *FastPipeline) runCombinedOptimization(ctx context.Context, productData json.RawMessage, vertical tools.VerticalProfile, additionalContext string) (*FastPipelineOutput, error) {
 	systemPrompt := `You are a product data optimization expert for Google Merchant Center (GMC).
 
 === GMC ATTRIBUTES REFERENCE (2025) ===
@@ -240,12 +276,7 @@ REQUIRED ATTRIBUTES:
 - availability: in_stock, out_of_stock, preorder, backorder
 - brand: Manufacturer/brand name
 
-REQUIRED FOR APPAREL (US, UK, DE, JP, FR, BR):
-- color: Product color - use standard names (black, blue, red), no hex codes
-- gender: male, female, unisex
-- age_group: newborn, infant, toddler, kids, adult
-- size: S, M, L, XL or numeric (8, 9.5, etc.)
-
+` + vertical.Prompt() + `
 STRONGLY RECOMMENDED:
 - gtin: EAN (13 digits) / UPC (12 digits) / ISBN
 - mpn: Manufacturer Part Number
@@ -257,15 +288,15 @@ STRONGLY RECOMMENDED:
 INFERABLE ATTRIBUTES (propose when missing):
 - material: cotton, polyester, leather, wool, silk, denim, etc.
 - pattern: solid, striped, floral, checkered, printed, etc.
-- size_type: regular, petite, plus, tall, maternity
-- size_system: US, UK, EU, FR, IT
+- size_type: regular, petite, plus, tall, maternity (apparel)
+- size_system: US, UK, EU, FR, IT (apparel)
 - product_weight: For shipping
 - product_height, product_width, product_length: Dimensions
 
 === OPTIMIZATION TASKS ===
 
 1. TITLE (ALWAYS optimize if improvable):
-   - Template: Brand + Gender + Product Type + Color + Size + Material
+   - Template: ` + vertical.TitleTemplate + `
    - Min 30 chars, optimal 60-150 chars
    - Front-load keywords (first 70 chars visible in search)
    - FORBIDDEN: promotional text (free shipping, sale, -50%, soldes)
@@ -275,15 +306,13 @@ INFERABLE ATTRIBUTES (propose when missing):
    - Min 50 chars, optimal 100-500 chars
    - Be specific and informative
 
-3. MISSING ATTRIBUTES (propose ALL that are inferable):
-   - color: From image or title ("Blue T-Shirt" → color: "blue")
-   - gender: From product type or image
-   - age_group: Default "adult" unless clearly kids product
+3. MISSING ATTRIBUTES (propose ALL key attributes of the vertical that are inferable):
+   - color: From image or title ("Blue T-Shirt" → color: "blue"), standard names, no hex codes
    - material: From image texture or product type
    - pattern: From image (solid, striped, etc.)
    - size: Extract from title if present
    - product_type: Build from category/title
-   - google_product_category: Map to Google taxonomy
+   - google_product_category: mapped in code from the Google taxonomy, do NOT propose it
 
 === OUTPUT FORMAT (JSON) ===
 {
@@ -328,31 +357,31 @@ INFERABLE ATTRIBUTES (propose when missing):
 - NO INVENTION: Only use facts from feed data or image analysis
 - Be GENEROUS: Propose all improvements, let humans reject if needed
 - Generate AT LEAST 3-5 proposals for products with missing attributes
-- For APPAREL: ALWAYS propose color, gender, age_group, size if missing`
+- Follow the PRODUCT VERTICAL rules: do not propose attributes that don't apply to it`
+	if p.feedback != "" {
+		systemPrompt += "\n\n" + p.feedback
+	}
 
 	userPrompt := fmt.Sprintf(`Product Data:
 %s
 %s
 
-Analyze this product and generate optimization proposals. Be thorough - propose improvements for every field that could be better.`, string(productData), additionalContext)
+Analyze this product and generate optimization proposals. Be thorough - propose improvements for every field that could be better.`, productdata.ForPrompt(p.config, productData), additionalContext)
 
-	resp, err := p.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: openai.GPT4oMini,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-			{Role: openai.ChatMessageRoleUser, Content: userPrompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	var output FastPipelineOutput
+	resp, err := p.client.Structured(ctx, llm.Request{
+		Model: p.config.GroupModel("all"),
+		Stage: llm.StageFastPipeline,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: systemPrompt},
+			{Role: llm.RoleUser, Content: userPrompt},
 		},
-		Temperature: 0.3,
-	})
-	if err != nil {
+		Schema: fastPipelineSchema,
+	}, &output)
+	if resp == nil {
 		return nil, err
 	}
-
-	var output FastPipelineOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &output); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
 	}
 
@@ -360,15 +389,17 @@ Analyze this product and generate optimization proposals. Be thorough - propose
 }
 
 func (p *FastPipeline) analyzeImageFast(ctx context.Context, imageURL string) (string, error) {
-	resp, err := p.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: openai.GPT4oMini,
-		Messages: []openai.ChatCompletionMessage{
+	image := llm.Image{URL: imageURL}
+	if !llm.Simulated(ctx) {
+		image.URL, _, _ = p.images.ForModel(ctx, imageURL, p.config.Agent.VisionMaxSize)
+	}
+	resp, err := p.client.ChatWithVision(ctx, llm.Request{
+		Model: p.config.Agent.VisionModel,
+		Stage: llm.StageFastPipelineVision,
+		Messages: []llm.Message{
 			{
-				Role: openai.ChatMessageRoleUser,
-				MultiContent: []openai.ChatMessagePart{
-					{
-						Type: openai.ChatMessagePartTypeText,
-						Text: `Extract ALL factual GMC attributes from this product image. Output JSON:
+				Role:    llm.RoleUser,
+				Content: `Extract ALL factual GMC attributes from this product image. Output JSON:
 {
   "color": "primary color (e.g., black, blue, red, white, beige)",
   "secondary_colors": ["additional colors if multicolor"],
@@ -382,27 +413,15 @@ func (p *FastPipeline) analyzeImageFast(ctx context.Context, imageURL string) (s
 }
 
 ONLY state what you can clearly see. Do NOT invent or guess.`,
-					},
-					{
-						Type: openai.ChatMessagePartTypeImageURL,
-						ImageURL: &openai.ChatMessageImageURL{
-							URL: imageURL,
-						},
-					},
-				},
 			},
 		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-		},
-		MaxTokens:   300,
-		Temperature: 0.1,
-	})
+		JSON: true,
+	}, image)
 	if err != nil {
 		return "", err
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return resp.Content, nil
 }
 
 func (p *FastPipeline) validateProposalDeterministic(prop FastProposal) bool {
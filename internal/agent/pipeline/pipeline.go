@@ -9,6 +9,7 @@ import (
 	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/config"
 	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/retry"
 	"github.com/google/uuid"
 )
 
@@ -33,6 +34,21 @@ type Pipeline struct {
 
 	// Callbacks for real-time updates
 	callbacks PipelineCallbacks
+
+	// Review decisions on the dataset, told to the planner
+	feedback string
+
+	// Sites trusted for the dataset, preferred by retrieval
+	trusted *tools.TrustedDomains
+
+	// Problem the last check found with the product's image; the image stage is skipped
+	imageProblem string
+
+	// GTIN and MPN read in the product images, registered as image evidence
+	imageCodes []tools.ImageCode
+
+	// Confidence at which image evidence is verified (see SetAutoVerifyConfidence)
+	autoVerify float64
 }
 
 type PipelineCallbacks struct {
@@ -48,6 +64,7 @@ type PipelineCallbacks struct {
 // PipelineResult contains the complete output with full audit trail
 type PipelineResult struct {
 	ProductID     uuid.UUID              `json:"product_id"`
+	Vertical      tools.Vertical         `json:"vertical"` // routes prompts, rules and title template
 	StartedAt     time.Time              `json:"started_at"`
 	CompletedAt   time.Time              `json:"completed_at"`
 	Stages        []StageResult          `json:"stages"`
@@ -55,6 +72,7 @@ type PipelineResult struct {
 	Rejections    []*Rejection           `json:"rejections"`
 	HumanRequired []*HumanReviewRequest  `json:"human_required"`
 	EvidenceTrail json.RawMessage        `json:"evidence_trail"`
+	RetrievedFacts []agents.SourcedFact  `json:"retrieved_facts,omitempty"` // found by the retrieval stage
 	Summary       *PipelineSummary       `json:"summary"`
 }
 
@@ -63,6 +81,7 @@ type StageResult struct {
 	StartedAt  time.Time       `json:"started_at"`
 	EndedAt    time.Time       `json:"ended_at"`
 	DurationMs int64           `json:"duration_ms"`
+	Retries    int             `json:"retries,omitempty"` // outbound calls retried during the stage
 	Output     json.RawMessage `json:"output"`
 	Error      string          `json:"error,omitempty"`
 }
@@ -77,6 +96,8 @@ type Proposal struct {
 	Risk        *tools.RiskAssessment `json:"risk"`
 	Verified    bool                 `json:"verified"`
 	Confidence  float64              `json:"confidence"`
+	// Retrieved facts on the proposal's field and the facts the writer used
+	RetrievedFacts []agents.SourcedFact `json:"retrieved_facts,omitempty"`
 }
 
 type Rejection struct {
@@ -93,6 +114,29 @@ type HumanReviewRequest struct {
 	Context   string `json:"context"`
 }
 
+// HumanReviews converts the fields the pipeline could not handle alone into review queue items
+func (r *PipelineResult) HumanReviews(product *models.Product) []models.HumanReview {
+	reviews := make([]models.HumanReview, 0, len(r.HumanRequired))
+	for _, hr := range r.HumanRequired {
+		review := models.HumanReview{
+			ID:        uuid.New(),
+			DatasetID: product.DatasetID,
+			ProductID: product.ID,
+			Source:    "pipeline",
+			Field:     hr.Field,
+			Question:  hr.Reason,
+			RiskLevel: hr.RiskLevel,
+			Status:    "pending",
+			CreatedAt: time.Now(),
+		}
+		if hr.Context != "" {
+			review.Context, _ = json.Marshal(map[string]string{"context": hr.Context})
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews
+}
+
 type PipelineSummary struct {
 	TotalStages      int     `json:"total_stages"`
 	ProposalsCreated int     `json:"proposals_created"`
@@ -118,6 +162,7 @@ func NewPipeline(cfg *config.Config) *Pipeline {
 		differ:     tools.NewDiffEngine(),
 		registry:   tools.NewEvidenceRegistry(),
 		risk:       tools.NewRiskClassifier(),
+		autoVerify: cfg.Agent.AutoVerifyConfidence,
 	}
 }
 
@@ -126,6 +171,34 @@ func (p *Pipeline) SetCallbacks(cb PipelineCallbacks) {
 	p.callbacks = cb
 }
 
+// SetFeedback sets the reviewer feedback section of the dataset (see agent.SetFeedbackSource)
+func (p *Pipeline) SetFeedback(feedback string) {
+	p.feedback = feedback
+}
+
+// SetTrustedDomains sets the sites trusted for the dataset (see agent.SetTrustSource):
+// retrieval searches them first, and changes backed by their facts carry less risk
+func (p *Pipeline) SetTrustedDomains(trusted *tools.TrustedDomains) {
+	p.trusted = trusted
+}
+
+// SetImageProblem skips the image stage: the product's image failed its last check
+// (see agent.SetImageCheckSource)
+func (p *Pipeline) SetImageProblem(problem string) {
+	p.imageProblem = problem
+}
+
+// SetImageCodes sets the identifiers read in the product images (see agent.imageCodes)
+func (p *Pipeline) SetImageCodes(codes []tools.ImageCode) {
+	p.imageCodes = codes
+}
+
+// SetAutoVerifyConfidence sets the confidence at which image evidence is verified, the
+// auto-verify threshold of the dataset (AGENT_AUTO_VERIFY_CONFIDENCE by default)
+func (p *Pipeline) SetAutoVerifyConfidence(threshold float64) {
+	p.autoVerify = threshold
+}
+
 // Run executes the full pipeline on a product
 func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineResult, error) {
 	result := &PipelineResult{
@@ -136,14 +209,18 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 		Rejections:    []*Rejection{},
 		HumanRequired: []*HumanReviewRequest{},
 	}
+	ctx, _ = retry.WithCounter(ctx)
 
 	// Initialize evidence registry with feed data
 	p.registry = tools.NewEvidenceRegistry()
+	p.registry.SetAutoVerifyConfidence(p.autoVerify)
 	if err := p.registry.LoadFromFeedData(product.ID, product.RawData); err != nil {
 		return nil, err
 	}
 
 	// Stage 1: Hard Rule Validation (deterministic)
+	result.Vertical = tools.DetectVertical(product.RawData)
+	vertical := tools.ProfileFor(result.Vertical)
 	stage1 := p.runStage(ctx, "validate", func() (interface{}, error) {
 		return p.validator.Validate(product.RawData), nil
 	})
@@ -154,7 +231,7 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 	stage2 := p.runStage(ctx, "audit", func() (interface{}, error) {
 		input := agents.AuditInput{
 			ProductData: product.RawData,
-			GMCRules:    getDefaultGMCRules(),
+			GMCRules:    getDefaultGMCRules(vertical),
 		}
 		var err error
 		auditResult, err = p.auditor.Audit(ctx, input)
@@ -170,11 +247,11 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 	// Stage 3: Image Evidence (if image available)
 	var imageEvidence *agents.ImageEvidenceOutput
 	imageURL := extractImageURL(product.RawData)
-	if imageURL != "" {
+	if imageURL != "" && p.imageProblem == "" {
 		stage3 := p.runStage(ctx, "image_evidence", func() (interface{}, error) {
 			input := agents.ImageEvidenceInput{
 				ImageURL:           imageURL,
-				AttributesToVerify: []string{"color", "material", "style"},
+				AttributesToVerify: vertical.ImageAttributes,
 			}
 			var err error
 			imageEvidence, err = p.evidence.ExtractEvidence(ctx, input)
@@ -188,9 +265,30 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 				valStr, _ := json.Marshal(obs.Value)
 				p.registry.RegisterFromImage(product.ID, obs.Attribute, string(valStr), imageURL, obs.Reasoning, obs.Confidence)
 			}
+			// Text read in the image, kept as evidence; promotional overlays and
+			// watermarks get the image disapproved and go to human review
+			for _, t := range imageEvidence.Text {
+				p.registry.RegisterFromImage(product.ID, "image_text", t.Text, imageURL, t.Kind+" text", t.Confidence)
+			}
+			for _, v := range imageEvidence.TextPolicy {
+				if v.Severity != "high" {
+					continue
+				}
+				result.HumanRequired = append(result.HumanRequired, &HumanReviewRequest{
+					Field:     "image_link",
+					Reason:    v.Reason,
+					RiskLevel: v.Severity,
+					Context:   imageURL,
+				})
+			}
 		}
 	}
 
+	// Barcodes decoded from the images are exact; they back the gtin field like feed data
+	for _, c := range p.imageCodes {
+		p.registry.RegisterFromImage(product.ID, c.Field, c.Value, c.ImageURL, c.Method+" read in the image", c.Confidence)
+	}
+
 	// Stage 4: Knowledge Retrieval (if needed)
 	var retrievedFacts *agents.RetrievalOutput
 	missingFields := getMissingFields(product.RawData, auditResult)
@@ -202,6 +300,7 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 				GTIN:         extractField(product.RawData, "gtin"),
 				ProductURL:   extractField(product.RawData, "link"),
 				FieldsNeeded: missingFields,
+				Trusted:      p.trusted,
 			}
 			var err error
 			retrievedFacts, err = p.retrieval.RetrieveFacts(ctx, input)
@@ -211,6 +310,7 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 
 		// Register retrieved facts
 		if retrievedFacts != nil {
+			result.RetrievedFacts = retrievedFacts.Facts
 			for _, fact := range retrievedFacts.Facts {
 				p.registry.RegisterFromWeb(product.ID, fact.Field, fact.Value, fact.URL, fact.Evidence, fact.Confidence)
 			}
@@ -223,6 +323,8 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 		input := agents.PlannerInput{
 			ProductData: product.RawData,
 			AuditResult: auditResult,
+			Vertical:    vertical,
+			Feedback:    p.feedback,
 		}
 		input.AvailableEvidence.ImageEvidence = imageEvidence
 		input.AvailableEvidence.RetrievedFacts = retrievedFacts
@@ -278,6 +380,12 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 			allowedFacts["current_"+action.Field] = currentValue
 		}
 
+		// Titles follow the template of the vertical
+		constraints := append([]string{}, action.Constraints...)
+		if action.Field == "title" {
+			constraints = append(constraints, "follow the title template: "+vertical.TitleTemplate)
+		}
+
 		// Execute writing
 		writerInput := agents.WriterInput{
 			Field:          action.Field,
@@ -285,7 +393,7 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 			Objective:      action.Objective,
 			AllowedFacts:   allowedFacts,
 			ForbiddenFacts: action.ForbiddenFacts,
-			Constraints:    action.Constraints,
+			Constraints:    constraints,
 		}
 
 		writerOutput, err := p.writer.Execute(ctx, writerInput)
@@ -298,6 +406,24 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 			continue
 		}
 
+		// High-risk fields are written several times and kept only when the samples agree
+		if n := p.config.Agent.SelfConsistencySamples; n > 1 && p.risk.IsHighRiskField(action.Field) {
+			agreed, disagreement := p.sampleWriter(ctx, writerInput, writerOutput, n)
+			if agreed == nil {
+				result.HumanRequired = append(result.HumanRequired, &HumanReviewRequest{
+					Field:     action.Field,
+					Reason:    "Writer samples disagree",
+					RiskLevel: "high",
+					Context:   disagreement,
+				})
+				if p.callbacks.OnHumanNeeded != nil {
+					p.callbacks.OnHumanNeeded(action.Field, disagreement)
+				}
+				continue
+			}
+			writerOutput = agreed
+		}
+
 		// Validate with controller
 		controlInput := agents.ControllerInput{
 			Field:            action.Field,
@@ -309,6 +435,21 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 			WriterConfidence: writerOutput.Confidence,
 		}
 
+		// Obvious violations are rejected without the LLM controller
+		if rejections := p.controller.PreCheck(controlInput); len(rejections) > 0 {
+			for _, rej := range rejections {
+				result.Rejections = append(result.Rejections, &Rejection{
+					Field:    action.Field,
+					Reason:   rej.Reason,
+					Evidence: rej.Evidence,
+					Stage:    "precheck",
+				})
+				if p.callbacks.OnRejection != nil {
+					p.callbacks.OnRejection(action.Field, rej.Reason)
+				}
+			}
+			continue
+		}
 		controlOutput, err := p.controller.Validate(ctx, controlInput)
 		if err != nil {
 			result.Rejections = append(result.Rejections, &Rejection{
@@ -337,7 +478,7 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 		}
 
 		// Assess risk
-		riskAssessment := p.risk.AssessChange(action.Field, currentValue, writerOutput.After, "mixed", writerOutput.Confidence)
+		riskAssessment := p.risk.AssessChange(action.Field, currentValue, writerOutput.After, factSourceType(retrievedFacts, action.Field), writerOutput.Confidence)
 
 		// Create proposal
 		proposal := &Proposal{
@@ -350,6 +491,7 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 			Risk:       riskAssessment,
 			Verified:   controlOutput.Verification.FactsVerified,
 			Confidence: controlOutput.Verification.OverallConfidence,
+			RetrievedFacts: factsBacking(retrievedFacts, action.Field, writerOutput.FactsUsed),
 		}
 		result.Proposals = append(result.Proposals, proposal)
 
@@ -397,6 +539,7 @@ func (p *Pipeline) Run(ctx context.Context, product *models.Product) (*PipelineR
 
 func (p *Pipeline) runStage(ctx context.Context, name string, fn func() (interface{}, error)) StageResult {
 	start := time.Now()
+	retriesBefore := retry.Count(ctx)
 
 	if p.callbacks.OnStageStart != nil {
 		p.callbacks.OnStageStart(name)
@@ -410,6 +553,7 @@ func (p *Pipeline) runStage(ctx context.Context, name string, fn func() (interfa
 		StartedAt:  start,
 		EndedAt:    end,
 		DurationMs: end.Sub(start).Milliseconds(),
+		Retries:    retry.Count(ctx) - retriesBefore,
 	}
 
 	if err != nil {
@@ -430,15 +574,21 @@ func (p *Pipeline) runStage(ctx context.Context, name string, fn func() (interfa
 
 // Helper functions
 
-func getDefaultGMCRules() []agents.GMCRule {
-	return []agents.GMCRule{
-		{Field: "title", Requirement: "30-150 characters, include brand and product type", Severity: "error"},
+// getDefaultGMCRules returns the rules the auditor judges against, with the title template
+// and key attributes of the product's vertical
+func getDefaultGMCRules(vertical tools.VerticalProfile) []agents.GMCRule {
+	rules := []agents.GMCRule{
+		{Field: "title", Requirement: "30-150 characters, template " + vertical.TitleTemplate, Severity: "error"},
 		{Field: "description", Requirement: "50+ characters, informative", Severity: "warning"},
 		{Field: "image_link", Requirement: "valid URL, no watermarks", Severity: "error"},
 		{Field: "price", Requirement: "required, valid format with currency", Severity: "error"},
 		{Field: "brand", Requirement: "recommended for most categories", Severity: "warning"},
 		{Field: "gtin", Requirement: "required if available, valid format", Severity: "warning"},
 	}
+	for _, attr := range vertical.Attributes {
+		rules = append(rules, agents.GMCRule{Field: attr, Requirement: "key attribute for " + vertical.Label, Severity: "warning"})
+	}
+	return rules
 }
 
 func extractImageURL(data json.RawMessage) string {
@@ -494,3 +644,35 @@ func getMissingFields(data json.RawMessage, audit *agents.AuditOutput) []string
 	}
 	return audit.Missing
 }
+
+// factSourceType is the source type of a change for the risk classifier: tools.SourceTrusted
+// when the field was retrieved from a trusted domain, mixed otherwise
+func factSourceType(retrieved *agents.RetrievalOutput, field string) string {
+	if retrieved != nil {
+		for _, f := range retrieved.Facts {
+			if f.Field == field && f.Trusted {
+				return tools.SourceTrusted
+			}
+		}
+	}
+	return "mixed"
+}
+
+// factsBacking returns the retrieved facts on a proposal's field or on the allowed facts
+// the writer used (FactUsage.Source is the allowed fact's key, a field name)
+func factsBacking(retrieved *agents.RetrievalOutput, field string, used []agents.FactUsage) []agents.SourcedFact {
+	if retrieved == nil {
+		return nil
+	}
+	fields := map[string]bool{field: true}
+	for _, u := range used {
+		fields[u.Source] = true
+	}
+	var facts []agents.SourcedFact
+	for _, f := range retrieved.Facts {
+		if fields[f.Field] {
+			facts = append(facts, f)
+		}
+	}
+	return facts
+}
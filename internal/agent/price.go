@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"context"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// priceFields are the fields whose proposals come from tools.CheckPrices, not the model
+var priceFields = map[string]bool{"price": true, "sale_price": true, "sale_price_effective_date": true}
+
+// checksPrices reports whether a group's proposals on price fields are replaced by the
+// deterministic price check
+func checksPrices(group OptimizationGroup) bool {
+	return group == GroupAll || group == GroupCriticalErrors || group == GroupPricingPromotions
+}
+
+// priceProposals turns the format fixes of tools.CheckPrices into proposals and queues the
+// price problems it finds (sale price not below price, invalid date range...) for review
+func (a *Agent) priceProposals(ctx context.Context, product *models.Product) []models.Proposal {
+	check := tools.CheckPrices(product.RawData)
+	for _, issue := range check.Issues {
+		escalateIssue(ctx, issue.Field, "high", issue.Description)
+	}
+
+	var proposals []models.Proposal
+	for _, f := range check.Fixes {
+		source := models.Source{Type: "feed", Reference: f.Field, Evidence: f.Before, Confidence: 1, Verified: true}
+		proposals = append(proposals, a.codeProposal(product, f.Field, f.Before, f.After, f.Reason, source, "low"))
+	}
+	return proposals
+}
+
+func isPriceField(field string) bool {
+	return priceFields[strings.ToLower(strings.TrimSpace(field))]
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// PriceSource provides model prices (the model_pricing table)
+type PriceSource interface {
+	ListModelPrices(ctx context.Context) ([]models.ModelPrice, error)
+}
+
+// pricingTTL is how long loaded prices are used before being read again
+const pricingTTL = 5 * time.Minute
+
+// pricing prices LLM calls from a PriceSource, cached for pricingTTL. Shared by the
+// agent and its variants.
+type pricing struct {
+	mu       sync.Mutex
+	source   PriceSource
+	byModel  map[string][]models.ModelPrice // newest first
+	loadedAt time.Time
+	warned   map[string]bool
+}
+
+func newPricing() *pricing {
+	return &pricing{warned: map[string]bool{}}
+}
+
+// SetPricing makes call costs use the prices of src instead of the built-in ones
+func (a *Agent) SetPricing(src PriceSource) {
+	a.pricing.mu.Lock()
+	defer a.pricing.mu.Unlock()
+	a.pricing.source = src
+	a.pricing.loadedAt = time.Time{}
+}
+
+// ReloadPricing makes the next priced call read prices again (after they were edited)
+func (a *Agent) ReloadPricing() {
+	a.pricing.mu.Lock()
+	defer a.pricing.mu.Unlock()
+	a.pricing.loadedAt = time.Time{}
+}
+
+// costUSD prices a call at the model's price in effect now. Models without a price
+// fall back to the built-in rates, with a warning once per model.
+func (a *Agent) costUSD(ctx context.Context, model string, promptTokens, completionTokens int) float64 {
+	price, ok := a.pricing.lookup(ctx, model, time.Now())
+	if !ok {
+		return builtinCostUSD(model, promptTokens, completionTokens)
+	}
+	return (float64(promptTokens)*price.InputPerMillion + float64(completionTokens)*price.OutputPerMillion) / 1e6
+}
+
+// lookup finds the price of a model at a time: the exact model, else the longest
+// priced prefix (gpt-4o-mini for gpt-4o-mini-2024-07-18)
+func (p *pricing) lookup(ctx context.Context, model string, at time.Time) (models.ModelPrice, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.source == nil {
+		return models.ModelPrice{}, false
+	}
+	if time.Since(p.loadedAt) > pricingTTL {
+		p.load(ctx)
+	}
+
+	prices, ok := p.byModel[model]
+	if !ok {
+		best := ""
+		for m := range p.byModel {
+			if strings.HasPrefix(model, m+"-") && len(m) > len(best) {
+				best = m
+			}
+		}
+		prices = p.byModel[best]
+	}
+	for _, price := range prices {
+		if !price.EffectiveFrom.After(at) {
+			return price, true
+		}
+	}
+	if !p.warned[model] {
+		p.warned[model] = true
+		fmt.Printf("Pricing: no price for model %q, costs use built-in rates; add one with POST /api/v1/model-prices\n", model)
+	}
+	return models.ModelPrice{}, false
+}
+
+// load reads the prices; on error the previous ones are kept until the next try
+func (p *pricing) load(ctx context.Context) {
+	p.loadedAt = time.Now()
+	list, err := p.source.ListModelPrices(ctx)
+	if err != nil {
+		fmt.Printf("Pricing: failed to load model prices, keeping previous ones: %v\n", err)
+		return
+	}
+	byModel := map[string][]models.ModelPrice{}
+	for _, price := range list {
+		byModel[price.Model] = append(byModel[price.Model], price)
+	}
+	p.byModel = byModel
+	p.warned = map[string]bool{}
+}
+
+// builtinCostUSD prices a call when no price table is available
+// GPT-4o-mini pricing (as of 2024): $0.15/1M input, $0.60/1M output
+// GPT-4o pricing: $2.50/1M input, $10.00/1M output
+// Claude Sonnet 4.5: $3/1M input, $15/1M output; Claude Haiku 4.5: $1/1M input, $5/1M output
+// Gemini 2.5 Pro: $1.25/1M input, $10/1M output; Gemini 2.5 Flash: $0.30/1M input, $2.50/1M output
+func builtinCostUSD(model string, promptTokens, completionTokens int) float64 {
+	switch model {
+	case "gpt-4o-mini", "gpt-4o-mini-2024-07-18":
+		return float64(promptTokens)*0.00000015 + float64(completionTokens)*0.0000006
+	case "gpt-4o", "gpt-4o-2024-05-13":
+		return float64(promptTokens)*0.0000025 + float64(completionTokens)*0.00001
+	case "claude-sonnet-4-5":
+		return float64(promptTokens)*0.000003 + float64(completionTokens)*0.000015
+	case "claude-haiku-4-5":
+		return float64(promptTokens)*0.000001 + float64(completionTokens)*0.000005
+	case "gemini-2.5-pro":
+		return float64(promptTokens)*0.00000125 + float64(completionTokens)*0.00001
+	case "gemini-2.5-flash":
+		return float64(promptTokens)*0.0000003 + float64(completionTokens)*0.0000025
+	default:
+		// Default to GPT-4o-mini pricing
+		return float64(promptTokens)*0.00000015 + float64(completionTokens)*0.0000006
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"context"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+type sessionKey struct{}
+
+// withSession lets the optimization steps of a run attach escalations to its session
+func withSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+func sessionFrom(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// escalateIssue queues a critical or high severity issue reported by the model for human
+// review: these are problems the agent was told not to fix itself (prices, GTINs, URLs...)
+func escalateIssue(ctx context.Context, field, severity, description string) {
+	s := sessionFrom(ctx)
+	severity = strings.ToLower(severity)
+	if s == nil || description == "" || (severity != "critical" && severity != "high") {
+		return
+	}
+	s.HumanReviews = append(s.HumanReviews, s.newHumanReview(models.HumanReview{
+		Source:    "issue",
+		Field:     field,
+		Question:  description,
+		RiskLevel: "high",
+	}))
+}
+
+// contradicted reports whether the session queued a contradiction between the feed and the
+// product images on field: the model may not overwrite it while a reviewer decides
+func contradicted(ctx context.Context, field string) bool {
+	s := sessionFrom(ctx)
+	if s == nil {
+		return false
+	}
+	for _, r := range s.HumanReviews {
+		if r.Source == "contradiction" && strings.EqualFold(r.Field, field) {
+			return true
+		}
+	}
+	return false
+}
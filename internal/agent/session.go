@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 	"github.com/benjamincozon/feedenrich/internal/models"
 	"github.com/google/uuid"
 )
@@ -19,6 +20,7 @@ func (s *Session) GetProductData() json.RawMessage {
 }
 
 func (s *Session) AddProposal(field, before, after string, sources []tools.Source, confidence float64, risk string) {
+	after = normalizeModelValue(field, after)
 	sourcesJSON, _ := json.Marshal(sources)
 	
 	var beforePtr *string
@@ -37,6 +39,7 @@ func (s *Session) AddProposal(field, before, after string, sources []tools.Sourc
 		Confidence:  confidence,
 		RiskLevel:   risk,
 		Status:      "proposed",
+		Module:      string(s.Group),
 		CreatedAt:   time.Now(),
 	}
 
@@ -51,3 +54,50 @@ func (s *Session) AddSource(source tools.Source) {
 		Confidence: source.Confidence,
 	})
 }
+
+func (s *Session) RequestHumanReview(question string, context map[string]any, options []string) string {
+	review := s.newHumanReview(models.HumanReview{
+		Source:   "tool",
+		Question: question,
+		Options:  options,
+	})
+	if field, ok := context["field"].(string); ok {
+		review.Field = field
+	}
+	if len(context) > 0 {
+		review.Context, _ = json.Marshal(context)
+	}
+	s.HumanReviews = append(s.HumanReviews, review)
+	return review.ID.String()
+}
+
+// newHumanReview fills the identifiers of a review raised during the session
+func (s *Session) newHumanReview(r models.HumanReview) models.HumanReview {
+	r.ID = uuid.New()
+	r.ProductID = s.ProductID
+	r.SessionID = &s.ID
+	if s.Product != nil {
+		r.DatasetID = s.Product.DatasetID
+	}
+	r.Status = "pending"
+	r.CreatedAt = time.Now()
+	return r
+}
+
+// keepCalls copies the call log of the run into the session, to be saved with it
+func (s *Session) keepCalls(log *llm.CallLog) {
+	for _, c := range log.Calls() {
+		s.LLMCalls = append(s.LLMCalls, models.LLMCall{
+			ID:               uuid.New(),
+			SessionID:        s.ID,
+			Model:            c.Model,
+			Request:          c.Request,
+			Response:         c.Response,
+			Error:            c.Error,
+			LatencyMs:        c.LatencyMs,
+			PromptTokens:     c.Usage.PromptTokens,
+			CompletionTokens: c.Usage.CompletionTokens,
+			CreatedAt:        c.CreatedAt,
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// normalizesSizes reports whether a group proposes the size fixes of tools.CheckSize
+func normalizesSizes(group OptimizationGroup) bool {
+	return group == GroupAll || group == GroupRecommendedAttrs
+}
+
+// sizeProposals turns the fixes of tools.CheckSize into proposals for apparel: the GMC
+// form of the size ("xl" → "XL") and the size_system the size, link or currency decide
+func (a *Agent) sizeProposals(product *models.Product) []models.Proposal {
+	if tools.DetectVertical(product.RawData) != tools.VerticalApparel {
+		return nil
+	}
+	var proposals []models.Proposal
+	for _, f := range tools.CheckSize(product.RawData) {
+		source := models.Source{Type: "feed", Reference: "size", Evidence: f.Reason, Confidence: f.Confidence, Verified: true}
+		proposals = append(proposals, a.codeProposal(product, f.Field, f.Before, f.After, f.Reason, source, "low"))
+	}
+	return proposals
+}
@@ -0,0 +1,285 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrNotPaused is returned when resuming a session that is not a paused tools session
+var ErrNotPaused = errors.New("session is not paused")
+
+// ErrAwaitingReview is returned when resuming a session whose human review is still pending
+var ErrAwaitingReview = errors.New("session is waiting for a human review")
+
+type resumableKey struct{}
+
+// WithResumable marks a tools run whose session is saved when it pauses, so it can be
+// resumed later: it pauses when Pause is called and when the model asks a reviewer a
+// question (request_human_review). Other runs (jobs) queue the question and carry on.
+func WithResumable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, resumableKey{}, true)
+}
+
+func resumableFrom(ctx context.Context) bool {
+	resumable, _ := ctx.Value(resumableKey{}).(bool)
+	return resumable
+}
+
+// runningSessions tracks the resumable tools sessions running in this process and
+// whether a pause was requested for them
+type runningSessions struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]bool
+}
+
+func newRunningSessions() *runningSessions {
+	return &runningSessions{sessions: map[uuid.UUID]bool{}}
+}
+
+func (r *runningSessions) start(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[id] = false
+}
+
+func (r *runningSessions) stop(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, id)
+}
+
+func (r *runningSessions) pauseRequested(id uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[id]
+}
+
+// Pause asks a resumable tools session running in this process to pause after its
+// current step. It reports false when no such session is running.
+func (a *Agent) Pause(sessionID uuid.UUID) bool {
+	a.running.mu.Lock()
+	defer a.running.mu.Unlock()
+	if _, ok := a.running.sessions[sessionID]; !ok {
+		return false
+	}
+	a.running.sessions[sessionID] = true
+	return true
+}
+
+// RunSteps runs the tools engine on a product: the model calls the toolbox one step at a
+// time (analyze, search, optimize, validate...) until it finishes or a guard stops it
+// (see stepLimit).
+// A resumable run (WithResumable) that pauses returns its session with the "paused"
+// status and a checkpoint, and no error; ResumeSteps continues it.
+func (a *Agent) RunSteps(ctx context.Context, product *models.Product, goal string) (*Session, error) {
+	session := a.newSession(ctx, product, goal)
+	session.Group = GroupAll
+	session.Engine = EngineTools
+	return session, a.runSteps(ctx, session)
+}
+
+// ResumeSteps continues a paused tools session from its saved traces and checkpoint.
+// answer is the review the session was waiting for, once resolved or dismissed: the model
+// reads the reviewer's resolution as the result of its request_human_review call.
+func (a *Agent) ResumeSteps(ctx context.Context, product *models.Product, saved *models.AgentSession, traces []models.AgentTrace, answer *models.HumanReview) (*Session, error) {
+	if saved.Status != "paused" || saved.Checkpoint == nil || Engine(saved.Engine) != EngineTools {
+		return nil, ErrNotPaused
+	}
+	checkpoint := saved.Checkpoint
+	if review := checkpoint.AwaitingReview; review != nil {
+		if answer == nil || answer.ID != *review || answer.Status == "pending" {
+			return nil, ErrAwaitingReview
+		}
+	}
+
+	session := a.newSession(ctx, product, saved.Goal)
+	session.ID = saved.ID
+	session.StartedAt = saved.StartedAt
+	session.Group = GroupAll
+	session.Engine = EngineTools
+	if saved.Thresholds != nil {
+		session.Thresholds = *saved.Thresholds
+	}
+	session.Traces = traces
+	session.Proposals = append(session.Proposals, checkpoint.Proposals...)
+	session.Sources = append(session.Sources, checkpoint.Sources...)
+	if checkpoint.AwaitingReview != nil {
+		answerReview(session, answer)
+	}
+	return session, a.runSteps(ctx, session)
+}
+
+// answerReview puts the reviewer's answer in the output of the request_human_review
+// step it answers, where the next step's prompt reads it
+func answerReview(session *Session, review *models.HumanReview) {
+	for i, t := range session.Traces {
+		var output tools.RequestHumanReviewOutput
+		if t.ToolName != "request_human_review" || json.Unmarshal(t.ToolOutput, &output) != nil || output.ReviewID != review.ID.String() {
+			continue
+		}
+		output.Status = review.Status
+		output.Answer = review.Resolution
+		session.Traces[i].ToolOutput, _ = json.Marshal(output)
+	}
+}
+
+// runSteps runs the steps of a tools session from its last trace
+func (a *Agent) runSteps(ctx context.Context, session *Session) error {
+	ctx = withSession(ctx, session)
+	if a.config.LLM.CallLog {
+		calls := &llm.CallLog{}
+		ctx = llm.WithCallLog(ctx, calls)
+		defer session.keepCalls(calls)
+	}
+	ctx, err := a.WithBudget(ctx, session.Product.DatasetID)
+	if err != nil {
+		session.Status = "failed"
+		return err
+	}
+	resumable, restored := resumableFrom(ctx), len(session.Proposals)
+	if resumable {
+		a.running.start(session.ID)
+		defer a.running.stop(session.ID)
+	}
+
+	// The duration guard also cuts a step in flight: the session then ends with what it has
+	started, stepCtx := time.Now(), ctx
+	if d := a.config.Agent.MaxSessionDuration; d > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	for step := len(session.Traces) + 1; ; step++ {
+		if reason := a.stepLimit(session, step, started); reason != "" {
+			a.stopSteps(session, step, reason)
+			break
+		}
+		if resumable && a.running.pauseRequested(session.ID) {
+			a.pauseSteps(ctx, session, restored, nil)
+			return nil
+		}
+		trace, _, done, err := a.executeStep(stepCtx, session, step)
+		if err != nil && ctx.Err() == nil && stepCtx.Err() != nil {
+			a.stopSteps(session, step, fmt.Sprintf("time limit reached (%s)", a.config.Agent.MaxSessionDuration))
+			break
+		}
+		if err != nil {
+			if a.callbacks.OnError != nil {
+				a.callbacks.OnError(err)
+			}
+			session.Status = "failed"
+			return err
+		}
+		session.Traces = append(session.Traces, *trace)
+		if done {
+			break
+		}
+		if review := requestedReview(trace); resumable && review != nil {
+			a.pauseSteps(ctx, session, restored, review)
+			return nil
+		}
+	}
+
+	a.settleProposals(ctx, session, restored)
+	session.Status = "completed"
+
+	if a.callbacks.OnComplete != nil {
+		usage := session.Usage()
+		a.callbacks.OnComplete(SessionSummary{
+			TotalSteps:       len(session.Traces),
+			TokensUsed:       usage.PromptTokens + usage.CompletionTokens,
+			DurationMs:       time.Since(session.StartedAt).Milliseconds(),
+			ProposalsCreated: len(session.Proposals),
+		})
+	}
+	return nil
+}
+
+// stepLimit returns why a tools session must stop before the given step, "" while it is
+// within AGENT_MAX_STEPS, AGENT_MAX_SESSION_TOKENS (all its steps, resumed runs included)
+// and AGENT_MAX_SESSION_DURATION (this run)
+func (a *Agent) stepLimit(session *Session, step int, started time.Time) string {
+	limits := a.config.Agent
+	if limits.MaxSteps > 0 && step > limits.MaxSteps {
+		return fmt.Sprintf("step limit reached (%d steps)", limits.MaxSteps)
+	}
+	tokens := 0
+	for _, t := range session.Traces {
+		tokens += t.TokensUsed
+	}
+	if limits.MaxSessionTokens > 0 && tokens >= limits.MaxSessionTokens {
+		return fmt.Sprintf("token limit reached (%d tokens)", tokens)
+	}
+	if limits.MaxSessionDuration > 0 && time.Since(started) >= limits.MaxSessionDuration {
+		return fmt.Sprintf("time limit reached (%s)", limits.MaxSessionDuration)
+	}
+	return ""
+}
+
+// stopSteps ends a tools session on a guard: a last trace says why, and the session is
+// finalized with the proposals it made so far
+func (a *Agent) stopSteps(session *Session, step int, reason string) {
+	thought := fmt.Sprintf("Stopped: %s, finalized with %d proposals", reason, len(session.Proposals))
+	session.Traces = append(session.Traces, models.AgentTrace{
+		ID:         uuid.New(),
+		SessionID:  session.ID,
+		StepNumber: step,
+		Thought:    thought,
+		CreatedAt:  time.Now(),
+	})
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog(thought)
+	}
+}
+
+// requestedReview returns the review a request_human_review step queued, nil for other steps
+func requestedReview(trace *models.AgentTrace) *uuid.UUID {
+	var output tools.RequestHumanReviewOutput
+	if trace.ToolName != "request_human_review" || json.Unmarshal(trace.ToolOutput, &output) != nil {
+		return nil
+	}
+	id, err := uuid.Parse(output.ReviewID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// settleProposals tags and scores the proposals made since the session last started;
+// the ones restored from a checkpoint were settled when it paused
+func (a *Agent) settleProposals(ctx context.Context, session *Session, restored int) {
+	proposals := session.Proposals[restored:]
+	for i := range proposals {
+		proposals[i].Module = string(GroupAll)
+	}
+	a.ScoreProposals(ctx, session.Product, proposals)
+}
+
+// pauseSteps stops a tools session, keeping what its traces don't carry in its checkpoint.
+// Its proposals so far are saved with it and kept for the rest of the run.
+func (a *Agent) pauseSteps(ctx context.Context, session *Session, restored int, review *uuid.UUID) {
+	a.settleProposals(ctx, session, restored)
+	session.Status = "paused"
+	session.Checkpoint = &models.SessionCheckpoint{
+		Proposals:      session.Proposals,
+		Sources:        session.Sources,
+		AwaitingReview: review,
+		PausedAt:       time.Now(),
+	}
+	if a.callbacks.OnLog != nil {
+		message := fmt.Sprintf("Session paused after %d steps", len(session.Traces))
+		if review != nil {
+			message += fmt.Sprintf(", waiting for human review %s", review)
+		}
+		a.callbacks.OnLog(message)
+	}
+}
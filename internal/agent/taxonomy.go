@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/taxonomy"
+)
+
+// mapsCategories reports whether a group's google_product_category proposals come from
+// the taxonomy mapper
+func mapsCategories(group OptimizationGroup) bool {
+	return group == GroupAll || group == GroupRecommendedAttrs
+}
+
+// categoryProposal maps an empty google_product_category from the product type and title
+// with the taxonomy mapper; nil when the product has one or nothing is close enough
+func (a *Agent) categoryProposal(product *models.Product) *models.Proposal {
+	var fields map[string]interface{}
+	json.Unmarshal(product.RawData, &fields)
+	if getFieldValueFromMap(fields, "google_product_category") != "" {
+		return nil
+	}
+	match, ok := a.taxonomy.Map(taxonomy.Input{
+		ProductType: getFieldValueFromMap(fields, "product_type"),
+		Title:       getFieldValueFromMap(fields, "title"),
+	}, a.config.Agent.TaxonomyMinScore)
+	if !ok {
+		return nil
+	}
+
+	reference, risk := "product_type", "low"
+	if match.Method == "similarity" {
+		reference, risk = "product_type, title", "medium"
+	}
+	// In an excerpt the deepest category found may only be a parent of the product's
+	verified := match.Score >= a.config.Agent.AutoVerifyConfidence && !a.taxonomy.Excerpt()
+	if a.taxonomy.Excerpt() {
+		risk = "medium"
+	}
+	source := models.Source{
+		Type:       "feed",
+		Reference:  reference,
+		Evidence:   fmt.Sprintf("Google taxonomy %d (%s, score %.2f)", match.Category.ID, match.Method, match.Score),
+		Confidence: match.Score,
+		Verified:   verified,
+	}
+	proposal := a.codeProposal(product, "google_product_category", "", match.Category.Name(),
+		fmt.Sprintf("Mapped to Google taxonomy category %d from the %s", match.Category.ID, reference), source, risk)
+	return &proposal
+}
+
+// knownCategory reports whether a google_product_category value is an ID or path of the
+// taxonomy, so model proposals naming categories that don't exist are dropped. With the
+// bundled excerpt, only paths under an unknown top-level category are (see Plausible).
+func (a *Agent) knownCategory(value string) bool {
+	return a.taxonomy.Plausible(value)
+}
+
+func isCategoryField(field string) bool {
+	return strings.EqualFold(strings.TrimSpace(field), "google_product_category")
+}
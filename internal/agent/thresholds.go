@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// ErrInvalidThresholds is returned for thresholds out of range
+var ErrInvalidThresholds = errors.New("invalid thresholds")
+
+// riskRank orders risk levels; a proposal is kept when its rank is within the tolerance
+var riskRank = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+// DefaultThresholds returns the AGENT_* thresholds
+func DefaultThresholds(cfg *config.Config) models.Thresholds {
+	return models.Thresholds{
+		MinConfidence:        cfg.Agent.MinConfidence,
+		AutoVerifyConfidence: cfg.Agent.AutoVerifyConfidence,
+		RiskTolerance:        cfg.Agent.RiskTolerance,
+	}
+}
+
+// ResolveThresholds applies overrides in order (e.g. dataset then request) and validates the result
+func ResolveThresholds(base models.Thresholds, overrides ...*models.ThresholdOverrides) (models.Thresholds, error) {
+	t := base
+	for _, o := range overrides {
+		if o == nil {
+			continue
+		}
+		if o.MinConfidence != nil {
+			t.MinConfidence = *o.MinConfidence
+		}
+		if o.AutoVerifyConfidence != nil {
+			t.AutoVerifyConfidence = *o.AutoVerifyConfidence
+		}
+		if o.RiskTolerance != nil {
+			t.RiskTolerance = *o.RiskTolerance
+		}
+	}
+	return t, ValidateThresholds(t)
+}
+
+// ValidateThresholds checks confidences are within 0-1, auto-verify is not below
+// the cut-off and the risk tolerance is a known level
+func ValidateThresholds(t models.Thresholds) error {
+	if t.MinConfidence < 0 || t.MinConfidence > 1 {
+		return fmt.Errorf("%w: min_confidence must be between 0 and 1", ErrInvalidThresholds)
+	}
+	if t.AutoVerifyConfidence < 0 || t.AutoVerifyConfidence > 1 {
+		return fmt.Errorf("%w: auto_verify_confidence must be between 0 and 1", ErrInvalidThresholds)
+	}
+	if t.AutoVerifyConfidence < t.MinConfidence {
+		return fmt.Errorf("%w: auto_verify_confidence must not be below min_confidence", ErrInvalidThresholds)
+	}
+	if _, ok := riskRank[t.RiskTolerance]; !ok {
+		return fmt.Errorf("%w: risk_tolerance must be low, medium or high", ErrInvalidThresholds)
+	}
+	return nil
+}
+
+// riskAllowed reports whether a proposal of the given risk is within tolerance.
+// Unknown levels are treated as high.
+func riskAllowed(level, tolerance string) bool {
+	rank, ok := riskRank[level]
+	if !ok {
+		rank = riskRank["high"]
+	}
+	return rank <= riskRank[tolerance]
+}
+
+type thresholdsKey struct{}
+
+// WithThresholds returns a context whose runs use t instead of the AGENT_* defaults
+func WithThresholds(ctx context.Context, t models.Thresholds) context.Context {
+	return context.WithValue(ctx, thresholdsKey{}, t)
+}
+
+func (a *Agent) thresholdsFrom(ctx context.Context) models.Thresholds {
+	if t, ok := ctx.Value(thresholdsKey{}).(models.Thresholds); ok {
+		return t
+	}
+	return DefaultThresholds(a.config)
+}
+
+// keepProposal applies the confidence cut-off and risk tolerance, logging what it drops
+func (a *Agent) keepProposal(t models.Thresholds, field string, confidence float64, risk string) bool {
+	if confidence < t.MinConfidence {
+		return false
+	}
+	if !riskAllowed(risk, t.RiskTolerance) {
+		if a.callbacks.OnLog != nil {
+			a.callbacks.OnLog(fmt.Sprintf("⚠️ Filtered %s-risk proposal for %s (risk tolerance: %s)", risk, field, t.RiskTolerance))
+		}
+		return false
+	}
+	return true
+}
@@ -6,13 +6,15 @@ import (
 	"fmt"
 
 	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 	"github.com/benjamincozon/feedenrich/internal/models"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/productdata"
 )
 
 // AnalyzeProductTool analyzes the current state of a product
 type AnalyzeProductTool struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
 }
 
@@ -57,25 +59,24 @@ Règles GMC à vérifier:
 - gtin ou mpn: au moins un requis
 - color, gender, size: recommandés pour vêtements/chaussures
 
-Retourne UNIQUEMENT le JSON, sans markdown.`, string(productData))
+Retourne UNIQUEMENT le JSON, sans markdown.`, productdata.ForPrompt(t.config, productData))
 
-	resp, err := t.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: t.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: prompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	resp, err := t.client.Chat(ctx, llm.Request{
+		Model: t.config.LLM.Model,
+		Stage: llm.StageAnalyzeProduct,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
+		JSON: true,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("openai analyze: %w", err)
+		return nil, fmt.Errorf("llm analyze: %w", err)
 	}
 
 	var result models.AnalysisResult
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
 		// Return raw response if parsing fails
-		return json.RawMessage(resp.Choices[0].Message.Content), nil
+		return json.RawMessage(resp.Content), nil
 	}
 
 	return result, nil
@@ -83,8 +84,9 @@ Retourne UNIQUEMENT le JSON, sans markdown.`, string(productData))
 
 // AnalyzeImageTool uses vision to analyze product images
 type AnalyzeImageTool struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
+	images *imageproxy.Proxy
 }
 
 func (t *AnalyzeImageTool) Name() string { return "analyze_image" }
@@ -164,28 +166,26 @@ Retourne un JSON avec:
 
 Retourne UNIQUEMENT le JSON.`, questionsPrompt)
 
-	resp, err := t.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: t.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role: openai.ChatMessageRoleUser,
-				MultiContent: []openai.ChatMessagePart{
-					{Type: openai.ChatMessagePartTypeText, Text: prompt},
-					{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: params.ImageURL}},
-				},
-			},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	image := llm.Image{URL: params.ImageURL}
+	if !llm.Simulated(ctx) {
+		image.URL, _, _ = t.images.ForModel(ctx, params.ImageURL, t.config.Agent.VisionMaxSize)
+	}
+
+	resp, err := t.client.ChatWithVision(ctx, llm.Request{
+		Model: t.config.LLM.Model,
+		Stage: llm.StageAnalyzeImage,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
-	})
+		JSON: true,
+	}, image)
 	if err != nil {
-		return nil, fmt.Errorf("openai vision: %w", err)
+		return nil, fmt.Errorf("llm vision: %w", err)
 	}
 
 	var result AnalyzeImageOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
-		return json.RawMessage(resp.Choices[0].Message.Content), nil
+	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
+		return json.RawMessage(resp.Content), nil
 	}
 
 	return result, nil
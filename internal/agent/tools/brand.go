@@ -0,0 +1,235 @@
+package tools
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BrandEntry is a brand of a catalog: its canonical spelling, the number of products
+// carrying it and the spellings found in the feed
+type BrandEntry struct {
+	Brand    string         `json:"brand"`
+	Count    int            `json:"count"`
+	Variants map[string]int `json:"variants"` // spelling → products
+}
+
+// BrandMatch is the canonical form found for a brand value
+type BrandMatch struct {
+	Brand string `json:"brand"`
+	// alias: set by an admin; feed: the most frequent spelling of the same name
+	// ("NIKE" → "Nike"); fuzzy: a misspelling of a much more frequent brand ("Adiddas" → "Adidas")
+	Method string `json:"method"`
+	Count  int    `json:"count,omitempty"` // products carrying the brand, 0 for aliases not in the feed
+}
+
+// brandFuzzyRatio is how much more frequent a brand must be than a close spelling for
+// the spelling to be taken as a misspelling of it
+const brandFuzzyRatio = 3
+
+// BrandDictionary is the brand dictionary of a catalog: the brands of its feed, with
+// spellings differing only by case, accents or punctuation grouped under the most frequent
+// one, and the aliases set by admins
+type BrandDictionary struct {
+	entries map[string]*BrandEntry // brandKey → entry
+	aliases map[string]string      // brandKey of the alias → brand
+}
+
+// NewBrandDictionary builds a dictionary from the brand spellings of a feed with their
+// product counts, and aliases (alias → brand). Aliases win over the feed, and the brand of
+// an alias is canonical for its own spellings too.
+func NewBrandDictionary(counts map[string]int, aliases map[string]string) *BrandDictionary {
+	d := &BrandDictionary{entries: map[string]*BrandEntry{}, aliases: map[string]string{}}
+	for spelling, n := range counts {
+		spelling = strings.TrimSpace(spelling)
+		key := brandKey(spelling)
+		if key == "" || n <= 0 {
+			continue
+		}
+		e := d.entries[key]
+		if e == nil {
+			e = &BrandEntry{Variants: map[string]int{}}
+			d.entries[key] = e
+		}
+		e.Variants[spelling] += n
+		e.Count += n
+	}
+	for _, e := range d.entries {
+		e.Brand = canonicalSpelling(e.Variants)
+	}
+	for alias, brand := range aliases {
+		brand = strings.TrimSpace(brand)
+		if key := brandKey(alias); key != "" && brand != "" {
+			d.aliases[key] = brand
+		}
+	}
+	for _, brand := range aliases {
+		if key := brandKey(brand); key != "" {
+			if _, ok := d.aliases[key]; !ok {
+				d.aliases[key] = strings.TrimSpace(brand)
+			}
+		}
+	}
+	return d
+}
+
+// Brands lists the brands of the feed, most frequent first
+func (d *BrandDictionary) Brands() []BrandEntry {
+	brands := make([]BrandEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		brands = append(brands, *e)
+	}
+	sort.Slice(brands, func(i, j int) bool {
+		if brands[i].Count != brands[j].Count {
+			return brands[i].Count > brands[j].Count
+		}
+		return brands[i].Brand < brands[j].Brand
+	})
+	return brands
+}
+
+// Canonicalize returns the canonical form of a brand, in order: an alias, then a
+// misspelling of a brand at least brandFuzzyRatio times more frequent (one edit for names
+// of 4 to 7 characters, two from 8), then the most frequent spelling of the same name. A
+// misspelling close to several brands is left alone. It returns false for brands the
+// dictionary doesn't know.
+func (d *BrandDictionary) Canonicalize(value string) (BrandMatch, bool) {
+	key := brandKey(value)
+	if key == "" {
+		return BrandMatch{}, false
+	}
+	if brand, ok := d.aliases[key]; ok {
+		return BrandMatch{Brand: brand, Method: "alias"}, true
+	}
+
+	own := 0
+	if e := d.entries[key]; e != nil {
+		own = e.Count
+	}
+	if match, ok := d.fuzzy(key, own); ok {
+		return match, true
+	}
+	if e := d.entries[key]; e != nil {
+		return BrandMatch{Brand: e.Brand, Method: "feed", Count: e.Count}, true
+	}
+	return BrandMatch{}, false
+}
+
+// fuzzy finds the single brand a key is a misspelling of
+func (d *BrandDictionary) fuzzy(key string, own int) (BrandMatch, bool) {
+	maxDist := brandMaxDistance(key)
+	if maxDist == 0 {
+		return BrandMatch{}, false
+	}
+	var found []BrandMatch
+	for k, brand := range d.aliases {
+		if k != key && editDistance(key, k, maxDist) <= maxDist {
+			m := BrandMatch{Brand: brand, Method: "fuzzy"}
+			if e := d.entries[k]; e != nil {
+				m.Count = e.Count
+			}
+			found = append(found, m)
+		}
+	}
+	for k, e := range d.entries {
+		if k == key || e.Count < brandFuzzyRatio*max(own, 1) {
+			continue
+		}
+		if editDistance(key, k, maxDist) <= maxDist {
+			found = append(found, BrandMatch{Brand: e.Brand, Method: "fuzzy", Count: e.Count})
+		}
+	}
+	if len(found) == 0 {
+		return BrandMatch{}, false
+	}
+	for _, m := range found[1:] {
+		if m.Brand != found[0].Brand {
+			return BrandMatch{}, false
+		}
+	}
+	return found[0], true
+}
+
+// brandMaxDistance is the number of edits a misspelling of a name may have; short names
+// are too close to each other to be corrected
+func brandMaxDistance(key string) int {
+	switch n := len([]rune(key)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// brandKey identifies the spellings of a brand name: lower-case letters and digits without
+// accents ("Levi's", "LEVIS" and "levis" are one brand)
+func brandKey(value string) string {
+	var b strings.Builder
+	for _, r := range foldAccents(strings.ToLower(strings.TrimSpace(value))) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// canonicalSpelling is the most frequent spelling of a brand; ties go to a spelling with
+// capitals, then alphabetically
+func canonicalSpelling(variants map[string]int) string {
+	best, bestN := "", 0
+	for s, n := range variants {
+		switch {
+		case n > bestN:
+		case n == bestN && hasUpper(s) && !hasUpper(best):
+		case n == bestN && hasUpper(s) == hasUpper(best) && s < best:
+		default:
+			continue
+		}
+		best, bestN = s, n
+	}
+	return best
+}
+
+func hasUpper(s string) bool {
+	return strings.IndexFunc(s, unicode.IsUpper) >= 0
+}
+
+// editDistance is the optimal string alignment distance of two strings (insertions,
+// deletions, substitutions and swaps of adjacent characters), or limit+1 once it is known
+// to be above limit
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	prevMin := 0
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		// a swap reads two rows back, so both rows must be above limit
+		if rowMin > limit && prevMin > limit {
+			return limit + 1
+		}
+		prevMin = rowMin
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/taxonomy"
+)
+
+// MapCategoryTool maps the product to a google_product_category of the Google taxonomy,
+// without an LLM, so the category proposed always exists
+type MapCategoryTool struct {
+	taxonomy *taxonomy.Taxonomy
+	minScore float64
+}
+
+func (t *MapCategoryTool) Name() string { return "map_category" }
+
+func (t *MapCategoryTool) Description() string {
+	return "Map the product to a Google taxonomy google_product_category from its product_type and title, deterministically, and propose it when the field is empty. Never write google_product_category yourself."
+}
+
+func (t *MapCategoryTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+	}
+}
+
+type MapCategoryOutput struct {
+	Found    bool            `json:"found"`
+	Match    *taxonomy.Match `json:"match,omitempty"`
+	Proposed bool            `json:"proposed"`
+}
+
+func (t *MapCategoryTool) Execute(ctx context.Context, input json.RawMessage, session SessionContext) (any, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(session.GetProductData(), &data); err != nil {
+		return nil, fmt.Errorf("parse product: %w", err)
+	}
+	current := getFieldValue(data, "google_product_category")
+	match, ok := t.taxonomy.Map(taxonomy.Input{
+		Category:    current,
+		ProductType: getFieldValue(data, "product_type"),
+		Title:       getFieldValue(data, "title"),
+	}, t.minScore)
+	if !ok {
+		return MapCategoryOutput{}, nil
+	}
+
+	output := MapCategoryOutput{Found: true, Match: &match}
+	if current == "" {
+		risk := "low"
+		if match.Method == "similarity" || t.taxonomy.Excerpt() {
+			risk = "medium"
+		}
+		session.AddProposal("google_product_category", "", match.Category.Name(), []Source{{
+			Type:       "feed",
+			Reference:  "product_type",
+			Evidence:   fmt.Sprintf("Google taxonomy %d (%s)", match.Category.ID, match.Method),
+			Confidence: match.Score,
+		}}, match.Score, risk)
+		output.Proposed = true
+	}
+	return output, nil
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Color is a GMC color value: a primary color and up to two secondary ones, written
+// "navy/white/red"
+type Color struct {
+	Primary   string   `json:"primary"`
+	Secondary []string `json:"secondary,omitempty"`
+}
+
+// String is the GMC form of the color
+func (c Color) String() string {
+	return strings.Join(append([]string{c.Primary}, c.Secondary...), "/")
+}
+
+// gmcColors are the standard color names colors are normalized to, with the RGB value
+// hex codes are matched against
+var gmcColors = map[string][3]int{
+	"black": {0, 0, 0}, "white": {255, 255, 255}, "grey": {128, 128, 128}, "silver": {192, 192, 192},
+	"gold": {212, 175, 55}, "red": {220, 20, 60}, "burgundy": {128, 0, 32}, "pink": {255, 150, 190},
+	"purple": {128, 0, 128}, "blue": {30, 100, 220}, "navy": {0, 0, 110}, "turquoise": {64, 224, 208},
+	"green": {34, 139, 34}, "khaki": {195, 176, 145}, "yellow": {255, 220, 0}, "orange": {255, 140, 0},
+	"brown": {120, 70, 30}, "beige": {225, 205, 170}, "ivory": {255, 255, 235},
+}
+
+// colorNames maps color words and phrases, French and English, to gmcColors; phrases
+// are read before words, so "bleu marine" is navy and not blue
+var colorNames = map[string]string{
+	// phrases
+	"bleu marine": "navy", "navy blue": "navy", "bleu nuit": "navy", "bleu ciel": "blue", "sky blue": "blue",
+	"bleu roi": "blue", "royal blue": "blue", "vert olive": "khaki", "olive green": "khaki", "vert kaki": "khaki",
+	"rose poudre": "pink", "gris anthracite": "grey", "off white": "ivory", "blanc casse": "ivory",
+	"rouge bordeaux": "burgundy", "multi color": "multicolor", "multi couleur": "multicolor",
+	// words
+	"noir": "black", "black": "black", "jet": "black", "blanc": "white", "white": "white", "ecru": "ivory",
+	"ivoire": "ivory", "ivory": "ivory", "creme": "ivory", "cream": "ivory", "gris": "grey", "grey": "grey",
+	"gray": "grey", "anthracite": "grey", "charcoal": "grey", "ardoise": "grey", "argent": "silver",
+	"argente": "silver", "silver": "silver", "or": "gold", "dore": "gold", "gold": "gold", "golden": "gold",
+	"rouge": "red", "red": "red", "bordeaux": "burgundy", "burgundy": "burgundy", "lie de vin": "burgundy",
+	"wine": "burgundy", "maroon": "burgundy", "rose": "pink", "pink": "pink", "fuchsia": "pink",
+	"framboise": "pink", "violet": "purple", "purple": "purple", "mauve": "purple", "lilas": "purple",
+	"lavande": "purple", "lavender": "purple", "prune": "purple", "plum": "purple", "bleu": "blue",
+	"blue": "blue", "azur": "blue", "indigo": "navy", "marine": "navy", "navy": "navy", "turquoise": "turquoise",
+	"turquoise blue": "turquoise", "teal": "turquoise", "cyan": "turquoise", "vert": "green", "green": "green",
+	"emeraude": "green", "emerald": "green", "menthe": "green", "mint": "green", "sapin": "green",
+	"kaki": "khaki", "khaki": "khaki", "olive": "khaki", "jaune": "yellow", "yellow": "yellow",
+	"moutarde": "yellow", "mustard": "yellow", "citron": "yellow", "orange": "orange", "corail": "orange",
+	"coral": "orange", "brique": "orange", "marron": "brown", "brun": "brown", "brown": "brown",
+	"chocolat": "brown", "chocolate": "brown", "camel": "brown", "cognac": "brown", "caramel": "brown",
+	"taupe": "brown", "tan": "brown", "beige": "beige", "sable": "beige", "sand": "beige", "nude": "beige",
+	"naturel": "beige", "natural": "beige", "multicolore": "multicolor", "multicolor": "multicolor",
+	"multicolour": "multicolor", "multi": "multicolor",
+}
+
+// colorModifiers are shade words dropped around a color name ("bleu clair" is blue)
+var colorModifiers = map[string]bool{
+	"clair": true, "fonce": true, "pale": true, "vif": true, "chine": true, "mat": true,
+	"brillant": true, "pastel": true, "fluo": true, "light": true, "dark": true, "bright": true,
+	"deep": true, "heather": true, "matte": true, "metallic": true, "metallise": true, "neon": true,
+	"soft": true, "tres": true, "very": true,
+}
+
+// colorSeparators split a value into its colors
+var colorSeparators = strings.NewReplacer("/", "|", ",", "|", "+", "|", "&", "|", ";", "|",
+	" et ", "|", " and ", "|", " avec ", "|", " with ", "|")
+
+// NormalizeColor maps a free-text color ("bleu marine", "anthracite", "#000000",
+// "navy/white") to GMC standard color names: the first color is the primary one, the
+// next two are secondary, duplicates are dropped. Hex codes go to the closest standard
+// color. It returns false when a part of the value is not a color it knows, so it is
+// never guessed.
+func NormalizeColor(value string) (Color, bool) {
+	value = foldAccents(strings.ToLower(strings.TrimSpace(value)))
+	if value == "" {
+		return Color{}, false
+	}
+
+	var colors []string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(colorSeparators.Replace(" "+value+" "), "|") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, ok := colorName(part)
+		if !ok {
+			return Color{}, false
+		}
+		if !seen[name] {
+			seen[name] = true
+			colors = append(colors, name)
+		}
+	}
+	if len(colors) == 0 {
+		return Color{}, false
+	}
+	if len(colors) > 3 {
+		// beyond three colors GMC expects the product to be described as multicolor
+		colors = []string{"multicolor"}
+	}
+	return Color{Primary: colors[0], Secondary: colors[1:]}, true
+}
+
+// colorName reads one color: a hex code, a known phrase, or a known word with shade
+// modifiers around it
+func colorName(part string) (string, bool) {
+	if strings.HasPrefix(part, "#") {
+		return nearestColor(part)
+	}
+	words := strings.FieldsFunc(part, func(r rune) bool { return !unicode.IsLetter(r) && r != '#' })
+	if name, ok := colorNames[strings.Join(words, " ")]; ok {
+		return name, true
+	}
+
+	var kept []string
+	for _, w := range words {
+		if !colorModifiers[w] {
+			kept = append(kept, w)
+		}
+	}
+	if name, ok := colorNames[strings.Join(kept, " ")]; ok && len(kept) > 0 {
+		return name, true
+	}
+	return "", false
+}
+
+// nearestColor maps a "#rgb" or "#rrggbb" code to the closest gmcColors entry
+func nearestColor(hex string) (string, bool) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return "", false
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return "", false
+	}
+	r, g, b := int(rgb>>16&0xff), int(rgb>>8&0xff), int(rgb&0xff)
+
+	best, bestDist := "", -1
+	for name, c := range gmcColors {
+		dist := (r-c[0])*(r-c[0]) + (g-c[1])*(g-c[1]) + (b-c[2])*(b-c[2])
+		if bestDist < 0 || dist < bestDist || (dist == bestDist && name < best) {
+			best, bestDist = name, dist
+		}
+	}
+	return best, true
+}
+
+// neighborColors are standard colors a photo can't reliably tell apart (lighting, screen,
+// shade names): one read for the other is not a contradiction
+var neighborColors = func() map[[2]string]bool {
+	neighbors := map[[2]string]bool{}
+	for _, pair := range [][2]string{
+		{"black", "grey"}, {"black", "navy"}, {"grey", "silver"}, {"white", "ivory"}, {"white", "silver"},
+		{"ivory", "beige"}, {"beige", "khaki"}, {"beige", "brown"}, {"khaki", "green"}, {"khaki", "brown"},
+		{"gold", "yellow"}, {"yellow", "orange"}, {"orange", "red"}, {"orange", "brown"}, {"red", "burgundy"},
+		{"red", "pink"}, {"burgundy", "purple"}, {"burgundy", "brown"}, {"pink", "purple"},
+		{"purple", "navy"}, {"blue", "navy"}, {"blue", "turquoise"}, {"turquoise", "green"},
+	} {
+		neighbors[pair] = true
+		neighbors[[2]string{pair[1], pair[0]}] = true
+	}
+	return neighbors
+}()
+
+// ColorsContradict reports whether a color seen in the product image contradicts the feed's:
+// none of their colors is the same or a neighbor of one another. Multicolor contradicts
+// nothing.
+func ColorsContradict(feed, image Color) bool {
+	for _, f := range append([]string{feed.Primary}, feed.Secondary...) {
+		for _, i := range append([]string{image.Primary}, image.Secondary...) {
+			if f == i || f == "multicolor" || i == "multicolor" || neighborColors[[2]string{f, i}] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// accentFolder strips the French accents of lower-case values before lookups
+var accentFolder = strings.NewReplacer("é", "e", "è", "e", "ê", "e", "ë", "e", "à", "a", "â", "a",
+	"î", "i", "ï", "i", "ô", "o", "û", "u", "ù", "u", "ç", "c")
+
+func foldAccents(s string) string {
+	return accentFolder.Replace(s)
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+)
+
+// availabilityValues maps vendor availability values, French and English, to GMC's
+var availabilityValues = map[string]string{
+	"in_stock": "in_stock", "in stock": "in_stock", "instock": "in_stock", "available": "in_stock",
+	"en stock": "in_stock", "disponible": "in_stock", "dispo": "in_stock", "true": "in_stock",
+	"yes": "in_stock", "oui": "in_stock", "y": "in_stock", "limited availability": "in_stock",
+	"out_of_stock": "out_of_stock", "out of stock": "out_of_stock", "outofstock": "out_of_stock",
+	"sold out": "out_of_stock", "soldout": "out_of_stock", "discontinued": "out_of_stock",
+	"rupture": "out_of_stock", "rupture de stock": "out_of_stock", "en rupture": "out_of_stock",
+	"en rupture de stock": "out_of_stock", "epuise": "out_of_stock", "indisponible": "out_of_stock",
+	"false": "out_of_stock", "no": "out_of_stock", "non": "out_of_stock", "n": "out_of_stock",
+	"preorder": "preorder", "pre-order": "preorder", "pre order": "preorder", "precommande": "preorder",
+	"en precommande": "preorder", "pre-commande": "preorder",
+	"backorder": "backorder", "back order": "backorder", "back-order": "backorder",
+	"sur commande": "backorder", "en reapprovisionnement": "backorder", "en cours de reapprovisionnement": "backorder",
+}
+
+// conditionValues maps vendor condition values, French and English, to GMC's
+var conditionValues = map[string]string{
+	"new": "new", "neuf": "new", "neuve": "new", "nouveau": "new", "brand new": "new", "newcondition": "new",
+	"refurbished": "refurbished", "reconditionne": "refurbished", "reconditionnee": "refurbished",
+	"remis a neuf": "refurbished", "renewed": "refurbished", "refurbishedcondition": "refurbished",
+	"used": "used", "occasion": "used", "d'occasion": "used", "d occasion": "used", "second hand": "used",
+	"second-hand": "used", "seconde main": "used", "pre-owned": "used", "preowned": "used",
+	"usage": "used", "usagee": "used", "usedcondition": "used",
+}
+
+// NormalizeAvailability maps an availability value ("En stock", "disponible", "1",
+// "true", "http://schema.org/InStock") to GMC's in_stock, out_of_stock, preorder or
+// backorder. A stock quantity is in_stock above 0. It returns false for values it
+// doesn't know.
+func NormalizeAvailability(value string) (string, bool) {
+	key := enumKey(value)
+	if v, ok := availabilityValues[key]; ok {
+		return v, true
+	}
+	if n, err := strconv.Atoi(key); err == nil && n >= 0 {
+		if n == 0 {
+			return "out_of_stock", true
+		}
+		return "in_stock", true
+	}
+	return "", false
+}
+
+// NormalizeCondition maps a condition value ("neuf", "occasion", "reconditionné") to
+// GMC's new, refurbished or used; false for values it doesn't know
+func NormalizeCondition(value string) (string, bool) {
+	v, ok := conditionValues[enumKey(value)]
+	return v, ok
+}
+
+// enumKey is the lookup form of an enum value: lower-case without accents, without a
+// schema.org prefix ("https://schema.org/InStock" → "instock")
+func enumKey(value string) string {
+	key := strings.ToLower(strings.TrimSpace(value))
+	if i := strings.LastIndex(key, "schema.org/"); i >= 0 {
+		key = key[i+len("schema.org/"):]
+	}
+	return strings.Join(strings.Fields(foldAccents(key)), " ")
+}
@@ -12,11 +12,15 @@ import (
 // Every fact must point to: image, source URL, or original feed
 // This is critical for auditability and trust
 type EvidenceRegistry struct {
-	mu       sync.RWMutex
-	evidence map[uuid.UUID]*Evidence
-	byField  map[string][]uuid.UUID // field -> evidence IDs
+	mu         sync.RWMutex
+	evidence   map[uuid.UUID]*Evidence
+	byField    map[string][]uuid.UUID // field -> evidence IDs
+	autoVerify float64                // image evidence at or above this confidence is verified
 }
 
+// DefaultAutoVerifyConfidence is the auto-verify threshold of a new registry
+const DefaultAutoVerifyConfidence = 0.85
+
 type Evidence struct {
 	ID         uuid.UUID `json:"id"`
 	ProductID  uuid.UUID `json:"product_id"`
@@ -41,11 +45,19 @@ type EvidenceSource struct {
 
 func NewEvidenceRegistry() *EvidenceRegistry {
 	return &EvidenceRegistry{
-		evidence: make(map[uuid.UUID]*Evidence),
-		byField:  make(map[string][]uuid.UUID),
+		evidence:   make(map[uuid.UUID]*Evidence),
+		byField:    make(map[string][]uuid.UUID),
+		autoVerify: DefaultAutoVerifyConfidence,
 	}
 }
 
+// SetAutoVerifyConfidence changes the confidence at which image evidence is auto-verified
+func (r *EvidenceRegistry) SetAutoVerifyConfidence(threshold float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.autoVerify = threshold
+}
+
 // RegisterFromFeed creates evidence from original feed data
 func (r *EvidenceRegistry) RegisterFromFeed(productID uuid.UUID, field, value string) *Evidence {
 	r.mu.Lock()
@@ -94,7 +106,7 @@ func (r *EvidenceRegistry) RegisterFromImage(productID uuid.UUID, field, value,
 			Timestamp: time.Now(),
 		},
 		Confidence: confidence,
-		Verified:   confidence >= 0.85, // Auto-verify high confidence
+		Verified:   confidence >= r.autoVerify, // Auto-verify high confidence
 		CreatedAt:  time.Now(),
 	}
 
@@ -0,0 +1,23 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRegisterFromImageAutoVerify(t *testing.T) {
+	r := NewEvidenceRegistry()
+	id := uuid.New()
+	if ev := r.RegisterFromImage(id, "color", "red", "https://a.example/x.jpg", "", 0.8); ev.Verified {
+		t.Fatalf("0.8 verified at the default threshold %.2f", DefaultAutoVerifyConfidence)
+	}
+
+	r.SetAutoVerifyConfidence(0.75)
+	if ev := r.RegisterFromImage(id, "color", "red", "https://a.example/x.jpg", "", 0.8); !ev.Verified {
+		t.Fatal("0.8 not verified at 0.75")
+	}
+	if ev := r.RegisterFromImage(id, "color", "red", "https://a.example/x.jpg", "", 0.7); ev.Verified {
+		t.Fatal("0.7 verified at 0.75")
+	}
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// gtinLengths are the GTIN formats GMC accepts: GTIN-8 (EAN-8), GTIN-12 (UPC-A),
+// GTIN-13 (EAN-13, ISBN-13) and GTIN-14 (ITF-14)
+var gtinLengths = map[int]bool{8: true, 12: true, 13: true, 14: true}
+
+// gtinRestrictedPrefixes are GS1 prefixes of numbers that never identify a trade item
+// sold across retailers, read on the GTIN-13 form
+var gtinRestrictedPrefixes = []struct {
+	from, to string
+	reason   string
+}{
+	{"020", "029", "restricted circulation number (store-internal)"},
+	{"040", "049", "restricted circulation number (store-internal)"},
+	{"050", "059", "coupon number"},
+	{"200", "299", "restricted circulation number (store-internal)"},
+	{"980", "980", "refund receipt number"},
+	{"981", "984", "coupon number"},
+	{"990", "999", "coupon number"},
+}
+
+// NormalizeGTIN removes the spaces and hyphens feeds often keep in GTINs ("4 006381 33393 1")
+func NormalizeGTIN(value string) string {
+	return strings.NewReplacer(" ", "", "-", "", "\u00a0", "").Replace(strings.TrimSpace(value))
+}
+
+// CheckGTIN validates a GTIN without any lookup: digits only, a GMC length, the GS1
+// check digit, no placeholder (0000000000000, 1234567890128) and no GS1 prefix reserved
+// for store-internal numbers or coupons. It returns why the GTIN is invalid, nil if valid.
+func CheckGTIN(value string) error {
+	gtin := NormalizeGTIN(value)
+	if gtin == "" {
+		return errors.New("empty")
+	}
+	for _, r := range gtin {
+		if r < '0' || r > '9' {
+			return errors.New("must contain digits only")
+		}
+	}
+	if !gtinLengths[len(gtin)] {
+		return fmt.Errorf("%d digits, expected 8, 12, 13 or 14", len(gtin))
+	}
+	if isPlaceholderGTIN(gtin) {
+		return errors.New("placeholder value")
+	}
+	if check := gtinCheckDigit(gtin[:len(gtin)-1]); gtin[len(gtin)-1] != check {
+		return fmt.Errorf("invalid check digit %c, expected %c", gtin[len(gtin)-1], check)
+	}
+	if len(gtin) == 8 {
+		return nil // GTIN-8 prefixes are allocated separately
+	}
+	gtin13 := strings.Repeat("0", 14-len(gtin)) + gtin
+	gtin13 = gtin13[1:] // GTIN-14 packaging indicator dropped
+	for _, p := range gtinRestrictedPrefixes {
+		if prefix := gtin13[:3]; prefix >= p.from && prefix <= p.to {
+			return fmt.Errorf("GS1 prefix %s is a %s", prefix, p.reason)
+		}
+	}
+	return nil
+}
+
+// gtinCheckDigit computes the GS1 check digit of a GTIN without it: from the right,
+// digits are weighted 3, 1, 3, 1...
+func gtinCheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < len(body); i++ {
+		digit := int(body[len(body)-1-i] - '0')
+		if i%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// isPlaceholderGTIN spots the values feeds put in when they have no GTIN: one repeated
+// digit, a zero body, or a run of consecutive digits
+func isPlaceholderGTIN(gtin string) bool {
+	if strings.Count(gtin, gtin[:1]) == len(gtin) {
+		return true
+	}
+	body := gtin[:len(gtin)-1]
+	if strings.Trim(body, "0") == "" {
+		return true
+	}
+	run := strings.TrimLeft(body, "0")
+	return len(run) >= 7 && (strings.Contains("12345678901234567890", run) || strings.Contains("98765432109876543210", run))
+}
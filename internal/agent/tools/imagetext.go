@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Kinds of text read in a product image
+const (
+	ImageTextOverlay   = "overlay"   // added over the photo: banners, badges, prices, slogans
+	ImageTextWatermark = "watermark" // photographer, stock agency or shop mark
+	ImageTextLabel     = "label"     // printed on the product or its packaging
+)
+
+// ImageText is a piece of text read in a product image, transcribed as it appears
+type ImageText struct {
+	Text       string  `json:"text"`
+	Kind       string  `json:"kind"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// ImageTextViolation is text of an image that breaks GMC's image policy: promotional
+// overlays and watermarks get images disapproved, other overlays hurt them
+type ImageTextViolation struct {
+	Text     string `json:"text"`
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"` // high, medium
+	Reason   string `json:"reason"`
+	Match    string `json:"match,omitempty"` // promotional word or pattern found
+}
+
+// imagePromoWords are read as whole words, in English and French
+var imagePromoWords = []string{
+	"sale", "soldes", "promo", "promotion", "discount", "remise", "réduction", "reduction",
+	"free shipping", "livraison gratuite", "livraison offerte", "free", "gratuit", "offert",
+	"offer", "offre", "deal", "bon plan", "best seller", "bestseller", "meilleure vente",
+	"best price", "meilleur prix", "prix choc", "clearance", "déstockage", "destockage",
+	"black friday", "cyber monday", "limited time", "offre limitée", "buy now", "shop now",
+	"order now", "achetez", "commandez", "new", "nouveau", "nouveauté", "exclusive", "exclusif",
+}
+
+// imagePromoPatterns catch discounts and prices, which GMC forbids over product images
+var imagePromoPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-\s?\d{1,2}\s?%`),
+	regexp.MustCompile(`(?i)\d{1,2}\s?%\s?(off|de réduction)`),
+	regexp.MustCompile(`(?i)\d+(?:[.,]\d{1,2})?\s?(€|\$|£|eur\b|usd\b|gbp\b)|[€$£]\s?\d+`),
+}
+
+// CheckImageText checks the text read in an image against GMC's image policy. Text printed
+// on the product or its packaging is part of the product and allowed.
+func CheckImageText(texts []ImageText) []ImageTextViolation {
+	var violations []ImageTextViolation
+	for _, t := range texts {
+		text := strings.TrimSpace(t.Text)
+		if text == "" {
+			continue
+		}
+		switch t.Kind {
+		case ImageTextWatermark:
+			violations = append(violations, ImageTextViolation{
+				Text: text, Kind: t.Kind, Severity: "high",
+				Reason: fmt.Sprintf("watermark %q on the image: GMC disapproves images with watermarks", text),
+			})
+		case ImageTextOverlay:
+			if match := promoMatch(text); match != "" {
+				violations = append(violations, ImageTextViolation{
+					Text: text, Kind: t.Kind, Severity: "high", Match: match,
+					Reason: fmt.Sprintf("promotional text %q over the image (%q): GMC disapproves promotional overlays", text, match),
+				})
+				continue
+			}
+			violations = append(violations, ImageTextViolation{
+				Text: text, Kind: t.Kind, Severity: "medium",
+				Reason: fmt.Sprintf("text %q over the image: GMC asks for images without overlays", text),
+			})
+		}
+	}
+	return violations
+}
+
+// promoMatch returns the promotional word or pattern found in text, "" when none
+func promoMatch(text string) string {
+	lower := strings.ToLower(text)
+	for _, word := range imagePromoWords {
+		if containsWord(lower, word) {
+			return word
+		}
+	}
+	for _, re := range imagePromoPatterns {
+		if m := re.FindString(text); m != "" {
+			return m
+		}
+	}
+	return ""
+}
+
+// containsWord reports whether s contains word not inside a longer word ("sale" is not in
+// "wholesale")
+func containsWord(s, word string) bool {
+	for from := 0; ; {
+		i := strings.Index(s[from:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := from+i, from+i+len(word)
+		if !letterBefore(s, start) && !letterAfter(s, end) {
+			return true
+		}
+		from = start + 1
+	}
+}
+
+func letterBefore(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	r := []rune(s[:i])
+	return unicode.IsLetter(r[len(r)-1])
+}
+
+func letterAfter(s string, i int) bool {
+	for _, r := range s[i:] {
+		return unicode.IsLetter(r)
+	}
+	return false
+}
+
+// Ways a product identifier was read in an image
+const (
+	ImageCodeBarcode = "barcode" // decoded from the bars, check digit verified
+	ImageCodeVision  = "vision"  // printed digits or reference read by the vision model
+)
+
+// ImageCode is a product identifier (gtin or mpn) read in a product image: packaging shots
+// often show the barcode or the model number
+type ImageCode struct {
+	Field      string  `json:"field"`
+	Value      string  `json:"value"`
+	ImageURL   string  `json:"image_url"`
+	Method     string  `json:"method"`
+	Confidence float64 `json:"confidence"`
+}
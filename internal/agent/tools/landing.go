@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/structdata"
+	"github.com/benjamincozon/feedenrich/internal/websearch"
+)
+
+// LandingMismatch is a feed value that differs from the product's landing page, which GMC
+// disapproves the product for
+type LandingMismatch struct {
+	Field       string `json:"field"`
+	Feed        string `json:"feed"`
+	Page        string `json:"page"`
+	Evidence    string `json:"evidence"` // where the page value was read: "json-ld Product.offers.price"
+	Description string `json:"description"`
+}
+
+// LandingCheck is the result of CheckLandingPage
+type LandingCheck struct {
+	URL        string            `json:"url"`
+	Facts      []structdata.Fact `json:"facts"` // the structured data of the page
+	Mismatches []LandingMismatch `json:"mismatches"`
+	Error      string            `json:"error,omitempty"` // the page couldn't be checked
+}
+
+// CheckLandingPage fetches the product's link and compares the price and availability its
+// structured data (JSON-LD, microdata, OpenGraph) gives with the feed's. Pages without
+// structured data have nothing to compare: only what the merchant publishes for search
+// engines is read, never the page text.
+func CheckLandingPage(ctx context.Context, cfg *config.Config, productData json.RawMessage) LandingCheck {
+	var data map[string]interface{}
+	json.Unmarshal(productData, &data)
+	check := LandingCheck{URL: strings.TrimSpace(getFieldValue(data, "link")), Mismatches: []LandingMismatch{}}
+	if u, err := url.Parse(check.URL); check.URL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		check.Error = "no valid link"
+		return check
+	}
+
+	page, err := websearch.For(cfg).Fetch(ctx, check.URL)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	if page.StatusCode != http.StatusOK {
+		check.Error = fmt.Sprintf("HTTP %d", page.StatusCode)
+		return check
+	}
+	check.Facts = structdata.Extract(page.Body)
+	check.Mismatches = CompareLandingPage(productData, check.Facts)
+	return check
+}
+
+// landingPriceTolerance absorbs rounding between the feed and the page
+const landingPriceTolerance = 0.01
+
+// CompareLandingPage compares the feed's price and availability with the facts of its
+// landing page. The page price may be the price or the sale price of the feed; the
+// mismatch is reported on sale_price when the feed has one, as it is the price shown.
+func CompareLandingPage(productData json.RawMessage, facts []structdata.Fact) []LandingMismatch {
+	mismatches := []LandingMismatch{}
+	var data map[string]interface{}
+	if err := json.Unmarshal(productData, &data); err != nil {
+		return mismatches
+	}
+	page := map[string]structdata.Fact{}
+	for _, f := range facts {
+		page[f.Field] = f
+	}
+	mismatch := func(field, feed string, fact structdata.Fact, format string, args ...any) {
+		mismatches = append(mismatches, LandingMismatch{
+			Field:       field,
+			Feed:        feed,
+			Page:        fact.Value,
+			Evidence:    fact.Source + " " + fact.Path,
+			Description: fmt.Sprintf(format, args...) + fmt.Sprintf(" (read from %s %s)", fact.Source, fact.Path),
+		})
+	}
+
+	if fact, ok := page["price"]; ok {
+		priceValue := strings.TrimSpace(getFieldValue(data, "price"))
+		saleValue := strings.TrimSpace(getFieldValue(data, "sale_price"))
+		field, shown := "price", priceValue
+		if saleValue != "" {
+			field, shown = "sale_price", saleValue
+		}
+		feedCurrency := currencyIn(shown)
+		if feedCurrency == "" {
+			feedCurrency = currencyIn(priceValue)
+		}
+		pagePrice, err := ParsePrice(fact.Value, feedCurrency)
+		if shown != "" && err == nil {
+			matches := false
+			for _, value := range []string{priceValue, saleValue} {
+				if p, err := ParsePrice(value, feedCurrency); err == nil && p.Currency == pagePrice.Currency &&
+					math.Abs(p.Value-pagePrice.Value) <= landingPriceTolerance {
+					matches = true
+				}
+			}
+			if !matches {
+				mismatch(field, shown, fact, "%s is %s in the feed but %s on the landing page", field, shown, pagePrice)
+			}
+		}
+	}
+
+	if fact, ok := page["availability"]; ok {
+		feedValue := strings.TrimSpace(getFieldValue(data, "availability"))
+		feed, feedOK := NormalizeAvailability(feedValue)
+		onPage, pageOK := NormalizeAvailability(fact.Value)
+		if feedOK && pageOK && feed != onPage {
+			mismatch("availability", feedValue, fact, "availability is %s in the feed but %s on the landing page", feed, onPage)
+		}
+	}
+	return mismatches
+}
+
+// CheckLandingPageTool compares the feed's price and availability with the product page
+type CheckLandingPageTool struct {
+	config *config.Config
+}
+
+func (t *CheckLandingPageTool) Name() string { return "check_landing_page" }
+
+func (t *CheckLandingPageTool) Description() string {
+	return "Fetch the product's link and compare the price and availability of its structured data (JSON-LD, microdata, OpenGraph) with the feed. Mismatches can't be fixed from here: add them to issues for human review."
+}
+
+func (t *CheckLandingPageTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+	}
+}
+
+func (t *CheckLandingPageTool) Execute(ctx context.Context, input json.RawMessage, session SessionContext) (any, error) {
+	return CheckLandingPage(ctx, t.config, session.GetProductData()), nil
+}
@@ -6,12 +6,12 @@ import (
 	"fmt"
 
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 )
 
 // OptimizeFieldTool generates improved versions of product fields
 type OptimizeFieldTool struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
 }
 
@@ -154,22 +154,21 @@ Retourne un JSON avec:
 
 Retourne UNIQUEMENT le JSON.`, fieldSpecificRules, params.Field, params.CurrentValue, string(contextJSON), string(constraintsJSON))
 
-	resp, err := t.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: t.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: prompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	resp, err := t.client.Chat(ctx, llm.Request{
+		Model: t.config.LLM.Model,
+		Stage: llm.StageOptimizeField,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
+		JSON: true,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("openai optimize: %w", err)
+		return nil, fmt.Errorf("llm optimize: %w", err)
 	}
 
 	var result OptimizeFieldOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
-		return json.RawMessage(resp.Choices[0].Message.Content), nil
+	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
+		return json.RawMessage(resp.Content), nil
 	}
 
 	return result, nil
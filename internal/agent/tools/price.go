@@ -0,0 +1,298 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Price is an amount with its ISO 4217 currency
+type Price struct {
+	Value    float64 `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+// String is the GMC price format: "29.99 EUR", "1299 JPY"
+func (p Price) String() string {
+	if zeroDecimalCurrencies[p.Currency] {
+		return fmt.Sprintf("%.0f %s", p.Value, p.Currency)
+	}
+	return fmt.Sprintf("%.2f %s", p.Value, p.Currency)
+}
+
+// currencySymbols are read before the ISO codes, longest first so "US$" is not read as "$"
+var currencySymbols = []struct{ symbol, code string }{
+	{"US$", "USD"}, {"CA$", "CAD"}, {"AU$", "AUD"}, {"NZ$", "NZD"}, {"HK$", "HKD"},
+	{"R$", "BRL"}, {"C$", "CAD"}, {"A$", "AUD"}, {"zł", "PLN"},
+	{"€", "EUR"}, {"£", "GBP"}, {"¥", "JPY"}, {"₹", "INR"}, {"$", "USD"},
+}
+
+// currencyCodes are the ISO 4217 codes recognized in prices
+var currencyCodes = map[string]bool{
+	"EUR": true, "USD": true, "GBP": true, "CHF": true, "JPY": true, "CAD": true, "AUD": true,
+	"NZD": true, "SEK": true, "NOK": true, "DKK": true, "PLN": true, "CZK": true, "HUF": true,
+	"RON": true, "BGN": true, "BRL": true, "MXN": true, "INR": true, "CNY": true, "HKD": true,
+	"SGD": true, "ZAR": true, "TRY": true, "KRW": true, "AED": true, "SAR": true, "ILS": true,
+}
+
+var zeroDecimalCurrencies = map[string]bool{"JPY": true, "KRW": true}
+
+// ParsePrice reads a feed price such as "29,99€", "EUR 29.99", "$1,299.00" or
+// "1 299,00 EUR". A lone separator followed by exactly three digits is a thousands
+// separator ("1,299"), otherwise it is the decimal one. Without a currency in the value,
+// fallback is used (e.g. the currency of the other price field); "" makes it an error.
+func ParsePrice(value, fallback string) (Price, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return Price{}, errors.New("empty")
+	}
+
+	currency := ""
+	for _, cs := range currencySymbols {
+		if strings.Contains(s, cs.symbol) {
+			currency = cs.code
+			s = strings.Replace(s, cs.symbol, " ", 1)
+			break
+		}
+	}
+	var number strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		code := strings.ToUpper(word)
+		if !currencyCodes[code] {
+			return Price{}, fmt.Errorf("unrecognized text %q", word)
+		}
+		if currency != "" && currency != code {
+			return Price{}, fmt.Errorf("two currencies (%s and %s)", currency, code)
+		}
+		currency = code
+	}
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',':
+			number.WriteRune(r)
+		case r == '-':
+			return Price{}, errors.New("negative amount")
+		}
+		// spaces, NBSP and apostrophes are thousands separators, letters the currency
+	}
+	if currency == "" {
+		currency = fallback
+	}
+	if currency == "" {
+		return Price{}, errors.New("no currency")
+	}
+
+	amount, err := parseAmount(number.String())
+	if err != nil {
+		return Price{}, err
+	}
+	return Price{Value: amount, Currency: currency}, nil
+}
+
+// parseAmount reads an amount with "." or "," as decimal or thousands separators
+func parseAmount(s string) (float64, error) {
+	if s == "" || strings.Trim(s, ".,") == "" {
+		return 0, errors.New("no amount")
+	}
+	dot, comma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	decimal := -1
+	switch {
+	case dot >= 0 && comma >= 0:
+		decimal = max(dot, comma) // "1,299.00", "1.299,00"
+	case dot >= 0 || comma >= 0:
+		sep := max(dot, comma)
+		lone := strings.Count(s, string(s[sep])) == 1
+		if lone && len(s)-sep-1 != 3 {
+			decimal = sep // "29,99", "29.9"
+		}
+	}
+	var digits strings.Builder
+	for i, r := range s {
+		switch {
+		case i == decimal:
+			digits.WriteRune('.')
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		}
+	}
+	amount, err := strconv.ParseFloat(digits.String(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
+}
+
+// effectiveDateLayouts are the ISO 8601 forms read in sale_price_effective_date
+var effectiveDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// effectiveDateFormat is the GMC form of each end of sale_price_effective_date
+const effectiveDateFormat = "2006-01-02T15:04-0700"
+
+// ParseEffectiveDate reads a sale_price_effective_date range ("start/end", ISO 8601).
+// Ends without a time cover their whole day; ends without an offset are UTC.
+func ParseEffectiveDate(value string) (start, end time.Time, err error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 {
+		return start, end, errors.New("expected an ISO 8601 range start/end")
+	}
+	if start, err = parseISODate(parts[0], false); err != nil {
+		return start, end, err
+	}
+	if end, err = parseISODate(parts[1], true); err != nil {
+		return start, end, err
+	}
+	if !end.After(start) {
+		return start, end, errors.New("range ends before it starts")
+	}
+	return start, end, nil
+}
+
+func parseISODate(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range effectiveDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" && endOfDay {
+			t = t.Add(24*time.Hour - time.Minute)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not an ISO 8601 date", value)
+}
+
+// PriceFix is a format fix of a price field, made without any model call
+type PriceFix struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+	Reason string `json:"reason"`
+}
+
+// PriceIssue is a price problem that needs a human: a value that can't be parsed, a sale
+// price not below the price, an effective date range that is not valid
+type PriceIssue struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// PriceCheck is the result of CheckPrices
+type PriceCheck struct {
+	Fixes  []PriceFix   `json:"fixes"`
+	Issues []PriceIssue `json:"issues"`
+}
+
+// CheckPrices parses price, sale_price and sale_price_effective_date and returns the
+// format fixes to GMC form ("29,99€" → "29.99 EUR") along with the problems no format
+// fix can solve: a value that can't be read, a sale price in another currency or not
+// below the price, a zero price, an effective date range that is not valid.
+func CheckPrices(productData json.RawMessage) PriceCheck {
+	check := PriceCheck{Fixes: []PriceFix{}, Issues: []PriceIssue{}}
+	var data map[string]interface{}
+	if err := json.Unmarshal(productData, &data); err != nil {
+		return check
+	}
+	issue := func(field, format string, args ...any) {
+		check.Issues = append(check.Issues, PriceIssue{Field: field, Description: fmt.Sprintf(format, args...)})
+	}
+	fix := func(field, before, after, reason string) {
+		if before != after {
+			check.Fixes = append(check.Fixes, PriceFix{Field: field, Before: before, After: after, Reason: reason})
+		}
+	}
+
+	priceValue := strings.TrimSpace(getFieldValue(data, "price"))
+	saleValue := strings.TrimSpace(getFieldValue(data, "sale_price"))
+	prices := map[string]*Price{}
+	for _, f := range []struct{ field, value, other string }{
+		{"price", priceValue, saleValue},
+		{"sale_price", saleValue, priceValue},
+	} {
+		if f.value == "" {
+			continue
+		}
+		p, err := ParsePrice(f.value, currencyIn(f.other))
+		if err != nil {
+			issue(f.field, "%s %q can't be read: %v", f.field, f.value, err)
+			continue
+		}
+		prices[f.field] = &p
+		fix(f.field, f.value, p.String(), "Price in GMC format: amount with a decimal point, then the ISO 4217 currency")
+	}
+
+	price, sale := prices["price"], prices["sale_price"]
+	if price != nil && price.Value == 0 {
+		issue("price", "price is 0")
+	}
+	if price != nil && sale != nil {
+		switch {
+		case price.Currency != sale.Currency:
+			issue("sale_price", "sale_price is in %s, price in %s", sale.Currency, price.Currency)
+		case sale.Value >= price.Value:
+			issue("sale_price", "sale_price %s is not below price %s", sale, price)
+		}
+	}
+
+	if value := strings.TrimSpace(getFieldValue(data, "sale_price_effective_date")); value != "" {
+		start, end, err := ParseEffectiveDate(value)
+		if err != nil {
+			issue("sale_price_effective_date", "sale_price_effective_date %q: %v", value, err)
+		} else {
+			fix("sale_price_effective_date", value, start.Format(effectiveDateFormat)+"/"+end.Format(effectiveDateFormat),
+				"Effective date range in GMC format: ISO 8601 start/end with time and UTC offset")
+		}
+	}
+	return check
+}
+
+// currencyIn returns the currency written in a price, "" if there is none
+func currencyIn(value string) string {
+	p, err := ParsePrice(value, "")
+	if err != nil {
+		return ""
+	}
+	return p.Currency
+}
+
+// NormalizePriceTool proposes the price format fixes of CheckPrices, without an LLM
+type NormalizePriceTool struct{}
+
+func (t *NormalizePriceTool) Name() string { return "normalize_price" }
+
+func (t *NormalizePriceTool) Description() string {
+	return "Parse price, sale_price and sale_price_effective_date deterministically, propose their GMC format fixes (e.g. '29,99€' → '29.99 EUR') and report price problems (sale price not below price, invalid date range). Use it instead of optimize_field for these fields."
+}
+
+func (t *NormalizePriceTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+	}
+}
+
+func (t *NormalizePriceTool) Execute(ctx context.Context, input json.RawMessage, session SessionContext) (any, error) {
+	check := CheckPrices(session.GetProductData())
+	for _, f := range check.Fixes {
+		session.AddProposal(f.Field, f.Before, f.After, []Source{{
+			Type:       "feed",
+			Reference:  f.Field,
+			Evidence:   f.Before,
+			Confidence: 1,
+		}}, 1, "low")
+	}
+	return check, nil
+}
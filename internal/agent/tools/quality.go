@@ -0,0 +1,316 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/productdata"
+)
+
+// QualityScorer rates proposals 0-100 so the review queue can surface the likely
+// good ones first. The deterministic part is always computed; an LLM judge can be
+// blended in for readability/fact support on top of it.
+type QualityScorer struct {
+	validator *HardRuleValidator
+	client    llm.Client // nil = deterministic only
+	model     string
+}
+
+// ProposalCandidate is the minimal proposal shape the scorer needs
+type ProposalCandidate struct {
+	Field      string   `json:"field"`
+	Before     string   `json:"before"`
+	After      string   `json:"after"`
+	Rationale  []string `json:"rationale,omitempty"`
+	Sources    []Source `json:"sources,omitempty"`
+	Confidence float64  `json:"confidence"`
+}
+
+// QualityScore is the score of one proposal with its components (each 0-100)
+type QualityScore struct {
+	Score          int      `json:"score"`
+	RuleCompliance int      `json:"rule_compliance"`
+	FactSupport    int      `json:"fact_support"`
+	Readability    int      `json:"readability"`
+	Judge          *int     `json:"judge,omitempty"` // LLM judge score when enabled
+	Issues         []string `json:"issues,omitempty"`
+}
+
+// Component weights of the deterministic score
+const (
+	weightRules       = 0.40
+	weightFacts       = 0.35
+	weightReadability = 0.25
+	weightJudge       = 0.40 // share of the final score given to the LLM judge
+)
+
+// NewQualityScorer creates a scorer; pass a nil client to disable the LLM judge
+// (config.Load defaults the model to the provider's fast model)
+func NewQualityScorer(client llm.Client, model string) *QualityScorer {
+	return &QualityScorer{
+		validator: NewHardRuleValidator(),
+		client:    client,
+		model:     model,
+	}
+}
+
+// Score rates proposals against the product data they apply to. The judge, when enabled,
+// scores all proposals of the product in a single call; if it fails, deterministic scores are kept.
+// The judge's response is returned for its usage, nil when it wasn't called or answered.
+func (s *QualityScorer) Score(ctx context.Context, productData json.RawMessage, proposals []ProposalCandidate) ([]QualityScore, *llm.Response) {
+	scores := make([]QualityScore, len(proposals))
+	for i, p := range proposals {
+		scores[i] = s.deterministic(productData, p)
+	}
+
+	var resp *llm.Response
+	if s.client != nil && len(proposals) > 0 {
+		var judged map[int]int
+		var err error
+		judged, resp, err = s.judge(ctx, productData, proposals)
+		if err != nil {
+			fmt.Printf("Quality judge failed, keeping deterministic scores: %v\n", err)
+			return scores, resp
+		}
+		for i := range scores {
+			if j, ok := judged[i]; ok {
+				j = clampScore(j)
+				scores[i].Judge = &j
+				scores[i].Score = int(math.Round(float64(scores[i].Score)*(1-weightJudge) + float64(j)*weightJudge))
+			}
+		}
+	}
+	return scores, resp
+}
+
+func (s *QualityScorer) deterministic(productData json.RawMessage, p ProposalCandidate) QualityScore {
+	q := QualityScore{}
+	q.RuleCompliance = s.ruleCompliance(productData, p, &q.Issues)
+	q.FactSupport = factSupport(p, &q.Issues)
+	q.Readability = readability(p, &q.Issues)
+	q.Score = int(math.Round(
+		float64(q.RuleCompliance)*weightRules +
+			float64(q.FactSupport)*weightFacts +
+			float64(q.Readability)*weightReadability))
+	return q
+}
+
+// ruleCompliance re-validates the product with the proposal applied and penalises
+// violations on the proposed field
+func (s *QualityScorer) ruleCompliance(productData json.RawMessage, p ProposalCandidate, issues *[]string) int {
+	var data map[string]interface{}
+	if err := json.Unmarshal(productData, &data); err != nil || data == nil {
+		data = map[string]interface{}{}
+	}
+	data[p.Field] = p.After
+	applied, _ := json.Marshal(data)
+
+	score := 100
+	result := s.validator.Validate(applied)
+	for _, v := range result.Violations {
+		if v.Field == p.Field {
+			score -= 50
+			*issues = append(*issues, v.Message)
+		}
+	}
+	for _, v := range result.Warnings {
+		if v.Field == p.Field {
+			score -= 15
+			*issues = append(*issues, v.Message)
+		}
+	}
+	return clampScore(score)
+}
+
+// factSupport rewards confident, sourced proposals; pure inference is capped
+func factSupport(p ProposalCandidate, issues *[]string) int {
+	score := int(math.Round(p.Confidence * 100))
+
+	sourced := false
+	inferredOnly := len(p.Sources) > 0
+	for _, src := range p.Sources {
+		if src.Reference != "" || src.Evidence != "" || (src.Type != "" && src.Type != "inferred") {
+			sourced = true
+		}
+		if src.Type != "inferred" && src.Type != "" {
+			inferredOnly = false
+		}
+	}
+	if inferredOnly {
+		if score > 70 {
+			score = 70
+		}
+		*issues = append(*issues, "value is inferred, not backed by feed/image/web evidence")
+	} else if sourced {
+		score += 10
+	}
+	if len(p.Rationale) == 0 || strings.TrimSpace(strings.Join(p.Rationale, "")) == "" {
+		score -= 10
+		*issues = append(*issues, "no rationale")
+	}
+	return clampScore(score)
+}
+
+// textFields get prose checks; other fields are expected to be short attribute values
+var textFields = map[string]bool{
+	"title":             true,
+	"description":       true,
+	"product_highlight": true,
+	"product_detail":    true,
+}
+
+// readability checks for shouting, repetition, placeholders and sensible lengths
+func readability(p ProposalCandidate, issues *[]string) int {
+	value := strings.TrimSpace(p.After)
+	if value == "" {
+		*issues = append(*issues, "empty value")
+		return 0
+	}
+	score := 100
+	lower := strings.ToLower(value)
+
+	for _, placeholder := range []string{"n/a", "unknown", "lorem ipsum", "todo", "tbd", "xxx", "null", "undefined"} {
+		if lower == placeholder || (textFields[p.Field] && strings.Contains(lower, placeholder)) {
+			score -= 60
+			*issues = append(*issues, "placeholder text")
+			break
+		}
+	}
+
+	letters, upper := 0, 0
+	for _, r := range value {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters >= 10 && float64(upper)/float64(letters) > 0.6 {
+		score -= 25
+		*issues = append(*issues, "mostly upper case")
+	}
+
+	words := strings.Fields(lower)
+	if repeated := repeatedWords(words); repeated > 0 {
+		score -= 10 * repeated
+		*issues = append(*issues, "repeated words")
+	}
+
+	if textFields[p.Field] {
+		if strings.Contains(value, "!!") || strings.Contains(value, "??") {
+			score -= 10
+			*issues = append(*issues, "repeated punctuation")
+		}
+		if p.Field == "description" {
+			if avg := averageSentenceWords(value); avg > 35 {
+				score -= 15
+				*issues = append(*issues, "very long sentences")
+			}
+		}
+		// A rewrite that only shrinks the text is rarely an improvement; the original is
+		// measured without its HTML, so a cleanup is not taken for a cut
+		before := productdata.CleanText(p.Before)
+		if before != "" && len([]rune(value)) < len([]rune(before))/2 {
+			score -= 20
+			*issues = append(*issues, "much shorter than the original")
+		}
+	} else if len([]rune(value)) > 60 {
+		score -= 30
+		*issues = append(*issues, "attribute value too long")
+	}
+
+	return clampScore(score)
+}
+
+// repeatedWords counts consecutive duplicate words ("the the")
+func repeatedWords(words []string) int {
+	n := 0
+	for i := 1; i < len(words); i++ {
+		if words[i] == words[i-1] && len(words[i]) > 2 {
+			n++
+		}
+	}
+	return n
+}
+
+func averageSentenceWords(text string) float64 {
+	sentences := strings.FieldsFunc(text, func(r rune) bool { return r == '.' || r == '!' || r == '?' })
+	total, count := 0, 0
+	for _, s := range sentences {
+		if w := len(strings.Fields(s)); w > 0 {
+			total += w
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return float64(total) / float64(count)
+}
+
+func clampScore(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 100 {
+		return 100
+	}
+	return v
+}
+
+// judge asks the LLM to rate every proposal of a product; returns scores by index
+func (s *QualityScorer) judge(ctx context.Context, productData json.RawMessage, proposals []ProposalCandidate) (map[int]int, *llm.Response, error) {
+	type item struct {
+		Index  int    `json:"index"`
+		Field  string `json:"field"`
+		Before string `json:"before"`
+		After  string `json:"after"`
+	}
+	items := make([]item, len(proposals))
+	for i, p := range proposals {
+		items[i] = item{Index: i, Field: p.Field, Before: p.Before, After: p.After}
+	}
+	itemsJSON, _ := json.Marshal(items)
+
+	systemPrompt := `You review product feed changes for Google Merchant Center.
+Rate each proposed change from 0 to 100 considering:
+- rule compliance (GMC policies, no promotional text, correct format for the attribute)
+- fact support (is the new value supported by the product data, or invented?)
+- readability (natural, well-formed, not keyword-stuffed)
+Respond with JSON: {"scores": [{"index": 0, "score": 85}, ...]}`
+
+	var out struct {
+		Scores []struct {
+			Index int `json:"index"`
+			Score int `json:"score"`
+		} `json:"scores"`
+	}
+	resp, err := s.client.Structured(ctx, llm.Request{
+		Model: s.model,
+		Stage: llm.StageJudge,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: systemPrompt},
+			{Role: llm.RoleUser, Content: fmt.Sprintf("Product data:\n%s\n\nProposed changes:\n%s", string(productData), string(itemsJSON))},
+		},
+	}, &out)
+	if resp == nil {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, resp, fmt.Errorf("parse judge response: %w", err)
+	}
+
+	judged := map[int]int{}
+	for _, sc := range out.Scores {
+		if sc.Index >= 0 && sc.Index < len(proposals) {
+			judged[sc.Index] = sc.Score
+		}
+	}
+	return judged, resp, nil
+}
@@ -65,7 +65,13 @@ func NewRiskClassifier() *RiskClassifier {
 	}
 }
 
-// AssessChange evaluates the risk of a proposed change
+// IsHighRiskField reports whether changes to a field always carry a high risk
+func (r *RiskClassifier) IsHighRiskField(field string) bool {
+	return r.highRiskFields[strings.ToLower(field)]
+}
+
+// AssessChange evaluates the risk of a proposed change. sourceType is where the new value
+// comes from: feed, web, image, SourceTrusted (a trusted domain) or mixed.
 func (r *RiskClassifier) AssessChange(field, before, after string, sourceType string, confidence float64) *RiskAssessment {
 	assessment := &RiskAssessment{
 		Level:       "low",
@@ -129,11 +135,30 @@ func (r *RiskClassifier) AssessChange(field, before, after string, sourceType st
 		assessment.Reasons = append(assessment.Reasons, "near-complete rewrite")
 	}
 
+	// Facts read on a trusted domain (SourceTrusted) make a high-risk field, or a value
+	// added where there was none, medium risk; new claims, low confidence and rewrites of an
+	// existing value keep their risk
+	if sourceType == SourceTrusted && assessment.Level == "high" {
+		lowered := true
+		for _, reason := range assessment.Reasons {
+			added := before == "" && (reason == "near-complete rewrite" || reason == "significant content change")
+			lowered = lowered && (added || strings.HasPrefix(reason, "high-risk field: "))
+		}
+		if lowered {
+			assessment.Level = "medium"
+			assessment.RequiresHuman = false
+			assessment.Reasons = append(assessment.Reasons, "sourced from a trusted domain")
+		}
+	}
+
 	// Low risk indicators
 	if assessment.Level == "low" && len(assessment.Reasons) == 0 {
 		if sourceType == "feed" {
 			assessment.Reasons = append(assessment.Reasons, "data from original feed")
 		}
+		if sourceType == SourceTrusted {
+			assessment.Reasons = append(assessment.Reasons, "sourced from a trusted domain")
+		}
 		if confidence >= 0.9 {
 			assessment.Reasons = append(assessment.Reasons, "high confidence")
 		}
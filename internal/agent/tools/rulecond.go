@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/taxonomy"
+)
+
+// RuleCondition restricts a rule to the products it holds for, e.g. a size rule to
+// google_product_category starting with "Apparel & Accessories > Clothing". Value is a
+// string or a list of strings: positive operators hold when one value matches, negative
+// ones (neq, not_contains, not_starts_with) when none does. Comparisons ignore case.
+type RuleCondition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"` // eq, neq, contains, not_contains, starts_with, not_starts_with, in, missing, present
+	Value any    `json:"value,omitempty"`
+}
+
+// ruleConditionOps are the operators of RuleCondition, with whether they are negative
+var ruleConditionOps = map[string]bool{
+	"eq": false, "in": false, "contains": false, "starts_with": false,
+	"neq": true, "not_contains": true, "not_starts_with": true,
+	"missing": false, "present": false,
+}
+
+// Validate reports a condition that can't be evaluated: no field, an unknown operator or
+// a missing value
+func (c RuleCondition) Validate() error {
+	if strings.TrimSpace(c.Field) == "" {
+		return fmt.Errorf("field is required")
+	}
+	if _, ok := ruleConditionOps[c.Op]; !ok {
+		return fmt.Errorf("invalid op %q", c.Op)
+	}
+	if c.Op != "missing" && c.Op != "present" && len(ruleConditionValues(c.Value)) == 0 {
+		return fmt.Errorf("op %s needs a value", c.Op)
+	}
+	return nil
+}
+
+// Holds evaluates the condition on product data. A google_product_category ID is compared
+// as its taxonomy path, so "1604" starts with "Apparel & Accessories > Clothing".
+func (c RuleCondition) Holds(data map[string]interface{}) bool {
+	value := strings.TrimSpace(getFieldValue(data, c.Field))
+	switch c.Op {
+	case "missing":
+		return value == ""
+	case "present":
+		return value != ""
+	}
+	if strings.EqualFold(c.Field, "google_product_category") {
+		if category, ok := taxonomy.Bundled().Lookup(value); ok {
+			value = category.Name()
+		}
+	}
+	value = strings.ToLower(value)
+
+	negative, ok := ruleConditionOps[c.Op]
+	if !ok {
+		return false
+	}
+	for _, want := range ruleConditionValues(c.Value) {
+		want = strings.ToLower(want)
+		var match bool
+		switch c.Op {
+		case "eq", "neq", "in":
+			match = value == want
+		case "contains", "not_contains":
+			match = strings.Contains(value, want)
+		case "starts_with", "not_starts_with":
+			match = strings.HasPrefix(value, want)
+		}
+		if match {
+			return !negative
+		}
+	}
+	return negative
+}
+
+// ruleConditionValues reads the value of a condition as a list of non-empty strings
+func ruleConditionValues(v any) []string {
+	var values []string
+	switch val := v.(type) {
+	case []string:
+		values = val
+	case []interface{}:
+		for _, x := range val {
+			values = append(values, toString(x))
+		}
+	case nil:
+	default:
+		values = []string{toString(val)}
+	}
+	out := values[:0:0]
+	for _, s := range values {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// appliesTo reports whether a rule is checked on a product: its vertical matches and all
+// its conditions hold
+func (r ValidationRule) appliesTo(data map[string]interface{}, vertical Vertical) bool {
+	if r.Vertical != "" && r.Vertical != vertical {
+		return false
+	}
+	for _, c := range r.When {
+		if !c.Holds(data) {
+			return false
+		}
+	}
+	return true
+}
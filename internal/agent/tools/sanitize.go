@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/productdata"
+)
+
+// TextFix is a cleanup of a text field made without any model call
+type TextFix struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+	Reason string `json:"reason"`
+}
+
+// htmlPattern finds HTML tags and entities in a value
+var htmlPattern = regexp.MustCompile(`<[^>]*>|&(#[0-9]+|#x[0-9a-fA-F]+|[a-zA-Z]+);`)
+
+// cleanedFields are the text fields CheckText cleans; only descriptions are deshouted, as
+// titles carry model names and brands in capitals
+var cleanedFields = []struct {
+	field   string
+	deshout bool
+}{
+	{"title", false},
+	{"description", true},
+}
+
+// CleanText returns the cleaned form of a text field: HTML tags and entities stripped and
+// whitespace normalized (productdata.CleanText), and for descriptions ALL-CAPS shouting in
+// sentence case, the brand kept as written (productdata.Deshout). The reason lists what changed.
+func CleanText(field, value, brand string) (string, string) {
+	cleaned := productdata.CleanText(value)
+	var reasons []string
+	if cleaned != value {
+		if htmlPattern.MatchString(value) {
+			reasons = append(reasons, "HTML removed")
+		} else {
+			reasons = append(reasons, "whitespace normalized")
+		}
+	}
+	for _, f := range cleanedFields {
+		if f.deshout && strings.EqualFold(f.field, field) {
+			if deshouted := productdata.Deshout(cleaned, brand); deshouted != cleaned {
+				cleaned = deshouted
+				reasons = append(reasons, "ALL-CAPS text in sentence case")
+			}
+		}
+	}
+	return cleaned, strings.Join(reasons, ", ")
+}
+
+// CheckText cleans the title and description of a product; fields already clean get no fix
+func CheckText(productData json.RawMessage) []TextFix {
+	var data map[string]interface{}
+	if err := json.Unmarshal(productData, &data); err != nil {
+		return nil
+	}
+	fixes := []TextFix{}
+	brand := getFieldValue(data, "brand")
+	for _, f := range cleanedFields {
+		current := getFieldValue(data, f.field)
+		if strings.TrimSpace(current) == "" {
+			continue
+		}
+		if cleaned, reason := CleanText(f.field, current, brand); cleaned != current && cleaned != "" {
+			fixes = append(fixes, TextFix{Field: f.field, Before: current, After: cleaned, Reason: reason})
+		}
+	}
+	return fixes
+}
+
+// CleanTextTool strips HTML, normalizes whitespace and deshouts descriptions, without an LLM
+type CleanTextTool struct{}
+
+func (t *CleanTextTool) Name() string { return "clean_text" }
+
+func (t *CleanTextTool) Description() string {
+	return "Clean title and description deterministically: strip HTML tags and entities, normalize whitespace and write ALL-CAPS shouting in descriptions in sentence case, and propose the cleaned values (low risk). Run it before optimize_field; it never rewrites content."
+}
+
+func (t *CleanTextTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+	}
+}
+
+type CleanTextOutput struct {
+	Fixes []TextFix `json:"fixes"`
+}
+
+func (t *CleanTextTool) Execute(ctx context.Context, input json.RawMessage, session SessionContext) (any, error) {
+	fixes := CheckText(session.GetProductData())
+	for _, f := range fixes {
+		session.AddProposal(f.Field, f.Before, f.After, []Source{{
+			Type:       "feed",
+			Reference:  f.Field,
+			Evidence:   f.Reason,
+			Confidence: 1,
+		}}, 1, "low")
+	}
+	return CleanTextOutput{Fixes: fixes}, nil
+}
@@ -1,16 +1,19 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/pdftext"
+	"github.com/benjamincozon/feedenrich/internal/structdata"
+	"github.com/benjamincozon/feedenrich/internal/websearch"
 	"golang.org/x/net/html"
 )
 
@@ -60,6 +63,7 @@ type SearchResult struct {
 
 type WebSearchOutput struct {
 	Results []SearchResult `json:"results"`
+	Error   string         `json:"error,omitempty"` // searches are paused: quota used up or provider failing
 }
 
 func (t *WebSearchTool) Execute(ctx context.Context, input json.RawMessage, session SessionContext) (any, error) {
@@ -82,8 +86,11 @@ func (t *WebSearchTool) Execute(ctx context.Context, input json.RawMessage, sess
 		numResults = 5
 	}
 
-	// Use Brave Search API
-	results, err := t.searchWithBrave(ctx, query, numResults)
+	// Use the configured search provider (WEBSEARCH_PROVIDER)
+	results, err := t.search(ctx, query, numResults)
+	if websearch.Unavailable(err) {
+		return WebSearchOutput{Results: []SearchResult{}, Error: err.Error()}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -91,53 +98,15 @@ func (t *WebSearchTool) Execute(ctx context.Context, input json.RawMessage, sess
 	return WebSearchOutput{Results: results}, nil
 }
 
-func (t *WebSearchTool) searchWithBrave(ctx context.Context, query string, numResults int) ([]SearchResult, error) {
-	if t.config.WebSearch.APIKey == "" {
-		// Fallback: return empty results if no API key
-		return []SearchResult{}, nil
-	}
-
-	// Brave Search API
-	searchURL := fmt.Sprintf("https://api.search.brave.com/res/v1/web/search?q=%s&count=%d&extra_snippets=true",
-		url.QueryEscape(query), numResults)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+func (t *WebSearchTool) search(ctx context.Context, query string, numResults int) ([]SearchResult, error) {
+	// Without an API key there are no results; hits are cached per query
+	found, err := websearch.For(t.config).Search(ctx, query, numResults)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("X-Subscription-Token", t.config.WebSearch.APIKey)
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("brave search request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("brave search error %d: %s", resp.StatusCode, string(body))
-	}
-
-	var braveResp struct {
-		Web struct {
-			Results []struct {
-				Title         string   `json:"title"`
-				URL           string   `json:"url"`
-				Description   string   `json:"description"`
-				ExtraSnippets []string `json:"extra_snippets,omitempty"`
-			} `json:"results"`
-		} `json:"web"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&braveResp); err != nil {
-		return nil, fmt.Errorf("parse brave response: %w", err)
-	}
-
-	var results []SearchResult
-	for _, r := range braveResp.Web.Results {
+	results := []SearchResult{}
+	for _, r := range found {
 		snippet := r.Description
 		if len(r.ExtraSnippets) > 0 {
 			snippet += " " + strings.Join(r.ExtraSnippets, " ")
@@ -153,12 +122,14 @@ func (t *WebSearchTool) searchWithBrave(ctx context.Context, query string, numRe
 }
 
 // FetchPageTool fetches and extracts content from a web page
-type FetchPageTool struct{}
+type FetchPageTool struct {
+	config *config.Config
+}
 
 func (t *FetchPageTool) Name() string { return "fetch_page" }
 
 func (t *FetchPageTool) Description() string {
-	return "Fetch a web page and extract its text content for detailed information"
+	return "Fetch a web page and extract its text content for detailed information, with the product facts it publishes as structured data (JSON-LD, microdata, OpenGraph). PDF datasheets are read too: their content is split in [page N] sections, cite the page of the facts you take from them."
 }
 
 func (t *FetchPageTool) Parameters() map[string]any {
@@ -179,11 +150,14 @@ type FetchPageInput struct {
 }
 
 type FetchPageOutput struct {
-	Title          string         `json:"title"`
-	Content        string         `json:"content"`
-	StructuredData map[string]any `json:"structured_data,omitempty"`
-	FetchedAt      time.Time      `json:"fetched_at"`
-	Error          string         `json:"error,omitempty"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// Product facts of the page's JSON-LD, microdata and OpenGraph tags, with the tag each
+	// was read from: exact where the text content needs reading
+	StructuredData []structdata.Fact `json:"structured_data,omitempty"`
+	Pages          int               `json:"pages,omitempty"` // PDF documents: pages with text
+	FetchedAt      time.Time         `json:"fetched_at"`
+	Error          string            `json:"error,omitempty"`
 }
 
 func (t *FetchPageTool) Execute(ctx context.Context, input json.RawMessage, session SessionContext) (any, error) {
@@ -198,26 +172,31 @@ func (t *FetchPageTool) Execute(ctx context.Context, input json.RawMessage, sess
 		return FetchPageOutput{Error: "Invalid URL"}, nil
 	}
 
-	// Fetch the page
-	client := &http.Client{Timeout: 15 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", params.URL, nil)
+	// Fetch the page (cached per URL)
+	page, err := websearch.For(t.config).Fetch(ctx, params.URL)
 	if err != nil {
 		return FetchPageOutput{Error: err.Error()}, nil
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; FeedEnrichBot/1.0)")
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return FetchPageOutput{Error: err.Error()}, nil
+	if page.StatusCode != http.StatusOK {
+		return FetchPageOutput{Error: fmt.Sprintf("HTTP %d", page.StatusCode)}, nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return FetchPageOutput{Error: fmt.Sprintf("HTTP %d", resp.StatusCode)}, nil
+	// PDF datasheets: text page by page, so facts can be cited with their page
+	if pdftext.IsPDF(page.Body) {
+		pages, err := pdftext.Extract(page.Body)
+		if err != nil {
+			return FetchPageOutput{Error: err.Error()}, nil
+		}
+		return FetchPageOutput{
+			Content:   pdftext.Format(pages, 8000), // denser than pages: more of it
+			Pages:     len(pages),
+			FetchedAt: page.FetchedAt,
+		}, nil
 	}
 
 	// Parse HTML
-	doc, err := html.Parse(resp.Body)
+	doc, err := html.Parse(bytes.NewReader(page.Body))
 	if err != nil {
 		return FetchPageOutput{Error: "Failed to parse HTML"}, nil
 	}
@@ -227,12 +206,67 @@ func (t *FetchPageTool) Execute(ctx context.Context, input json.RawMessage, sess
 	content := extractTextContent(doc, 5000) // Limit to 5000 chars
 
 	return FetchPageOutput{
-		Title:     title,
-		Content:   content,
-		FetchedAt: time.Now(),
+		Title:          title,
+		Content:        content,
+		StructuredData: structdata.Extract(page.Body),
+		FetchedAt:      page.FetchedAt,
 	}, nil
 }
 
+// LookupGTINTool looks the product's GTIN up in the GTIN registries
+type LookupGTINTool struct {
+	config *config.Config
+}
+
+func (t *LookupGTINTool) Name() string { return "lookup_gtin" }
+
+func (t *LookupGTINTool) Description() string {
+	return "Look the product's GTIN up in the configured GTIN registries (GS1, Open Food Facts, UPCitemdb). Their data comes from the brand owner or a product database: prefer it over web_search results. Returns found=false when the GTIN is invalid or unknown."
+}
+
+func (t *LookupGTINTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"gtin": map[string]any{
+				"type":        "string",
+				"description": "GTIN to look up; the product's gtin when empty",
+			},
+		},
+	}
+}
+
+type LookupGTINOutput struct {
+	Found   bool                   `json:"found"`
+	Product *websearch.GTINProduct `json:"product,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+func (t *LookupGTINTool) Execute(ctx context.Context, input json.RawMessage, session SessionContext) (any, error) {
+	var params struct {
+		GTIN string `json:"gtin"`
+	}
+	json.Unmarshal(input, &params)
+	if params.GTIN == "" {
+		var data map[string]interface{}
+		json.Unmarshal(session.GetProductData(), &data)
+		params.GTIN = getFieldValue(data, "gtin")
+	}
+	gtin := NormalizeGTIN(params.GTIN)
+	if err := CheckGTIN(gtin); err != nil {
+		return LookupGTINOutput{Error: "invalid GTIN: " + err.Error()}, nil
+	}
+	search := websearch.For(t.config)
+	if !search.RegistriesEnabled() {
+		return LookupGTINOutput{Error: "no GTIN registry configured"}, nil
+	}
+	product, err := search.LookupGTIN(ctx, gtin)
+	if err != nil {
+		return LookupGTINOutput{Error: err.Error()}, nil
+	}
+	return LookupGTINOutput{Found: product != nil, Product: product}, nil
+}
+
 func extractTitle(n *html.Node) string {
 	if n.Type == html.ElementNode && n.Data == "title" {
 		if n.FirstChild != nil {
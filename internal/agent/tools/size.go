@@ -0,0 +1,236 @@
+package tools
+
+import (
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Size is a parsed size value
+type Size struct {
+	Value   string    `json:"value"`             // GMC form: "XL", "44/46", "42.5"
+	Letters []string  `json:"letters,omitempty"` // XS, S, M, L, XL, XXL...
+	Numbers []float64 `json:"numbers,omitempty"`
+	System  string    `json:"system,omitempty"` // written in the value ("EU 42")
+}
+
+// letterSizes maps letter sizes and their spelled-out forms to GMC letters
+var letterSizes = map[string]string{
+	"xxs": "XXS", "xs": "XS", "s": "S", "m": "M", "l": "L", "xl": "XL", "xxl": "XXL", "2xl": "XXL",
+	"xxxl": "XXXL", "3xl": "XXXL", "4xl": "4XL", "5xl": "5XL",
+	"small": "S", "medium": "M", "large": "L", "extra small": "XS", "extra large": "XL",
+	"one size": "One Size", "onesize": "One Size", "taille unique": "One Size", "tu": "One Size",
+}
+
+// sizeSystems are the GMC size_system values
+var sizeSystems = []string{"AU", "BR", "CN", "DE", "EU", "FR", "IT", "JP", "MEX", "UK", "US"}
+
+// sizeWords are dropped from size values ("Taille 42", "Size: M")
+var sizeWords = map[string]bool{"taille": true, "size": true, "pointure": true, "t": true}
+
+// NormalizeSize parses a size ("xl", "44 / 46", "XL – 42", "EU 42", "Taille 38") into
+// its GMC form: the letter size when there is one ("XL – 42" is XL), else the numbers
+// joined by "/" ("44/46"). The numbers and a size system written in the value are kept
+// for InferSizeSystem. It returns false when a part of the value is not a size.
+func NormalizeSize(value string) (Size, bool) {
+	text := strings.ToLower(strings.TrimSpace(value))
+	if text == "" {
+		return Size{}, false
+	}
+	if letter, ok := letterSizes[strings.Join(strings.Fields(text), " ")]; ok {
+		return Size{Value: letter, Letters: []string{letter}}, true
+	}
+
+	var size Size
+	for _, token := range strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("/|()–—-:", r)
+	}) {
+		token = strings.ReplaceAll(token, "½", ".5")
+		switch {
+		case sizeWords[token]:
+		case letterSizes[token] != "":
+			if letter := letterSizes[token]; !slices.Contains(size.Letters, letter) {
+				size.Letters = append(size.Letters, letter)
+			}
+		case slices.Contains(sizeSystems, strings.ToUpper(token)):
+			size.System = strings.ToUpper(token)
+		default:
+			n, err := strconv.ParseFloat(strings.Replace(strings.TrimPrefix(token, "t"), ",", ".", 1), 64)
+			if err != nil || n <= 0 {
+				return Size{}, false
+			}
+			size.Numbers = append(size.Numbers, n)
+		}
+	}
+
+	switch {
+	case len(size.Letters) > 0:
+		size.Value = strings.Join(size.Letters, "/")
+	case len(size.Numbers) > 0:
+		parts := make([]string, len(size.Numbers))
+		for i, n := range size.Numbers {
+			parts[i] = strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		size.Value = strings.Join(parts, "/")
+	default:
+		return Size{}, false
+	}
+	return size, true
+}
+
+// SizeSystemInput are the signals InferSizeSystem reads, in priority order
+type SizeSystemInput struct {
+	Size     Size
+	Shoes    bool
+	Link     string
+	Currency string // ISO 4217 currency of the price
+}
+
+// InferSizeSystem infers size_system from, in order: a system written in the size, the
+// systems the size value can belong to, the link's country domain, then the currency.
+// The domain and currency only decide among the systems the size allows, so a .fr shop
+// selling size 8 is not called EU. It returns false when the signals leave more than one
+// system, the case left to the model.
+func InferSizeSystem(in SizeSystemInput) (system, reason string, ok bool) {
+	if in.Size.System != "" {
+		return in.Size.System, "size", true
+	}
+	candidates := sizeCandidates(in.Size, in.Shoes)
+	if len(candidates) == 1 {
+		return candidates[0], "size", true
+	}
+	if s := domainSizeSystem(in.Link); s != "" && slices.Contains(candidates, s) {
+		return s, "link", true
+	}
+	if s := currencySizeSystems[in.Currency]; s != "" && slices.Contains(candidates, s) {
+		return s, "currency", true
+	}
+	return "", "", false
+}
+
+var currencySizeSystems = map[string]string{"EUR": "EU", "GBP": "UK", "USD": "US"}
+
+// sizeCandidates are the systems all numbers of a size can belong to; letter sizes are
+// used everywhere
+func sizeCandidates(size Size, shoes bool) []string {
+	candidates := []string{"EU", "UK", "US"}
+	for _, n := range size.Numbers {
+		var fits []string
+		switch {
+		case shoes && n >= 33:
+			fits = []string{"EU"}
+		case shoes && n <= 16:
+			fits = []string{"UK", "US"}
+		case shoes:
+			fits = nil
+		case n <= 4:
+			fits = []string{"US"} // US women 0-4, below EU and UK ranges
+		case n <= 33:
+			fits = []string{"UK", "US"} // UK women 6-20, US women and waist sizes
+		case n <= 48:
+			fits = []string{"EU", "UK", "US"} // EU 34-48, UK and US men chest sizes
+		case n <= 64:
+			fits = []string{"EU"}
+		}
+		candidates = slices.DeleteFunc(candidates, func(s string) bool { return !slices.Contains(fits, s) })
+	}
+	return candidates
+}
+
+// domainSizeSystem reads the market of a product from its link's country domain
+func domainSizeSystem(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case strings.HasSuffix(host, ".uk"):
+		return "UK"
+	case strings.HasSuffix(host, ".us"):
+		return "US"
+	}
+	for _, tld := range []string{".fr", ".de", ".it", ".es", ".eu", ".be", ".nl", ".at", ".pt", ".lu"} {
+		if strings.HasSuffix(host, tld) {
+			return "EU"
+		}
+	}
+	return ""
+}
+
+// shoeWords mark a product as shoes in its category, product type or title, as whole
+// words or their plural: "boots" but not "bootcut", "baskets" but not "basketball"
+var shoeWords = []string{"shoe", "chaussure", "sneaker", "basket", "boot", "botte", "sandal", "sandale", "escarpin", "mocassin", "pointure"}
+
+// isShoes reports whether a text names shoes
+func isShoes(text string) bool {
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		word = strings.TrimSuffix(word, "s")
+		if slices.Contains(shoeWords, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// SizeFix is a size or size_system proposal made without any model call
+type SizeFix struct {
+	Field      string  `json:"field"`
+	Before     string  `json:"before"`
+	After      string  `json:"after"`
+	Reason     string  `json:"reason"`
+	Confidence float64 `json:"confidence"`
+}
+
+// CheckSize normalizes the size of a product and infers its size_system when empty.
+// Products whose size can't be read get no fix; size_system is left to the model when
+// the signals are ambiguous.
+func CheckSize(productData json.RawMessage) []SizeFix {
+	var data map[string]interface{}
+	if err := json.Unmarshal(productData, &data); err != nil {
+		return nil
+	}
+	current := strings.TrimSpace(getFieldValue(data, "size"))
+	size, ok := NormalizeSize(current)
+	if !ok {
+		return nil
+	}
+	fixes := []SizeFix{}
+	if size.Value != current {
+		fixes = append(fixes, SizeFix{Field: "size", Before: current, After: size.Value, Reason: "Size in GMC form", Confidence: 1})
+	}
+	if getFieldValue(data, "size_system") != "" {
+		return fixes
+	}
+
+	in := SizeSystemInput{Size: size, Link: getFieldValue(data, "link")}
+	if p, err := ParsePrice(getFieldValue(data, "price"), ""); err == nil {
+		in.Currency = p.Currency
+	}
+	in.Shoes = isShoes(getFieldValue(data, "google_product_category") + " " + getFieldValue(data, "product_type") + " " + getFieldValue(data, "title"))
+
+	if system, reason, ok := InferSizeSystem(in); ok {
+		confidence := 1.0
+		if reason != "size" {
+			confidence = 0.9
+		}
+		// Shoe sizes are read from a keyword guess, never certain
+		if in.Shoes && size.System == "" {
+			confidence = min(confidence, 0.8)
+		}
+		fixes = append(fixes, SizeFix{Field: "size_system", After: system, Reason: "size_system inferred from the " + reason, Confidence: confidence})
+	}
+	return fixes
+}
+
+// NormalizeSizeSystem returns the GMC form of a size_system value ("eu" → "EU"), false
+// when it is not one
+func NormalizeSizeSystem(value string) (string, bool) {
+	system := strings.ToUpper(strings.TrimSpace(value))
+	return system, slices.Contains(sizeSystems, system)
+}
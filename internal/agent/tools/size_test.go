@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIsShoes(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Apparel & Accessories > Shoes", true},
+		{"Chaussures > Baskets", true},
+		{"Bottes en cuir", true},
+		{"Leather boot", true},
+		{"Sandales femme", true},
+		{"Bootcut jeans", false},
+		{"Basketball shorts", false},
+		{"Shoelace set", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isShoes(tt.text); got != tt.want {
+			t.Errorf("isShoes(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSizeSystem(t *testing.T) {
+	tests := []struct {
+		name       string
+		product    string
+		wantSystem string // empty = no size_system fix
+		confidence float64
+	}{
+		{
+			name:    "bootcut is not a boot",
+			product: `{"title": "Bootcut jeans", "size": "34"}`,
+		},
+		{
+			name:    "basketball is not a basket",
+			product: `{"title": "Basketball shorts", "product_type": "Sport > Basketball", "size": "36"}`,
+		},
+		{
+			name:       "shoe size from a keyword is not certain",
+			product:    `{"title": "Baskets running", "size": "42"}`,
+			wantSystem: "EU",
+			confidence: 0.8,
+		},
+		{
+			name:       "system written in the size",
+			product:    `{"title": "Baskets running", "size": "EU 42"}`,
+			wantSystem: "EU",
+			confidence: 1,
+		},
+		{
+			name:       "clothing size decided by the link",
+			product:    `{"title": "Robe", "size": "38", "link": "https://shop.example.fr/robe"}`,
+			wantSystem: "EU",
+			confidence: 0.9,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *SizeFix
+			for _, fix := range CheckSize(json.RawMessage(tt.product)) {
+				if fix.Field == "size_system" {
+					got = &fix
+				}
+			}
+			if tt.wantSystem == "" {
+				if got != nil {
+					t.Fatalf("unexpected size_system fix: %+v", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("no size_system fix, want %s", tt.wantSystem)
+			}
+			if got.After != tt.wantSystem || got.Confidence != tt.confidence {
+				t.Errorf("got %s at %v, want %s at %v", got.After, got.Confidence, tt.wantSystem, tt.confidence)
+			}
+		})
+	}
+}
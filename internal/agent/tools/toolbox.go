@@ -6,7 +6,9 @@ import (
 	"fmt"
 
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/taxonomy"
 )
 
 // SessionContext is passed to tools for context
@@ -14,6 +16,8 @@ type SessionContext interface {
 	GetProductData() json.RawMessage
 	AddProposal(field, before, after string, sources []Source, confidence float64, risk string)
 	AddSource(source Source)
+	// RequestHumanReview queues a question for a reviewer and returns the review ID
+	RequestHumanReview(question string, context map[string]any, options []string) string
 }
 
 // Source represents evidence for a fact
@@ -28,7 +32,7 @@ type Source struct {
 type Toolbox struct {
 	config *config.Config
 	tools  map[string]Tool
-	client *openai.Client
+	client llm.Client
 }
 
 // Tool is an executable tool
@@ -41,7 +45,7 @@ type Tool interface {
 
 // New creates a new Toolbox
 func New(cfg *config.Config) *Toolbox {
-	client := openai.NewClient(cfg.OpenAI.APIKey)
+	client := llm.New(cfg)
 	
 	tb := &Toolbox{
 		config: cfg,
@@ -52,10 +56,15 @@ func New(cfg *config.Config) *Toolbox {
 	// Register all tools
 	tb.Register(&AnalyzeProductTool{client: client, config: cfg})
 	tb.Register(&WebSearchTool{config: cfg})
-	tb.Register(&FetchPageTool{})
-	tb.Register(&AnalyzeImageTool{client: client, config: cfg})
+	tb.Register(&FetchPageTool{config: cfg})
+	tb.Register(&CheckLandingPageTool{config: cfg})
+	tb.Register(&LookupGTINTool{config: cfg})
+	tb.Register(&AnalyzeImageTool{client: client, config: cfg, images: imageproxy.New(cfg)})
 	tb.Register(&OptimizeFieldTool{client: client, config: cfg})
 	tb.Register(&AddAttributeTool{})
+	tb.Register(&NormalizePriceTool{})
+	tb.Register(&CleanTextTool{})
+	tb.Register(&MapCategoryTool{taxonomy: taxonomy.Open(cfg.Agent.TaxonomyFile), minScore: cfg.Agent.TaxonomyMinScore})
 	tb.Register(&ValidateProposalTool{client: client, config: cfg})
 	tb.Register(&CommitChangesTool{})
 	tb.Register(&RequestHumanReviewTool{})
@@ -77,17 +86,15 @@ func (tb *Toolbox) Execute(ctx context.Context, name string, input json.RawMessa
 	return tool.Execute(ctx, input, session)
 }
 
-// OpenAITools returns the tools in OpenAI format
-func (tb *Toolbox) OpenAITools() []openai.Tool {
-	var result []openai.Tool
+// Tools returns the tool definitions sent to the model
+func (tb *Toolbox) Tools() []llm.Tool {
+	var result []llm.Tool
 	for _, tool := range tb.tools {
-		result = append(result, openai.Tool{
-			Type: openai.ToolTypeFunction,
-			Function: &openai.FunctionDefinition{
-				Name:        tool.Name(),
-				Description: tool.Description(),
-				Parameters:  tool.Parameters(),
-			},
+		params, _ := json.Marshal(tool.Parameters())
+		result = append(result, llm.Tool{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			Parameters:  params,
 		})
 	}
 	return result
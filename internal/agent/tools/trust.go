@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// SourceTrusted is the source type of facts read on a trusted domain, see
+// RiskClassifier.AssessChange
+const SourceTrusted = "trusted"
+
+// TrustedMinConfidence is the confidence facts read on a trusted domain get at least
+const TrustedMinConfidence = 0.9
+
+// TrustedDomains are the sites trusted as sources for a catalog: the brands' own sites, the
+// merchant's. A nil TrustedDomains trusts nothing.
+type TrustedDomains struct {
+	entries []trustedDomain
+}
+
+type trustedDomain struct {
+	domain string
+	brand  string // brandKey, "" for every brand
+}
+
+// NewTrustedDomains builds the trusted domains of a catalog
+func NewTrustedDomains(domains []models.TrustedDomain) *TrustedDomains {
+	t := &TrustedDomains{}
+	for _, d := range domains {
+		if domain := NormalizeDomain(d.Domain); domain != "" {
+			t.entries = append(t.entries, trustedDomain{domain: domain, brand: brandKey(d.Brand)})
+		}
+	}
+	return t
+}
+
+// NormalizeDomain reads the domain of a host or URL, lower case and without "www."
+// ("https://www.Nike.com/fr/" → "nike.com"); "" when there is none
+func NormalizeDomain(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	if !strings.Contains(host, ".") {
+		return ""
+	}
+	return strings.TrimPrefix(host, "www.")
+}
+
+// Trusts reports whether a URL is on a domain, or a subdomain of a domain, trusted for
+// the brand
+func (t *TrustedDomains) Trusts(rawURL, brand string) bool {
+	if t == nil {
+		return false
+	}
+	host := NormalizeDomain(rawURL)
+	if host == "" {
+		return false
+	}
+	key := brandKey(brand)
+	for _, e := range t.entries {
+		if (e.brand == "" || e.brand == key) && (host == e.domain || strings.HasSuffix(host, "."+e.domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Domains lists the domains trusted for a brand
+func (t *TrustedDomains) Domains(brand string) []string {
+	if t == nil {
+		return nil
+	}
+	key := brandKey(brand)
+	var domains []string
+	for _, e := range t.entries {
+		if e.brand == "" || e.brand == key {
+			domains = append(domains, e.domain)
+		}
+	}
+	return domains
+}
@@ -4,14 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/benjamincozon/feedenrich/internal/config"
-	openai "github.com/sashabaranov/go-openai"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 )
 
 // ValidateProposalTool validates a proposal before committing
 type ValidateProposalTool struct {
-	client *openai.Client
+	client llm.Client
 	config *config.Config
 }
 
@@ -107,22 +108,21 @@ Si claims sensibles même sourcés → risk=high, requires_human_review=true
 
 Retourne UNIQUEMENT le JSON.`, params.Field, params.Before, params.After, string(sourcesJSON))
 
-	resp, err := t.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: t.config.OpenAI.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: prompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	resp, err := t.client.Chat(ctx, llm.Request{
+		Model: t.config.LLM.Model,
+		Stage: llm.StageValidateProposal,
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: prompt},
 		},
+		JSON: true,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("openai validate: %w", err)
+		return nil, fmt.Errorf("llm validate: %w", err)
 	}
 
 	var result ValidateProposalOutput
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
-		return json.RawMessage(resp.Choices[0].Message.Content), nil
+	if err := json.Unmarshal([]byte(resp.Content), &result); err != nil {
+		return json.RawMessage(resp.Content), nil
 	}
 
 	return result, nil
@@ -250,6 +250,7 @@ type RequestHumanReviewInput struct {
 type RequestHumanReviewOutput struct {
 	ReviewID string `json:"review_id"`
 	Status   string `json:"status"`
+	Answer   string `json:"answer,omitempty"` // reviewer's resolution, once a paused run resumes
 }
 
 func (t *RequestHumanReviewTool) Execute(ctx context.Context, input json.RawMessage, session SessionContext) (any, error) {
@@ -258,9 +259,13 @@ func (t *RequestHumanReviewTool) Execute(ctx context.Context, input json.RawMess
 		return nil, fmt.Errorf("parse input: %w", err)
 	}
 
-	// In a real implementation, this would create a review request in the database
+	if strings.TrimSpace(params.Question) == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+
+	// Persisted with the session; reviewers see it in the human review queue
 	return RequestHumanReviewOutput{
-		ReviewID: "review-pending",
+		ReviewID: session.RequestHumanReview(params.Question, params.Context, params.Options),
 		Status:   "pending",
 	}, nil
 }
@@ -2,8 +2,11 @@ package tools
 
 import (
 	"encoding/json"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // HardRuleValidator is a DETERMINISTIC, REPRODUCIBLE, EXPLAINABLE validator
@@ -15,10 +18,12 @@ type HardRuleValidator struct {
 type ValidationRule struct {
 	ID        string      `json:"id"`
 	Field     string      `json:"field"`
-	Type      string      `json:"type"` // required, min_length, max_length, pattern, forbidden_words, format
+	Type      string      `json:"type"` // required, min_length, max_length, pattern, forbidden_words, url, gtin
 	Value     interface{} `json:"value"`
 	Message   string      `json:"message"`
 	Severity  string      `json:"severity"` // error, warning
+	Vertical  Vertical    `json:"vertical,omitempty"` // only checked on products of this vertical
+	When      []RuleCondition `json:"when,omitempty"`  // only checked on products all conditions hold for
 }
 
 type ValidationResult struct {
@@ -26,6 +31,7 @@ type ValidationResult struct {
 	Violations []RuleViolation   `json:"violations"`
 	Warnings   []RuleViolation   `json:"warnings"`
 	Checked    int               `json:"rules_checked"`
+	Vertical   Vertical          `json:"vertical"`
 }
 
 type RuleViolation struct {
@@ -34,6 +40,10 @@ type RuleViolation struct {
 	Message  string `json:"message"`
 	Expected string `json:"expected"`
 	Actual   string `json:"actual"`
+	// ExpectedValue and ActualValue are the numbers behind Expected and Actual for length
+	// rules (the limit and the length in characters), nil for the other rules
+	ExpectedValue *float64 `json:"expected_value,omitempty"`
+	ActualValue   *float64 `json:"actual_value,omitempty"`
 }
 
 func NewHardRuleValidator() *HardRuleValidator {
@@ -42,12 +52,23 @@ func NewHardRuleValidator() *HardRuleValidator {
 	}
 }
 
-// LoadRules adds custom rules to the validator
-func (v *HardRuleValidator) LoadRules(rules []ValidationRule) {
+// LoadRules adds custom rules to the validator; it fails on a rule with a condition that
+// can't be evaluated, and then adds none
+func (v *HardRuleValidator) LoadRules(rules []ValidationRule) error {
+	for _, rule := range rules {
+		for _, c := range rule.When {
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("rule %s: %w", rule.ID, err)
+			}
+		}
+	}
 	v.rules = append(v.rules, rules...)
+	return nil
 }
 
-// Validate checks product data against all rules
+// Validate checks product data against all rules, the vertical ones only when they
+// match the vertical of the product (see DetectVertical) and the conditional ones only
+// when their conditions hold
 func (v *HardRuleValidator) Validate(productData json.RawMessage) *ValidationResult {
 	result := &ValidationResult{
 		Valid:      true,
@@ -69,7 +90,11 @@ func (v *HardRuleValidator) Validate(productData json.RawMessage) *ValidationRes
 	}
 
 	// Check each rule
+	result.Vertical = detectVertical(data)
 	for _, rule := range v.rules {
+		if !rule.appliesTo(data, result.Vertical) {
+			continue
+		}
 		result.Checked++
 
 		fieldValue := getFieldValue(data, rule.Field)
@@ -88,6 +113,35 @@ func (v *HardRuleValidator) Validate(productData json.RawMessage) *ValidationRes
 	return result
 }
 
+// ValidateField checks a single value against the error rules of its field, e.g. a
+// proposed title against the length and promotional text rules. Without the product,
+// vertical and conditional rules are left out.
+func (v *HardRuleValidator) ValidateField(field, value string) []RuleViolation {
+	violations := []RuleViolation{}
+	for _, rule := range v.rules {
+		if rule.Severity != "error" || rule.Vertical != "" || len(rule.When) > 0 || !strings.EqualFold(rule.Field, field) {
+			continue
+		}
+		if violation := v.checkRule(rule, value); violation != nil {
+			violations = append(violations, *violation)
+		}
+	}
+	return violations
+}
+
+// ComplianceScore is the share of rules passed (0-1); warnings count half
+func (r *ValidationResult) ComplianceScore() float64 {
+	if r.Checked == 0 {
+		return 0
+	}
+	failed := float64(len(r.Violations)) + float64(len(r.Warnings))/2
+	score := 1 - failed/float64(r.Checked)
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
 func (v *HardRuleValidator) checkRule(rule ValidationRule, value string) *RuleViolation {
 	switch rule.Type {
 	case "required":
@@ -101,33 +155,26 @@ func (v *HardRuleValidator) checkRule(rule ValidationRule, value string) *RuleVi
 			}
 		}
 
-	case "min_length":
-		minLen, ok := rule.Value.(float64)
+	case "min_length", "max_length":
+		limit, ok := ruleNumber(rule.Value)
 		if !ok {
 			return nil
 		}
-		if len(value) < int(minLen) {
-			return &RuleViolation{
-				RuleID:   rule.ID,
-				Field:    rule.Field,
-				Message:  rule.Message,
-				Expected: string(rune(int(minLen))) + "+ characters",
-				Actual:   string(rune(len(value))) + " characters",
-			}
-		}
-
-	case "max_length":
-		maxLen, ok := rule.Value.(float64)
-		if !ok {
-			return nil
+		// GMC limits count characters, not bytes: "é" is one
+		length := float64(utf8.RuneCountInString(value))
+		expected := formatNumber(limit) + "+ characters"
+		if rule.Type == "max_length" {
+			expected = "max " + formatNumber(limit) + " characters"
 		}
-		if len(value) > int(maxLen) {
+		if (rule.Type == "min_length" && length < limit) || (rule.Type == "max_length" && length > limit) {
 			return &RuleViolation{
-				RuleID:   rule.ID,
-				Field:    rule.Field,
-				Message:  rule.Message,
-				Expected: "max " + string(rune(int(maxLen))) + " characters",
-				Actual:   string(rune(len(value))) + " characters",
+				RuleID:        rule.ID,
+				Field:         rule.Field,
+				Message:       rule.Message,
+				Expected:      expected,
+				Actual:        formatNumber(length) + " characters",
+				ExpectedValue: &limit,
+				ActualValue:   &length,
 			}
 		}
 
@@ -169,6 +216,20 @@ func (v *HardRuleValidator) checkRule(rule ValidationRule, value string) *RuleVi
 			}
 		}
 
+	case "gtin":
+		if strings.TrimSpace(value) == "" {
+			return nil // see the required rule
+		}
+		if err := CheckGTIN(value); err != nil {
+			return &RuleViolation{
+				RuleID:   rule.ID,
+				Field:    rule.Field,
+				Message:  rule.Message,
+				Expected: "valid GTIN-8, GTIN-12, GTIN-13 or GTIN-14",
+				Actual:   value + " (" + err.Error() + ")",
+			}
+		}
+
 	case "url":
 		if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
 			return &RuleViolation{
@@ -209,7 +270,9 @@ func toString(v interface{}) string {
 	case string:
 		return val
 	case float64:
-		return strings.TrimRight(strings.TrimRight(strings.Replace(string(rune(int(val))), ".", "", 1), "0"), ".")
+		return formatNumber(val)
+	case json.Number:
+		return val.String()
 	case bool:
 		if val {
 			return "true"
@@ -221,6 +284,33 @@ func toString(v interface{}) string {
 	}
 }
 
+// ruleNumber reads the numeric value of a rule: a JSON number, a Go int or float, or a
+// numeric string ("150") as rules stored by hand may have
+func ruleNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// formatNumber writes a number without exponent or trailing zeros: 150, 42.5, 1000000
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
 // defaultGMCRules returns standard Google Merchant Center rules (2025)
 func defaultGMCRules() []ValidationRule {
 	return []ValidationRule{
@@ -243,6 +333,9 @@ func defaultGMCRules() []ValidationRule {
 		{ID: "gmc_link_url", Field: "link", Type: "url", Message: "Product link must be a valid URL", Severity: "error"},
 		{ID: "gmc_image_url", Field: "image_link", Type: "url", Message: "Image link must be a valid URL", Severity: "error"},
 
+		// === IDENTIFIERS ===
+		{ID: "gmc_gtin_valid", Field: "gtin", Type: "gtin", Message: "GTIN must have a valid length, check digit and GS1 prefix, and not be a placeholder", Severity: "error"},
+
 		// === FORBIDDEN CONTENT ===
 		{ID: "gmc_title_promo", Field: "title", Type: "forbidden_words", Value: []interface{}{"free shipping", "sale", "discount", "promo", "soldes", "-50%", "-30%", "-20%", "livraison gratuite", "gratuit", "offre", "promotion"}, Message: "Title must not contain promotional text", Severity: "error"},
 		{ID: "gmc_description_promo", Field: "description", Type: "forbidden_words", Value: []interface{}{"free shipping", "livraison gratuite", "click here", "buy now", "limited time"}, Message: "Description should not contain promotional calls to action", Severity: "warning"},
@@ -254,10 +347,28 @@ func defaultGMCRules() []ValidationRule {
 		{ID: "gmc_google_category_recommended", Field: "google_product_category", Type: "required", Message: "Google product category improves search relevance", Severity: "info"},
 
 		// === APPAREL-SPECIFIC (Required in US, UK, DE, JP, FR, BR) ===
-		{ID: "gmc_color_apparel", Field: "color", Type: "required", Message: "Color is required for apparel products", Severity: "warning"},
-		{ID: "gmc_gender_apparel", Field: "gender", Type: "required", Message: "Gender is required for apparel (male/female/unisex)", Severity: "warning"},
-		{ID: "gmc_age_group_apparel", Field: "age_group", Type: "required", Message: "Age group is required for apparel (adult/kids/infant/etc.)", Severity: "warning"},
-		{ID: "gmc_size_apparel", Field: "size", Type: "required", Message: "Size is required for clothing and shoes", Severity: "warning"},
+		{ID: "gmc_color_apparel", Field: "color", Type: "required", Message: "Color is required for apparel products", Severity: "warning", Vertical: VerticalApparel},
+		{ID: "gmc_gender_apparel", Field: "gender", Type: "required", Message: "Gender is required for apparel (male/female/unisex)", Severity: "warning", Vertical: VerticalApparel},
+		{ID: "gmc_age_group_apparel", Field: "age_group", Type: "required", Message: "Age group is required for apparel (adult/kids/infant/etc.)", Severity: "warning", Vertical: VerticalApparel},
+		// size is only required for clothing and shoes, not for bags, jewelry or accessories
+		{ID: "gmc_size_apparel", Field: "size", Type: "required", Message: "Size is required for clothing and shoes", Severity: "warning", Vertical: VerticalApparel,
+			When: []RuleCondition{{Field: "google_product_category", Op: "not_starts_with", Value: []string{
+				"Apparel & Accessories > Clothing Accessories", "Apparel & Accessories > Costumes & Accessories",
+				"Apparel & Accessories > Handbag & Wallet Accessories", "Apparel & Accessories > Handbags, Wallets & Cases",
+				"Apparel & Accessories > Jewelry", "Apparel & Accessories > Shoe Accessories",
+			}}}},
+
+		// === ELECTRONICS-SPECIFIC ===
+		{ID: "gmc_mpn_electronics", Field: "mpn", Type: "required", Message: "MPN identifies the exact model of electronics", Severity: "warning", Vertical: VerticalElectronics},
+		{ID: "gmc_title_promo_electronics", Field: "title", Type: "forbidden_words", Value: []interface{}{"compatible with all", "universal", "meilleur prix"}, Message: "Electronics titles must not make unverifiable compatibility claims", Severity: "warning", Vertical: VerticalElectronics},
+
+		// === HOME-SPECIFIC ===
+		{ID: "gmc_color_home", Field: "color", Type: "required", Message: "Color helps shoppers filter furniture and decor", Severity: "warning", Vertical: VerticalHome},
+		{ID: "gmc_size_home", Field: "size", Type: "required", Message: "Dimensions help shoppers compare furniture and decor", Severity: "info", Vertical: VerticalHome},
+
+		// === BEAUTY-SPECIFIC ===
+		{ID: "gmc_size_beauty", Field: "size", Type: "required", Message: "Volume or weight (50ml, 100g) distinguishes beauty variants", Severity: "warning", Vertical: VerticalBeauty},
+		{ID: "gmc_description_claims_beauty", Field: "description", Type: "forbidden_words", Value: []interface{}{"cures", "guérit", "clinically proven", "cliniquement prouvé", "miracle"}, Message: "Beauty descriptions must not make medical claims", Severity: "error", Vertical: VerticalBeauty},
 
 		// === VARIANT PRODUCTS ===
 		{ID: "gmc_item_group_variants", Field: "item_group_id", Type: "required", Message: "Item group ID required for product variants", Severity: "info"},
@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{150, "150"},
+		{42.5, "42.5"},
+		{1e6, "1000000"},
+		{0.1, "0.1"},
+		{0, "0"},
+	}
+	for _, tt := range tests {
+		if got := formatNumber(tt.in); got != tt.want {
+			t.Errorf("formatNumber(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToStringNumbers(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{float64(150), "150"},
+		{1e6, "1000000"},
+		{json.Number("42.50"), "42.50"},
+	}
+	for _, tt := range tests {
+		if got := toString(tt.in); got != tt.want {
+			t.Errorf("toString(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLengthRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     ValidationRule
+		value    string
+		violates bool
+		expected string
+		actual   string
+	}{
+		{
+			name:  "multibyte characters count once",
+			rule:  ValidationRule{ID: "max", Field: "title", Type: "max_length", Value: 5.0},
+			value: "éééée",
+		},
+		{
+			name:     "over the limit in characters",
+			rule:     ValidationRule{ID: "max", Field: "title", Type: "max_length", Value: 5.0},
+			value:    "éééééé",
+			violates: true,
+			expected: "max 5 characters",
+			actual:   "6 characters",
+		},
+		{
+			name:     "under the minimum in characters",
+			rule:     ValidationRule{ID: "min", Field: "title", Type: "min_length", Value: 4.0},
+			value:    "çàé",
+			violates: true,
+			expected: "4+ characters",
+			actual:   "3 characters",
+		},
+		{
+			name:     "large limit without exponent",
+			rule:     ValidationRule{ID: "min", Field: "description", Type: "min_length", Value: 1e6},
+			value:    "short",
+			violates: true,
+			expected: "1000000+ characters",
+			actual:   "5 characters",
+		},
+		{
+			name:     "numeric string limit",
+			rule:     ValidationRule{ID: "max", Field: "title", Type: "max_length", Value: "2"},
+			value:    "abc",
+			violates: true,
+			expected: "max 2 characters",
+			actual:   "3 characters",
+		},
+	}
+	v := &HardRuleValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.checkRule(tt.rule, tt.value)
+			if !tt.violates {
+				if got != nil {
+					t.Fatalf("unexpected violation: %+v", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("expected a violation")
+			}
+			if got.Expected != tt.expected || got.Actual != tt.actual {
+				t.Errorf("got expected %q, actual %q; want %q, %q", got.Expected, got.Actual, tt.expected, tt.actual)
+			}
+		})
+	}
+}
+
+func TestLengthViolationValues(t *testing.T) {
+	v := &HardRuleValidator{rules: []ValidationRule{
+		{ID: "title_max", Field: "title", Type: "max_length", Value: 10.0, Message: "too long", Severity: "error"},
+	}}
+	result := v.Validate(json.RawMessage(`{"title": "Crème brûlée set"}`))
+	if result.Valid || len(result.Violations) != 1 {
+		t.Fatalf("expected one violation, got %+v", result)
+	}
+	violation := result.Violations[0]
+	if violation.ExpectedValue == nil || *violation.ExpectedValue != 10 {
+		t.Errorf("ExpectedValue = %v, want 10", violation.ExpectedValue)
+	}
+	if violation.ActualValue == nil || *violation.ActualValue != 16 {
+		t.Errorf("ActualValue = %v, want 16", violation.ActualValue)
+	}
+
+	out, err := json.Marshal(violation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"expected_value":10`, `"actual_value":16`, `"expected":"max 10 characters"`, `"actual":"16 characters"`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("violation JSON %s lacks %s", out, want)
+		}
+	}
+}
+
+func TestNonLengthViolationsHaveNoValues(t *testing.T) {
+	v := &HardRuleValidator{}
+	got := v.checkRule(ValidationRule{ID: "req", Field: "title", Type: "required"}, " ")
+	if got == nil {
+		t.Fatal("expected a violation")
+	}
+	if got.ExpectedValue != nil || got.ActualValue != nil {
+		t.Errorf("required rule set numeric values: %+v", got)
+	}
+	out, _ := json.Marshal(got)
+	if strings.Contains(string(out), "expected_value") || strings.Contains(string(out), "actual_value") {
+		t.Errorf("violation JSON %s has numeric values", out)
+	}
+}
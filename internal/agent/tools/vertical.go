@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/benjamincozon/feedenrich/internal/taxonomy"
+)
+
+// Vertical is the product family a product belongs to. It decides which GMC attributes
+// matter, which title template applies and what the prompts may propose.
+type Vertical string
+
+const (
+	VerticalApparel     Vertical = "apparel"
+	VerticalElectronics Vertical = "electronics"
+	VerticalHome        Vertical = "home"
+	VerticalBeauty      Vertical = "beauty"
+	VerticalGeneric     Vertical = "generic" // none detected
+)
+
+// verticalOrder is the order keywords are tried in within a category segment
+var verticalOrder = []Vertical{VerticalApparel, VerticalElectronics, VerticalBeauty, VerticalHome}
+
+// verticalCategoryIDs maps the top-level Google product category IDs. Deeper IDs are read
+// as their path in the bundled taxonomy, and fall back to product_type when not in it.
+var verticalCategoryIDs = map[string]Vertical{
+	"166": VerticalApparel,     // Apparel & Accessories
+	"222": VerticalElectronics, // Electronics
+	"536": VerticalHome,        // Home & Garden
+	"436": VerticalHome,        // Furniture
+	"469": VerticalBeauty,      // Health & Beauty
+}
+
+// verticalKeywords match the start of a word of a category (English and French), so
+// "shirt" matches "Shirts & Tops"
+var verticalKeywords = map[Vertical][]string{
+	VerticalApparel: {
+		"apparel", "clothing", "vêtement", "vetement", "shoe", "chaussure", "sneaker", "fashion",
+		"dress", "robe", "shirt", "jean", "pantalon", "trouser", "jacket", "veste", "manteau",
+		"coat", "lingerie", "swimwear", "maillot de bain", "handbag", "sac à main", "jewelry",
+		"bijou", "sweat",
+	},
+	VerticalElectronics: {
+		"electronic", "électronique", "electronique", "computer", "ordinateur", "laptop",
+		"smartphone", "phone", "téléphone", "telephone", "tablet", "tablette", "camera",
+		"appareil photo", "tv", "télévision", "television", "audio", "headphone", "casque audio",
+		"écouteur", "ecouteur", "game console", "console de jeu", "charger", "chargeur",
+	},
+	VerticalBeauty: {
+		"beauty", "beauté", "beaute", "cosmetic", "cosmétique", "cosmetique", "makeup",
+		"maquillage", "skin care", "skincare", "soins du visage", "perfume", "parfum",
+		"fragrance", "hair care", "capillaire", "nail polish", "vernis à ongles", "lipstick",
+		"rouge à lèvres",
+	},
+	VerticalHome: {
+		"home", "maison", "garden", "jardin", "furniture", "meuble", "mobilier", "kitchen",
+		"cuisine", "decor", "déco", "deco", "bedding", "literie", "lighting", "luminaire",
+		"sofa", "canapé", "chair", "chaise", "tapis", "curtain", "rideau",
+	},
+}
+
+// DetectVertical reads the vertical of a product from its google_product_category, then
+// its product_type. Category paths are read from the top level down, so
+// "Home & Garden > Kitchen > Appliances" is home.
+func DetectVertical(productData json.RawMessage) Vertical {
+	var data map[string]interface{}
+	if err := json.Unmarshal(productData, &data); err != nil {
+		return VerticalGeneric
+	}
+	return detectVertical(data)
+}
+
+func detectVertical(data map[string]interface{}) Vertical {
+	for _, field := range []string{"google_product_category", "product_type"} {
+		if v := categoryVertical(getFieldValue(data, field)); v != VerticalGeneric {
+			return v
+		}
+	}
+	return VerticalGeneric
+}
+
+func categoryVertical(category string) Vertical {
+	category = strings.TrimSpace(category)
+	if v, ok := verticalCategoryIDs[category]; ok {
+		return v
+	}
+	if c, ok := taxonomy.Bundled().Lookup(category); ok {
+		category = c.Name()
+	}
+	for _, segment := range strings.FieldsFunc(category, func(r rune) bool { return r == '>' || r == '/' || r == '|' }) {
+		words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(segment), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}), " ")
+		for _, v := range verticalOrder {
+			for _, keyword := range verticalKeywords[v] {
+				if strings.Contains(words, " "+keyword) {
+					return v
+				}
+			}
+		}
+	}
+	return VerticalGeneric
+}
+
+// VerticalProfile is what the pipelines route on for a vertical: its title template, the
+// attributes worth proposing, what to look for in the image and the prompt rules
+type VerticalProfile struct {
+	Vertical        Vertical `json:"vertical"`
+	Label           string   `json:"label"`
+	TitleTemplate   string   `json:"title_template"`
+	TitleExample    string   `json:"title_example"`
+	Attributes      []string `json:"attributes"`       // GMC attributes to fill for the vertical
+	ImageAttributes []string `json:"image_attributes"` // attributes image evidence can verify
+	Guidance        []string `json:"guidance"`
+}
+
+var verticalProfiles = map[Vertical]VerticalProfile{
+	VerticalApparel: {
+		Vertical:        VerticalApparel,
+		Label:           "Apparel & Accessories",
+		TitleTemplate:   "Brand + Gender + Type + Color + Size + Material",
+		TitleExample:    "Nike Men's Air Max 90 Running Shoes Black Size 42 Leather",
+		Attributes:      []string{"color", "gender", "age_group", "size", "size_system", "material", "pattern"},
+		ImageAttributes: []string{"color", "material", "pattern", "style"},
+		Guidance: []string{
+			"color, gender, age_group and size are required in US, UK, DE, JP, FR, BR: ALWAYS propose them when missing",
+			"age_group: \"adult\" unless clearly a kids product",
+			"size: extract from the title when present; size_system is inferred in code from the size, link and currency, only propose it when they are ambiguous",
+		},
+	},
+	VerticalElectronics: {
+		Vertical:        VerticalElectronics,
+		Label:           "Electronics",
+		TitleTemplate:   "Brand + Line + Model + Key Spec + Capacity",
+		TitleExample:    "Samsung Galaxy S24 Ultra 5G Smartphone 256GB Titanium Gray",
+		Attributes:      []string{"gtin", "mpn", "brand", "color", "condition"},
+		ImageAttributes: []string{"color"},
+		Guidance: []string{
+			"gtin and mpn identify the exact model: never guess them",
+			"Specs (capacity, screen size, wattage, compatibility) only from the feed or a cited source, never from the image",
+			"Do NOT propose gender, age_group or size",
+			"condition: refurbished or used products must say so",
+		},
+	},
+	VerticalHome: {
+		Vertical:        VerticalHome,
+		Label:           "Home & Garden",
+		TitleTemplate:   "Brand + Type + Material + Dimensions + Style",
+		TitleExample:    "IKEA KALLAX Shelf Unit Engineered Wood White 77x147cm Modern",
+		Attributes:      []string{"material", "color", "pattern", "size", "product_length", "product_width", "product_height"},
+		ImageAttributes: []string{"color", "material", "pattern", "style"},
+		Guidance: []string{
+			"Dimensions and capacity only from the feed or a cited source, never estimated from the image",
+			"Do NOT propose gender or age_group",
+		},
+	},
+	VerticalBeauty: {
+		Vertical:        VerticalBeauty,
+		Label:           "Health & Beauty",
+		TitleTemplate:   "Brand + Line + Type + Variant + Size",
+		TitleExample:    "L'Oréal Paris Revitalift Night Cream Anti-Wrinkle 50ml",
+		Attributes:      []string{"brand", "size", "gtin", "product_type"},
+		ImageAttributes: []string{"color"},
+		Guidance: []string{
+			"size is the volume or weight (50ml, 100g): put it in the title too",
+			"NO health, medical or efficacy claims unless quoted from the feed",
+			"Do NOT propose gender or age_group unless the feed states them",
+		},
+	},
+	VerticalGeneric: {
+		Vertical:        VerticalGeneric,
+		Label:           "General merchandise",
+		TitleTemplate:   "Brand + Product Type + Key Attributes (color, material, size)",
+		TitleExample:    "Moleskine Classic Notebook Hard Cover Black A5 Ruled",
+		Attributes:      []string{"brand", "gtin", "color", "material"},
+		ImageAttributes: []string{"color", "material"},
+		Guidance: []string{
+			"Propose apparel attributes (gender, age_group, size) only for clothing and shoes",
+		},
+	},
+}
+
+// ProfileFor returns the profile of a vertical, the generic one for unknown verticals
+func ProfileFor(v Vertical) VerticalProfile {
+	if profile, ok := verticalProfiles[v]; ok {
+		return profile
+	}
+	return verticalProfiles[VerticalGeneric]
+}
+
+// Prompt renders the profile as a prompt section
+func (p VerticalProfile) Prompt() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "PRODUCT VERTICAL: %s\n", p.Label)
+	fmt.Fprintf(&b, "- Title template: %s (e.g. %q)\n", p.TitleTemplate, p.TitleExample)
+	fmt.Fprintf(&b, "- Key attributes: %s\n", strings.Join(p.Attributes, ", "))
+	for _, g := range p.Guidance {
+		fmt.Fprintf(&b, "- %s\n", g)
+	}
+	return b.String()
+}
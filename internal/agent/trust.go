@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// TrustSource reads the domains trusted as sources for a dataset
+type TrustSource interface {
+	ListTrustedDomains(ctx context.Context, datasetID uuid.UUID) ([]models.TrustedDomain, error)
+}
+
+// trustTTL is how long the trusted domains of a dataset are reused before they are read again
+const trustTTL = 5 * time.Minute
+
+type trustEntry struct {
+	domains *tools.TrustedDomains
+	expires time.Time
+}
+
+// trustCache keeps the trusted domains of recent datasets
+type trustCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]trustEntry
+}
+
+// SetTrustSource makes web sources on a dataset's trusted domains preferred, and lowers
+// the risk of the changes they back
+func (a *Agent) SetTrustSource(source TrustSource) {
+	a.trust = source
+	a.trustCache = &trustCache{entries: map[uuid.UUID]trustEntry{}}
+}
+
+// ReloadTrustedDomains makes the next product of a dataset read its trusted domains again
+// (after they were edited)
+func (a *Agent) ReloadTrustedDomains(datasetID uuid.UUID) {
+	if a.trustCache == nil {
+		return
+	}
+	a.trustCache.mu.Lock()
+	defer a.trustCache.mu.Unlock()
+	delete(a.trustCache.entries, datasetID)
+}
+
+// trustedDomains returns the trusted domains of a dataset, nil when there is no trust
+// source or they can't be read
+func (a *Agent) trustedDomains(ctx context.Context, datasetID uuid.UUID) *tools.TrustedDomains {
+	if a.trust == nil {
+		return nil
+	}
+	c := a.trustCache
+	c.mu.Lock()
+	entry, ok := c.entries[datasetID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.domains
+	}
+
+	list, err := a.trust.ListTrustedDomains(ctx, datasetID)
+	if err != nil {
+		fmt.Printf("Failed to load the trusted domains of dataset %s: %v\n", datasetID, err)
+		return entry.domains
+	}
+	domains := tools.NewTrustedDomains(list)
+	c.mu.Lock()
+	c.entries[datasetID] = trustEntry{domains: domains, expires: time.Now().Add(trustTTL)}
+	c.mu.Unlock()
+	return domains
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// UsageMeter accumulates the usage of LLM calls made with a context carrying it
+// (see WithUsageMeter). Safe for concurrent use.
+type UsageMeter struct {
+	mu    sync.Mutex
+	usage models.RunUsage
+}
+
+type usageMeterKey struct{}
+
+// WithUsageMeter returns a context whose LLM calls are added to m
+func WithUsageMeter(ctx context.Context, m *UsageMeter) context.Context {
+	return context.WithValue(ctx, usageMeterKey{}, m)
+}
+
+func usageMeterFrom(ctx context.Context) *UsageMeter {
+	m, _ := ctx.Value(usageMeterKey{}).(*UsageMeter)
+	return m
+}
+
+func (m *UsageMeter) add(promptTokens, completionTokens int, costUSD float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage.Calls++
+	m.usage.PromptTokens += promptTokens
+	m.usage.CompletionTokens += completionTokens
+	m.usage.CostUSD += costUSD
+}
+
+// addVision counts an image analysis: a reuse of a cached one, or a call and the
+// tokens downscaling saved on it
+func (m *UsageMeter) addVision(reused bool, tokensSaved int, savedUSD float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if reused {
+		m.usage.VisionReused++
+	} else {
+		m.usage.VisionCalls++
+	}
+	m.usage.VisionTokensSaved += tokensSaved
+	m.usage.VisionSavedUSD += savedUSD
+}
+
+// Usage returns the usage accumulated so far
+func (m *UsageMeter) Usage() models.RunUsage {
+	if m == nil {
+		return models.RunUsage{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
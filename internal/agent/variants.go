@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// variantSource is the source type of a proposal copied from a sibling variant
+const variantSource = "variant"
+
+// ItemGroupID returns the item_group_id of a product, "" when it has none
+func ItemGroupID(p *models.Product) string {
+	return productField(p, "item_group_id")
+}
+
+// productField reads a field from the product's current data, falling back to the raw feed
+func productField(p *models.Product, field string) string {
+	for _, data := range []json.RawMessage{p.CurrentData, p.RawData} {
+		var fields map[string]interface{}
+		if json.Unmarshal(data, &fields) == nil && len(fields) > 0 {
+			return getFieldValueFromMap(fields, field)
+		}
+	}
+	return ""
+}
+
+// VariantProposals copies the proposals a run made on the shared attributes
+// (AGENT_VARIANT_FIELDS) of a product to its siblings in the same item group, without
+// any model call. A sibling gets the proposal when its value for the field is the one
+// the proposal replaces (the same gap or the same wrong value), and, for values read
+// from the image, when it shows the same image: variants that differ in color usually
+// have their own photo.
+func (a *Agent) VariantProposals(source *models.Product, proposals []models.Proposal, siblings []models.Product) []models.Proposal {
+	group := ItemGroupID(source)
+	if group == "" || len(siblings) == 0 {
+		return nil
+	}
+	sourceImage := extractImageURL(source.RawData)
+
+	var copies []models.Proposal
+	for _, p := range proposals {
+		if !slices.Contains(a.config.Agent.VariantFields, p.Field) {
+			continue
+		}
+		var sources []models.Source
+		json.Unmarshal(p.Sources, &sources)
+		fromImage := slices.ContainsFunc(sources, func(s models.Source) bool { return s.Type == "image" || s.Type == "vision" })
+		before := ""
+		if p.BeforeValue != nil {
+			before = *p.BeforeValue
+		}
+
+		for i := range siblings {
+			sibling := &siblings[i]
+			if sibling.ID == source.ID || ItemGroupID(sibling) != group {
+				continue
+			}
+			current := productField(sibling, p.Field)
+			if current != before || current == p.AfterValue {
+				continue
+			}
+			if fromImage && extractImageURL(sibling.RawData) != sourceImage {
+				continue
+			}
+
+			copySources, _ := json.Marshal(append(slices.Clone(sources), models.Source{
+				Type:       variantSource,
+				Reference:  source.ExternalID,
+				Evidence:   fmt.Sprintf("%s = %q on variant %s of item group %s", p.Field, p.AfterValue, source.ExternalID, group),
+				Confidence: p.Confidence,
+			}))
+			copies = append(copies, models.Proposal{
+				ID:               uuid.New(),
+				ProductID:        sibling.ID,
+				Field:            p.Field,
+				BeforeValue:      &current,
+				AfterValue:       p.AfterValue,
+				Rationale:        append(slices.Clone(p.Rationale), fmt.Sprintf("Propagated from variant %s (item group %s)", source.ExternalID, group)),
+				Sources:          copySources,
+				Confidence:       p.Confidence,
+				RiskLevel:        p.RiskLevel,
+				Status:           p.Status,
+				QualityScore:     p.QualityScore,
+				QualityBreakdown: p.QualityBreakdown,
+				Module:           p.Module,
+				CreatedAt:        time.Now(),
+			})
+		}
+	}
+	return copies
+}
@@ -0,0 +1,245 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"golang.org/x/sync/singleflight"
+)
+
+// visionPrompt asks every image question in one call. Groups then read the
+// section they need, so an image is analyzed once per model however many
+// groups, retries or re-runs look at it within AGENT_VISION_CACHE_TTL.
+const visionPrompt = `Analyze this product image. Answer every question below, use null for anything not clearly visible. Be precise and factual.
+{
+  "attributes": {
+    "color": "main color(s)",
+    "color_confidence": "0-1, how sure the color is: lighting, filters or several variants in the photo lower it",
+    "material": "visible material (cotton, leather, metal, etc.)",
+    "pattern": "pattern if any (solid, striped, floral, etc.)",
+    "gender": "target gender if obvious (male/female/unisex)",
+    "product_type": "what type of product",
+    "style": "style description",
+    "observations": ["list of additional visual details"]
+  },
+  "quality": {
+    "lighting": "professional/amateur/poor",
+    "shadows": true/false,
+    "watermarks": true/false,
+    "text_overlay": true/false,
+    "text": [{"text": "every text visible, transcribed exactly", "kind": "overlay (added over the photo: banner, badge, price) / watermark / label (printed on the product or packaging)"}],
+    "quality_score": 0-100,
+    "issues": ["list of issues found"],
+    "recommendations": ["suggested improvements"]
+  },
+  "identifiers": {
+    "gtin": "digits printed under a barcode (8, 12 or 13 digits), exactly as printed",
+    "mpn": "model or reference number printed on the product, its label or packaging"
+  }
+}`
+
+// Sections of the vision answer
+const (
+	visionAttributes  = "attributes"
+	visionQuality     = "quality"
+	visionIdentifiers = "identifiers"
+)
+
+const visionCacheMaxEntries = 2000
+
+// visionAnalysis is the batched answer for one image
+type visionAnalysis struct {
+	sections map[string]json.RawMessage
+	usage    llm.Usage // usage of the call, saved again by every reuse
+	expires  time.Time
+}
+
+// visionCache keeps recent analyses by model and image URL
+type visionCache struct {
+	mu      sync.Mutex
+	entries map[string]*visionAnalysis
+	group   singleflight.Group
+}
+
+func newVisionCache() *visionCache {
+	return &visionCache{entries: map[string]*visionAnalysis{}}
+}
+
+func (c *visionCache) get(key string) *visionAnalysis {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expires) {
+		return e
+	}
+	return nil
+}
+
+func (c *visionCache) put(key string, e *visionAnalysis) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, old := range c.entries {
+		if now.After(old.expires) || len(c.entries) >= visionCacheMaxEntries {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = e
+}
+
+// imageContext returns the requested sections of the image analysis as prompt context
+func (a *Agent) imageContext(ctx context.Context, imageURL string, sections ...string) string {
+	if !a.config.Agent.EnableVision {
+		if a.callbacks.OnLog != nil {
+			a.callbacks.OnLog("⚠️ Vision disabled - skipping image analysis")
+		}
+		return ""
+	}
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog("👁️ Analyzing product image...")
+	}
+
+	analysis, err := a.analyzeImage(ctx, imageURL)
+	if err != nil {
+		if a.callbacks.OnLog != nil {
+			a.callbacks.OnLog(fmt.Sprintf("❌ Image analysis failed: %v", err))
+		}
+		return ""
+	}
+
+	picked := map[string]json.RawMessage{}
+	for _, s := range sections {
+		if v, ok := analysis[s]; ok {
+			picked[s] = v
+		}
+	}
+	if len(picked) == 0 {
+		return ""
+	}
+	content, _ := json.Marshal(picked)
+	if a.callbacks.OnLog != nil {
+		a.callbacks.OnLog(fmt.Sprintf("✅ Image: %s", content))
+	}
+	return "\n\n=== IMAGE ANALYSIS ===\n" + string(content)
+}
+
+// analyzeImage returns the batched analysis of an image, from cache when possible.
+// Concurrent requests for the same image share a single call.
+func (a *Agent) analyzeImage(ctx context.Context, imageURL string) (map[string]json.RawMessage, error) {
+	if llm.Simulated(ctx) {
+		// An estimate's empty analysis must not be served to real runs
+		analysis, err := a.callVision(ctx, imageURL)
+		if err != nil {
+			return nil, err
+		}
+		return analysis.sections, nil
+	}
+
+	key := a.visionModelFor() + "|" + imageURL
+	if cached := a.vision.get(key); cached != nil {
+		a.recordVisionReuse(ctx, cached)
+		return cached.sections, nil
+	}
+
+	called := false
+	v, err, _ := a.vision.group.Do(key, func() (any, error) {
+		called = true
+		return a.callVision(ctx, imageURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	analysis := v.(*visionAnalysis)
+	if !called {
+		a.recordVisionReuse(ctx, analysis)
+	} else {
+		a.vision.put(key, analysis)
+	}
+	return analysis.sections, nil
+}
+
+func (a *Agent) callVision(ctx context.Context, imageURL string) (*visionAnalysis, error) {
+	image, original, sent := a.visionImage(ctx, imageURL)
+
+	prompt := visionPrompt
+	if original != nil && original.Width > 0 {
+		// The model sees a downscaled copy, so tell it the real resolution
+		prompt += fmt.Sprintf("\n\nThe original image is %dx%d pixels.", original.Width, original.Height)
+	}
+
+	model := a.visionModelFor()
+	var sections map[string]json.RawMessage
+	resp, err := a.client.Structured(ctx, llm.Request{
+		Model:    model,
+		Stage:    llm.StageVision,
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: prompt, Images: []llm.Image{image}}},
+	}, &sections)
+	if resp == nil {
+		return nil, err
+	}
+	a.recordUsage(ctx, resp.Model, resp.Usage)
+	if err != nil {
+		return nil, fmt.Errorf("parse vision response: %w", err)
+	}
+
+	saved := 0
+	if original != nil && sent != nil {
+		saved = imageTokens(original.Width, original.Height, image.Detail) - imageTokens(sent.Width, sent.Height, image.Detail)
+	}
+	if m := usageMeterFrom(ctx); m != nil {
+		m.addVision(false, saved, a.costUSD(ctx, resp.Model, saved, 0))
+	}
+
+	return &visionAnalysis{
+		sections: sections,
+		usage:    resp.Usage,
+		expires:  time.Now().Add(a.config.Agent.VisionCacheTTL),
+	}, nil
+}
+
+// recordVisionReuse counts a cached analysis as a saved call
+func (a *Agent) recordVisionReuse(ctx context.Context, analysis *visionAnalysis) {
+	if m := usageMeterFrom(ctx); m != nil {
+		tokens := analysis.usage.PromptTokens + analysis.usage.CompletionTokens
+		m.addVision(true, tokens, a.costUSD(ctx, a.visionModelFor(), analysis.usage.PromptTokens, analysis.usage.CompletionTokens))
+	}
+}
+
+// visionImage returns the image to send: the copy downloaded through the image proxy,
+// downscaled to AGENT_VISION_MAX_SIZE, as a data URL; the original URL when the proxy
+// couldn't fetch it. original and sent are nil when unknown.
+func (a *Agent) visionImage(ctx context.Context, imageURL string) (image llm.Image, original, sent *imageproxy.Image) {
+	image = llm.Image{URL: imageURL, Detail: a.config.Agent.VisionDetail}
+	// Estimates do not fetch the image: every one is priced as a downscaled copy
+	if a.images == nil || llm.Simulated(ctx) {
+		return image, nil, nil
+	}
+	image.URL, original, sent = a.images.ForModel(ctx, imageURL, a.config.Agent.VisionMaxSize)
+	return image, original, sent
+}
+
+// imageTokens estimates the prompt tokens of an image with OpenAI's tile formula:
+// fit in 2048x2048, shortest side down to 768, then 170 tokens per 512px tile plus 85.
+// Low detail is a flat 85. Returns 0 for unknown dimensions.
+func imageTokens(width, height int, detail string) int {
+	if detail == "low" {
+		return 85
+	}
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+	w, h := float64(width), float64(height)
+	if longest := math.Max(w, h); longest > 2048 {
+		w, h = w*2048/longest, h*2048/longest
+	}
+	if shortest := math.Min(w, h); shortest > 768 {
+		w, h = w*768/shortest, h*768/shortest
+	}
+	tiles := math.Ceil(w/512) * math.Ceil(h/512)
+	return 85 + 170*int(tiles)
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/api/handlers"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeyHeader is the alternative to an Authorization: Bearer header
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyContext is where authenticate stores the key of the request
+const apiKeyContext = "api_key"
+
+// OrganizationHeader selects the organization (ID or slug) requests made with the
+// deployment's admin key, or without authentication, act for; the default one otherwise
+const OrganizationHeader = "X-Organization"
+
+// authenticate requires an active API key on every API request when API_AUTH_ENABLED is
+// set, and the read scope for GET requests. API keys are only read from headers; image
+// and export URLs carry a short-lived URL token instead (see IssueURLToken). Other methods declare their scope on the
+// route (see requireScope). The request then acts for the organization of the key:
+// only API_ADMIN_KEY, and every request when authentication is disabled, may select one
+// with X-Organization.
+func (s *Server) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !s.config.Server.AuthEnabled {
+			return s.selectOrganization(c, next)
+		}
+		req := c.Request()
+		secret := req.Header.Get(APIKeyHeader)
+		if auth := req.Header.Get(echo.HeaderAuthorization); secret == "" && strings.HasPrefix(auth, "Bearer ") {
+			secret = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if token := c.QueryParam(urlTokenParam); secret == "" && token != "" && acceptsURLToken(c) {
+			return s.authenticateURLToken(c, token, next)
+		}
+		if secret == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "API key required")
+		}
+
+		var key *models.APIKey
+		if admin := s.config.Server.AdminKey; admin != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(admin)) == 1 {
+			key = &models.APIKey{Name: "API_ADMIN_KEY", Scopes: []string{models.ScopeAdmin}}
+		} else {
+			var err error
+			key, err = s.queries.GetActiveAPIKey(req.Context(), secret)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid, expired or revoked API key")
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check API key")
+			}
+		}
+		c.Set(apiKeyContext, key)
+
+		if (req.Method == http.MethodGet || req.Method == http.MethodHead) && !key.Allows(models.ScopeRead) {
+			return echo.NewHTTPError(http.StatusForbidden, "API key lacks the read scope")
+		}
+		if key.ID == uuid.Nil {
+			return s.selectOrganization(c, next)
+		}
+		c.Set(handlers.OrgContext, key.OrgID)
+		return next(c)
+	}
+}
+
+// selectOrganization serves a platform request (see handlers.PlatformContext) for the
+// organization of X-Organization
+func (s *Server) selectOrganization(c echo.Context, next echo.HandlerFunc) error {
+	ref := c.Request().Header.Get(OrganizationHeader)
+	if ref == "" {
+		ref = db.DefaultOrganization
+	}
+	orgID, err := s.organizationID(c.Request().Context(), ref)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown organization "+ref)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load organization")
+	}
+	c.Set(handlers.OrgContext, orgID)
+	c.Set(handlers.PlatformContext, true)
+	return next(c)
+}
+
+// organizationID resolves an organization ID or slug. Organizations are never deleted
+// and their slug never changes, so resolutions are cached for good.
+func (s *Server) organizationID(ctx context.Context, ref string) (uuid.UUID, error) {
+	if id, ok := s.orgIDs.Load(ref); ok {
+		return id.(uuid.UUID), nil
+	}
+	org, err := s.queries.GetOrganization(ctx, ref)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	s.orgIDs.Store(ref, org.ID)
+	return org.ID, nil
+}
+
+// platform is the route middleware of deployment-wide settings (prompts, model prices,
+// retention...), shared by every organization: only API_ADMIN_KEY may change them
+func (s *Server) platform(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if platform, _ := c.Get(handlers.PlatformContext).(bool); !platform {
+			return echo.NewHTTPError(http.StatusForbidden, "Only API_ADMIN_KEY may manage deployment settings")
+		}
+		return next(c)
+	}
+}
+
+// recordKinds maps the resource of a route to the kind of record its :id is
+// (see db.RecordOrganization)
+var recordKinds = map[string]string{
+	"datasets":        "dataset",
+	"products":        "product",
+	"snapshots":       "snapshot",
+	"segments":        "segment",
+	"brand-aliases":   "brand_alias",
+	"trusted-domains": "trusted_domain",
+	"sessions":        "session",
+	"jobs":            "job",
+	"proposals":       "proposal",
+	"review-samples":  "review_sample",
+	"human-reviews":   "human_review",
+	"approval-rules":  "approval_rule",
+	"rules":           "rule",
+	"api-keys":        "api_key",
+	"users":           "user",
+}
+
+// isolate answers 404 to requests on a record, or filtered on a dataset (?dataset_id=),
+// of another organization than the request's. Records that don't exist are left to the
+// handler.
+func (s *Server) isolate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		orgID, _ := c.Get(handlers.OrgContext).(uuid.UUID)
+		ctx := c.Request().Context()
+		if kind, ok := recordKinds[resourceOf(c.Path())]; ok {
+			if id, err := uuid.Parse(c.Param("id")); err == nil {
+				if err := s.checkOrganization(ctx, kind, id, orgID); err != nil {
+					return err
+				}
+			}
+		}
+		if id, err := uuid.Parse(c.QueryParam("dataset_id")); err == nil {
+			if err := s.checkOrganization(ctx, "dataset", id, orgID); err != nil {
+				return err
+			}
+		}
+		return next(c)
+	}
+}
+
+func (s *Server) checkOrganization(ctx context.Context, kind string, id, orgID uuid.UUID) error {
+	owner, err := s.queries.RecordOrganization(ctx, kind, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check organization")
+	}
+	if owner != orgID {
+		return echo.ErrNotFound
+	}
+	return nil
+}
+
+// resourceOf returns the path segment naming the record of a route's :id
+// ("/api/v1/proposals/:id/revert" -> "proposals"), "" without one
+func resourceOf(path string) string {
+	parts := strings.Split(path, "/")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == ":id" {
+			return parts[i-1]
+		}
+	}
+	return ""
+}
+
+// requireScope returns a route middleware rejecting keys without the scope. It runs after
+// authenticate, the group middleware.
+func (s *Server) requireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if !s.config.Server.AuthEnabled {
+			return next
+		}
+		return func(c echo.Context) error {
+			key, _ := c.Get(apiKeyContext).(*models.APIKey)
+			if key == nil || !key.Allows(scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "API key lacks the "+scope+" scope")
+			}
+			return next(c)
+		}
+	}
+}
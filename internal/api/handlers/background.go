@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// errShuttingDown is returned when work is started after Drain began
+var errShuttingDown = errors.New("server is shutting down")
+
+// background tracks the enrichment goroutines started by requests so that
+// shutdown can wait for them instead of orphaning them mid-run
+type background struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	ctx      context.Context // cancelled when draining runs out of time
+	cancel   context.CancelFunc
+	closed   bool
+	products map[uuid.UUID]int // in-flight runs per product
+}
+
+func newBackground() *background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &background{ctx: ctx, cancel: cancel, products: map[uuid.UUID]int{}}
+}
+
+// Go runs fn for a product in a tracked goroutine; fn's context is cancelled if
+// the shutdown deadline passes before it returns
+func (b *background) Go(productID uuid.UUID, fn func(ctx context.Context)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errShuttingDown
+	}
+	b.wg.Add(1)
+	b.products[productID]++
+	go func() {
+		defer func() {
+			b.mu.Lock()
+			if b.products[productID]--; b.products[productID] <= 0 {
+				delete(b.products, productID)
+			}
+			b.mu.Unlock()
+			b.wg.Done()
+		}()
+		fn(b.ctx)
+	}()
+	return nil
+}
+
+// drain refuses new work and waits for running goroutines until ctx is done,
+// then cancels the rest and returns the products whose runs were interrupted
+func (b *background) drain(ctx context.Context) []uuid.UUID {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	interrupted := make([]uuid.UUID, 0, len(b.products))
+	for id := range b.products {
+		interrupted = append(interrupted, id)
+	}
+	b.mu.Unlock()
+
+	// Runs see a cancelled context and return without saving
+	b.cancel()
+	<-done
+	return interrupted
+}
+
+// Drain waits for in-flight enrichment started by EnrichProduct to finish and
+// persist. Runs still going when ctx expires are aborted and reported.
+func (h *Handlers) Drain(ctx context.Context) error {
+	interrupted := h.background.drain(ctx)
+	if len(interrupted) == 0 {
+		return nil
+	}
+	for _, id := range interrupted {
+		fmt.Printf("Shutdown: enrichment of product %s interrupted, nothing saved - re-run POST /api/v1/products/%s/enrich\n", id, id)
+	}
+	return fmt.Errorf("%d enrichment runs interrupted by shutdown", len(interrupted))
+}
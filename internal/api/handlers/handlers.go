@@ -4,19 +4,32 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/config"
 	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/imagecheck"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/jobs"
+	"github.com/benjamincozon/feedenrich/internal/llm"
 	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/retention"
+	"github.com/benjamincozon/feedenrich/internal/shadow"
+	"github.com/benjamincozon/feedenrich/internal/websearch"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
@@ -24,14 +37,58 @@ type Handlers struct {
 	config  *config.Config
 	queries *db.Queries
 	agent   *agent.Agent
+	runner  *jobs.Runner
+	shadow  *shadow.Evaluator
+	images  *imageproxy.Proxy
+	checks  *imagecheck.Checker
+
+	background *background // in-flight EnrichProduct runs, drained on shutdown
 }
 
-func NewHandlers(cfg *config.Config, queries *db.Queries, agnt *agent.Agent) *Handlers {
+func NewHandlers(cfg *config.Config, queries *db.Queries, agnt *agent.Agent, runner *jobs.Runner, shadowEval *shadow.Evaluator) *Handlers {
 	return &Handlers{
 		config:  cfg,
 		queries: queries,
 		agent:   agnt,
+		runner:  runner,
+		shadow:  shadowEval,
+		images:  imageproxy.New(cfg),
+		checks:  imagecheck.New(cfg, queries),
+
+		background: newBackground(),
+	}
+}
+
+// OrgContext is where the API stores the organization a request acts for (uuid.UUID):
+// its records are the only ones it sees
+const OrgContext = "org_id"
+
+// PlatformContext is set (true) on requests made with API_ADMIN_KEY, or without
+// authentication, which may manage the deployment and select their organization
+const PlatformContext = "platform"
+
+// orgID returns the organization of the request
+func orgID(c echo.Context) uuid.UUID {
+	id, _ := c.Get(OrgContext).(uuid.UUID)
+	return id
+}
+
+func platform(c echo.Context) bool {
+	p, _ := c.Get(PlatformContext).(bool)
+	return p
+}
+
+// checkDataset answers 404 unless the dataset belongs to the request's organization, for
+// datasets given in a request body (the API checks those of the path and query)
+func (h *Handlers) checkDataset(c echo.Context, datasetID uuid.UUID) error {
+	owner, err := h.queries.RecordOrganization(c.Request().Context(), "dataset", datasetID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && owner != orgID(c)) {
+		return echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
 	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load dataset")
+	}
+	return nil
 }
 
 // calculateAgentReadinessScore computes a score based on enrichment results
@@ -76,67 +133,149 @@ func (h *Handlers) UploadDataset(c echo.Context) error {
 		name = "Untitled Dataset"
 	}
 
+	datasetID := uuid.New()
+	filePath, err := h.saveUpload(c, datasetID)
+	if err != nil {
+		return err
+	}
+
+	// Parse the file to get the products
+	_, products, err := h.parseFile(filePath)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to parse file: %v", err))
+	}
+
+	// Rows the unique constraint would reject are dropped before the COPY
+	products, skipped := uniqueProducts(products)
+	if skipped > 0 {
+		fmt.Printf("Dataset %s: skipped %d rows with a duplicate or oversized id\n", datasetID, skipped)
+	}
+
+	// Create the dataset and its products in one transaction; the row count is what was copied
+	dataset := models.Dataset{
+		ID:            datasetID,
+		OrgID:         orgID(c),
+		Name:          name,
+		SourceFileURL: filePath,
+		Status:        "uploaded",
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if err := h.queries.CreateDatasetWithProducts(c.Request().Context(), &dataset, products); err != nil {
+		fmt.Printf("Failed to create dataset: %v\n", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create dataset")
+	}
+
+	return c.JSON(http.StatusCreated, dataset)
+}
+
+// ReimportDataset loads a new export of a dataset's feed: products are matched on their
+// external ID, new ones are inserted and changed ones updated, and the import is recorded as
+// a dataset version
+func (h *Handlers) ReimportDataset(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	ctx := c.Request().Context()
+	if _, err := h.queries.GetDataset(ctx, id); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	}
+
+	filePath, err := h.saveUpload(c, id)
+	if err != nil {
+		return err
+	}
+	rowCount, products, err := h.parseFile(filePath)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to parse file: %v", err))
+	}
+	products, skipped := uniqueProducts(products)
+	if skipped > 0 {
+		fmt.Printf("Dataset %s: skipped %d rows with a duplicate or oversized id\n", id, skipped)
+	}
+
+	result, err := h.queries.UpsertProducts(ctx, id, products)
+	if err != nil {
+		fmt.Printf("Failed to re-import products: %v\n", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to import products")
+	}
+
+	version := models.DatasetVersion{
+		ID:        uuid.New(),
+		DatasetID: id,
+		FileName:  filepath.Base(filePath),
+		RowCount:  rowCount,
+		CreatedAt: time.Now(),
+		CreatedBy: c.FormValue("created_by"),
+		Notes: fmt.Sprintf("Re-import: %d inserted, %d updated, %d unchanged",
+			result.Inserted, result.Updated, result.Unchanged),
+	}
+	if version.VersionNumber, err = h.queries.GetNextVersionNumber(ctx, id); err == nil {
+		err = h.queries.CreateDatasetVersion(ctx, version)
+	}
+	if err != nil {
+		fmt.Printf("Failed to record dataset version for %s: %v\n", id, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"status":    "imported",
+		"version":   version.VersionNumber,
+		"inserted":  result.Inserted,
+		"updated":   result.Updated,
+		"unchanged": result.Unchanged,
+		"skipped":   skipped,
+	})
+}
+
+// saveUpload stores the uploaded "file" form field under STORAGE_PATH, named after the dataset
+// (parseFile reads the dataset ID back from the name)
+func (h *Handlers) saveUpload(c echo.Context, datasetID uuid.UUID) (string, error) {
 	file, err := c.FormFile("file")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "No file uploaded")
+		return "", echo.NewHTTPError(http.StatusBadRequest, "No file uploaded")
 	}
 
 	src, err := file.Open()
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to open file")
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "Failed to open file")
 	}
 	defer src.Close()
 
 	// Save file locally (in production, use S3/GCS)
 	uploadDir := h.config.Storage.Path
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create upload dir")
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "Failed to create upload dir")
 	}
 
-	datasetID := uuid.New()
-	filename := fmt.Sprintf("%s_%s", datasetID.String(), file.Filename)
+	filename := fmt.Sprintf("%s_%s", datasetID.String(), filepath.Base(file.Filename))
 	filePath := filepath.Join(uploadDir, filename)
 
 	dst, err := os.Create(filePath)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save file")
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "Failed to save file")
 	}
 	defer dst.Close()
 
 	if _, err := io.Copy(dst, src); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to copy file")
-	}
-
-	// Parse the file to get row count and detect schema
-	rowCount, products, err := h.parseFile(filePath)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to parse file: %v", err))
-	}
-
-	// Create dataset in DB
-	dataset := models.Dataset{
-		ID:            datasetID,
-		Name:          name,
-		SourceFileURL: filePath,
-		RowCount:      rowCount,
-		Status:        "uploaded",
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
-	}
-
-	if err := h.queries.CreateDataset(c.Request().Context(), dataset); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create dataset")
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "Failed to copy file")
 	}
+	return filePath, nil
+}
 
-	// Create products
+// uniqueProducts keeps the first product for each external ID and drops IDs longer than
+// the column allows, returning how many were skipped
+func uniqueProducts(products []models.Product) ([]models.Product, int) {
+	seen := make(map[string]bool, len(products))
+	kept := products[:0]
 	for _, p := range products {
-		if err := h.queries.CreateProduct(c.Request().Context(), p); err != nil {
-			// Log error but continue
-			fmt.Printf("Failed to create product: %v\n", err)
+		if seen[p.ExternalID] || utf8.RuneCountInString(p.ExternalID) > 255 {
+			continue
 		}
+		seen[p.ExternalID] = true
+		kept = append(kept, p)
 	}
-
-	return c.JSON(http.StatusCreated, dataset)
+	return kept, len(products) - len(kept)
 }
 
 func (h *Handlers) parseFile(filePath string) (int, []models.Product, error) {
@@ -251,6 +390,7 @@ func (h *Handlers) parseFile(filePath string) (int, []models.Product, error) {
 
 	var products []models.Product
 	rowCount := 0
+	validator := tools.NewHardRuleValidator()
 
 	datasetID := uuid.MustParse(filepath.Base(filePath)[:36])
 
@@ -274,6 +414,7 @@ func (h *Handlers) parseFile(filePath string) (int, []models.Product, error) {
 		}
 
 		rawData, _ := json.Marshal(data)
+		baseline := jobs.RuleScore(validator, rawData)
 
 		// Get external ID
 		externalID := ""
@@ -286,24 +427,26 @@ func (h *Handlers) parseFile(filePath string) (int, []models.Product, error) {
 		}
 
 		products = append(products, models.Product{
-			ID:          uuid.New(),
-			DatasetID:   datasetID,
-			ExternalID:  externalID,
-			RawData:     rawData,
-			CurrentData: rawData,
-			Version:     1,
-			Status:      "pending",
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			ID:            uuid.New(),
+			DatasetID:     datasetID,
+			ExternalID:    externalID,
+			RawData:       rawData,
+			CurrentData:   rawData,
+			Version:       1,
+			Status:        "pending",
+			BaselineScore: &baseline,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
 		})
 	}
 
 	return rowCount, products, nil
 }
 
-// ListDatasets returns all datasets
+// ListDatasets returns the datasets of the organization
 func (h *Handlers) ListDatasets(c echo.Context) error {
-	datasets, err := h.queries.ListDatasets(c.Request().Context())
+	org := orgID(c)
+	datasets, err := h.queries.ListDatasets(c.Request().Context(), &org)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list datasets")
 	}
@@ -351,7 +494,12 @@ func (h *Handlers) ExportDataset(c echo.Context) error {
 		format = "tsv"
 	}
 
-	products, err := h.queries.ListProductsByDataset(c.Request().Context(), id)
+	segmentID, err := h.resolveSegment(c, id, c.QueryParam("segment_id"))
+	if err != nil {
+		return err
+	}
+
+	products, err := h.queries.ListProductsInScope(c.Request().Context(), id, segmentID, nil)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get products")
 	}
@@ -375,7 +523,12 @@ func (h *Handlers) GetDatasetStats(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
 	}
 
-	stats, err := h.queries.GetDatasetStats(c.Request().Context(), id)
+	// Score what changed since the last read; stale aggregates are still served on failure
+	ctx := c.Request().Context()
+	if _, err := jobs.ScoreDataset(ctx, h.queries, id); err != nil {
+		fmt.Printf("Failed to score dataset %s: %v\n", id, err)
+	}
+	stats, err := h.queries.GetDatasetStats(ctx, id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get stats")
 	}
@@ -383,21 +536,199 @@ func (h *Handlers) GetDatasetStats(c echo.Context) error {
 	return c.JSON(http.StatusOK, stats)
 }
 
-// ListProducts returns products for a dataset
+// ListProducts returns a page of the products of a dataset, filtered by tags, status,
+// readiness score range and a search over external_id and title
 func (h *Handlers) ListProducts(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
 	}
 
-	products, err := h.queries.ListProductsByDataset(c.Request().Context(), id)
+	filter := db.ProductFilter{
+		Tags:   normalizeTags(c.QueryParams()["tag"]),
+		Status: c.QueryParam("status"),
+		Search: c.QueryParam("q"),
+		Sort:   c.QueryParam("sort"),
+		Limit:  100,
+	}
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &filter.Limit)
+	}
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 1000")
+	}
+	if o := c.QueryParam("offset"); o != "" {
+		fmt.Sscanf(o, "%d", &filter.Offset)
+	}
+	if filter.Offset < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "offset must not be negative")
+	}
+	if filter.MinScore, err = scoreParam(c, "min_score"); err != nil {
+		return err
+	}
+	if filter.MaxScore, err = scoreParam(c, "max_score"); err != nil {
+		return err
+	}
+	if !db.ValidProductSort(filter.Sort) {
+		return echo.NewHTTPError(http.StatusBadRequest, "sort must be one of created_at, updated_at, external_id, title, status, score, optionally prefixed by -")
+	}
+
+	products, total, err := h.queries.ListProductsPage(c.Request().Context(), id, filter)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
 	}
 
+	return c.JSON(http.StatusOK, map[string]any{
+		"data":   products,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// SearchProducts finds products of a dataset by exact external_id or GTIN, or by the words
+// of their title and description (?q=, ?limit=)
+func (h *Handlers) SearchProducts(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	query := strings.TrimSpace(c.QueryParam("q"))
+	if query == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "q is required")
+	}
+	limit := 50
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit <= 0 || limit > 500 {
+		return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 500")
+	}
+
+	products, err := h.queries.SearchProducts(c.Request().Context(), id, query, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to search products")
+	}
 	return c.JSON(http.StatusOK, map[string]any{"data": products})
 }
 
+// scoreParam parses an optional score or confidence query parameter (0-1)
+func scoreParam(c echo.Context, name string) (*float64, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return nil, nil
+	}
+	score, err := strconv.ParseFloat(raw, 64)
+	if err != nil || score < 0 || score > 1 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, name+" must be a number between 0 and 1")
+	}
+	return &score, nil
+}
+
+// normalizeTags trims, splits comma-separated values and dedupes tags
+func normalizeTags(values []string) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" || seen[t] {
+				continue
+			}
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// UpdateProduct updates product tags and notes
+func (h *Handlers) UpdateProduct(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid product ID")
+	}
+
+	product, err := h.queries.GetProduct(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Product not found")
+	}
+
+	var req struct {
+		Tags  *[]string `json:"tags"`
+		Notes *string   `json:"notes"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	if req.Tags != nil {
+		product.Tags = normalizeTags(*req.Tags)
+	}
+	if req.Notes != nil {
+		product.Notes = *req.Notes
+	}
+
+	if err := h.queries.UpdateProductTags(c.Request().Context(), id, product.Tags, product.Notes); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update product")
+	}
+
+	return c.JSON(http.StatusOK, product)
+}
+
+// AddProductTag adds a single tag to a product
+func (h *Handlers) AddProductTag(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid product ID")
+	}
+
+	var req struct {
+		Tag string `json:"tag"`
+	}
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Tag) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Tag is required")
+	}
+
+	if err := h.queries.AddProductTag(c.Request().Context(), id, strings.TrimSpace(req.Tag)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to add tag")
+	}
+
+	product, err := h.queries.GetProduct(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Product not found")
+	}
+	return c.JSON(http.StatusOK, product)
+}
+
+// RemoveProductTag removes a single tag from a product
+func (h *Handlers) RemoveProductTag(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid product ID")
+	}
+
+	if err := h.queries.RemoveProductTag(c.Request().Context(), id, c.Param("tag")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to remove tag")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListDatasetTags returns tags used in a dataset with product counts
+func (h *Handlers) ListDatasetTags(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	tags, err := h.queries.ListDatasetTags(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list tags")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": tags})
+}
+
 // GetProduct returns a single product
 func (h *Handlers) GetProduct(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
@@ -427,51 +758,116 @@ func (h *Handlers) EnrichProduct(c echo.Context) error {
 
 	var req struct {
 		Goal   string         `json:"goal"`
+		Mode   string         `json:"mode"`   // agent, pipeline, fast_pipeline, tools; default the dataset's
+		Groups []string       `json:"groups"` // optimization groups to run (default all)
 		Config map[string]any `json:"config"`
+		models.ThresholdOverrides
 	}
 	if err := c.Bind(&req); err != nil {
 		req.Goal = "GMC compliance + agent readiness"
 	}
 
-	// Run agent in background with separate context
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	thresholds, err := h.resolveThresholds(c, product.DatasetID, req.ThresholdOverrides)
+	if err != nil {
+		return err
+	}
+	groups, err := parseGroups(req.Groups)
+	if err != nil {
+		return err
+	}
+	engine, err := h.resolveEngine(c, product.DatasetID, req.Mode)
+	if err != nil {
+		return err
+	}
+	if engine, err = groupsEngine(groups, engine, req.Mode); err != nil {
+		return err
+	}
+	if err := h.checkBudget(c, product.DatasetID); err != nil {
+		return err
+	}
+
+	// Run agent in background with separate context; shutdown waits for it (see Drain)
+	err = h.background.Go(product.ID, func(bg context.Context) {
+		runCtx, cancel := context.WithTimeout(agent.WithResumable(agent.WithThresholds(bg, thresholds)), 5*time.Minute)
 		defer cancel()
+		// Once the run is done, saving must not be cut short by the shutdown deadline
+		ctx := context.WithoutCancel(runCtx)
 		
-		fmt.Printf("Starting agent for product %s with goal: %s\n", product.ID, req.Goal)
+		fmt.Printf("Starting %s for product %s with goal: %s\n", engine, product.ID, req.Goal)
 		
-		session, err := h.agent.Run(ctx, product, req.Goal)
+		// Shadow runs compare models on a single group of the agent engine
+		var shadowRun *shadow.Run
+		if engine == agent.EngineAgent && len(groups) == 1 {
+			shadowRun = h.shadow.Start(product, groups[0], nil)
+		}
+		session, err := h.agent.RunEngine(runCtx, product, req.Goal, groups, engine)
 		if err != nil {
+			shadowRun.Complete(nil, err)
 			fmt.Printf("Agent error for product %s: %v\n", product.ID, err)
+			if session != nil {
+				if err := h.queries.CreateLLMCalls(ctx, session.LLMCalls); err != nil {
+					fmt.Printf("Failed to save LLM call log for %s: %v\n", product.ID, err)
+				}
+			}
 			return
 		}
+		shadowRun.Complete(session.Proposals, nil)
+		h.saveEnrichment(ctx, product, groups, session)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Server is shutting down")
+	}
 
-		fmt.Printf("Agent completed for product %s: %d steps, %d proposals\n", product.ID, len(session.Traces), len(session.Proposals))
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status":     "started",
+		"message":    "Agent enrichment started",
+		"engine":     engine,
+		"groups":     groups,
+		"thresholds": thresholds,
+	})
+}
 
-		// Save session and proposals to DB
+// saveEnrichment saves the session of a single-product run and updates the product. A
+// paused session is only saved: the product is updated when it completes.
+func (h *Handlers) saveEnrichment(ctx context.Context, product *models.Product, groups []agent.OptimizationGroup, session *agent.Session) {
+	if session.Status == "paused" {
+		fmt.Printf("Agent paused for product %s after %d steps\n", product.ID, len(session.Traces))
 		if err := h.queries.CreateAgentSession(ctx, *session); err != nil {
-			fmt.Printf("Failed to save session for product %s: %v\n", product.ID, err)
+			fmt.Printf("Failed to save paused session for product %s: %v\n", product.ID, err)
 		}
+		return
+	}
 
-		// Calculate agent readiness score based on proposals
-		score := calculateAgentReadinessScore(session)
-		status := "enriched"
-		if len(session.Proposals) == 0 {
-			status = "pending" // No proposals generated
-		}
+	fmt.Printf("Agent completed for product %s: %d steps, %d proposals\n", product.ID, len(session.Traces), len(session.Proposals))
 
-		// Update product with score
-		if err := h.queries.UpdateProductAfterEnrichment(ctx, product.ID, score, status); err != nil {
-			fmt.Printf("Failed to update product score for %s: %v\n", product.ID, err)
-		} else {
-			fmt.Printf("Updated product %s: score=%.2f, status=%s\n", product.ID, score, status)
+	// Save session and proposals to DB
+	if err := h.queries.CreateAgentSession(ctx, *session); err != nil {
+		fmt.Printf("Failed to save session for product %s: %v\n", product.ID, err)
+	}
+	for _, prop := range h.runner.VariantProposals(ctx, product, session.Proposals) {
+		if err := h.queries.CreateProposal(ctx, prop); err != nil {
+			fmt.Printf("Failed to save variant proposal: %v\n", err)
+		}
+	}
+	for _, group := range groups {
+		if err := h.queries.RecordEnrichmentRun(ctx, product.ID, string(group), jobs.CountModule(session.Proposals, group), jobs.ComplianceScore(product)); err != nil {
+			fmt.Printf("Failed to record enrichment run for %s: %v\n", product.ID, err)
 		}
-	}()
+	}
 
-	return c.JSON(http.StatusAccepted, map[string]string{
-		"status":  "started",
-		"message": "Agent enrichment started",
-	})
+	// Calculate agent readiness score based on proposals
+	score := calculateAgentReadinessScore(session)
+	status := "enriched"
+	if len(session.Proposals) == 0 {
+		status = "pending" // No proposals generated
+	}
+
+	// Update product with score
+	if err := h.queries.UpdateProductAfterEnrichment(ctx, product.ID, score, status); err != nil {
+		fmt.Printf("Failed to update product score for %s: %v\n", product.ID, err)
+	} else {
+		fmt.Printf("Updated product %s: score=%.2f, status=%s\n", product.ID, score, status)
+	}
 }
 
 // EnrichDataset starts batch enrichment for all products
@@ -481,56 +877,328 @@ func (h *Handlers) EnrichDataset(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
 	}
 
-	// Create a job (in production, this would be queued)
-	job := models.Job{
-		ID:        uuid.New(),
-		DatasetID: id,
-		Type:      "enrich_all",
-		Status:    "pending",
-		CreatedAt: time.Now(),
+	var req struct {
+		Tags       []string `json:"tags"`        // only enrich products carrying all these tags
+		SegmentID  string   `json:"segment_id"`  // only enrich products in this saved segment
+		DryRun     bool     `json:"dry_run"`     // run the pipeline, store simulated proposals and project cost
+		SampleSize int      `json:"sample_size"` // dry run only: products to actually run (0 = all)
+		Group      string   `json:"group"`       // dry run only: optimization group (default all)
+		Groups     []string `json:"groups"`      // optimization groups to run (default all)
+		Batch      bool     `json:"batch"`       // submit through the provider's batch API (half price, results within 24h)
+		Mode       string   `json:"mode"`        // agent, pipeline, fast_pipeline, tools; default the dataset's
+		// Similar products (same brand and category) optimized per call, 2-10; 0 = one per call
+		MultiProduct int `json:"multi_product"`
+		models.ThresholdOverrides
 	}
+	c.Bind(&req)
 
-	if err := h.queries.CreateJob(c.Request().Context(), job); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create job")
+	segmentID, err := h.resolveSegment(c, id, req.SegmentID)
+	if err != nil {
+		return err
 	}
-
-	return c.JSON(http.StatusAccepted, job)
-}
-
-// GetAuditGroups returns available optimization groups
-func (h *Handlers) GetAuditGroups(c echo.Context) error {
-	groups := agent.GetAllGroups()
-	return c.JSON(http.StatusOK, map[string]any{"groups": groups})
-}
-
-// AuditDataset runs a specific optimization group on all products
-func (h *Handlers) AuditDataset(c echo.Context) error {
-	id, err := uuid.Parse(c.Param("id"))
+	thresholds, err := h.resolveThresholds(c, id, req.ThresholdOverrides)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+		return err
 	}
-
-	var req struct {
-		Group string `json:"group"`
+	if err := h.checkBudget(c, id); err != nil {
+		return err
 	}
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	engine, err := h.resolveEngine(c, id, req.Mode)
+	if err != nil {
+		return err
+	}
+	if (req.DryRun || req.Batch) && req.Mode != "" && engine != agent.EngineAgent {
+		return echo.NewHTTPError(http.StatusBadRequest, "Dry runs and batch enrichment use the agent engine")
+	}
+	groups, err := parseGroups(req.Groups)
+	if err != nil {
+		return err
 	}
 
-	// Validate group
-	validGroup := false
-	for _, g := range agent.GetAllGroups() {
-		if string(g.ID) == req.Group {
-			validGroup = true
-			break
+	if req.DryRun {
+		group := req.Group
+		if len(req.Groups) > 0 {
+			if len(groups) > 1 || (group != "" && group != string(groups[0])) {
+				return echo.NewHTTPError(http.StatusBadRequest, "A dry run takes a single group")
+			}
+			group = string(groups[0])
+		}
+		return h.dryRunDataset(c, id, segmentID, normalizeTags(req.Tags), group, req.SampleSize, thresholds)
+	}
+	if req.Batch {
+		if len(groups) > 1 || groups[0] != agent.GroupAll {
+			return echo.NewHTTPError(http.StatusBadRequest, "Batch enrichment runs the all group")
+		}
+		return h.batchEnrichDataset(c, id, segmentID, normalizeTags(req.Tags), thresholds)
+	}
+	if engine, err = groupsEngine(groups, engine, req.Mode); err != nil {
+		return err
+	}
+	if req.MultiProduct > 1 {
+		if req.MultiProduct > agent.MaxMultiProducts {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("multi_product takes at most %d products per call", agent.MaxMultiProducts))
+		}
+		if len(groups) > 1 || groups[0] != agent.GroupAll || engine != agent.EngineAgent {
+			return echo.NewHTTPError(http.StatusBadRequest, "Multi-product enrichment runs the all group on the agent engine")
+		}
+	}
+
+	products, err := h.queries.ListProductsInScope(c.Request().Context(), id, segmentID, normalizeTags(req.Tags))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
+	}
+
+	// Queued like an audit: every product runs the groups in one session
+	job := models.JobWithDetails{
+		Job: models.Job{
+			ID:        uuid.New(),
+			DatasetID: id,
+			Type:      "enrich_all",
+			Status:    "pending",
+			CreatedAt: time.Now(),
+		},
+		Module:     jobs.GroupsLabel(groups),
+		Priority:   jobs.DefaultPriority,
+		TotalItems: len(products),
+		Logs:       []models.JobLog{},
+	}
+	cfg := jobs.AuditConfig{Group: groups[0], Tags: normalizeTags(req.Tags), SegmentID: segmentID, Thresholds: &thresholds, Engine: engine}
+	if len(groups) > 1 {
+		cfg.Groups = groups
+	}
+	if req.MultiProduct > 1 {
+		cfg.MultiProduct = req.MultiProduct
+	}
+	job.Config, _ = json.Marshal(cfg)
+
+	if err := h.queries.CreateJobWithDetails(c.Request().Context(), job); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create job")
+	}
+	h.runner.StartAudit(job, products)
+
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// batchEnrichDataset runs the fast mode on every product in scope through the batch API:
+// proposals appear when the provider completes the batch, within 24 hours
+func (h *Handlers) batchEnrichDataset(c echo.Context, datasetID uuid.UUID, segmentID *uuid.UUID, tags []string, thresholds models.Thresholds) error {
+	if !h.runner.SupportsBatch() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Batch enrichment requires LLM_PROVIDER=openai")
+	}
+
+	products, err := h.queries.ListProductsInScope(c.Request().Context(), datasetID, segmentID, tags)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
+	}
+	if len(products) > llm.MaxBatchRequests {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("A batch takes at most %d products, narrow the scope with tags or a segment", llm.MaxBatchRequests))
+	}
+
+	job := models.JobWithDetails{
+		Job: models.Job{
+			ID:        uuid.New(),
+			DatasetID: datasetID,
+			Type:      "enrich_all",
+			Status:    "pending",
+			CreatedAt: time.Now(),
+		},
+		Module:     string(agent.GroupAll),
+		Priority:   jobs.DefaultPriority,
+		TotalItems: len(products),
+		Logs:       []models.JobLog{},
+	}
+	job.Config, _ = json.Marshal(jobs.AuditConfig{
+		Group:      agent.GroupAll,
+		Tags:       tags,
+		SegmentID:  segmentID,
+		Thresholds: &thresholds,
+		Batch:      &jobs.BatchConfig{},
+	})
+	if err := h.queries.CreateJobWithDetails(c.Request().Context(), job); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create job")
+	}
+
+	h.runner.StartBatch(job, products)
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status":         "started",
+		"batch":          true,
+		"job_id":         job.ID,
+		"total_products": len(products),
+		"message":        fmt.Sprintf("Preparing a batch of %d products, proposals are created when it completes", len(products)),
+	})
+}
+
+// dryRunDataset queues an enrichment whose proposals are stored as simulated. The job report
+// gives proposal counts and token cost, projected to the whole scope when only a sample is run.
+func (h *Handlers) dryRunDataset(c echo.Context, datasetID uuid.UUID, segmentID *uuid.UUID, tags []string, rawGroup string, sampleSize int, thresholds models.Thresholds) error {
+	group, err := scheduleGroup(rawGroup)
+	if err != nil {
+		return err
+	}
+
+	products, err := h.queries.ListProductsInScope(c.Request().Context(), datasetID, segmentID, tags)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
+	}
+	if sampleSize < 0 {
+		sampleSize = 0
+	}
+	sample := jobs.SampleProducts(products, sampleSize)
+
+	cfg := jobs.AuditConfig{
+		Group:     group,
+		Tags:      tags,
+		SegmentID: segmentID,
+		DryRun:     &jobs.DryRunConfig{ScopeProducts: len(products), SampleSize: sampleSize},
+		Thresholds: &thresholds,
+	}
+	if len(sample) < len(products) {
+		// Resume must run the same sample
+		for _, p := range sample {
+			cfg.ProductIDs = append(cfg.ProductIDs, p.ID)
+		}
+	}
+
+	job := models.JobWithDetails{
+		Job: models.Job{
+			ID:        uuid.New(),
+			DatasetID: datasetID,
+			Type:      "dry_run",
+			Status:    "pending",
+			CreatedAt: time.Now(),
+		},
+		Module:     string(group),
+		Priority:   jobs.DefaultPriority,
+		TotalItems: len(sample),
+		Logs:       []models.JobLog{},
+	}
+	job.Config, _ = json.Marshal(cfg)
+	if err := h.queries.CreateJobWithDetails(c.Request().Context(), job); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create job")
+	}
+
+	h.runner.StartAudit(job, sample)
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status":         "started",
+		"dry_run":        true,
+		"job_id":         job.ID,
+		"group":          group,
+		"scope_products": len(products),
+		"sample_size":    len(sample),
+		"message":        fmt.Sprintf("Dry run on %d of %d products, see the job report for projected cost", len(sample), len(products)),
+	})
+}
+
+// EstimateDataset projects the cost, tokens and time of enriching a dataset with the
+// given optimization groups, from a simulated run of a sample (nothing is sent or stored)
+func (h *Handlers) EstimateDataset(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req struct {
+		Groups     []string `json:"groups"`      // optimization groups to run (default all)
+		SampleSize int      `json:"sample_size"` // products simulated per group (default 20, at most 200)
+		Tags       []string `json:"tags"`
+		SegmentID  string   `json:"segment_id"`
+		models.ThresholdOverrides
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if req.SampleSize <= 0 {
+		req.SampleSize = 20
+	}
+	if req.SampleSize > 200 {
+		return echo.NewHTTPError(http.StatusBadRequest, "sample_size must be at most 200")
+	}
+	groups, err := parseGroups(req.Groups)
+	if err != nil {
+		return err
+	}
+
+	segmentID, err := h.resolveSegment(c, id, req.SegmentID)
+	if err != nil {
+		return err
+	}
+	thresholds, err := h.resolveThresholds(c, id, req.ThresholdOverrides)
+	if err != nil {
+		return err
+	}
+	products, err := h.queries.ListProductsInScope(c.Request().Context(), id, segmentID, normalizeTags(req.Tags))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
+	}
+
+	return c.JSON(http.StatusOK, h.runner.Estimate(c.Request().Context(), products, req.SampleSize, groups, thresholds))
+}
+
+// GetAuditGroups returns available optimization groups
+func (h *Handlers) GetAuditGroups(c echo.Context) error {
+	groups := agent.GetAllGroups()
+	return c.JSON(http.StatusOK, map[string]any{"groups": groups})
+}
+
+// AuditDataset runs a specific optimization group on all products
+func (h *Handlers) AuditDataset(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req struct {
+		Group    string   `json:"group"`
+		Tags      []string `json:"tags"`       // only audit products carrying all these tags
+		SegmentID string   `json:"segment_id"` // only audit products in this saved segment
+		Priority  *int     `json:"priority"`   // 1 (lowest) - 10 (highest), default 5
+		Mode      string   `json:"mode"`       // agent, pipeline, fast_pipeline, tools; default the dataset's
+		models.ThresholdOverrides
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	tags := normalizeTags(req.Tags)
+
+	// Validate group
+	validGroup := false
+	for _, g := range agent.GetAllGroups() {
+		if string(g.ID) == req.Group {
+			validGroup = true
+			break
 		}
 	}
 	if !validGroup {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid optimization group")
 	}
 
+	segmentID, err := h.resolveSegment(c, id, req.SegmentID)
+	if err != nil {
+		return err
+	}
+	thresholds, err := h.resolveThresholds(c, id, req.ThresholdOverrides)
+	if err != nil {
+		return err
+	}
+	if err := h.checkBudget(c, id); err != nil {
+		return err
+	}
+	engine, err := h.resolveEngine(c, id, req.Mode)
+	if err != nil {
+		return err
+	}
+	if engine != agent.EngineAgent && req.Group != string(agent.GroupAll) {
+		if req.Mode != "" {
+			return echo.NewHTTPError(http.StatusBadRequest, agent.ErrEngineGroup.Error())
+		}
+		// The dataset's default pipeline doesn't apply to single-group audits
+		engine = agent.EngineAgent
+	}
+
 	// Get products for this dataset
-	products, err := h.queries.ListProductsByDataset(c.Request().Context(), id)
+	products, err := h.queries.ListProductsInScope(c.Request().Context(), id, segmentID, tags)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
 	}
@@ -556,90 +1224,29 @@ func (h *Handlers) AuditDataset(c echo.Context) error {
 			CreatedAt: time.Now(),
 		},
 		Module:     string(group),
+		Priority:   jobs.DefaultPriority,
 		TotalItems: len(products),
 		Logs:       []models.JobLog{},
 	}
+	if req.Priority != nil {
+		job.Priority = jobs.ClampPriority(*req.Priority)
+	}
+	job.Config, _ = json.Marshal(jobs.AuditConfig{Group: group, Tags: tags, SegmentID: segmentID, Thresholds: &thresholds, Engine: engine})
 	
 	if err := h.queries.CreateJobWithDetails(c.Request().Context(), job); err != nil {
 		fmt.Printf("Failed to create job record: %v\n", err)
 	}
 
 	// Process products in background
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-		defer cancel()
-		
-		// Update job status to running
-		h.queries.UpdateJobStatus(ctx, job.ID, "running", nil)
-		h.queries.UpdateJobProgress(ctx, job.ID, 0, 0, &models.JobLog{
-			Timestamp: time.Now(),
-			Level:     "info",
-			Message:   fmt.Sprintf("Starting %s audit for %d products", group, len(products)),
-		})
-		
-		fmt.Printf("Starting audit group %s for dataset %s (%d products)\n", group, id, len(products))
-		
-		processedCount := 0
-		proposalCount := 0
-		errorCount := 0
-		
-		for i := range products {
-			session, err := h.agent.RunWithGroup(ctx, &products[i], "Audit: "+string(group), group)
-			if err != nil {
-				fmt.Printf("Audit error for product %s: %v\n", products[i].ID, err)
-				errorCount++
-				h.queries.UpdateJobProgress(ctx, job.ID, processedCount+1, proposalCount, &models.JobLog{
-					Timestamp: time.Now(),
-					Level:     "error",
-					Message:   fmt.Sprintf("Error processing %s: %v", products[i].ExternalID, err),
-				})
-				continue
-			}
-			
-			processedCount++
-			proposalCount += len(session.Proposals)
-			
-			// Save proposals to DB with module tag
-			for _, prop := range session.Proposals {
-				if err := h.queries.CreateProposal(ctx, prop); err != nil {
-					fmt.Printf("Failed to save proposal: %v\n", err)
-				}
-			}
-			
-			// Update job progress every product
-			logMsg := fmt.Sprintf("Processed %s: %d proposals", products[i].ExternalID, len(session.Proposals))
-			h.queries.UpdateJobProgress(ctx, job.ID, processedCount, proposalCount, &models.JobLog{
-				Timestamp: time.Now(),
-				Level:     "success",
-				Message:   logMsg,
-			})
-			
-			fmt.Printf("Audit %s: product %d/%d - %d proposals\n", group, processedCount, len(products), len(session.Proposals))
-		}
-		
-		// Mark job as completed
-		h.queries.UpdateJobProgress(ctx, job.ID, processedCount, proposalCount, &models.JobLog{
-			Timestamp: time.Now(),
-			Level:     "info",
-			Message:   fmt.Sprintf("Completed: %d products, %d proposals, %d errors", processedCount, proposalCount, errorCount),
-		})
-		
-		if errorCount > 0 && errorCount == len(products) {
-			errMsg := fmt.Sprintf("All %d products failed", errorCount)
-			h.queries.UpdateJobStatus(ctx, job.ID, "failed", &errMsg)
-		} else {
-			h.queries.UpdateJobStatus(ctx, job.ID, "completed", nil)
-		}
-		
-		fmt.Printf("Audit %s completed: %d/%d products, %d proposals, %d errors\n", 
-			group, processedCount, len(products), proposalCount, errorCount)
-	}()
+	h.runner.StartAudit(job, products)
 
 	return c.JSON(http.StatusAccepted, map[string]any{
 		"status":         "started",
 		"job_id":         job.ID,
 		"group":          group,
+		"engine":         engine,
 		"total_products": len(products),
+		"thresholds":     thresholds,
 		"message":        fmt.Sprintf("Started %s audit for %d products", group, len(products)),
 	})
 }
@@ -674,176 +1281,541 @@ func (h *Handlers) GetAgentTrace(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]any{"steps": traces})
 }
 
-// ListProposals returns proposals with filters
-func (h *Handlers) ListProposals(c echo.Context) error {
-	proposals, err := h.queries.ListProposals(c.Request().Context())
+// PauseAgentSession asks a running tools session to pause after its current step; it is
+// saved with a checkpoint and can be resumed with ResumeAgentSession
+func (h *Handlers) PauseAgentSession(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list proposals")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid session ID")
 	}
-	return c.JSON(http.StatusOK, map[string]any{"data": proposals})
+	if !h.agent.Pause(id) {
+		return echo.NewHTTPError(http.StatusConflict, "Session is not a running tools session")
+	}
+	return c.JSON(http.StatusAccepted, map[string]any{"status": "pausing", "session_id": id})
 }
 
-// ListProposalsWithProducts returns proposals enriched with product info
-func (h *Handlers) ListProposalsWithProducts(c echo.Context) error {
-	proposals, err := h.queries.ListProposalsWithProducts(c.Request().Context())
+// ResumeAgentSession continues a paused tools session in the background
+func (h *Handlers) ResumeAgentSession(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list proposals")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid session ID")
+	}
+	saved, err := h.queries.GetAgentSession(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
 	}
-	return c.JSON(http.StatusOK, map[string]any{"data": proposals})
+	var answer *models.HumanReview
+	if saved.Checkpoint != nil && saved.Checkpoint.AwaitingReview != nil {
+		if answer, err = h.queries.GetHumanReview(c.Request().Context(), *saved.Checkpoint.AwaitingReview); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get the awaited review")
+		}
+	}
+
+	err = h.resumeSession(c.Request().Context(), saved, answer)
+	switch {
+	case errors.Is(err, agent.ErrNotPaused):
+		return echo.NewHTTPError(http.StatusConflict, "Session is not paused")
+	case errors.Is(err, agent.ErrAwaitingReview):
+		return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("Session is waiting for human review %s", saved.Checkpoint.AwaitingReview))
+	case errors.Is(err, errShuttingDown):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Server is shutting down")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to resume session")
+	}
+	return c.JSON(http.StatusAccepted, map[string]any{"status": "resumed", "session_id": id})
 }
 
-// GetProposal returns a single proposal
-func (h *Handlers) GetProposal(c echo.Context) error {
-	id, err := uuid.Parse(c.Param("id"))
+// resumeSession checks that a paused session can go on and continues it in the background,
+// saving it like EnrichProduct does. answer is the review it waits for, if any.
+func (h *Handlers) resumeSession(ctx context.Context, saved *models.AgentSession, answer *models.HumanReview) error {
+	if saved.Status != "paused" || saved.Checkpoint == nil {
+		return agent.ErrNotPaused
+	}
+	if review := saved.Checkpoint.AwaitingReview; review != nil && (answer == nil || answer.ID != *review || answer.Status == "pending") {
+		return agent.ErrAwaitingReview
+	}
+	product, err := h.queries.GetProduct(ctx, saved.ProductID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid proposal ID")
+		return err
 	}
-
-	proposal, err := h.queries.GetProposal(c.Request().Context(), id)
+	traces, err := h.queries.GetAgentTraces(ctx, saved.ID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Proposal not found")
+		return err
 	}
 
-	return c.JSON(http.StatusOK, proposal)
+	return h.background.Go(product.ID, func(bg context.Context) {
+		runCtx, cancel := context.WithTimeout(agent.WithResumable(bg), 5*time.Minute)
+		defer cancel()
+		ctx := context.WithoutCancel(runCtx)
+
+		fmt.Printf("Resuming session %s for product %s at step %d\n", saved.ID, product.ID, len(traces)+1)
+		session, err := h.agent.ResumeSteps(runCtx, product, saved, traces, answer)
+		if err != nil {
+			fmt.Printf("Agent error resuming session %s: %v\n", saved.ID, err)
+			if session != nil {
+				if err := h.queries.CreateLLMCalls(ctx, session.LLMCalls); err != nil {
+					fmt.Printf("Failed to save LLM call log for %s: %v\n", product.ID, err)
+				}
+			}
+			return
+		}
+		h.saveEnrichment(ctx, product, []agent.OptimizationGroup{agent.GroupAll}, session)
+	})
 }
 
-// UpdateProposal updates a proposal (accept/reject/edit)
-func (h *Handlers) UpdateProposal(c echo.Context) error {
+// GetAgentLLMCalls returns the model calls of a session (recorded when LLM_CALL_LOG is on)
+func (h *Handlers) GetAgentLLMCalls(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid proposal ID")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid session ID")
 	}
 
-	var req struct {
-		Action      string `json:"action"` // accept, reject, edit
-		EditedValue string `json:"edited_value,omitempty"`
-	}
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	calls, err := h.queries.ListLLMCalls(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get LLM calls")
 	}
 
-	status := "proposed"
-	switch req.Action {
-	case "accept":
-		status = "accepted"
-	case "reject":
-		status = "rejected"
-	case "edit":
-		status = "edited"
-	default:
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action")
-	}
+	return c.JSON(http.StatusOK, map[string]any{"data": calls})
+}
 
-	if err := h.queries.UpdateProposalStatus(c.Request().Context(), id, status); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update proposal")
+// GetAgentPipeline returns the stages, rejections and evidence of a pipeline session
+func (h *Handlers) GetAgentPipeline(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid session ID")
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"status": status})
-}
-
-// BulkUpdateProposals updates multiple proposals based on filters
-func (h *Handlers) BulkUpdateProposals(c echo.Context) error {
-	var req struct {
-		Action           string   `json:"action"`            // accept, reject
-		Fields           []string `json:"fields"`            // filter by field names (title, description, etc.)
-		MinConfidence    float64  `json:"min_confidence"`    // minimum confidence threshold (0-1)
-		RiskLevels       []string `json:"risk_levels"`       // filter by risk levels (low, medium, high)
-		DatasetID        string   `json:"dataset_id"`        // filter by dataset
-		OnlyStatus       string   `json:"only_status"`       // filter by current status (usually "proposed")
+	run, err := h.queries.GetPipelineRun(c.Request().Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Pipeline run not found")
 	}
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get pipeline run")
 	}
 
-	status := "proposed"
-	switch req.Action {
-	case "accept":
-		status = "accepted"
-	case "reject":
-		status = "rejected"
-	default:
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action")
-	}
+	return c.JSON(http.StatusOK, run)
+}
 
-	// Get all proposals matching filters
-	proposals, err := h.queries.ListProposals(c.Request().Context())
+// ListProposals returns a page of proposals, newest first, filtered in SQL; ?cursor= takes
+// the next_cursor of the previous page
+func (h *Handlers) ListProposals(c echo.Context) error {
+	filter, err := proposalListFilter(c)
+	if err != nil {
+		return err
+	}
+	proposals, next, err := h.queries.ListProposals(c.Request().Context(), filter)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list proposals")
 	}
+	return c.JSON(http.StatusOK, map[string]any{"data": proposals, "next_cursor": cursorString(next)})
+}
 
-	// Filter and update
-	updated := 0
-	for _, p := range proposals {
-		// Only update "proposed" status by default
-		if req.OnlyStatus != "" && p.Status != req.OnlyStatus {
-			continue
+// proposalListFilter reads the filters, sort and page of the proposal lists from the query
+func proposalListFilter(c echo.Context) (db.ProposalListFilter, error) {
+	filter := db.ProposalListFilter{
+		OrgID:       orgID(c),
+		Status:      c.QueryParam("status"),
+		Field:       c.QueryParam("field"),
+		RiskLevel:   c.QueryParam("risk_level"),
+		Module:      c.QueryParam("module"),
+		Assignee:    c.QueryParam("assignee"),
+		ReviewState: c.QueryParam("review_state"),
+		Sort:        c.QueryParam("sort"),
+	}
+	if raw := c.QueryParam("dataset_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
 		}
-		if req.OnlyStatus == "" && p.Status != "proposed" {
-			continue
+		filter.DatasetID = &id
+	}
+	if raw := c.QueryParam("product_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid product ID")
+		}
+		filter.ProductID = &id
+	}
+	var err error
+	if filter.MinConfidence, err = scoreParam(c, "min_confidence"); err != nil {
+		return filter, err
+	}
+	if filter.MaxConfidence, err = scoreParam(c, "max_confidence"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedAfter, err = timeParam(c, "created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = timeParam(c, "created_before"); err != nil {
+		return filter, err
+	}
+	if raw := c.QueryParam("cursor"); raw != "" {
+		if filter.After, err = db.ParseProposalCursor(raw); err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid cursor")
 		}
+	}
+	if filter.Limit, err = limitParam(c, 100, 1000); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
 
-		// Field filter
-		if len(req.Fields) > 0 {
-			matched := false
-			for _, f := range req.Fields {
-				if strings.EqualFold(p.Field, f) {
-					matched = true
-					break
-				}
-			}
-			if !matched {
-				continue
-			}
+// limitParam parses the ?limit= query parameter, def when absent
+func limitParam(c echo.Context, def, max int) (int, error) {
+	raw := c.QueryParam("limit")
+	if raw == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > max {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", max))
+	}
+	return limit, nil
+}
+
+// cursorString is the next_cursor of a page, null on the last one
+func cursorString(next *db.ProposalCursor) *string {
+	if next == nil {
+		return nil
+	}
+	cursor := next.String()
+	return &cursor
+}
+
+// timeParam parses an optional RFC 3339 timestamp or YYYY-MM-DD date query parameter
+func timeParam(c echo.Context, name string) (*time.Time, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", raw); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, name+" must be an RFC 3339 timestamp or a YYYY-MM-DD date")
 		}
+	}
+	return &t, nil
+}
 
-		// Confidence filter
-		if req.MinConfidence > 0 && p.Confidence < req.MinConfidence {
-			continue
+// ListProposalsWithProducts returns a page of proposals enriched with product info, with
+// the filters and cursor of ListProposals (?assignee=name|none, ?review_state= to split the
+// queue between reviewers)
+func (h *Handlers) ListProposalsWithProducts(c echo.Context) error {
+	filter, err := proposalListFilter(c)
+	if err != nil {
+		return err
+	}
+	proposals, next, err := h.queries.ListProposalsWithProducts(c.Request().Context(), filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list proposals")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": proposals, "next_cursor": cursorString(next)})
+}
+
+// GetProposal returns a single proposal
+func (h *Handlers) GetProposal(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid proposal ID")
+	}
+
+	proposal, err := h.queries.GetProposal(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Proposal not found")
+	}
+
+	return c.JSON(http.StatusOK, proposal)
+}
+
+// GetProposalSources returns the evidence of a proposal for reviewers to check before
+// approving: the sources it was saved with and the retrieved facts (page URL and snippet)
+// that back it
+func (h *Handlers) GetProposalSources(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid proposal ID")
+	}
+
+	ctx := c.Request().Context()
+	proposal, err := h.queries.GetProposal(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Proposal not found")
+	}
+	sources := []models.Source{}
+	if len(proposal.Sources) > 0 {
+		json.Unmarshal(proposal.Sources, &sources)
+	}
+	facts, err := h.queries.ListProposalFacts(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list retrieved facts")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": map[string]any{
+		"proposal_id":     id,
+		"field":           proposal.Field,
+		"sources":         sources,
+		"retrieved_facts": facts,
+	}})
+}
+
+// UpdateProposal updates a proposal (accept/reject/edit)
+func (h *Handlers) UpdateProposal(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid proposal ID")
+	}
+
+	var req struct {
+		Action      string `json:"action"` // accept, reject, edit
+		EditedValue string `json:"edited_value,omitempty"`
+		Reviewer    string `json:"reviewer,omitempty"` // recorded as reviewed_by; must be the assignee if any
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+
+	if p, err := h.queries.GetProposal(c.Request().Context(), id); err == nil {
+		if p.Status == jobs.ProposalStatusSimulated {
+			return echo.NewHTTPError(http.StatusConflict, "Simulated proposals cannot be reviewed")
+		}
+		if req.Reviewer != "" && p.Assignee != nil && *p.Assignee != req.Reviewer {
+			return echo.NewHTTPError(http.StatusConflict, "Proposal is assigned to "+*p.Assignee)
 		}
+	}
 
-		// Risk level filter
-		if len(req.RiskLevels) > 0 {
-			matched := false
-			for _, r := range req.RiskLevels {
-				if strings.EqualFold(p.RiskLevel, r) {
-					matched = true
-					break
-				}
-			}
-			if !matched {
-				continue
-			}
+	status := "proposed"
+	var edited *string
+	switch req.Action {
+	case "accept":
+		status = "accepted"
+	case "reject":
+		status = "rejected"
+	case "edit":
+		status = "edited"
+		if strings.TrimSpace(req.EditedValue) == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "edited_value is required for edit")
+		}
+		edited = &req.EditedValue
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action")
+	}
+
+	// Accepting or editing writes the value into current_data; rejecting only changes the status
+	if status != "rejected" {
+		applied, err := h.queries.ApplyProposal(c.Request().Context(), id, status, edited, req.Reviewer)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return echo.NewHTTPError(http.StatusNotFound, "Proposal not found")
+		case errors.Is(err, db.ErrProposalSuperseded):
+			return echo.NewHTTPError(http.StatusConflict, "Proposal was superseded by a competing proposal; revert that one first")
+		case errors.Is(err, db.ErrProposalNotReviewable):
+			return echo.NewHTTPError(http.StatusConflict, "Proposal is already applied")
+		case err != nil:
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply proposal")
+		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"id":         id,
+			"status":     status,
+			"applied_at": applied.AppliedAt,
+			"superseded": applied.Superseded,
+		})
+	}
+
+	if err := h.queries.UpdateProposalStatus(c.Request().Context(), id, status, req.Reviewer); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update proposal")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": status})
+}
+
+// ListProposalConflicts returns pending proposals that compete for the same product field
+func (h *Handlers) ListProposalConflicts(c echo.Context) error {
+	var datasetID *uuid.UUID
+	if raw := c.QueryParam("dataset_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+		}
+		datasetID = &id
+	}
+
+	conflicts, err := h.queries.ListProposalConflicts(c.Request().Context(), orgID(c), datasetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list conflicts")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": conflicts})
+}
+
+// RevertProposal undoes an applied proposal, restoring the field's before_value
+func (h *Handlers) RevertProposal(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid proposal ID")
+	}
+
+	err = h.queries.RevertProposal(c.Request().Context(), id)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return echo.NewHTTPError(http.StatusNotFound, "Proposal not found")
+	case errors.Is(err, db.ErrProposalNotApplied):
+		return echo.NewHTTPError(http.StatusConflict, "Proposal is not applied")
+	case errors.Is(err, db.ErrFieldChanged):
+		return echo.NewHTTPError(http.StatusConflict, "Field was modified after the proposal was applied")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revert proposal")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "reverted"})
+}
+
+// BulkUpdateProposals accepts or rejects every proposal matching the filters in one transaction
+func (h *Handlers) BulkUpdateProposals(c echo.Context) error {
+	var req struct {
+		Action        string   `json:"action"`         // accept, reject
+		Fields        []string `json:"fields"`         // filter by field names (title, description, etc.)
+		Module        string   `json:"module"`         // filter by optimization module
+		MinConfidence float64  `json:"min_confidence"` // minimum confidence threshold (0-1)
+		RiskLevels    []string `json:"risk_levels"`    // filter by risk levels (low, medium, high)
+		DatasetID     string   `json:"dataset_id"`     // filter by dataset
+		OnlyStatus    string   `json:"only_status"`    // filter by current status (default "proposed")
+		Reviewer      string   `json:"reviewer"`       // proposals assigned to others are skipped
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	if req.OnlyStatus == jobs.ProposalStatusSimulated {
+		return echo.NewHTTPError(http.StatusBadRequest, "Simulated proposals cannot be reviewed")
+	}
+	if req.MinConfidence < 0 || req.MinConfidence > 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "min_confidence must be between 0 and 1")
+	}
+
+	status := "proposed"
+	switch req.Action {
+	case "accept":
+		status = "accepted"
+	case "reject":
+		status = "rejected"
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action")
+	}
+
+	filter := db.ProposalFilter{
+		OrgID:         orgID(c),
+		Fields:        req.Fields,
+		Module:        req.Module,
+		RiskLevels:    req.RiskLevels,
+		MinConfidence: req.MinConfidence,
+		Status:        req.OnlyStatus,
+		Reviewer:      req.Reviewer,
+	}
+	if req.DatasetID != "" {
+		id, err := uuid.Parse(req.DatasetID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
 		}
+		filter.DatasetID = &id
+	}
+
+	result, err := h.queries.BulkUpdateProposals(c.Request().Context(), filter, status)
+	if err != nil {
+		fmt.Printf("Bulk %s failed: %v\n", req.Action, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update proposals")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"updated":    result.Updated,
+		"superseded": result.Superseded,
+		"conflicts":  result.Conflicts,
+		"status":     status,
+	})
+}
 
-		// Update this proposal
-		if err := h.queries.UpdateProposalStatus(c.Request().Context(), p.ID, status); err == nil {
-			updated++
+// ListProposalGroups groups pending proposals by field and value (?dataset_id, field, min_size, limit)
+func (h *Handlers) ListProposalGroups(c echo.Context) error {
+	var datasetID *uuid.UUID
+	if raw := c.QueryParam("dataset_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
 		}
+		datasetID = &id
+	}
+	minSize, limit := 2, 50
+	if v := c.QueryParam("min_size"); v != "" {
+		fmt.Sscanf(v, "%d", &minSize)
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		fmt.Sscanf(v, "%d", &limit)
+	}
+
+	groups, err := h.queries.ListProposalGroups(c.Request().Context(), orgID(c), datasetID, c.QueryParam("field"), minSize, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list proposal groups")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": groups})
+}
+
+// AcceptProposalGroup accepts every pending proposal of a dataset writing the same value
+// into the same field, with a single audit entry
+func (h *Handlers) AcceptProposalGroup(c echo.Context) error {
+	var req struct {
+		DatasetID string `json:"dataset_id"`
+		Field     string `json:"field"`
+		Value     string `json:"value"`
+		Reviewer  string `json:"reviewer"` // recorded on the audit entry; proposals assigned to others are skipped
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	datasetID, err := uuid.Parse(req.DatasetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	if req.Field == "" || req.Value == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "field and value are required")
+	}
+	if err := h.checkDataset(c, datasetID); err != nil {
+		return err
+	}
+
+	result, err := h.queries.AcceptProposalGroup(c.Request().Context(), datasetID, req.Field, req.Value, req.Reviewer)
+	if err != nil {
+		fmt.Printf("Group accept %s=%q failed: %v\n", req.Field, req.Value, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to accept proposal group")
 	}
 
 	return c.JSON(http.StatusOK, map[string]any{
-		"updated": updated,
-		"status":  status,
+		"updated":    result.Updated,
+		"superseded": result.Superseded,
+		"conflicts":  result.Conflicts,
+		"change_id":  result.ChangeID,
 	})
 }
 
-// ListRules returns all rules
+// ListRules returns the rules of the organization
 func (h *Handlers) ListRules(c echo.Context) error {
-	rules, err := h.queries.ListRules(c.Request().Context())
+	rules, err := h.queries.ListRules(c.Request().Context(), orgID(c))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list rules")
 	}
 	return c.JSON(http.StatusOK, map[string]any{"data": rules})
 }
 
-// CreateRule creates a new rule
+// CreateRule creates a new rule of the organization
 func (h *Handlers) CreateRule(c echo.Context) error {
 	var rule models.Rule
 	if err := c.Bind(&rule); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
+	if rule.DatasetID != nil {
+		if err := h.checkDataset(c, *rule.DatasetID); err != nil {
+			return err
+		}
+	}
 
 	rule.ID = uuid.New()
+	rule.OrgID = orgID(c)
 	rule.CreatedAt = time.Now()
 
 	if err := h.queries.CreateRule(c.Request().Context(), rule); err != nil {
@@ -926,316 +1898,2223 @@ func (h *Handlers) UpdatePrompt(c echo.Context) error {
 	return c.JSON(http.StatusOK, prompt)
 }
 
-// GetTokenUsageStats returns token usage statistics
+// ListStageParams returns the generation parameters of every LLM stage: the configured
+// defaults, the override set here and what calls run with
+func (h *Handlers) ListStageParams(c echo.Context) error {
+	ctx := c.Request().Context()
+	overrides, err := h.queries.ListStageParams(ctx)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list stage params")
+	}
+	byStage := map[string]models.StageParams{}
+	for _, o := range overrides {
+		byStage[o.Stage] = o
+	}
+
+	stages := llm.StagesFor(h.config)
+	data := []map[string]any{}
+	for _, st := range llm.AllStages {
+		entry := map[string]any{
+			"stage":     st,
+			"defaults":  stages.Defaults(st),
+			"effective": stages.Get(ctx, st),
+		}
+		if o, ok := byStage[string(st)]; ok {
+			entry["override"] = o
+		}
+		data = append(data, entry)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": data})
+}
+
+// UpdateStageParams sets the override of a stage's temperature, max tokens and seed;
+// null fields keep the configured value, all null removes the override
+func (h *Handlers) UpdateStageParams(c echo.Context) error {
+	stage := c.Param("stage")
+	if !llm.KnownStage(stage) {
+		return echo.NewHTTPError(http.StatusNotFound, "Stage not found")
+	}
+
+	var req struct {
+		Temperature *float32 `json:"temperature"`
+		MaxTokens   *int     `json:"max_tokens"`
+		Seed        *int     `json:"seed"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if req.Temperature != nil && (*req.Temperature < 0 || *req.Temperature > 2) {
+		return echo.NewHTTPError(http.StatusBadRequest, "temperature must be between 0 and 2")
+	}
+	if req.MaxTokens != nil && *req.MaxTokens <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "max_tokens must be positive")
+	}
+
+	params := &models.StageParams{Stage: stage, Temperature: req.Temperature, MaxTokens: req.MaxTokens, Seed: req.Seed}
+	if err := h.queries.SetStageParams(c.Request().Context(), params); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save stage params")
+	}
+	stages := llm.StagesFor(h.config)
+	stages.Reload()
+	return c.JSON(http.StatusOK, map[string]any{
+		"stage":     stage,
+		"defaults":  stages.Defaults(llm.Stage(stage)),
+		"override":  params,
+		"effective": stages.Get(c.Request().Context(), llm.Stage(stage)),
+	})
+}
+
+// GetTokenUsageStats returns token usage statistics, optionally grouped by dataset,
+// product or module (?group_by) and narrowed to a dataset (?dataset_id)
 func (h *Handlers) GetTokenUsageStats(c echo.Context) error {
 	days := 30 // Default to last 30 days
 	if d := c.QueryParam("days"); d != "" {
 		fmt.Sscanf(d, "%d", &days)
 	}
-	if days < 1 {
-		days = 1
+	if days < 1 {
+		days = 1
+	}
+	if days > 365 {
+		days = 365
+	}
+
+	groupBy := c.QueryParam("group_by")
+	if groupBy != "" && !db.ValidUsageGrouping(groupBy) {
+		return echo.NewHTTPError(http.StatusBadRequest, "group_by must be dataset, product or module")
+	}
+	var datasetID *uuid.UUID
+	if ds := c.QueryParam("dataset_id"); ds != "" {
+		id, err := uuid.Parse(ds)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+		}
+		datasetID = &id
+	}
+	limit := 100
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+
+	stats, err := h.queries.GetTokenUsageStats(c.Request().Context(), orgID(c), days)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get token usage stats")
+	}
+	if groupBy != "" {
+		stats.GroupBy = groupBy
+		if stats.Groups, err = h.queries.GetTokenUsageGroups(c.Request().Context(), orgID(c), days, groupBy, datasetID, limit); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get token usage stats")
+		}
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// ListModelPrices returns the model price table
+func (h *Handlers) ListModelPrices(c echo.Context) error {
+	prices, err := h.queries.ListModelPrices(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list model prices")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": prices})
+}
+
+// SetModelPrice sets a model's price from a date on (today by default); past costs are not rewritten
+func (h *Handlers) SetModelPrice(c echo.Context) error {
+	var req struct {
+		Model            string  `json:"model"`
+		InputPerMillion  float64 `json:"input_per_million"`
+		OutputPerMillion float64 `json:"output_per_million"`
+		EffectiveFrom    string  `json:"effective_from"` // YYYY-MM-DD
+		Note             string  `json:"note"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if req.Model == "" || req.InputPerMillion < 0 || req.OutputPerMillion < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "model and non-negative prices are required")
+	}
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.EffectiveFrom != "" {
+		t, err := time.Parse("2006-01-02", req.EffectiveFrom)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "effective_from must be YYYY-MM-DD")
+		}
+		from = t
+	}
+
+	price := &models.ModelPrice{
+		Model:            req.Model,
+		InputPerMillion:  req.InputPerMillion,
+		OutputPerMillion: req.OutputPerMillion,
+		EffectiveFrom:    from,
+		Note:             req.Note,
+	}
+	if err := h.queries.UpsertModelPrice(c.Request().Context(), price); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save model price")
+	}
+	h.agent.ReloadPricing()
+	return c.JSON(http.StatusOK, price)
+}
+
+// DeleteModelPrice removes a price (e.g. one entered by mistake)
+func (h *Handlers) DeleteModelPrice(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid price ID")
+	}
+	if err := h.queries.DeleteModelPrice(c.Request().Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Price not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete model price")
+	}
+	h.agent.ReloadPricing()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListAPIKeys returns the API keys of the organization, revoked ones included (never the
+// keys themselves)
+func (h *Handlers) ListAPIKeys(c echo.Context) error {
+	keys, err := h.queries.ListAPIKeys(c.Request().Context(), orgID(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list API keys")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": keys})
+}
+
+// CreateAPIKey generates an API key of the organization, optionally issued to one of its
+// users; the key is only in this response
+func (h *Handlers) CreateAPIKey(c echo.Context) error {
+	var req struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		UserID    *uuid.UUID `json:"user_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if strings.TrimSpace(req.Name) == "" || len(req.Scopes) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "name and scopes are required")
+	}
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.APIScopes, scope) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Unknown scope %q (read, review, enrich, admin)", scope))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "expires_at must be in the future")
+	}
+	if req.UserID != nil {
+		owner, err := h.queries.RecordOrganization(c.Request().Context(), "user", *req.UserID)
+		if err != nil || owner != orgID(c) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown user_id")
+		}
+	}
+
+	key, secret, err := h.queries.CreateAPIKey(c.Request().Context(), orgID(c), req.UserID, strings.TrimSpace(req.Name), scopes, req.ExpiresAt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create API key")
+	}
+	return c.JSON(http.StatusCreated, map[string]any{"data": key, "key": secret})
+}
+
+// RevokeAPIKey disables an API key; requests made with it are rejected from then on
+func (h *Handlers) RevokeAPIKey(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID")
+	}
+	if err := h.queries.RevokeAPIKey(c.Request().Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "API key not found or already revoked")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke API key")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListOrganizations returns every organization (provider keys are never returned)
+func (h *Handlers) ListOrganizations(c echo.Context) error {
+	orgs, err := h.queries.ListOrganizations(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list organizations")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": orgs})
+}
+
+// organizationRequest is the body of CreateOrganization and UpdateOrganization; omitted
+// fields are left unchanged on update, an empty key falls back to the deployment's
+type organizationRequest struct {
+	Name         *string        `json:"name"`
+	Slug         string         `json:"slug"`
+	OpenAIAPIKey *string        `json:"openai_api_key"`
+	BraveAPIKey  *string        `json:"brave_api_key"`
+	Budget       *models.Budget `json:"budget"`
+}
+
+// apply validates the request and copies it into o
+func (r *organizationRequest) apply(o *models.Organization) error {
+	if r.Name != nil {
+		o.Name = strings.TrimSpace(*r.Name)
+	}
+	if o.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
+	}
+	if r.OpenAIAPIKey != nil {
+		o.OpenAIAPIKey = strings.TrimSpace(*r.OpenAIAPIKey)
+	}
+	if r.BraveAPIKey != nil {
+		o.BraveAPIKey = strings.TrimSpace(*r.BraveAPIKey)
+	}
+	if b := r.Budget; b != nil {
+		if b.DailyUSD != nil && *b.DailyUSD <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "budget.daily_usd must be positive")
+		}
+		if b.MonthlyUSD != nil && *b.MonthlyUSD <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "budget.monthly_usd must be positive")
+		}
+		o.Budget = *b
+	}
+	o.HasOpenAIKey, o.HasBraveKey = o.OpenAIAPIKey != "", o.BraveAPIKey != ""
+	return nil
+}
+
+// CreateOrganization adds an organization (tenant); its slug selects it in X-Organization
+func (h *Handlers) CreateOrganization(c echo.Context) error {
+	var req organizationRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	var org models.Organization
+	if err := req.apply(&org); err != nil {
+		return err
+	}
+	org.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	if org.Slug == "" || len(org.Slug) > 100 || strings.Trim(org.Slug, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "slug is required: lowercase letters, digits and dashes")
+	}
+
+	if err := h.queries.CreateOrganization(c.Request().Context(), &org); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusConflict, "Slug already used")
+		}
+		if errors.Is(err, db.ErrNoEncryptionKey) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Provider keys require ORG_KEYS_ENCRYPTION_KEY on the server")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create organization")
+	}
+	return c.JSON(http.StatusCreated, map[string]any{"data": org})
+}
+
+// GetOrganization returns the organization of the request with its spend
+func (h *Handlers) GetOrganization(c echo.Context) error {
+	ctx := c.Request().Context()
+	org, err := h.queries.GetOrganization(ctx, orgID(c).String())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load organization")
+	}
+	spent, err := h.queries.GetOrganizationSpend(ctx, org.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load spend")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": org, "spent": spent})
+}
+
+// UpdateOrganization changes the name, provider keys or budget of the request's organization
+func (h *Handlers) UpdateOrganization(c echo.Context) error {
+	var req organizationRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	ctx := c.Request().Context()
+	org, err := h.queries.GetOrganization(ctx, orgID(c).String())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load organization")
+	}
+	if err := req.apply(org); err != nil {
+		return err
+	}
+
+	if err := h.queries.UpdateOrganization(ctx, org); err != nil {
+		if errors.Is(err, db.ErrNoEncryptionKey) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Provider keys require ORG_KEYS_ENCRYPTION_KEY on the server")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update organization")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": org})
+}
+
+// ListUsers returns the members of the organization
+func (h *Handlers) ListUsers(c echo.Context) error {
+	users, err := h.queries.ListUsers(c.Request().Context(), orgID(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list users")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": users})
+}
+
+// CreateUser adds a member to the organization
+func (h *Handlers) CreateUser(c echo.Context) error {
+	var req struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	user := models.User{OrgID: orgID(c), Email: strings.ToLower(strings.TrimSpace(req.Email)), Name: strings.TrimSpace(req.Name)}
+	if !strings.Contains(user.Email, "@") {
+		return echo.NewHTTPError(http.StatusBadRequest, "A valid email is required")
+	}
+
+	if err := h.queries.CreateUser(c.Request().Context(), &user); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusConflict, "Email already used")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user")
+	}
+	return c.JSON(http.StatusCreated, map[string]any{"data": user})
+}
+
+// DeleteUser removes a member of the organization, with their API keys
+func (h *Handlers) DeleteUser(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	if err := h.queries.DeleteUser(c.Request().Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete user")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListBrands returns the brand dictionary of a dataset: its brands with their spellings
+func (h *Handlers) ListBrands(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	h.agent.ReloadBrands(id)
+	dict := h.agent.BrandDictionary(c.Request().Context(), id)
+	if dict == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load brands")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": dict.Brands()})
+}
+
+// ListBrandAliases returns the brand aliases of a dataset
+func (h *Handlers) ListBrandAliases(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	aliases, err := h.queries.ListBrandAliases(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list brand aliases")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": aliases})
+}
+
+// SetBrandAlias makes a brand spelling canonical for a dataset, replacing the brand the
+// alias had
+func (h *Handlers) SetBrandAlias(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	var req struct {
+		Alias string `json:"alias"`
+		Brand string `json:"brand"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	alias := &models.BrandAlias{DatasetID: id, Alias: strings.TrimSpace(req.Alias), Brand: strings.TrimSpace(req.Brand)}
+	if alias.Alias == "" || alias.Brand == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "alias and brand are required")
+	}
+	if err := h.queries.UpsertBrandAlias(c.Request().Context(), alias); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save brand alias")
+	}
+	h.agent.ReloadBrands(id)
+	return c.JSON(http.StatusOK, alias)
+}
+
+// DeleteBrandAlias removes a brand alias
+func (h *Handlers) DeleteBrandAlias(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid alias ID")
+	}
+	datasetID, err := h.queries.DeleteBrandAlias(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Brand alias not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete brand alias")
+	}
+	h.agent.ReloadBrands(datasetID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListTrustedDomains returns the domains trusted as sources for a dataset
+func (h *Handlers) ListTrustedDomains(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	domains, err := h.queries.ListTrustedDomains(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list trusted domains")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": domains})
+}
+
+// AddTrustedDomain trusts a domain as a source for a dataset, for one brand or every brand
+func (h *Handlers) AddTrustedDomain(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	var req struct {
+		Domain string `json:"domain"`
+		Brand  string `json:"brand"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	domain := &models.TrustedDomain{DatasetID: id, Domain: tools.NormalizeDomain(req.Domain), Brand: strings.TrimSpace(req.Brand)}
+	if domain.Domain == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "domain must be a host name such as nike.com")
+	}
+	if err := h.queries.AddTrustedDomain(c.Request().Context(), domain); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save trusted domain")
+	}
+	h.agent.ReloadTrustedDomains(id)
+	return c.JSON(http.StatusOK, domain)
+}
+
+// DeleteTrustedDomain removes a trusted domain
+func (h *Handlers) DeleteTrustedDomain(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid trusted domain ID")
+	}
+	datasetID, err := h.queries.DeleteTrustedDomain(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "Trusted domain not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete trusted domain")
+	}
+	h.agent.ReloadTrustedDomains(datasetID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetResponseCacheStats returns the hits of the response cache and what they saved
+func (h *Handlers) GetResponseCacheStats(c echo.Context) error {
+	stats, err := h.queries.GetResponseCacheStats(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get response cache stats")
+	}
+	return c.JSON(http.StatusOK, stats)
+}
+
+// GetWebSearchStatus returns the web search provider's quota and circuit breaker state
+func (h *Handlers) GetWebSearchStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, websearch.For(h.config).QuotaStatus())
+}
+
+// ClearResponseCache drops every cached answer, e.g. after editing prompts in place
+func (h *Handlers) ClearResponseCache(c echo.Context) error {
+	n, err := h.queries.ClearResponseCache(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to clear response cache")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"deleted": n})
+}
+
+// ===== DATA FEEDS HANDLERS =====
+
+// ListDatasetVersions returns version history for a dataset
+func (h *Handlers) ListDatasetVersions(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	versions, err := h.queries.ListDatasetVersions(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list versions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": versions})
+}
+
+// CreateSnapshot creates a snapshot of current dataset state
+func (h *Handlers) CreateSnapshot(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req struct {
+		Name string `json:"name"`
+		Type string `json:"type"` // pre_enrichment, post_enrichment, manual
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("Snapshot %s", time.Now().Format("2006-01-02 15:04"))
+	}
+	if req.Type == "" {
+		req.Type = "manual"
+	}
+
+	snapshot := models.DatasetSnapshot{
+		ID:           uuid.New(),
+		DatasetID:    id,
+		Name:         req.Name,
+		SnapshotType: req.Type,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := h.queries.CreateSnapshot(c.Request().Context(), &snapshot); err != nil {
+		fmt.Printf("Failed to create snapshot of dataset %s: %v\n", id, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create snapshot")
+	}
+
+	return c.JSON(http.StatusCreated, snapshot)
+}
+
+// ListSnapshots returns snapshots for a dataset
+func (h *Handlers) ListSnapshots(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	snapshots, err := h.queries.ListSnapshots(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list snapshots")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": snapshots})
+}
+
+// DeleteSnapshot deletes a snapshot
+func (h *Handlers) DeleteSnapshot(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid snapshot ID")
+	}
+
+	if err := h.queries.DeleteSnapshot(c.Request().Context(), id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete snapshot")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetChangeLog returns change history for a dataset
+func (h *Handlers) GetChangeLog(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	limit := 100
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+
+	entries, err := h.queries.GetChangeLog(c.Request().Context(), id, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get change log")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": entries})
+}
+
+// ===== THRESHOLD HANDLERS =====
+
+// resolveThresholds merges request overrides over the dataset's and the AGENT_* defaults
+func (h *Handlers) resolveThresholds(c echo.Context, datasetID uuid.UUID, request models.ThresholdOverrides) (models.Thresholds, error) {
+	t, err := h.runner.Thresholds(c.Request().Context(), datasetID, &request)
+	switch {
+	case errors.Is(err, agent.ErrInvalidThresholds):
+		return t, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		return t, echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	case err != nil:
+		return t, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load thresholds")
+	}
+	return t, nil
+}
+
+// checkBudget refuses to start work on a dataset when its budget, its organization's or the
+// deployment's is spent
+func (h *Handlers) checkBudget(c echo.Context, datasetID uuid.UUID) error {
+	_, err := h.agent.WithBudget(c.Request().Context(), datasetID)
+	switch {
+	case errors.Is(err, agent.ErrBudgetExhausted):
+		return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		return echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check budget")
+	}
+	return nil
+}
+
+// GetDatasetBudget returns the dataset's spend caps and today's and this month's spend,
+// with the deployment's (BUDGET_*) for comparison
+func (h *Handlers) GetDatasetBudget(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	budget, err := h.queries.GetDatasetBudget(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	}
+	return h.budgetResponse(c, id, *budget)
+}
+
+// UpdateDatasetBudget replaces the dataset's spend caps; omitted caps are unlimited
+func (h *Handlers) UpdateDatasetBudget(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req models.Budget
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if req.DailyUSD != nil && *req.DailyUSD <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "daily_usd must be positive")
+	}
+	if req.MonthlyUSD != nil && *req.MonthlyUSD <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "monthly_usd must be positive")
+	}
+
+	if _, err := h.queries.GetDataset(c.Request().Context(), id); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	}
+	if err := h.queries.UpdateDatasetBudget(c.Request().Context(), id, req); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update budget")
+	}
+	return h.budgetResponse(c, id, req)
+}
+
+func (h *Handlers) budgetResponse(c echo.Context, datasetID uuid.UUID, budget models.Budget) error {
+	ctx := c.Request().Context()
+	total, orgSpent, spent, err := h.queries.GetBudgetSpend(ctx, datasetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load spend")
+	}
+	org, err := h.queries.GetDatasetOrganization(ctx, datasetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load organization")
+	}
+	resp := map[string]any{
+		"budget": budget,
+		"spent":  spent,
+		"organization": map[string]any{
+			"budget": org.Budget,
+			"spent":  orgSpent,
+		},
+	}
+	// What other organizations spend is only the deployment's business
+	if platform(c) {
+		var deployment models.Budget
+		if v := h.config.Budget.DailyUSD; v > 0 {
+			deployment.DailyUSD = &v
+		}
+		if v := h.config.Budget.MonthlyUSD; v > 0 {
+			deployment.MonthlyUSD = &v
+		}
+		resp["deployment"] = map[string]any{
+			"budget": deployment,
+			"spent":  total,
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetDatasetThresholds returns the dataset's threshold overrides and the thresholds runs will use
+func (h *Handlers) GetDatasetThresholds(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	overrides, err := h.queries.GetDatasetThresholds(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	}
+	effective, err := agent.ResolveThresholds(agent.DefaultThresholds(h.config), overrides)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Stored thresholds are invalid: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"overrides": overrides,
+		"effective": effective,
+	})
+}
+
+// UpdateDatasetThresholds replaces the dataset's threshold overrides; omitted keys use AGENT_* defaults
+func (h *Handlers) UpdateDatasetThresholds(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req models.ThresholdOverrides
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	effective, err := agent.ResolveThresholds(agent.DefaultThresholds(h.config), &req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := h.queries.GetDataset(c.Request().Context(), id); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	}
+	if err := h.queries.UpdateDatasetThresholds(c.Request().Context(), id, req); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update thresholds")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"overrides": req,
+		"effective": effective,
+	})
+}
+
+// resolveEngine returns the engine of a run on a dataset: the request's mode, then the
+// dataset's default, then the agent
+func (h *Handlers) resolveEngine(c echo.Context, datasetID uuid.UUID, mode string) (agent.Engine, error) {
+	engine, err := agent.ParseEngine(mode)
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if engine != "" {
+		return engine, nil
+	}
+	stored, err := h.queries.GetDatasetEngine(c.Request().Context(), datasetID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return "", echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	case err != nil:
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "Failed to load engine")
+	}
+	if engine, err = agent.ParseEngine(stored); err != nil || engine == "" {
+		return agent.EngineAgent, nil
+	}
+	return engine, nil
+}
+
+// GetDatasetEngine returns the dataset's default engine and the one runs use
+func (h *Handlers) GetDatasetEngine(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	engine, err := h.resolveEngine(c, id, "")
+	if err != nil {
+		return err
+	}
+	stored, _ := h.queries.GetDatasetEngine(c.Request().Context(), id)
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"engine":    stored,
+		"effective": engine,
+	})
+}
+
+// UpdateDatasetEngine sets the engine the dataset's runs use when the request has no mode;
+// an empty engine restores the agent
+func (h *Handlers) UpdateDatasetEngine(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req struct {
+		Engine string `json:"engine"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	engine, err := agent.ParseEngine(req.Engine)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := h.queries.GetDataset(c.Request().Context(), id); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Dataset not found")
+	}
+	if err := h.queries.UpdateDatasetEngine(c.Request().Context(), id, string(engine)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update engine")
+	}
+
+	effective := engine
+	if effective == "" {
+		effective = agent.EngineAgent
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"engine":    engine,
+		"effective": effective,
+	})
+}
+
+// ===== JOB HANDLERS =====
+
+// ListJobs returns jobs with optional filters
+func (h *Handlers) ListJobs(c echo.Context) error {
+	var datasetID *uuid.UUID
+	if dsID := c.QueryParam("dataset_id"); dsID != "" {
+		id, err := uuid.Parse(dsID)
+		if err == nil {
+			datasetID = &id
+		}
+	}
+
+	status := c.QueryParam("status")
+	limit := 50
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+
+	list, err := h.queries.ListJobs(c.Request().Context(), orgID(c), datasetID, status, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list jobs")
+	}
+	for i := range list {
+		list[i].Queue = h.runner.QueueInfo(list[i].ID)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": list})
+}
+
+// GetJobQueue returns queued and running jobs in scheduling order with ETAs
+func (h *Handlers) GetJobQueue(c echo.Context) error {
+	org := orgID(c)
+	datasets, err := h.queries.ListDatasets(c.Request().Context(), &org)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list datasets")
+	}
+	own := make(map[uuid.UUID]bool, len(datasets))
+	for _, d := range datasets {
+		own[d.ID] = true
+	}
+	// Workers are shared: the depth counts the jobs of every organization
+	queued := []models.JobWithDetails{}
+	depth := 0
+	for _, j := range h.runner.Snapshot() {
+		if j.Queue != nil {
+			depth += j.Queue.Remaining
+		}
+		if own[j.DatasetID] {
+			queued = append(queued, j)
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"data":        queued,
+		"queue_depth": depth,
+		"workers":     h.config.Jobs.Workers,
+	})
+}
+
+// GetJobDetails returns details for a single job
+func (h *Handlers) GetJobDetails(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid job ID")
+	}
+
+	job, err := h.queries.GetJob(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Job not found")
+	}
+	job.Queue = h.runner.QueueInfo(id)
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// PauseJob pauses a running job after the product in flight
+func (h *Handlers) PauseJob(c echo.Context) error {
+	return h.controlJob(c, "paused", h.runner.Pause)
+}
+
+// ResumeJob resumes a paused job from its checkpoint
+func (h *Handlers) ResumeJob(c echo.Context) error {
+	return h.controlJob(c, "running", h.runner.Resume)
+}
+
+// CancelJob stops a job immediately
+func (h *Handlers) CancelJob(c echo.Context) error {
+	return h.controlJob(c, "cancelled", h.runner.Cancel)
+}
+
+func (h *Handlers) controlJob(c echo.Context, status string, action func(context.Context, uuid.UUID) error) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid job ID")
+	}
+
+	job, err := h.queries.GetJob(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Job not found")
+	}
+
+	if err := action(c.Request().Context(), id); err != nil {
+		if errors.Is(err, jobs.ErrInvalidTransition) {
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("Job is %s", job.Status))
+		}
+		if errors.Is(err, jobs.ErrStillStopping) {
+			return echo.NewHTTPError(http.StatusConflict, "Job is still finishing its current product, retry shortly")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to update job: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"job_id": id,
+		"status": status,
+	})
+}
+
+// ListJobFailures returns the dead-lettered products of a job
+func (h *Handlers) ListJobFailures(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid job ID")
+	}
+
+	failures, err := h.queries.ListJobFailures(c.Request().Context(), id, c.QueryParam("status"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list failures")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": failures})
+}
+
+// RequeueJobFailures starts a new job over dead-lettered products of a job
+func (h *Handlers) RequeueJobFailures(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid job ID")
+	}
+
+	var req struct {
+		ProductIDs []uuid.UUID `json:"product_ids"` // empty = every dead-lettered product
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if _, err := h.queries.GetJob(c.Request().Context(), id); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Job not found")
+	}
+
+	job, err := h.runner.Requeue(c.Request().Context(), id, req.ProductIDs)
+	if errors.Is(err, jobs.ErrNothingToRequeue) {
+		return echo.NewHTTPError(http.StatusConflict, "No dead-lettered products to requeue")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to requeue: %v", err))
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status":         "started",
+		"job_id":         job.ID,
+		"total_products": job.TotalItems,
+	})
+}
+
+// ===== RE-ENRICHMENT SCHEDULING HANDLERS =====
+
+// scheduleGroup reads the optimization group of a re-enrichment request (default all)
+func scheduleGroup(raw string) (agent.OptimizationGroup, error) {
+	if raw == "" {
+		return agent.GroupAll, nil
+	}
+	for _, g := range agent.GetAllGroups() {
+		if string(g.ID) == raw {
+			return g.ID, nil
+		}
+	}
+	return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid optimization group")
+}
+
+// parseGroups validates a list of optimization groups, dropping duplicates; none = all
+func parseGroups(raw []string) ([]agent.OptimizationGroup, error) {
+	groups := []agent.OptimizationGroup{}
+	for _, r := range raw {
+		group, err := scheduleGroup(r)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == 0 {
+		groups = append(groups, agent.GroupAll)
+	}
+	return groups, nil
+}
+
+// groupsEngine checks the engine can run the groups: the pipelines only run all. A
+// dataset's default pipeline gives way to the agent; a requested one is a 400.
+func groupsEngine(groups []agent.OptimizationGroup, engine agent.Engine, mode string) (agent.Engine, error) {
+	if engine == agent.EngineAgent || (len(groups) == 1 && groups[0] == agent.GroupAll) {
+		return engine, nil
+	}
+	if mode != "" {
+		return "", echo.NewHTTPError(http.StatusBadRequest, agent.ErrEngineGroup.Error())
+	}
+	return agent.EngineAgent, nil
+}
+
+// ListStaleProducts previews the products a re-enrichment of the dataset would re-run
+func (h *Handlers) ListStaleProducts(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	group, err := scheduleGroup(c.QueryParam("group"))
+	if err != nil {
+		return err
+	}
+
+	maxAgeDays, limit := 0, 100
+	if d := c.QueryParam("max_age_days"); d != "" {
+		fmt.Sscanf(d, "%d", &maxAgeDays)
+	}
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+
+	stale, err := h.runner.StaleProducts(c.Request().Context(), id, group, maxAgeDays, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list stale products")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": stale})
+}
+
+// RefreshDataset queues a re-enrichment job for the stale products of a dataset
+func (h *Handlers) RefreshDataset(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req struct {
+		Group      string `json:"group"`
+		MaxAgeDays int    `json:"max_age_days"` // default SCHEDULE_MAX_AGE_DAYS
+		Priority   *int   `json:"priority"`     // default SCHEDULE_PRIORITY
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	group, err := scheduleGroup(req.Group)
+	if err != nil {
+		return err
+	}
+	priority := h.config.Schedule.Priority
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+	if err := h.checkBudget(c, id); err != nil {
+		return err
+	}
+
+	job, err := h.runner.ScheduleRefresh(c.Request().Context(), id, group, req.MaxAgeDays, priority)
+	if errors.Is(err, jobs.ErrJobActive) {
+		return echo.NewHTTPError(http.StatusConflict, "A job for this group is already active on the dataset")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to schedule re-enrichment")
+	}
+	if job == nil {
+		return c.JSON(http.StatusOK, map[string]any{
+			"status":  "up_to_date",
+			"message": "No stale products",
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status":         "started",
+		"job_id":         job.ID,
+		"group":          group,
+		"total_products": job.TotalItems,
+	})
+}
+
+// GetProductOutcomes returns the last run verdict of each module run on a product
+func (h *Handlers) GetProductOutcomes(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid product ID")
+	}
+
+	outcomes, err := h.queries.ListProductOutcomes(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list outcomes")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": outcomes})
+}
+
+// GetDatasetOutcomes counts products per module as clean, changes proposed or not run
+func (h *Handlers) GetDatasetOutcomes(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	stats, err := h.queries.GetDatasetOutcomeStats(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get outcomes")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": stats})
+}
+
+// ===== APPROVAL RULES HANDLERS =====
+
+// ListApprovalRules returns approval rules
+func (h *Handlers) ListApprovalRules(c echo.Context) error {
+	var datasetID *uuid.UUID
+	if dsID := c.QueryParam("dataset_id"); dsID != "" {
+		id, err := uuid.Parse(dsID)
+		if err == nil {
+			datasetID = &id
+		}
+	}
+
+	rules, err := h.queries.ListApprovalRules(c.Request().Context(), orgID(c), datasetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list rules")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": rules})
+}
+
+// CreateApprovalRule creates a new approval rule of the organization
+func (h *Handlers) CreateApprovalRule(c echo.Context) error {
+	var req models.ApprovalRule
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	if req.DatasetID != nil {
+		if err := h.checkDataset(c, *req.DatasetID); err != nil {
+			return err
+		}
+	}
+
+	req.ID = uuid.New()
+	req.OrgID = orgID(c)
+	req.CreatedAt = time.Now()
+	req.Active = true
+
+	if err := h.queries.CreateApprovalRule(c.Request().Context(), req); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create rule")
+	}
+
+	return c.JSON(http.StatusCreated, req)
+}
+
+// UpdateApprovalRule updates an existing rule
+func (h *Handlers) UpdateApprovalRule(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rule ID")
+	}
+
+	var req models.ApprovalRule
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	req.ID = id
+
+	if err := h.queries.UpdateApprovalRule(c.Request().Context(), req); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update rule")
+	}
+
+	return c.JSON(http.StatusOK, req)
+}
+
+// DeleteApprovalRule deletes a rule
+func (h *Handlers) DeleteApprovalRule(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rule ID")
+	}
+
+	if err := h.queries.DeleteApprovalRule(c.Request().Context(), id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete rule")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ApplyApprovalRules applies all active rules to pending proposals
+func (h *Handlers) ApplyApprovalRules(c echo.Context) error {
+	var datasetID *uuid.UUID
+	if dsID := c.QueryParam("dataset_id"); dsID != "" {
+		id, err := uuid.Parse(dsID)
+		if err == nil {
+			datasetID = &id
+		}
+	}
+
+	affected, err := h.queries.ApplyApprovalRules(c.Request().Context(), orgID(c), datasetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply rules")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"affected": affected,
+		"message":  fmt.Sprintf("Applied rules to %d proposals", affected),
+	})
+}
+
+// ScoreProposals computes quality scores for pending proposals that have none yet
+func (h *Handlers) ScoreProposals(c echo.Context) error {
+	var datasetID *uuid.UUID
+	if dsID := c.QueryParam("dataset_id"); dsID != "" {
+		id, err := uuid.Parse(dsID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+		}
+		datasetID = &id
+	}
+
+	limit := 500
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+
+	ctx := c.Request().Context()
+	proposals, err := h.queries.ListUnscoredProposals(ctx, orgID(c), datasetID, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list proposals")
+	}
+
+	// Score per product so the judge (if enabled) sees all of a product's proposals at once
+	byProduct := map[uuid.UUID][]models.Proposal{}
+	for _, p := range proposals {
+		byProduct[p.ProductID] = append(byProduct[p.ProductID], p)
+	}
+
+	scored := 0
+	for productID, group := range byProduct {
+		product, err := h.queries.GetProduct(ctx, productID)
+		if err != nil {
+			continue
+		}
+		// The judge, when enabled, calls the model with the organization's key
+		scoreCtx, err := h.agent.WithProviderKeys(ctx, product.DatasetID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load organization")
+		}
+		h.agent.ScoreProposals(scoreCtx, product, group)
+		for _, p := range group {
+			if p.QualityScore == nil {
+				continue
+			}
+			if err := h.queries.UpdateProposalQuality(ctx, p.ID, *p.QualityScore, p.QualityBreakdown); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save quality score")
+			}
+			scored++
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"scored": scored})
+}
+
+// ===== PROPOSALS BY MODULE =====
+
+// GetProposalsByModule returns proposals grouped by module
+func (h *Handlers) GetProposalsByModule(c echo.Context) error {
+	var datasetID *uuid.UUID
+	if dsID := c.QueryParam("dataset_id"); dsID != "" {
+		id, err := uuid.Parse(dsID)
+		if err == nil {
+			datasetID = &id
+		}
+	}
+
+	groups, err := h.queries.GetProposalsByModule(c.Request().Context(), orgID(c), datasetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get proposals by module")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": groups})
+}
+
+// ListProposalsByModuleFiltered returns a page of proposals with their dataset, with the
+// filters and cursor of ListProposals (?module= for a specific module)
+func (h *Handlers) ListProposalsByModuleFiltered(c echo.Context) error {
+	filter, err := proposalListFilter(c)
+	if err != nil {
+		return err
+	}
+	proposals, next, err := h.queries.ListProposalsByModule(c.Request().Context(), filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list proposals")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": proposals, "next_cursor": cursorString(next)})
+}
+
+// ===== RETENTION HANDLERS =====
+
+// ListAuditPartitions returns the monthly partitions of the audit tables
+func (h *Handlers) ListAuditPartitions(c echo.Context) error {
+	result := map[string][]models.AuditPartition{}
+	for _, table := range db.AuditTables {
+		partitions, err := h.queries.ListAuditPartitions(c.Request().Context(), table)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list partitions")
+		}
+		result[table] = partitions
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": result})
+}
+
+// ListAuditArchives returns exported audit partitions
+func (h *Handlers) ListAuditArchives(c echo.Context) error {
+	archives, err := h.queries.ListAuditArchives(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list archives")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": archives})
+}
+
+// RunRetention archives and purges audit partitions past the retention window and prunes
+// old token usage and snapshots
+func (h *Handlers) RunRetention(c echo.Context) error {
+	svc, err := retention.New(h.config, h.queries)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	dryRun := c.QueryParam("dry_run") == "true"
+	report, err := svc.Run(c.Request().Context(), dryRun)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Retention failed: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// ===== SEGMENT HANDLERS =====
+
+// resolveSegment parses an optional segment ID and checks it belongs to the dataset
+func (h *Handlers) resolveSegment(c echo.Context, datasetID uuid.UUID, raw string) (*uuid.UUID, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	segmentID, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid segment ID")
+	}
+	segment, err := h.queries.GetSegment(c.Request().Context(), segmentID)
+	if err != nil || segment.DatasetID != datasetID {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Segment not found")
+	}
+	return &segmentID, nil
+}
+
+// ListSegments returns saved segments for a dataset
+func (h *Handlers) ListSegments(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	segments, err := h.queries.ListSegments(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list segments")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": segments})
+}
+
+// CreateSegment saves a filter expression as a segment of a dataset
+func (h *Handlers) CreateSegment(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var segment models.Segment
+	if err := c.Bind(&segment); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	segment.Name = strings.TrimSpace(segment.Name)
+	if segment.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Name is required")
+	}
+	if err := db.ValidateSegmentFilter(segment.Filter); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid filter: %v", err))
+	}
+
+	segment.ID = uuid.New()
+	segment.DatasetID = id
+	segment.CreatedAt = time.Now()
+	segment.UpdatedAt = segment.CreatedAt
+
+	if err := h.queries.CreateSegment(c.Request().Context(), segment); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create segment")
+	}
+
+	return c.JSON(http.StatusCreated, segment)
+}
+
+// GetSegment returns a single segment
+func (h *Handlers) GetSegment(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid segment ID")
+	}
+
+	segment, err := h.queries.GetSegment(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Segment not found")
+	}
+
+	return c.JSON(http.StatusOK, segment)
+}
+
+// UpdateSegment updates a segment's name, description or filter
+func (h *Handlers) UpdateSegment(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid segment ID")
+	}
+
+	segment, err := h.queries.GetSegment(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Segment not found")
+	}
+
+	var req struct {
+		Name        *string               `json:"name"`
+		Description *string               `json:"description"`
+		Filter      *models.SegmentFilter `json:"filter"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Name is required")
+		}
+		segment.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		segment.Description = *req.Description
+	}
+	if req.Filter != nil {
+		if err := db.ValidateSegmentFilter(*req.Filter); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid filter: %v", err))
+		}
+		segment.Filter = *req.Filter
+	}
+
+	if err := h.queries.UpdateSegment(c.Request().Context(), *segment); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update segment")
+	}
+
+	return c.JSON(http.StatusOK, segment)
+}
+
+// DeleteSegment deletes a segment
+func (h *Handlers) DeleteSegment(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid segment ID")
+	}
+
+	if err := h.queries.DeleteSegment(c.Request().Context(), id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete segment")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListSegmentProducts returns the products currently matching a segment
+func (h *Handlers) ListSegmentProducts(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid segment ID")
+	}
+
+	segment, err := h.queries.GetSegment(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Segment not found")
+	}
+
+	tags := normalizeTags(c.QueryParams()["tag"])
+	products, err := h.queries.ListProductsMatching(c.Request().Context(), segment.DatasetID, tags, &segment.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": products})
+}
+
+// GetSegmentStats returns product and proposal statistics for a segment
+func (h *Handlers) GetSegmentStats(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid segment ID")
+	}
+
+	segment, err := h.queries.GetSegment(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Segment not found")
+	}
+
+	stats, err := h.queries.GetSegmentStats(c.Request().Context(), *segment)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get stats")
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// ===== IMAGE PROXY HANDLERS =====
+
+// GetImage serves a product image through the cache, resized to fit w x h when given
+func (h *Handlers) GetImage(c echo.Context) error {
+	rawURL := c.QueryParam("url")
+	if rawURL == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "url is required")
+	}
+	width, height := 0, 0
+	if w := c.QueryParam("w"); w != "" {
+		fmt.Sscanf(w, "%d", &width)
+	}
+	if hh := c.QueryParam("h"); hh != "" {
+		fmt.Sscanf(hh, "%d", &height)
+	}
+
+	img, err := h.images.Get(c.Request().Context(), rawURL, width, height)
+	if err != nil {
+		switch {
+		case errors.Is(err, imageproxy.ErrInvalidURL):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, imageproxy.ErrTooLarge), errors.Is(err, imageproxy.ErrNotImage):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusBadGateway, "Failed to fetch image")
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=86400")
+	return c.Blob(http.StatusOK, img.ContentType, img.Data)
+}
+
+// StartImageCheck requests the image_link of every product in scope in the background and
+// records broken, non-image and hotlink-protected images
+func (h *Handlers) StartImageCheck(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req struct {
+		Tags      []string `json:"tags"`
+		SegmentID string   `json:"segment_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	segmentID, err := h.resolveSegment(c, id, req.SegmentID)
+	if err != nil {
+		return err
+	}
+	products, err := h.queries.ListProductsInScope(c.Request().Context(), id, segmentID, normalizeTags(req.Tags))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
+	}
+	if len(products) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "No products to check")
+	}
+
+	if err := h.checks.Start(id, products); err != nil {
+		if errors.Is(err, imagecheck.ErrRunning) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start the image check")
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status":         "started",
+		"total_products": len(products),
+	})
+}
+
+// GetImageCheckReport returns the products of a dataset whose image failed its last check,
+// with the progress of a running check
+func (h *Handlers) GetImageCheckReport(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	report, err := h.checks.Report(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get the image check report")
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// StartImageAudit queues a job that measures and analyzes the main image of every product
+// in scope, like the image_analysis group without its optimization call: no proposals, the
+// job report is a scorecard of the dataset's images
+func (h *Handlers) StartImageAudit(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+
+	var req struct {
+		Tags      []string `json:"tags"`
+		SegmentID string   `json:"segment_id"`
+		Priority  *int     `json:"priority"` // 1 (lowest) - 10 (highest), default 5
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	segmentID, err := h.resolveSegment(c, id, req.SegmentID)
+	if err != nil {
+		return err
+	}
+	if err := h.checkBudget(c, id); err != nil {
+		return err
+	}
+	tags := normalizeTags(req.Tags)
+	products, err := h.queries.ListProductsInScope(c.Request().Context(), id, segmentID, tags)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list products")
+	}
+	if len(products) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "No products to audit")
+	}
+
+	job := models.JobWithDetails{
+		Job: models.Job{
+			ID:        uuid.New(),
+			DatasetID: id,
+			Type:      "image_audit",
+			Status:    "pending",
+			CreatedAt: time.Now(),
+		},
+		Module:     string(agent.GroupImageAnalysis),
+		Priority:   jobs.DefaultPriority,
+		TotalItems: len(products),
+		Logs:       []models.JobLog{},
+	}
+	if req.Priority != nil {
+		job.Priority = jobs.ClampPriority(*req.Priority)
+	}
+	job.Config, _ = json.Marshal(jobs.AuditConfig{
+		Group:      agent.GroupImageAnalysis,
+		Tags:       tags,
+		SegmentID:  segmentID,
+		ImageAudit: &jobs.ImageAuditConfig{},
+	})
+	if err := h.queries.CreateJobWithDetails(c.Request().Context(), job); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create job")
+	}
+
+	h.runner.StartAudit(job, products)
+
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"status":         "started",
+		"job_id":         job.ID,
+		"total_products": len(products),
+	})
+}
+
+// ===== SHADOW EVALUATION HANDLERS =====
+
+// GetShadowEvaluations compares shadow variants with the primary model
+func (h *Handlers) GetShadowEvaluations(c echo.Context) error {
+	summaries, err := h.queries.GetShadowSummaries(c.Request().Context(), c.QueryParam("variant"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get shadow evaluations")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"active_variant": h.shadow.Variant(),
+		"data":           summaries,
+	})
+}
+
+// ListShadowRuns returns recent shadow runs
+func (h *Handlers) ListShadowRuns(c echo.Context) error {
+	limit := 50
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+
+	runs, err := h.queries.ListShadowRuns(c.Request().Context(), c.QueryParam("variant"), limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list shadow runs")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"data": runs})
+}
+
+// GetShadowRun returns a shadow run with its proposals
+func (h *Handlers) GetShadowRun(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid shadow run ID")
+	}
+
+	run, err := h.queries.GetShadowRun(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Shadow run not found")
+	}
+
+	return c.JSON(http.StatusOK, run)
+}
+
+// ===== HUMAN REVIEW HANDLERS =====
+
+// humanReviewStatuses are the states a reviewer can set
+var humanReviewStatuses = map[string]bool{"pending": true, "resolved": true, "dismissed": true}
+
+// ListHumanReviews returns the queue of items the agent escalated (pending by default)
+func (h *Handlers) ListHumanReviews(c echo.Context) error {
+	filter := db.HumanReviewFilter{
+		OrgID:       orgID(c),
+		Status:      c.QueryParam("status"),
+		Source:      c.QueryParam("source"),
+		Assignee:    c.QueryParam("assignee"),
+		ReviewState: c.QueryParam("review_state"),
+	}
+	if filter.Status == "" {
+		filter.Status = "pending"
+	} else if filter.Status == "all" {
+		filter.Status = ""
 	}
-	if days > 365 {
-		days = 365
+	if raw := c.QueryParam("dataset_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+		}
+		filter.DatasetID = &id
+	}
+	if raw := c.QueryParam("product_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid product ID")
+		}
+		filter.ProductID = &id
+	}
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &filter.Limit)
 	}
 
-	stats, err := h.queries.GetTokenUsageStats(c.Request().Context(), days)
+	reviews, err := h.queries.ListHumanReviews(c.Request().Context(), filter)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get token usage stats")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list human reviews")
 	}
-
-	return c.JSON(http.StatusOK, stats)
+	pending, err := h.queries.CountPendingHumanReviews(c.Request().Context(), filter.DatasetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count human reviews")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"data": reviews, "pending": pending})
 }
 
-// ===== DATA FEEDS HANDLERS =====
-
-// ListDatasetVersions returns version history for a dataset
-func (h *Handlers) ListDatasetVersions(c echo.Context) error {
+// GetHumanReview returns a single escalated item
+func (h *Handlers) GetHumanReview(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid review ID")
 	}
 
-	versions, err := h.queries.ListDatasetVersions(c.Request().Context(), id)
+	review, err := h.queries.GetHumanReview(c.Request().Context(), id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list versions")
+		return echo.NewHTTPError(http.StatusNotFound, "Review not found")
 	}
-
-	return c.JSON(http.StatusOK, map[string]any{"data": versions})
+	return c.JSON(http.StatusOK, review)
 }
 
-// CreateSnapshot creates a snapshot of current dataset state
-func (h *Handlers) CreateSnapshot(c echo.Context) error {
+// UpdateHumanReview resolves, dismisses or reopens an escalated item
+func (h *Handlers) UpdateHumanReview(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid review ID")
 	}
 
 	var req struct {
-		Name string `json:"name"`
-		Type string `json:"type"` // pre_enrichment, post_enrichment, manual
+		Status     string `json:"status"`     // pending, resolved, dismissed
+		Resolution string `json:"resolution"` // what the reviewer decided or did
+		ResolvedBy string `json:"resolved_by"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
-	if req.Name == "" {
-		req.Name = fmt.Sprintf("Snapshot %s", time.Now().Format("2006-01-02 15:04"))
+	if !humanReviewStatuses[req.Status] {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid status")
 	}
-	if req.Type == "" {
-		req.Type = "manual"
+
+	if r, err := h.queries.GetHumanReview(c.Request().Context(), id); err == nil &&
+		req.ResolvedBy != "" && r.Assignee != "" && r.Assignee != req.ResolvedBy {
+		return echo.NewHTTPError(http.StatusConflict, "Review is assigned to "+r.Assignee)
 	}
 
-	// Get all products for this dataset
-	products, err := h.queries.ListProductsByDataset(c.Request().Context(), id)
+	err = h.queries.UpdateHumanReview(c.Request().Context(), id, req.Status, req.Resolution, req.ResolvedBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "Review not found")
+	}
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get products")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update review")
 	}
 
-	snapshot := models.DatasetSnapshot{
-		ID:           uuid.New(),
-		DatasetID:    id,
-		Name:         req.Name,
-		SnapshotType: req.Type,
-		ProductCount: len(products),
-		CreatedAt:    time.Now(),
+	review, err := h.queries.GetHumanReview(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get review")
 	}
-
-	if err := h.queries.CreateSnapshot(c.Request().Context(), snapshot); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create snapshot")
+	// A tools session paused on this question goes on with the answer
+	if review.Status != "pending" && review.SessionID != nil {
+		if saved, err := h.queries.GetAgentSession(c.Request().Context(), *review.SessionID); err == nil &&
+			saved.Checkpoint != nil && saved.Checkpoint.AwaitingReview != nil && *saved.Checkpoint.AwaitingReview == review.ID {
+			if err := h.resumeSession(c.Request().Context(), saved, review); err != nil {
+				fmt.Printf("Failed to resume session %s after review %s: %v\n", saved.ID, review.ID, err)
+			}
+		}
 	}
+	return c.JSON(http.StatusOK, review)
+}
 
-	if err := h.queries.CreateSnapshotProducts(c.Request().Context(), snapshot.ID, products); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save snapshot products")
-	}
+// ===== REVIEW WORKFLOW HANDLERS (assignment, states, comments) =====
 
-	return c.JSON(http.StatusCreated, snapshot)
+// reviewItem names a reviewable item type in routes and error messages
+type reviewItem struct {
+	target db.ReviewTarget
+	name   string // "Proposal", "Review"
 }
 
-// ListSnapshots returns snapshots for a dataset
-func (h *Handlers) ListSnapshots(c echo.Context) error {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
-	}
-
-	snapshots, err := h.queries.ListSnapshots(c.Request().Context(), id)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list snapshots")
-	}
+var (
+	proposalItem    = reviewItem{db.ReviewTargetProposal, "Proposal"}
+	humanReviewItem = reviewItem{db.ReviewTargetHumanReview, "Review"}
+)
 
-	return c.JSON(http.StatusOK, map[string]any{"data": snapshots})
+// settableReviewStates are the states a reviewer sets directly; approved and rejected come
+// from the decision (PATCH /proposals/:id, PATCH /human-reviews/:id)
+var settableReviewStates = map[string]bool{
+	models.ReviewStatePending:   true,
+	models.ReviewStateInReview:  true,
+	models.ReviewStateNeedsInfo: true,
 }
 
-// DeleteSnapshot deletes a snapshot
-func (h *Handlers) DeleteSnapshot(c echo.Context) error {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid snapshot ID")
-	}
+// AssignProposal gives a proposal to a reviewer (empty assignee releases it)
+func (h *Handlers) AssignProposal(c echo.Context) error {
+	return h.assignReview(c, proposalItem)
+}
 
-	if err := h.queries.DeleteSnapshot(c.Request().Context(), id); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete snapshot")
-	}
+// SetProposalReviewState moves a proposal between pending, in_review and needs_info
+func (h *Handlers) SetProposalReviewState(c echo.Context) error {
+	return h.setReviewState(c, proposalItem)
+}
 
-	return c.NoContent(http.StatusNoContent)
+// ListProposalComments returns the reviewer comments of a proposal
+func (h *Handlers) ListProposalComments(c echo.Context) error {
+	return h.listReviewComments(c, proposalItem)
 }
 
-// GetChangeLog returns change history for a dataset
-func (h *Handlers) GetChangeLog(c echo.Context) error {
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
-	}
+// AddProposalComment adds a reviewer comment to a proposal
+func (h *Handlers) AddProposalComment(c echo.Context) error {
+	return h.addReviewComment(c, proposalItem)
+}
 
-	limit := 100
-	if l := c.QueryParam("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
-	}
+// AssignHumanReview gives an escalated item to a reviewer (empty assignee releases it)
+func (h *Handlers) AssignHumanReview(c echo.Context) error {
+	return h.assignReview(c, humanReviewItem)
+}
 
-	entries, err := h.queries.GetChangeLog(c.Request().Context(), id, limit)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get change log")
-	}
+// SetHumanReviewState moves an escalated item between pending, in_review and needs_info
+func (h *Handlers) SetHumanReviewState(c echo.Context) error {
+	return h.setReviewState(c, humanReviewItem)
+}
 
-	return c.JSON(http.StatusOK, map[string]any{"data": entries})
+// ListHumanReviewComments returns the reviewer comments of an escalated item
+func (h *Handlers) ListHumanReviewComments(c echo.Context) error {
+	return h.listReviewComments(c, humanReviewItem)
 }
 
-// ===== JOB HANDLERS =====
+// AddHumanReviewComment adds a reviewer comment to an escalated item
+func (h *Handlers) AddHumanReviewComment(c echo.Context) error {
+	return h.addReviewComment(c, humanReviewItem)
+}
 
-// ListJobs returns jobs with optional filters
-func (h *Handlers) ListJobs(c echo.Context) error {
-	var datasetID *uuid.UUID
-	if dsID := c.QueryParam("dataset_id"); dsID != "" {
-		id, err := uuid.Parse(dsID)
-		if err == nil {
-			datasetID = &id
-		}
+func (h *Handlers) assignReview(c echo.Context, item reviewItem) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+strings.ToLower(item.name)+" ID")
 	}
 
-	status := c.QueryParam("status")
-	limit := 50
-	if l := c.QueryParam("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
+	var req struct {
+		Assignee string `json:"assignee"` // empty = unassign
+		Force    bool   `json:"force"`    // take over an item assigned to someone else
 	}
-
-	jobs, err := h.queries.ListJobs(c.Request().Context(), datasetID, status, limit)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list jobs")
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
 
-	return c.JSON(http.StatusOK, map[string]any{"data": jobs})
+	err = h.queries.AssignReview(c.Request().Context(), item.target, id, strings.TrimSpace(req.Assignee), req.Force)
+	if err := reviewWorkflowError(err, item, "Failed to assign "+strings.ToLower(item.name)); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"id": id, "assignee": strings.TrimSpace(req.Assignee)})
 }
 
-// GetJobDetails returns details for a single job
-func (h *Handlers) GetJobDetails(c echo.Context) error {
+func (h *Handlers) setReviewState(c echo.Context, item reviewItem) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid job ID")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+strings.ToLower(item.name)+" ID")
 	}
 
-	job, err := h.queries.GetJob(c.Request().Context(), id)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "Job not found")
+	var req struct {
+		State   string `json:"state"`   // pending, in_review, needs_info
+		Author  string `json:"author"`  // must be the assignee if any
+		Comment string `json:"comment"` // e.g. what information is needed
 	}
-
-	return c.JSON(http.StatusOK, job)
-}
-
-// ===== APPROVAL RULES HANDLERS =====
-
-// ListApprovalRules returns approval rules
-func (h *Handlers) ListApprovalRules(c echo.Context) error {
-	var datasetID *uuid.UUID
-	if dsID := c.QueryParam("dataset_id"); dsID != "" {
-		id, err := uuid.Parse(dsID)
-		if err == nil {
-			datasetID = &id
-		}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
-
-	rules, err := h.queries.ListApprovalRules(c.Request().Context(), datasetID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list rules")
+	if !settableReviewStates[req.State] {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid state (approve or reject through the review decision)")
+	}
+	if req.Comment != "" && req.Author == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "author is required with a comment")
 	}
 
-	return c.JSON(http.StatusOK, map[string]any{"data": rules})
+	err = h.queries.SetReviewState(c.Request().Context(), item.target, id, req.State, req.Author, req.Comment)
+	if err := reviewWorkflowError(err, item, "Failed to update review state"); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]any{"id": id, "review_state": req.State})
 }
 
-// CreateApprovalRule creates a new approval rule
-func (h *Handlers) CreateApprovalRule(c echo.Context) error {
-	var req models.ApprovalRule
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+func (h *Handlers) listReviewComments(c echo.Context, item reviewItem) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+strings.ToLower(item.name)+" ID")
 	}
 
-	req.ID = uuid.New()
-	req.CreatedAt = time.Now()
-	req.Active = true
-
-	if err := h.queries.CreateApprovalRule(c.Request().Context(), req); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create rule")
+	comments, err := h.queries.ListReviewComments(c.Request().Context(), item.target, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list comments")
 	}
-
-	return c.JSON(http.StatusCreated, req)
+	return c.JSON(http.StatusOK, map[string]any{"data": comments})
 }
 
-// UpdateApprovalRule updates an existing rule
-func (h *Handlers) UpdateApprovalRule(c echo.Context) error {
+func (h *Handlers) addReviewComment(c echo.Context, item reviewItem) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rule ID")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+strings.ToLower(item.name)+" ID")
 	}
 
-	var req models.ApprovalRule
+	var req struct {
+		Author string `json:"author"`
+		Body   string `json:"body"`
+	}
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
-	req.ID = id
+	if strings.TrimSpace(req.Author) == "" || strings.TrimSpace(req.Body) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "author and body are required")
+	}
 
-	if err := h.queries.UpdateApprovalRule(c.Request().Context(), req); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update rule")
+	comment, err := h.queries.AddReviewComment(c.Request().Context(), item.target, id, req.Author, req.Body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, item.name+" not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to add comment")
 	}
+	return c.JSON(http.StatusCreated, comment)
+}
 
-	return c.JSON(http.StatusOK, req)
+// reviewWorkflowError maps assignment/state errors to HTTP errors (nil when err is nil)
+func reviewWorkflowError(err error, item reviewItem, failed string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pgx.ErrNoRows):
+		return echo.NewHTTPError(http.StatusNotFound, item.name+" not found")
+	case errors.Is(err, db.ErrAssignedToOther):
+		return echo.NewHTTPError(http.StatusConflict, item.name+" is assigned to another reviewer")
+	case errors.Is(err, db.ErrReviewClosed):
+		return echo.NewHTTPError(http.StatusConflict, item.name+" already has a decision")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, failed)
 }
 
-// DeleteApprovalRule deletes a rule
-func (h *Handlers) DeleteApprovalRule(c echo.Context) error {
-	id, err := uuid.Parse(c.Param("id"))
+// ===== SAMPLING REVIEW HANDLERS =====
+
+// CreateReviewSamples draws a random sample per module+field of a dataset's pending proposals
+func (h *Handlers) CreateReviewSamples(c echo.Context) error {
+	var req struct {
+		DatasetID  string   `json:"dataset_id"`
+		Module     string   `json:"module"`     // restrict to one module
+		Field      string   `json:"field"`      // restrict to one field
+		Confidence *float64 `json:"confidence"` // confidence level, default REVIEW_SAMPLE_CONFIDENCE
+		Margin     *float64 `json:"margin"`     // margin of error, default REVIEW_SAMPLE_MARGIN
+		Threshold  *float64 `json:"threshold"`  // acceptance rate to auto-approve, default REVIEW_SAMPLE_THRESHOLD
+		CreatedBy  string   `json:"created_by"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	datasetID, err := uuid.Parse(req.DatasetID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rule ID")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
+	}
+	if err := h.checkDataset(c, datasetID); err != nil {
+		return err
 	}
 
-	if err := h.queries.DeleteApprovalRule(c.Request().Context(), id); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete rule")
+	params := db.ReviewSampleParams{
+		DatasetID:  datasetID,
+		Module:     req.Module,
+		Field:      req.Field,
+		Confidence: h.config.Review.SampleConfidence,
+		Margin:     h.config.Review.SampleMargin,
+		MinSize:    h.config.Review.SampleMinSize,
+		Threshold:  h.config.Review.AutoApproveRate,
+		CreatedBy:  req.CreatedBy,
+	}
+	if req.Confidence != nil {
+		params.Confidence = *req.Confidence
+	}
+	if req.Margin != nil {
+		params.Margin = *req.Margin
+	}
+	if req.Threshold != nil {
+		params.Threshold = *req.Threshold
+	}
+	if params.Confidence <= 0 || params.Confidence >= 1 || params.Margin <= 0 || params.Margin >= 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "confidence and margin must be between 0 and 1")
+	}
+	if params.Threshold <= 0 || params.Threshold > 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "threshold must be between 0 and 1")
 	}
 
-	return c.NoContent(http.StatusNoContent)
+	samples, err := h.queries.CreateReviewSamples(c.Request().Context(), params)
+	if err != nil {
+		fmt.Printf("Review sampling failed for dataset %s: %v\n", datasetID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create review samples")
+	}
+	return c.JSON(http.StatusCreated, map[string]any{"data": samples})
 }
 
-// ApplyApprovalRules applies all active rules to pending proposals
-func (h *Handlers) ApplyApprovalRules(c echo.Context) error {
+// ListReviewSamples returns review samples with their results (?dataset_id, status)
+func (h *Handlers) ListReviewSamples(c echo.Context) error {
 	var datasetID *uuid.UUID
-	if dsID := c.QueryParam("dataset_id"); dsID != "" {
-		id, err := uuid.Parse(dsID)
-		if err == nil {
-			datasetID = &id
+	if raw := c.QueryParam("dataset_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dataset ID")
 		}
+		datasetID = &id
 	}
 
-	affected, err := h.queries.ApplyApprovalRules(c.Request().Context(), datasetID)
+	samples, err := h.queries.ListReviewSamples(c.Request().Context(), orgID(c), datasetID, c.QueryParam("status"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply rules")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list review samples")
 	}
-
-	return c.JSON(http.StatusOK, map[string]any{
-		"affected": affected,
-		"message":  fmt.Sprintf("Applied rules to %d proposals", affected),
-	})
+	return c.JSON(http.StatusOK, map[string]any{"data": samples})
 }
 
-// ===== PROPOSALS BY MODULE =====
-
-// GetProposalsByModule returns proposals grouped by module
-func (h *Handlers) GetProposalsByModule(c echo.Context) error {
-	var datasetID *uuid.UUID
-	if dsID := c.QueryParam("dataset_id"); dsID != "" {
-		id, err := uuid.Parse(dsID)
-		if err == nil {
-			datasetID = &id
-		}
+// GetReviewSample returns a sample with its results and sampled proposals
+func (h *Handlers) GetReviewSample(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid sample ID")
 	}
 
-	groups, err := h.queries.GetProposalsByModule(c.Request().Context(), datasetID)
+	sample, err := h.queries.GetReviewSample(c.Request().Context(), id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get proposals by module")
+		return echo.NewHTTPError(http.StatusNotFound, "Sample not found")
 	}
-
-	return c.JSON(http.StatusOK, map[string]any{"data": groups})
+	return c.JSON(http.StatusOK, sample)
 }
 
-// ListProposalsByModuleFiltered returns proposals for a specific module
-func (h *Handlers) ListProposalsByModuleFiltered(c echo.Context) error {
-	module := c.QueryParam("module")
-	status := c.QueryParam("status")
-	
-	var datasetID *uuid.UUID
-	if dsID := c.QueryParam("dataset_id"); dsID != "" {
-		id, err := uuid.Parse(dsID)
-		if err == nil {
-			datasetID = &id
-		}
+// AutoApproveReviewSample accepts the rest of a sample's module+field once the sample is
+// fully reviewed and at or above its acceptance threshold
+func (h *Handlers) AutoApproveReviewSample(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid sample ID")
 	}
 
-	limit := 100
-	if l := c.QueryParam("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
+	var req struct {
+		Reviewer string `json:"reviewer"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
 
-	proposals, err := h.queries.ListProposalsByModule(c.Request().Context(), module, datasetID, status, limit)
+	sample, err := h.queries.AutoApproveSample(c.Request().Context(), id, req.Reviewer)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return echo.NewHTTPError(http.StatusNotFound, "Sample not found")
+	case errors.Is(err, db.ErrSampleClosed):
+		return echo.NewHTTPError(http.StatusConflict, "Sample is not open")
+	case errors.Is(err, db.ErrSampleIncomplete):
+		return echo.NewHTTPError(http.StatusConflict, "Sample still has proposals to review")
+	case errors.Is(err, db.ErrSampleBelowThreshold):
+		return echo.NewHTTPError(http.StatusConflict, "Sample acceptance rate is below the threshold")
+	case err != nil:
+		fmt.Printf("Sample %s auto-approval failed: %v\n", id, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to auto-approve proposals")
+	}
+	return c.JSON(http.StatusOK, sample)
+}
+
+// CloseReviewSample ends a sample without auto-approving the rest
+func (h *Handlers) CloseReviewSample(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list proposals")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid sample ID")
+	}
+
+	var req struct {
+		ClosedBy string `json:"closed_by"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
 
-	return c.JSON(http.StatusOK, map[string]any{"data": proposals})
+	err = h.queries.CloseReviewSample(c.Request().Context(), id, req.ClosedBy)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return echo.NewHTTPError(http.StatusNotFound, "Sample not found")
+	case errors.Is(err, db.ErrSampleClosed):
+		return echo.NewHTTPError(http.StatusConflict, "Sample is not open")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to close sample")
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "closed"})
 }
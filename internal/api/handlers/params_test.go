@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestLimitParam(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+		fails bool
+	}{
+		{"", 100, false},
+		{"limit=1", 1, false},
+		{"limit=1000", 1000, false},
+		{"limit=0", 0, true},
+		{"limit=1001", 0, true},
+		{"limit=-5", 0, true},
+		{"limit=ten", 0, true},
+		{"limit=10abc", 0, true},
+	}
+	for _, tt := range tests {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/proposals?"+tt.query, nil), httptest.NewRecorder())
+		got, err := limitParam(c, 100, 1000)
+		if tt.fails {
+			if he, ok := err.(*echo.HTTPError); !ok || he.Code != http.StatusBadRequest {
+				t.Errorf("%q: err = %v, want 400", tt.query, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q = %d, %v, want %d", tt.query, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// benchRows is the size of the generated feed, the 100k rows of the import target of
+// docs/09-test-plan.md
+const benchRows = 100000
+
+// writeFeed writes a TSV feed with realistic field sizes, named like an upload
+func writeFeed(tb testing.TB, rows int) string {
+	var b bytes.Buffer
+	b.WriteString("id\ttitle\tdescription\tprice\tbrand\tcolor\timage_link\n")
+	desc := strings.Repeat("Comfortable everyday product made from durable materials. ", 4)
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "BENCH-%07d\tProduct %d running shoe\t%s\t%d.99 EUR\tAcme\tblue\thttps://example.com/img/%d.jpg\n",
+			i, i, desc, 10+i%200, i)
+	}
+	path := filepath.Join(tb.TempDir(), uuid.NewString()+"_bench.tsv")
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func BenchmarkParseFile(b *testing.B) {
+	path := writeFeed(b, benchRows)
+	info, _ := os.Stat(path)
+	h := &Handlers{}
+
+	b.SetBytes(info.Size())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, products, err := h.parseFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if rows != benchRows || len(products) != benchRows {
+			b.Fatalf("parsed %d rows, %d products, want %d", rows, len(products), benchRows)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IdempotencyHeader is the request header carrying the client-chosen key
+const IdempotencyHeader = "Idempotency-Key"
+
+// idempotencyTTL is how long a key and its response are remembered
+const idempotencyTTL = 24 * time.Hour
+
+// idempotent dedupes requests sent with an Idempotency-Key header: the first request
+// runs, concurrent duplicates get 409, and later duplicates replay the stored response
+// without re-running the handler (and without spending tokens again).
+// Requests without the header are passed through unchanged.
+func (s *Server) idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(IdempotencyHeader)
+		if key == "" {
+			return next(c)
+		}
+		if len(key) > 255 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Idempotency-Key too long")
+		}
+
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+
+		ctx := c.Request().Context()
+		// Same scope whether the route was called through /api or /api/v1
+		scope := c.Request().Method + " " + versionedPath(c.Request().URL.Path)
+		existing, claimed, err := s.queries.ClaimIdempotencyKey(ctx, scope, key, hash, idempotencyTTL)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check idempotency key")
+		}
+		if !claimed {
+			if existing.RequestHash != hash {
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, "Idempotency-Key already used with a different request body")
+			}
+			if existing.Status != "completed" {
+				return echo.NewHTTPError(http.StatusConflict, "A request with this Idempotency-Key is already in progress")
+			}
+			c.Response().Header().Set("Idempotent-Replayed", "true")
+			return c.Blob(existing.StatusCode, echo.MIMEApplicationJSONCharsetUTF8, existing.Response)
+		}
+
+		// Capture the response so it can be replayed
+		rec := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = rec
+
+		handlerErr := next(c)
+		status := c.Response().Status
+		if he, ok := handlerErr.(*echo.HTTPError); ok {
+			status = he.Code
+		}
+
+		// Use a detached context: the key must be settled even if the client went away
+		bg := context.Background()
+		if handlerErr != nil || status >= http.StatusInternalServerError {
+			// Failed requests may be retried with the same key
+			s.queries.ReleaseIdempotencyKey(bg, scope, key)
+			return handlerErr
+		}
+		s.queries.CompleteIdempotencyKey(bg, scope, key, status, rec.body.Bytes())
+		return nil
+	}
+}
+
+// responseRecorder tees the response body into a buffer
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
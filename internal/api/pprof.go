@@ -0,0 +1,49 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerPprof mounts the runtime profiles under /debug/pprof when PPROF_ENABLED is set
+func (s *Server) registerPprof() {
+	if !s.config.Server.Pprof {
+		return
+	}
+	g := s.echo.Group("/debug/pprof", s.pprofGuard)
+	g.GET("/", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	g.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	g.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.POST("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+	// heap, goroutine, allocs, block, mutex, threadcreate
+	g.GET("/:profile", func(c echo.Context) error {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Response(), c.Request())
+		return nil
+	})
+}
+
+// pprofGuard requires PPROF_TOKEN as a bearer token, or a loopback client when no token is set
+func (s *Server) pprofGuard(next echo.HandlerFunc) echo.HandlerFunc {
+	token := s.config.Server.PprofToken
+	return func(c echo.Context) error {
+		if token == "" {
+			host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+			if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+				return echo.NewHTTPError(http.StatusForbidden, "Profiling is only available from localhost without PPROF_TOKEN")
+			}
+			return next(c)
+		}
+		got := strings.TrimPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid profiling token")
+		}
+		return next(c)
+	}
+}
@@ -2,13 +2,20 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/benjamincozon/feedenrich/internal/agent"
 	"github.com/benjamincozon/feedenrich/internal/agent/tools"
 	"github.com/benjamincozon/feedenrich/internal/api/handlers"
 	"github.com/benjamincozon/feedenrich/internal/config"
 	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/jobs"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/shadow"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -18,6 +25,13 @@ type Server struct {
 	config  *config.Config
 	queries *db.Queries
 	agent   *agent.Agent
+	runner  *jobs.Runner
+	shadow  *shadow.Evaluator
+	orgIDs  sync.Map // organization ID or slug -> ID, see organizationID
+
+	urlTokenKey []byte // signs URL tokens, see IssueURLToken
+
+	handlers *handlers.Handlers
 }
 
 func NewServer(cfg *config.Config, queries *db.Queries) *Server {
@@ -25,7 +39,11 @@ func NewServer(cfg *config.Config, queries *db.Queries) *Server {
 	e.HideBanner = true
 
 	// Middleware
-	e.Use(middleware.Logger())
+	// Access log without the URL tokens of image and export links
+	logConfig := middleware.DefaultLoggerConfig
+	logConfig.Format = strings.Replace(logConfig.Format, "${uri}", "${custom}", 1)
+	logConfig.CustomTagFunc = logURI
+	e.Use(middleware.LoggerWithConfig(logConfig))
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
@@ -35,12 +53,27 @@ func NewServer(cfg *config.Config, queries *db.Queries) *Server {
 	
 	// Set token tracker to record usage to database
 	agnt.SetTokenTracker(queries)
+	agnt.SetPricing(queries)
+	agnt.SetResponseCache(queries)
+	agnt.SetFeedbackSource(queries)
+	agnt.SetBrandSource(queries)
+	agnt.SetTrustSource(queries)
+	agnt.SetImageHashStore(queries)
+	agnt.SetImageCheckSource(queries)
+	llm.StagesFor(cfg).SetSource(queries)
+
+	// Shadow evaluation of a candidate model (nil when disabled)
+	shadowEval := shadow.New(cfg, queries, agnt)
 
 	s := &Server{
 		echo:    e,
 		config:  cfg,
 		queries: queries,
 		agent:   agnt,
+		runner:  jobs.NewRunner(cfg, queries, agnt, shadowEval),
+		shadow:  shadowEval,
+
+		urlTokenKey: urlTokenSecret(cfg.Server.URLTokenSecret),
 	}
 
 	s.setupRoutes()
@@ -48,87 +81,262 @@ func NewServer(cfg *config.Config, queries *db.Queries) *Server {
 }
 
 func (s *Server) setupRoutes() {
-	// Health check
+	// Health check, with the migration the database is at and the one the binary requires
 	s.echo.GET("/health", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+		version, err := s.queries.AppliedSchemaVersion(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]any{"status": "database unavailable"})
+		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"status":          "ok",
+			"schema_version":  version,
+			"schema_required": db.SchemaVersion,
+		})
+	})
+
+	h := handlers.NewHandlers(s.config, s.queries, s.agent, s.runner, s.shadow)
+	s.handlers = h
+
+	// Versioned API. The unversioned /api prefix serves the same routes for existing
+	// clients until its sunset (see deprecated). Both require an API key when
+	// API_AUTH_ENABLED is set (see authenticate).
+	s.registerAPI(s.echo.Group(APIPrefix, s.authenticate, s.isolate), h)
+	s.registerAPI(s.echo.Group(legacyAPIPrefix, s.deprecated, s.authenticate, s.isolate), h)
+
+	// Web UI under its own prefix; unknown paths fall back to index.html for client-side routing
+	s.echo.Group(webPrefix, middleware.StaticWithConfig(middleware.StaticConfig{
+		Root:  "web/static",
+		HTML5: true,
+	}))
+	s.echo.GET("/", func(c echo.Context) error {
+		return c.Redirect(http.StatusFound, webPrefix+"/")
 	})
 
-	// API routes
-	api := s.echo.Group("/api")
+	s.registerPprof()
+}
+
+// registerAPI mounts every API route on a group. GET routes need the read scope, the
+// others declare theirs.
+func (s *Server) registerAPI(api *echo.Group, h *handlers.Handlers) {
+	review := s.requireScope(models.ScopeReview)
+	enrich := s.requireScope(models.ScopeEnrich)
+	admin := s.requireScope(models.ScopeAdmin)
 
 	// Datasets
-	h := handlers.NewHandlers(s.config, s.queries, s.agent)
-	api.POST("/datasets/upload", h.UploadDataset)
+	api.POST("/datasets/upload", h.UploadDataset, enrich)
+	api.POST("/datasets/:id/reimport", h.ReimportDataset, enrich)
 	api.GET("/datasets", h.ListDatasets)
 	api.GET("/datasets/:id", h.GetDataset)
-	api.DELETE("/datasets/:id", h.DeleteDataset)
+	api.DELETE("/datasets/:id", h.DeleteDataset, admin)
 	api.GET("/datasets/:id/export", h.ExportDataset)
 	api.GET("/datasets/:id/stats", h.GetDatasetStats)
+	api.GET("/datasets/:id/thresholds", h.GetDatasetThresholds)
+	api.PUT("/datasets/:id/thresholds", h.UpdateDatasetThresholds, admin)
+	api.GET("/datasets/:id/budget", h.GetDatasetBudget)
+	api.PUT("/datasets/:id/budget", h.UpdateDatasetBudget, admin)
+	api.GET("/datasets/:id/engine", h.GetDatasetEngine)
+	api.PUT("/datasets/:id/engine", h.UpdateDatasetEngine, admin)
 
 	// Data Feeds - Versions, Snapshots, Change Log
 	api.GET("/datasets/:id/versions", h.ListDatasetVersions)
-	api.POST("/datasets/:id/snapshots", h.CreateSnapshot)
+	api.POST("/datasets/:id/snapshots", h.CreateSnapshot, enrich)
 	api.GET("/datasets/:id/snapshots", h.ListSnapshots)
-	api.DELETE("/snapshots/:id", h.DeleteSnapshot)
+	api.DELETE("/snapshots/:id", h.DeleteSnapshot, admin)
 	api.GET("/datasets/:id/changelog", h.GetChangeLog)
 
 	// Products
 	api.GET("/datasets/:id/products", h.ListProducts)
+	api.GET("/datasets/:id/products/search", h.SearchProducts)
 	api.GET("/products/:id", h.GetProduct)
+	api.PATCH("/products/:id", h.UpdateProduct, review)
+	api.POST("/products/:id/tags", h.AddProductTag, review)
+	api.DELETE("/products/:id/tags/:tag", h.RemoveProductTag, review)
+	api.GET("/datasets/:id/tags", h.ListDatasetTags)
+
+	// Segments (saved product filters)
+	api.GET("/datasets/:id/segments", h.ListSegments)
+	api.POST("/datasets/:id/segments", h.CreateSegment, review)
+	api.GET("/segments/:id", h.GetSegment)
+	api.PATCH("/segments/:id", h.UpdateSegment, review)
+	api.DELETE("/segments/:id", h.DeleteSegment, review)
+	api.GET("/segments/:id/products", h.ListSegmentProducts)
+	api.GET("/segments/:id/stats", h.GetSegmentStats)
+
+	// Brand dictionary
+	api.GET("/datasets/:id/brands", h.ListBrands)
+	api.GET("/datasets/:id/brand-aliases", h.ListBrandAliases)
+	api.POST("/datasets/:id/brand-aliases", h.SetBrandAlias, enrich)
+	api.DELETE("/brand-aliases/:id", h.DeleteBrandAlias, enrich)
+	api.GET("/datasets/:id/trusted-domains", h.ListTrustedDomains)
+	api.POST("/datasets/:id/trusted-domains", h.AddTrustedDomain, enrich)
+	api.DELETE("/trusted-domains/:id", h.DeleteTrustedDomain, enrich)
 
 	// Agent
-	api.POST("/products/:id/enrich", h.EnrichProduct)
-	api.POST("/datasets/:id/enrich", h.EnrichDataset)
+	api.POST("/products/:id/enrich", h.EnrichProduct, enrich, s.idempotent)
+	api.POST("/datasets/:id/enrich", h.EnrichDataset, enrich, s.idempotent)
+	api.POST("/datasets/:id/estimate", h.EstimateDataset, enrich)
 	api.GET("/agent/sessions/:id", h.GetAgentSession)
 	api.GET("/agent/sessions/:id/trace", h.GetAgentTrace)
+	api.GET("/agent/sessions/:id/llm-calls", h.GetAgentLLMCalls)
+	api.GET("/agent/sessions/:id/pipeline", h.GetAgentPipeline)
+	api.POST("/agent/sessions/:id/pause", h.PauseAgentSession, enrich)
+	api.POST("/agent/sessions/:id/resume", h.ResumeAgentSession, enrich)
 
 	// Feed Audit
 	api.GET("/audit/groups", h.GetAuditGroups)
-	api.POST("/datasets/:id/audit", h.AuditDataset)
+	api.POST("/datasets/:id/audit", h.AuditDataset, enrich)
 
 	// Jobs (Execution tracking)
 	api.GET("/jobs", h.ListJobs)
+	api.GET("/jobs/queue", h.GetJobQueue)
 	api.GET("/jobs/:id", h.GetJobDetails)
+	api.POST("/jobs/:id/pause", h.PauseJob, enrich)
+	api.POST("/jobs/:id/resume", h.ResumeJob, enrich)
+	api.POST("/jobs/:id/cancel", h.CancelJob, enrich)
+	api.GET("/jobs/:id/failures", h.ListJobFailures)
+	api.POST("/jobs/:id/failures/requeue", h.RequeueJobFailures, enrich)
+
+	// Re-enrichment of stale products (data changed or last run too old)
+	api.GET("/datasets/:id/stale", h.ListStaleProducts)
+	api.POST("/datasets/:id/refresh", h.RefreshDataset, enrich)
+	api.GET("/datasets/:id/outcomes", h.GetDatasetOutcomes)
+	api.GET("/products/:id/outcomes", h.GetProductOutcomes)
 
 	// Proposals
 	api.GET("/proposals", h.ListProposals)
 	api.GET("/proposals/with-products", h.ListProposalsWithProducts)
 	api.GET("/proposals/by-module", h.GetProposalsByModule)
 	api.GET("/proposals/module", h.ListProposalsByModuleFiltered)
+	api.GET("/proposals/conflicts", h.ListProposalConflicts)
+	api.GET("/proposals/groups", h.ListProposalGroups)
+	api.POST("/proposals/groups/accept", h.AcceptProposalGroup, review)
 	api.GET("/proposals/:id", h.GetProposal)
-	api.PATCH("/proposals/:id", h.UpdateProposal)
-	api.POST("/proposals/bulk", h.BulkUpdateProposals)
-	api.POST("/proposals/apply-rules", h.ApplyApprovalRules)
+	api.PATCH("/proposals/:id", h.UpdateProposal, review)
+	api.POST("/proposals/:id/revert", h.RevertProposal, review)
+	api.POST("/proposals/:id/assign", h.AssignProposal, review)
+	api.POST("/proposals/:id/state", h.SetProposalReviewState, review)
+	api.GET("/proposals/:id/sources", h.GetProposalSources)
+	api.GET("/proposals/:id/comments", h.ListProposalComments)
+	api.POST("/proposals/:id/comments", h.AddProposalComment, review)
+	api.POST("/proposals/bulk", h.BulkUpdateProposals, review)
+	api.POST("/proposals/apply-rules", h.ApplyApprovalRules, review)
+	api.POST("/proposals/score", h.ScoreProposals, enrich)
+
+	// Sampling review (review a sample, auto-approve the rest when it passes)
+	api.POST("/review-samples", h.CreateReviewSamples, review)
+	api.GET("/review-samples", h.ListReviewSamples)
+	api.GET("/review-samples/:id", h.GetReviewSample)
+	api.POST("/review-samples/:id/auto-approve", h.AutoApproveReviewSample, review)
+	api.POST("/review-samples/:id/close", h.CloseReviewSample, review)
+
+	// Human review queue (items the agent escalated)
+	api.GET("/human-reviews", h.ListHumanReviews)
+	api.GET("/human-reviews/:id", h.GetHumanReview)
+	api.PATCH("/human-reviews/:id", h.UpdateHumanReview, review)
+	api.POST("/human-reviews/:id/assign", h.AssignHumanReview, review)
+	api.POST("/human-reviews/:id/state", h.SetHumanReviewState, review)
+	api.GET("/human-reviews/:id/comments", h.ListHumanReviewComments)
+	api.POST("/human-reviews/:id/comments", h.AddHumanReviewComment, review)
 
 	// Approval Rules
 	api.GET("/approval-rules", h.ListApprovalRules)
-	api.POST("/approval-rules", h.CreateApprovalRule)
-	api.PATCH("/approval-rules/:id", h.UpdateApprovalRule)
-	api.DELETE("/approval-rules/:id", h.DeleteApprovalRule)
+	api.POST("/approval-rules", h.CreateApprovalRule, admin)
+	api.PATCH("/approval-rules/:id", h.UpdateApprovalRule, admin)
+	api.DELETE("/approval-rules/:id", h.DeleteApprovalRule, admin)
 
 	// Rules (validation rules - legacy)
 	api.GET("/rules", h.ListRules)
-	api.POST("/rules", h.CreateRule)
-	api.PATCH("/rules/:id", h.UpdateRule)
-	api.DELETE("/rules/:id", h.DeleteRule)
+	api.POST("/rules", h.CreateRule, admin)
+	api.PATCH("/rules/:id", h.UpdateRule, admin)
+	api.DELETE("/rules/:id", h.DeleteRule, admin)
 
 	// Prompts
 	api.GET("/prompts", h.ListPrompts)
+	api.GET("/prompts/stages", h.ListStageParams)
+	api.PUT("/prompts/stages/:stage", h.UpdateStageParams, s.platform)
 	api.GET("/prompts/:id", h.GetPrompt)
-	api.PATCH("/prompts/:id", h.UpdatePrompt)
+	api.PATCH("/prompts/:id", h.UpdatePrompt, s.platform)
 
 	// Token usage stats
 	api.GET("/token-usage", h.GetTokenUsageStats)
 
-	// Serve static files for frontend
-	s.echo.Static("/", "web/static")
+	// Response cache (optimization answers reused for unchanged products)
+	api.GET("/response-cache", h.GetResponseCacheStats)
+	api.DELETE("/response-cache", h.ClearResponseCache, s.platform)
+
+	// Web search quota and circuit breaker
+	api.GET("/websearch/status", h.GetWebSearchStatus)
+
+	// Model pricing (costs recorded with token usage)
+	api.GET("/model-prices", h.ListModelPrices)
+	api.POST("/model-prices", h.SetModelPrice, s.platform)
+	api.DELETE("/model-prices/:id", h.DeleteModelPrice, s.platform)
+
+	// Audit retention (change_log / agent_traces partitions)
+	api.GET("/retention/partitions", h.ListAuditPartitions, s.platform)
+	api.GET("/retention/archives", h.ListAuditArchives, s.platform)
+	api.POST("/retention/run", h.RunRetention, s.platform)
+
+	// Shadow evaluation (candidate model output, never shown to reviewers)
+	api.GET("/shadow/evaluations", h.GetShadowEvaluations, s.platform)
+	api.GET("/shadow/runs", h.ListShadowRuns, s.platform)
+	api.GET("/shadow/runs/:id", h.GetShadowRun, s.platform)
+
+	// Image proxy (cached, resized product images for the review UI)
+	api.GET("/images", h.GetImage)
+
+	// Image checks (broken, non-image and hotlink-protected image_link)
+	api.POST("/datasets/:id/image-check", h.StartImageCheck, enrich)
+	api.GET("/datasets/:id/image-check", h.GetImageCheckReport)
+
+	// Image audit (image quality scorecard of a dataset, no proposals)
+	api.POST("/datasets/:id/image-audit", h.StartImageAudit, enrich)
+
+	// Organizations (tenants) and their members
+	api.GET("/organizations", h.ListOrganizations, s.platform)
+	api.POST("/organizations", h.CreateOrganization, s.platform)
+	api.GET("/organization", h.GetOrganization)
+	api.PATCH("/organization", h.UpdateOrganization, admin)
+	api.GET("/users", h.ListUsers, admin)
+	api.POST("/users", h.CreateUser, admin)
+	api.DELETE("/users/:id", h.DeleteUser, admin)
+
+	// API keys (of the request's organization)
+	api.GET("/api-keys", h.ListAPIKeys, admin)
+	api.POST("/api-keys", h.CreateAPIKey, admin)
+	api.DELETE("/api-keys/:id", h.RevokeAPIKey, admin)
+
+	// Short-lived tokens for the image and export URLs the browser loads itself
+	api.GET("/url-token", s.IssueURLToken)
 }
 
 func (s *Server) Start(ctx context.Context) error {
+	go s.runner.StartScheduler(ctx)
+	go s.runner.PollBatches(ctx)
+
 	addr := ":" + s.config.Server.Port
 	return s.echo.Start(addr)
 }
 
+// Shutdown stops accepting requests, then waits until ctx is done for in-flight
+// enrichment to finish: EnrichProduct runs are saved, jobs are paused at their
+// checkpoint so they can be resumed. Work still running at the deadline is aborted.
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.echo.Shutdown(ctx)
+	if err := s.echo.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var drainErr, jobsErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		drainErr = s.handlers.Drain(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		jobsErr = s.runner.Shutdown(ctx)
+	}()
+	wg.Wait()
+	return errors.Join(drainErr, jobsErr)
 }
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/api/handlers"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// urlTokenParam carries a URL token on the GET requests the browser makes itself
+// (image proxy, export links), which can't set headers
+const urlTokenParam = "token"
+
+// urlTokenTTL bounds how long a leaked URL token (browser history, proxy logs) stays usable
+const urlTokenTTL = 10 * time.Minute
+
+// urlTokenRoutes are the routes, under either API prefix, accepting a URL token instead of
+// an API key
+var urlTokenRoutes = []string{"/images", "/datasets/:id/export"}
+
+// redactedParams are the query parameters the access log doesn't show
+var redactedParams = map[string]bool{urlTokenParam: true, "api_key": true}
+
+// urlTokenSecret returns the key signing URL tokens: URL_TOKEN_SECRET, or a random one
+// when unset, so tokens then don't outlive the process
+func urlTokenSecret(configured string) []byte {
+	if configured != "" {
+		return []byte(configured)
+	}
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
+
+// IssueURLToken returns a read-only token for the organization of the request, valid
+// urlTokenTTL on urlTokenRoutes
+func (s *Server) IssueURLToken(c echo.Context) error {
+	orgID, _ := c.Get(handlers.OrgContext).(uuid.UUID)
+	expiresAt := time.Now().Add(urlTokenTTL).Truncate(time.Second)
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": map[string]any{
+			"token":      s.signURLToken(orgID, expiresAt),
+			"expires_at": expiresAt,
+		},
+	})
+}
+
+// signURLToken encodes "<org>.<expiry>.<signature>"
+func (s *Server) signURLToken(orgID uuid.UUID, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%s.%d", orgID, expiresAt.Unix())
+	return payload + "." + s.urlTokenSignature(payload)
+}
+
+func (s *Server) urlTokenSignature(payload string) string {
+	mac := hmac.New(sha256.New, s.urlTokenKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// acceptsURLToken reports whether the route of c may be authenticated with a URL token
+func acceptsURLToken(c echo.Context) bool {
+	method := c.Request().Method
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+	for _, route := range urlTokenRoutes {
+		if c.Path() == APIPrefix+route || c.Path() == legacyAPIPrefix+route {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateURLToken serves a request carrying a URL token with the read scope, for the
+// organization it was issued to
+func (s *Server) authenticateURLToken(c echo.Context, token string, next echo.HandlerFunc) error {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(s.urlTokenSignature(token[:i]))) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid URL token")
+	}
+	org, expiry, _ := strings.Cut(token[:i], ".")
+	orgID, err := uuid.Parse(org)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid URL token")
+	}
+	if exp, err := strconv.ParseInt(expiry, 10, 64); err != nil || time.Now().Unix() > exp {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Expired URL token")
+	}
+
+	c.Set(apiKeyContext, &models.APIKey{Name: "url token", OrgID: orgID, Scopes: []string{models.ScopeRead}})
+	c.Set(handlers.OrgContext, orgID)
+	return next(c)
+}
+
+// redactedURI is the request URI with the values of redactedParams hidden, for the access log
+func redactedURI(req *http.Request) string {
+	if req.URL.RawQuery == "" {
+		return req.RequestURI
+	}
+	params := strings.Split(req.URL.RawQuery, "&")
+	for i, param := range params {
+		if name, _, _ := strings.Cut(param, "="); redactedParams[name] {
+			params[i] = name + "=REDACTED"
+		}
+	}
+	return req.URL.EscapedPath() + "?" + strings.Join(params, "&")
+}
+
+// logURI writes redactedURI into the JSON access log line
+func logURI(c echo.Context, buf *bytes.Buffer) (int, error) {
+	quoted, _ := json.Marshal(redactedURI(c.Request()))
+	return buf.Write(quoted[1 : len(quoted)-1])
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/api/handlers"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func TestURLToken(t *testing.T) {
+	s := &Server{urlTokenKey: urlTokenSecret("")}
+	orgID := uuid.New()
+	valid := s.signURLToken(orgID, time.Now().Add(time.Minute))
+	other := &Server{urlTokenKey: urlTokenSecret("")}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"expired", s.signURLToken(orgID, time.Now().Add(-time.Second)), http.StatusUnauthorized},
+		{"other secret", other.signURLToken(orgID, time.Now().Add(time.Minute)), http.StatusUnauthorized},
+		{"other organization", uuid.NewString() + valid[len(orgID.String()):], http.StatusUnauthorized},
+		{"no signature", "garbage", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			err := s.authenticateURLToken(c, tt.token, func(c echo.Context) error {
+				if got := c.Get(handlers.OrgContext); got != orgID {
+					t.Errorf("organization = %v, want %v", got, orgID)
+				}
+				return c.NoContent(http.StatusOK)
+			})
+			code := c.Response().Status
+			if he, ok := err.(*echo.HTTPError); ok {
+				code = he.Code
+			}
+			if code != tt.want {
+				t.Fatalf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptsURLToken(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{http.MethodGet, APIPrefix + "/images", true},
+		{http.MethodGet, legacyAPIPrefix + "/datasets/:id/export", true},
+		{http.MethodPost, APIPrefix + "/images", false},
+		{http.MethodGet, APIPrefix + "/datasets/:id", false},
+		{http.MethodGet, APIPrefix + "/url-token", false},
+	}
+	for _, tt := range tests {
+		c := echo.New().NewContext(httptest.NewRequest(tt.method, "/", nil), httptest.NewRecorder())
+		c.SetPath(tt.path)
+		if got := acceptsURLToken(c); got != tt.want {
+			t.Errorf("%s %s = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRedactedURI(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1/images?url=" + url.QueryEscape("https://a.example/x.jpg") + "&w=96&token=abc.def": "/api/v1/images?url=https%3A%2F%2Fa.example%2Fx.jpg&w=96&token=REDACTED",
+		"/api/v1/datasets?api_key=fe_secret": "/api/v1/datasets?api_key=REDACTED",
+		"/api/v1/datasets?limit=10":          "/api/v1/datasets?limit=10",
+		"/api/v1/datasets":                   "/api/v1/datasets",
+	}
+	for uri, want := range tests {
+		if got := redactedURI(httptest.NewRequest(http.MethodGet, uri, nil)); got != want {
+			t.Errorf("redactedURI(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
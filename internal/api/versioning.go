@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// APIPrefix is the current API version
+	APIPrefix = "/api/v1"
+	// legacyAPIPrefix serves the same routes unversioned, deprecated
+	legacyAPIPrefix = "/api"
+	// webPrefix is where the web UI is served
+	webPrefix = "/app"
+)
+
+// deprecated marks responses of the unversioned API with Deprecation, Link (successor)
+// and, when API_LEGACY_SUNSET is set, Sunset headers. After the sunset date the legacy
+// routes answer 410 Gone.
+func (s *Server) deprecated(next echo.HandlerFunc) echo.HandlerFunc {
+	var sunset time.Time
+	if raw := s.config.Server.LegacyAPISunset; raw != "" {
+		if t, err := time.Parse("2006-01-02", raw); err == nil {
+			sunset = t
+		}
+	}
+
+	return func(c echo.Context) error {
+		// Unknown /api/v1 paths also reach the legacy group's catch-all
+		if strings.HasPrefix(c.Request().URL.Path, APIPrefix+"/") {
+			return next(c)
+		}
+		successor := versionedPath(c.Request().URL.Path)
+		header := c.Response().Header()
+		header.Set("Deprecation", "true")
+		header.Set("Link", "<"+successor+`>; rel="successor-version"`)
+		if !sunset.IsZero() {
+			header.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			if time.Now().After(sunset) {
+				return echo.NewHTTPError(http.StatusGone, "This API version was removed, use "+successor)
+			}
+		}
+		return next(c)
+	}
+}
+
+// versionedPath maps a legacy /api path to its /api/v1 equivalent
+func versionedPath(p string) string {
+	if strings.HasPrefix(p, APIPrefix+"/") || p == APIPrefix {
+		return p
+	}
+	return APIPrefix + strings.TrimPrefix(p, legacyAPIPrefix)
+}
@@ -0,0 +1,265 @@
+// Package barcode reads the EAN-13, UPC-A and EAN-8 barcodes of product photos: packaging
+// shots often show the product's GTIN. The image is scanned along rows and columns; a code
+// is only returned when its check digit is right and at least two scan lines read it.
+package barcode
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"sort"
+)
+
+const (
+	scanLines   = 96  // rows, and as many columns, scanned per image
+	minContrast = 48  // gray levels between the darkest and lightest pixel of a line
+	maxDigitErr = 1.6 // summed module error of a digit's four bars and spaces
+	minReads    = 2   // scan lines that must agree on a code
+)
+
+// Bar and space widths, in modules, of the digits: L codes (left half, odd parity) and R
+// codes (right half) share the widths, G codes (left half, even parity) are L reversed
+var lCodes = [10][4]int{
+	{3, 2, 1, 1}, {2, 2, 2, 1}, {2, 1, 2, 2}, {1, 4, 1, 1}, {1, 1, 3, 2},
+	{1, 2, 3, 1}, {1, 1, 1, 4}, {1, 3, 1, 2}, {1, 2, 1, 3}, {3, 1, 1, 2},
+}
+
+var gCodes = func() (g [10][4]int) {
+	for d, l := range lCodes {
+		g[d] = [4]int{l[3], l[2], l[1], l[0]}
+	}
+	return g
+}()
+
+// firstDigits maps the parity of the six left digits of an EAN-13 (bit set = G code, first
+// digit on the high bit) to the first digit, which has no bars of its own
+var firstDigits = map[int]int{
+	0b000000: 0, 0b001011: 1, 0b001101: 2, 0b001110: 3, 0b010011: 4,
+	0b011001: 5, 0b011100: 6, 0b010101: 7, 0b010110: 8, 0b011010: 9,
+}
+
+// Scan decodes an image (JPEG, PNG or GIF) and returns the GTINs of the barcodes it shows,
+// the most read first. UPC-A codes are returned with 12 digits.
+func Scan(data []byte) []string {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return Decode(img)
+}
+
+// Decode returns the GTINs of the barcodes an image shows, the most read first
+func Decode(img image.Image) []string {
+	b := img.Bounds()
+	if b.Dx() < 67 && b.Dy() < 67 {
+		return nil // narrower than the smallest barcode
+	}
+	reads := map[string]int{}
+	var order []string
+	read := func(line []uint8) {
+		for _, code := range decodeLine(line) {
+			if reads[code] == 0 {
+				order = append(order, code)
+			}
+			reads[code]++
+		}
+	}
+
+	for n := 1; n <= scanLines; n++ {
+		y := b.Min.Y + n*b.Dy()/(scanLines+1)
+		line := make([]uint8, 0, b.Dx())
+		for x := b.Min.X; x < b.Max.X; x++ {
+			line = append(line, luminance(img, x, y))
+		}
+		read(line)
+	}
+	// Barcodes printed sideways
+	for n := 1; n <= scanLines; n++ {
+		x := b.Min.X + n*b.Dx()/(scanLines+1)
+		line := make([]uint8, 0, b.Dy())
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			line = append(line, luminance(img, x, y))
+		}
+		read(line)
+	}
+
+	var codes []string
+	for _, code := range order {
+		if reads[code] >= minReads {
+			codes = append(codes, code)
+		}
+	}
+	sort.SliceStable(codes, func(i, j int) bool { return reads[codes[i]] > reads[codes[j]] })
+	return codes
+}
+
+func luminance(img image.Image, x, y int) uint8 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return uint8((299*r + 587*g + 114*b) / 1000 >> 8)
+}
+
+// decodeLine returns the codes read on a scan line, in both directions
+func decodeLine(line []uint8) []string {
+	lo, hi := uint8(255), uint8(0)
+	for _, v := range line {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	if hi-lo < minContrast {
+		return nil
+	}
+	threshold := (int(lo) + int(hi)) / 2
+
+	// Widths of the alternating dark and light runs
+	var runs []int
+	firstDark := int(line[0]) < threshold
+	dark := firstDark
+	width := 0
+	for _, v := range line {
+		if (int(v) < threshold) == dark {
+			width++
+			continue
+		}
+		runs = append(runs, width)
+		dark, width = !dark, 1
+	}
+	runs = append(runs, width)
+
+	codes := decodeRuns(runs, firstDark)
+	reversed := make([]int, len(runs))
+	for i, w := range runs {
+		reversed[len(runs)-1-i] = w
+	}
+	lastDark := firstDark == (len(runs)%2 == 1)
+	return append(codes, decodeRuns(reversed, lastDark)...)
+}
+
+// decodeRuns looks for EAN-13 and EAN-8 symbols in a sequence of runs, the first dark when
+// firstDark. Symbols need a light quiet zone on both sides.
+func decodeRuns(runs []int, firstDark bool) []string {
+	var codes []string
+	for i := 1; i < len(runs); i++ {
+		if (i%2 == 0) != firstDark {
+			continue // symbols start with a bar
+		}
+		if code, ok := decodeEAN(runs, i, 13); ok {
+			codes = append(codes, code)
+		} else if code, ok := decodeEAN(runs, i, 8); ok {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// decodeEAN decodes the EAN symbol of the given length starting at runs[start]
+func decodeEAN(runs []int, start, length int) (string, bool) {
+	half := (length - 1) / 2 * 4 // runs of the digits of each half
+	if length == 8 {
+		half = 16
+	}
+	count := 3 + half + 5 + half + 3
+	modules := 3 + half/4*7 + 5 + half/4*7 + 3
+	if start+count >= len(runs) {
+		return "", false
+	}
+	total := 0
+	for _, w := range runs[start : start+count] {
+		total += w
+	}
+	module := float64(total) / float64(modules)
+	if module < 1 {
+		return "", false
+	}
+	// Quiet zones and guard bars
+	if float64(runs[start-1]) < 3*module || float64(runs[start+count]) < 3*module {
+		return "", false
+	}
+	for _, g := range [][2]int{{0, 3}, {3 + half, 5}, {3 + half + 5 + half, 3}} {
+		for _, w := range runs[start+g[0] : start+g[0]+g[1]] {
+			if float64(w) > 2*module {
+				return "", false
+			}
+		}
+	}
+
+	digits := make([]int, 0, length)
+	parity := 0
+	for d := 0; d < half/4; d++ {
+		w := runs[start+3+4*d : start+7+4*d]
+		l, lErr := matchDigit(w, &lCodes)
+		g, gErr := matchDigit(w, &gCodes)
+		switch {
+		case lErr <= gErr && lErr <= maxDigitErr:
+			digits = append(digits, l)
+			parity <<= 1
+		case gErr < lErr && gErr <= maxDigitErr && length == 13:
+			digits = append(digits, g)
+			parity = parity<<1 | 1
+		default:
+			return "", false
+		}
+	}
+	for d := 0; d < half/4; d++ {
+		w := runs[start+3+half+5+4*d : start+7+half+5+4*d]
+		r, err := matchDigit(w, &lCodes)
+		if err > maxDigitErr {
+			return "", false
+		}
+		digits = append(digits, r)
+	}
+	if length == 13 {
+		first, ok := firstDigits[parity]
+		if !ok {
+			return "", false
+		}
+		digits = append([]int{first}, digits...)
+	}
+	if !validCheckDigit(digits) {
+		return "", false
+	}
+
+	code := make([]byte, len(digits))
+	for i, d := range digits {
+		code[i] = byte('0' + d)
+	}
+	if length == 13 && code[0] == '0' {
+		return string(code[1:]), true // UPC-A
+	}
+	return string(code), true
+}
+
+// matchDigit returns the digit whose widths are closest to four runs, and how far they are
+// in modules
+func matchDigit(w []int, codes *[10][4]int) (int, float64) {
+	sum := w[0] + w[1] + w[2] + w[3]
+	best, bestErr := 0, 1e9
+	for d, c := range codes {
+		e := 0.0
+		for k := range c {
+			diff := float64(w[k])*7/float64(sum) - float64(c[k])
+			if diff < 0 {
+				diff = -diff
+			}
+			e += diff
+		}
+		if e < bestErr {
+			best, bestErr = d, e
+		}
+	}
+	return best, bestErr
+}
+
+// validCheckDigit checks the GS1 check digit: from the right, the digits before it are
+// weighted 3, 1, 3, 1...
+func validCheckDigit(digits []int) bool {
+	sum := 0
+	for i := len(digits) - 2; i >= 0; i-- {
+		if (len(digits)-2-i)%2 == 0 {
+			sum += 3 * digits[i]
+		} else {
+			sum += digits[i]
+		}
+	}
+	return (10-sum%10)%10 == digits[len(digits)-1]
+}
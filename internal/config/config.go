@@ -1,7 +1,9 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -12,17 +14,95 @@ type Config struct {
 		Port         string        `default:"8080" envconfig:"PORT"`
 		ReadTimeout  time.Duration `default:"30s" envconfig:"READ_TIMEOUT"`
 		WriteTimeout time.Duration `default:"30s" envconfig:"WRITE_TIMEOUT"`
+		// How long shutdown waits for in-flight enrichment before aborting it
+		ShutdownTimeout time.Duration `default:"60s" envconfig:"SHUTDOWN_TIMEOUT"`
+		// Date (YYYY-MM-DD) after which the unversioned /api routes return 410; empty = no sunset yet
+		LegacyAPISunset string `envconfig:"API_LEGACY_SUNSET"`
+		// Serve net/http/pprof under /debug/pprof; PPROF_TOKEN is then required as a bearer
+		// token (without one, only loopback clients are allowed)
+		Pprof      bool   `default:"false" envconfig:"PPROF_ENABLED"`
+		PprofToken string `envconfig:"PPROF_TOKEN"`
+		// Require an API key with the route's scope on every API route (see api_keys)
+		AuthEnabled bool `default:"false" envconfig:"API_AUTH_ENABLED"`
+		// Key granting every scope, to create the first keys; empty = none
+		AdminKey string `envconfig:"API_ADMIN_KEY"`
+		// Key signing the short-lived tokens of image and export URLs; empty = random per
+		// process, so tokens don't survive a restart nor work across instances
+		URLTokenSecret string `envconfig:"URL_TOKEN_SECRET"`
 	}
 
 	Database struct {
-		URL             string `required:"true" envconfig:"DATABASE_URL"`
-		MaxConns        int    `default:"10" envconfig:"DB_MAX_CONNS"`
-		MaxConnIdleTime string `default:"30m" envconfig:"DB_MAX_CONN_IDLE_TIME"`
+		URL             string        `required:"true" envconfig:"DATABASE_URL"`
+		MaxConns        int32         `default:"10" envconfig:"DB_MAX_CONNS"`
+		MinConns        int32         `default:"0" envconfig:"DB_MIN_CONNS"`
+		MaxConnLifetime time.Duration `default:"1h" envconfig:"DB_MAX_CONN_LIFETIME"`
+		MaxConnIdleTime time.Duration `default:"30m" envconfig:"DB_MAX_CONN_IDLE_TIME"`
+		// Server-side limit on each statement (Postgres statement_timeout); 0 = none
+		StatementTimeout time.Duration `default:"30s" envconfig:"DB_STATEMENT_TIMEOUT"`
+		// Deadline given to query methods called without one, pool wait included; 0 = none
+		QueryTimeout time.Duration `default:"60s" envconfig:"DB_QUERY_TIMEOUT"`
+		// Base64 AES-256 key encrypting the provider keys of organizations at rest; empty =
+		// organizations can't set provider keys
+		OrgKeysEncryptionKey string `envconfig:"ORG_KEYS_ENCRYPTION_KEY"`
+	}
+
+	// LLM selects the provider behind every model call (agent, pipeline agents, tools).
+	// Model and FastModel default to the provider's models, see Load.
+	LLM struct {
+		Provider  string `default:"openai" envconfig:"LLM_PROVIDER"` // openai, anthropic, gemini, local
+		Model     string `envconfig:"LLM_MODEL"`                     // main model (tools, pipeline agents)
+		FastModel string `envconfig:"LLM_FAST_MODEL"`                // fast/focused modes, vision, fast pipeline
+		// Models of the same provider tried in order when a call fails after its retries
+		Fallbacks []string      `envconfig:"LLM_FALLBACK_MODELS"`
+		Timeout   time.Duration `default:"2m" envconfig:"LLM_TIMEOUT"` // per model attempt, then the next fallback; 0 = none
+		// Store every call of a session (prompt, answer, latency, model) for debugging,
+		// API keys redacted. Off by default: prompts carry the full product data.
+		CallLog bool `default:"false" envconfig:"LLM_CALL_LOG"`
+		// Generation parameters per stage (fast_mode:0.2,vision:0); the prompts admin API
+		// overrides them at runtime, unlisted stages keep their built-in values
+		StageTemperatures map[string]float32 `envconfig:"LLM_STAGE_TEMPERATURES"`
+		StageMaxTokens    map[string]int     `envconfig:"LLM_STAGE_MAX_TOKENS"`
+		StageSeeds        map[string]int     `envconfig:"LLM_STAGE_SEEDS"`
+		// Outbound call limits shared by every agent, pipeline and tool call of the process,
+		// to stay under the provider's organization rate limits; 0 = unlimited
+		MaxConcurrent     int `default:"16" envconfig:"LLM_MAX_CONCURRENT"`
+		RequestsPerMinute int `default:"0" envconfig:"LLM_REQUESTS_PER_MINUTE"`
+		TokensPerMinute   int `default:"0" envconfig:"LLM_TOKENS_PER_MINUTE"`
+		// Ceiling on the estimated prompt of a single call: longer prompts have their longest
+		// user message cut from the end (system prompts are kept); 0 = no ceiling
+		MaxPromptTokens int `default:"32000" envconfig:"LLM_MAX_PROMPT_TOKENS"`
 	}
 
 	OpenAI struct {
-		APIKey string `required:"true" envconfig:"OPENAI_API_KEY"`
+		APIKey string `envconfig:"OPENAI_API_KEY"`
 		Model  string `default:"gpt-4o" envconfig:"OPENAI_MODEL"`
+		// Alternative API endpoint (OpenAI-compatible gateway, or the fake LLM of cmd/e2e); empty = api.openai.com.
+		// With the azure API type, the resource endpoint (https://<resource>.openai.azure.com).
+		BaseURL string `envconfig:"OPENAI_BASE_URL"`
+		APIType string `default:"openai" envconfig:"OPENAI_API_TYPE"` // openai, azure
+		// Azure only: API version, and model → deployment names (gpt-4o:prod-gpt4o,gpt-4o-mini:prod-mini).
+		// Models without a deployment use their name without dots, Azure's default naming.
+		APIVersion  string            `default:"2024-10-21" envconfig:"OPENAI_API_VERSION"`
+		Deployments map[string]string `envconfig:"OPENAI_DEPLOYMENTS"`
+	}
+
+	Anthropic struct {
+		APIKey    string `envconfig:"ANTHROPIC_API_KEY"`
+		BaseURL   string `envconfig:"ANTHROPIC_BASE_URL"`                  // empty = api.anthropic.com
+		MaxTokens int    `default:"4096" envconfig:"ANTHROPIC_MAX_TOKENS"` // required by the API when a call sets none
+	}
+
+	Gemini struct {
+		APIKey  string `envconfig:"GEMINI_API_KEY"`
+		BaseURL string `envconfig:"GEMINI_BASE_URL"` // empty = generativelanguage.googleapis.com
+	}
+
+	// Local is an OpenAI-compatible server (Ollama, vLLM) for catalogs that must not leave
+	// the network. LLM_MODEL is required; vision is off unless the model reads images.
+	Local struct {
+		BaseURL string `default:"http://localhost:11434/v1" envconfig:"LOCAL_LLM_BASE_URL"`
+		APIKey  string `envconfig:"LOCAL_LLM_API_KEY"`
+		Vision  bool   `default:"false" envconfig:"LOCAL_LLM_VISION"`
 	}
 
 	Storage struct {
@@ -32,17 +112,196 @@ type Config struct {
 	}
 
 	Agent struct {
-		MaxSteps          int           `default:"20" envconfig:"AGENT_MAX_STEPS"`
-		Timeout           time.Duration `default:"5m" envconfig:"AGENT_TIMEOUT"`
-		EnableWebSearch   bool          `default:"true" envconfig:"AGENT_ENABLE_WEB_SEARCH"`
-		EnableVision      bool          `default:"true" envconfig:"AGENT_ENABLE_VISION"`
-		AutoCommitLowRisk bool          `default:"false" envconfig:"AGENT_AUTO_COMMIT_LOW_RISK"`
+		MaxSteps int           `default:"20" envconfig:"AGENT_MAX_STEPS"`
+		Timeout  time.Duration `default:"5m" envconfig:"AGENT_TIMEOUT"`
+		// Guards of a tools session besides MaxSteps: tokens of all its steps and wall-clock
+		// time of a run; the session is then finalized with its proposals so far. 0 = no limit
+		MaxSessionTokens   int           `default:"150000" envconfig:"AGENT_MAX_SESSION_TOKENS"`
+		MaxSessionDuration time.Duration `default:"3m" envconfig:"AGENT_MAX_SESSION_DURATION"`
+		// Self-consistency of the pipeline writer on high-risk fields (material, certifications...):
+		// it writes the field this many times, and the change is kept only when at least the
+		// agreement share of the samples write the same value, else it goes to human review.
+		// 0 or 1 = a single sample
+		SelfConsistencySamples   int     `default:"0" envconfig:"AGENT_SELF_CONSISTENCY_SAMPLES"`
+		SelfConsistencyAgreement float64 `default:"0.67" envconfig:"AGENT_SELF_CONSISTENCY_AGREEMENT"`
+		// Review decisions of the dataset over this window are summed up by field in the
+		// prompts, for fields with at least FeedbackMinReviews decisions; 0 = no feedback
+		FeedbackWindow     time.Duration `default:"720h" envconfig:"AGENT_FEEDBACK_WINDOW"`
+		FeedbackMinReviews int           `default:"10" envconfig:"AGENT_FEEDBACK_MIN_REVIEWS"`
+		// google_product_category is mapped from the Google taxonomy in this file (Google's
+		// taxonomy-with-ids format); empty = the excerpt bundled in the binary. Mappings by
+		// similarity below TaxonomyMinScore are not proposed
+		TaxonomyFile     string  `envconfig:"AGENT_TAXONOMY_FILE"`
+		TaxonomyMinScore float64 `default:"0.3" envconfig:"AGENT_TAXONOMY_MIN_SCORE"`
+		EnableWebSearch  bool    `default:"true" envconfig:"AGENT_ENABLE_WEB_SEARCH"`
+		// The product link is fetched in the critical errors and pricing groups and the price
+		// and availability of its structured data compared with the feed's
+		CheckLandingPages bool `default:"false" envconfig:"AGENT_CHECK_LANDING_PAGES"`
+		// The image analysis group flags an image shown by at least this many products of
+		// other item groups as a likely placeholder; 0 = off
+		PlaceholderMinProducts int           `default:"5" envconfig:"AGENT_PLACEHOLDER_MIN_PRODUCTS"`
+		EnableVision           bool          `default:"true" envconfig:"AGENT_ENABLE_VISION"`
+		AutoCommitLowRisk      bool          `default:"false" envconfig:"AGENT_AUTO_COMMIT_LOW_RISK"`
+		MinConfidence          float64       `default:"0.3" envconfig:"AGENT_MIN_CONFIDENCE"`          // proposals below are dropped
+		AutoVerifyConfidence   float64       `default:"0.85" envconfig:"AGENT_AUTO_VERIFY_CONFIDENCE"` // sources at or above are marked verified
+		RiskTolerance          string        `default:"high" envconfig:"AGENT_RISK_TOLERANCE"`         // highest proposal risk kept: low, medium, high
+		VisionMaxSize          int           `default:"512" envconfig:"AGENT_VISION_MAX_SIZE"`         // images are downscaled to fit this box before vision calls, 0 = send originals
+		VisionDetail           string        `default:"auto" envconfig:"AGENT_VISION_DETAIL"`          // low, high or auto
+		VisionCacheTTL         time.Duration `default:"1h" envconfig:"AGENT_VISION_CACHE_TTL"`         // reuse an image analysis across groups and retries
+		// Reuse the optimization answer of an unchanged product (same data, group, model and
+		// prompts) instead of calling the model again; 0 = off
+		ResponseCacheTTL time.Duration `default:"168h" envconfig:"AGENT_RESPONSE_CACHE_TTL"`
+		// Proposals on these fields are copied to the product's variants (same item_group_id)
+		// that have the same gap, without a model call; empty = no propagation
+		VariantFields []string `default:"color,material,gender" envconfig:"AGENT_VARIANT_FIELDS"`
+		// Product data sent in prompts is slimmed first (empty fields dropped, HTML stripped):
+		// text values are cut above ProductFieldMaxChars, and the least useful fields dropped
+		// until the data fits ProductMaxTokens (estimated); 0 = no limit
+		ProductFieldMaxChars int `default:"2000" envconfig:"AGENT_PRODUCT_FIELD_MAX_CHARS"`
+		ProductMaxTokens     int `default:"3000" envconfig:"AGENT_PRODUCT_MAX_TOKENS"`
+		// Model routing: optimization group → model (required_attributes:gpt-4o-mini,description_optimization:gpt-4o,
+		// "all" for the fast mode and fast pipeline). Unlisted groups use LLM_FAST_MODEL.
+		GroupModels map[string]string `envconfig:"AGENT_GROUP_MODELS"`
+		VisionModel string            `envconfig:"AGENT_VISION_MODEL"` // image analysis; empty = LLM_FAST_MODEL
+	}
+
+	Shadow struct {
+		Enabled       bool    `default:"false" envconfig:"SHADOW_ENABLED"`
+		Model         string  `envconfig:"SHADOW_MODEL"`        // candidate model, e.g. gpt-4.1-mini
+		Instructions  string  `envconfig:"SHADOW_INSTRUCTIONS"` // extra system prompt instructions for the candidate
+		Variant       string  `envconfig:"SHADOW_VARIANT"`      // label for results, defaults to the model name
+		SampleRate    float64 `default:"0.05" envconfig:"SHADOW_SAMPLE_RATE"`
+		MaxConcurrent int     `default:"2" envconfig:"SHADOW_MAX_CONCURRENT"`
 	}
 
+	Retry struct {
+		MaxAttempts int           `default:"4" envconfig:"RETRY_MAX_ATTEMPTS"` // total attempts per outbound call (OpenAI, search, fetch)
+		BaseDelay   time.Duration `default:"500ms" envconfig:"RETRY_BASE_DELAY"`
+		MaxDelay    time.Duration `default:"30s" envconfig:"RETRY_MAX_DELAY"` // also the longest Retry-After honoured
+	}
+
+	Quality struct {
+		JudgeEnabled bool   `default:"false" envconfig:"QUALITY_LLM_JUDGE"` // blend an LLM judge into proposal quality scores
+		JudgeModel   string `envconfig:"QUALITY_JUDGE_MODEL"`               // empty = LLM_FAST_MODEL
+	}
+
+	Review struct {
+		// Sampling review: sample size for this confidence level and margin of error, and the
+		// sample acceptance rate needed to auto-approve the rest of a module+field stratum
+		SampleConfidence float64 `default:"0.95" envconfig:"REVIEW_SAMPLE_CONFIDENCE"`
+		SampleMargin     float64 `default:"0.05" envconfig:"REVIEW_SAMPLE_MARGIN"`
+		SampleMinSize    int     `default:"30" envconfig:"REVIEW_SAMPLE_MIN_SIZE"`
+		AutoApproveRate  float64 `default:"0.95" envconfig:"REVIEW_SAMPLE_THRESHOLD"`
+	}
+
+	// Budget caps the LLM spend of the whole deployment in USD, 0 = unlimited. Datasets can
+	// have their own caps (PUT /datasets/:id/budget); runs stop at whichever is reached first.
+	Budget struct {
+		DailyUSD   float64 `default:"0" envconfig:"BUDGET_DAILY_USD"`
+		MonthlyUSD float64 `default:"0" envconfig:"BUDGET_MONTHLY_USD"`
+	}
+
+	Jobs struct {
+		Workers     int `default:"2" envconfig:"JOB_WORKERS"`      // products processed concurrently across all jobs
+		MaxAttempts int `default:"3" envconfig:"JOB_MAX_ATTEMPTS"` // attempts per product before it is dead-lettered
+		// How often submitted batch enrichments are checked for results
+		BatchPollInterval time.Duration `default:"5m" envconfig:"JOB_BATCH_POLL_INTERVAL"`
+	}
+
+	Schedule struct {
+		Enabled    bool          `default:"false" envconfig:"SCHEDULE_ENABLED"` // periodically re-enrich stale products
+		Interval   time.Duration `default:"6h" envconfig:"SCHEDULE_INTERVAL"`
+		MaxAgeDays int           `default:"30" envconfig:"SCHEDULE_MAX_AGE_DAYS"` // re-run products whose last run is older than this
+		Groups     []string      `default:"all" envconfig:"SCHEDULE_GROUPS"`      // optimization groups to keep fresh
+		Priority   int           `default:"3" envconfig:"SCHEDULE_PRIORITY"`      // below interactive jobs by default
+		BatchSize  int           `default:"500" envconfig:"SCHEDULE_BATCH_SIZE"`  // max products per scheduled job
+	}
+
+	ImageProxy struct {
+		CacheTTL     time.Duration `default:"168h" envconfig:"IMAGE_PROXY_CACHE_TTL"`
+		MaxBytes     int64         `default:"15728640" envconfig:"IMAGE_PROXY_MAX_BYTES"` // largest original fetched (15MB)
+		MaxDimension int           `default:"2048" envconfig:"IMAGE_PROXY_MAX_DIMENSION"`
+		AllowPrivate bool          `default:"false" envconfig:"IMAGE_PROXY_ALLOW_PRIVATE"` // allow fetching from private/loopback hosts
+	}
+
+	// ImageCheck requests the image_link of every product of a dataset the way Google's
+	// crawler does, to catch broken images before vision calls are spent on them
+	ImageCheck struct {
+		Concurrency int           `default:"8" envconfig:"IMAGE_CHECK_CONCURRENCY"`
+		Rate        float64       `default:"10" envconfig:"IMAGE_CHECK_RATE"` // requests per second, all hosts together
+		Timeout     time.Duration `default:"15s" envconfig:"IMAGE_CHECK_TIMEOUT"`
+		// Vision skips images whose last check found them broken
+		SkipBroken bool `default:"true" envconfig:"IMAGE_CHECK_SKIP_BROKEN"`
+	}
+
+	// WebSearch selects the search API of the agent's web search, the web_search tool and
+	// the retrieval agent. Searches are off without the key of the provider.
 	WebSearch struct {
-		Provider string `default:"brave" envconfig:"WEBSEARCH_PROVIDER"` // brave
-		APIKey   string `envconfig:"BRAVE_API_KEY"`
+		Provider     string `default:"brave" envconfig:"WEBSEARCH_PROVIDER"` // brave, serpapi, bing, google
+		BraveAPIKey  string `envconfig:"BRAVE_API_KEY"`
+		SerpAPIKey   string `envconfig:"SERPAPI_API_KEY"`
+		BingAPIKey   string `envconfig:"BING_SEARCH_API_KEY"`
+		BingEndpoint string `default:"https://api.bing.microsoft.com/v7.0/search" envconfig:"BING_SEARCH_ENDPOINT"`
+		GoogleAPIKey string `envconfig:"GOOGLE_SEARCH_API_KEY"` // Google Custom Search JSON API
+		GoogleCX     string `envconfig:"GOOGLE_SEARCH_CX"`      // Programmable Search Engine ID
+		APIKey       string `ignored:"true"`                    // key of the provider, see Load
+		// Search results (per query) and fetched pages (per URL) are reused for this long; 0 = no cache
+		CacheTTL time.Duration `default:"24h" envconfig:"WEBSEARCH_CACHE_TTL"`
+		// Page fetches identify as UserAgent, follow the robots.txt of each site and send at most
+		// HostConcurrency requests at a time to a host, HostDelay apart (or the site's Crawl-delay)
+		UserAgent       string        `default:"Mozilla/5.0 (compatible; FeedEnrichBot/1.0)" envconfig:"WEBSEARCH_USER_AGENT"`
+		RespectRobots   bool          `default:"true" envconfig:"WEBSEARCH_RESPECT_ROBOTS"`
+		HostConcurrency int           `default:"1" envconfig:"WEBSEARCH_HOST_CONCURRENCY"`
+		HostDelay       time.Duration `default:"1s" envconfig:"WEBSEARCH_HOST_DELAY"`
+		// Pages of RenderDomains (comma-separated, subdomains included) are rendered by a
+		// headless browser service, for shops whose pages are empty without JavaScript
+		RenderProvider string        `envconfig:"WEBSEARCH_RENDER_PROVIDER"` // browserless, splash; empty = off
+		RenderEndpoint string        `envconfig:"WEBSEARCH_RENDER_ENDPOINT"` // https://chrome.browserless.io, http://splash:8050
+		RenderToken    string        `envconfig:"WEBSEARCH_RENDER_TOKEN"`    // browserless token
+		RenderDomains  string        `envconfig:"WEBSEARCH_RENDER_DOMAINS"`
+		RenderTimeout  time.Duration `default:"30s" envconfig:"WEBSEARCH_RENDER_TIMEOUT"`
+		// Searches are refused up front, instead of failing each product of a batch, while the
+		// provider's quota is used up (Brave reports it; MonthlyQuota is counted here for the
+		// others) or for BreakerCooldown after BreakerThreshold failures in a row
+		MonthlyQuota     int           `envconfig:"WEBSEARCH_MONTHLY_QUOTA"` // 0 = unknown
+		BreakerThreshold int           `default:"5" envconfig:"WEBSEARCH_BREAKER_THRESHOLD"`
+		BreakerCooldown  time.Duration `default:"5m" envconfig:"WEBSEARCH_BREAKER_COOLDOWN"`
+	}
+
+	// GTINRegistry looks GTINs up in product registries, in the order of GTIN_REGISTRIES,
+	// before searching the web: their data comes from the brand owner or a product database,
+	// not a page ranking. Empty = no lookups.
+	GTINRegistry struct {
+		Registries   string        `envconfig:"GTIN_REGISTRIES"` // comma-separated: gs1, openfoodfacts, upcitemdb
+		GS1APIKey    string        `envconfig:"GS1_API_KEY"`     // Verified by GS1
+		GS1Endpoint  string        `default:"https://grp.gs1.org/grp/v3.1/gtins/verified" envconfig:"GS1_ENDPOINT"`
+		UPCitemdbKey string        `envconfig:"UPCITEMDB_API_KEY"`                      // empty = the free trial endpoint (100 lookups a day)
+		CacheTTL     time.Duration `default:"168h" envconfig:"GTIN_REGISTRY_CACHE_TTL"` // lookups, found or not, are reused for this long
 	}
+
+	Retention struct {
+		Enabled     bool          `default:"true" envconfig:"RETENTION_ENABLED"`
+		AuditMonths int           `default:"6" envconfig:"RETENTION_AUDIT_MONTHS"` // months of change_log/agent_traces kept online
+		Archive     bool          `default:"true" envconfig:"RETENTION_ARCHIVE"`   // export partitions to storage before purge
+		Interval    time.Duration `default:"24h" envconfig:"RETENTION_INTERVAL"`
+		// Per-table windows of the audit partitions; 0 = AuditMonths
+		TracesMonths    int `default:"0" envconfig:"RETENTION_TRACES_MONTHS"`
+		ChangeLogMonths int `default:"0" envconfig:"RETENTION_CHANGE_LOG_MONTHS"`
+		// Months of daily token usage and its attribution per session (the current month always
+		// stays for budgets); 0 = kept
+		TokenUsageMonths int `default:"13" envconfig:"RETENTION_TOKEN_USAGE_MONTHS"`
+		// Snapshots older than this are deleted, except the latest SnapshotsKept of each dataset; 0 = kept
+		SnapshotDays  int `default:"90" envconfig:"RETENTION_SNAPSHOT_DAYS"`
+		SnapshotsKept int `default:"3" envconfig:"RETENTION_SNAPSHOTS_KEPT"`
+	}
+}
+
+// providerModels are the default main and fast models of each LLM provider
+var providerModels = map[string][2]string{
+	"openai":    {"", "gpt-4o-mini"}, // main model: OPENAI_MODEL
+	"anthropic": {"claude-sonnet-4-5", "claude-haiku-4-5"},
+	"gemini":    {"gemini-2.5-pro", "gemini-2.5-flash"},
+	"local":     {"", ""}, // whatever the server runs: LLM_MODEL, also the fast model
 }
 
 func Load() (*Config, error) {
@@ -50,5 +309,107 @@ func Load() (*Config, error) {
 	if err := envconfig.Process("", &cfg); err != nil {
 		return nil, fmt.Errorf("config load: %w", err)
 	}
+
+	models, ok := providerModels[cfg.LLM.Provider]
+	if !ok {
+		return nil, fmt.Errorf("config load: unknown LLM_PROVIDER %q (openai, anthropic, gemini, local)", cfg.LLM.Provider)
+	}
+	var key string
+	switch cfg.LLM.Provider {
+	case "openai":
+		key, models[0] = cfg.OpenAI.APIKey, cfg.OpenAI.Model
+		switch cfg.OpenAI.APIType {
+		case "openai":
+		case "azure":
+			if cfg.OpenAI.BaseURL == "" {
+				return nil, fmt.Errorf("config load: OPENAI_API_TYPE=azure requires OPENAI_BASE_URL")
+			}
+		default:
+			return nil, fmt.Errorf("config load: unknown OPENAI_API_TYPE %q (openai, azure)", cfg.OpenAI.APIType)
+		}
+	case "anthropic":
+		key = cfg.Anthropic.APIKey
+	case "gemini":
+		key = cfg.Gemini.APIKey
+	case "local":
+		if cfg.LLM.Model == "" {
+			return nil, fmt.Errorf("config load: LLM_PROVIDER=local requires LLM_MODEL")
+		}
+		models[1] = cfg.LLM.Model
+		if !cfg.Local.Vision {
+			// Image analysis would only fail; skip it everywhere instead
+			cfg.Agent.EnableVision = false
+		}
+	}
+	if key == "" && cfg.LLM.Provider != "local" { // local servers rarely check keys
+		return nil, fmt.Errorf("config load: no API key for LLM_PROVIDER %s", cfg.LLM.Provider)
+	}
+	switch cfg.WebSearch.Provider {
+	case "brave":
+		cfg.WebSearch.APIKey = cfg.WebSearch.BraveAPIKey
+	case "serpapi":
+		cfg.WebSearch.APIKey = cfg.WebSearch.SerpAPIKey
+	case "bing":
+		cfg.WebSearch.APIKey = cfg.WebSearch.BingAPIKey
+	case "google":
+		cfg.WebSearch.APIKey = cfg.WebSearch.GoogleAPIKey
+		if cfg.WebSearch.APIKey != "" && cfg.WebSearch.GoogleCX == "" {
+			return nil, fmt.Errorf("config load: WEBSEARCH_PROVIDER=google requires GOOGLE_SEARCH_CX")
+		}
+	default:
+		return nil, fmt.Errorf("config load: unknown WEBSEARCH_PROVIDER %q (brave, serpapi, bing, google)", cfg.WebSearch.Provider)
+	}
+	for _, registry := range strings.Split(cfg.GTINRegistry.Registries, ",") {
+		switch strings.TrimSpace(registry) {
+		case "", "openfoodfacts", "upcitemdb":
+		case "gs1":
+			if cfg.GTINRegistry.GS1APIKey == "" {
+				return nil, fmt.Errorf("config load: GTIN_REGISTRIES gs1 requires GS1_API_KEY")
+			}
+		default:
+			return nil, fmt.Errorf("config load: unknown GTIN registry %q (gs1, openfoodfacts, upcitemdb)", registry)
+		}
+	}
+	switch cfg.WebSearch.RenderProvider {
+	case "":
+	case "browserless", "splash":
+		if cfg.WebSearch.RenderEndpoint == "" {
+			return nil, fmt.Errorf("config load: WEBSEARCH_RENDER_PROVIDER %s requires WEBSEARCH_RENDER_ENDPOINT", cfg.WebSearch.RenderProvider)
+		}
+	default:
+		return nil, fmt.Errorf("config load: unknown WEBSEARCH_RENDER_PROVIDER %q (browserless, splash)", cfg.WebSearch.RenderProvider)
+	}
+	if k := cfg.Database.OrgKeysEncryptionKey; k != "" {
+		if key, err := base64.StdEncoding.DecodeString(k); err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("config load: ORG_KEYS_ENCRYPTION_KEY must be 32 bytes in base64")
+		}
+	}
+	if cfg.WebSearch.HostConcurrency < 1 {
+		return nil, fmt.Errorf("config load: WEBSEARCH_HOST_CONCURRENCY must be at least 1")
+	}
+	if cfg.WebSearch.BreakerThreshold < 1 {
+		return nil, fmt.Errorf("config load: WEBSEARCH_BREAKER_THRESHOLD must be at least 1")
+	}
+	if cfg.LLM.Model == "" {
+		cfg.LLM.Model = models[0]
+	}
+	if cfg.LLM.FastModel == "" {
+		cfg.LLM.FastModel = models[1]
+	}
+	if cfg.Quality.JudgeModel == "" {
+		cfg.Quality.JudgeModel = cfg.LLM.FastModel
+	}
+	if cfg.Agent.VisionModel == "" {
+		cfg.Agent.VisionModel = cfg.LLM.FastModel
+	}
 	return &cfg, nil
 }
+
+// GroupModel returns the model routed to an optimization group (AGENT_GROUP_MODELS),
+// the fast model when the group has no route
+func (c *Config) GroupModel(group string) string {
+	if m := c.Agent.GroupModels[group]; m != "" {
+		return m
+	}
+	return c.LLM.FastModel
+}
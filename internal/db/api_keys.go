@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== API KEY OPERATIONS =====
+
+// apiKeyPrefix starts every generated key, so leaked keys are easy to search for
+const apiKeyPrefix = "fe_"
+
+// apiKeyTouchInterval limits last_used_at writes to one per key and interval
+const apiKeyTouchInterval = time.Minute
+
+const apiKeyColumns = `id, org_id, user_id, name, prefix, scopes, created_at, expires_at, last_used_at, revoked_at`
+
+// HashAPIKey is the digest stored for a key. Keys are 256 random bits, so an unsalted
+// SHA-256 is enough and keeps the lookup a single index scan.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey generates a key of an organization (optionally issued to one of its users)
+// with the given scopes and returns it with its record; the key is not stored and can't be
+// shown again
+func (q *Queries) CreateAPIKey(ctx context.Context, orgID uuid.UUID, userID *uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	k := &models.APIKey{
+		OrgID:     orgID,
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:len(apiKeyPrefix)+8],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	err := q.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (org_id, user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, k.OrgID, k.UserID, k.Name, k.Prefix, HashAPIKey(key), k.Scopes, k.ExpiresAt).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return k, key, nil
+}
+
+// GetActiveAPIKey returns the key matching a secret, or pgx.ErrNoRows when there is none or
+// it was revoked or has expired. Its last_used_at is updated at most once a minute.
+func (q *Queries) GetActiveAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+	var k models.APIKey
+	err := q.pool.QueryRow(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`, HashAPIKey(key)).Scan(&k.ID, &k.OrgID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if k.LastUsedAt == nil || time.Since(*k.LastUsedAt) > apiKeyTouchInterval {
+		// Usage tracking only: a failed write doesn't reject the request
+		q.pool.Exec(ctx, `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, k.ID)
+	}
+	return &k, nil
+}
+
+// ListAPIKeys returns the keys of an organization, revoked ones included, newest first
+func (q *Queries) ListAPIKeys(ctx context.Context, orgID uuid.UUID) ([]models.APIKey, error) {
+	rows, err := q.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE org_id = $1 ORDER BY created_at DESC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var k models.APIKey
+		if err := rows.Scan(&k.ID, &k.OrgID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey disables a key for good; the record is kept for the list. Returns
+// pgx.ErrNoRows when the key doesn't exist or was already revoked.
+func (q *Queries) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	tag, err := q.pool.Exec(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
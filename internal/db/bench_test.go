@@ -0,0 +1,190 @@
+//go:build integration
+
+package db_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/testdb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// benchRows is the size of the benchmark datasets, the 100k products and proposals of the
+// targets of docs/09-test-plan.md
+const benchRows = 100000
+
+// benchPool and benchQueries run on the Postgres container started by TestMain
+var (
+	benchPool    *pgxpool.Pool
+	benchQueries *db.Queries
+)
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+	url, stop, err := testdb.Start(ctx)
+	if err != nil {
+		log.Fatalf("start postgres: %v", err)
+	}
+	benchPool, err = pgxpool.New(ctx, url)
+	if err != nil {
+		stop()
+		log.Fatalf("connect: %v", err)
+	}
+	benchQueries = db.New(benchPool, &config.Config{})
+
+	code := m.Run()
+	benchPool.Close()
+	stop()
+	os.Exit(code)
+}
+
+// benchProducts builds n products of a dataset
+func benchProducts(datasetID uuid.UUID, n int) []models.Product {
+	products := make([]models.Product, n)
+	now := time.Now()
+	for i := range products {
+		data, _ := json.Marshal(map[string]string{
+			"id":          fmt.Sprintf("BENCH-%07d", i),
+			"title":       fmt.Sprintf("Product %d running shoe", i),
+			"description": "Comfortable everyday product made from durable materials.",
+			"price":       fmt.Sprintf("%d.99 EUR", 10+i%200),
+			"brand":       "Acme",
+		})
+		products[i] = models.Product{
+			ID:          uuid.New(),
+			DatasetID:   datasetID,
+			ExternalID:  fmt.Sprintf("BENCH-%07d", i),
+			RawData:     data,
+			CurrentData: data,
+			Version:     1,
+			Status:      "pending",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+	}
+	return products
+}
+
+// benchDataset creates a dataset of the default organization with n products and one
+// pending title proposal each
+func benchDataset(b testing.TB, n int) (orgID, datasetID uuid.UUID) {
+	ctx := context.Background()
+	org, err := benchQueries.GetOrganization(ctx, db.DefaultOrganization)
+	if err != nil {
+		b.Fatalf("default organization: %v", err)
+	}
+	dataset := models.Dataset{ID: uuid.New(), OrgID: org.ID, Name: "bench", Status: "uploaded", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	products := benchProducts(dataset.ID, n)
+	if err := benchQueries.CreateDatasetWithProducts(ctx, &dataset, products); err != nil {
+		b.Fatalf("create dataset: %v", err)
+	}
+	b.Cleanup(func() { benchQueries.DeleteDataset(context.Background(), dataset.ID) })
+
+	now := time.Now()
+	if _, err := benchPool.CopyFrom(ctx, pgx.Identifier{"proposals"},
+		[]string{"id", "product_id", "field", "before_value", "after_value", "confidence", "risk_level", "status", "module", "created_at"},
+		pgx.CopyFromSlice(len(products), func(i int) ([]any, error) {
+			title := fmt.Sprintf("Product %d running shoe", i)
+			return []any{uuid.New(), products[i].ID, "title", title, "Acme " + title + " - Lightweight",
+				0.8, "low", "proposed", "title_optimization", now}, nil
+		}),
+	); err != nil {
+		b.Fatalf("seed proposals: %v", err)
+	}
+	return org.ID, dataset.ID
+}
+
+func BenchmarkCreateProducts(b *testing.B) {
+	ctx := context.Background()
+	org, err := benchQueries.GetOrganization(ctx, db.DefaultOrganization)
+	if err != nil {
+		b.Fatalf("default organization: %v", err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		dataset := models.Dataset{ID: uuid.New(), OrgID: org.ID, Name: "bench", Status: "uploaded", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := benchQueries.CreateDataset(ctx, dataset); err != nil {
+			b.Fatal(err)
+		}
+		products := benchProducts(dataset.ID, benchRows)
+		b.StartTimer()
+
+		copied, err := benchQueries.CreateProducts(ctx, products)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		if copied != benchRows {
+			b.Fatalf("copied %d products, want %d", copied, benchRows)
+		}
+		if err := benchQueries.DeleteDataset(ctx, dataset.ID); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+}
+
+func BenchmarkListProposalsWithProducts(b *testing.B) {
+	orgID, _ := benchDataset(b, benchRows)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Every page, as the review UI reads them
+		filter := db.ProposalListFilter{OrgID: orgID, Limit: 1000}
+		listed := 0
+		for {
+			proposals, next, err := benchQueries.ListProposalsWithProducts(ctx, filter)
+			if err != nil {
+				b.Fatal(err)
+			}
+			// The handler's response encoding is part of the listing target
+			if _, err := json.Marshal(map[string]any{"data": proposals}); err != nil {
+				b.Fatal(err)
+			}
+			listed += len(proposals)
+			if next == nil {
+				break
+			}
+			filter.After = next
+		}
+		if listed != benchRows {
+			b.Fatalf("%d proposals, want %d", listed, benchRows)
+		}
+	}
+}
+
+func BenchmarkExportJSON(b *testing.B) {
+	_, datasetID := benchDataset(b, benchRows)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		products, err := benchQueries.ListProductsInScope(ctx, datasetID, nil, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := json.Marshal(products); err != nil {
+			b.Fatal(err)
+		}
+		if len(products) != benchRows {
+			b.Fatalf("exported %d products, want %d", len(products), benchRows)
+		}
+	}
+}
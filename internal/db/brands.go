@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== BRAND OPERATIONS =====
+
+// GetDatasetBrands counts the products of a dataset by brand, as spelled in the feed
+func (q *Queries) GetDatasetBrands(ctx context.Context, datasetID uuid.UUID) (map[string]int, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT TRIM(raw_data->>'brand'), COUNT(*)
+		FROM products
+		WHERE dataset_id = $1 AND COALESCE(TRIM(raw_data->>'brand'), '') <> ''
+		GROUP BY 1
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	brands := map[string]int{}
+	for rows.Next() {
+		var brand string
+		var n int
+		if err := rows.Scan(&brand, &n); err != nil {
+			return nil, err
+		}
+		brands[brand] = n
+	}
+	return brands, rows.Err()
+}
+
+// ListBrandAliases returns the brand aliases of a dataset, by alias
+func (q *Queries) ListBrandAliases(ctx context.Context, datasetID uuid.UUID) ([]models.BrandAlias, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, dataset_id, alias, brand, created_at
+		FROM brand_aliases
+		WHERE dataset_id = $1
+		ORDER BY LOWER(alias)
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []models.BrandAlias{}
+	for rows.Next() {
+		var a models.BrandAlias
+		if err := rows.Scan(&a.ID, &a.DatasetID, &a.Alias, &a.Brand, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// UpsertBrandAlias sets the brand of an alias, replacing the brand it had
+func (q *Queries) UpsertBrandAlias(ctx context.Context, a *models.BrandAlias) error {
+	return q.pool.QueryRow(ctx, `
+		INSERT INTO brand_aliases (dataset_id, alias, brand)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (dataset_id, LOWER(alias)) DO UPDATE SET brand = EXCLUDED.brand
+		RETURNING id, created_at
+	`, a.DatasetID, a.Alias, a.Brand).Scan(&a.ID, &a.CreatedAt)
+}
+
+// DeleteBrandAlias removes an alias and returns its dataset; pgx.ErrNoRows when there is none
+func (q *Queries) DeleteBrandAlias(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	var datasetID uuid.UUID
+	err := q.pool.QueryRow(ctx, `DELETE FROM brand_aliases WHERE id = $1 RETURNING dataset_id`, id).Scan(&datasetID)
+	return datasetID, err
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== BUDGET OPERATIONS =====
+
+// GetDatasetBudget returns the dataset's spend caps (empty when none are set)
+func (q *Queries) GetDatasetBudget(ctx context.Context, datasetID uuid.UUID) (*models.Budget, error) {
+	var raw []byte
+	err := q.pool.QueryRow(ctx, `SELECT budget FROM datasets WHERE id = $1`, datasetID).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	var b models.Budget
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, err
+		}
+	}
+	return &b, nil
+}
+
+// UpdateDatasetBudget replaces the dataset's spend caps
+func (q *Queries) UpdateDatasetBudget(ctx context.Context, datasetID uuid.UUID, b models.Budget) error {
+	raw, _ := json.Marshal(b)
+	_, err := q.pool.Exec(ctx, `UPDATE datasets SET budget = $2, updated_at = NOW() WHERE id = $1`, datasetID, raw)
+	return err
+}
+
+// GetBudgetSpend returns what was spent today and this month in total, by the organization
+// of the dataset and on the dataset
+func (q *Queries) GetBudgetSpend(ctx context.Context, datasetID uuid.UUID) (total, org, dataset models.BudgetSpend, err error) {
+	err = q.pool.QueryRow(ctx, `
+		SELECT
+			COALESCE((SELECT SUM(cost_usd) FROM token_usage WHERE date = CURRENT_DATE), 0),
+			COALESCE((SELECT SUM(cost_usd) FROM token_usage WHERE date >= date_trunc('month', CURRENT_DATE)), 0),
+			COALESCE(SUM(a.cost_usd) FILTER (WHERE a.date = CURRENT_DATE), 0),
+			COALESCE(SUM(a.cost_usd), 0),
+			COALESCE(SUM(a.cost_usd) FILTER (WHERE a.date = CURRENT_DATE AND a.dataset_id = $1), 0),
+			COALESCE(SUM(a.cost_usd) FILTER (WHERE a.dataset_id = $1), 0)
+		FROM token_usage_attribution a
+		WHERE a.date >= date_trunc('month', CURRENT_DATE)
+		AND a.dataset_id IN (SELECT id FROM datasets WHERE org_id = (SELECT org_id FROM datasets WHERE id = $1))
+	`, datasetID).Scan(&total.TodayUSD, &total.MonthUSD, &org.TodayUSD, &org.MonthUSD, &dataset.TodayUSD, &dataset.MonthUSD)
+	return total, org, dataset, err
+}
@@ -2,29 +2,62 @@ package db
 
 import (
 	"context"
+	"crypto/cipher"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/config"
 	"github.com/benjamincozon/feedenrich/internal/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Queries wraps database operations
 type Queries struct {
-	pool *pgxpool.Pool
+	pool *timeoutPool
+	// keys encrypts the provider keys of organizations, nil without ORG_KEYS_ENCRYPTION_KEY
+	keys cipher.AEAD
 }
 
-// New creates a new Queries instance
-func New(pool *pgxpool.Pool) *Queries {
-	return &Queries{pool: pool}
+// execer runs statements on the pool or inside a transaction, so writes can be shared
+// between standalone calls and transactional ones
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
 }
 
-// Connect establishes a database connection pool
-func Connect(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
-	pool, err := pgxpool.New(ctx, databaseURL)
+// New creates a new Queries instance. Queries called without a deadline get
+// DB_QUERY_TIMEOUT.
+func New(pool *pgxpool.Pool, cfg *config.Config) *Queries {
+	return &Queries{
+		pool: &timeoutPool{Pool: pool, timeout: cfg.Database.QueryTimeout},
+		keys: keyCipher(cfg.Database.OrgKeysEncryptionKey),
+	}
+}
+
+// Connect establishes a database connection pool sized and limited by the DB_* settings
+func Connect(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.Database.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse database URL: %w", err)
+	}
+	poolConfig.MaxConns = cfg.Database.MaxConns
+	poolConfig.MinConns = cfg.Database.MinConns
+	poolConfig.MaxConnLifetime = cfg.Database.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.Database.MaxConnIdleTime
+	if t := cfg.Database.StatementTimeout; t > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = fmt.Sprint(t.Milliseconds())
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("connect to database: %w", err)
 	}
@@ -40,29 +73,30 @@ func Connect(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
 
 func (q *Queries) CreateDataset(ctx context.Context, d models.Dataset) error {
 	_, err := q.pool.Exec(ctx, `
-		INSERT INTO datasets (id, name, source_file_url, row_count, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, d.ID, d.Name, d.SourceFileURL, d.RowCount, d.Status, d.CreatedAt, d.UpdatedAt)
+		INSERT INTO datasets (id, org_id, name, source_file_url, row_count, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, d.ID, d.OrgID, d.Name, d.SourceFileURL, d.RowCount, d.Status, d.CreatedAt, d.UpdatedAt)
 	return err
 }
 
 func (q *Queries) GetDataset(ctx context.Context, id uuid.UUID) (*models.Dataset, error) {
 	var d models.Dataset
 	err := q.pool.QueryRow(ctx, `
-		SELECT id, name, source_file_url, row_count, status, created_at, updated_at
+		SELECT id, org_id, name, source_file_url, row_count, status, created_at, updated_at
 		FROM datasets WHERE id = $1
-	`, id).Scan(&d.ID, &d.Name, &d.SourceFileURL, &d.RowCount, &d.Status, &d.CreatedAt, &d.UpdatedAt)
+	`, id).Scan(&d.ID, &d.OrgID, &d.Name, &d.SourceFileURL, &d.RowCount, &d.Status, &d.CreatedAt, &d.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &d, nil
 }
 
-func (q *Queries) ListDatasets(ctx context.Context) ([]models.Dataset, error) {
+// ListDatasets returns the datasets of an organization, or of all of them when orgID is nil
+func (q *Queries) ListDatasets(ctx context.Context, orgID *uuid.UUID) ([]models.Dataset, error) {
 	rows, err := q.pool.Query(ctx, `
-		SELECT id, name, source_file_url, row_count, status, created_at, updated_at
-		FROM datasets ORDER BY created_at DESC
-	`)
+		SELECT id, org_id, name, source_file_url, row_count, status, created_at, updated_at
+		FROM datasets WHERE ($1::uuid IS NULL OR org_id = $1) ORDER BY created_at DESC
+	`, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +105,7 @@ func (q *Queries) ListDatasets(ctx context.Context) ([]models.Dataset, error) {
 	var datasets []models.Dataset
 	for rows.Next() {
 		var d models.Dataset
-		if err := rows.Scan(&d.ID, &d.Name, &d.SourceFileURL, &d.RowCount, &d.Status, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		if err := rows.Scan(&d.ID, &d.OrgID, &d.Name, &d.SourceFileURL, &d.RowCount, &d.Status, &d.CreatedAt, &d.UpdatedAt); err != nil {
 			return nil, err
 		}
 		datasets = append(datasets, d)
@@ -79,15 +113,38 @@ func (q *Queries) ListDatasets(ctx context.Context) ([]models.Dataset, error) {
 	return datasets, nil
 }
 
+// DeleteDataset deletes a dataset with its snapshots and the snapshot data they alone
+// referenced; the rest of its records cascade
 func (q *Queries) DeleteDataset(ctx context.Context, id uuid.UUID) error {
-	_, err := q.pool.Exec(ctx, `DELETE FROM datasets WHERE id = $1`, id)
-	return err
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT id FROM dataset_snapshots WHERE dataset_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	snapshots, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return err
+	}
+	if err := deleteSnapshotsTx(ctx, tx, snapshots); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM datasets WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
+// GetDatasetStats returns the product and proposal counts of a dataset and its score
+// aggregates as of the last RefreshDatasetStats
 func (q *Queries) GetDatasetStats(ctx context.Context, id uuid.UUID) (map[string]any, error) {
 	var total, enriched, pending int
-	var avgScoreBefore, avgScoreAfter float64
-	
+	var readiness float64
+
 	err := q.pool.QueryRow(ctx, `
 		SELECT 
 			COUNT(*),
@@ -95,41 +152,46 @@ func (q *Queries) GetDatasetStats(ctx context.Context, id uuid.UUID) (map[string
 			COUNT(*) FILTER (WHERE status = 'pending'),
 			COALESCE(AVG(agent_readiness_score) FILTER (WHERE agent_readiness_score IS NOT NULL), 0)
 		FROM products WHERE dataset_id = $1
-	`, id).Scan(&total, &enriched, &pending, &avgScoreAfter)
+	`, id).Scan(&total, &enriched, &pending, &readiness)
 	if err != nil {
 		return nil, err
 	}
 
-	// Calculate "before" score - base quality without enrichment (estimate ~0.35 for unprocessed)
-	// After enrichment, the score reflects the improved quality
-	if enriched > 0 {
-		avgScoreBefore = 0.35 // Estimated base quality
-	} else {
-		avgScoreBefore = 0.35
-		avgScoreAfter = 0.35
+	scores, err := q.GetDatasetScoreStats(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
 	// Count proposals
 	var proposalsTotal, proposalsAccepted, proposalsPending int
-	q.pool.QueryRow(ctx, `
+	err = q.pool.QueryRow(ctx, `
 		SELECT 
 			COUNT(*),
-			COUNT(*) FILTER (WHERE status = 'accepted'),
+			COUNT(*) FILTER (WHERE status IN ('accepted', 'edited')),
 			COUNT(*) FILTER (WHERE status = 'proposed')
 		FROM proposals p
 		JOIN products pr ON p.product_id = pr.id
-		WHERE pr.dataset_id = $1
+		WHERE pr.dataset_id = $1 AND p.status <> 'simulated'
 	`, id).Scan(&proposalsTotal, &proposalsAccepted, &proposalsPending)
+	if err != nil {
+		return nil, err
+	}
 
 	return map[string]any{
 		"products": map[string]int{
 			"total":    total,
 			"enriched": enriched,
 			"pending":  pending,
+			"scored":   scores.Scored,
 		},
 		"scores": map[string]float64{
-			"before": avgScoreBefore,
-			"after":  avgScoreAfter,
+			"before":    scores.Before,
+			"after":     scores.After,
+			"readiness": readiness,
+		},
+		"distribution": map[string][]int{
+			"before": scores.BeforeDistribution,
+			"after":  scores.AfterDistribution,
 		},
 		"proposals": map[string]int{
 			"total":    proposalsTotal,
@@ -149,12 +211,146 @@ func (q *Queries) CreateProduct(ctx context.Context, p models.Product) error {
 	return err
 }
 
+// copier bulk-copies rows on the pool or inside a transaction
+type copier interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// CreateProducts bulk-inserts products with COPY, all or nothing. External IDs must be
+// unique within the dataset (see the products unique constraint).
+func (q *Queries) CreateProducts(ctx context.Context, products []models.Product) (int64, error) {
+	return copyProducts(ctx, q.pool, products)
+}
+
+// CreateDatasetWithProducts creates a dataset and COPYs its products in one transaction, so a
+// failed import leaves no empty dataset behind. The dataset's row count is the number of
+// products copied.
+func (q *Queries) CreateDatasetWithProducts(ctx context.Context, d *models.Dataset, products []models.Product) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO datasets (id, org_id, name, source_file_url, row_count, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 0, $5, $6, $7)
+	`, d.ID, d.OrgID, d.Name, d.SourceFileURL, d.Status, d.CreatedAt, d.UpdatedAt); err != nil {
+		return err
+	}
+	copied, err := copyProducts(ctx, tx, products)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE datasets SET row_count = $2 WHERE id = $1`, d.ID, copied); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	d.RowCount = int(copied)
+	return nil
+}
+
+func copyProducts(ctx context.Context, db copier, products []models.Product) (int64, error) {
+	return db.CopyFrom(ctx,
+		pgx.Identifier{"products"},
+		[]string{"id", "dataset_id", "external_id", "raw_data", "current_data", "version", "status", "baseline_score", "created_at", "updated_at"},
+		pgx.CopyFromSlice(len(products), func(i int) ([]any, error) {
+			p := products[i]
+			return []any{p.ID, p.DatasetID, p.ExternalID, p.RawData, p.CurrentData, p.Version, p.Status, p.BaselineScore, p.CreatedAt, p.UpdatedAt}, nil
+		}),
+	)
+}
+
+// ImportResult counts what a re-import did to the products of a dataset
+type ImportResult struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// UpsertProducts re-imports a feed into a dataset: the rows are copied into a temporary table,
+// then inserted, or merged into the product with the same external ID. A merged product gets
+// the new feed data with its applied changes on top (fields of current_data that differed
+// from its previous raw_data), and a new version; products whose feed data didn't change are
+// left alone. Products missing from the feed are kept. All or nothing.
+func (q *Queries) UpsertProducts(ctx context.Context, datasetID uuid.UUID, products []models.Product) (*ImportResult, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE product_import (LIKE products INCLUDING DEFAULTS) ON COMMIT DROP`); err != nil {
+		return nil, err
+	}
+	columns := []string{"id", "dataset_id", "external_id", "raw_data", "current_data", "version", "status", "baseline_score", "created_at", "updated_at"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"product_import"}, columns,
+		pgx.CopyFromSlice(len(products), func(i int) ([]any, error) {
+			p := products[i]
+			return []any{p.ID, datasetID, p.ExternalID, p.RawData, p.CurrentData, p.Version, p.Status, p.BaselineScore, p.CreatedAt, p.UpdatedAt}, nil
+		}),
+	); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		INSERT INTO products (`+strings.Join(columns, ", ")+`)
+		SELECT `+strings.Join(columns, ", ")+` FROM product_import
+		ON CONFLICT (dataset_id, external_id) DO UPDATE SET
+			raw_data = EXCLUDED.raw_data,
+			baseline_score = EXCLUDED.baseline_score,
+			current_data = EXCLUDED.raw_data || COALESCE((
+				SELECT jsonb_object_agg(e.key, e.value) FROM jsonb_each(products.current_data) e
+				WHERE products.raw_data->e.key IS DISTINCT FROM e.value
+			), '{}'),
+			version = products.version + 1,
+			updated_at = NOW()
+		WHERE products.raw_data IS DISTINCT FROM EXCLUDED.raw_data
+		RETURNING xmax = 0
+	`)
+	if err != nil {
+		return nil, err
+	}
+	res := &ImportResult{}
+	for rows.Next() {
+		var inserted bool
+		if err := rows.Scan(&inserted); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if inserted {
+			res.Inserted++
+		} else {
+			res.Updated++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	res.Unchanged = len(products) - res.Inserted - res.Updated
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE datasets SET row_count = (SELECT COUNT(*) FROM products WHERE dataset_id = $1), updated_at = NOW()
+		WHERE id = $1
+	`, datasetID); err != nil {
+		return nil, err
+	}
+	return res, tx.Commit(ctx)
+}
+
+// productColumns is the column list matched by scanProduct
+const productColumns = `id, dataset_id, external_id, raw_data, current_data, version, status, agent_readiness_score, baseline_score::float8, COALESCE(tags, '{}'), COALESCE(notes, ''), created_at, updated_at`
+
+func scanProduct(row pgx.Row, p *models.Product) error {
+	return row.Scan(&p.ID, &p.DatasetID, &p.ExternalID, &p.RawData, &p.CurrentData, &p.Version, &p.Status, &p.AgentReadinessScore, &p.BaselineScore, &p.Tags, &p.Notes, &p.CreatedAt, &p.UpdatedAt)
+}
+
 func (q *Queries) GetProduct(ctx context.Context, id uuid.UUID) (*models.Product, error) {
 	var p models.Product
-	err := q.pool.QueryRow(ctx, `
-		SELECT id, dataset_id, external_id, raw_data, current_data, version, status, agent_readiness_score, created_at, updated_at
-		FROM products WHERE id = $1
-	`, id).Scan(&p.ID, &p.DatasetID, &p.ExternalID, &p.RawData, &p.CurrentData, &p.Version, &p.Status, &p.AgentReadinessScore, &p.CreatedAt, &p.UpdatedAt)
+	err := scanProduct(q.pool.QueryRow(ctx, `SELECT `+productColumns+` FROM products WHERE id = $1`, id), &p)
 	if err != nil {
 		return nil, err
 	}
@@ -171,43 +367,189 @@ func (q *Queries) UpdateProductAfterEnrichment(ctx context.Context, id uuid.UUID
 }
 
 func (q *Queries) ListProductsByDataset(ctx context.Context, datasetID uuid.UUID) ([]models.Product, error) {
+	return q.ListProductsByDatasetTagged(ctx, datasetID, nil)
+}
+
+// ListProductsByDatasetTagged returns products carrying all of the given tags (all products when tags is empty)
+func (q *Queries) ListProductsByDatasetTagged(ctx context.Context, datasetID uuid.UUID, tags []string) ([]models.Product, error) {
+	return q.ListProductsMatching(ctx, datasetID, tags, nil)
+}
+
+// ProductFilter narrows and pages the product list of a dataset; zero values match everything
+type ProductFilter struct {
+	Tags     []string
+	Status   string
+	MinScore *float64 // agent_readiness_score, 0-1
+	MaxScore *float64
+	Search   string // contained in external_id or the title, case-insensitive
+	Sort     string // see productOrders
+	Limit    int    // default 100
+	Offset   int
+}
+
+// productTitle is the title a product is listed and searched by
+const productTitle = `COALESCE(current_data->>'title', raw_data->>'title', external_id)`
+
+// productOrders are the sorts of the product list; a "-" prefix reverses them. Ties are
+// broken by id so pages don't overlap.
+var productOrders = map[string]string{
+	"created_at":  "created_at",
+	"updated_at":  "updated_at",
+	"external_id": "external_id",
+	"title":       "LOWER(" + productTitle + ")",
+	"status":      "status",
+	"score":       "agent_readiness_score",
+}
+
+// ValidProductSort reports whether sort is a sort of the product list ("" = created_at)
+func ValidProductSort(sort string) bool {
+	_, ok := productOrders[strings.TrimPrefix(sort, "-")]
+	return sort == "" || ok
+}
+
+func productOrder(sort string) string {
+	dir := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		sort, dir = sort[1:], "DESC"
+	}
+	column, ok := productOrders[sort]
+	if !ok {
+		column = productOrders["created_at"]
+	}
+	return column + " " + dir + " NULLS LAST, id " + dir
+}
+
+// ListProductsPage returns a page of a dataset's products matching the filter, and how many
+// match in all
+func (q *Queries) ListProductsPage(ctx context.Context, datasetID uuid.UUID, f ProductFilter) ([]models.Product, int, error) {
+	if f.Tags == nil {
+		f.Tags = []string{}
+	}
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	where := `dataset_id = $1 AND tags @> $2
+		AND ($3 = '' OR status = $3)
+		AND ($4::float8 IS NULL OR agent_readiness_score >= $4)
+		AND ($5::float8 IS NULL OR agent_readiness_score <= $5)
+		AND ($6 = '' OR external_id ILIKE '%' || $6 || '%' OR ` + productTitle + ` ILIKE '%' || $6 || '%')`
+	args := []any{datasetID, f.Tags, f.Status, f.MinScore, f.MaxScore, strings.TrimSpace(f.Search)}
+
+	var total int
+	if err := q.pool.QueryRow(ctx, `SELECT COUNT(*) FROM products WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+productColumns+`
+		FROM products WHERE `+where+`
+		ORDER BY `+productOrder(f.Sort)+` LIMIT $7 OFFSET $8
+	`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, 0, err
+		}
+		products = append(products, p)
+	}
+	return products, total, rows.Err()
+}
+
+// UpdateProductTags replaces the tags and notes of a product
+func (q *Queries) UpdateProductTags(ctx context.Context, id uuid.UUID, tags []string, notes string) error {
+	_, err := q.pool.Exec(ctx, `
+		UPDATE products SET tags = $2, notes = NULLIF($3, ''), updated_at = NOW() WHERE id = $1
+	`, id, tags, notes)
+	return err
+}
+
+// AddProductTag adds a tag to a product if not already present
+func (q *Queries) AddProductTag(ctx context.Context, id uuid.UUID, tag string) error {
+	_, err := q.pool.Exec(ctx, `
+		UPDATE products SET tags = array_append(tags, $2), updated_at = NOW()
+		WHERE id = $1 AND NOT ($2 = ANY(tags))
+	`, id, tag)
+	return err
+}
+
+// RemoveProductTag removes a tag from a product
+func (q *Queries) RemoveProductTag(ctx context.Context, id uuid.UUID, tag string) error {
+	_, err := q.pool.Exec(ctx, `
+		UPDATE products SET tags = array_remove(tags, $2), updated_at = NOW() WHERE id = $1
+	`, id, tag)
+	return err
+}
+
+// ListDatasetTags returns the distinct tags used in a dataset with product counts
+func (q *Queries) ListDatasetTags(ctx context.Context, datasetID uuid.UUID) (map[string]int, error) {
 	rows, err := q.pool.Query(ctx, `
-		SELECT id, dataset_id, external_id, raw_data, current_data, version, status, agent_readiness_score, created_at, updated_at
-		FROM products WHERE dataset_id = $1 ORDER BY created_at
+		SELECT tag, COUNT(*) FROM products, unnest(tags) AS tag
+		WHERE dataset_id = $1 GROUP BY tag ORDER BY tag
 	`, datasetID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var products []models.Product
+	tags := map[string]int{}
 	for rows.Next() {
-		var p models.Product
-		if err := rows.Scan(&p.ID, &p.DatasetID, &p.ExternalID, &p.RawData, &p.CurrentData, &p.Version, &p.Status, &p.AgentReadinessScore, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		var tag string
+		var count int
+		if err := rows.Scan(&tag, &count); err != nil {
 			return nil, err
 		}
-		products = append(products, p)
+		tags[tag] = count
 	}
-	return products, nil
+	return tags, nil
 }
 
 // Agent session operations
 
+// CreateAgentSession saves a session with its traces, proposals, escalations and call log.
+// Saving a resumed session again updates it: its status and checkpoint are replaced, the
+// usage of the new run is added, and traces and proposals already saved are kept (only the
+// outputs of traces are updated, with the answers of human reviews). Everything is written in
+// one transaction: a failure leaves no session without its traces, nor traces without their session.
 func (q *Queries) CreateAgentSession(ctx context.Context, s agent.Session) error {
-	_, err := q.pool.Exec(ctx, `
-		INSERT INTO agent_sessions (id, product_id, goal, status, total_steps, tokens_used, started_at, completed_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, s.ID, s.ProductID, s.Goal, s.Status, len(s.Traces), 0, s.StartedAt, nil)
+	thresholds, _ := json.Marshal(s.Thresholds)
+	var checkpoint []byte
+	if s.Checkpoint != nil {
+		checkpoint, _ = json.Marshal(s.Checkpoint)
+	}
+	usage := s.Usage()
+
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO agent_sessions (id, product_id, goal, status, total_steps, tokens_used, cost_usd, started_at, completed_at, thresholds, engine, checkpoint)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			total_steps = EXCLUDED.total_steps,
+			tokens_used = agent_sessions.tokens_used + EXCLUDED.tokens_used,
+			cost_usd = agent_sessions.cost_usd + EXCLUDED.cost_usd,
+			checkpoint = EXCLUDED.checkpoint
+	`, s.ID, s.ProductID, s.Goal, s.Status, len(s.Traces), usage.PromptTokens+usage.CompletionTokens, usage.CostUSD, s.StartedAt, nil, thresholds, s.Engine, checkpoint)
 	if err != nil {
 		return err
 	}
 
 	// Save traces
 	for _, t := range s.Traces {
-		_, err := q.pool.Exec(ctx, `
-			INSERT INTO agent_traces (id, session_id, step_number, thought, tool_name, tool_input, tool_output, tokens_used, duration_ms, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		`, t.ID, t.SessionID, t.StepNumber, t.Thought, t.ToolName, t.ToolInput, t.ToolOutput, t.TokensUsed, t.DurationMs, t.CreatedAt)
+		_, err := tx.Exec(ctx, `
+			INSERT INTO agent_traces (id, session_id, step_number, thought, tool_name, tool_input, tool_output, tokens_used, duration_ms, retries, model, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
+			ON CONFLICT (id, created_at) DO UPDATE SET tool_output = EXCLUDED.tool_output
+		`, t.ID, t.SessionID, t.StepNumber, t.Thought, t.ToolName, t.ToolInput, t.ToolOutput, t.TokensUsed, t.DurationMs, t.Retries, t.Model, t.CreatedAt)
 		if err != nil {
 			return err
 		}
@@ -215,24 +557,69 @@ func (q *Queries) CreateAgentSession(ctx context.Context, s agent.Session) error
 
 	// Save proposals
 	for _, p := range s.Proposals {
-		_, err := q.pool.Exec(ctx, `
-			INSERT INTO proposals (id, product_id, session_id, field, before_value, after_value, sources, confidence, risk_level, status, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		`, p.ID, p.ProductID, p.SessionID, p.Field, p.BeforeValue, p.AfterValue, p.Sources, p.Confidence, p.RiskLevel, p.Status, p.CreatedAt)
+		_, err := tx.Exec(ctx, `
+			INSERT INTO proposals (id, product_id, session_id, field, before_value, after_value, rationale, sources, confidence, risk_level, status, quality_score, quality_breakdown, module, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15)
+			ON CONFLICT (id) DO NOTHING
+		`, p.ID, p.ProductID, p.SessionID, p.Field, p.BeforeValue, p.AfterValue, p.Rationale, p.Sources, p.Confidence, p.RiskLevel, p.Status, p.QualityScore, p.QualityBreakdown, p.Module, p.CreatedAt)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	// Save escalations, the pipeline trail and the call log
+	if err := createHumanReviews(ctx, tx, s.HumanReviews); err != nil {
+		return err
+	}
+	if err := createPipelineRun(ctx, tx, s.PipelineRun); err != nil {
+		return err
+	}
+	if err := createLLMCalls(ctx, tx, s.LLMCalls); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RunRecords is what a job run leaves on a product: jobs don't save agent sessions
+type RunRecords struct {
+	Proposals    []models.Proposal
+	HumanReviews []models.HumanReview
+	PipelineRun  *models.PipelineRun
+	LLMCalls     []models.LLMCall
+}
+
+// CreateRunRecords saves the records of a job run in one transaction, so the retrieved facts
+// of the pipeline trail are linked to the proposals saved with them
+func (q *Queries) CreateRunRecords(ctx context.Context, r RunRecords) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, p := range r.Proposals {
+		if err := createProposal(ctx, tx, p); err != nil {
+			return err
+		}
+	}
+	if err := createHumanReviews(ctx, tx, r.HumanReviews); err != nil {
+		return err
+	}
+	if err := createPipelineRun(ctx, tx, r.PipelineRun); err != nil {
+		return err
+	}
+	if err := createLLMCalls(ctx, tx, r.LLMCalls); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (q *Queries) GetAgentSession(ctx context.Context, id uuid.UUID) (*models.AgentSession, error) {
 	var s models.AgentSession
 	err := q.pool.QueryRow(ctx, `
-		SELECT id, product_id, goal, status, total_steps, tokens_used, started_at, completed_at
+		SELECT id, product_id, goal, status, total_steps, tokens_used, cost_usd, started_at, completed_at, thresholds, engine, checkpoint
 		FROM agent_sessions WHERE id = $1
-	`, id).Scan(&s.ID, &s.ProductID, &s.Goal, &s.Status, &s.TotalSteps, &s.TokensUsed, &s.StartedAt, &s.CompletedAt)
+	`, id).Scan(&s.ID, &s.ProductID, &s.Goal, &s.Status, &s.TotalSteps, &s.TokensUsed, &s.CostUSD, &s.StartedAt, &s.CompletedAt, &s.Thresholds, &s.Engine, &s.Checkpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -241,7 +628,7 @@ func (q *Queries) GetAgentSession(ctx context.Context, id uuid.UUID) (*models.Ag
 
 func (q *Queries) GetAgentTraces(ctx context.Context, sessionID uuid.UUID) ([]models.AgentTrace, error) {
 	rows, err := q.pool.Query(ctx, `
-		SELECT id, session_id, step_number, thought, tool_name, tool_input, tool_output, tokens_used, duration_ms, created_at
+		SELECT id, session_id, step_number, thought, tool_name, tool_input, tool_output, tokens_used, duration_ms, retries, COALESCE(model, ''), created_at
 		FROM agent_traces WHERE session_id = $1 ORDER BY step_number
 	`, sessionID)
 	if err != nil {
@@ -252,7 +639,7 @@ func (q *Queries) GetAgentTraces(ctx context.Context, sessionID uuid.UUID) ([]mo
 	var traces []models.AgentTrace
 	for rows.Next() {
 		var t models.AgentTrace
-		if err := rows.Scan(&t.ID, &t.SessionID, &t.StepNumber, &t.Thought, &t.ToolName, &t.ToolInput, &t.ToolOutput, &t.TokensUsed, &t.DurationMs, &t.CreatedAt); err != nil {
+		if err := rows.Scan(&t.ID, &t.SessionID, &t.StepNumber, &t.Thought, &t.ToolName, &t.ToolInput, &t.ToolOutput, &t.TokensUsed, &t.DurationMs, &t.Retries, &t.Model, &t.CreatedAt); err != nil {
 			return nil, err
 		}
 		traces = append(traces, t)
@@ -262,33 +649,185 @@ func (q *Queries) GetAgentTraces(ctx context.Context, sessionID uuid.UUID) ([]mo
 
 // Proposal operations
 
-func (q *Queries) ListProposals(ctx context.Context) ([]models.Proposal, error) {
+// ProposalListFilter narrows and pages a proposal list; zero values match everything.
+// Simulated proposals are only listed when Status asks for them.
+type ProposalListFilter struct {
+	OrgID         uuid.UUID
+	DatasetID     *uuid.UUID
+	ProductID     *uuid.UUID
+	Status        string
+	Field         string // case-insensitive
+	RiskLevel     string
+	Module        string
+	MinConfidence *float64
+	MaxConfidence *float64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Assignee      string // "none" = unassigned
+	ReviewState   string
+	Sort          string          // see proposalSortKey
+	After         *ProposalCursor // the last proposal of the previous page
+	Limit         int             // default 100
+}
+
+// ProposalCursor is the position of a proposal in a list: its sort key (see
+// proposalSortKey), then newest first
+type ProposalCursor struct {
+	Key       int
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// String encodes the cursor for the next_cursor of a page
+func (c ProposalCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(c.Key) + "|" + c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()))
+}
+
+// ParseProposalCursor decodes a cursor returned by ProposalCursor.String
+func ParseProposalCursor(s string) (*ProposalCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	c := &ProposalCursor{}
+	switch len(parts) {
+	case 2: // cursors of the newest-first list before sort keys
+	case 3:
+		if c.Key, err = strconv.Atoi(parts[0]); err != nil {
+			return nil, errInvalidCursor
+		}
+		parts = parts[1:]
+	default:
+		return nil, errInvalidCursor
+	}
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return nil, errInvalidCursor
+	}
+	if c.ID, err = uuid.Parse(parts[1]); err != nil {
+		return nil, errInvalidCursor
+	}
+	return c, nil
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// proposalSortKey maps a sort option to the integer proposals aliased p are listed by,
+// descending, before newest first: "quality" (best first), "quality_asc" (worst first),
+// anything else = newest first only. Unscored proposals come last either way.
+func proposalSortKey(sort string) string {
+	switch sort {
+	case "quality":
+		return "COALESCE(p.quality_score, -1)"
+	case "quality_asc":
+		return "-COALESCE(p.quality_score, 1000)"
+	}
+	return "0"
+}
+
+// proposalPage is the filter, order and limit of a proposal list page, on proposals p
+// joined to their products pr, with proposalPageArgs as parameters
+func proposalPage(sort string) string {
+	key := proposalSortKey(sort)
+	return `
+		WHERE (($1 = '' AND p.status <> 'simulated') OR p.status = $1)
+		AND ($2::uuid IS NULL OR pr.dataset_id = $2)
+		AND ($3::uuid IS NULL OR p.product_id = $3)
+		AND ($4 = '' OR lower(p.field) = lower($4))
+		AND ($5 = '' OR p.risk_level = $5)
+		AND ($6 = '' OR COALESCE(p.module, '') = $6)
+		AND ($7::float8 IS NULL OR p.confidence >= $7)
+		AND ($8::float8 IS NULL OR p.confidence <= $8)
+		AND ($9::timestamptz IS NULL OR p.created_at >= $9)
+		AND ($10::timestamptz IS NULL OR p.created_at < $10)
+		AND ($11 = '' OR ($11 = 'none' AND p.assignee IS NULL) OR p.assignee = $11)
+		AND ($12 = '' OR p.review_state = $12)
+		AND ($14::timestamptz IS NULL OR (` + key + `, p.created_at, p.id) < ($13::int, $14, $15::uuid))
+		AND pr.dataset_id IN (SELECT id FROM datasets WHERE org_id = $16)
+		ORDER BY ` + key + ` DESC, p.created_at DESC, p.id DESC
+		LIMIT $17`
+}
+
+// proposalPageArgs are the parameters of proposalPage, reading one more proposal than the
+// page holds to tell whether another page follows
+func proposalPageArgs(f *ProposalListFilter) []any {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	var afterKey *int
+	var afterAt *time.Time
+	var afterID *uuid.UUID
+	if f.After != nil {
+		afterKey, afterAt, afterID = &f.After.Key, &f.After.CreatedAt, &f.After.ID
+	}
+	return []any{f.Status, f.DatasetID, f.ProductID, f.Field, f.RiskLevel, f.Module, f.MinConfidence, f.MaxConfidence,
+		f.CreatedAfter, f.CreatedBefore, f.Assignee, f.ReviewState, afterKey, afterAt, afterID, f.OrgID, f.Limit + 1}
+}
+
+// nextProposalCursor trims the extra proposal read by proposalPageArgs and returns the
+// cursor after the last one kept, nil on the last page
+func nextProposalCursor(n, limit int, keyOf func(i int) ProposalCursor) (int, *ProposalCursor) {
+	if n <= limit {
+		return n, nil
+	}
+	next := keyOf(limit - 1)
+	return limit, &next
+}
+
+// sortKeyOf computes proposalSortKey in Go, for the cursor of a page
+func sortKeyOf(sort string, quality *int) int {
+	switch sort {
+	case "quality":
+		if quality == nil {
+			return -1
+		}
+		return *quality
+	case "quality_asc":
+		if quality == nil {
+			return -1000
+		}
+		return -*quality
+	}
+	return 0
+}
+
+// ListProposals returns a page of proposals and the cursor of the next page (nil on the
+// last one). Pages are keyed on the sort key and (created_at, id) so proposals created
+// while paging don't shift them.
+func (q *Queries) ListProposals(ctx context.Context, f ProposalListFilter) ([]models.Proposal, *ProposalCursor, error) {
 	rows, err := q.pool.Query(ctx, `
-		SELECT id, product_id, session_id, field, before_value, after_value, sources, confidence, risk_level, status, reviewed_by, reviewed_at, created_at
-		FROM proposals ORDER BY created_at DESC
-	`)
+		SELECT p.id, p.product_id, p.session_id, p.field, p.before_value, p.after_value, COALESCE(p.rationale, '{}'), p.sources, p.confidence, p.risk_level, p.status, p.quality_score, p.reviewed_by, p.reviewed_at, p.assignee, p.review_state, COALESCE(p.module, ''), p.created_at
+		FROM proposals p
+		JOIN products pr ON pr.id = p.product_id
+	`+proposalPage(f.Sort), proposalPageArgs(&f)...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var proposals []models.Proposal
+	proposals := []models.Proposal{}
 	for rows.Next() {
 		var p models.Proposal
-		if err := rows.Scan(&p.ID, &p.ProductID, &p.SessionID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.ReviewedBy, &p.ReviewedAt, &p.CreatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&p.ID, &p.ProductID, &p.SessionID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.Rationale, &p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.QualityScore, &p.ReviewedBy, &p.ReviewedAt, &p.Assignee, &p.ReviewState, &p.Module, &p.CreatedAt); err != nil {
+			return nil, nil, err
 		}
 		proposals = append(proposals, p)
 	}
-	return proposals, nil
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	n, next := nextProposalCursor(len(proposals), f.Limit, func(i int) ProposalCursor {
+		return ProposalCursor{Key: sortKeyOf(f.Sort, proposals[i].QualityScore), CreatedAt: proposals[i].CreatedAt, ID: proposals[i].ID}
+	})
+	return proposals[:n], next, nil
 }
 
 func (q *Queries) GetProposal(ctx context.Context, id uuid.UUID) (*models.Proposal, error) {
 	var p models.Proposal
 	err := q.pool.QueryRow(ctx, `
-		SELECT id, product_id, session_id, field, before_value, after_value, sources, confidence, risk_level, status, reviewed_by, reviewed_at, created_at
+		SELECT id, product_id, session_id, field, before_value, after_value, edited_value, COALESCE(rationale, '{}'), sources, confidence, risk_level, status, quality_score, quality_breakdown, reviewed_by, reviewed_at, applied_at, superseded_by, assignee, assigned_at, review_state, group_change_id, COALESCE(module, ''), created_at
 		FROM proposals WHERE id = $1
-	`, id).Scan(&p.ID, &p.ProductID, &p.SessionID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.ReviewedBy, &p.ReviewedAt, &p.CreatedAt)
+	`, id).Scan(&p.ID, &p.ProductID, &p.SessionID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.EditedValue, &p.Rationale, &p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.QualityScore, &p.QualityBreakdown, &p.ReviewedBy, &p.ReviewedAt, &p.AppliedAt, &p.SupersededBy, &p.Assignee, &p.AssignedAt, &p.ReviewState, &p.GroupChangeID, &p.Module, &p.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -298,54 +837,109 @@ func (q *Queries) GetProposal(ctx context.Context, id uuid.UUID) (*models.Propos
 // ProposalWithProduct includes product info alongside the proposal
 type ProposalWithProduct struct {
 	models.Proposal
-	ProductExternalID string          `json:"product_external_id"`
-	ProductTitle      string          `json:"product_title"`
-	DatasetID         uuid.UUID       `json:"dataset_id"`
+	ProductExternalID string    `json:"product_external_id"`
+	ProductTitle      string    `json:"product_title"`
+	DatasetID         uuid.UUID `json:"dataset_id"`
+	Competing         int       `json:"competing"` // other pending proposals on the same product field
 }
 
-func (q *Queries) ListProposalsWithProducts(ctx context.Context) ([]ProposalWithProduct, error) {
+// ListProposalsWithProducts returns a page of reviewable proposals with their product, and
+// the cursor of the next page (see ListProposals)
+func (q *Queries) ListProposalsWithProducts(ctx context.Context, f ProposalListFilter) ([]ProposalWithProduct, *ProposalCursor, error) {
 	rows, err := q.pool.Query(ctx, `
 		SELECT 
-			p.id, p.product_id, p.session_id, p.field, p.before_value, p.after_value, 
-			p.sources, p.confidence, p.risk_level, p.status, p.reviewed_by, p.reviewed_at, p.created_at,
+			p.id, p.product_id, p.session_id, p.field, p.before_value, p.after_value, p.edited_value,
+			COALESCE(p.rationale, '{}'), p.sources, p.confidence, p.risk_level, p.status, p.quality_score, p.reviewed_by, p.reviewed_at,
+			p.assignee, p.assigned_at, p.review_state, COALESCE(p.module, ''), p.created_at,
 			pr.external_id,
 			COALESCE(pr.raw_data->>'title', pr.raw_data->>'titre', pr.raw_data->>'Titre', pr.external_id) as product_title,
-			pr.dataset_id
+			pr.dataset_id,
+			CASE WHEN p.status = 'proposed' THEN (
+				SELECT COUNT(*) FROM proposals o
+				WHERE o.product_id = p.product_id AND o.field = p.field AND o.status = 'proposed' AND o.id <> p.id
+			) ELSE 0 END
 		FROM proposals p
 		JOIN products pr ON p.product_id = pr.id
-		ORDER BY p.created_at DESC
-	`)
+	`+proposalPage(f.Sort), proposalPageArgs(&f)...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var proposals []ProposalWithProduct
+	proposals := []ProposalWithProduct{}
 	for rows.Next() {
 		var p ProposalWithProduct
 		if err := rows.Scan(
-			&p.ID, &p.ProductID, &p.SessionID, &p.Field, &p.BeforeValue, &p.AfterValue,
-			&p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.ReviewedBy, &p.ReviewedAt, &p.CreatedAt,
-			&p.ProductExternalID, &p.ProductTitle, &p.DatasetID,
+			&p.ID, &p.ProductID, &p.SessionID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.EditedValue,
+			&p.Rationale, &p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.QualityScore, &p.ReviewedBy, &p.ReviewedAt,
+			&p.Assignee, &p.AssignedAt, &p.ReviewState, &p.Module, &p.CreatedAt,
+			&p.ProductExternalID, &p.ProductTitle, &p.DatasetID, &p.Competing,
 		); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		proposals = append(proposals, p)
 	}
-	return proposals, nil
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	n, next := nextProposalCursor(len(proposals), f.Limit, func(i int) ProposalCursor {
+		return ProposalCursor{Key: sortKeyOf(f.Sort, proposals[i].QualityScore), CreatedAt: proposals[i].CreatedAt, ID: proposals[i].ID}
+	})
+	return proposals[:n], next, nil
 }
 
-func (q *Queries) UpdateProposalStatus(ctx context.Context, id uuid.UUID, status string) error {
-	_, err := q.pool.Exec(ctx, `UPDATE proposals SET status = $2, reviewed_at = NOW() WHERE id = $1`, id, status)
+func (q *Queries) UpdateProposalStatus(ctx context.Context, id uuid.UUID, status, reviewer string) error {
+	// Simulated (dry run) proposals are never actionable
+	_, err := q.pool.Exec(ctx, `
+		UPDATE proposals SET status = $2, reviewed_at = NOW(), review_state = $3, reviewed_by = COALESCE(NULLIF($4, ''), reviewed_by)
+		WHERE id = $1 AND status <> 'simulated'
+	`, id, status, reviewStateFor(status), reviewer)
 	return err
 }
 
 func (q *Queries) CreateProposal(ctx context.Context, p models.Proposal) error {
-	_, err := q.pool.Exec(ctx, `
-		INSERT INTO proposals (id, product_id, field, before_value, after_value, rationale, sources, confidence, risk_level, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	return createProposal(ctx, q.pool, p)
+}
+
+func createProposal(ctx context.Context, db execer, p models.Proposal) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO proposals (id, product_id, field, before_value, after_value, rationale, sources, confidence, risk_level, status, quality_score, quality_breakdown, module, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''), $14)
 		ON CONFLICT (id) DO NOTHING
-	`, p.ID, p.ProductID, p.Field, p.BeforeValue, p.AfterValue, p.Rationale, p.Sources, p.Confidence, p.RiskLevel, p.Status, p.CreatedAt)
+	`, p.ID, p.ProductID, p.Field, p.BeforeValue, p.AfterValue, p.Rationale, p.Sources, p.Confidence, p.RiskLevel, p.Status, p.QualityScore, p.QualityBreakdown, p.Module, p.CreatedAt)
+	return err
+}
+
+// ListUnscoredProposals returns the pending proposals of an organization without a quality score
+func (q *Queries) ListUnscoredProposals(ctx context.Context, orgID uuid.UUID, datasetID *uuid.UUID, limit int) ([]models.Proposal, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT p.id, p.product_id, p.field, p.before_value, p.after_value, COALESCE(p.rationale, '{}'), p.sources, p.confidence, p.risk_level, p.status, p.created_at
+		FROM proposals p
+		JOIN products pr ON p.product_id = pr.id
+		WHERE p.quality_score IS NULL AND p.status = 'proposed'
+		AND ($1::uuid IS NULL OR pr.dataset_id = $1)
+		AND pr.dataset_id IN (SELECT id FROM datasets WHERE org_id = $3)
+		ORDER BY p.product_id, p.created_at LIMIT $2
+	`, datasetID, limit, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var proposals []models.Proposal
+	for rows.Next() {
+		var p models.Proposal
+		if err := rows.Scan(&p.ID, &p.ProductID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.Rationale, &p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, p)
+	}
+	return proposals, nil
+}
+
+// UpdateProposalQuality stores the quality score of a proposal
+func (q *Queries) UpdateProposalQuality(ctx context.Context, id uuid.UUID, score int, breakdown json.RawMessage) error {
+	_, err := q.pool.Exec(ctx, `UPDATE proposals SET quality_score = $2, quality_breakdown = $3 WHERE id = $1`, id, score, breakdown)
 	return err
 }
 
@@ -361,11 +955,12 @@ func (q *Queries) CreateJob(ctx context.Context, j models.Job) error {
 
 // Rule operations
 
-func (q *Queries) ListRules(ctx context.Context) ([]models.Rule, error) {
+// ListRules returns the active rules of an organization
+func (q *Queries) ListRules(ctx context.Context, orgID uuid.UUID) ([]models.Rule, error) {
 	rows, err := q.pool.Query(ctx, `
-		SELECT id, dataset_id, name, type, field, condition, message, severity, active, created_by, created_at
-		FROM rules WHERE active = true ORDER BY created_at
-	`)
+		SELECT id, org_id, dataset_id, name, type, field, condition, message, severity, active, created_by, created_at
+		FROM rules WHERE active = true AND org_id = $1 ORDER BY created_at
+	`, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -374,7 +969,7 @@ func (q *Queries) ListRules(ctx context.Context) ([]models.Rule, error) {
 	var rules []models.Rule
 	for rows.Next() {
 		var r models.Rule
-		if err := rows.Scan(&r.ID, &r.DatasetID, &r.Name, &r.Type, &r.Field, &r.Condition, &r.Message, &r.Severity, &r.Active, &r.CreatedBy, &r.CreatedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.OrgID, &r.DatasetID, &r.Name, &r.Type, &r.Field, &r.Condition, &r.Message, &r.Severity, &r.Active, &r.CreatedBy, &r.CreatedAt); err != nil {
 			return nil, err
 		}
 		rules = append(rules, r)
@@ -384,9 +979,9 @@ func (q *Queries) ListRules(ctx context.Context) ([]models.Rule, error) {
 
 func (q *Queries) CreateRule(ctx context.Context, r models.Rule) error {
 	_, err := q.pool.Exec(ctx, `
-		INSERT INTO rules (id, dataset_id, name, type, field, condition, message, severity, active, created_by, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`, r.ID, r.DatasetID, r.Name, r.Type, r.Field, r.Condition, r.Message, r.Severity, r.Active, r.CreatedBy, r.CreatedAt)
+		INSERT INTO rules (id, org_id, dataset_id, name, type, field, condition, message, severity, active, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, r.ID, r.OrgID, r.DatasetID, r.Name, r.Type, r.Field, r.Condition, r.Message, r.Severity, r.Active, r.CreatedBy, r.CreatedAt)
 	return err
 }
 
@@ -469,21 +1064,22 @@ func (q *Queries) RecordTokenUsage(ctx context.Context, model string, promptToke
 	return err
 }
 
-// GetTokenUsageStats returns aggregated token usage statistics
-func (q *Queries) GetTokenUsageStats(ctx context.Context, days int) (*models.TokenUsageStats, error) {
+// GetTokenUsageStats returns aggregated token usage statistics of the datasets of an organization
+func (q *Queries) GetTokenUsageStats(ctx context.Context, orgID uuid.UUID, days int) (*models.TokenUsageStats, error) {
 	stats := &models.TokenUsageStats{}
 
 	// Get totals
 	err := q.pool.QueryRow(ctx, `
 		SELECT 
-			COALESCE(SUM(prompt_tokens), 0),
-			COALESCE(SUM(completion_tokens), 0),
-			COALESCE(SUM(total_tokens), 0),
+			COALESCE(SUM(prompt_tokens), 0)::bigint,
+			COALESCE(SUM(completion_tokens), 0)::bigint,
+			COALESCE(SUM(prompt_tokens + completion_tokens), 0)::bigint,
 			COALESCE(SUM(cost_usd), 0),
 			COALESCE(SUM(api_calls), 0)
-		FROM token_usage
+		FROM token_usage_attribution
 		WHERE date >= CURRENT_DATE - $1::integer
-	`, days).Scan(&stats.TotalPromptTokens, &stats.TotalCompletionTokens, &stats.TotalTokens, &stats.TotalCostUSD, &stats.TotalAPICalls)
+		AND dataset_id IN (SELECT id FROM datasets WHERE org_id = $2)
+	`, days, orgID).Scan(&stats.TotalPromptTokens, &stats.TotalCompletionTokens, &stats.TotalTokens, &stats.TotalCostUSD, &stats.TotalAPICalls)
 	if err != nil {
 		return nil, err
 	}
@@ -492,16 +1088,17 @@ func (q *Queries) GetTokenUsageStats(ctx context.Context, days int) (*models.Tok
 	rows, err := q.pool.Query(ctx, `
 		SELECT 
 			model,
-			SUM(prompt_tokens) as prompt_tokens,
-			SUM(completion_tokens) as completion_tokens,
-			SUM(total_tokens) as total_tokens,
+			SUM(prompt_tokens)::bigint as prompt_tokens,
+			SUM(completion_tokens)::bigint as completion_tokens,
+			SUM(prompt_tokens + completion_tokens)::bigint as total_tokens,
 			SUM(cost_usd) as cost_usd,
 			SUM(api_calls) as api_calls
-		FROM token_usage
+		FROM token_usage_attribution
 		WHERE date >= CURRENT_DATE - $1::integer
+		AND dataset_id IN (SELECT id FROM datasets WHERE org_id = $2)
 		GROUP BY model
 		ORDER BY total_tokens DESC
-	`, days)
+	`, days, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -519,17 +1116,18 @@ func (q *Queries) GetTokenUsageStats(ctx context.Context, days int) (*models.Tok
 	rows2, err := q.pool.Query(ctx, `
 		SELECT 
 			date::text,
-			SUM(prompt_tokens) as prompt_tokens,
-			SUM(completion_tokens) as completion_tokens,
-			SUM(total_tokens) as total_tokens,
+			SUM(prompt_tokens)::bigint as prompt_tokens,
+			SUM(completion_tokens)::bigint as completion_tokens,
+			SUM(prompt_tokens + completion_tokens)::bigint as total_tokens,
 			SUM(cost_usd) as cost_usd,
 			SUM(api_calls) as api_calls
-		FROM token_usage
+		FROM token_usage_attribution
 		WHERE date >= CURRENT_DATE - $1::integer
+		AND dataset_id IN (SELECT id FROM datasets WHERE org_id = $2)
 		GROUP BY date
 		ORDER BY date DESC
 		LIMIT 30
-	`, days)
+	`, days, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -589,25 +1187,46 @@ func (q *Queries) GetNextVersionNumber(ctx context.Context, datasetID uuid.UUID)
 
 // Snapshot operations
 
-func (q *Queries) CreateSnapshot(ctx context.Context, s models.DatasetSnapshot) error {
-	_, err := q.pool.Exec(ctx, `
-		INSERT INTO dataset_snapshots (id, dataset_id, name, snapshot_type, product_count, created_at, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, s.ID, s.DatasetID, s.Name, s.SnapshotType, s.ProductCount, s.CreatedAt, s.CreatedBy)
-	return err
-}
+// snapshotHash identifies the content of a product in snapshot_data
+const snapshotHash = `md5(jsonb_build_array(raw_data, current_data)::text)`
 
-func (q *Queries) CreateSnapshotProducts(ctx context.Context, snapshotID uuid.UUID, products []models.Product) error {
-	for _, p := range products {
-		_, err := q.pool.Exec(ctx, `
-			INSERT INTO snapshot_products (snapshot_id, product_id, raw_data, current_data)
-			VALUES ($1, $2, $3, $4)
-		`, snapshotID, p.ID, p.RawData, p.CurrentData)
-		if err != nil {
-			return err
-		}
+// CreateSnapshot records a snapshot of every product of its dataset and sets its ProductCount.
+// The products are copied within the database, and their data is only stored when no
+// snapshot holds the same content yet. All or nothing.
+func (q *Queries) CreateSnapshot(ctx context.Context, s *models.DatasetSnapshot) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
 	}
-	return nil
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO dataset_snapshots (id, dataset_id, name, snapshot_type, product_count, created_at, created_by)
+		VALUES ($1, $2, $3, $4, 0, $5, $6)
+	`, s.ID, s.DatasetID, s.Name, s.SnapshotType, s.CreatedAt, s.CreatedBy); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO snapshot_data (hash, raw_data, current_data)
+		SELECT DISTINCT ON (hash) hash, raw_data, current_data FROM (
+			SELECT `+snapshotHash+` AS hash, raw_data, current_data FROM products WHERE dataset_id = $1
+		) p
+		ON CONFLICT (hash) DO NOTHING
+	`, s.DatasetID); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO snapshot_products (snapshot_id, product_id, data_hash)
+		SELECT $1, id, `+snapshotHash+` FROM products WHERE dataset_id = $2
+	`, s.ID, s.DatasetID)
+	if err != nil {
+		return err
+	}
+	s.ProductCount = int(tag.RowsAffected())
+	if _, err := tx.Exec(ctx, `UPDATE dataset_snapshots SET product_count = $2 WHERE id = $1`, s.ID, s.ProductCount); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (q *Queries) ListSnapshots(ctx context.Context, datasetID uuid.UUID) ([]models.DatasetSnapshot, error) {
@@ -633,8 +1252,9 @@ func (q *Queries) ListSnapshots(ctx context.Context, datasetID uuid.UUID) ([]mod
 
 func (q *Queries) GetSnapshotProducts(ctx context.Context, snapshotID uuid.UUID) ([]models.SnapshotProduct, error) {
 	rows, err := q.pool.Query(ctx, `
-		SELECT id, snapshot_id, product_id, raw_data, current_data
-		FROM snapshot_products WHERE snapshot_id = $1
+		SELECT sp.id, sp.snapshot_id, sp.product_id, d.raw_data, d.current_data
+		FROM snapshot_products sp JOIN snapshot_data d ON d.hash = sp.data_hash
+		WHERE sp.snapshot_id = $1
 	`, snapshotID)
 	if err != nil {
 		return nil, err
@@ -652,8 +1272,37 @@ func (q *Queries) GetSnapshotProducts(ctx context.Context, snapshotID uuid.UUID)
 	return products, nil
 }
 
+// DeleteSnapshot deletes a snapshot, then the data no other snapshot references
 func (q *Queries) DeleteSnapshot(ctx context.Context, id uuid.UUID) error {
-	_, err := q.pool.Exec(ctx, `DELETE FROM dataset_snapshots WHERE id = $1`, id)
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := deleteSnapshotsTx(ctx, tx, []uuid.UUID{id}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// deleteSnapshotsTx deletes snapshots and the data they alone referenced
+func deleteSnapshotsTx(ctx context.Context, tx pgx.Tx, ids []uuid.UUID) error {
+	rows, err := tx.Query(ctx, `DELETE FROM snapshot_products WHERE snapshot_id = ANY($1) RETURNING data_hash`, ids)
+	if err != nil {
+		return err
+	}
+	hashes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM dataset_snapshots WHERE id = ANY($1)`, ids); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		DELETE FROM snapshot_data d
+		WHERE d.hash = ANY($1) AND NOT EXISTS (SELECT 1 FROM snapshot_products sp WHERE sp.data_hash = d.hash)
+	`, hashes)
 	return err
 }
 
@@ -692,27 +1341,21 @@ func (q *Queries) GetChangeLog(ctx context.Context, datasetID uuid.UUID, limit i
 
 func (q *Queries) CreateApprovalRule(ctx context.Context, r models.ApprovalRule) error {
 	_, err := q.pool.Exec(ctx, `
-		INSERT INTO approval_rules (id, dataset_id, name, field, module, min_confidence, max_risk, action, priority, active, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`, r.ID, r.DatasetID, r.Name, r.Field, r.Module, r.MinConfidence, r.MaxRisk, r.Action, r.Priority, r.Active, r.CreatedAt)
+		INSERT INTO approval_rules (id, org_id, dataset_id, name, field, module, min_confidence, max_risk, action, priority, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, r.ID, r.OrgID, r.DatasetID, r.Name, r.Field, r.Module, r.MinConfidence, r.MaxRisk, r.Action, r.Priority, r.Active, r.CreatedAt)
 	return err
 }
 
-func (q *Queries) ListApprovalRules(ctx context.Context, datasetID *uuid.UUID) ([]models.ApprovalRule, error) {
-	var rows pgx.Rows
-	var err error
-	
-	if datasetID != nil {
-		rows, err = q.pool.Query(ctx, `
-			SELECT id, dataset_id, name, COALESCE(field, ''), COALESCE(module, ''), min_confidence, COALESCE(max_risk, ''), action, priority, active, created_at, updated_at
-			FROM approval_rules WHERE dataset_id = $1 OR dataset_id IS NULL ORDER BY priority DESC, created_at
-		`, datasetID)
-	} else {
-		rows, err = q.pool.Query(ctx, `
-			SELECT id, dataset_id, name, COALESCE(field, ''), COALESCE(module, ''), min_confidence, COALESCE(max_risk, ''), action, priority, active, created_at, updated_at
-			FROM approval_rules ORDER BY priority DESC, created_at
-		`)
-	}
+// ListApprovalRules returns the approval rules of an organization; with a dataset, only
+// those of the dataset and the organization-wide ones
+func (q *Queries) ListApprovalRules(ctx context.Context, orgID uuid.UUID, datasetID *uuid.UUID) ([]models.ApprovalRule, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, org_id, dataset_id, name, COALESCE(field, ''), COALESCE(module, ''), min_confidence, COALESCE(max_risk, ''), action, priority, active, created_at, updated_at
+		FROM approval_rules
+		WHERE org_id = $1 AND ($2::uuid IS NULL OR dataset_id = $2 OR dataset_id IS NULL)
+		ORDER BY priority DESC, created_at
+	`, orgID, datasetID)
 	if err != nil {
 		return nil, err
 	}
@@ -721,7 +1364,7 @@ func (q *Queries) ListApprovalRules(ctx context.Context, datasetID *uuid.UUID) (
 	var rules []models.ApprovalRule
 	for rows.Next() {
 		var r models.ApprovalRule
-		if err := rows.Scan(&r.ID, &r.DatasetID, &r.Name, &r.Field, &r.Module, &r.MinConfidence, &r.MaxRisk, &r.Action, &r.Priority, &r.Active, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.OrgID, &r.DatasetID, &r.Name, &r.Field, &r.Module, &r.MinConfidence, &r.MaxRisk, &r.Action, &r.Priority, &r.Active, &r.CreatedAt, &r.UpdatedAt); err != nil {
 			return nil, err
 		}
 		rules = append(rules, r)
@@ -746,22 +1389,14 @@ func (q *Queries) DeleteApprovalRule(ctx context.Context, id uuid.UUID) error {
 
 func (q *Queries) CreateJobWithDetails(ctx context.Context, j models.JobWithDetails) error {
 	logsJSON, _ := json.Marshal(j.Logs)
-	// Try full insert with new columns first
 	_, err := q.pool.Exec(ctx, `
-		INSERT INTO jobs (id, dataset_id, type, status, module, total_items, processed_items, proposals_generated, logs, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
-	`, j.ID, j.DatasetID, j.Type, j.Status, j.Module, j.TotalItems, j.ProcessedItems, j.ProposalsGenerated, logsJSON, j.CreatedAt)
-	
-	// Fallback to basic insert if new columns don't exist yet
-	if err != nil {
-		_, err = q.pool.Exec(ctx, `
-			INSERT INTO jobs (id, dataset_id, type, status, created_at)
-			VALUES ($1, $2, $3, $4, $5)
-		`, j.ID, j.DatasetID, j.Type, j.Status, j.CreatedAt)
-	}
+		INSERT INTO jobs (id, dataset_id, type, status, module, total_items, processed_items, proposals_generated, logs, config, priority, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10, '{}'::jsonb), $11, $12, $12)
+	`, j.ID, j.DatasetID, j.Type, j.Status, j.Module, j.TotalItems, j.ProcessedItems, j.ProposalsGenerated, logsJSON, j.Config, j.Priority, j.CreatedAt)
 	return err
 }
 
+// UpdateJobProgress records the counters of a job and appends log to its logs (when not nil)
 func (q *Queries) UpdateJobProgress(ctx context.Context, jobID uuid.UUID, processed, proposals int, log *models.JobLog) error {
 	if log != nil {
 		logJSON, _ := json.Marshal(log)
@@ -773,89 +1408,66 @@ func (q *Queries) UpdateJobProgress(ctx context.Context, jobID uuid.UUID, proces
 				updated_at = NOW()
 			WHERE id = $1
 		`, jobID, processed, proposals, logJSON)
-		// Fallback if columns don't exist
-		if err != nil {
-			return nil // Silently ignore if columns missing
-		}
-		return nil
+		return err
 	}
-	_, _ = q.pool.Exec(ctx, `
+	_, err := q.pool.Exec(ctx, `
 		UPDATE jobs SET processed_items = $2, proposals_generated = $3, updated_at = NOW() WHERE id = $1
 	`, jobID, processed, proposals)
-	return nil // Don't fail if columns missing
+	return err
 }
 
 func (q *Queries) UpdateJobStatus(ctx context.Context, jobID uuid.UUID, status string, errMsg *string) error {
 	if status == "running" {
-		// Try with updated_at, fall back to basic
 		_, err := q.pool.Exec(ctx, `UPDATE jobs SET status = $2, started_at = NOW(), updated_at = NOW() WHERE id = $1`, jobID, status)
-		if err != nil {
-			_, err = q.pool.Exec(ctx, `UPDATE jobs SET status = $2, started_at = NOW() WHERE id = $1`, jobID, status)
-		}
 		return err
 	}
-	if status == "completed" || status == "failed" {
+	if status == "completed" || status == "failed" || status == "cancelled" {
 		_, err := q.pool.Exec(ctx, `UPDATE jobs SET status = $2, error = $3, completed_at = NOW(), updated_at = NOW() WHERE id = $1`, jobID, status, errMsg)
-		if err != nil {
-			_, err = q.pool.Exec(ctx, `UPDATE jobs SET status = $2, error = $3, completed_at = NOW() WHERE id = $1`, jobID, status, errMsg)
-		}
 		return err
 	}
 	_, err := q.pool.Exec(ctx, `UPDATE jobs SET status = $2, updated_at = NOW() WHERE id = $1`, jobID, status)
-	if err != nil {
-		_, err = q.pool.Exec(ctx, `UPDATE jobs SET status = $2 WHERE id = $1`, jobID, status)
-	}
 	return err
 }
 
 func (q *Queries) GetJob(ctx context.Context, id uuid.UUID) (*models.JobWithDetails, error) {
 	var j models.JobWithDetails
-	var logsJSON []byte
+	var logsJSON, reportJSON []byte
 	err := q.pool.QueryRow(ctx, `
-		SELECT id, dataset_id, type, status, COALESCE(module, ''), COALESCE(total_items, 0), COALESCE(processed_items, 0), COALESCE(proposals_generated, 0), COALESCE(logs, '[]'), error, started_at, completed_at, created_at, updated_at
+		SELECT id, dataset_id, type, status, COALESCE(config, '{}'), COALESCE(module, ''), priority, COALESCE(total_items, 0), COALESCE(processed_items, 0), COALESCE(proposals_generated, 0), COALESCE(logs, '[]'), checkpoint_product_id, report, error, started_at, completed_at, created_at, updated_at
 		FROM jobs WHERE id = $1
-	`, id).Scan(&j.ID, &j.DatasetID, &j.Type, &j.Status, &j.Module, &j.TotalItems, &j.ProcessedItems, &j.ProposalsGenerated, &logsJSON, &j.Error, &j.StartedAt, &j.CompletedAt, &j.CreatedAt, &j.UpdatedAt)
+	`, id).Scan(&j.ID, &j.DatasetID, &j.Type, &j.Status, &j.Config, &j.Module, &j.Priority, &j.TotalItems, &j.ProcessedItems, &j.ProposalsGenerated, &logsJSON, &j.CheckpointProductID, &reportJSON, &j.Error, &j.StartedAt, &j.CompletedAt, &j.CreatedAt, &j.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	json.Unmarshal(logsJSON, &j.Logs)
+	if reportJSON != nil {
+		json.Unmarshal(reportJSON, &j.Report)
+	}
 	return &j, nil
 }
 
-func (q *Queries) ListJobs(ctx context.Context, datasetID *uuid.UUID, status string, limit int) ([]models.JobWithDetails, error) {
-	// Try query with new columns first
-	query := `
-		SELECT j.id, j.dataset_id, j.type, j.status, COALESCE(j.module, ''), COALESCE(j.total_items, 0), COALESCE(j.processed_items, 0), COALESCE(j.proposals_generated, 0), COALESCE(j.logs, '[]'), j.error, j.started_at, j.completed_at, j.created_at, j.updated_at
+// UpdateJobReport stores the report of a job run
+func (q *Queries) UpdateJobReport(ctx context.Context, jobID uuid.UUID, report *models.JobReport) error {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = q.pool.Exec(ctx, `UPDATE jobs SET report = $2, updated_at = NOW() WHERE id = $1`, jobID, reportJSON)
+	return err
+}
+
+// ListJobs returns the jobs of an organization, newest first
+func (q *Queries) ListJobs(ctx context.Context, orgID uuid.UUID, datasetID *uuid.UUID, status string, limit int) ([]models.JobWithDetails, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT j.id, j.dataset_id, j.type, j.status, COALESCE(j.module, ''), j.priority, COALESCE(j.total_items, 0), COALESCE(j.processed_items, 0), COALESCE(j.proposals_generated, 0), COALESCE(j.logs, '[]'), j.error, j.started_at, j.completed_at, j.created_at, j.updated_at
 		FROM jobs j
 		WHERE ($1::uuid IS NULL OR j.dataset_id = $1)
 		AND ($2 = '' OR j.status = $2)
+		AND j.dataset_id IN (SELECT id FROM datasets WHERE org_id = $4)
 		ORDER BY j.created_at DESC LIMIT $3
-	`
-	rows, err := q.pool.Query(ctx, query, datasetID, status, limit)
-	if err != nil {
-		// Fallback to basic query if new columns don't exist
-		query = `
-			SELECT j.id, j.dataset_id, j.type, j.status, j.error, j.started_at, j.completed_at, j.created_at
-			FROM jobs j
-			WHERE ($1::uuid IS NULL OR j.dataset_id = $1)
-			AND ($2 = '' OR j.status = $2)
-			ORDER BY j.created_at DESC LIMIT $3
-		`
-		rows, err = q.pool.Query(ctx, query, datasetID, status, limit)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-		
-		var jobs []models.JobWithDetails
-		for rows.Next() {
-			var j models.JobWithDetails
-			if err := rows.Scan(&j.ID, &j.DatasetID, &j.Type, &j.Status, &j.Error, &j.StartedAt, &j.CompletedAt, &j.CreatedAt); err != nil {
-				return nil, err
-			}
-			jobs = append(jobs, j)
-		}
-		return jobs, nil
+	`, datasetID, status, limit, orgID)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -863,7 +1475,7 @@ func (q *Queries) ListJobs(ctx context.Context, datasetID *uuid.UUID, status str
 	for rows.Next() {
 		var j models.JobWithDetails
 		var logsJSON []byte
-		if err := rows.Scan(&j.ID, &j.DatasetID, &j.Type, &j.Status, &j.Module, &j.TotalItems, &j.ProcessedItems, &j.ProposalsGenerated, &logsJSON, &j.Error, &j.StartedAt, &j.CompletedAt, &j.CreatedAt, &j.UpdatedAt); err != nil {
+		if err := rows.Scan(&j.ID, &j.DatasetID, &j.Type, &j.Status, &j.Module, &j.Priority, &j.TotalItems, &j.ProcessedItems, &j.ProposalsGenerated, &logsJSON, &j.Error, &j.StartedAt, &j.CompletedAt, &j.CreatedAt, &j.UpdatedAt); err != nil {
 			return nil, err
 		}
 		json.Unmarshal(logsJSON, &j.Logs)
@@ -872,24 +1484,84 @@ func (q *Queries) ListJobs(ctx context.Context, datasetID *uuid.UUID, status str
 	return jobs, nil
 }
 
+// UpdateJobCheckpoint records the last product fully processed so a paused job can resume after it
+func (q *Queries) UpdateJobCheckpoint(ctx context.Context, jobID, productID uuid.UUID, processed, proposals int) error {
+	_, err := q.pool.Exec(ctx, `
+		UPDATE jobs SET checkpoint_product_id = $2, processed_items = $3, proposals_generated = $4, updated_at = NOW()
+		WHERE id = $1
+	`, jobID, productID, processed, proposals)
+	return err
+}
+
+// TransitionJobStatus atomically moves a job to status if its current status is one of from.
+// Returns false when the job is not in an allowed state.
+func (q *Queries) TransitionJobStatus(ctx context.Context, jobID uuid.UUID, from []string, status string) (bool, error) {
+	result, err := q.pool.Exec(ctx, `
+		UPDATE jobs SET status = $3, updated_at = NOW(),
+			completed_at = CASE WHEN $3 IN ('completed', 'failed', 'cancelled') THEN NOW() ELSE completed_at END
+		WHERE id = $1 AND status = ANY($2)
+	`, jobID, from, status)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// UpdateJobConfig replaces the config of a job (e.g. to record a submitted batch)
+func (q *Queries) UpdateJobConfig(ctx context.Context, jobID uuid.UUID, config json.RawMessage) error {
+	_, err := q.pool.Exec(ctx, `UPDATE jobs SET config = $2, updated_at = NOW() WHERE id = $1`, jobID, config)
+	return err
+}
+
+// ListBatchJobIDs returns the running jobs waiting on a submitted batch, oldest first
+func (q *Queries) ListBatchJobIDs(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id FROM jobs
+		WHERE status = 'running' AND COALESCE(config->'batch'->>'id', '') <> ''
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetJobStatus returns only the status of a job (cheap poll for workers)
+func (q *Queries) GetJobStatus(ctx context.Context, jobID uuid.UUID) (string, error) {
+	var status string
+	err := q.pool.QueryRow(ctx, `SELECT status FROM jobs WHERE id = $1`, jobID).Scan(&status)
+	return status, err
+}
+
 // ===== PROPOSALS BY MODULE =====
 
-func (q *Queries) GetProposalsByModule(ctx context.Context, datasetID *uuid.UUID) ([]models.ProposalsByModule, error) {
+func (q *Queries) GetProposalsByModule(ctx context.Context, orgID uuid.UUID, datasetID *uuid.UUID) ([]models.ProposalsByModule, error) {
 	query := `
 		SELECT 
 			COALESCE(p.module, 'unknown') as module,
 			COUNT(*) as total,
 			COUNT(*) FILTER (WHERE p.status = 'proposed') as pending,
-			COUNT(*) FILTER (WHERE p.status = 'accepted') as approved,
+			COUNT(*) FILTER (WHERE p.status IN ('accepted', 'edited')) as approved,
 			COUNT(*) FILTER (WHERE p.status = 'rejected') as rejected,
 			0 as auto_approved
 		FROM proposals p
 		JOIN products pr ON p.product_id = pr.id
-		WHERE ($1::uuid IS NULL OR pr.dataset_id = $1)
+		WHERE ($1::uuid IS NULL OR pr.dataset_id = $1) AND p.status <> 'simulated'
+		AND pr.dataset_id IN (SELECT id FROM datasets WHERE org_id = $2)
 		GROUP BY COALESCE(p.module, 'unknown')
 		ORDER BY total DESC
 	`
-	rows, err := q.pool.Query(ctx, query, datasetID)
+	rows, err := q.pool.Query(ctx, query, datasetID, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -906,43 +1578,55 @@ func (q *Queries) GetProposalsByModule(ctx context.Context, datasetID *uuid.UUID
 	return results, nil
 }
 
-func (q *Queries) ListProposalsByModule(ctx context.Context, module string, datasetID *uuid.UUID, status string, limit int) ([]models.ProposalWithProduct, error) {
-	query := `
-		SELECT p.id, p.product_id, p.session_id, p.field, p.before_value, p.after_value, p.rationale, p.sources, p.confidence, p.risk_level, p.status, p.reviewed_by, p.reviewed_at, p.created_at,
+// ListProposalsByModule returns a page of proposals with their product and dataset, and
+// the cursor of the next page (see ListProposals)
+func (q *Queries) ListProposalsByModule(ctx context.Context, f ProposalListFilter) ([]models.ProposalWithProduct, *ProposalCursor, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT p.id, p.product_id, p.session_id, p.field, p.before_value, p.after_value, COALESCE(p.rationale, '{}'), p.sources, p.confidence, p.risk_level, p.status, p.quality_score, p.reviewed_by, p.reviewed_at, p.assignee, p.review_state, p.created_at,
 			COALESCE(p.module, ''), pr.external_id, COALESCE(pr.current_data->>'title', ''), pr.dataset_id, d.name
 		FROM proposals p
 		JOIN products pr ON p.product_id = pr.id
 		JOIN datasets d ON pr.dataset_id = d.id
-		WHERE ($1 = '' OR COALESCE(p.module, '') = $1)
-		AND ($2::uuid IS NULL OR pr.dataset_id = $2)
-		AND ($3 = '' OR p.status = $3)
-		ORDER BY p.created_at DESC LIMIT $4
-	`
-	rows, err := q.pool.Query(ctx, query, module, datasetID, status, limit)
+	`+proposalPage(f.Sort), proposalPageArgs(&f)...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var proposals []models.ProposalWithProduct
+	proposals := []models.ProposalWithProduct{}
 	for rows.Next() {
 		var p models.ProposalWithProduct
-		if err := rows.Scan(&p.ID, &p.ProductID, &p.SessionID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.Rationale, &p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.ReviewedBy, &p.ReviewedAt, &p.CreatedAt,
+		if err := rows.Scan(&p.ID, &p.ProductID, &p.SessionID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.Rationale, &p.Sources, &p.Confidence, &p.RiskLevel, &p.Status, &p.QualityScore, &p.ReviewedBy, &p.ReviewedAt, &p.Assignee, &p.ReviewState, &p.CreatedAt,
 			&p.Module, &p.ProductExternalID, &p.ProductTitle, &p.DatasetID, &p.DatasetName); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		proposals = append(proposals, p)
 	}
-	return proposals, nil
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	n, next := nextProposalCursor(len(proposals), f.Limit, func(i int) ProposalCursor {
+		return ProposalCursor{Key: sortKeyOf(f.Sort, proposals[i].QualityScore), CreatedAt: proposals[i].CreatedAt, ID: proposals[i].ID}
+	})
+	return proposals[:n], next, nil
 }
 
-// ApplyApprovalRules applies rules to pending proposals and returns count of affected
-func (q *Queries) ApplyApprovalRules(ctx context.Context, datasetID *uuid.UUID) (int, error) {
+// ApplyApprovalRules applies the rules of an organization to its pending proposals (of
+// one dataset when datasetID is set) and returns count of affected. A rule set on a
+// dataset only applies to the proposals of that dataset.
+func (q *Queries) ApplyApprovalRules(ctx context.Context, orgID uuid.UUID, datasetID *uuid.UUID) (int, error) {
 	// Get active rules ordered by priority
-	rules, err := q.ListApprovalRules(ctx, datasetID)
+	rules, err := q.ListApprovalRules(ctx, orgID, datasetID)
+	if err != nil {
+		return 0, err
+	}
+
+	// All rules or none: a failing rule must not leave the ones before it applied
+	tx, err := q.pool.Begin(ctx)
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback(ctx)
 
 	totalAffected := 0
 	for _, rule := range rules {
@@ -952,12 +1636,15 @@ func (q *Queries) ApplyApprovalRules(ctx context.Context, datasetID *uuid.UUID)
 
 		// Build query based on rule criteria
 		query := `
-			UPDATE proposals SET status = $1, reviewed_at = NOW(), reviewed_by = 'rule:' || $2
-			WHERE status = 'proposed'
+			UPDATE proposals SET status = $1, reviewed_at = NOW(), reviewed_by = 'rule:' || $2, review_state = $7
+			WHERE status = 'proposed' AND assignee IS NULL AND review_state <> 'needs_info'
 			AND ($3 = '' OR field = $3)
 			AND ($4 = '' OR module = $4)
 			AND ($5::decimal = 0 OR confidence >= $5)
 			AND ($6 = '' OR risk_level = $6 OR ($6 = 'low' AND risk_level = 'low') OR ($6 = 'medium' AND risk_level IN ('low', 'medium')))
+			AND product_id IN (
+				SELECT pr.id FROM products pr JOIN datasets d ON d.id = pr.dataset_id
+				WHERE d.org_id = $8 AND ($9::uuid IS NULL OR pr.dataset_id = $9) AND ($10::uuid IS NULL OR pr.dataset_id = $10))
 		`
 		
 		newStatus := "accepted"
@@ -967,12 +1654,13 @@ func (q *Queries) ApplyApprovalRules(ctx context.Context, datasetID *uuid.UUID)
 			continue // Skip flagging rules for now
 		}
 
-		result, err := q.pool.Exec(ctx, query, newStatus, rule.Name, rule.Field, rule.Module, rule.MinConfidence, rule.MaxRisk)
+		result, err := tx.Exec(ctx, query, newStatus, rule.Name, rule.Field, rule.Module, rule.MinConfidence, rule.MaxRisk, reviewStateFor(newStatus),
+			orgID, rule.DatasetID, datasetID)
 		if err != nil {
-			return totalAffected, err
+			return 0, err
 		}
 		totalAffected += int(result.RowsAffected())
 	}
 
-	return totalAffected, nil
+	return totalAffected, tx.Commit(ctx)
 }
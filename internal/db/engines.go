@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// ===== DATASET ENGINE OPERATIONS =====
+
+// GetDatasetEngine returns the dataset's default engine ("" when none is set)
+func (q *Queries) GetDatasetEngine(ctx context.Context, datasetID uuid.UUID) (string, error) {
+	var engine string
+	err := q.pool.QueryRow(ctx, `SELECT COALESCE(engine, '') FROM datasets WHERE id = $1`, datasetID).Scan(&engine)
+	return engine, err
+}
+
+// UpdateDatasetEngine sets the dataset's default engine; "" clears it
+func (q *Queries) UpdateDatasetEngine(ctx context.Context, datasetID uuid.UUID, engine string) error {
+	_, err := q.pool.Exec(ctx, `UPDATE datasets SET engine = NULLIF($2, ''), updated_at = NOW() WHERE id = $1`, datasetID, engine)
+	return err
+}
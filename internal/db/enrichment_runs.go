@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== ENRICHMENT RUN OPERATIONS =====
+
+// dataHash fingerprints the feed data of a product. Applied proposals only change
+// current_data, so they don't make the product look changed since the run proposing them.
+const dataHash = `md5(raw_data::text)`
+
+// RecordEnrichmentRun marks a product as enriched for a module now, with a hash of its feed data.
+// A run without proposals is recorded as a "clean" verdict.
+func (q *Queries) RecordEnrichmentRun(ctx context.Context, productID uuid.UUID, module string, proposals int, score float64) error {
+	_, err := q.pool.Exec(ctx, `
+		INSERT INTO product_enrichment_runs (product_id, module, last_run_at, data_hash, proposals, outcome, score)
+		SELECT id, $2, NOW(), `+dataHash+`, $3, CASE WHEN $3 = 0 THEN 'clean' ELSE 'changes_proposed' END, $4
+		FROM products WHERE id = $1
+		ON CONFLICT (product_id, module) DO UPDATE
+		SET last_run_at = EXCLUDED.last_run_at, data_hash = EXCLUDED.data_hash, proposals = EXCLUDED.proposals,
+			outcome = EXCLUDED.outcome, score = EXCLUDED.score
+	`, productID, module, proposals, score)
+	return err
+}
+
+// ListProductOutcomes returns the last run verdict of each module run on a product
+func (q *Queries) ListProductOutcomes(ctx context.Context, productID uuid.UUID) ([]models.EnrichmentOutcome, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT product_id, module, outcome, score::float8, proposals, last_run_at
+		FROM product_enrichment_runs WHERE product_id = $1 ORDER BY module
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outcomes []models.EnrichmentOutcome
+	for rows.Next() {
+		var o models.EnrichmentOutcome
+		if err := rows.Scan(&o.ProductID, &o.Module, &o.Outcome, &o.Score, &o.Proposals, &o.LastRunAt); err != nil {
+			return nil, err
+		}
+		outcomes = append(outcomes, o)
+	}
+	return outcomes, nil
+}
+
+// GetDatasetOutcomeStats counts dataset products per module as clean, changes proposed or not run
+func (q *Queries) GetDatasetOutcomeStats(ctx context.Context, datasetID uuid.UUID) ([]models.ModuleOutcomeStats, error) {
+	var total int
+	if err := q.pool.QueryRow(ctx, `SELECT COUNT(*) FROM products WHERE dataset_id = $1`, datasetID).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.pool.Query(ctx, `
+		SELECT er.module,
+			COUNT(*) FILTER (WHERE er.outcome = 'clean'),
+			COUNT(*) FILTER (WHERE er.outcome = 'changes_proposed'),
+			(AVG(er.score) FILTER (WHERE er.outcome = 'clean'))::float8
+		FROM product_enrichment_runs er
+		JOIN products p ON p.id = er.product_id
+		WHERE p.dataset_id = $1
+		GROUP BY er.module ORDER BY er.module
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.ModuleOutcomeStats
+	for rows.Next() {
+		var s models.ModuleOutcomeStats
+		if err := rows.Scan(&s.Module, &s.Clean, &s.ChangesProposed, &s.AvgCleanScore); err != nil {
+			return nil, err
+		}
+		s.NotRun = total - s.Clean - s.ChangesProposed
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// ListStaleProducts returns dataset products already enriched for module (or with "all") whose
+// data changed since that run or whose run is older than runBefore, oldest run first.
+// Products never enriched are not stale: they are left to explicit audits.
+func (q *Queries) ListStaleProducts(ctx context.Context, datasetID uuid.UUID, module string, runBefore time.Time, limit int) ([]models.StaleProduct, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+productColumns+`, r.module, r.last_run_at,
+			CASE WHEN r.data_hash <> `+dataHash+` THEN 'data_changed' ELSE 'expired' END
+		FROM products
+		JOIN LATERAL (
+			SELECT er.module, er.last_run_at, er.data_hash FROM product_enrichment_runs er
+			WHERE er.product_id = products.id AND er.module IN ($2, 'all')
+			ORDER BY er.last_run_at DESC LIMIT 1
+		) r ON TRUE
+		WHERE dataset_id = $1 AND (r.data_hash <> `+dataHash+` OR r.last_run_at < $3)
+		ORDER BY r.last_run_at LIMIT $4
+	`, datasetID, module, runBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stale []models.StaleProduct
+	for rows.Next() {
+		var s models.StaleProduct
+		p := &s.Product
+		if err := rows.Scan(&p.ID, &p.DatasetID, &p.ExternalID, &p.RawData, &p.CurrentData, &p.Version, &p.Status, &p.AgentReadinessScore, &p.BaselineScore, &p.Tags, &p.Notes, &p.CreatedAt, &p.UpdatedAt,
+			&s.Module, &s.LastRunAt, &s.Reason); err != nil {
+			return nil, err
+		}
+		stale = append(stale, s)
+	}
+	return stale, nil
+}
+
+// HasActiveJob reports whether a dataset has a pending, running or paused job for a module
+func (q *Queries) HasActiveJob(ctx context.Context, datasetID uuid.UUID, module string) (bool, error) {
+	var exists bool
+	err := q.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM jobs WHERE dataset_id = $1 AND module = $2 AND status IN ('pending', 'running', 'paused')
+		)
+	`, datasetID, module).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// GetReviewFeedback counts the review decisions made since a time on the proposals of a
+// dataset, by field, most reviewed first. Fields with fewer than minReviews decisions are
+// left out; a reverted proposal counts as rejected.
+func (q *Queries) GetReviewFeedback(ctx context.Context, datasetID uuid.UUID, since time.Time, minReviews int) ([]models.FieldFeedback, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT p.field,
+			COUNT(*) FILTER (WHERE p.status = 'accepted'),
+			COUNT(*) FILTER (WHERE p.status = 'edited'),
+			COUNT(*) FILTER (WHERE p.status IN ('rejected', 'reverted'))
+		FROM proposals p
+		JOIN products pr ON pr.id = p.product_id
+		WHERE pr.dataset_id = $1 AND p.reviewed_at >= $2
+		AND p.status IN ('accepted', 'edited', 'rejected', 'reverted')
+		GROUP BY p.field
+		HAVING COUNT(*) >= $3
+		ORDER BY COUNT(*) DESC, p.field
+	`, datasetID, since, minReviews)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feedback := []models.FieldFeedback{}
+	for rows.Next() {
+		var f models.FieldFeedback
+		if err := rows.Scan(&f.Field, &f.Accepted, &f.Edited, &f.Rejected); err != nil {
+			return nil, err
+		}
+		feedback = append(feedback, f)
+	}
+	return feedback, rows.Err()
+}
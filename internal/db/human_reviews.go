@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== HUMAN REVIEW OPERATIONS =====
+
+// CreateHumanReviews stores escalations raised during a run
+func (q *Queries) CreateHumanReviews(ctx context.Context, reviews []models.HumanReview) error {
+	return createHumanReviews(ctx, q.pool, reviews)
+}
+
+func createHumanReviews(ctx context.Context, db execer, reviews []models.HumanReview) error {
+	if len(reviews) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, r := range reviews {
+		batch.Queue(`
+			INSERT INTO human_reviews (id, dataset_id, product_id, session_id, source, field, question, risk_level, context, options, status, created_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, NULLIF($8, ''), $9, $10, $11, $12)
+			ON CONFLICT (id) DO NOTHING
+		`, r.ID, r.DatasetID, r.ProductID, r.SessionID, r.Source, r.Field, r.Question, r.RiskLevel, r.Context, r.Options, r.Status, r.CreatedAt)
+	}
+	return db.SendBatch(ctx, batch).Close()
+}
+
+// HumanReviewFilter narrows the review queue; zero values match everything
+type HumanReviewFilter struct {
+	OrgID       uuid.UUID
+	Status      string
+	DatasetID   *uuid.UUID
+	ProductID   *uuid.UUID
+	Source      string
+	Assignee    string // "none" = unassigned
+	ReviewState string
+	Limit       int
+}
+
+const humanReviewColumns = `r.id, r.dataset_id, r.product_id, r.session_id, r.source, COALESCE(r.field, ''), r.question,
+	COALESCE(r.risk_level, ''), r.context, COALESCE(r.options, '{}'), r.status, COALESCE(r.resolution, ''),
+	COALESCE(r.resolved_by, ''), r.resolved_at, COALESCE(r.assignee, ''), r.assigned_at, r.review_state, r.created_at, pr.external_id,
+	COALESCE(pr.current_data->>'title', pr.raw_data->>'title', pr.external_id)`
+
+func scanHumanReview(row pgx.Row, r *models.HumanReview) error {
+	return row.Scan(&r.ID, &r.DatasetID, &r.ProductID, &r.SessionID, &r.Source, &r.Field, &r.Question,
+		&r.RiskLevel, &r.Context, &r.Options, &r.Status, &r.Resolution,
+		&r.ResolvedBy, &r.ResolvedAt, &r.Assignee, &r.AssignedAt, &r.ReviewState, &r.CreatedAt, &r.ProductExternalID, &r.ProductTitle)
+}
+
+// ListHumanReviews returns the review queue, oldest first so nothing starves
+func (q *Queries) ListHumanReviews(ctx context.Context, f HumanReviewFilter) ([]models.HumanReview, error) {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+humanReviewColumns+`
+		FROM human_reviews r
+		JOIN products pr ON pr.id = r.product_id
+		WHERE ($1 = '' OR r.status = $1)
+		AND ($2::uuid IS NULL OR r.dataset_id = $2)
+		AND ($3::uuid IS NULL OR r.product_id = $3)
+		AND ($4 = '' OR r.source = $4)
+		AND ($6 = '' OR ($6 = 'none' AND r.assignee IS NULL) OR r.assignee = $6)
+		AND ($7 = '' OR r.review_state = $7)
+		AND r.dataset_id IN (SELECT id FROM datasets WHERE org_id = $8)
+		ORDER BY r.created_at LIMIT $5
+	`, f.Status, f.DatasetID, f.ProductID, f.Source, f.Limit, f.Assignee, f.ReviewState, f.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.HumanReview{}
+	for rows.Next() {
+		var r models.HumanReview
+		if err := scanHumanReview(rows, &r); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
+
+func (q *Queries) GetHumanReview(ctx context.Context, id uuid.UUID) (*models.HumanReview, error) {
+	var r models.HumanReview
+	err := scanHumanReview(q.pool.QueryRow(ctx, `
+		SELECT `+humanReviewColumns+`
+		FROM human_reviews r
+		JOIN products pr ON pr.id = r.product_id
+		WHERE r.id = $1
+	`, id), &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// UpdateHumanReview sets a review's status and resolution, closing its review state;
+// moving it back to pending clears the resolution and reopens it
+func (q *Queries) UpdateHumanReview(ctx context.Context, id uuid.UUID, status, resolution, resolvedBy string) error {
+	tag, err := q.pool.Exec(ctx, `
+		UPDATE human_reviews SET
+			status = $2,
+			resolution = CASE WHEN $2 = 'pending' THEN NULL ELSE NULLIF($3, '') END,
+			resolved_by = CASE WHEN $2 = 'pending' THEN NULL ELSE NULLIF($4, '') END,
+			resolved_at = CASE WHEN $2 = 'pending' THEN NULL ELSE NOW() END,
+			review_state = CASE WHEN $2 <> 'pending' THEN $5
+				WHEN review_state IN ('approved', 'rejected') THEN 'pending'
+				ELSE review_state END
+		WHERE id = $1
+	`, id, status, resolution, resolvedBy, reviewStateFor(status))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// CountPendingHumanReviews returns the queue size, optionally for one dataset
+func (q *Queries) CountPendingHumanReviews(ctx context.Context, datasetID *uuid.UUID) (int, error) {
+	var n int
+	err := q.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM human_reviews WHERE status = 'pending' AND ($1::uuid IS NULL OR dataset_id = $1)
+	`, datasetID).Scan(&n)
+	return n, err
+}
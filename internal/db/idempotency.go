@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// ===== IDEMPOTENCY OPERATIONS =====
+
+// ClaimIdempotencyKey reserves a key for a request. When the key is already taken (and
+// not expired) it returns the existing record and claimed=false.
+func (q *Queries) ClaimIdempotencyKey(ctx context.Context, scope, key, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, bool, error) {
+	tag, err := q.pool.Exec(ctx, `
+		INSERT INTO idempotency_keys (scope, key, request_hash, status, created_at, expires_at)
+		VALUES ($1, $2, $3, 'in_progress', NOW(), NOW() + $4 * INTERVAL '1 second')
+		ON CONFLICT (scope, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status = 'in_progress', status_code = NULL,
+			response = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()
+	`, scope, key, requestHash, int64(ttl.Seconds()))
+	if err != nil {
+		return nil, false, err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+
+	var r models.IdempotencyRecord
+	var statusCode *int
+	err = q.pool.QueryRow(ctx, `
+		SELECT scope, key, request_hash, status, status_code, response, created_at, expires_at
+		FROM idempotency_keys WHERE scope = $1 AND key = $2
+	`, scope, key).Scan(&r.Scope, &r.Key, &r.RequestHash, &r.Status, &statusCode, &r.Response, &r.CreatedAt, &r.ExpiresAt)
+	if err != nil {
+		return nil, false, err
+	}
+	if statusCode != nil {
+		r.StatusCode = *statusCode
+	}
+	return &r, false, nil
+}
+
+// CompleteIdempotencyKey stores the response replayed for later requests with the key
+func (q *Queries) CompleteIdempotencyKey(ctx context.Context, scope, key string, statusCode int, response []byte) error {
+	_, err := q.pool.Exec(ctx, `
+		UPDATE idempotency_keys SET status = 'completed', status_code = $3, response = $4
+		WHERE scope = $1 AND key = $2
+	`, scope, key, statusCode, response)
+	return err
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed so the client can retry
+func (q *Queries) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	_, err := q.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`, scope, key)
+	return err
+}
+
+// PurgeExpiredIdempotencyKeys deletes expired keys
+func (q *Queries) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := q.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== IMAGE CHECK OPERATIONS =====
+
+// SaveImageCheck records the last check of a product's image_link
+func (q *Queries) SaveImageCheck(ctx context.Context, c *models.ImageCheck) error {
+	_, err := q.pool.Exec(ctx, `
+		INSERT INTO image_checks (product_id, dataset_id, url, status_code, content_type, final_url, redirects, problem, detail, checked_at)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, ''), NULLIF($9, ''), $10)
+		ON CONFLICT (product_id) DO UPDATE SET
+			url = EXCLUDED.url, status_code = EXCLUDED.status_code, content_type = EXCLUDED.content_type,
+			final_url = EXCLUDED.final_url, redirects = EXCLUDED.redirects, problem = EXCLUDED.problem,
+			detail = EXCLUDED.detail, checked_at = EXCLUDED.checked_at
+	`, c.ProductID, c.DatasetID, c.URL, c.StatusCode, c.ContentType, c.FinalURL, c.Redirects, c.Problem, c.Detail, c.CheckedAt)
+	return err
+}
+
+const imageCheckColumns = `c.product_id, p.external_id, c.dataset_id, c.url, COALESCE(c.status_code, 0),
+	COALESCE(c.content_type, ''), COALESCE(c.final_url, ''), c.redirects, COALESCE(c.problem, ''),
+	COALESCE(c.detail, ''), c.checked_at`
+
+func scanImageCheck(row interface{ Scan(...any) error }) (models.ImageCheck, error) {
+	var c models.ImageCheck
+	err := row.Scan(&c.ProductID, &c.ExternalID, &c.DatasetID, &c.URL, &c.StatusCode, &c.ContentType,
+		&c.FinalURL, &c.Redirects, &c.Problem, &c.Detail, &c.CheckedAt)
+	return c, err
+}
+
+// GetImageCheck returns the last check of a product's image_link; nil when it was never
+// checked
+func (q *Queries) GetImageCheck(ctx context.Context, productID uuid.UUID) (*models.ImageCheck, error) {
+	c, err := scanImageCheck(q.pool.QueryRow(ctx, `
+		SELECT `+imageCheckColumns+`
+		FROM image_checks c JOIN products p ON p.id = c.product_id
+		WHERE c.product_id = $1
+	`, productID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetImageCheckReport counts the checked products of a dataset by problem and lists the
+// ones with a problem, by external ID
+func (q *Queries) GetImageCheckReport(ctx context.Context, datasetID uuid.UUID) (*models.ImageCheckReport, error) {
+	report := &models.ImageCheckReport{DatasetID: datasetID, ByProblem: map[string]int{}, Problems: []models.ImageCheck{}}
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+imageCheckColumns+`
+		FROM image_checks c JOIN products p ON p.id = c.product_id
+		WHERE c.dataset_id = $1 AND c.problem IS NOT NULL
+		ORDER BY p.external_id
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c, err := scanImageCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		report.ByProblem[c.Problem]++
+		report.Problems = append(report.Problems, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = q.pool.QueryRow(ctx, `SELECT COUNT(*) FROM image_checks WHERE dataset_id = $1`, datasetID).Scan(&report.Products)
+	return report, err
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== IMAGE HASH OPERATIONS =====
+
+// SaveImageHashes replaces the perceptual hashes of a product's images (by URL)
+func (q *Queries) SaveImageHashes(ctx context.Context, productID, datasetID uuid.UUID, itemGroupID string, hashes map[string]int64) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM image_hashes WHERE product_id = $1`, productID)
+	for url, hash := range hashes {
+		batch.Queue(`
+			INSERT INTO image_hashes (product_id, url, dataset_id, item_group_id, hash)
+			VALUES ($1, $2, $3, $4, $5)
+		`, productID, url, datasetID, itemGroupID, hash)
+	}
+	return q.pool.SendBatch(ctx, batch).Close()
+}
+
+// CountSharedImages counts, for each hash, the other item groups of the dataset showing the
+// same image. Products without item_group_id count as their own group; the product's own
+// group is left out, its variants may share a photo.
+func (q *Queries) CountSharedImages(ctx context.Context, datasetID, productID uuid.UUID, itemGroupID string, hashes []int64) (map[int64]int, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT hash, COUNT(DISTINCT COALESCE(NULLIF(item_group_id, ''), product_id::text))
+		FROM image_hashes
+		WHERE dataset_id = $1 AND hash = ANY($4) AND product_id <> $2
+			AND ($3 = '' OR item_group_id <> $3)
+		GROUP BY hash
+	`, datasetID, productID, itemGroupID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shared := map[int64]int{}
+	for rows.Next() {
+		var hash int64
+		var n int
+		if err := rows.Scan(&hash, &n); err != nil {
+			return nil, err
+		}
+		shared[hash] = n
+	}
+	return shared, rows.Err()
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== JOB FAILURE OPERATIONS =====
+
+// RecordJobFailure moves a product to the dead-letter of a job (replacing an earlier entry)
+func (q *Queries) RecordJobFailure(ctx context.Context, f models.JobFailure) error {
+	_, err := q.pool.Exec(ctx, `
+		INSERT INTO job_failures (id, job_id, product_id, attempts, error, error_kind, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'dead_letter', NOW(), NOW())
+		ON CONFLICT (job_id, product_id) DO UPDATE
+		SET attempts = job_failures.attempts + EXCLUDED.attempts, error = EXCLUDED.error,
+			error_kind = EXCLUDED.error_kind, status = 'dead_letter', requeued_job_id = NULL, updated_at = NOW()
+	`, f.ID, f.JobID, f.ProductID, f.Attempts, f.Error, f.ErrorKind)
+	return err
+}
+
+// ListJobFailures returns the failures of a job, optionally with one status
+func (q *Queries) ListJobFailures(ctx context.Context, jobID uuid.UUID, status string) ([]models.JobFailure, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT f.id, f.job_id, f.product_id, COALESCE(p.external_id, ''), f.attempts, f.error, f.error_kind,
+			f.status, f.requeued_job_id, f.created_at, f.updated_at
+		FROM job_failures f
+		JOIN products p ON p.id = f.product_id
+		WHERE f.job_id = $1 AND ($2 = '' OR f.status = $2)
+		ORDER BY f.updated_at DESC
+	`, jobID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []models.JobFailure
+	for rows.Next() {
+		var f models.JobFailure
+		if err := rows.Scan(&f.ID, &f.JobID, &f.ProductID, &f.ExternalID, &f.Attempts, &f.Error, &f.ErrorKind,
+			&f.Status, &f.RequeuedJobID, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, nil
+}
+
+// MarkJobFailuresRequeued links dead-lettered products of a job to the job retrying them
+func (q *Queries) MarkJobFailuresRequeued(ctx context.Context, jobID uuid.UUID, productIDs []uuid.UUID, requeuedJobID uuid.UUID) error {
+	_, err := q.pool.Exec(ctx, `
+		UPDATE job_failures SET status = 'requeued', requeued_job_id = $3, updated_at = NOW()
+		WHERE job_id = $1 AND product_id = ANY($2) AND status = 'dead_letter'
+	`, jobID, productIDs, requeuedJobID)
+	return err
+}
+
+// ResolveJobFailures marks open failures of a product resolved after a successful run
+func (q *Queries) ResolveJobFailures(ctx context.Context, productID uuid.UUID) error {
+	_, err := q.pool.Exec(ctx, `
+		UPDATE job_failures SET status = 'resolved', updated_at = NOW()
+		WHERE product_id = $1 AND status IN ('dead_letter', 'requeued')
+	`, productID)
+	return err
+}
+
+// ListProductsByIDs returns the given products in creation order
+func (q *Queries) ListProductsByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Product, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+productColumns+` FROM products WHERE id = ANY($1) ORDER BY created_at
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== LLM CALL LOG OPERATIONS =====
+
+// CreateLLMCalls stores the call log of a session
+func (q *Queries) CreateLLMCalls(ctx context.Context, calls []models.LLMCall) error {
+	return createLLMCalls(ctx, q.pool, calls)
+}
+
+func createLLMCalls(ctx context.Context, db execer, calls []models.LLMCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, c := range calls {
+		batch.Queue(`
+			INSERT INTO llm_calls (id, session_id, model, request, response, error, latency_ms, prompt_tokens, completion_tokens, created_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10)
+			ON CONFLICT (id) DO NOTHING
+		`, c.ID, c.SessionID, c.Model, c.Request, c.Response, c.Error, c.LatencyMs, c.PromptTokens, c.CompletionTokens, c.CreatedAt)
+	}
+	return db.SendBatch(ctx, batch).Close()
+}
+
+// ListLLMCalls returns the call log of a session in call order
+func (q *Queries) ListLLMCalls(ctx context.Context, sessionID uuid.UUID) ([]models.LLMCall, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, session_id, model, request, COALESCE(response, ''), COALESCE(error, ''), latency_ms, prompt_tokens, completion_tokens, created_at
+		FROM llm_calls WHERE session_id = $1 ORDER BY created_at
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calls := []models.LLMCall{}
+	for rows.Next() {
+		var c models.LLMCall
+		if err := rows.Scan(&c.ID, &c.SessionID, &c.Model, &c.Request, &c.Response, &c.Error, &c.LatencyMs, &c.PromptTokens, &c.CompletionTokens, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== MODEL PRICING OPERATIONS =====
+
+// ListModelPrices returns every price, grouped by model, newest first
+func (q *Queries) ListModelPrices(ctx context.Context) ([]models.ModelPrice, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, model, input_per_million, output_per_million, effective_from, COALESCE(note, ''), created_at
+		FROM model_pricing
+		ORDER BY model, effective_from DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []models.ModelPrice{}
+	for rows.Next() {
+		var p models.ModelPrice
+		if err := rows.Scan(&p.ID, &p.Model, &p.InputPerMillion, &p.OutputPerMillion, &p.EffectiveFrom, &p.Note, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+// UpsertModelPrice sets a model's price from a date on, replacing one already set for that date
+func (q *Queries) UpsertModelPrice(ctx context.Context, p *models.ModelPrice) error {
+	return q.pool.QueryRow(ctx, `
+		INSERT INTO model_pricing (model, input_per_million, output_per_million, effective_from, note)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (model, effective_from) DO UPDATE SET
+			input_per_million = EXCLUDED.input_per_million,
+			output_per_million = EXCLUDED.output_per_million,
+			note = EXCLUDED.note
+		RETURNING id, created_at
+	`, p.Model, p.InputPerMillion, p.OutputPerMillion, p.EffectiveFrom, p.Note).Scan(&p.ID, &p.CreatedAt)
+}
+
+func (q *Queries) DeleteModelPrice(ctx context.Context, id uuid.UUID) error {
+	tag, err := q.pool.Exec(ctx, `DELETE FROM model_pricing WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
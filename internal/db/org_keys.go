@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ===== ORGANIZATION PROVIDER KEYS =====
+
+// sealedPrefix marks the provider keys stored encrypted; values without it predate
+// ORG_KEYS_ENCRYPTION_KEY and are read as is until SealOrganizationKeys encrypts them
+const sealedPrefix = "enc:v1:"
+
+// ErrNoEncryptionKey is returned when an organization provider key would be stored without
+// ORG_KEYS_ENCRYPTION_KEY to encrypt it
+var ErrNoEncryptionKey = errors.New("ORG_KEYS_ENCRYPTION_KEY is not set")
+
+// keyCipher returns the AES-256-GCM cipher of ORG_KEYS_ENCRYPTION_KEY, nil when it is
+// unset (config.Load checks its size)
+func keyCipher(encoded string) cipher.AEAD {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil
+	}
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+// sealKey encrypts a provider key for its column, which authenticates it so a value can't
+// be moved to the other column; an empty key stays empty (not set)
+func (q *Queries) sealKey(column, plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	if q.keys == nil {
+		return "", ErrNoEncryptionKey
+	}
+	nonce := make([]byte, q.keys.NonceSize())
+	rand.Read(nonce)
+	sealed := q.keys.Seal(nonce, nonce, []byte(plain), []byte(column))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openKey decrypts a provider key stored by sealKey
+func (q *Queries) openKey(column, stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, nil
+	}
+	if q.keys == nil {
+		return "", fmt.Errorf("decrypt %s: %w", column, ErrNoEncryptionKey)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < q.keys.NonceSize() {
+		return "", fmt.Errorf("decrypt %s: malformed value", column)
+	}
+	n := q.keys.NonceSize()
+	plain, err := q.keys.Open(nil, sealed[:n], sealed[n:], []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s: %w", column, err)
+	}
+	return string(plain), nil
+}
+
+// SealOrganizationKeys encrypts the provider keys stored in clear before
+// ORG_KEYS_ENCRYPTION_KEY was set and returns how many organizations it updated;
+// ErrNoEncryptionKey, with their number, when some are in clear and the key is unset
+func (q *Queries) SealOrganizationKeys(ctx context.Context) (int, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, COALESCE(openai_api_key, ''), COALESCE(brave_api_key, '') FROM organizations
+		WHERE openai_api_key NOT LIKE $1 || '%' OR brave_api_key NOT LIKE $1 || '%'
+	`, sealedPrefix)
+	if err != nil {
+		return 0, err
+	}
+	type clearKeys struct {
+		id            uuid.UUID
+		openai, brave string
+	}
+	var orgs []clearKeys
+	for rows.Next() {
+		var o clearKeys
+		if err := rows.Scan(&o.id, &o.openai, &o.brave); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		orgs = append(orgs, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(orgs) > 0 && q.keys == nil {
+		return len(orgs), ErrNoEncryptionKey
+	}
+
+	for i, o := range orgs {
+		openai, brave := o.openai, o.brave
+		if !strings.HasPrefix(openai, sealedPrefix) {
+			if openai, err = q.sealKey("openai_api_key", openai); err != nil {
+				return i, err
+			}
+		}
+		if !strings.HasPrefix(brave, sealedPrefix) {
+			if brave, err = q.sealKey("brave_api_key", brave); err != nil {
+				return i, err
+			}
+		}
+		if _, err := q.pool.Exec(ctx, `
+			UPDATE organizations SET openai_api_key = NULLIF($2, ''), brave_api_key = NULLIF($3, '')
+			WHERE id = $1 AND COALESCE(openai_api_key, '') = $4 AND COALESCE(brave_api_key, '') = $5
+		`, o.id, openai, brave, o.openai, o.brave); err != nil {
+			return i, err
+		}
+	}
+	return len(orgs), nil
+}
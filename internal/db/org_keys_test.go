@@ -0,0 +1,45 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealKey(t *testing.T) {
+	q := &Queries{keys: keyCipher(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))}
+	other := &Queries{keys: keyCipher(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)))}
+
+	sealed, err := q.sealKey("openai_api_key", "sk-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, sealedPrefix) || strings.Contains(sealed, "sk-secret") {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	if plain, err := q.openKey("openai_api_key", sealed); err != nil || plain != "sk-secret" {
+		t.Fatalf("openKey = %q, %v", plain, err)
+	}
+	if _, err := q.openKey("brave_api_key", sealed); err == nil {
+		t.Fatal("opened a key sealed for another column")
+	}
+	if _, err := other.openKey("openai_api_key", sealed); err == nil {
+		t.Fatal("opened a key sealed with another key")
+	}
+	if _, err := (&Queries{}).openKey("openai_api_key", sealed); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Fatalf("err = %v, want ErrNoEncryptionKey", err)
+	}
+
+	// Keys stored before the encryption key was set are read in clear
+	if plain, err := q.openKey("openai_api_key", "sk-legacy"); err != nil || plain != "sk-legacy" {
+		t.Fatalf("openKey = %q, %v", plain, err)
+	}
+	if sealed, err := (&Queries{}).sealKey("openai_api_key", ""); err != nil || sealed != "" {
+		t.Fatalf("sealKey of no key = %q, %v", sealed, err)
+	}
+	if _, err := (&Queries{}).sealKey("openai_api_key", "sk-secret"); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Fatalf("err = %v, want ErrNoEncryptionKey", err)
+	}
+}
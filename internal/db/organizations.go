@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== ORGANIZATION OPERATIONS =====
+
+// DefaultOrganization is the slug of the organization the data predating organizations
+// belongs to, and the one requests act for when none is selected
+const DefaultOrganization = "default"
+
+const organizationColumns = `id, name, slug, COALESCE(openai_api_key, ''), COALESCE(brave_api_key, ''), budget, created_at, updated_at`
+
+func (q *Queries) scanOrganization(row pgx.Row) (*models.Organization, error) {
+	var o models.Organization
+	var openai, brave string
+	var budget []byte
+	if err := row.Scan(&o.ID, &o.Name, &o.Slug, &openai, &brave, &budget, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if o.OpenAIAPIKey, err = q.openKey("openai_api_key", openai); err != nil {
+		return nil, err
+	}
+	if o.BraveAPIKey, err = q.openKey("brave_api_key", brave); err != nil {
+		return nil, err
+	}
+	if len(budget) > 0 {
+		if err := json.Unmarshal(budget, &o.Budget); err != nil {
+			return nil, err
+		}
+	}
+	o.HasOpenAIKey, o.HasBraveKey = o.OpenAIAPIKey != "", o.BraveAPIKey != ""
+	return &o, nil
+}
+
+// CreateOrganization adds an organization, pgx.ErrNoRows when its slug is taken and
+// ErrNoEncryptionKey when it has provider keys but ORG_KEYS_ENCRYPTION_KEY is unset
+func (q *Queries) CreateOrganization(ctx context.Context, o *models.Organization) error {
+	openai, brave, err := q.sealKeys(o)
+	if err != nil {
+		return err
+	}
+	budget, _ := json.Marshal(o.Budget)
+	return q.pool.QueryRow(ctx, `
+		INSERT INTO organizations (name, slug, openai_api_key, brave_api_key, budget)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		ON CONFLICT (slug) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`, o.Name, o.Slug, openai, brave, budget).Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+}
+
+// sealKeys encrypts the provider keys of an organization for storage
+func (q *Queries) sealKeys(o *models.Organization) (openai, brave string, err error) {
+	if openai, err = q.sealKey("openai_api_key", o.OpenAIAPIKey); err != nil {
+		return "", "", err
+	}
+	if brave, err = q.sealKey("brave_api_key", o.BraveAPIKey); err != nil {
+		return "", "", err
+	}
+	return openai, brave, nil
+}
+
+// GetOrganization returns an organization by ID or slug
+func (q *Queries) GetOrganization(ctx context.Context, idOrSlug string) (*models.Organization, error) {
+	if id, err := uuid.Parse(idOrSlug); err == nil {
+		return q.scanOrganization(q.pool.QueryRow(ctx, `SELECT `+organizationColumns+` FROM organizations WHERE id = $1`, id))
+	}
+	return q.scanOrganization(q.pool.QueryRow(ctx, `SELECT `+organizationColumns+` FROM organizations WHERE slug = $1`, idOrSlug))
+}
+
+// GetDatasetOrganization returns the organization a dataset belongs to
+func (q *Queries) GetDatasetOrganization(ctx context.Context, datasetID uuid.UUID) (*models.Organization, error) {
+	return q.scanOrganization(q.pool.QueryRow(ctx, `
+		SELECT `+organizationColumns+` FROM organizations
+		WHERE id = (SELECT org_id FROM datasets WHERE id = $1)
+	`, datasetID))
+}
+
+// ListOrganizations returns every organization by name
+func (q *Queries) ListOrganizations(ctx context.Context) ([]models.Organization, error) {
+	rows, err := q.pool.Query(ctx, `SELECT `+organizationColumns+` FROM organizations ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		o, err := q.scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, *o)
+	}
+	return orgs, rows.Err()
+}
+
+// UpdateOrganization replaces the name, provider keys and budget of an organization,
+// ErrNoEncryptionKey when it has provider keys but ORG_KEYS_ENCRYPTION_KEY is unset
+func (q *Queries) UpdateOrganization(ctx context.Context, o *models.Organization) error {
+	openai, brave, err := q.sealKeys(o)
+	if err != nil {
+		return err
+	}
+	budget, _ := json.Marshal(o.Budget)
+	tag, err := q.pool.Exec(ctx, `
+		UPDATE organizations SET name = $2, openai_api_key = NULLIF($3, ''), brave_api_key = NULLIF($4, ''),
+			budget = $5, updated_at = NOW()
+		WHERE id = $1
+	`, o.ID, o.Name, openai, brave, budget)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// GetOrganizationSpend returns what the datasets of an organization spent today and this month
+func (q *Queries) GetOrganizationSpend(ctx context.Context, orgID uuid.UUID) (models.BudgetSpend, error) {
+	var spend models.BudgetSpend
+	err := q.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(cost_usd) FILTER (WHERE date = CURRENT_DATE), 0), COALESCE(SUM(cost_usd), 0)
+		FROM token_usage_attribution
+		WHERE date >= date_trunc('month', CURRENT_DATE)
+		AND dataset_id IN (SELECT id FROM datasets WHERE org_id = $1)
+	`, orgID).Scan(&spend.TodayUSD, &spend.MonthUSD)
+	return spend, err
+}
+
+// ownerQueries return the organization of a record by ID, per kind of record
+var ownerQueries = map[string]string{
+	"dataset":        `SELECT org_id FROM datasets WHERE id = $1`,
+	"product":        `SELECT d.org_id FROM products p JOIN datasets d ON d.id = p.dataset_id WHERE p.id = $1`,
+	"snapshot":       `SELECT d.org_id FROM dataset_snapshots s JOIN datasets d ON d.id = s.dataset_id WHERE s.id = $1`,
+	"segment":        `SELECT d.org_id FROM segments s JOIN datasets d ON d.id = s.dataset_id WHERE s.id = $1`,
+	"brand_alias":    `SELECT d.org_id FROM brand_aliases a JOIN datasets d ON d.id = a.dataset_id WHERE a.id = $1`,
+	"trusted_domain": `SELECT d.org_id FROM trusted_domains t JOIN datasets d ON d.id = t.dataset_id WHERE t.id = $1`,
+	"session": `SELECT d.org_id FROM agent_sessions s JOIN products p ON p.id = s.product_id
+		JOIN datasets d ON d.id = p.dataset_id WHERE s.id = $1`,
+	"job": `SELECT d.org_id FROM jobs j JOIN datasets d ON d.id = j.dataset_id WHERE j.id = $1`,
+	"proposal": `SELECT d.org_id FROM proposals pp JOIN products p ON p.id = pp.product_id
+		JOIN datasets d ON d.id = p.dataset_id WHERE pp.id = $1`,
+	"review_sample": `SELECT d.org_id FROM review_samples s JOIN datasets d ON d.id = s.dataset_id WHERE s.id = $1`,
+	"human_review": `SELECT d.org_id FROM human_reviews r JOIN products p ON p.id = r.product_id
+		JOIN datasets d ON d.id = p.dataset_id WHERE r.id = $1`,
+	"approval_rule": `SELECT org_id FROM approval_rules WHERE id = $1`,
+	"rule":          `SELECT org_id FROM rules WHERE id = $1`,
+	"api_key":       `SELECT org_id FROM api_keys WHERE id = $1`,
+	"user":          `SELECT org_id FROM users WHERE id = $1`,
+}
+
+// RecordOrganization returns the organization a record of the given kind belongs to
+// (dataset, product, proposal, job...), or pgx.ErrNoRows when there is no such record
+func (q *Queries) RecordOrganization(ctx context.Context, kind string, id uuid.UUID) (uuid.UUID, error) {
+	query, ok := ownerQueries[kind]
+	if !ok {
+		return uuid.Nil, fmt.Errorf("unknown record kind %q", kind)
+	}
+	var orgID uuid.UUID
+	err := q.pool.QueryRow(ctx, query, id).Scan(&orgID)
+	return orgID, err
+}
+
+// ===== USER OPERATIONS =====
+
+// CreateUser adds a member to an organization, pgx.ErrNoRows when the email is taken
+// in that organization
+func (q *Queries) CreateUser(ctx context.Context, u *models.User) error {
+	return q.pool.QueryRow(ctx, `
+		INSERT INTO users (org_id, email, name) VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, email) DO NOTHING
+		RETURNING id, created_at
+	`, u.OrgID, u.Email, u.Name).Scan(&u.ID, &u.CreatedAt)
+}
+
+// ListUsers returns the members of an organization by email
+func (q *Queries) ListUsers(ctx context.Context, orgID uuid.UUID) ([]models.User, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, org_id, email, name, created_at FROM users WHERE org_id = $1 ORDER BY email
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.OrgID, &u.Email, &u.Name, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// DeleteUser removes a member and revokes their API keys (deleted with them)
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	tag, err := q.pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== PIPELINE RUN OPERATIONS =====
+
+// CreatePipelineRun stores the audit trail of a pipeline session; nil is a no-op
+func (q *Queries) CreatePipelineRun(ctx context.Context, run *models.PipelineRun) error {
+	return createPipelineRun(ctx, q.pool, run)
+}
+
+func createPipelineRun(ctx context.Context, db execer, run *models.PipelineRun) error {
+	if run == nil {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		INSERT INTO pipeline_runs (id, session_id, product_id, engine, vertical, started_at, completed_at, summary, evidence_trail, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO NOTHING
+	`, run.ID, run.SessionID, run.ProductID, run.Engine, run.Vertical, run.StartedAt, run.CompletedAt, run.Summary, run.EvidenceTrail, run.CreatedAt)
+	for i, s := range run.Stages {
+		batch.Queue(`
+			INSERT INTO pipeline_stages (run_id, position, stage, started_at, ended_at, duration_ms, retries, output, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
+			ON CONFLICT DO NOTHING
+		`, run.ID, i, s.Stage, s.StartedAt, s.EndedAt, s.DurationMs, s.Retries, s.Output, s.Error)
+	}
+	for i, r := range run.Rejections {
+		batch.Queue(`
+			INSERT INTO pipeline_rejections (run_id, position, field, reason, evidence, stage)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+			ON CONFLICT DO NOTHING
+		`, run.ID, i, r.Field, r.Reason, r.Evidence, r.Stage)
+	}
+	// A proposal that wasn't saved leaves its facts unlinked
+	for i, f := range run.RetrievedFacts {
+		batch.Queue(`
+			INSERT INTO retrieved_facts (run_id, position, proposal_id, field, value, source, url, evidence, confidence, trusted)
+			VALUES ($1, $2, (SELECT id FROM proposals WHERE id = $3), $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT DO NOTHING
+		`, run.ID, i, f.ProposalID, f.Field, f.Value, f.Source, f.URL, f.Evidence, f.Confidence, f.Trusted)
+	}
+	return db.SendBatch(ctx, batch).Close()
+}
+
+// GetPipelineRun returns the pipeline trail of a session, with its stages, rejections and
+// retrieved facts in run order (pgx.ErrNoRows when the session did not run a pipeline)
+func (q *Queries) GetPipelineRun(ctx context.Context, sessionID uuid.UUID) (*models.PipelineRun, error) {
+	run := models.PipelineRun{Stages: []models.PipelineStage{}, Rejections: []models.PipelineRejection{}, RetrievedFacts: []models.RetrievedFact{}}
+	err := q.pool.QueryRow(ctx, `
+		SELECT id, session_id, product_id, engine, vertical, started_at, completed_at, summary, evidence_trail, created_at
+		FROM pipeline_runs WHERE session_id = $1
+	`, sessionID).Scan(&run.ID, &run.SessionID, &run.ProductID, &run.Engine, &run.Vertical, &run.StartedAt, &run.CompletedAt, &run.Summary, &run.EvidenceTrail, &run.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.pool.Query(ctx, `
+		SELECT stage, started_at, ended_at, duration_ms, retries, output, COALESCE(error, '')
+		FROM pipeline_stages WHERE run_id = $1 ORDER BY position
+	`, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s models.PipelineStage
+		if err := rows.Scan(&s.Stage, &s.StartedAt, &s.EndedAt, &s.DurationMs, &s.Retries, &s.Output, &s.Error); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		run.Stages = append(run.Stages, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.pool.Query(ctx, `
+		SELECT field, reason, COALESCE(evidence, ''), stage
+		FROM pipeline_rejections WHERE run_id = $1 ORDER BY position
+	`, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.PipelineRejection
+		if err := rows.Scan(&r.Field, &r.Reason, &r.Evidence, &r.Stage); err != nil {
+			return nil, err
+		}
+		run.Rejections = append(run.Rejections, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	run.RetrievedFacts, err = q.queryRetrievedFacts(ctx, `
+		SELECT proposal_id, field, value, source, url, evidence, confidence, trusted
+		FROM retrieved_facts WHERE run_id = $1 ORDER BY position
+	`, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListProposalFacts returns the retrieved facts backing a proposal, in run order
+func (q *Queries) ListProposalFacts(ctx context.Context, proposalID uuid.UUID) ([]models.RetrievedFact, error) {
+	return q.queryRetrievedFacts(ctx, `
+		SELECT proposal_id, field, value, source, url, evidence, confidence, trusted
+		FROM retrieved_facts WHERE proposal_id = $1 ORDER BY position
+	`, proposalID)
+}
+
+func (q *Queries) queryRetrievedFacts(ctx context.Context, query string, args ...any) ([]models.RetrievedFact, error) {
+	rows, err := q.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	facts := []models.RetrievedFact{}
+	for rows.Next() {
+		var f models.RetrievedFact
+		if err := rows.Scan(&f.ProposalID, &f.Field, &f.Value, &f.Source, &f.URL, &f.Evidence, &f.Confidence, &f.Trusted); err != nil {
+			return nil, err
+		}
+		facts = append(facts, f)
+	}
+	return facts, rows.Err()
+}
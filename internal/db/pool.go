@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// timeoutPool gives the queries run on the pool a deadline when their context has none,
+// so a runaway query releases its connection instead of holding it until the request is
+// abandoned. Statements inside a transaction use the caller's context and are bounded by
+// the server-side statement_timeout set in Connect.
+type timeoutPool struct {
+	*pgxpool.Pool
+	timeout time.Duration // 0 = none
+}
+
+func (p *timeoutPool) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.timeout)
+}
+
+func (p *timeoutPool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return p.Pool.Exec(ctx, sql, args...)
+}
+
+func (p *timeoutPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	rows, err := p.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timeoutRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (p *timeoutPool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	ctx, cancel := p.withTimeout(ctx)
+	return &timeoutRow{row: p.Pool.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+func (p *timeoutPool) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return p.Pool.CopyFrom(ctx, table, columns, src)
+}
+
+func (p *timeoutPool) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	ctx, cancel := p.withTimeout(ctx)
+	return &timeoutBatch{BatchResults: p.Pool.SendBatch(ctx, b), cancel: cancel}
+}
+
+// Begin bounds waiting for a connection and the BEGIN itself; the transaction outlives it
+func (p *timeoutPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return p.Pool.Begin(ctx)
+}
+
+// timeoutRows releases the deadline of a query once its rows are closed
+type timeoutRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+type timeoutRow struct {
+	row    pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.row.Scan(dest...)
+}
+
+type timeoutBatch struct {
+	pgx.BatchResults
+	cancel context.CancelFunc
+}
+
+func (b *timeoutBatch) Close() error {
+	defer b.cancel()
+	return b.BatchResults.Close()
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== PRODUCT SEARCH =====
+
+// ProductMatch is a product found by SearchProducts
+type ProductMatch struct {
+	models.Product
+	Match string  `json:"match"` // external_id, gtin, text (title or description) or similar (a word of the title)
+	Rank  float64 `json:"rank"`
+}
+
+// The expressions below are the ones indexed by migration 047: keep them identical
+const (
+	productText      = `to_tsvector('simple', COALESCE(current_data->>'title', '') || ' ' || COALESCE(current_data->>'description', ''))`
+	productTitleTrgm = `COALESCE(current_data->>'title', '')`
+)
+
+// SearchProducts finds the products of a dataset by exact external_id or GTIN, full-text
+// search over title and description, or trigram similarity with the words of the title.
+// Exact matches come first, then the best ranked.
+func (q *Queries) SearchProducts(ctx context.Context, datasetID uuid.UUID, query string, limit int) ([]ProductMatch, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+productColumns+`, match, rank FROM (
+			SELECT *,
+				CASE
+					WHEN external_id = $2 THEN 'external_id'
+					WHEN current_data @> jsonb_build_object('gtin', $2::text) THEN 'gtin'
+					WHEN `+productText+` @@ websearch_to_tsquery('simple', $2) THEN 'text'
+					ELSE 'similar'
+				END AS match,
+				GREATEST(ts_rank(`+productText+`, websearch_to_tsquery('simple', $2)),
+					word_similarity($2, `+productTitleTrgm+`)) AS rank
+			FROM products
+			WHERE dataset_id = $1 AND (
+				external_id = $2
+				OR current_data @> jsonb_build_object('gtin', $2::text)
+				OR `+productText+` @@ websearch_to_tsquery('simple', $2)
+				OR $2 <% `+productTitleTrgm+`
+			)
+		) found
+		ORDER BY match IN ('external_id', 'gtin') DESC, rank DESC, id
+		LIMIT $3
+	`, datasetID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []ProductMatch{}
+	for rows.Next() {
+		var m ProductMatch
+		p := &m.Product
+		if err := rows.Scan(&p.ID, &p.DatasetID, &p.ExternalID, &p.RawData, &p.CurrentData, &p.Version, &p.Status, &p.AgentReadinessScore, &p.BaselineScore, &p.Tags, &p.Notes, &p.CreatedAt, &p.UpdatedAt,
+			&m.Match, &m.Rank); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
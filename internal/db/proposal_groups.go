@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== PROPOSAL GROUP OPERATIONS =====
+
+// contestedProposal is true when another pending proposal on the same product field
+// (proposals aliased p) writes a different value
+const contestedProposal = `EXISTS (
+	SELECT 1 FROM proposals o
+	WHERE o.product_id = p.product_id AND o.field = p.field AND o.status = 'proposed'
+	AND o.after_value IS DISTINCT FROM p.after_value)`
+
+// ListProposalGroups groups the pending proposals of an organization by field and value,
+// largest groups first. Only groups touching at least minSize products are returned.
+func (q *Queries) ListProposalGroups(ctx context.Context, orgID uuid.UUID, datasetID *uuid.UUID, field string, minSize, limit int) ([]models.ProposalGroup, error) {
+	if minSize <= 0 {
+		minSize = 2
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := q.pool.Query(ctx, `
+		WITH pending AS (
+			SELECT p.field, p.after_value, p.product_id, p.confidence, p.risk_level,
+				COALESCE(p.module, '') AS module, p.created_at, pr.external_id,
+				`+contestedProposal+` AS contested
+			FROM proposals p
+			JOIN products pr ON p.product_id = pr.id
+			WHERE p.status = 'proposed' AND p.applied_at IS NULL AND p.after_value IS NOT NULL
+			AND ($1::uuid IS NULL OR pr.dataset_id = $1)
+			AND ($2 = '' OR p.field = $2)
+			AND pr.dataset_id IN (SELECT id FROM datasets WHERE org_id = $5)
+		)
+		SELECT field, after_value, COUNT(*), COUNT(DISTINCT product_id),
+			COUNT(DISTINCT product_id) FILTER (WHERE contested),
+			AVG(confidence)::float8, MIN(confidence)::float8,
+			array_agg(DISTINCT risk_level), array_agg(DISTINCT module),
+			(array_agg(external_id ORDER BY created_at))[1:5]
+		FROM pending
+		GROUP BY field, after_value
+		HAVING COUNT(DISTINCT product_id) >= $3
+		ORDER BY COUNT(DISTINCT product_id) DESC, field
+		LIMIT $4
+	`, datasetID, field, minSize, limit, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.ProposalGroup{}
+	for rows.Next() {
+		var g models.ProposalGroup
+		if err := rows.Scan(&g.Field, &g.Value, &g.Proposals, &g.Products, &g.Conflicts,
+			&g.AvgConfidence, &g.MinConfidence, &g.RiskLevels, &g.Modules, &g.SampleProducts); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// GroupResult describes a group accept
+type GroupResult struct {
+	BulkResult
+	ChangeID *uuid.UUID // the single change_log entry, nil when nothing was applied
+}
+
+// AcceptProposalGroup applies every pending proposal of a dataset writing value into field,
+// in one transaction, and records a single proposal_group_accepted change log entry that
+// each applied proposal points to (group_change_id). Per product, the newest proposal is
+// applied and identical ones are superseded. Products with a competing pending value are
+// left out and counted as conflicts, as are proposals waiting for information or assigned
+// to someone other than reviewer.
+func (q *Queries) AcceptProposalGroup(ctx context.Context, datasetID uuid.UUID, field, value, reviewer string) (*GroupResult, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	res := &GroupResult{}
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(DISTINCT p.product_id)
+		FROM proposals p
+		JOIN products pr ON p.product_id = pr.id
+		WHERE pr.dataset_id = $1 AND p.field = $2 AND p.after_value = $3
+		AND p.status = 'proposed' AND p.applied_at IS NULL
+		AND `+contestedProposal+`
+	`, datasetID, field, value).Scan(&res.Conflicts); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+lockedProposalColumns+`
+		FROM proposals p
+		JOIN products pr ON p.product_id = pr.id
+		WHERE pr.dataset_id = $1 AND p.field = $2 AND p.after_value = $3
+		AND p.status = 'proposed' AND p.applied_at IS NULL
+		AND p.review_state <> 'needs_info'
+		AND (p.assignee IS NULL OR p.assignee = $4)
+		AND NOT `+contestedProposal+`
+		ORDER BY p.created_at DESC
+		FOR UPDATE OF p, pr
+	`, datasetID, field, value, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	var newest []*appliedProposal
+	seen := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var p appliedProposal
+		if err := rows.Scan(p.dest()...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !seen[p.productID] {
+			seen[p.productID] = true
+			newest = append(newest, &p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(newest) == 0 {
+		return res, tx.Commit(ctx)
+	}
+
+	changeID := uuid.New()
+	module := newest[0].module // kept only when the whole group comes from one module
+	for _, p := range newest {
+		applied, err := writeProposalTx(ctx, tx, p, "accepted", reviewer)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE proposals SET group_change_id = $2 WHERE id = $1`, p.id, changeID); err != nil {
+			return nil, err
+		}
+		res.Updated++
+		res.Superseded += applied.Superseded
+		if p.module != module {
+			module = ""
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO change_log (id, dataset_id, product_id, action, field, old_value, new_value, source, module, created_at, created_by)
+		VALUES ($1, $2, NULL, 'proposal_group_accepted', $3, NULL, $4, 'user', NULLIF($5, ''), $6, NULLIF($7, ''))
+	`, changeID, datasetID, field, value, module, time.Now(), reviewer); err != nil {
+		return nil, err
+	}
+	res.ChangeID = &changeID
+	return res, tx.Commit(ctx)
+}
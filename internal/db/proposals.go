@@ -0,0 +1,430 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== PROPOSAL APPLY / REVERT OPERATIONS =====
+
+var (
+	// ErrProposalNotApplied is returned when reverting a proposal that never reached current_data
+	ErrProposalNotApplied = errors.New("proposal is not applied")
+	// ErrFieldChanged is returned when the field no longer holds the value the proposal wrote
+	ErrFieldChanged = errors.New("field changed since the proposal was applied")
+	// ErrProposalNotReviewable is returned when applying a proposal that is simulated or already applied
+	ErrProposalNotReviewable = errors.New("proposal cannot be applied")
+	// ErrProposalSuperseded is returned when applying a proposal that lost to a competing one
+	ErrProposalSuperseded = errors.New("proposal was superseded by a competing proposal")
+)
+
+// ApplyResult describes an applied proposal
+type ApplyResult struct {
+	AppliedAt  time.Time
+	Superseded int // competing pending proposals on the same product+field
+}
+
+// appliedProposal is the locked proposal row an apply or revert works on
+type appliedProposal struct {
+	id        uuid.UUID
+	productID uuid.UUID
+	datasetID uuid.UUID
+	field     string
+	before    *string
+	after     string
+	edited    *string // reviewer's replacement for after, applied instead of it
+	status    string
+	module    string
+	appliedAt *time.Time
+	current   *string // value of the field in current_data (falling back to raw_data)
+}
+
+// lockedProposalColumns is the column list scanned into appliedProposal
+const lockedProposalColumns = `p.id, p.product_id, pr.dataset_id, p.field, p.before_value, COALESCE(p.after_value, ''), p.edited_value, p.status,
+	COALESCE(p.module, ''), p.applied_at, COALESCE(pr.current_data, pr.raw_data)->>p.field`
+
+// lockProposal loads a proposal and locks it together with its product
+func lockProposal(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*appliedProposal, error) {
+	var p appliedProposal
+	err := tx.QueryRow(ctx, `
+		SELECT `+lockedProposalColumns+`
+		FROM proposals p
+		JOIN products pr ON p.product_id = pr.id
+		WHERE p.id = $1
+		FOR UPDATE OF p, pr
+	`, id).Scan(p.dest()...)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// dest returns the scan targets matching lockedProposalColumns
+func (p *appliedProposal) dest() []any {
+	return []any{&p.id, &p.productID, &p.datasetID, &p.field, &p.before, &p.after, &p.edited, &p.status, &p.module, &p.appliedAt, &p.current}
+}
+
+// value is what applying the proposal writes: the reviewer's edit if any, else the agent's value
+func (p *appliedProposal) value() string {
+	if p.edited != nil {
+		return *p.edited
+	}
+	return p.after
+}
+
+// ApplyProposal accepts a proposal and writes its value into the product's current_data,
+// bumping the product version and recording a proposal_accepted change log entry.
+// before_value is replaced by what the field actually held, so a revert restores that
+// rather than the value the agent quoted.
+// A non-nil editedValue is stored as edited_value and applied instead of after_value,
+// which is kept for audit. Everything happens in one transaction; it returns when the
+// value was applied. Competing pending proposals for the same product+field are
+// superseded (see applyTx). A non-empty reviewer is recorded as reviewed_by.
+func (q *Queries) ApplyProposal(ctx context.Context, id uuid.UUID, status string, editedValue *string, reviewer string) (*ApplyResult, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	p, err := lockProposal(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p.status == "superseded" {
+		return nil, ErrProposalSuperseded
+	}
+	if p.status == "simulated" || p.appliedAt != nil {
+		return nil, ErrProposalNotReviewable
+	}
+	if editedValue != nil {
+		p.edited = editedValue
+	}
+
+	res, err := applyTx(ctx, tx, p, status, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	return res, tx.Commit(ctx)
+}
+
+// applyTx writes a locked proposal's value into current_data, logs the change and marks the
+// other pending proposals on the same product+field superseded by it, so two contradictory
+// values can never both be approved. Reverting the proposal puts them back in review.
+func applyTx(ctx context.Context, tx pgx.Tx, p *appliedProposal, status, reviewer string) (*ApplyResult, error) {
+	res, err := writeProposalTx(ctx, tx, p, status, reviewer)
+	if err != nil {
+		return nil, err
+	}
+
+	action := "proposal_accepted"
+	if p.edited != nil {
+		action = "proposal_edited"
+	}
+	return res, logChangeTx(ctx, tx, p, action, deref(p.current), p.value())
+}
+
+// writeProposalTx is applyTx without the change log entry
+func writeProposalTx(ctx context.Context, tx pgx.Tx, p *appliedProposal, status, reviewer string) (*ApplyResult, error) {
+	var res ApplyResult
+	if _, err := tx.Exec(ctx, `
+		UPDATE products SET
+			current_data = jsonb_set(COALESCE(current_data, raw_data, '{}'), ARRAY[$2::text], to_jsonb($3::text)),
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $1
+	`, p.productID, p.field, p.value()); err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(ctx, `
+		UPDATE proposals SET status = $2, reviewed_at = NOW(), applied_at = NOW(), before_value = $3, edited_value = $4,
+			review_state = $5, reviewed_by = COALESCE(NULLIF($6, ''), reviewed_by)
+		WHERE id = $1
+		RETURNING applied_at
+	`, p.id, status, p.current, p.edited, reviewStateFor(status), reviewer).Scan(&res.AppliedAt); err != nil {
+		return nil, err
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE proposals SET status = 'superseded', superseded_by = $3, reviewed_at = NOW()
+		WHERE product_id = $1 AND field = $2 AND id <> $3 AND status = 'proposed'
+	`, p.productID, p.field, p.id)
+	if err != nil {
+		return nil, err
+	}
+	res.Superseded = int(tag.RowsAffected())
+	return &res, nil
+}
+
+// RevertProposal undoes an applied proposal: the field gets its before_value back (or is
+// removed when the proposal introduced it), the proposal is marked reverted and a
+// proposal_reverted entry mirroring the original change is logged. Proposals it superseded
+// go back to proposed. It fails with ErrFieldChanged if the field was edited after the proposal was applied.
+func (q *Queries) RevertProposal(ctx context.Context, id uuid.UUID) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	p, err := lockProposal(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if p.appliedAt == nil || p.status == "reverted" {
+		return ErrProposalNotApplied
+	}
+	if p.current == nil || *p.current != p.value() {
+		return ErrFieldChanged
+	}
+
+	if p.before != nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE products SET
+				current_data = jsonb_set(current_data, ARRAY[$2::text], to_jsonb($3::text)),
+				version = version + 1,
+				updated_at = NOW()
+			WHERE id = $1
+		`, p.productID, p.field, *p.before)
+	} else {
+		_, err = tx.Exec(ctx, `
+			UPDATE products SET current_data = current_data - $2::text, version = version + 1, updated_at = NOW()
+			WHERE id = $1
+		`, p.productID, p.field)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE proposals SET status = 'reverted', reviewed_at = NOW(), applied_at = NULL WHERE id = $1
+	`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE proposals SET status = 'proposed', superseded_by = NULL, reviewed_at = NULL
+		WHERE superseded_by = $1 AND status = 'superseded'
+	`, id); err != nil {
+		return err
+	}
+	if err := logChangeTx(ctx, tx, p, "proposal_reverted", p.value(), deref(p.before)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ProposalFilter selects proposals for a bulk review; zero values match everything
+type ProposalFilter struct {
+	OrgID         uuid.UUID
+	DatasetID     *uuid.UUID
+	Fields        []string // case-insensitive
+	Module        string
+	RiskLevels    []string
+	MinConfidence float64
+	Status        string // current status, "proposed" when empty
+	Reviewer      string // proposals assigned to anyone else are left out
+}
+
+// BulkResult counts what a bulk review did
+type BulkResult struct {
+	Updated    int
+	Superseded int // competing proposals closed by the accepted ones
+	Conflicts  int // matched proposals left pending because they disagree
+}
+
+// BulkUpdateProposals accepts or rejects every proposal matching the filter in one
+// transaction, each with a change log entry. When accepting, matched proposals are grouped
+// by product+field: a group whose values all agree has its newest proposal applied (the
+// rest are superseded), a group with contradictory values is skipped and counted as
+// conflicts for manual resolution. Simulated and already applied proposals are never matched,
+// nor are proposals waiting for information or assigned to someone other than f.Reviewer.
+func (q *Queries) BulkUpdateProposals(ctx context.Context, f ProposalFilter, status string) (*BulkResult, error) {
+	if f.Status == "" {
+		f.Status = "proposed"
+	}
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+lockedProposalColumns+`
+		FROM proposals p
+		JOIN products pr ON p.product_id = pr.id
+		WHERE p.status = $1 AND p.status <> 'simulated' AND p.applied_at IS NULL
+		AND ($2::uuid IS NULL OR pr.dataset_id = $2)
+		AND (cardinality($3::text[]) = 0 OR lower(p.field) = ANY($3))
+		AND ($4 = '' OR p.module = $4)
+		AND (cardinality($5::text[]) = 0 OR lower(p.risk_level) = ANY($5))
+		AND ($6::decimal = 0 OR p.confidence >= $6)
+		AND p.review_state <> 'needs_info'
+		AND (p.assignee IS NULL OR p.assignee = $7)
+		AND pr.dataset_id IN (SELECT id FROM datasets WHERE org_id = $8)
+		ORDER BY p.created_at
+		FOR UPDATE OF p, pr
+	`, f.Status, f.DatasetID, lowerAll(f.Fields), f.Module, lowerAll(f.RiskLevels), f.MinConfidence, f.Reviewer, f.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*appliedProposal
+	for rows.Next() {
+		var p appliedProposal
+		if err := rows.Scan(p.dest()...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		matched = append(matched, &p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	res := &BulkResult{}
+	if status != "accepted" {
+		for _, p := range matched {
+			if _, err := tx.Exec(ctx, `
+				UPDATE proposals SET status = $2, reviewed_at = NOW(), review_state = $3, reviewed_by = COALESCE(NULLIF($4, ''), reviewed_by)
+				WHERE id = $1
+			`, p.id, status, reviewStateFor(status), f.Reviewer); err != nil {
+				return nil, err
+			}
+			if err := logChangeTx(ctx, tx, p, "proposal_rejected", deref(p.current), p.after); err != nil {
+				return nil, err
+			}
+			res.Updated++
+		}
+		return res, tx.Commit(ctx)
+	}
+
+	type key struct {
+		product uuid.UUID
+		field   string
+	}
+	var order []key
+	groups := map[key][]*appliedProposal{}
+	for _, p := range matched {
+		k := key{p.productID, p.field}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], p)
+	}
+	for _, k := range order {
+		group := groups[k]
+		if !agree(group) {
+			res.Conflicts += len(group)
+			continue
+		}
+		applied, err := applyTx(ctx, tx, group[len(group)-1], status, f.Reviewer)
+		if err != nil {
+			return nil, err
+		}
+		res.Updated++
+		res.Superseded += applied.Superseded
+	}
+
+	return res, tx.Commit(ctx)
+}
+
+// agree reports whether every proposal of a group would write the same value
+func agree(group []*appliedProposal) bool {
+	for _, p := range group[1:] {
+		if p.value() != group[0].value() {
+			return false
+		}
+	}
+	return true
+}
+
+func lowerAll(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ListProposalConflicts returns the product fields of an organization with pending proposals
+// that disagree, optionally restricted to a dataset
+func (q *Queries) ListProposalConflicts(ctx context.Context, orgID uuid.UUID, datasetID *uuid.UUID) ([]models.ProposalConflict, error) {
+	rows, err := q.pool.Query(ctx, `
+		WITH conflicts AS (
+			SELECT p.product_id, p.field
+			FROM proposals p
+			JOIN products pr ON p.product_id = pr.id
+			WHERE p.status = 'proposed' AND ($1::uuid IS NULL OR pr.dataset_id = $1)
+			AND pr.dataset_id IN (SELECT id FROM datasets WHERE org_id = $2)
+			GROUP BY p.product_id, p.field
+			HAVING COUNT(DISTINCT COALESCE(p.edited_value, p.after_value)) > 1
+		)
+		SELECT pr.id, pr.external_id,
+			COALESCE(pr.current_data->>'title', pr.raw_data->>'title', pr.external_id),
+			pr.dataset_id, p.field, COALESCE(pr.current_data, pr.raw_data)->>p.field,
+			p.id, p.session_id, p.before_value, p.after_value, p.edited_value, COALESCE(p.rationale, '{}'), p.sources,
+			p.confidence, p.risk_level, p.status, p.quality_score, COALESCE(p.module, ''), p.created_at
+		FROM conflicts c
+		JOIN proposals p ON p.product_id = c.product_id AND p.field = c.field AND p.status = 'proposed'
+		JOIN products pr ON pr.id = p.product_id
+		ORDER BY pr.external_id, p.field, p.created_at DESC
+	`, datasetID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conflicts := []models.ProposalConflict{}
+	for rows.Next() {
+		var c models.ProposalConflict
+		var p models.Proposal
+		if err := rows.Scan(&c.ProductID, &c.ProductExternalID, &c.ProductTitle, &c.DatasetID, &c.Field, &c.CurrentValue,
+			&p.ID, &p.SessionID, &p.BeforeValue, &p.AfterValue, &p.EditedValue, &p.Rationale, &p.Sources,
+			&p.Confidence, &p.RiskLevel, &p.Status, &p.QualityScore, &p.Module, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		p.ProductID, p.Field = c.ProductID, c.Field
+		if n := len(conflicts); n > 0 && conflicts[n-1].ProductID == c.ProductID && conflicts[n-1].Field == c.Field {
+			conflicts[n-1].Proposals = append(conflicts[n-1].Proposals, p)
+			continue
+		}
+		c.Proposals = []models.Proposal{p}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+// logChangeTx records a user change on the proposal's field inside tx
+func logChangeTx(ctx context.Context, tx pgx.Tx, p *appliedProposal, action, oldValue, newValue string) error {
+	entry := models.ChangeLogEntry{
+		ID:        uuid.New(),
+		DatasetID: &p.datasetID,
+		ProductID: &p.productID,
+		Action:    action,
+		Field:     p.field,
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		Source:    "user",
+		Module:    p.module,
+		CreatedAt: time.Now(),
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO change_log (id, dataset_id, product_id, action, field, old_value, new_value, source, module, created_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''))
+	`, entry.ID, entry.DatasetID, entry.ProductID, entry.Action, entry.Field, entry.OldValue, entry.NewValue, entry.Source, entry.Module, entry.CreatedAt, entry.CreatedBy)
+	return err
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
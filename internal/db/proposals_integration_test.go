@@ -0,0 +1,64 @@
+//go:build integration
+
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/google/uuid"
+)
+
+func TestProposalListPages(t *testing.T) {
+	ctx := context.Background()
+	orgID, datasetID := benchDataset(t, 25)
+	// Scores 0-9 on most proposals, some left unscored
+	if _, err := benchPool.Exec(ctx, `
+		UPDATE proposals SET quality_score = (abs(hashtext(id::text)) % 10)
+		WHERE product_id IN (SELECT id FROM products WHERE dataset_id = $1) AND abs(hashtext(id::text)) % 5 <> 0
+	`, datasetID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sort := range []string{"", "quality", "quality_asc"} {
+		filter := db.ProposalListFilter{OrgID: orgID, DatasetID: &datasetID, Sort: sort, Limit: 4}
+		seen := map[uuid.UUID]bool{}
+		var previous *int
+		scored := true
+		for pages := 0; ; pages++ {
+			if pages > 10 {
+				t.Fatalf("sort %q: more pages than proposals", sort)
+			}
+			proposals, next, err := benchQueries.ListProposalsWithProducts(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range proposals {
+				if seen[p.ID] {
+					t.Fatalf("sort %q: proposal %s listed twice", sort, p.ID)
+				}
+				seen[p.ID] = true
+				if sort == "" {
+					continue
+				}
+				// Unscored proposals come last, scores in order before them
+				if p.QualityScore == nil {
+					scored = false
+				} else if !scored {
+					t.Fatalf("sort %q: scored proposal after an unscored one", sort)
+				} else if previous != nil && ((sort == "quality" && *p.QualityScore > *previous) || (sort == "quality_asc" && *p.QualityScore < *previous)) {
+					t.Fatalf("sort %q: %d after %d", sort, *p.QualityScore, *previous)
+				}
+				previous = p.QualityScore
+			}
+			if next == nil {
+				break
+			}
+			filter.After = next
+		}
+		if len(seen) != 25 {
+			t.Fatalf("sort %q: %d proposals listed, want 25", sort, len(seen))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestProposalCursor(t *testing.T) {
+	c := ProposalCursor{Key: -42, CreatedAt: time.Date(2026, 6, 2, 10, 15, 2, 123000000, time.UTC), ID: uuid.New()}
+	parsed, err := ParseProposalCursor(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *parsed != c {
+		t.Fatalf("got %+v, want %+v", *parsed, c)
+	}
+
+	// Cursors handed out before sort keys
+	legacy := base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.Format(time.RFC3339Nano) + "|" + c.ID.String()))
+	if parsed, err = ParseProposalCursor(legacy); err != nil || parsed.Key != 0 || parsed.ID != c.ID {
+		t.Fatalf("legacy cursor: %+v, %v", parsed, err)
+	}
+
+	for _, bad := range []string{"", "!!", base64.RawURLEncoding.EncodeToString([]byte("x|2026-06-02T10:15:02Z|" + c.ID.String())), base64.RawURLEncoding.EncodeToString([]byte("2026-06-02|nope"))} {
+		if _, err := ParseProposalCursor(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+func TestSortKeyOf(t *testing.T) {
+	score := func(n int) *int { return &n }
+	// Listed by key descending: best first for quality, worst first for quality_asc,
+	// unscored last for both
+	tests := []struct {
+		sort  string
+		order []*int
+	}{
+		{"quality", []*int{score(90), score(10), score(0), nil}},
+		{"quality_asc", []*int{score(0), score(10), score(90), nil}},
+	}
+	for _, tt := range tests {
+		for i := 1; i < len(tt.order); i++ {
+			if sortKeyOf(tt.sort, tt.order[i-1]) <= sortKeyOf(tt.sort, tt.order[i]) {
+				t.Errorf("%s: position %d doesn't sort before %d", tt.sort, i-1, i)
+			}
+		}
+	}
+	if sortKeyOf("", score(50)) != 0 {
+		t.Error("newest first has a sort key")
+	}
+}
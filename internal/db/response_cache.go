@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== RESPONSE CACHE OPERATIONS =====
+
+// GetCachedResponse returns the live entry for key and counts the hit; nil when there is none
+func (q *Queries) GetCachedResponse(ctx context.Context, key string) (*models.CachedResponse, error) {
+	var r models.CachedResponse
+	err := q.pool.QueryRow(ctx, `
+		UPDATE llm_response_cache SET hits = hits + 1, last_hit_at = NOW()
+		WHERE key = $1 AND expires_at > NOW()
+		RETURNING key, group_name, model, content, prompt_tokens, completion_tokens, cost_usd, hits, created_at, expires_at, last_hit_at
+	`, key).Scan(&r.Key, &r.Group, &r.Model, &r.Content, &r.PromptTokens, &r.CompletionTokens, &r.CostUSD, &r.Hits, &r.CreatedAt, &r.ExpiresAt, &r.LastHitAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// StoreCachedResponse stores an answer, replacing an expired entry for the same key
+func (q *Queries) StoreCachedResponse(ctx context.Context, r models.CachedResponse) error {
+	_, err := q.pool.Exec(ctx, `
+		INSERT INTO llm_response_cache (key, group_name, model, content, prompt_tokens, completion_tokens, cost_usd, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), $8)
+		ON CONFLICT (key) DO UPDATE SET
+			group_name = EXCLUDED.group_name, model = EXCLUDED.model, content = EXCLUDED.content,
+			prompt_tokens = EXCLUDED.prompt_tokens, completion_tokens = EXCLUDED.completion_tokens,
+			cost_usd = EXCLUDED.cost_usd, hits = 0, created_at = NOW(), expires_at = EXCLUDED.expires_at, last_hit_at = NULL
+	`, r.Key, r.Group, r.Model, r.Content, r.PromptTokens, r.CompletionTokens, r.CostUSD, r.ExpiresAt)
+	return err
+}
+
+// GetResponseCacheStats returns the hits of live entries and what they saved, per group
+func (q *Queries) GetResponseCacheStats(ctx context.Context) (*models.ResponseCacheStats, error) {
+	stats := &models.ResponseCacheStats{Groups: []models.ResponseCacheGroup{}}
+	rows, err := q.pool.Query(ctx, `
+		SELECT group_name, COUNT(*), COALESCE(SUM(hits), 0),
+			COALESCE(SUM(hits * (prompt_tokens + completion_tokens)), 0), COALESCE(SUM(hits * cost_usd), 0)
+		FROM llm_response_cache WHERE expires_at > NOW()
+		GROUP BY group_name ORDER BY group_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var g models.ResponseCacheGroup
+		if err := rows.Scan(&g.Group, &g.Entries, &g.Hits, &g.TokensSaved, &g.SavedUSD); err != nil {
+			return nil, err
+		}
+		stats.Entries += g.Entries
+		stats.Hits += g.Hits
+		stats.TokensSaved += g.TokensSaved
+		stats.SavedUSD += g.SavedUSD
+		stats.Groups = append(stats.Groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = q.pool.QueryRow(ctx, `SELECT COUNT(*) FROM llm_response_cache WHERE expires_at <= NOW()`).Scan(&stats.Expired)
+	return stats, err
+}
+
+// ClearResponseCache deletes every entry, so the next runs call the model again
+func (q *Queries) ClearResponseCache(ctx context.Context) (int64, error) {
+	tag, err := q.pool.Exec(ctx, `DELETE FROM llm_response_cache`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PurgeExpiredResponses deletes expired entries
+func (q *Queries) PurgeExpiredResponses(ctx context.Context) (int64, error) {
+	tag, err := q.pool.Exec(ctx, `DELETE FROM llm_response_cache WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
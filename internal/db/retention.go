@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== AUDIT RETENTION OPERATIONS =====
+
+// AuditTables lists the tables partitioned by month on created_at
+var AuditTables = []string{"change_log", "agent_traces"}
+
+// EnsureAuditPartitions creates monthly partitions from the current month up to monthsAhead
+func (q *Queries) EnsureAuditPartitions(ctx context.Context, monthsAhead int) error {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, table := range AuditTables {
+		for i := 0; i <= monthsAhead; i++ {
+			month := start.AddDate(0, i, 0)
+			if _, err := q.pool.Exec(ctx, `SELECT create_monthly_partition($1, $2::date)`, table, month); err != nil {
+				return fmt.Errorf("create partition %s %s: %w", table, month.Format("2006-01"), err)
+			}
+		}
+	}
+	return nil
+}
+
+// ListAuditPartitions returns the monthly partitions of an audit table, oldest first
+func (q *Queries) ListAuditPartitions(ctx context.Context, table string) ([]models.AuditPartition, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT c.relname, GREATEST(c.reltuples, 0)::bigint
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = $1
+		ORDER BY c.relname
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefix := table + "_p"
+	var partitions []models.AuditPartition
+	for rows.Next() {
+		var p models.AuditPartition
+		if err := rows.Scan(&p.Name, &p.RowCount); err != nil {
+			return nil, err
+		}
+		// Skip the default partition and anything not following the naming scheme
+		if !strings.HasPrefix(p.Name, prefix) {
+			continue
+		}
+		month, err := time.Parse("2006_01", strings.TrimPrefix(p.Name, prefix))
+		if err != nil {
+			continue
+		}
+		p.Table = table
+		p.MonthStart = month
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+// ExportAuditPartition streams every row of a partition to w as JSON lines and returns the row count.
+// A month of audit rows takes longer than the query timeouts: the export runs on the bare pool,
+// in a transaction without statement_timeout.
+func (q *Queries) ExportAuditPartition(ctx context.Context, partition string, w io.Writer) (int64, error) {
+	tx, err := q.pool.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT row_to_json(t)::text FROM `+pgx.Identifier{partition}.Sanitize()+` t`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return count, err
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// DropAuditPartition detaches and drops a monthly partition
+func (q *Queries) DropAuditPartition(ctx context.Context, table, partition string) error {
+	_, err := q.pool.Exec(ctx, `ALTER TABLE `+pgx.Identifier{table}.Sanitize()+` DETACH PARTITION `+pgx.Identifier{partition}.Sanitize())
+	if err != nil {
+		return err
+	}
+	_, err = q.pool.Exec(ctx, `DROP TABLE `+pgx.Identifier{partition}.Sanitize())
+	return err
+}
+
+// PurgeTokenUsage deletes the daily token usage and its attribution per session dated before
+// the given day and returns how many rows went. With dryRun the rows are only counted.
+func (q *Queries) PurgeTokenUsage(ctx context.Context, before time.Time, dryRun bool) (int64, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var purged int64
+	for _, table := range []string{"token_usage", "token_usage_attribution"} {
+		tag, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE date < $1`, before)
+		if err != nil {
+			return 0, err
+		}
+		purged += tag.RowsAffected()
+	}
+	if dryRun {
+		return purged, nil
+	}
+	return purged, tx.Commit(ctx)
+}
+
+// PurgeSnapshots deletes the snapshots created before the given time, except the latest kept
+// of each dataset, with the data no other snapshot references. It returns the deleted
+// snapshots; with dryRun nothing is deleted.
+func (q *Queries) PurgeSnapshots(ctx context.Context, before time.Time, kept int, dryRun bool) ([]uuid.UUID, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id FROM (
+			SELECT id, created_at, ROW_NUMBER() OVER (PARTITION BY dataset_id ORDER BY created_at DESC) AS recent
+			FROM dataset_snapshots
+		) s
+		WHERE created_at < $1 AND recent > $2
+	`, before, kept)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 || dryRun {
+		return ids, nil
+	}
+	if err := deleteSnapshotsTx(ctx, tx, ids); err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit(ctx)
+}
+
+func (q *Queries) CreateAuditArchive(ctx context.Context, a models.AuditArchive) error {
+	_, err := q.pool.Exec(ctx, `
+		INSERT INTO audit_archives (id, table_name, partition_name, month_start, row_count, location, purged, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (table_name, month_start) DO UPDATE SET
+			row_count = EXCLUDED.row_count, location = EXCLUDED.location, purged = EXCLUDED.purged, created_at = EXCLUDED.created_at
+	`, a.ID, a.TableName, a.PartitionName, a.MonthStart, a.RowCount, a.Location, a.Purged, a.CreatedAt)
+	return err
+}
+
+func (q *Queries) ListAuditArchives(ctx context.Context) ([]models.AuditArchive, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, table_name, partition_name, month_start, row_count, location, purged, created_at
+		FROM audit_archives ORDER BY month_start DESC, table_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var archives []models.AuditArchive
+	for rows.Next() {
+		var a models.AuditArchive
+		if err := rows.Scan(&a.ID, &a.TableName, &a.PartitionName, &a.MonthStart, &a.RowCount, &a.Location, &a.Purged, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		archives = append(archives, a)
+	}
+	return archives, nil
+}
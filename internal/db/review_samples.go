@@ -0,0 +1,334 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== SAMPLING REVIEW OPERATIONS =====
+
+var (
+	// ErrSampleClosed is returned when deciding a sample that was already auto-approved or closed
+	ErrSampleClosed = errors.New("review sample is not open")
+	// ErrSampleIncomplete is returned when auto-approving before every sampled proposal was reviewed
+	ErrSampleIncomplete = errors.New("review sample has pending proposals")
+	// ErrSampleBelowThreshold is returned when the sample acceptance rate is under the threshold
+	ErrSampleBelowThreshold = errors.New("review sample acceptance rate below threshold")
+)
+
+// sampleSize is Cochran's sample size for a proportion (worst case p = 0.5) at the given
+// confidence level and margin of error, corrected for the finite population, floored at
+// minSize and capped at the population
+func sampleSize(population int, confidence, margin float64, minSize int) int {
+	z := math.Sqrt2 * math.Erfinv(confidence)
+	n0 := z * z * 0.25 / (margin * margin)
+	n := int(math.Ceil(n0 / (1 + (n0-1)/float64(population))))
+	if n < minSize {
+		n = minSize
+	}
+	if n > population {
+		n = population
+	}
+	return n
+}
+
+// ReviewSampleParams describes the samples to draw; empty Module/Field match every stratum
+type ReviewSampleParams struct {
+	DatasetID  uuid.UUID
+	Module     string
+	Field      string
+	Confidence float64
+	Margin     float64
+	MinSize    int
+	Threshold  float64
+	CreatedBy  string
+}
+
+// stratumProposals is the WHERE clause of the pending proposals of a stratum
+// ($1 dataset, $2 module, $3 field) on proposals aliased p joined to products pr
+const stratumProposals = `pr.dataset_id = $1 AND COALESCE(p.module, '') = $2 AND p.field = $3
+	AND p.status = 'proposed' AND p.applied_at IS NULL`
+
+// CreateReviewSamples draws one random sample per module+field stratum of the dataset's
+// pending proposals, sized by sampleSize. Strata that already have an open sample are skipped.
+func (q *Queries) CreateReviewSamples(ctx context.Context, params ReviewSampleParams) ([]models.ReviewSample, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT COALESCE(p.module, ''), p.field, COUNT(*)
+		FROM proposals p
+		JOIN products pr ON p.product_id = pr.id
+		WHERE pr.dataset_id = $1 AND p.status = 'proposed' AND p.applied_at IS NULL
+		AND ($2 = '' OR COALESCE(p.module, '') = $2)
+		AND ($3 = '' OR p.field = $3)
+		AND NOT EXISTS (
+			SELECT 1 FROM review_samples s
+			WHERE s.dataset_id = $1 AND s.module = COALESCE(p.module, '') AND s.field = p.field AND s.status = 'open')
+		GROUP BY 1, 2
+		ORDER BY 3 DESC
+	`, params.DatasetID, params.Module, params.Field)
+	if err != nil {
+		return nil, err
+	}
+	type stratum struct {
+		module, field string
+		population    int
+	}
+	var strata []stratum
+	for rows.Next() {
+		var s stratum
+		if err := rows.Scan(&s.module, &s.field, &s.population); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		strata = append(strata, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	for _, s := range strata {
+		id := uuid.New()
+		size := sampleSize(s.population, params.Confidence, params.Margin, params.MinSize)
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO review_samples (id, dataset_id, module, field, population, sample_size, confidence, margin, threshold, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+		`, id, params.DatasetID, s.module, s.field, s.population, size, params.Confidence, params.Margin, params.Threshold, params.CreatedBy); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO review_sample_items (sample_id, proposal_id)
+			SELECT $4, p.id
+			FROM proposals p
+			JOIN products pr ON p.product_id = pr.id
+			WHERE `+stratumProposals+`
+			ORDER BY random()
+			LIMIT $5
+		`, params.DatasetID, s.module, s.field, id, size); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	samples := []models.ReviewSample{}
+	for _, id := range ids {
+		s, err := q.GetReviewSample(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, *s)
+	}
+	return samples, nil
+}
+
+// reviewSampleQuery selects samples with the live results of their proposals; a reverted
+// proposal counts as rejected, a superseded one is left out
+const reviewSampleQuery = `
+	SELECT s.id, s.dataset_id, s.module, s.field, s.population, s.sample_size,
+		s.confidence::float8, s.margin::float8, s.threshold::float8, s.status,
+		COALESCE(s.created_by, ''), s.created_at, COALESCE(s.decided_by, ''), s.decided_at,
+		s.auto_approved, s.change_id,
+		COUNT(p.id) FILTER (WHERE p.status = 'accepted'),
+		COUNT(p.id) FILTER (WHERE p.status = 'edited'),
+		COUNT(p.id) FILTER (WHERE p.status IN ('rejected', 'reverted')),
+		COUNT(p.id) FILTER (WHERE p.status = 'proposed')
+	FROM review_samples s
+	LEFT JOIN review_sample_items i ON i.sample_id = s.id
+	LEFT JOIN proposals p ON p.id = i.proposal_id`
+
+func scanReviewSample(row pgx.Row) (*models.ReviewSample, error) {
+	var s models.ReviewSample
+	if err := row.Scan(&s.ID, &s.DatasetID, &s.Module, &s.Field, &s.Population, &s.SampleSize,
+		&s.Confidence, &s.Margin, &s.Threshold, &s.Status,
+		&s.CreatedBy, &s.CreatedAt, &s.DecidedBy, &s.DecidedAt,
+		&s.AutoApproved, &s.ChangeID,
+		&s.Accepted, &s.Edited, &s.Rejected, &s.Pending); err != nil {
+		return nil, err
+	}
+	if reviewed := s.Accepted + s.Edited + s.Rejected; reviewed > 0 {
+		s.AcceptanceRate = float64(s.Accepted) / float64(reviewed)
+	}
+	s.Eligible = s.Status == "open" && s.Pending == 0 && s.Accepted > 0 && s.AcceptanceRate >= s.Threshold
+	return &s, nil
+}
+
+// ListReviewSamples returns the samples of an organization, newest first, optionally for
+// a dataset and status
+func (q *Queries) ListReviewSamples(ctx context.Context, orgID uuid.UUID, datasetID *uuid.UUID, status string) ([]models.ReviewSample, error) {
+	rows, err := q.pool.Query(ctx, reviewSampleQuery+`
+		WHERE ($1::uuid IS NULL OR s.dataset_id = $1) AND ($2 = '' OR s.status = $2)
+		AND s.dataset_id IN (SELECT id FROM datasets WHERE org_id = $3)
+		GROUP BY s.id
+		ORDER BY s.created_at DESC
+	`, datasetID, status, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []models.ReviewSample{}
+	for rows.Next() {
+		s, err := scanReviewSample(rows)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, *s)
+	}
+	return samples, rows.Err()
+}
+
+// GetReviewSample returns a sample with its results and sampled proposal IDs
+func (q *Queries) GetReviewSample(ctx context.Context, id uuid.UUID) (*models.ReviewSample, error) {
+	s, err := getReviewSample(ctx, q.pool, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.pool.Query(ctx, `SELECT proposal_id FROM review_sample_items WHERE sample_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pid uuid.UUID
+		if err := rows.Scan(&pid); err != nil {
+			return nil, err
+		}
+		s.ProposalIDs = append(s.ProposalIDs, pid)
+	}
+	return s, rows.Err()
+}
+
+func getReviewSample(ctx context.Context, db rowQuerier, id uuid.UUID) (*models.ReviewSample, error) {
+	return scanReviewSample(db.QueryRow(ctx, reviewSampleQuery+` WHERE s.id = $1 GROUP BY s.id`, id))
+}
+
+// AutoApproveSample accepts the rest of a fully reviewed sample's stratum when the sample
+// acceptance rate reaches its threshold: pending proposals of the same dataset, module and
+// field created before the sample was drawn, except contested ones (another pending value
+// on the same product field), those waiting for information and those assigned to someone
+// other than reviewer. Everything happens in one transaction with a single
+// proposal_sample_approved change log entry whose new_value states the sample results;
+// each applied proposal is reviewed_by "sample:<id>" and points at the entry (group_change_id).
+func (q *Queries) AutoApproveSample(ctx context.Context, id uuid.UUID, reviewer string) (*models.ReviewSample, error) {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM review_samples WHERE id = $1 FOR UPDATE`, id); err != nil {
+		return nil, err
+	}
+	s, err := getReviewSample(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case s.Status != "open":
+		return nil, ErrSampleClosed
+	case s.Pending > 0:
+		return nil, ErrSampleIncomplete
+	case !s.Eligible:
+		return nil, ErrSampleBelowThreshold
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+lockedProposalColumns+`
+		FROM proposals p
+		JOIN products pr ON p.product_id = pr.id
+		WHERE `+stratumProposals+`
+		AND p.created_at <= $4
+		AND p.review_state <> 'needs_info'
+		AND (p.assignee IS NULL OR p.assignee = $5)
+		AND NOT `+contestedProposal+`
+		ORDER BY p.created_at DESC
+		FOR UPDATE OF p, pr
+	`, s.DatasetID, s.Module, s.Field, s.CreatedAt, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	var newest []*appliedProposal
+	seen := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var p appliedProposal
+		if err := rows.Scan(p.dest()...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !seen[p.productID] {
+			seen[p.productID] = true
+			newest = append(newest, &p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	changeID := uuid.New()
+	for _, p := range newest {
+		if _, err := writeProposalTx(ctx, tx, p, "accepted", "sample:"+id.String()); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE proposals SET group_change_id = $2 WHERE id = $1`, p.id, changeID); err != nil {
+			return nil, err
+		}
+	}
+
+	reviewed := s.Accepted + s.Edited + s.Rejected
+	justification := fmt.Sprintf("sample %s: %d/%d accepted (%.1f%%, threshold %.1f%%, %.0f%% confidence ±%.1f%%), %d of %d auto-approved",
+		id, s.Accepted, reviewed, s.AcceptanceRate*100, s.Threshold*100, s.Confidence*100, s.Margin*100, len(newest), s.Population)
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO change_log (id, dataset_id, product_id, action, field, old_value, new_value, source, module, created_at, created_by)
+		VALUES ($1, $2, NULL, 'proposal_sample_approved', $3, NULL, $4, 'user', NULLIF($5, ''), $6, NULLIF($7, ''))
+	`, changeID, s.DatasetID, s.Field, justification, s.Module, time.Now(), reviewer); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE review_samples SET status = 'auto_approved', sample_accepted = $2, sample_reviewed = $3,
+			auto_approved = $4, change_id = $5, decided_by = NULLIF($6, ''), decided_at = NOW()
+		WHERE id = $1
+	`, id, s.Accepted, reviewed, len(newest), changeID, reviewer); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return q.GetReviewSample(ctx, id)
+}
+
+// CloseReviewSample ends an open sample without auto-approving (the rest is reviewed by hand)
+func (q *Queries) CloseReviewSample(ctx context.Context, id uuid.UUID, by string) error {
+	var status string
+	if err := q.pool.QueryRow(ctx, `SELECT status FROM review_samples WHERE id = $1`, id).Scan(&status); err != nil {
+		return err
+	}
+	tag, err := q.pool.Exec(ctx, `
+		UPDATE review_samples SET status = 'closed', decided_by = NULLIF($2, ''), decided_at = NOW()
+		WHERE id = $1 AND status = 'open'
+	`, id, by)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSampleClosed
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== REVIEW WORKFLOW OPERATIONS (assignment, states, comments) =====
+
+var (
+	// ErrAssignedToOther is returned when an item held by another reviewer is changed without force
+	ErrAssignedToOther = errors.New("assigned to another reviewer")
+	// ErrReviewClosed is returned when assigning or commenting on the workflow of a decided item
+	ErrReviewClosed = errors.New("review already decided")
+)
+
+// ReviewTarget is the table of a reviewable item
+type ReviewTarget string
+
+const (
+	ReviewTargetProposal    ReviewTarget = "proposals"
+	ReviewTargetHumanReview ReviewTarget = "human_reviews"
+)
+
+// openStatus is the status an item keeps while it awaits a decision
+var openStatus = map[ReviewTarget]string{
+	ReviewTargetProposal:    "proposed",
+	ReviewTargetHumanReview: "pending",
+}
+
+// commentColumn is the review_comments column pointing at an item of the target
+var commentColumn = map[ReviewTarget]string{
+	ReviewTargetProposal:    "proposal_id",
+	ReviewTargetHumanReview: "human_review_id",
+}
+
+// reviewStateFor is the review state a decision leaves an item in
+func reviewStateFor(status string) string {
+	switch status {
+	case "accepted", "edited", "resolved":
+		return models.ReviewStateApproved
+	case "rejected", "dismissed":
+		return models.ReviewStateRejected
+	}
+	return models.ReviewStatePending
+}
+
+// rowQuerier is satisfied by both the pool and a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// lockReview locks an open item and returns its assignee
+func lockReview(ctx context.Context, tx pgx.Tx, t ReviewTarget, id uuid.UUID) (*string, error) {
+	var assignee *string
+	var status string
+	err := tx.QueryRow(ctx, `SELECT assignee, status FROM `+string(t)+` WHERE id = $1 FOR UPDATE`, id).Scan(&assignee, &status)
+	if err != nil {
+		return nil, err
+	}
+	if status != openStatus[t] {
+		return nil, ErrReviewClosed
+	}
+	return assignee, nil
+}
+
+// AssignReview hands an open item to a reviewer and moves it from pending to in_review.
+// An item held by someone else is only taken over with force. An empty assignee releases
+// the item; an in_review item goes back to pending.
+func (q *Queries) AssignReview(ctx context.Context, t ReviewTarget, id uuid.UUID, assignee string, force bool) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := lockReview(ctx, tx, t, id)
+	if err != nil {
+		return err
+	}
+	if assignee != "" && current != nil && *current != assignee && !force {
+		return ErrAssignedToOther
+	}
+
+	if assignee == "" {
+		_, err = tx.Exec(ctx, `
+			UPDATE `+string(t)+` SET assignee = NULL, assigned_at = NULL,
+				review_state = CASE WHEN review_state = 'in_review' THEN 'pending' ELSE review_state END
+			WHERE id = $1
+		`, id)
+	} else {
+		_, err = tx.Exec(ctx, `
+			UPDATE `+string(t)+` SET assignee = $2, assigned_at = NOW(),
+				review_state = CASE WHEN review_state = 'pending' THEN 'in_review' ELSE review_state END
+			WHERE id = $1
+		`, id, assignee)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SetReviewState moves an open item between pending, in_review and needs_info, recording
+// the comment (if any) with the new state. Only the assignee may change an assigned item;
+// an unassigned item taken in_review is assigned to the author.
+func (q *Queries) SetReviewState(ctx context.Context, t ReviewTarget, id uuid.UUID, state, author, comment string) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := lockReview(ctx, tx, t, id)
+	if err != nil {
+		return err
+	}
+	if current != nil && author != "" && *current != author {
+		return ErrAssignedToOther
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE `+string(t)+` SET review_state = $2,
+			assignee = CASE WHEN $2 = 'in_review' AND assignee IS NULL THEN NULLIF($3, '') ELSE assignee END,
+			assigned_at = CASE WHEN $2 = 'in_review' AND assignee IS NULL AND $3 <> '' THEN NOW() ELSE assigned_at END
+		WHERE id = $1
+	`, id, state, author); err != nil {
+		return err
+	}
+	if comment != "" {
+		if _, err := addComment(ctx, tx, t, id, author, comment, state); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// AddReviewComment adds a reviewer note to an item; pgx.ErrNoRows when the item does not exist
+func (q *Queries) AddReviewComment(ctx context.Context, t ReviewTarget, id uuid.UUID, author, body string) (*models.ReviewComment, error) {
+	return addComment(ctx, q.pool, t, id, author, body, "")
+}
+
+func addComment(ctx context.Context, db rowQuerier, t ReviewTarget, id uuid.UUID, author, body, state string) (*models.ReviewComment, error) {
+	var c models.ReviewComment
+	err := db.QueryRow(ctx, `
+		INSERT INTO review_comments (`+commentColumn[t]+`, author, body, review_state)
+		SELECT id, $2, $3, NULLIF($4, '') FROM `+string(t)+` WHERE id = $1
+		RETURNING id, proposal_id, human_review_id, author, body, COALESCE(review_state, ''), created_at
+	`, id, author, body, state).Scan(&c.ID, &c.ProposalID, &c.HumanReviewID, &c.Author, &c.Body, &c.ReviewState, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListReviewComments returns an item's comments, oldest first
+func (q *Queries) ListReviewComments(ctx context.Context, t ReviewTarget, id uuid.UUID) ([]models.ReviewComment, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, proposal_id, human_review_id, author, body, COALESCE(review_state, ''), created_at
+		FROM review_comments WHERE `+commentColumn[t]+` = $1
+		ORDER BY created_at
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.ReviewComment{}
+	for rows.Next() {
+		var c models.ReviewComment
+		if err := rows.Scan(&c.ID, &c.ProposalID, &c.HumanReviewID, &c.Author, &c.Body, &c.ReviewState, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
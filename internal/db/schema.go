@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/migrations"
+	"github.com/pressly/goose/v3"
+)
+
+// SchemaVersion is the last migration the queries are written against. Bump it with
+// every new file in migrations/.
+const SchemaVersion int64 = 54
+
+// ErrSchemaOutdated is returned by CheckSchema when migrations are missing
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// Migrate applies the pending migrations embedded in the binary and returns how many ran
+func Migrate(ctx context.Context, conn *sql.DB) (int, error) {
+	provider, err := goose.NewProvider(goose.DialectPostgres, conn, migrations.FS)
+	if err != nil {
+		return 0, fmt.Errorf("load migrations: %w", err)
+	}
+	results, err := provider.Up(ctx)
+	return len(results), err
+}
+
+// AppliedSchemaVersion returns the last migration goose applied to the database
+func (q *Queries) AppliedSchemaVersion(ctx context.Context) (int64, error) {
+	var version int64
+	err := q.pool.QueryRow(ctx, `
+		SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied
+	`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// CheckSchema fails when the database is behind SchemaVersion: the queries rely on every
+// migration and don't fall back to older schemas
+func (q *Queries) CheckSchema(ctx context.Context) error {
+	version, err := q.AppliedSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("%w: migration %d applied, %d required", ErrSchemaOutdated, version, SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== PRODUCT SCORE OPERATIONS =====
+
+// ScoreBins is the number of 0.1-wide score ranges of the distributions
+const ScoreBins = 10
+
+// ProductScore is the rule compliance of a product's data at a version
+type ProductScore struct {
+	ProductID uuid.UUID
+	Version   int
+	Baseline  float64 // only kept when the product has no baseline yet
+	Current   float64
+}
+
+// ListUnscoredProducts returns up to limit products of a dataset whose data changed since
+// they were last scored
+func (q *Queries) ListUnscoredProducts(ctx context.Context, datasetID uuid.UUID, limit int) ([]models.Product, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+productColumns+` FROM products
+		WHERE dataset_id = $1 AND scored_version IS DISTINCT FROM version
+		ORDER BY id LIMIT $2
+	`, datasetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// SaveProductScores records the scores of products. A product edited since it was read keeps
+// an older scored_version and is scored again next time.
+func (q *Queries) SaveProductScores(ctx context.Context, scores []ProductScore) error {
+	batch := &pgx.Batch{}
+	for _, s := range scores {
+		batch.Queue(`
+			UPDATE products SET baseline_score = COALESCE(baseline_score, $2), current_score = $3, scored_version = $4
+			WHERE id = $1
+		`, s.ProductID, s.Baseline, s.Current, s.Version)
+	}
+	return q.pool.SendBatch(ctx, batch).Close()
+}
+
+// RefreshDatasetStats recomputes the score aggregates of every dataset, without blocking
+// the readers of the previous ones
+func (q *Queries) RefreshDatasetStats(ctx context.Context) error {
+	_, err := q.pool.Exec(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY dataset_score_stats`)
+	return err
+}
+
+// GetDatasetScoreStats returns the score aggregates of a dataset as of the last refresh;
+// a dataset without scored products has zero averages and empty distributions
+func (q *Queries) GetDatasetScoreStats(ctx context.Context, datasetID uuid.UUID) (*models.DatasetScoreStats, error) {
+	stats := &models.DatasetScoreStats{
+		BeforeDistribution: make([]int, ScoreBins),
+		AfterDistribution:  make([]int, ScoreBins),
+	}
+	var before, after []byte
+	err := q.pool.QueryRow(ctx, `
+		SELECT scored, avg_before, avg_after, before_distribution, after_distribution
+		FROM dataset_score_stats WHERE dataset_id = $1
+	`, datasetID).Scan(&stats.Scored, &stats.Before, &stats.After, &before, &after)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fillDistribution(stats.BeforeDistribution, before)
+	fillDistribution(stats.AfterDistribution, after)
+	return stats, nil
+}
+
+// fillDistribution copies the {"bin": count} object of the view into bins
+func fillDistribution(bins []int, raw []byte) {
+	var counts map[string]int
+	json.Unmarshal(raw, &counts)
+	for key, n := range counts {
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(bins) {
+			bins[i] = n
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ===== SEGMENT OPERATIONS =====
+
+// productData is the attribute document segment conditions are evaluated against
+const productData = `COALESCE(current_data, raw_data)`
+
+// numericValue extracts the leading number of a text value ("49,99 EUR" -> 49.99)
+const numericValue = `NULLIF(substring(replace(%s, ',', '.') from '[0-9]+(?:\.[0-9]+)?'), '')::numeric`
+
+// ValidateSegmentFilter reports whether a filter can be compiled to SQL
+func ValidateSegmentFilter(f models.SegmentFilter) error {
+	_, _, err := segmentWhere(f, nil)
+	return err
+}
+
+// segmentWhere compiles a filter to a SQL boolean expression whose placeholders
+// continue after args. Field names are always bound as parameters.
+func segmentWhere(f models.SegmentFilter, args []any) (string, []any, error) {
+	join := " AND "
+	switch f.Match {
+	case "", "all":
+	case "any":
+		join = " OR "
+	default:
+		return "", nil, fmt.Errorf("invalid match %q (use all or any)", f.Match)
+	}
+	if len(f.Conditions) == 0 {
+		return "TRUE", args, nil
+	}
+
+	parts := make([]string, 0, len(f.Conditions))
+	for i, cond := range f.Conditions {
+		var clause string
+		var err error
+		clause, args, err = conditionSQL(cond, args)
+		if err != nil {
+			return "", nil, fmt.Errorf("condition %d: %w", i+1, err)
+		}
+		parts = append(parts, "("+clause+")")
+	}
+	return strings.Join(parts, join), args, nil
+}
+
+func conditionSQL(c models.SegmentCondition, args []any) (string, []any, error) {
+	field := strings.TrimSpace(c.Field)
+	if field == "" {
+		return "", nil, fmt.Errorf("field is required")
+	}
+	bind := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if field == "tag" {
+		switch c.Op {
+		case "eq", "contains":
+			return bind(valueString(c.Value)) + " = ANY(tags)", args, nil
+		case "neq", "not_contains":
+			return "NOT (" + bind(valueString(c.Value)) + " = ANY(tags))", args, nil
+		case "in":
+			return "tags && " + bind(valueStrings(c.Value)) + "::text[]", args, nil
+		case "missing":
+			return "cardinality(tags) = 0", args, nil
+		case "present":
+			return "cardinality(tags) > 0", args, nil
+		}
+		return "", nil, fmt.Errorf("operator %q not supported on tag", c.Op)
+	}
+
+	// Text and numeric expressions for the field
+	var text, num string
+	switch field {
+	case "status", "external_id":
+		text = field
+		num = fmt.Sprintf(numericValue, field)
+	case "score":
+		text = "agent_readiness_score::text"
+		num = "agent_readiness_score"
+	default:
+		text = productData + "->>" + bind(field)
+		num = fmt.Sprintf(numericValue, text)
+	}
+
+	switch c.Op {
+	case "eq":
+		return "LOWER(" + text + ") = LOWER(" + bind(valueString(c.Value)) + ")", args, nil
+	case "neq":
+		return "COALESCE(LOWER(" + text + "), '') <> LOWER(" + bind(valueString(c.Value)) + ")", args, nil
+	case "contains":
+		return text + " ILIKE '%' || " + bind(valueString(c.Value)) + " || '%'", args, nil
+	case "not_contains":
+		return "COALESCE(" + text + ", '') NOT ILIKE '%' || " + bind(valueString(c.Value)) + " || '%'", args, nil
+	case "in":
+		values := valueStrings(c.Value)
+		for i := range values {
+			values[i] = strings.ToLower(values[i])
+		}
+		return "LOWER(" + text + ") = ANY(" + bind(values) + "::text[])", args, nil
+	case "missing":
+		return "COALESCE(TRIM(" + text + "), '') = ''", args, nil
+	case "present":
+		return "COALESCE(TRIM(" + text + "), '') <> ''", args, nil
+	case "lt", "lte", "gt", "gte":
+		n, err := valueNumber(c.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		op := map[string]string{"lt": "<", "lte": "<=", "gt": ">", "gte": ">="}[c.Op]
+		return num + " " + op + " " + bind(n), args, nil
+	}
+	return "", nil, fmt.Errorf("unknown operator %q", c.Op)
+}
+
+func valueString(v any) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func valueStrings(v any) []string {
+	switch vals := v.(type) {
+	case []string:
+		return vals
+	case []any:
+		out := make([]string, 0, len(vals))
+		for _, x := range vals {
+			out = append(out, valueString(x))
+		}
+		return out
+	case string:
+		out := []string{}
+		for _, s := range strings.Split(vals, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return []string{valueString(v)}
+}
+
+func valueNumber(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err != nil {
+			return 0, fmt.Errorf("value %q is not a number", n)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("value %v is not a number", v)
+}
+
+// ListProductsMatching returns dataset products carrying all tags and matching the filter (nil = no filter)
+func (q *Queries) ListProductsMatching(ctx context.Context, datasetID uuid.UUID, tags []string, filter *models.SegmentFilter) ([]models.Product, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	where := "TRUE"
+	args := []any{datasetID, tags}
+	if filter != nil {
+		var err error
+		where, args, err = segmentWhere(*filter, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+productColumns+`
+		FROM products WHERE dataset_id = $1 AND tags @> $2 AND (`+where+`) ORDER BY created_at
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+const segmentColumns = `id, dataset_id, name, COALESCE(description, ''), filter, created_at, updated_at`
+
+func scanSegment(row pgx.Row, s *models.Segment) error {
+	var filter []byte
+	if err := row.Scan(&s.ID, &s.DatasetID, &s.Name, &s.Description, &filter, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(filter, &s.Filter)
+}
+
+func (q *Queries) CreateSegment(ctx context.Context, s models.Segment) error {
+	filter, err := json.Marshal(s.Filter)
+	if err != nil {
+		return err
+	}
+	_, err = q.pool.Exec(ctx, `
+		INSERT INTO segments (id, dataset_id, name, description, filter, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
+	`, s.ID, s.DatasetID, s.Name, s.Description, filter, s.CreatedAt, s.UpdatedAt)
+	return err
+}
+
+func (q *Queries) GetSegment(ctx context.Context, id uuid.UUID) (*models.Segment, error) {
+	var s models.Segment
+	if err := scanSegment(q.pool.QueryRow(ctx, `SELECT `+segmentColumns+` FROM segments WHERE id = $1`, id), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (q *Queries) ListSegments(ctx context.Context, datasetID uuid.UUID) ([]models.Segment, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+segmentColumns+` FROM segments WHERE dataset_id = $1 ORDER BY name
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []models.Segment
+	for rows.Next() {
+		var s models.Segment
+		if err := scanSegment(rows, &s); err != nil {
+			return nil, err
+		}
+		segments = append(segments, s)
+	}
+	return segments, nil
+}
+
+func (q *Queries) UpdateSegment(ctx context.Context, s models.Segment) error {
+	filter, err := json.Marshal(s.Filter)
+	if err != nil {
+		return err
+	}
+	_, err = q.pool.Exec(ctx, `
+		UPDATE segments SET name = $2, description = NULLIF($3, ''), filter = $4, updated_at = NOW()
+		WHERE id = $1
+	`, s.ID, s.Name, s.Description, filter)
+	return err
+}
+
+func (q *Queries) DeleteSegment(ctx context.Context, id uuid.UUID) error {
+	_, err := q.pool.Exec(ctx, `DELETE FROM segments WHERE id = $1`, id)
+	return err
+}
+
+// GetSegmentStats returns product and proposal counts for the products in a segment
+func (q *Queries) GetSegmentStats(ctx context.Context, s models.Segment) (map[string]any, error) {
+	where, args, err := segmentWhere(s.Filter, []any{s.DatasetID})
+	if err != nil {
+		return nil, err
+	}
+
+	var total, enriched, pending int
+	var avgScore *float64
+	err = q.pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status = 'enriched'),
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			AVG(agent_readiness_score)
+		FROM products WHERE dataset_id = $1 AND (`+where+`)
+	`, args...).Scan(&total, &enriched, &pending, &avgScore)
+	if err != nil {
+		return nil, err
+	}
+
+	var proposalsTotal, proposalsAccepted, proposalsPending int
+	err = q.pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE p.status IN ('accepted', 'edited')),
+			COUNT(*) FILTER (WHERE p.status = 'proposed')
+		FROM proposals p
+		WHERE p.status <> 'simulated' AND p.product_id IN (SELECT id FROM products WHERE dataset_id = $1 AND (`+where+`))
+	`, args...).Scan(&proposalsTotal, &proposalsAccepted, &proposalsPending)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"segment_id": s.ID,
+		"products": map[string]int{
+			"total":    total,
+			"enriched": enriched,
+			"pending":  pending,
+		},
+		"avg_score": avgScore,
+		"proposals": map[string]int{
+			"total":    proposalsTotal,
+			"accepted": proposalsAccepted,
+			"pending":  proposalsPending,
+		},
+	}, nil
+}
+
+// ListProductsInScope lists the products a job targets: the whole dataset or a saved
+// segment of it, narrowed to products carrying all tags
+func (q *Queries) ListProductsInScope(ctx context.Context, datasetID uuid.UUID, segmentID *uuid.UUID, tags []string) ([]models.Product, error) {
+	if segmentID == nil {
+		return q.ListProductsMatching(ctx, datasetID, tags, nil)
+	}
+	s, err := q.GetSegment(ctx, *segmentID)
+	if err != nil {
+		return nil, err
+	}
+	if s.DatasetID != datasetID {
+		return nil, fmt.Errorf("segment %s does not belong to dataset %s", s.ID, datasetID)
+	}
+	return q.ListProductsMatching(ctx, datasetID, tags, &s.Filter)
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== SHADOW EVALUATION OPERATIONS =====
+
+// CreateShadowRun stores a shadow run and its proposals in one transaction
+func (q *Queries) CreateShadowRun(ctx context.Context, r models.ShadowRun) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	primary := r.PrimaryProposals
+	if primary == nil {
+		primary = []byte("[]")
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO shadow_runs (id, product_id, job_id, module, variant, model, status, error,
+			primary_proposals, shadow_count, primary_count, field_overlap, agreed, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12, $13, $14, $15)
+	`, r.ID, r.ProductID, r.JobID, r.Module, r.Variant, r.Model, r.Status, r.Error,
+		primary, r.ShadowCount, r.PrimaryCount, r.FieldOverlap, r.Agreed, r.DurationMs, r.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range r.Proposals {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO shadow_proposals (id, run_id, field, before_value, after_value, rationale, confidence, risk_level, matches_primary, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, p.ID, r.ID, p.Field, p.BeforeValue, p.AfterValue, p.Rationale, p.Confidence, p.RiskLevel, p.MatchesPrimary, p.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+const shadowRunColumns = `id, product_id, job_id, module, variant, model, status, COALESCE(error, ''),
+	primary_proposals, shadow_count, primary_count, field_overlap, agreed, duration_ms, created_at`
+
+// ListShadowRuns returns recent shadow runs, optionally for one variant
+func (q *Queries) ListShadowRuns(ctx context.Context, variant string, limit int) ([]models.ShadowRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+shadowRunColumns+`
+		FROM shadow_runs WHERE ($1 = '' OR variant = $1)
+		ORDER BY created_at DESC LIMIT $2
+	`, variant, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.ShadowRun
+	for rows.Next() {
+		var r models.ShadowRun
+		if err := rows.Scan(&r.ID, &r.ProductID, &r.JobID, &r.Module, &r.Variant, &r.Model, &r.Status, &r.Error,
+			&r.PrimaryProposals, &r.ShadowCount, &r.PrimaryCount, &r.FieldOverlap, &r.Agreed, &r.DurationMs, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
+// GetShadowRun returns a shadow run with its proposals
+func (q *Queries) GetShadowRun(ctx context.Context, id uuid.UUID) (*models.ShadowRun, error) {
+	var r models.ShadowRun
+	err := q.pool.QueryRow(ctx, `SELECT `+shadowRunColumns+` FROM shadow_runs WHERE id = $1`, id).Scan(
+		&r.ID, &r.ProductID, &r.JobID, &r.Module, &r.Variant, &r.Model, &r.Status, &r.Error,
+		&r.PrimaryProposals, &r.ShadowCount, &r.PrimaryCount, &r.FieldOverlap, &r.Agreed, &r.DurationMs, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, run_id, field, before_value, COALESCE(after_value, ''), COALESCE(rationale, '{}'),
+			COALESCE(confidence, 0), COALESCE(risk_level, ''), matches_primary, created_at
+		FROM shadow_proposals WHERE run_id = $1 ORDER BY field
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.ShadowProposal
+		if err := rows.Scan(&p.ID, &p.RunID, &p.Field, &p.BeforeValue, &p.AfterValue, &p.Rationale,
+			&p.Confidence, &p.RiskLevel, &p.MatchesPrimary, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Proposals = append(r.Proposals, p)
+	}
+	return &r, nil
+}
+
+// GetShadowSummaries aggregates shadow runs per variant, model and module
+func (q *Queries) GetShadowSummaries(ctx context.Context, variant string) ([]models.ShadowSummary, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT variant, model, module,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status = 'failed'),
+			COALESCE(SUM(shadow_count), 0),
+			COALESCE(SUM(primary_count), 0),
+			COALESCE(SUM(field_overlap), 0),
+			COALESCE(SUM(agreed), 0),
+			COALESCE(AVG(duration_ms), 0)
+		FROM shadow_runs WHERE ($1 = '' OR variant = $1)
+		GROUP BY variant, model, module
+		ORDER BY variant, module
+	`, variant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.ShadowSummary
+	for rows.Next() {
+		var s models.ShadowSummary
+		if err := rows.Scan(&s.Variant, &s.Model, &s.Module, &s.Runs, &s.Failed, &s.ShadowProposals,
+			&s.PrimaryProposals, &s.FieldOverlap, &s.Agreed, &s.AvgDurationMs); err != nil {
+			return nil, err
+		}
+		if s.FieldOverlap > 0 {
+			s.AgreementRate = float64(s.Agreed) / float64(s.FieldOverlap)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, nil
+}
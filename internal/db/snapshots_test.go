@@ -0,0 +1,72 @@
+//go:build integration
+
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// snapshotDataRows counts the snapshot data of the products of a dataset
+func snapshotDataRows(t *testing.T, datasetID uuid.UUID) int {
+	var n int
+	err := benchPool.QueryRow(context.Background(), `
+		SELECT COUNT(*) FROM snapshot_data
+		WHERE hash IN (SELECT md5(jsonb_build_array(raw_data, current_data)::text) FROM products WHERE dataset_id = $1)
+	`, datasetID).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// snapshotDataset creates a dataset of the default organization with n products and one snapshot
+func snapshotDataset(t *testing.T, n int) uuid.UUID {
+	ctx := context.Background()
+	org, err := benchQueries.GetOrganization(ctx, db.DefaultOrganization)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataset := models.Dataset{ID: uuid.New(), OrgID: org.ID, Name: "snapshots", Status: "uploaded", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := benchQueries.CreateDatasetWithProducts(ctx, &dataset, benchProducts(dataset.ID, n)); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := models.DatasetSnapshot{ID: uuid.New(), DatasetID: dataset.ID, Name: "manual", SnapshotType: "manual", CreatedAt: time.Now()}
+	if err := benchQueries.CreateSnapshot(ctx, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	return dataset.ID
+}
+
+func TestDeleteDatasetSnapshotData(t *testing.T) {
+	ctx := context.Background()
+	// Both datasets have the same product data, shared by their snapshots
+	deleted, kept := snapshotDataset(t, 5), snapshotDataset(t, 3)
+	t.Cleanup(func() { benchQueries.DeleteDataset(context.Background(), kept) })
+
+	if n := snapshotDataRows(t, deleted); n != 5 {
+		t.Fatalf("%d snapshot data rows, want 5", n)
+	}
+	if err := benchQueries.DeleteDataset(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+	if n := snapshotDataRows(t, kept); n != 3 {
+		t.Fatalf("%d snapshot data rows of the other dataset, want 3", n)
+	}
+
+	var orphans int
+	if err := benchPool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM snapshot_data d
+		WHERE NOT EXISTS (SELECT 1 FROM snapshot_products sp WHERE sp.data_hash = d.hash)
+	`).Scan(&orphans); err != nil {
+		t.Fatal(err)
+	}
+	if orphans != 0 {
+		t.Fatalf("%d snapshot data rows left unreferenced", orphans)
+	}
+}
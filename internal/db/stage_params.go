@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// ===== STAGE PARAMS OPERATIONS =====
+
+// ListStageParams returns the generation parameter overrides of every stage that has one
+func (q *Queries) ListStageParams(ctx context.Context) ([]models.StageParams, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT stage, temperature, max_tokens, seed, updated_at FROM stage_params ORDER BY stage
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	params := []models.StageParams{}
+	for rows.Next() {
+		var p models.StageParams
+		if err := rows.Scan(&p.Stage, &p.Temperature, &p.MaxTokens, &p.Seed, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		params = append(params, p)
+	}
+	return params, rows.Err()
+}
+
+// SetStageParams replaces the overrides of a stage; all nil removes them
+func (q *Queries) SetStageParams(ctx context.Context, p *models.StageParams) error {
+	if p.Temperature == nil && p.MaxTokens == nil && p.Seed == nil {
+		_, err := q.pool.Exec(ctx, `DELETE FROM stage_params WHERE stage = $1`, p.Stage)
+		return err
+	}
+	return q.pool.QueryRow(ctx, `
+		INSERT INTO stage_params (stage, temperature, max_tokens, seed)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (stage) DO UPDATE SET
+			temperature = EXCLUDED.temperature,
+			max_tokens = EXCLUDED.max_tokens,
+			seed = EXCLUDED.seed,
+			updated_at = NOW()
+		RETURNING updated_at
+	`, p.Stage, p.Temperature, p.MaxTokens, p.Seed).Scan(&p.UpdatedAt)
+}
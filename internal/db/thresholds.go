@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== DATASET THRESHOLD OPERATIONS =====
+
+// GetDatasetThresholds returns the dataset's threshold overrides (empty when none are set)
+func (q *Queries) GetDatasetThresholds(ctx context.Context, datasetID uuid.UUID) (*models.ThresholdOverrides, error) {
+	var raw []byte
+	err := q.pool.QueryRow(ctx, `SELECT thresholds FROM datasets WHERE id = $1`, datasetID).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	var o models.ThresholdOverrides
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &o); err != nil {
+			return nil, err
+		}
+	}
+	return &o, nil
+}
+
+// UpdateDatasetThresholds replaces the dataset's threshold overrides
+func (q *Queries) UpdateDatasetThresholds(ctx context.Context, datasetID uuid.UUID, o models.ThresholdOverrides) error {
+	raw, _ := json.Marshal(o)
+	_, err := q.pool.Exec(ctx, `UPDATE datasets SET thresholds = $2, updated_at = NOW() WHERE id = $1`, datasetID, raw)
+	return err
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== TOKEN USAGE ATTRIBUTION OPERATIONS =====
+
+// RecordTokenAttribution adds a call's usage to its session's total for the model;
+// calls outside a session get a row each
+func (q *Queries) RecordTokenAttribution(ctx context.Context, a models.TokenAttribution, promptTokens, completionTokens int, costUSD float64) error {
+	_, err := q.pool.Exec(ctx, `
+		INSERT INTO token_usage_attribution (session_id, dataset_id, product_id, module, model, date, prompt_tokens, completion_tokens, cost_usd, api_calls)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_DATE, $6, $7, $8, 1)
+		ON CONFLICT (session_id, model) DO UPDATE SET
+			prompt_tokens = token_usage_attribution.prompt_tokens + EXCLUDED.prompt_tokens,
+			completion_tokens = token_usage_attribution.completion_tokens + EXCLUDED.completion_tokens,
+			cost_usd = token_usage_attribution.cost_usd + EXCLUDED.cost_usd,
+			api_calls = token_usage_attribution.api_calls + 1,
+			updated_at = NOW()
+	`, a.SessionID, a.DatasetID, a.ProductID, a.Module, a.Model, promptTokens, completionTokens, costUSD)
+	return err
+}
+
+// usageGroupings are the columns token usage can be grouped by: key, label, dataset
+// (product groups) and the GROUP BY clause
+var usageGroupings = map[string][4]string{
+	"dataset": {"a.dataset_id::text", "COALESCE(d.name, '')", "NULL::uuid", "a.dataset_id, d.name"},
+	"product": {"a.product_id::text", "COALESCE(pr.external_id, '')", "a.dataset_id", "a.product_id, a.dataset_id, pr.external_id"},
+	"module":  {"COALESCE(NULLIF(a.module, ''), 'unknown')", "COALESCE(NULLIF(a.module, ''), 'unknown')", "NULL::uuid", "COALESCE(NULLIF(a.module, ''), 'unknown')"},
+}
+
+// ValidUsageGrouping reports whether token usage can be grouped by g
+func ValidUsageGrouping(g string) bool {
+	_, ok := usageGroupings[g]
+	return ok
+}
+
+// GetTokenUsageGroups returns the usage of an organization over the last days grouped by
+// dataset, product or module, costliest first, optionally for one dataset
+func (q *Queries) GetTokenUsageGroups(ctx context.Context, orgID uuid.UUID, days int, groupBy string, datasetID *uuid.UUID, limit int) ([]models.TokenUsageGroup, error) {
+	g, ok := usageGroupings[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown token usage grouping %q", groupBy)
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	productsOnly := ""
+	if groupBy == "product" {
+		productsOnly = "AND a.product_id IS NOT NULL"
+	}
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+g[0]+`, `+g[1]+`, `+g[2]+`,
+			SUM(a.prompt_tokens)::bigint, SUM(a.completion_tokens)::bigint,
+			SUM(a.prompt_tokens + a.completion_tokens)::bigint, SUM(a.cost_usd), SUM(a.api_calls)::bigint,
+			COUNT(DISTINCT a.session_id)
+		FROM token_usage_attribution a
+		LEFT JOIN datasets d ON d.id = a.dataset_id
+		LEFT JOIN products pr ON pr.id = a.product_id
+		WHERE a.date >= CURRENT_DATE - $1::integer
+		AND ($2::uuid IS NULL OR a.dataset_id = $2) `+productsOnly+`
+		AND d.org_id = $4
+		GROUP BY `+g[3]+`
+		ORDER BY SUM(a.cost_usd) DESC, 6 DESC
+		LIMIT $3
+	`, days, datasetID, limit, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.TokenUsageGroup{}
+	for rows.Next() {
+		var u models.TokenUsageGroup
+		if err := rows.Scan(&u.Key, &u.Label, &u.DatasetID, &u.PromptTokens, &u.CompletionTokens,
+			&u.TotalTokens, &u.CostUSD, &u.APICalls, &u.Sessions); err != nil {
+			return nil, err
+		}
+		groups = append(groups, u)
+	}
+	return groups, rows.Err()
+}
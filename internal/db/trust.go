@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== TRUSTED DOMAIN OPERATIONS =====
+
+// ListTrustedDomains returns the trusted domains of a dataset, by domain
+func (q *Queries) ListTrustedDomains(ctx context.Context, datasetID uuid.UUID) ([]models.TrustedDomain, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT id, dataset_id, domain, brand, created_at
+		FROM trusted_domains
+		WHERE dataset_id = $1
+		ORDER BY LOWER(domain), LOWER(brand)
+	`, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []models.TrustedDomain{}
+	for rows.Next() {
+		var d models.TrustedDomain
+		if err := rows.Scan(&d.ID, &d.DatasetID, &d.Domain, &d.Brand, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// AddTrustedDomain trusts a domain for a dataset; adding it again returns the existing one
+func (q *Queries) AddTrustedDomain(ctx context.Context, d *models.TrustedDomain) error {
+	return q.pool.QueryRow(ctx, `
+		INSERT INTO trusted_domains (dataset_id, domain, brand)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (dataset_id, LOWER(domain), LOWER(brand)) DO UPDATE SET domain = EXCLUDED.domain
+		RETURNING id, created_at
+	`, d.DatasetID, d.Domain, d.Brand).Scan(&d.ID, &d.CreatedAt)
+}
+
+// DeleteTrustedDomain removes a trusted domain and returns its dataset; pgx.ErrNoRows when
+// there is none
+func (q *Queries) DeleteTrustedDomain(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	var datasetID uuid.UUID
+	err := q.pool.QueryRow(ctx, `DELETE FROM trusted_domains WHERE id = $1 RETURNING dataset_id`, id).Scan(&datasetID)
+	return datasetID, err
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ===== VARIANT OPERATIONS =====
+
+// ListVariantSiblings returns the other products of an item group in a dataset
+func (q *Queries) ListVariantSiblings(ctx context.Context, datasetID uuid.UUID, itemGroupID string, exclude uuid.UUID) ([]models.Product, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+productColumns+` FROM products
+		WHERE dataset_id = $1 AND id <> $3
+		AND COALESCE(NULLIF(current_data->>'item_group_id', ''), raw_data->>'item_group_id') = $2
+		ORDER BY created_at
+	`, datasetID, itemGroupID, exclude)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// ListPendingProposalFields returns, per product, the fields with a proposal awaiting review
+func (q *Queries) ListPendingProposalFields(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID]map[string]bool, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT DISTINCT product_id, field FROM proposals
+		WHERE product_id = ANY($1) AND status = 'proposed'
+	`, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := map[uuid.UUID]map[string]bool{}
+	for rows.Next() {
+		var id uuid.UUID
+		var field string
+		if err := rows.Scan(&id, &field); err != nil {
+			return nil, err
+		}
+		if pending[id] == nil {
+			pending[id] = map[string]bool{}
+		}
+		pending[id][field] = true
+	}
+	return pending, rows.Err()
+}
@@ -0,0 +1,368 @@
+//go:build integration
+
+// Package e2e walks a dataset through upload → enrich → review → revert → export against
+// Postgres in a container (testcontainers) and a fake LLM. Needs Docker:
+//
+//	go test -tags integration ./internal/e2e
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/api"
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/testdb"
+)
+
+// feed is the uploaded dataset: no image links so no vision call is made
+const feed = "id\ttitle\tdescription\tprice\tbrand\n" +
+	"SKU-1\ttrail shoe\tLightweight trail running shoe.\t89.00 EUR\tAcme\n" +
+	"SKU-2\train jacket\tWaterproof rain jacket.\t120.00 EUR\tAcme\n"
+
+// improvedTitle is what the fake LLM proposes for every product
+const improvedTitle = "Acme Trail Running Shoe - Lightweight"
+
+type product struct {
+	ID         string `json:"id"`
+	ExternalID string `json:"external_id"`
+}
+
+type proposal struct {
+	ID         string `json:"id"`
+	ProductID  string `json:"product_id"`
+	Field      string `json:"field"`
+	AfterValue string `json:"after_value"`
+	Status     string `json:"status"`
+}
+
+func TestEndToEnd(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	h := &harness{ctx: ctx, client: &http.Client{Timeout: 30 * time.Second}}
+	h.startAPI(t, h.startFakeLLM(t))
+
+	var datasetID string
+	var products []product
+	var proposals []proposal
+
+	steps := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"upload dataset", func(t *testing.T) {
+			var dataset struct {
+				ID       string `json:"id"`
+				RowCount int    `json:"row_count"`
+			}
+			h.upload(t, "e2e", feed, &dataset)
+			if dataset.RowCount != 2 {
+				t.Fatalf("row_count = %d, want 2", dataset.RowCount)
+			}
+			datasetID = dataset.ID
+
+			var list struct {
+				Data []product `json:"data"`
+			}
+			h.call(t, http.MethodGet, "/datasets/"+datasetID+"/products", nil, http.StatusOK, &list)
+			if len(list.Data) != 2 {
+				t.Fatalf("%d products, want 2", len(list.Data))
+			}
+			products = list.Data
+		}},
+
+		{"enrich dataset", func(t *testing.T) {
+			var started struct {
+				JobID string `json:"job_id"`
+			}
+			body := map[string]any{"group": "title_optimization"}
+			h.call(t, http.MethodPost, "/datasets/"+datasetID+"/audit", body, http.StatusAccepted, &started)
+			h.waitJob(t, started.JobID, len(products))
+		}},
+
+		{"review proposals", func(t *testing.T) {
+			var list struct {
+				Data []proposal `json:"data"`
+			}
+			h.call(t, http.MethodGet, "/proposals/with-products", nil, http.StatusOK, &list)
+			if len(list.Data) != len(products) {
+				t.Fatalf("%d proposals, want one per product (%d)", len(list.Data), len(products))
+			}
+			proposals = list.Data
+			h.call(t, http.MethodPatch, "/proposals/"+proposals[0].ID, map[string]string{"action": "accept"}, http.StatusOK, nil)
+			h.call(t, http.MethodPatch, "/proposals/"+proposals[1].ID, map[string]string{"action": "reject"}, http.StatusOK, nil)
+
+			var accepted proposal
+			h.call(t, http.MethodGet, "/proposals/"+proposals[0].ID, nil, http.StatusOK, &accepted)
+			if accepted.Status != "accepted" {
+				t.Fatalf("proposal status = %q, want accepted", accepted.Status)
+			}
+			if title := h.productTitle(t, proposals[0].ProductID); title != improvedTitle {
+				t.Fatalf("current title = %q after accept, want %q", title, improvedTitle)
+			}
+		}},
+
+		{"revert proposal", func(t *testing.T) {
+			id := proposals[0].ID
+			h.call(t, http.MethodPost, "/proposals/"+id+"/revert", nil, http.StatusOK, nil)
+			// A second revert, and the revert of a rejected proposal, conflict
+			h.call(t, http.MethodPost, "/proposals/"+id+"/revert", nil, http.StatusConflict, nil)
+			h.call(t, http.MethodPost, "/proposals/"+proposals[1].ID+"/revert", nil, http.StatusConflict, nil)
+
+			var reverted proposal
+			h.call(t, http.MethodGet, "/proposals/"+id, nil, http.StatusOK, &reverted)
+			if reverted.Status != "reverted" {
+				t.Fatalf("proposal status = %q, want reverted", reverted.Status)
+			}
+			title := h.productTitle(t, proposals[0].ProductID)
+			if title == improvedTitle || title == "" {
+				t.Fatalf("current title = %q after revert, want the original", title)
+			}
+
+			var changes struct {
+				Data []struct {
+					Action   string `json:"action"`
+					OldValue string `json:"old_value"`
+					NewValue string `json:"new_value"`
+				} `json:"data"`
+			}
+			h.call(t, http.MethodGet, "/datasets/"+datasetID+"/changelog", nil, http.StatusOK, &changes)
+			actions := map[string]int{}
+			for _, e := range changes.Data {
+				actions[e.Action]++
+				if e.Action == "proposal_reverted" && (e.OldValue != improvedTitle || e.NewValue != title) {
+					t.Errorf("revert entry %q -> %q, want %q -> %q", e.OldValue, e.NewValue, improvedTitle, title)
+				}
+			}
+			if actions["proposal_accepted"] != 1 || actions["proposal_reverted"] != 1 {
+				t.Fatalf("change log actions = %v, want one accepted and one reverted", actions)
+			}
+		}},
+
+		{"export dataset", func(t *testing.T) {
+			var exported []product
+			h.call(t, http.MethodGet, "/datasets/"+datasetID+"/export?format=json", nil, http.StatusOK, &exported)
+			if len(exported) != len(products) {
+				t.Fatalf("exported %d products, want %d", len(exported), len(products))
+			}
+		}},
+	}
+
+	// Each step builds on the previous ones: stop at the first failure
+	for _, step := range steps {
+		if !t.Run(step.name, step.run) {
+			return
+		}
+	}
+}
+
+type harness struct {
+	ctx      context.Context
+	client   *http.Client
+	apiURL   string
+	llmCalls atomic.Int64
+}
+
+// startFakeLLM serves OpenAI chat completions with a fixed title proposal and returns its URL
+func (h *harness) startFakeLLM(t *testing.T) string {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+		n := h.llmCalls.Add(1)
+		content, _ := json.Marshal(map[string]any{
+			"score":  0.6,
+			"issues": []any{},
+			"proposals": []map[string]any{{
+				"field":      "title",
+				"before":     "original title",
+				"after":      improvedTitle,
+				"rationale":  "Adds brand and key attribute",
+				"source":     "feed",
+				"confidence": 0.9,
+				"risk_level": "low",
+			}},
+		})
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      fmt.Sprintf("chatcmpl-e2e-%d", n),
+			"object":  "chat.completion",
+			"created": time.Now().Unix(),
+			"model":   "gpt-4o-mini",
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]string{"role": "assistant", "content": string(content)},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]int{"prompt_tokens": 100, "completion_tokens": 50, "total_tokens": 150},
+		})
+	}))
+	t.Cleanup(llm.Close)
+	return llm.URL
+}
+
+// startAPI starts Postgres and serves the API against it and the fake LLM at llmURL
+func (h *harness) startAPI(t *testing.T, llmURL string) {
+	dbURL, stop, err := testdb.Start(h.ctx)
+	if err != nil {
+		t.Fatalf("start postgres: %v", err)
+	}
+	t.Cleanup(stop)
+
+	port := freePort(t)
+	for k, v := range map[string]string{
+		"DATABASE_URL":       dbURL,
+		"OPENAI_API_KEY":     "sk-e2e",
+		"OPENAI_BASE_URL":    llmURL + "/v1",
+		"PORT":               port,
+		"STORAGE_PATH":       t.TempDir(),
+		"RETRY_MAX_ATTEMPTS": "1",
+		"RETENTION_ENABLED":  "false",
+	} {
+		t.Setenv(k, v)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	pool, err := db.Connect(h.ctx, cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	server := api.NewServer(cfg, db.New(pool, cfg))
+	go server.Start(h.ctx)
+	t.Cleanup(pool.Close)
+	// Registered after the pool's close so the server is drained first
+	t.Cleanup(func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	})
+
+	h.apiURL = "http://127.0.0.1:" + port + api.APIPrefix
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if resp, err := h.client.Get("http://127.0.0.1:" + port + "/health"); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+	}
+	t.Fatalf("API did not become healthy on port %s", port)
+}
+
+// call sends a JSON request to the API and decodes the response into out (if not nil)
+func (h *harness) call(t *testing.T, method, path string, body any, wantStatus int, out any) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, _ := json.Marshal(body)
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(h.ctx, method, h.apiURL+path, reader)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	h.do(t, req, wantStatus, out)
+}
+
+func (h *harness) upload(t *testing.T, name, content string, out any) {
+	t.Helper()
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	form.WriteField("name", name)
+	part, _ := form.CreateFormFile("file", "feed.tsv")
+	part.Write([]byte(content))
+	form.Close()
+
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodPost, h.apiURL+"/datasets/upload", &buf)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	h.do(t, req, http.StatusCreated, out)
+}
+
+func (h *harness) do(t *testing.T, req *http.Request, wantStatus int, out any) {
+	t.Helper()
+	resp, err := h.client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: status %d, want %d: %s", req.Method, req.URL.Path, resp.StatusCode, wantStatus, raw)
+	}
+	if out == nil {
+		return
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		t.Fatalf("%s %s: decode: %v", req.Method, req.URL.Path, err)
+	}
+}
+
+// productTitle returns the title in a product's current_data
+func (h *harness) productTitle(t *testing.T, productID string) string {
+	t.Helper()
+	var product struct {
+		CurrentData map[string]any `json:"current_data"`
+	}
+	h.call(t, http.MethodGet, "/products/"+productID, nil, http.StatusOK, &product)
+	title, _ := product.CurrentData["title"].(string)
+	return title
+}
+
+// waitJob polls a job until it completes, expecting every product processed
+func (h *harness) waitJob(t *testing.T, jobID string, wantProcessed int) {
+	t.Helper()
+	for deadline := time.Now().Add(60 * time.Second); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		var job struct {
+			Status         string  `json:"status"`
+			Error          *string `json:"error"`
+			ProcessedItems int     `json:"processed_items"`
+		}
+		h.call(t, http.MethodGet, "/jobs/"+jobID, nil, http.StatusOK, &job)
+		switch job.Status {
+		case "completed":
+			if job.ProcessedItems != wantProcessed {
+				t.Fatalf("job processed %d products, want %d", job.ProcessedItems, wantProcessed)
+			}
+			return
+		case "failed", "cancelled":
+			msg := ""
+			if job.Error != nil {
+				msg = *job.Error
+			}
+			t.Fatalf("job %s: %s", job.Status, msg)
+		}
+	}
+	t.Fatalf("job %s did not complete in time", jobID)
+}
+
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("free port: %v", err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
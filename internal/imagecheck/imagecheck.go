@@ -0,0 +1,251 @@
+// Package imagecheck requests the image_link of every product of a dataset the way Google's
+// crawler does and records what it got: status, content type and redirects. 404s, HTML
+// served instead of an image and crawler or hotlink blocks are caught without a model call,
+// before vision analysis spends tokens on an image that can't be seen.
+package imagecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrRunning is returned when a check is started while the dataset's previous one runs
+var ErrRunning = errors.New("an image check is already running for this dataset")
+
+const (
+	googleUserAgent  = "Googlebot-Image/1.0"
+	browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+	// foreignReferer asks for the image as a page of another site would
+	foreignReferer = "https://www.example.com/"
+	sniffBytes     = 512 // bytes read from a GET to tell an image from a page
+)
+
+// Checker runs the image checks of datasets in the background, one at a time per dataset
+type Checker struct {
+	config  *config.Config
+	queries *db.Queries
+	client  *http.Client
+
+	mu      sync.Mutex
+	running map[uuid.UUID]*progress
+}
+
+type progress struct {
+	total   int
+	checked atomic.Int32
+}
+
+// New creates a checker. Private and loopback hosts are refused like in the image proxy
+// (IMAGE_PROXY_ALLOW_PRIVATE).
+func New(cfg *config.Config, queries *db.Queries) *Checker {
+	return &Checker{
+		config:  cfg,
+		queries: queries,
+		client: &http.Client{
+			Timeout:   cfg.ImageCheck.Timeout,
+			Transport: imageproxy.SafeTransport(cfg.ImageProxy.AllowPrivate),
+		},
+		running: map[uuid.UUID]*progress{},
+	}
+}
+
+// Start checks the image_link of products in the background, IMAGE_CHECK_CONCURRENCY at a
+// time and at most IMAGE_CHECK_RATE requests per second
+func (c *Checker) Start(datasetID uuid.UUID, products []models.Product) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.running[datasetID]; ok {
+		return ErrRunning
+	}
+	p := &progress{total: len(products)}
+	c.running[datasetID] = p
+	go c.run(datasetID, products, p)
+	return nil
+}
+
+func (c *Checker) run(datasetID uuid.UUID, products []models.Product, p *progress) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.running, datasetID)
+		c.mu.Unlock()
+	}()
+	ctx := context.Background()
+	interval := time.Second
+	if c.config.ImageCheck.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / c.config.ImageCheck.Rate)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var problems atomic.Int32
+	var wg sync.WaitGroup
+	next := make(chan *models.Product)
+	for w := 0; w < max(c.config.ImageCheck.Concurrency, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for product := range next {
+				check := c.check(ctx, ticker.C, agent.ImageLink(product))
+				check.ProductID, check.DatasetID = product.ID, product.DatasetID
+				if check.Problem != "" {
+					problems.Add(1)
+				}
+				if err := c.queries.SaveImageCheck(ctx, &check); err != nil {
+					fmt.Printf("Failed to save the image check of product %s: %v\n", product.ID, err)
+				}
+				p.checked.Add(1)
+			}
+		}()
+	}
+	for i := range products {
+		next <- &products[i]
+	}
+	close(next)
+	wg.Wait()
+	fmt.Printf("Image check for dataset %s: %d products, %d with a problem\n", datasetID, len(products), problems.Load())
+}
+
+// Report returns the checks recorded for a dataset, with the progress of its running check
+func (c *Checker) Report(ctx context.Context, datasetID uuid.UUID) (*models.ImageCheckReport, error) {
+	report, err := c.queries.GetImageCheckReport(ctx, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if p, ok := c.running[datasetID]; ok {
+		report.Running, report.Total, report.Checked = true, p.total, int(p.checked.Load())
+	}
+	c.mu.Unlock()
+	return report, nil
+}
+
+// answer is what a request for the image got
+type answer struct {
+	status      int
+	contentType string
+	finalURL    string
+	redirects   int
+	body        []byte // first bytes, GET only
+}
+
+// image reports whether the answer is an image: by its Content-Type, or by its first bytes
+// when the server doesn't say
+func (a *answer) image() bool {
+	if strings.HasPrefix(a.contentType, "image/") {
+		return true
+	}
+	generic := a.contentType == "" || a.contentType == "application/octet-stream" || a.contentType == "binary/octet-stream"
+	return generic && len(a.body) > 0 && strings.HasPrefix(http.DetectContentType(a.body), "image/")
+}
+
+func (a *answer) ok() bool {
+	return a.status >= 200 && a.status < 300
+}
+
+// check requests an image as Google's crawler does: HEAD, then a GET of its first bytes
+// when HEAD is refused or doesn't tell the type. A fetchable image is requested again
+// with a Referer from another site to find hotlink protection. Each request waits for
+// a tick of the rate limiter.
+func (c *Checker) check(ctx context.Context, tick <-chan time.Time, imageURL string) models.ImageCheck {
+	check := models.ImageCheck{URL: imageURL, CheckedAt: time.Now()}
+	if imageURL == "" {
+		check.Problem, check.Detail = models.ImageProblemMissing, "no image_link with an http(s) URL"
+		return check
+	}
+	u, err := url.Parse(imageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		check.Problem = models.ImageProblemInvalidURL
+		return check
+	}
+
+	a, err := c.fetch(ctx, tick, http.MethodHead, imageURL, googleUserAgent, "")
+	if err == nil && !(a.ok() && a.image()) {
+		a, err = c.fetch(ctx, tick, http.MethodGet, imageURL, googleUserAgent, "")
+	}
+	if err != nil {
+		check.Problem, check.Detail = models.ImageProblemUnreachable, err.Error()
+		return check
+	}
+	check.StatusCode, check.ContentType, check.FinalURL, check.Redirects = a.status, a.contentType, a.finalURL, a.redirects
+
+	switch {
+	case a.status == http.StatusNotFound || a.status == http.StatusGone:
+		check.Problem = models.ImageProblemNotFound
+	case a.status == http.StatusForbidden || a.status == http.StatusUnauthorized:
+		// CDNs that block crawlers still serve browsers: Google can't fetch it either way
+		check.Problem, check.Detail = models.ImageProblemHTTPError, fmt.Sprintf("HTTP %d", a.status)
+		if b, err := c.fetch(ctx, tick, http.MethodGet, imageURL, browserUserAgent, ""); err == nil && b.ok() && b.image() {
+			check.Problem, check.Detail = models.ImageProblemCrawlerBlocked, fmt.Sprintf("HTTP %d to %s, served to a browser", a.status, googleUserAgent)
+		}
+	case !a.ok():
+		check.Problem, check.Detail = models.ImageProblemHTTPError, fmt.Sprintf("HTTP %d", a.status)
+	case !a.image():
+		check.Problem, check.Detail = models.ImageProblemNotImage, "served as "+a.contentType
+	default:
+		b, err := c.fetch(ctx, tick, http.MethodGet, imageURL, browserUserAgent, foreignReferer)
+		if err == nil && !(b.ok() && b.image()) {
+			check.Problem = models.ImageProblemHotlink
+			check.Detail = fmt.Sprintf("HTTP %d when requested from another site", b.status)
+			if b.ok() {
+				check.Detail = fmt.Sprintf("served as %s when requested from another site", b.contentType)
+			}
+		}
+	}
+	return check
+}
+
+func (c *Checker) fetch(ctx context.Context, tick <-chan time.Time, method, imageURL, userAgent, referer string) (*answer, error) {
+	select {
+	case <-tick:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "image/*,*/*;q=0.8")
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", sniffBytes-1))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	a := &answer{
+		status:      resp.StatusCode,
+		contentType: strings.ToLower(strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])),
+		finalURL:    resp.Request.URL.String(),
+	}
+	for r := resp.Request; r.Response != nil; r = r.Response.Request {
+		a.redirects++
+	}
+	if a.redirects == 0 {
+		a.finalURL = ""
+	}
+	if method == http.MethodGet {
+		a.body, _ = io.ReadAll(io.LimitReader(resp.Body, sniffBytes))
+	}
+	return a, nil
+}
@@ -0,0 +1,162 @@
+package imageproxy
+
+import "sort"
+
+// Backgrounds of a product image
+const (
+	BackgroundWhite       = "white"
+	BackgroundTransparent = "transparent"
+	BackgroundColored     = "colored"   // uniform, another color than white
+	BackgroundLifestyle   = "lifestyle" // a scene: the product can't be told from it by color
+)
+
+const (
+	compositionSide  = 256 // longest side the pixels are sampled on
+	uniformBorder    = 90  // percent of the border matching its color for a uniform background
+	backgroundMatch  = 20  // channel difference of a pixel still counted as background
+	productContrast  = 32  // channel difference of a pixel counted as product
+	whiteLevel       = 235 // every channel at or above it, for a white background
+	maxWhiteChroma   = 15
+	productLineShare = 100 // a row or column is product when 1/this of its pixels are
+)
+
+// Composition is how the product sits in its image, measured on the pixels
+type Composition struct {
+	Background string
+	// Percent of the image border matching the background's color
+	Uniformity int
+	// Percent of the image's width or height (the larger) spanned by the product, on
+	// uniform backgrounds; 0 on lifestyle ones
+	ProductFill int
+}
+
+// AnalyzeComposition tells the background of an image and how much of it the product fills.
+// The background is read on the border of the image; on a uniform one, the product is what
+// differs from it. ok is false for formats the standard library can't decode (webp, avif)
+// and images over maxPixels.
+func AnalyzeComposition(data []byte) (c Composition, ok bool) {
+	src, _, err := decode(data)
+	if err != nil {
+		return c, false
+	}
+	b := src.Bounds()
+	if b.Dx() < 8 || b.Dy() < 8 {
+		return c, false
+	}
+
+	// Sample on a grid of at most compositionSide pixels per side
+	step := max(max(b.Dx(), b.Dy())/compositionSide, 1)
+	w, h := (b.Dx()+step-1)/step, (b.Dy()+step-1)/step
+	pixels := make([][4]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, a := src.At(b.Min.X+x*step, b.Min.Y+y*step).RGBA()
+			pixels[y*w+x] = [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8), uint8(a >> 8)}
+		}
+	}
+	margin := max(min(w, h)/50, 1)
+	var border [][4]uint8
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < margin || y < margin || x >= w-margin || y >= h-margin {
+				border = append(border, pixels[y*w+x])
+			}
+		}
+	}
+
+	var product func(p [4]uint8) bool
+	transparent := 0
+	for _, p := range border {
+		if p[3] < 32 {
+			transparent++
+		}
+	}
+	if transparent*100 >= uniformBorder*len(border) {
+		c.Background, c.Uniformity = BackgroundTransparent, transparent*100/len(border)
+		product = func(p [4]uint8) bool { return p[3] >= 128 }
+	} else {
+		ref := medianColor(border)
+		matching := 0
+		for _, p := range border {
+			if colorDistance(p, ref) <= backgroundMatch {
+				matching++
+			}
+		}
+		c.Uniformity = matching * 100 / len(border)
+		if c.Uniformity < uniformBorder {
+			c.Background = BackgroundLifestyle
+			return c, true
+		}
+		c.Background = BackgroundColored
+		lo, hi := min(ref[0], ref[1], ref[2]), max(ref[0], ref[1], ref[2])
+		if lo >= whiteLevel && hi-lo <= maxWhiteChroma {
+			c.Background = BackgroundWhite
+		}
+		product = func(p [4]uint8) bool { return p[3] >= 128 && colorDistance(p, ref) > productContrast }
+	}
+
+	// Bounding box of the rows and columns with enough product pixels, so JPEG noise and
+	// stray specks don't stretch it
+	rows, cols := make([]int, h), make([]int, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if product(pixels[y*w+x]) {
+				rows[y]++
+				cols[x]++
+			}
+		}
+	}
+	top, bottom := span(rows, max(w/productLineShare, 1))
+	left, right := span(cols, max(h/productLineShare, 1))
+	if bottom < top || right < left {
+		return c, true // nothing but background
+	}
+	c.ProductFill = max((right-left+1)*100/w, (bottom-top+1)*100/h)
+	return c, true
+}
+
+// span returns the first and last index whose count reaches least (last < first when none)
+func span(counts []int, least int) (first, last int) {
+	first, last = len(counts), -1
+	for i, n := range counts {
+		if n >= least {
+			first = i
+			break
+		}
+	}
+	for i := len(counts) - 1; i >= 0; i-- {
+		if counts[i] >= least {
+			last = i
+			break
+		}
+	}
+	return first, last
+}
+
+// medianColor is the per-channel median of pixels
+func medianColor(pixels [][4]uint8) [4]uint8 {
+	var m [4]uint8
+	channel := make([]int, len(pixels))
+	for c := 0; c < 3; c++ {
+		for i, p := range pixels {
+			channel[i] = int(p[c])
+		}
+		sort.Ints(channel)
+		m[c] = uint8(channel[len(channel)/2])
+	}
+	m[3] = 255
+	return m
+}
+
+// colorDistance is the largest channel difference between two colors
+func colorDistance(a, b [4]uint8) int {
+	d := 0
+	for c := 0; c < 3; c++ {
+		diff := int(a[c]) - int(b[c])
+		if diff < 0 {
+			diff = -diff
+		}
+		d = max(d, diff)
+	}
+	return d
+}
@@ -0,0 +1,36 @@
+package imageproxy
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestAnalyzeComposition(t *testing.T) {
+	// A dark square filling the middle half of a white image
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			c := color.RGBA{255, 255, 255, 255}
+			if x >= 50 && x < 150 && y >= 50 && y < 150 {
+				c = color.RGBA{20, 40, 60, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	c, ok := AnalyzeComposition(buf.Bytes())
+	if !ok || c.Background != BackgroundWhite || c.ProductFill < 45 || c.ProductFill > 55 {
+		t.Fatalf("got %+v, %v", c, ok)
+	}
+
+	if _, ok := AnalyzeComposition(bomb(t, 100000, 100000)); ok {
+		t.Fatal("an image over maxPixels was analyzed")
+	}
+}
@@ -0,0 +1,55 @@
+package imageproxy
+
+import "math/bits"
+
+// PerceptualHash returns the difference hash (dHash) of an image: its grayscale shrunk to
+// 9x8, one bit per pair of neighbouring pixels telling whether the left one is brighter.
+// Recompressed, resized or slightly retouched copies of an image get the same hash or one
+// a few bits away. ok is false for formats the standard library can't decode (webp, avif)
+// and images over maxPixels.
+func PerceptualHash(data []byte) (hash uint64, ok bool) {
+	src, _, err := decode(data)
+	if err != nil {
+		return 0, false
+	}
+	b := src.Bounds()
+	if b.Dx() < 9 || b.Dy() < 8 {
+		return 0, false
+	}
+
+	// Mean luminance of each of the 9x8 cells, sampled on a grid of at most 8x8 pixels per
+	// cell: the hash doesn't need every pixel of a large image
+	var gray [8][9]float64
+	for cy := 0; cy < 8; cy++ {
+		y0, y1 := b.Min.Y+cy*b.Dy()/8, b.Min.Y+(cy+1)*b.Dy()/8
+		for cx := 0; cx < 9; cx++ {
+			x0, x1 := b.Min.X+cx*b.Dx()/9, b.Min.X+(cx+1)*b.Dx()/9
+			stepY, stepX := max((y1-y0)/8, 1), max((x1-x0)/8, 1)
+			sum, n := 0.0, 0
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					r, g, bl, _ := src.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+					n++
+				}
+			}
+			gray[cy][cx] = sum / float64(n)
+		}
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, true
+}
+
+// HashDistance is the number of bits two perceptual hashes differ by: 0 for the same
+// picture, up to about 10 for near duplicates
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,39 @@
+package imageproxy
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// gradient is a w x h image getting brighter from left to right
+func gradient(t *testing.T, w, h int) []byte {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x * 255 / w)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPerceptualHash(t *testing.T) {
+	a, ok := PerceptualHash(gradient(t, 180, 160))
+	if !ok {
+		t.Fatal("no hash")
+	}
+	b, ok := PerceptualHash(gradient(t, 90, 80))
+	if !ok || HashDistance(a, b) > 10 {
+		t.Fatalf("resized copy at distance %d", HashDistance(a, b))
+	}
+
+	if _, ok := PerceptualHash(bomb(t, 100000, 100000)); ok {
+		t.Fatal("an image over maxPixels was hashed")
+	}
+}
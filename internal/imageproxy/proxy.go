@@ -0,0 +1,290 @@
+// Package imageproxy fetches product images from merchant CDNs, caches them on disk
+// and serves resized copies, so the review UI doesn't hotlink full-size images and
+// vision calls send the downloaded (and downscaled) copies instead of the merchant URLs.
+package imageproxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/retry"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrInvalidURL = errors.New("image URL must be an absolute http(s) URL")
+	ErrTooLarge   = errors.New("image exceeds the maximum size")
+	ErrNotImage   = errors.New("URL did not return an image")
+	errBlocked    = errors.New("image host resolves to a private address")
+)
+
+// Image is a fetched (and possibly resized) image
+type Image struct {
+	Data        []byte `json:"-"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"` // 0 when the format can't be decoded (e.g. avif)
+	Height      int    `json:"height"`
+	Resized     bool   `json:"resized"`
+}
+
+// Proxy fetches and caches images
+type Proxy struct {
+	config *config.Config
+	client *http.Client
+	dir    string
+	group  singleflight.Group
+}
+
+// New creates a proxy caching under STORAGE_PATH/image-cache
+func New(cfg *config.Config) *Proxy {
+	return &Proxy{
+		config: cfg,
+		client: &http.Client{
+			Timeout: 20 * time.Second,
+			Transport: &retry.Transport{
+				Base:   SafeTransport(cfg.ImageProxy.AllowPrivate),
+				Policy: retry.PolicyFromConfig(cfg),
+			},
+		},
+		dir: cacheDir(cfg),
+	}
+}
+
+func cacheDir(cfg *config.Config) string {
+	return filepath.Join(cfg.Storage.Path, "image-cache")
+}
+
+// Get returns the image at rawURL fitted within width x height (0 = unbounded, both 0 = original).
+// Images are never upscaled.
+func (p *Proxy) Get(ctx context.Context, rawURL string, width, height int) (*Image, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	rawURL = u.String()
+	width, height = p.clamp(width), p.clamp(height)
+
+	key := cacheKey(rawURL, width, height)
+	if img, ok := p.load(key); ok {
+		return img, nil
+	}
+
+	v, err, _ := p.group.Do(key, func() (any, error) {
+		original, err := p.original(ctx, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		if width == 0 && height == 0 {
+			return original, nil
+		}
+		img := resize(original, width, height)
+		p.store(key, img)
+		return img, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Image), nil
+}
+
+// modelFormats are the image types vision models accept inline
+var modelFormats = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true, "image/webp": true}
+
+// ForModel returns what to send a vision model for rawURL: the image downloaded through the
+// cache and fitted within maxSide (0 = original size) as a data URL, so the provider never
+// fetches from merchant CDNs that block it and re-analyses don't download again. rawURL itself
+// is returned when the image can't be downloaded or is in a format models don't read inline.
+// original and sent are nil when the image wasn't downloaded.
+func (p *Proxy) ForModel(ctx context.Context, rawURL string, maxSide int) (send string, original, sent *Image) {
+	original, err := p.Get(ctx, rawURL, 0, 0)
+	if err != nil {
+		// Let the provider try, it may still reach the image
+		fmt.Printf("Image proxy: %s not downloaded, sending its URL: %v\n", rawURL, err)
+		return rawURL, nil, nil
+	}
+	sent = original
+	if maxSide > 0 {
+		if resized, err := p.Get(ctx, rawURL, maxSide, maxSide); err == nil && resized.Resized {
+			sent = resized
+		}
+	}
+	if !modelFormats[sent.ContentType] {
+		return rawURL, original, sent
+	}
+	return "data:" + sent.ContentType + ";base64," + base64.StdEncoding.EncodeToString(sent.Data), original, sent
+}
+
+func (p *Proxy) clamp(d int) int {
+	if d < 0 {
+		return 0
+	}
+	if max := p.config.ImageProxy.MaxDimension; max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// original returns the full-size image, from cache or the merchant CDN
+func (p *Proxy) original(ctx context.Context, rawURL string) (*Image, error) {
+	key := cacheKey(rawURL, 0, 0)
+	if img, ok := p.load(key); ok {
+		return img, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", "FeedEnrich-ImageProxy/1.0")
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch image: status %d", resp.StatusCode)
+	}
+
+	limit := p.config.ImageProxy.MaxBytes
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		// Sniffing doesn't know every format (webp, avif): trust an image/* header then
+		contentType = strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+		if !strings.HasPrefix(contentType, "image/") {
+			return nil, ErrNotImage
+		}
+	}
+
+	img := &Image{Data: data, ContentType: contentType}
+	img.Width, img.Height = dimensions(data)
+	p.store(key, img)
+	return img, nil
+}
+
+// ===== DISK CACHE =====
+
+func cacheKey(rawURL string, width, height int) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return fmt.Sprintf("%s_%dx%d", hex.EncodeToString(sum[:16]), width, height)
+}
+
+func (p *Proxy) paths(key string) (data, meta string) {
+	// Two-level fan-out keeps directories small
+	base := filepath.Join(p.dir, key[:2], key)
+	return base, base + ".json"
+}
+
+func (p *Proxy) load(key string) (*Image, bool) {
+	dataPath, metaPath := p.paths(key)
+	info, err := os.Stat(metaPath)
+	if err != nil || time.Since(info.ModTime()) > p.config.ImageProxy.CacheTTL {
+		return nil, false
+	}
+	metaJSON, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, false
+	}
+	var img Image
+	if err := json.Unmarshal(metaJSON, &img); err != nil {
+		return nil, false
+	}
+	if img.Data, err = os.ReadFile(dataPath); err != nil {
+		return nil, false
+	}
+	return &img, true
+}
+
+// store writes an entry; failures only cost a refetch so they are logged, not returned
+func (p *Proxy) store(key string, img *Image) {
+	dataPath, metaPath := p.paths(key)
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
+		fmt.Printf("Image cache: %v\n", err)
+		return
+	}
+	metaJSON, _ := json.Marshal(img)
+	// Data first: an entry is only visible once its metadata exists
+	if err := os.WriteFile(dataPath, img.Data, 0644); err != nil {
+		fmt.Printf("Image cache: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(metaPath, metaJSON, 0644); err != nil {
+		fmt.Printf("Image cache: %v\n", err)
+	}
+}
+
+// PurgeExpired deletes cache entries older than IMAGE_PROXY_CACHE_TTL and returns how many
+func PurgeExpired(cfg *config.Config) (int, error) {
+	dir := cacheDir(cfg)
+	purged := 0
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || time.Since(info.ModTime()) <= cfg.ImageProxy.CacheTTL {
+			return nil
+		}
+		os.Remove(path)
+		os.Remove(strings.TrimSuffix(path, ".json"))
+		purged++
+		return nil
+	})
+	return purged, err
+}
+
+// ===== SSRF PROTECTION =====
+
+// SafeTransport refuses to connect to loopback, private and link-local addresses,
+// so image URLs from uploaded feeds can't be used to reach internal services
+func SafeTransport(allowPrivate bool) http.RoundTripper {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+				return errBlocked
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,161 @@
+package imageproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder for image.Decode
+	"image/jpeg"
+	"image/png"
+)
+
+// maxPixels bounds the images decoded (about 200 MB of RGBA): a small file can declare a
+// huge canvas, whose pixels are allocated before any of them is read
+const maxPixels = 50_000_000
+
+var errTooManyPixels = errors.New("image has too many pixels to decode")
+
+// decode decodes an image after checking its declared size against maxPixels
+func decode(data []byte) (image.Image, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, "", errTooManyPixels
+	}
+	return image.Decode(bytes.NewReader(data))
+}
+
+// dimensions returns the size of an image without decoding its pixels (0, 0 if unknown)
+func dimensions(data []byte) (int, int) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return webpDimensions(data)
+	}
+	return cfg.Width, cfg.Height
+}
+
+// webpDimensions reads the size in a WebP header: the standard library has no decoder
+func webpDimensions(data []byte) (int, int) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0
+	}
+	b := data[20:]
+	switch string(data[12:16]) {
+	case "VP8 ": // lossy: frame tag, start code, 14-bit sizes
+		if b[3] != 0x9d || b[4] != 0x01 || b[5] != 0x2a {
+			return 0, 0
+		}
+		return int(binary.LittleEndian.Uint16(b[6:8]) & 0x3fff), int(binary.LittleEndian.Uint16(b[8:10]) & 0x3fff)
+	case "VP8L": // lossless: signature, then 14-bit sizes minus one
+		if b[0] != 0x2f {
+			return 0, 0
+		}
+		bits := binary.LittleEndian.Uint32(b[1:5])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1
+	case "VP8X": // extended: 24-bit canvas sizes minus one
+		return int(uint32(b[4])|uint32(b[5])<<8|uint32(b[6])<<16) + 1, int(uint32(b[7])|uint32(b[8])<<8|uint32(b[9])<<16) + 1
+	}
+	return 0, 0
+}
+
+// resize fits an image within width x height keeping its aspect ratio. Formats the
+// standard library can't decode, images over maxPixels and images already small enough
+// are returned as is.
+func resize(original *Image, width, height int) *Image {
+	src, format, err := decode(original.Data)
+	if err != nil {
+		return original
+	}
+	b := src.Bounds()
+	w, h := fit(b.Dx(), b.Dy(), width, height)
+	if w >= b.Dx() && h >= b.Dy() {
+		return original
+	}
+
+	dst := boxDownscale(src, w, h)
+
+	var buf bytes.Buffer
+	out := &Image{Width: w, Height: h, Resized: true}
+	if format == "png" || format == "gif" {
+		// Keep transparency
+		if err := png.Encode(&buf, dst); err != nil {
+			return original
+		}
+		out.ContentType = "image/png"
+	} else {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 82}); err != nil {
+			return original
+		}
+		out.ContentType = "image/jpeg"
+	}
+	out.Data = buf.Bytes()
+	return out
+}
+
+// fit scales srcW x srcH to fit within maxW x maxH (0 = unbounded)
+func fit(srcW, srcH, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 && srcW > maxW {
+		scale = float64(maxW) / float64(srcW)
+	}
+	if maxH > 0 && float64(srcH)*scale > float64(maxH) {
+		scale = float64(maxH) / float64(srcH)
+	}
+	w, h := int(float64(srcW)*scale+0.5), int(float64(srcH)*scale+0.5)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// boxDownscale averages the source pixels covered by each destination pixel,
+// which avoids the aliasing of nearest-neighbour sampling on large reductions
+func boxDownscale(src image.Image, w, h int) *image.NRGBA {
+	b := src.Bounds()
+	in := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(in, in.Bounds(), src, b.Min, draw.Src)
+
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	sw, sh := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := in.Pix[sy*in.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					px := row[sx*4 : sx*4+4]
+					// Weight colour by alpha so transparent pixels don't darken edges
+					pa := uint64(px[3])
+					r += uint64(px[0]) * pa
+					g += uint64(px[1]) * pa
+					bl += uint64(px[2]) * pa
+					a += pa
+					n++
+				}
+			}
+			o := out.Pix[y*out.Stride+x*4:]
+			if a > 0 {
+				o[0] = uint8(r / a)
+				o[1] = uint8(g / a)
+				o[2] = uint8(bl / a)
+			}
+			o[3] = uint8(a / n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package imageproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// pngOf encodes a w x h image
+func pngOf(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// bomb is a PNG of a few bytes declaring a width x height canvas
+func bomb(t *testing.T, width, height uint32) []byte {
+	data := pngOf(t, 1, 1)
+	// IHDR: length (8), type (12), width (16), height (20), ..., CRC of type and data (29)
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestDecodeRejectsTooManyPixels(t *testing.T) {
+	if _, _, err := decode(bomb(t, 100000, 100000)); !errors.Is(err, errTooManyPixels) {
+		t.Fatalf("err = %v, want errTooManyPixels", err)
+	}
+	if _, _, err := decode(pngOf(t, 64, 64)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	original := &Image{Data: pngOf(t, 400, 200), ContentType: "image/png"}
+	resized := resize(original, 100, 100)
+	if !resized.Resized || resized.Width != 100 || resized.Height != 50 {
+		t.Fatalf("got %dx%d, resized %v", resized.Width, resized.Height, resized.Resized)
+	}
+
+	huge := &Image{Data: bomb(t, 100000, 100000), ContentType: "image/png"}
+	if got := resize(huge, 100, 100); got != huge {
+		t.Fatal("an image over maxPixels was decoded")
+	}
+}
@@ -0,0 +1,317 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// BatchConfig marks an enrich_all job run through the provider's batch API (OpenAI: half
+// price, results within 24 hours). The optimization call of every product is submitted at
+// once; proposals are created when the batch completes, see PollBatches.
+type BatchConfig struct {
+	ID          string     `json:"id,omitempty"` // provider batch, empty until submitted
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+}
+
+// SupportsBatch reports whether the configured provider has a batch API
+func (r *Runner) SupportsBatch() bool {
+	return r.batcher != nil
+}
+
+// StartBatch prepares the products of a batch job in the background and submits them.
+// Image analysis and web search run during preparation, on the job workers' concurrency.
+func (r *Runner) StartBatch(job models.JobWithDetails, products []models.Product) {
+	go r.submitBatch(job, products)
+}
+
+func (r *Runner) submitBatch(job models.JobWithDetails, products []models.Product) {
+	bg := context.Background()
+	var cfg AuditConfig
+	json.Unmarshal(job.Config, &cfg)
+
+	ctx := bg
+	if cfg.Thresholds != nil {
+		ctx = agent.WithThresholds(bg, *cfg.Thresholds)
+	}
+	r.setStatus(job.ID, "running", nil)
+	ctx, err := r.agent.WithBudget(ctx, job.DatasetID)
+	if err != nil {
+		r.pauseBatchForBudget(job.ID, err)
+		return
+	}
+	r.logJob(job.ID, 0, "info", fmt.Sprintf("Preparing batch enrichment of %d products", len(products)))
+
+	items := make([]llm.BatchItem, len(products))
+	stopped := false
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < r.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				prepCtx, cancel := context.WithTimeout(ctx, r.config.Agent.Timeout)
+				items[i] = llm.BatchItem{ID: products[i].ID.String(), Request: r.agent.BatchRequest(prepCtx, &products[i])}
+				cancel()
+			}
+		}()
+	}
+	for i := range products {
+		// Paused or cancelled while preparing: nothing was submitted yet
+		if status, err := r.queries.GetJobStatus(bg, job.ID); err == nil && status != "running" {
+			stopped = true
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if stopped {
+		r.logJob(job.ID, 0, "warning", "Batch preparation stopped before submission")
+		return
+	}
+
+	// Preparation spends too (image analysis): check again before committing to the batch
+	if err := r.agent.CheckBudget(ctx); err != nil {
+		r.pauseBatchForBudget(job.ID, err)
+		return
+	}
+	id, err := r.batcher.Submit(ctx, items)
+	if err != nil {
+		errMsg := fmt.Sprintf("Batch submission failed: %v", err)
+		r.logJob(job.ID, 0, "error", errMsg)
+		r.setStatus(job.ID, "failed", &errMsg)
+		return
+	}
+	now := time.Now()
+	cfg.Batch = &BatchConfig{ID: id, SubmittedAt: &now}
+	raw, _ := json.Marshal(cfg)
+	if err := r.queries.UpdateJobConfig(bg, job.ID, raw); err != nil {
+		fmt.Printf("Failed to record batch %s of job %s: %v\n", id, job.ID, err)
+	}
+	r.logJob(job.ID, 0, "info", fmt.Sprintf("Submitted batch %s: %d products, results within 24h", id, len(items)))
+	fmt.Printf("Batch %s submitted for job %s: %d products\n", id, job.ID, len(items))
+}
+
+func (r *Runner) logJob(jobID uuid.UUID, processed int, level, message string) {
+	r.updateProgress(jobID, processed, 0, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     level,
+		Message:   message,
+	})
+}
+
+// resumeBatch resumes a paused batch job: polling again once submitted, preparing it
+// again from scratch when it was paused before submission
+func (r *Runner) resumeBatch(ctx context.Context, job *models.JobWithDetails, cfg AuditConfig) error {
+	if cfg.Batch.ID != "" {
+		ok, err := r.queries.TransitionJobStatus(ctx, job.ID, []string{"paused"}, "running")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTransition
+		}
+		return nil
+	}
+
+	products, err := r.queries.ListProductsInScope(ctx, job.DatasetID, cfg.SegmentID, cfg.Tags)
+	if err != nil {
+		return err
+	}
+	ok, err := r.queries.TransitionJobStatus(ctx, job.ID, []string{"paused"}, "pending")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTransition
+	}
+	r.StartBatch(*job, products)
+	return nil
+}
+
+// cancelBatch stops the provider batch of a cancelled job, if it was submitted
+func (r *Runner) cancelBatch(ctx context.Context, jobID uuid.UUID) {
+	if r.batcher == nil {
+		return
+	}
+	job, err := r.queries.GetJob(ctx, jobID)
+	if err != nil {
+		return
+	}
+	var cfg AuditConfig
+	if json.Unmarshal(job.Config, &cfg) != nil || cfg.Batch == nil || cfg.Batch.ID == "" {
+		return
+	}
+	if keyed, err := r.agent.WithProviderKeys(ctx, job.DatasetID); err == nil {
+		ctx = keyed
+	}
+	if err := r.batcher.Cancel(ctx, cfg.Batch.ID); err != nil {
+		fmt.Printf("Failed to cancel batch %s of job %s: %v\n", cfg.Batch.ID, jobID, err)
+	}
+}
+
+// PollBatches checks submitted batch jobs every JOB_BATCH_POLL_INTERVAL and ingests the
+// results of finished ones, until ctx is cancelled. Batches survive restarts: their
+// IDs are stored with the job.
+func (r *Runner) PollBatches(ctx context.Context) {
+	if r.batcher == nil {
+		return
+	}
+	ticker := time.NewTicker(r.config.Jobs.BatchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.pollBatches(ctx); err != nil {
+			fmt.Printf("Batch polling failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) pollBatches(ctx context.Context) error {
+	ids, err := r.queries.ListBatchJobIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		job, err := r.queries.GetJob(ctx, id)
+		if err != nil {
+			continue
+		}
+		var cfg AuditConfig
+		if json.Unmarshal(job.Config, &cfg) != nil || cfg.Batch == nil {
+			continue
+		}
+		// The batch was submitted with the organization's key: only it can read the batch
+		jobCtx, err := r.agent.WithProviderKeys(ctx, job.DatasetID)
+		if err != nil {
+			fmt.Printf("Batch %s of job %s: %v\n", cfg.Batch.ID, job.ID, err)
+			continue
+		}
+		status, err := r.batcher.Status(jobCtx, cfg.Batch.ID)
+		if err != nil {
+			fmt.Printf("Batch %s of job %s: %v\n", cfg.Batch.ID, job.ID, err)
+			continue
+		}
+		if !status.Done() {
+			continue
+		}
+		if err := r.ingestBatch(jobCtx, job, cfg, status); err != nil {
+			fmt.Printf("Failed to ingest batch %s of job %s: %v\n", cfg.Batch.ID, job.ID, err)
+		}
+	}
+	return nil
+}
+
+// ingestBatch turns the results of a finished batch into proposals, then completes the
+// job like a synchronous run: products without a usable result are dead-lettered
+func (r *Runner) ingestBatch(ctx context.Context, job *models.JobWithDetails, cfg AuditConfig, status *llm.BatchStatus) error {
+	if status.State == "failed" {
+		// Rejected as a whole (invalid input file): no request ran
+		errMsg := fmt.Sprintf("Batch %s failed: %s", status.ID, status.Error)
+		r.logJob(job.ID, 0, "error", errMsg)
+		return r.queries.UpdateJobStatus(ctx, job.ID, "failed", &errMsg)
+	}
+	results, err := r.batcher.Results(ctx, status)
+	if err != nil {
+		return err // retried on the next poll
+	}
+	byID := make(map[uuid.UUID]llm.BatchResult, len(results))
+	ids := make([]uuid.UUID, 0, len(results))
+	for _, res := range results {
+		if id, err := uuid.Parse(res.ID); err == nil {
+			byID[id] = res
+			ids = append(ids, id)
+		}
+	}
+	products, err := r.queries.ListProductsByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	// A stable order, so an ingestion cut short by a restart resumes after its checkpoint
+	slices.SortFunc(products, func(a, b models.Product) int { return strings.Compare(a.ID.String(), b.ID.String()) })
+	start := 0
+	if job.CheckpointProductID != nil {
+		for i := range products {
+			if products[i].ID == *job.CheckpointProductID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	aj := &activeJob{
+		job:       *job,
+		group:     cfg.Group,
+		products:  products,
+		start:     start,
+		processed: job.ProcessedItems,
+		proposals: job.ProposalsGenerated,
+		usage:     &agent.UsageMeter{},
+		ctx:       runCtx,
+		cancel:    cancel,
+	}
+	if cfg.Thresholds != nil {
+		aj.thresholds = *cfg.Thresholds
+	}
+	if status.State != "completed" && start == 0 {
+		message := fmt.Sprintf("Batch %s ended %s: %d/%d requests completed", status.ID, status.State, status.Completed, status.Total)
+		if status.Error != "" {
+			message += ": " + status.Error
+		}
+		r.logJob(job.ID, aj.processed, "warning", message)
+	}
+
+	runCtx = agent.WithThresholds(agent.WithUsageMeter(runCtx, aj.usage), aj.thresholds)
+	for i := start; i < len(products); i++ {
+		product := &products[i]
+		res := byID[product.ID]
+		aj.processed++
+
+		var session *agent.Session
+		err := fmt.Errorf("batch request failed: %s", res.Error)
+		if res.Response != nil {
+			session, err = r.agent.BatchSession(runCtx, product, res.Response)
+		}
+		if err != nil {
+			aj.errors++
+			r.deadLetter(aj, product, 1, err)
+			r.queries.UpdateJobCheckpoint(ctx, job.ID, product.ID, aj.processed, aj.proposals)
+			continue
+		}
+
+		variants := r.VariantProposals(ctx, product, session.Proposals)
+		aj.proposals += len(session.Proposals) + len(variants)
+		if err := r.queries.CreateRunRecords(ctx, db.RunRecords{
+			Proposals:    append(session.Proposals, variants...),
+			HumanReviews: session.HumanReviews,
+		}); err != nil {
+			fmt.Printf("Failed to save proposals and human reviews for %s: %v\n", product.ID, err)
+		}
+		r.queries.ResolveJobFailures(ctx, product.ID)
+		if err := r.queries.RecordEnrichmentRun(ctx, product.ID, string(aj.group), len(session.Proposals), ComplianceScore(product)); err != nil {
+			fmt.Printf("Failed to record enrichment run for %s: %v\n", product.ID, err)
+		}
+		r.queries.UpdateJobCheckpoint(ctx, job.ID, product.ID, aj.processed, aj.proposals)
+	}
+
+	r.complete(aj)
+	return nil
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// pauseForBudget pauses a job whose dataset or deployment budget is spent, after the
+// product in flight, which is not counted. Resuming it once the budget is raised or the
+// period is over runs that product again.
+func (r *Runner) pauseForBudget(aj *activeJob, err error) {
+	bg := context.Background()
+	r.mu.Lock()
+	aj.stop = "paused"
+	r.mu.Unlock()
+	if ok, terr := r.queries.TransitionJobStatus(bg, aj.job.ID, []string{"pending", "running"}, "paused"); terr != nil || !ok {
+		return
+	}
+	r.updateProgress(aj.job.ID, aj.processed, aj.proposals, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "error",
+		Message:   fmt.Sprintf("Paused: %v. Raise the budget or wait for the next period, then resume", err),
+	})
+	fmt.Printf("Budget: paused job %s at %d/%d products: %v\n", aj.job.ID, aj.processed, len(aj.products), err)
+}
+
+// pauseBatchForBudget pauses a batch job before submission; resuming prepares it again
+func (r *Runner) pauseBatchForBudget(jobID uuid.UUID, err error) {
+	if ok, terr := r.queries.TransitionJobStatus(context.Background(), jobID, []string{"pending", "running"}, "paused"); terr != nil || !ok {
+		return
+	}
+	r.logJob(jobID, 0, "error", fmt.Sprintf("Paused before submission: %v. Raise the budget or wait for the next period, then resume", err))
+	fmt.Printf("Budget: paused batch job %s: %v\n", jobID, err)
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"math"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// ProposalStatusSimulated marks proposals of a dry run: stored for inspection, never reviewable
+const ProposalStatusSimulated = "simulated"
+
+// DryRunConfig is set on jobs that run the pipeline for estimation only
+type DryRunConfig struct {
+	ScopeProducts int `json:"scope_products"`        // products the real run would cover
+	SampleSize    int `json:"sample_size,omitempty"` // products actually run (0 = all)
+
+	byField map[string]int
+	byRisk  map[string]int
+}
+
+func (d *DryRunConfig) count(p models.Proposal) {
+	if d.byField == nil {
+		d.byField = map[string]int{}
+		d.byRisk = map[string]int{}
+	}
+	d.byField[p.Field]++
+	d.byRisk[p.RiskLevel]++
+}
+
+// SampleProducts picks the dry run sample: evenly spaced products so the sample
+// is not biased toward the oldest products of the scope
+func SampleProducts(products []models.Product, size int) []models.Product {
+	if size <= 0 || size >= len(products) {
+		return products
+	}
+	step := float64(len(products)) / float64(size)
+	sample := make([]models.Product, 0, size)
+	for i := 0; i < size; i++ {
+		sample = append(sample, products[int(float64(i)*step)])
+	}
+	return sample
+}
+
+// report summarizes a job run; dry runs also get counts and a projection to the full scope,
+// image audits their scorecard
+func (r *Runner) report(aj *activeJob) *models.JobReport {
+	processed := aj.processed - aj.job.ProcessedItems
+	report := &models.JobReport{
+		Products:  processed,
+		Proposals: aj.proposals - aj.job.ProposalsGenerated,
+		Errors:    aj.errors,
+		Usage:     aj.usage.Usage(),
+		DryRun:    aj.dryRun != nil,
+	}
+	if aj.imageAudit != nil {
+		report.Images = aj.imageAudit.scorecard()
+	}
+	if aj.dryRun == nil {
+		return report
+	}
+
+	report.ProposalsByField = aj.dryRun.byField
+	report.ProposalsByRisk = aj.dryRun.byRisk
+	if processed > 0 {
+		scale := float64(aj.dryRun.ScopeProducts) / float64(processed)
+		report.Projection = &models.JobProjection{
+			Products:  aj.dryRun.ScopeProducts,
+			Proposals: int(math.Round(float64(report.Proposals) * scale)),
+			Tokens:    int(math.Round(float64(report.Usage.PromptTokens+report.Usage.CompletionTokens) * scale)),
+			CostUSD:   report.Usage.CostUSD * scale,
+		}
+	}
+	return report
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// assumedProductTime is the per-product duration of estimates made before any job ran
+const assumedProductTime = 10 * time.Second
+
+// Estimate projects the cost of running groups over products from a simulated run of a
+// sample: the agent builds every call as usual but none is sent (see llm.WithSimulation),
+// so nothing is spent or stored. Web search is skipped and answer lengths are typical
+// ones; a dry run (POST /datasets/:id/enrich with dry_run) measures the real cost.
+func (r *Runner) Estimate(ctx context.Context, products []models.Product, sampleSize int, groups []agent.OptimizationGroup, thresholds models.Thresholds) *models.CostEstimate {
+	sample := SampleProducts(products, sampleSize)
+	estimate := &models.CostEstimate{ScopeProducts: len(products), SampleSize: len(sample)}
+
+	for _, group := range groups {
+		usage := &agent.UsageMeter{}
+		runCtx := llm.WithSimulation(agent.WithThresholds(agent.WithUsageMeter(ctx, usage), thresholds))
+		var failed atomic.Int32
+		var wg sync.WaitGroup
+		next := make(chan int)
+		for w := 0; w < r.workers(); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range next {
+					if _, err := r.agent.RunWithGroup(runCtx, &sample[i], "Estimate: "+string(group), group); err != nil {
+						failed.Add(1)
+					}
+				}
+			}()
+		}
+		for i := range sample {
+			next <- i
+		}
+		close(next)
+		wg.Wait()
+
+		g := models.GroupEstimate{Group: string(group), Errors: int(failed.Load())}
+		if len(sample) > 0 {
+			u := usage.Usage()
+			scale := float64(len(products)) / float64(len(sample))
+			g.Calls = int(math.Round(float64(u.Calls) * scale))
+			g.PromptTokens = int(math.Round(float64(u.PromptTokens) * scale))
+			g.CompletionTokens = int(math.Round(float64(u.CompletionTokens) * scale))
+			g.CostUSD = u.CostUSD * scale
+			g.CostPerProductUSD = u.CostUSD / float64(len(sample))
+		}
+		estimate.Groups = append(estimate.Groups, g)
+		estimate.Calls += g.Calls
+		estimate.Tokens += g.PromptTokens + g.CompletionTokens
+		estimate.CostUSD += g.CostUSD
+	}
+
+	r.mu.Lock()
+	perProduct := r.queue.avgItem
+	r.mu.Unlock()
+	estimate.DurationBasis = "observed"
+	if perProduct == 0 {
+		perProduct, estimate.DurationBasis = assumedProductTime, "assumed"
+	}
+	runs := float64(len(products) * len(groups))
+	estimate.DurationSeconds = runs * perProduct.Seconds() / float64(r.workers())
+	return estimate
+}
@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrNothingToRequeue is returned when none of the requested products is dead-lettered
+var ErrNothingToRequeue = errors.New("no dead-lettered products to requeue")
+
+// run executes the agent on a product, retrying failed attempts up to JOB_MAX_ATTEMPTS.
+// Each attempt gets the full agent timeout. It returns the number of attempts made.
+func (r *Runner) run(aj *activeJob, product *models.Product) (*agent.Session, int, error) {
+	maxAttempts := r.config.Jobs.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		runCtx := agent.WithThresholds(agent.WithUsageMeter(aj.ctx, aj.usage), aj.thresholds)
+		ctx, cancel := context.WithTimeout(runCtx, r.config.Agent.Timeout)
+		var session *agent.Session
+		session, err = r.agent.RunEngine(ctx, product, "Audit: "+aj.label(), aj.groups, aj.engine)
+		cancel()
+		if err == nil {
+			return session, attempt, nil
+		}
+		// The call log of a failed attempt is what explains it
+		if session != nil {
+			if err := r.queries.CreateLLMCalls(context.Background(), session.LLMCalls); err != nil {
+				fmt.Printf("Failed to save LLM call log for %s: %v\n", product.ID, err)
+			}
+		}
+		// Retrying cannot help once the budget is spent, after a cancel, nor on errors
+		// the next attempt would get again
+		kind, transient := classifyError(err)
+		if aj.ctx.Err() != nil || errors.Is(err, agent.ErrBudgetExhausted) || !transient {
+			return nil, attempt, err
+		}
+		if attempt < maxAttempts {
+			fmt.Printf("Audit attempt %d/%d failed for product %s (%s): %v\n", attempt, maxAttempts, product.ID, kind, err)
+		}
+	}
+	return nil, maxAttempts, err
+}
+
+// deadLetter records a product that failed every attempt so it can be inspected and requeued
+func (r *Runner) deadLetter(aj *activeJob, product *models.Product, attempts int, err error) {
+	failure := models.JobFailure{
+		ID:        uuid.New(),
+		JobID:     aj.job.ID,
+		ProductID: product.ID,
+		Attempts:  attempts,
+		Error:     err.Error(),
+		ErrorKind: errorKind(err),
+	}
+	if err := r.queries.RecordJobFailure(context.Background(), failure); err != nil {
+		fmt.Printf("Failed to dead-letter product %s: %v\n", product.ID, err)
+	}
+}
+
+// permanentErrors fail every attempt the same way: the product isn't retried
+var permanentErrors = []error{
+	llm.ErrVisionUnsupported,
+	agent.ErrUnknownEngine,
+	agent.ErrEngineGroup,
+	agent.ErrInvalidThresholds,
+}
+
+// errorKind classifies an agent error for triage
+func errorKind(err error) string {
+	kind, _ := classifyError(err)
+	return kind
+}
+
+// classifyError returns the kind of an agent error (timeout, parse, api, error) and whether
+// another attempt may succeed. Provider answers are transient on 408, 429 and 5xx only (the
+// retry transport has already retried them), the model's output may parse next time.
+func classifyError(err error) (kind string, transient bool) {
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout", true
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, llm.ErrEmptyResponse):
+		return "parse", true
+	}
+	if status := llm.StatusCode(err); status != 0 {
+		return "api", status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+	}
+	for _, permanent := range permanentErrors {
+		if errors.Is(err, permanent) {
+			return "error", false
+		}
+	}
+	return "error", true
+}
+
+// Requeue starts a new job over dead-lettered products of a job (all of them when productIDs
+// is empty), with the same group as the original job
+func (r *Runner) Requeue(ctx context.Context, jobID uuid.UUID, productIDs []uuid.UUID) (*models.JobWithDetails, error) {
+	original, err := r.queries.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	var cfg AuditConfig
+	if err := json.Unmarshal(original.Config, &cfg); err != nil || cfg.Group == "" {
+		return nil, fmt.Errorf("job %s has no audit config", jobID)
+	}
+
+	failures, err := r.queries.ListJobFailures(ctx, jobID, "dead_letter")
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[uuid.UUID]bool{}
+	for _, id := range productIDs {
+		wanted[id] = true
+	}
+	var ids []uuid.UUID
+	for _, f := range failures {
+		if len(wanted) == 0 || wanted[f.ProductID] {
+			ids = append(ids, f.ProductID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, ErrNothingToRequeue
+	}
+
+	products, err := r.queries.ListProductsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.JobWithDetails{
+		Job: models.Job{
+			ID:        uuid.New(),
+			DatasetID: original.DatasetID,
+			Type:      "Requeue: " + groupName(cfg.Group),
+			Status:    "pending",
+			CreatedAt: time.Now(),
+		},
+		Module:     string(cfg.Group),
+		Priority:   original.Priority,
+		TotalItems: len(products),
+		Logs:       []models.JobLog{},
+	}
+	job.Config, _ = json.Marshal(AuditConfig{Group: cfg.Group, ProductIDs: ids, Thresholds: cfg.Thresholds})
+	if err := r.queries.CreateJobWithDetails(ctx, job); err != nil {
+		return nil, err
+	}
+	if err := r.queries.MarkJobFailuresRequeued(ctx, jobID, ids, job.ID); err != nil {
+		return nil, err
+	}
+
+	r.StartAudit(job, products)
+	return &job, nil
+}
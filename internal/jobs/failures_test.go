@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestClassifyError(t *testing.T) {
+	var syntaxErr *json.SyntaxError
+	parseErr := json.Unmarshal([]byte("{"), &struct{}{})
+	if !errors.As(parseErr, &syntaxErr) {
+		t.Fatalf("unexpected parse error %T", parseErr)
+	}
+
+	tests := []struct {
+		name      string
+		err       error
+		kind      string
+		transient bool
+	}{
+		{"deadline", fmt.Errorf("optimize: %w", context.DeadlineExceeded), "timeout", true},
+		{"model output", fmt.Errorf("llm: decode structured output: %w", parseErr), "parse", true},
+		{"empty response", llm.ErrEmptyResponse, "parse", true},
+		{"rate limited", &llm.APIError{StatusCode: 429}, "api", true},
+		{"provider down", &openai.APIError{HTTPStatusCode: 503}, "api", true},
+		{"bad request", &llm.APIError{StatusCode: 400}, "api", false},
+		{"bad key", fmt.Errorf("run: %w", &openai.RequestError{HTTPStatusCode: 401}), "api", false},
+		{"no vision", fmt.Errorf("analyze image: %w", llm.ErrVisionUnsupported), "error", false},
+		{"unknown engine", agent.ErrUnknownEngine, "error", false},
+		// Mentioning a timeout doesn't make an error one
+		{"message only", errors.New("timeout field missing"), "error", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, transient := classifyError(tt.err)
+			if kind != tt.kind || transient != tt.transient {
+				t.Fatalf("got %s, %v, want %s, %v", kind, transient, tt.kind, tt.transient)
+			}
+		})
+	}
+}
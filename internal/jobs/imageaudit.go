@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// ImageAuditConfig is set on image audit jobs: the main image of each product is measured
+// and analyzed without the optimization call, and the report is a scorecard of the images
+type ImageAuditConfig struct{}
+
+// resolutionRanges bucket the shortest side of the images at the GMC thresholds: minimum,
+// apparel minimum and recommended size
+var resolutionRanges = []struct {
+	label string
+	below int
+}{
+	{"<100", 100}, {"100-249", 250}, {"250-799", 800}, {"800-1199", 1200}, {"1200+", math.MaxInt},
+}
+
+// imageAudit accumulates the scorecard of an image audit job
+type imageAudit struct {
+	card       models.ImageScorecard
+	qualitySum int
+}
+
+// newImageAudit starts a scorecard, from the report of a paused job when it is resumed
+func newImageAudit(report *models.JobReport) *imageAudit {
+	ia := &imageAudit{card: models.ImageScorecard{Backgrounds: map[string]int{}}}
+	for _, r := range resolutionRanges {
+		ia.card.Resolution = append(ia.card.Resolution, models.ResolutionBucket{Range: r.label})
+	}
+	ia.card.Resolution = append(ia.card.Resolution, models.ResolutionBucket{Range: "unknown"})
+	if report != nil && report.Images != nil {
+		ia.card = *report.Images
+		if ia.card.Backgrounds == nil {
+			ia.card.Backgrounds = map[string]int{}
+		}
+		ia.qualitySum = int(math.Round(ia.card.AverageQualityScore * float64(ia.card.Analyzed)))
+	}
+	return ia
+}
+
+func (ia *imageAudit) add(audit *agent.ImageAudit) {
+	c := &ia.card
+	c.Products++
+	switch {
+	case audit == nil:
+		c.NoImage++
+		return
+	case audit.Problem != "":
+		c.Unavailable++
+		return
+	}
+
+	if audit.Width > 0 || audit.Background != "" {
+		c.Measured++
+		bucket := len(resolutionRanges) // unknown
+		if side := min(audit.Width, audit.Height); side > 0 {
+			for i, r := range resolutionRanges {
+				if side < r.below {
+					bucket = i
+					break
+				}
+			}
+		}
+		c.Resolution[bucket].Products++
+	}
+	if audit.Background != "" {
+		c.Backgrounds[audit.Background]++
+		if audit.FillInRange {
+			c.ProductFillOK++
+		}
+	}
+	if audit.Analyzed {
+		c.Analyzed++
+		ia.qualitySum += audit.QualityScore
+		if audit.Watermark {
+			c.Watermarks++
+		}
+		if audit.TextOverlay {
+			c.TextOverlays++
+		}
+		if audit.PromoOverlay {
+			c.PromoOverlays++
+		}
+	}
+}
+
+// scorecard returns the scorecard with its percentages
+func (ia *imageAudit) scorecard() *models.ImageScorecard {
+	c := ia.card
+	backgrounds, uniform := 0, 0
+	for background, n := range c.Backgrounds {
+		backgrounds += n
+		if background != imageproxy.BackgroundLifestyle {
+			uniform += n
+		}
+	}
+	c.WhiteBackgroundPct = percent(c.Backgrounds[imageproxy.BackgroundWhite], backgrounds)
+	c.ProductFillOKPct = percent(c.ProductFillOK, uniform)
+	c.WatermarkPct = percent(c.Watermarks, c.Analyzed)
+	c.TextOverlayPct = percent(c.TextOverlays, c.Analyzed)
+	if c.Analyzed > 0 {
+		c.AverageQualityScore = math.Round(float64(ia.qualitySum)/float64(c.Analyzed)*10) / 10
+	}
+	return &c
+}
+
+// percent is n of total in percent, one decimal
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)*1000/float64(total)) / 10
+}
+
+// processImageAudit audits the main image of a product of an image audit job
+func (r *Runner) processImageAudit(aj *activeJob, index int) {
+	bg := context.Background()
+	product := &aj.products[index]
+
+	runCtx := agent.WithThresholds(agent.WithUsageMeter(aj.ctx, aj.usage), aj.thresholds)
+	ctx, cancel := context.WithTimeout(runCtx, r.config.Agent.Timeout)
+	audit, err := r.agent.AuditImage(ctx, product)
+	cancel()
+	if err != nil {
+		r.failed(aj, product, 1, err)
+		return
+	}
+
+	aj.imageAudit.add(audit)
+	aj.processed++
+	message := fmt.Sprintf("Audited %s: no image", product.ExternalID)
+	switch {
+	case audit == nil:
+	case audit.Problem != "":
+		message = fmt.Sprintf("Audited %s: image unavailable (%s)", product.ExternalID, audit.Problem)
+	default:
+		message = fmt.Sprintf("Audited %s: %dx%d, %s background", product.ExternalID, audit.Width, audit.Height, orUnknown(audit.Background))
+		if audit.Analyzed {
+			message += fmt.Sprintf(", quality %d", audit.QualityScore)
+		}
+	}
+	r.queries.UpdateJobCheckpoint(bg, aj.job.ID, product.ID, aj.processed, aj.proposals)
+	r.updateProgress(aj.job.ID, aj.processed, aj.proposals, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "success",
+		Message:   message,
+	})
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
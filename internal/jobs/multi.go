@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// chunk returns how many products the next pick of a job processes: up to its
+// multi-product size, one for other jobs. r.mu must be held.
+func (aj *activeJob) chunk() int {
+	if aj.multi < agent.MinMultiProducts {
+		return 1
+	}
+	return min(aj.multi, len(aj.products)-aj.next)
+}
+
+// processMulti runs the fast mode on count products of a multi-product job in one
+// optimization call. Products the call leaves out, or all of them when it fails, are run
+// again one by one, with the job's usual retries and dead-lettering.
+func (r *Runner) processMulti(aj *activeJob, index, count int) {
+	products := make([]*models.Product, count)
+	for i := range products {
+		products[i] = &aj.products[index+i]
+	}
+
+	runCtx := agent.WithThresholds(agent.WithUsageMeter(aj.ctx, aj.usage), aj.thresholds)
+	ctx, cancel := context.WithTimeout(runCtx, r.config.Agent.Timeout)
+	sessions, err := r.agent.RunMulti(ctx, products, "Audit: "+aj.label())
+	cancel()
+	if aj.ctx.Err() != nil {
+		return // cancelled: the products are not counted
+	}
+	if errors.Is(err, agent.ErrBudgetExhausted) {
+		r.pauseForBudget(aj, err)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Multi-product call failed for %d products, running them one by one: %v\n", count, err)
+	}
+	if len(sessions) != count {
+		for i := range products {
+			r.process(aj, index+i)
+		}
+		return
+	}
+
+	for i, session := range sessions {
+		if session.Status == "completed" {
+			r.save(aj, products[i], session)
+			continue
+		}
+		// The call log of the shared call explains why the product is run again
+		if err := r.queries.CreateLLMCalls(context.Background(), session.LLMCalls); err != nil {
+			fmt.Printf("Failed to save LLM call log for %s: %v\n", session.ProductID, err)
+		}
+		r.process(aj, index+i)
+	}
+}
@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// queue schedules products across active jobs with stride scheduling per dataset:
+// each dataset advances a virtual clock by 1/weight every time one of its products
+// is picked, and the dataset with the smallest clock goes next. The weight is the
+// highest priority among the dataset's queued jobs, so a small high-priority dataset
+// keeps getting slots while a massive low-priority backfill is running.
+// All methods must be called with Runner.mu held.
+type queue struct {
+	jobs    map[uuid.UUID]*activeJob
+	pass    map[uuid.UUID]float64 // virtual clock per dataset
+	avgItem time.Duration         // moving average of per-product duration
+}
+
+func newQueue() *queue {
+	return &queue{
+		jobs: make(map[uuid.UUID]*activeJob),
+		pass: make(map[uuid.UUID]float64),
+	}
+}
+
+func (q *queue) add(aj *activeJob) {
+	ds := aj.job.DatasetID
+	if _, ok := q.pass[ds]; !ok {
+		// Newcomers start at the current minimum so they neither starve nor burst
+		q.pass[ds] = q.minPass()
+	}
+	q.jobs[aj.job.ID] = aj
+}
+
+// remove drops aj, unless the job has since been resumed under a new entry
+func (q *queue) remove(aj *activeJob) {
+	if q.jobs[aj.job.ID] != aj {
+		return
+	}
+	delete(q.jobs, aj.job.ID)
+	for _, other := range q.jobs {
+		if other.job.DatasetID == aj.job.DatasetID {
+			return
+		}
+	}
+	delete(q.pass, aj.job.DatasetID)
+}
+
+func (q *queue) minPass() float64 {
+	min := 0.0
+	first := true
+	for _, p := range q.pass {
+		if first || p < min {
+			min = p
+			first = false
+		}
+	}
+	return min
+}
+
+func runnable(aj *activeJob) bool {
+	return !aj.inFlight && aj.stop == "" && aj.next < len(aj.products)
+}
+
+func (q *queue) weight(datasetID uuid.UUID) int {
+	w := MinPriority
+	for _, aj := range q.jobs {
+		if aj.job.DatasetID == datasetID && aj.stop == "" && aj.job.Priority > w {
+			w = aj.job.Priority
+		}
+	}
+	return w
+}
+
+// before orders jobs within a dataset: higher priority first, then FIFO
+func before(a, b *activeJob) bool {
+	if a.job.Priority != b.job.Priority {
+		return a.job.Priority > b.job.Priority
+	}
+	return a.enqueuedAt.Before(b.enqueuedAt)
+}
+
+// pick returns the next job to process one product of, or nil when nothing is runnable
+func (q *queue) pick() *activeJob {
+	best := map[uuid.UUID]*activeJob{}
+	for _, aj := range q.jobs {
+		if !runnable(aj) {
+			continue
+		}
+		ds := aj.job.DatasetID
+		if cur, ok := best[ds]; !ok || before(aj, cur) {
+			best[ds] = aj
+		}
+	}
+
+	var chosen *activeJob
+	for ds, aj := range best {
+		if chosen == nil {
+			chosen = aj
+			continue
+		}
+		cds := chosen.job.DatasetID
+		if q.pass[ds] < q.pass[cds] || (q.pass[ds] == q.pass[cds] && aj.enqueuedAt.Before(chosen.enqueuedAt)) {
+			chosen = aj
+		}
+	}
+	if chosen == nil {
+		return nil
+	}
+
+	ds := chosen.job.DatasetID
+	q.pass[ds] += 1.0 / float64(q.weight(ds))
+	return chosen
+}
+
+// observe feeds a product duration into the moving average
+func (q *queue) observe(d time.Duration) {
+	if q.avgItem == 0 {
+		q.avgItem = d
+		return
+	}
+	q.avgItem = time.Duration(0.8*float64(q.avgItem) + 0.2*float64(d))
+}
+
+// ordered returns queued jobs in approximate service order
+func (q *queue) ordered() []*activeJob {
+	list := make([]*activeJob, 0, len(q.jobs))
+	for _, aj := range q.jobs {
+		list = append(list, aj)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		pi, pj := q.pass[list[i].job.DatasetID], q.pass[list[j].job.DatasetID]
+		if list[i].job.DatasetID != list[j].job.DatasetID && pi != pj {
+			return pi < pj
+		}
+		return before(list[i], list[j])
+	})
+	return list
+}
+
+func remaining(aj *activeJob) int {
+	if aj.next >= len(aj.products) {
+		return 0
+	}
+	return len(aj.products) - aj.next
+}
+
+// info estimates a job's completion from its dataset's share of the workers.
+// Jobs of a dataset are served in priority/FIFO order, so a job finishes after the
+// remaining work of the jobs ahead of it in the same dataset.
+func (q *queue) info(jobID uuid.UUID, workers int) *models.QueueInfo {
+	aj, ok := q.jobs[jobID]
+	if !ok {
+		return nil
+	}
+
+	depth := 0
+	totalWeight := 0
+	datasetJobs := map[uuid.UUID][]*activeJob{}
+	for _, other := range q.jobs {
+		depth += remaining(other)
+		if other.stop == "" {
+			datasetJobs[other.job.DatasetID] = append(datasetJobs[other.job.DatasetID], other)
+		}
+	}
+	for ds := range datasetJobs {
+		totalWeight += q.weight(ds)
+	}
+
+	info := &models.QueueInfo{
+		Remaining:  remaining(aj),
+		QueueDepth: depth,
+		ActiveJobs: len(q.jobs),
+	}
+
+	siblings := datasetJobs[aj.job.DatasetID]
+	if len(siblings) == 0 || totalWeight == 0 {
+		return info
+	}
+
+	// Each job processes one product at a time, so a dataset can't use more workers than it has jobs
+	share := float64(workers) * float64(q.weight(aj.job.DatasetID)) / float64(totalWeight)
+	share = math.Min(share, float64(len(siblings)))
+	info.Share = math.Round(share*100) / 100
+
+	avg := q.avgItem
+	if done := aj.next - aj.start; done > 0 && aj.busy > 0 {
+		avg = aj.busy / time.Duration(done)
+	}
+	if avg == 0 || share == 0 {
+		return info
+	}
+
+	sort.Slice(siblings, func(i, j int) bool { return before(siblings[i], siblings[j]) })
+	ahead := 0
+	for _, s := range siblings {
+		ahead += remaining(s)
+		if s == aj {
+			break
+		}
+	}
+
+	eta := float64(ahead) * avg.Seconds() / share
+	eta = math.Round(eta)
+	completion := time.Now().Add(time.Duration(eta) * time.Second)
+	info.ETASeconds = &eta
+	info.EstimatedCompletion = &completion
+	return info
+}
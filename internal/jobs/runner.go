@@ -0,0 +1,645 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/benjamincozon/feedenrich/internal/shadow"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidTransition is returned when a control action doesn't apply to the job's current status
+var ErrInvalidTransition = errors.New("job cannot transition from its current status")
+
+// ErrStillStopping is returned when resuming a paused job whose last product is still in flight
+var ErrStillStopping = errors.New("job is still finishing its in-flight product")
+
+const (
+	MinPriority     = 1
+	MaxPriority     = 10
+	DefaultPriority = 5
+)
+
+// ClampPriority bounds a requested priority to the supported range
+func ClampPriority(p int) int {
+	if p < MinPriority {
+		return MinPriority
+	}
+	if p > MaxPriority {
+		return MaxPriority
+	}
+	return p
+}
+
+// AuditConfig is persisted in jobs.config so a paused job can be resumed
+type AuditConfig struct {
+	Group      agent.OptimizationGroup   `json:"group"`
+	Groups     []agent.OptimizationGroup `json:"groups,omitempty"`      // several groups run in one session per product; Group is the first
+	Tags       []string                  `json:"tags,omitempty"`        // product tag filter
+	SegmentID  *uuid.UUID                `json:"segment_id,omitempty"`  // saved segment the job targets
+	Refresh    *RefreshConfig            `json:"refresh,omitempty"`     // set on re-enrichment jobs of stale products
+	ProductIDs []uuid.UUID               `json:"product_ids,omitempty"` // explicit products, e.g. requeued failures
+	DryRun     *DryRunConfig             `json:"dry_run,omitempty"`     // run the pipeline without actionable output
+	ImageAudit *ImageAuditConfig         `json:"image_audit,omitempty"` // measure and analyze the images only, see imageaudit.go
+	Thresholds *models.Thresholds        `json:"thresholds,omitempty"`  // resolved when the job was created
+	Batch      *BatchConfig              `json:"batch,omitempty"`       // run through the provider's batch API
+	Engine     agent.Engine              `json:"engine,omitempty"`      // execution path, empty = agent
+	// Fast mode on this many similar products per optimization call (2-10), 0 = one per call
+	MultiProduct int `json:"multi_product,omitempty"`
+}
+
+// Thresholds resolves the thresholds of a run on a dataset: AGENT_* defaults, then the
+// dataset's overrides, then the request's. Invalid values return agent.ErrInvalidThresholds.
+func (r *Runner) Thresholds(ctx context.Context, datasetID uuid.UUID, request *models.ThresholdOverrides) (models.Thresholds, error) {
+	dataset, err := r.queries.GetDatasetThresholds(ctx, datasetID)
+	if err != nil {
+		return models.Thresholds{}, err
+	}
+	return agent.ResolveThresholds(agent.DefaultThresholds(r.config), dataset, request)
+}
+
+// Runner executes batch audit jobs on a fixed pool of workers.
+// Work is scheduled one product at a time so that concurrent jobs share workers
+// fairly across datasets, weighted by priority (see queue.go).
+type Runner struct {
+	config  *config.Config
+	queries *db.Queries
+	agent   *agent.Agent
+	shadow  *shadow.Evaluator
+	batcher llm.Batcher // nil when the provider has no batch API
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    *queue
+	draining bool // set by Shutdown: no new product is picked
+}
+
+// activeJob is the in-process state of a queued or running job
+type activeJob struct {
+	job      models.JobWithDetails
+	group    agent.OptimizationGroup   // first of groups
+	groups   []agent.OptimizationGroup // groups run on each product, in order
+	engine   agent.Engine
+	multi    int // products per optimization call, see AuditConfig.MultiProduct
+	products []models.Product
+	next     int // index of the next product to process
+	start    int // index the current run started from
+
+	processed int
+	proposals int
+	errors    int
+
+	dryRun     *DryRunConfig
+	imageAudit *imageAudit       // set on image audit jobs
+	usage      *agent.UsageMeter // LLM usage of the job's runs
+	thresholds models.Thresholds
+
+	inFlight   bool
+	started    bool
+	stop       string // "", paused, cancelled
+	ctx        context.Context
+	cancel     context.CancelFunc
+	enqueuedAt time.Time
+	busy       time.Duration // total processing time, for per-job ETA
+}
+
+// NewRunner creates a job runner and starts its workers
+func NewRunner(cfg *config.Config, queries *db.Queries, agnt *agent.Agent, shadowEval *shadow.Evaluator) *Runner {
+	r := &Runner{
+		config:  cfg,
+		queries: queries,
+		agent:   agnt,
+		shadow:  shadowEval,
+		queue:   newQueue(),
+	}
+	r.cond = sync.NewCond(&r.mu)
+	if b, err := llm.NewBatcher(cfg); err == nil {
+		r.batcher = b
+	}
+
+	workers := cfg.Jobs.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go r.worker()
+	}
+	return r
+}
+
+// updateProgress records the counters of a job with a log entry. Progress is best effort: a
+// failed write is logged and the run goes on.
+func (r *Runner) updateProgress(jobID uuid.UUID, processed, proposals int, entry *models.JobLog) {
+	if err := r.queries.UpdateJobProgress(context.Background(), jobID, processed, proposals, entry); err != nil {
+		fmt.Printf("Failed to record progress of job %s: %v\n", jobID, err)
+	}
+}
+
+// setStatus moves a job to status, logging a failed write
+func (r *Runner) setStatus(jobID uuid.UUID, status string, errMsg *string) {
+	if err := r.queries.UpdateJobStatus(context.Background(), jobID, status, errMsg); err != nil {
+		fmt.Printf("Failed to set job %s %s: %v\n", jobID, status, err)
+	}
+}
+
+// StartAudit queues an audit job over products
+func (r *Runner) StartAudit(job models.JobWithDetails, products []models.Product) {
+	var cfg AuditConfig
+	json.Unmarshal(job.Config, &cfg)
+
+	r.updateProgress(job.ID, job.ProcessedItems, job.ProposalsGenerated, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "info",
+		Message:   fmt.Sprintf("Queued %s audit for %d products (priority %d)", GroupsLabel(cfg.groups()), len(products), job.Priority),
+	})
+	cfg.order(products)
+	r.enqueue(job, cfg, products, 0)
+}
+
+// CountModule counts the proposals of one group
+func CountModule(proposals []models.Proposal, group agent.OptimizationGroup) int {
+	n := 0
+	for _, p := range proposals {
+		if p.Module == string(group) {
+			n++
+		}
+	}
+	return n
+}
+
+// groups returns the groups the job runs: Groups, or the single Group of older jobs
+func (c AuditConfig) groups() []agent.OptimizationGroup {
+	if len(c.Groups) > 0 {
+		return c.Groups
+	}
+	return []agent.OptimizationGroup{c.Group}
+}
+
+// order sorts the products of a multi-product job so that similar ones share a call.
+// The order is deterministic: a resumed job finds its checkpoint in it again.
+func (c AuditConfig) order(products []models.Product) {
+	if c.MultiProduct >= agent.MinMultiProducts {
+		agent.SortSimilar(products)
+	}
+}
+
+// label names the job's groups in logs
+func (aj *activeJob) label() string {
+	if len(aj.groups) == 0 {
+		return string(aj.group)
+	}
+	return GroupsLabel(aj.groups)
+}
+
+// GroupsLabel names a list of groups, for logs and a job's module
+// ("title_optimization+description_optimization")
+func GroupsLabel(groups []agent.OptimizationGroup) string {
+	names := make([]string, len(groups))
+	for i, g := range groups {
+		names[i] = string(g)
+	}
+	return strings.Join(names, "+")
+}
+
+func (r *Runner) enqueue(job models.JobWithDetails, cfg AuditConfig, products []models.Product, start int) {
+	thresholds := agent.DefaultThresholds(r.config)
+	if cfg.Thresholds != nil {
+		thresholds = *cfg.Thresholds
+	} else if t, err := r.Thresholds(context.Background(), job.DatasetID, nil); err == nil {
+		// Jobs created before thresholds were recorded follow the dataset's current ones
+		thresholds = t
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	aj := &activeJob{
+		job:        job,
+		group:      cfg.Group,
+		groups:     cfg.groups(),
+		engine:     cfg.Engine,
+		multi:      cfg.MultiProduct,
+		products:   products,
+		dryRun:     cfg.DryRun,
+		usage:      &agent.UsageMeter{},
+		thresholds: thresholds,
+		next:       start,
+		start:      start,
+		processed:  job.ProcessedItems,
+		proposals:  job.ProposalsGenerated,
+		ctx:        ctx,
+		cancel:     cancel,
+		enqueuedAt: time.Now(),
+	}
+	if cfg.ImageAudit != nil {
+		aj.imageAudit = newImageAudit(job.Report)
+	}
+
+	if start >= len(products) {
+		r.complete(aj)
+		return
+	}
+
+	r.mu.Lock()
+	if r.draining {
+		r.mu.Unlock()
+		r.pauseForShutdown(aj)
+		return
+	}
+	r.queue.add(aj)
+	r.mu.Unlock()
+	r.cond.Broadcast()
+}
+
+// Pause stops a job after the product currently being processed; it can be resumed later
+func (r *Runner) Pause(ctx context.Context, jobID uuid.UUID) error {
+	ok, err := r.queries.TransitionJobStatus(ctx, jobID, []string{"pending", "running"}, "paused")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTransition
+	}
+	r.stopJob(jobID, "paused")
+	return nil
+}
+
+// Cancel stops a job immediately, aborting the in-flight product
+func (r *Runner) Cancel(ctx context.Context, jobID uuid.UUID) error {
+	ok, err := r.queries.TransitionJobStatus(ctx, jobID, []string{"pending", "running", "paused"}, "cancelled")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTransition
+	}
+	r.stopJob(jobID, "cancelled")
+	r.cancelBatch(ctx, jobID)
+	return nil
+}
+
+func (r *Runner) stopJob(jobID uuid.UUID, reason string) {
+	r.mu.Lock()
+	aj, found := r.queue.jobs[jobID]
+	if !found {
+		r.mu.Unlock()
+		return
+	}
+	aj.stop = reason
+	if reason == "cancelled" {
+		aj.cancel()
+	}
+	idle := !aj.inFlight
+	if idle {
+		r.queue.remove(aj)
+	}
+	r.mu.Unlock()
+
+	// Jobs with a product in flight are finalized by their worker
+	if idle {
+		r.finishStopped(aj)
+	}
+}
+
+// Resume re-queues a paused job from the product after its checkpoint
+func (r *Runner) Resume(ctx context.Context, jobID uuid.UUID) error {
+	// The paused run is finalized by its worker once the product in flight is done;
+	// queueing the new run before that would let the old worker remove or overwrite it
+	r.mu.Lock()
+	_, stopping := r.queue.jobs[jobID]
+	r.mu.Unlock()
+	if stopping {
+		return ErrStillStopping
+	}
+
+	job, err := r.queries.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status != "paused" {
+		return ErrInvalidTransition
+	}
+
+	var cfg AuditConfig
+	if err := json.Unmarshal(job.Config, &cfg); err != nil || cfg.Group == "" {
+		return fmt.Errorf("job %s has no resumable config", jobID)
+	}
+	if cfg.Batch != nil {
+		return r.resumeBatch(ctx, job, cfg)
+	}
+
+	var products []models.Product
+	start := job.ProcessedItems
+	if cfg.Refresh != nil {
+		// Products re-enriched before the pause are no longer stale: start over on what is left
+		stale, err := r.StaleProducts(ctx, job.DatasetID, cfg.Group, cfg.Refresh.MaxAgeDays, 0)
+		if err != nil {
+			return err
+		}
+		for _, s := range stale {
+			products = append(products, s.Product)
+		}
+		start = 0
+	} else if len(cfg.ProductIDs) > 0 {
+		if products, err = r.queries.ListProductsByIDs(ctx, cfg.ProductIDs); err != nil {
+			return err
+		}
+	} else if products, err = r.queries.ListProductsInScope(ctx, job.DatasetID, cfg.SegmentID, cfg.Tags); err != nil {
+		return err
+	}
+
+	cfg.order(products)
+	if cfg.Refresh == nil && job.CheckpointProductID != nil {
+		for i := range products {
+			if products[i].ID == *job.CheckpointProductID {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if start > len(products) {
+		start = len(products)
+	}
+
+	// Back to pending: the worker flips it to running when it picks the next product
+	ok, err := r.queries.TransitionJobStatus(ctx, jobID, []string{"paused"}, "pending")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTransition
+	}
+
+	r.enqueue(*job, cfg, products, start)
+	return nil
+}
+
+// IsRunning reports whether a job is queued or executing in this process
+func (r *Runner) IsRunning(jobID uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.queue.jobs[jobID]
+	return ok
+}
+
+// QueueInfo returns scheduling info for a job queued in this process, or nil
+func (r *Runner) QueueInfo(jobID uuid.UUID) *models.QueueInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.queue.info(jobID, r.workers())
+}
+
+// Snapshot returns every queued job with its scheduling info
+func (r *Runner) Snapshot() []models.JobWithDetails {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var result []models.JobWithDetails
+	for _, aj := range r.queue.ordered() {
+		j := aj.job
+		j.ProcessedItems = aj.processed
+		j.ProposalsGenerated = aj.proposals
+		j.Queue = r.queue.info(aj.job.ID, r.workers())
+		result = append(result, j)
+	}
+	return result
+}
+
+func (r *Runner) workers() int {
+	if r.config.Jobs.Workers < 1 {
+		return 1
+	}
+	return r.config.Jobs.Workers
+}
+
+func (r *Runner) worker() {
+	for {
+		r.mu.Lock()
+		aj := r.next()
+		for aj == nil {
+			r.cond.Wait()
+			aj = r.next()
+		}
+		aj.inFlight = true
+		index, count := aj.next, aj.chunk()
+		first := !aj.started
+		aj.started = true
+		r.mu.Unlock()
+
+		if first {
+			r.begin(aj)
+		}
+
+		began := time.Now()
+		if count > 1 {
+			r.processMulti(aj, index, count)
+		} else {
+			r.process(aj, index)
+		}
+		elapsed := time.Since(began)
+
+		r.mu.Lock()
+		aj.inFlight = false
+		aj.next += count
+		aj.busy += elapsed
+		r.queue.observe(elapsed / time.Duration(count))
+		stopped := aj.stop != ""
+		done := aj.next >= len(aj.products)
+		if stopped || done {
+			r.queue.remove(aj)
+		}
+		r.mu.Unlock()
+		r.cond.Broadcast()
+
+		if stopped {
+			r.finishStopped(aj)
+		} else if done {
+			r.complete(aj)
+		}
+	}
+}
+
+// next returns the job to work on next, nil while draining for shutdown. r.mu must be held.
+func (r *Runner) next() *activeJob {
+	if r.draining {
+		return nil
+	}
+	return r.queue.pick()
+}
+
+// begin marks the job running when its first product is picked
+func (r *Runner) begin(aj *activeJob) {
+	message := fmt.Sprintf("Starting %s audit for %d products", aj.label(), len(aj.products))
+	if aj.start > 0 {
+		message = fmt.Sprintf("Resuming %s audit at product %d/%d", aj.label(), aj.start+1, len(aj.products))
+	}
+	r.setStatus(aj.job.ID, "running", nil)
+	r.updateProgress(aj.job.ID, aj.processed, aj.proposals, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "info",
+		Message:   message,
+	})
+	fmt.Printf("Audit group %s for dataset %s: products %d-%d\n", aj.label(), aj.job.DatasetID, aj.start+1, len(aj.products))
+}
+
+// process runs the agent on a single product of a job and records progress
+func (r *Runner) process(aj *activeJob, index int) {
+	if aj.imageAudit != nil {
+		r.processImageAudit(aj, index)
+		return
+	}
+	product := &aj.products[index]
+
+	var shadowRun *shadow.Run
+	if aj.dryRun == nil && len(aj.groups) == 1 && (aj.engine == "" || aj.engine == agent.EngineAgent) {
+		shadowRun = r.shadow.Start(product, aj.group, &aj.job.ID)
+	}
+	session, attempts, err := r.run(aj, product)
+	if err != nil {
+		shadowRun.Complete(nil, err)
+		r.failed(aj, product, attempts, err)
+		return
+	}
+
+	shadowRun.Complete(session.Proposals, nil)
+	r.save(aj, product, session)
+}
+
+// failed records a product of a job whose run failed: dead-lettered, or not counted when the
+// job was cancelled or ran out of budget
+func (r *Runner) failed(aj *activeJob, product *models.Product, attempts int, err error) {
+	// Use a detached context for bookkeeping so a cancelled run can still record its state
+	bg := context.Background()
+	if aj.ctx.Err() != nil {
+		return // cancelled: the product is not counted
+	}
+	if errors.Is(err, agent.ErrBudgetExhausted) {
+		r.pauseForBudget(aj, err)
+		return
+	}
+	fmt.Printf("Audit error for product %s after %d attempts: %v\n", product.ID, attempts, err)
+	aj.errors++
+	aj.processed++
+	r.deadLetter(aj, product, attempts, err)
+	r.queries.UpdateJobCheckpoint(bg, aj.job.ID, product.ID, aj.processed, aj.proposals)
+	r.updateProgress(aj.job.ID, aj.processed, aj.proposals, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "error",
+		Message:   fmt.Sprintf("Error processing %s (dead-lettered after %d attempts): %v", product.ExternalID, attempts, err),
+	})
+}
+
+// save stores what a successful run produced on a product of a job and records progress
+func (r *Runner) save(aj *activeJob, product *models.Product, session *agent.Session) {
+	bg := context.Background()
+	variants := r.VariantProposals(bg, product, session.Proposals)
+	aj.processed++
+	aj.proposals += len(session.Proposals) + len(variants)
+
+	records := db.RunRecords{PipelineRun: session.PipelineRun, LLMCalls: session.LLMCalls}
+	for _, prop := range append(session.Proposals, variants...) {
+		if aj.dryRun != nil {
+			prop.Status = ProposalStatusSimulated
+			aj.dryRun.count(prop)
+		}
+		records.Proposals = append(records.Proposals, prop)
+	}
+	// A dry run leaves no trace on the product: no enrichment record, no escalations, failures stay open
+	if aj.dryRun == nil {
+		records.HumanReviews = session.HumanReviews
+	}
+	if err := r.queries.CreateRunRecords(bg, records); err != nil {
+		fmt.Printf("Failed to save proposals and run records for %s: %v\n", product.ID, err)
+	}
+	if aj.dryRun == nil {
+		r.queries.ResolveJobFailures(bg, product.ID)
+		for _, group := range aj.groups {
+			if err := r.queries.RecordEnrichmentRun(bg, product.ID, string(group), CountModule(session.Proposals, group), ComplianceScore(product)); err != nil {
+				fmt.Printf("Failed to record enrichment run for %s: %v\n", product.ID, err)
+			}
+		}
+	}
+
+	r.queries.UpdateJobCheckpoint(bg, aj.job.ID, product.ID, aj.processed, aj.proposals)
+	r.updateProgress(aj.job.ID, aj.processed, aj.proposals, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "success",
+		Message:   processedMessage(product, len(session.Proposals), len(variants)),
+	})
+
+	fmt.Printf("Audit %s: product %d/%d - %d proposals\n", aj.label(), aj.processed, len(aj.products), len(session.Proposals))
+}
+
+func processedMessage(product *models.Product, proposals, variants int) string {
+	message := fmt.Sprintf("Processed %s: %d proposals", product.ExternalID, proposals)
+	if variants > 0 {
+		message += fmt.Sprintf(", %d copied to variants", variants)
+	}
+	return message
+}
+
+// ComplianceScore rates the product data a run saw against the hard rules (0-1)
+func ComplianceScore(product *models.Product) float64 {
+	data := product.CurrentData
+	if len(data) == 0 {
+		data = product.RawData
+	}
+	return tools.NewHardRuleValidator().Validate(data).ComplianceScore()
+}
+
+func (r *Runner) finishStopped(aj *activeJob) {
+	aj.cancel()
+	r.queries.UpdateJobReport(context.Background(), aj.job.ID, r.report(aj))
+	r.updateProgress(aj.job.ID, aj.processed, aj.proposals, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "warning",
+		Message:   fmt.Sprintf("Job %s after %d/%d products", aj.stop, aj.processed, len(aj.products)),
+	})
+}
+
+func (r *Runner) complete(aj *activeJob) {
+	aj.cancel()
+	bg := context.Background()
+
+	report := r.report(aj)
+	r.queries.UpdateJobReport(bg, aj.job.ID, report)
+	message := fmt.Sprintf("Completed: %d products, %d proposals, %d errors, %d tokens ($%.4f)",
+		aj.processed, aj.proposals, aj.errors, report.Usage.PromptTokens+report.Usage.CompletionTokens, report.Usage.CostUSD)
+	if saved := report.Usage.VisionTokensSaved; saved > 0 {
+		message += fmt.Sprintf(" - vision: %d calls, %d reused, %d tokens saved ($%.4f)",
+			report.Usage.VisionCalls, report.Usage.VisionReused, saved, report.Usage.VisionSavedUSD)
+	}
+	if card := report.Images; card != nil {
+		message += fmt.Sprintf(" - images: %.1f%% white background, %.1f%% watermarked, %d promotional overlays",
+			card.WhiteBackgroundPct, card.WatermarkPct, card.PromoOverlays)
+	}
+	if report.Projection != nil {
+		message += fmt.Sprintf(" - projected for %d products: %d proposals, $%.2f",
+			report.Projection.Products, report.Projection.Proposals, report.Projection.CostUSD)
+	}
+	r.updateProgress(aj.job.ID, aj.processed, aj.proposals, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "info",
+		Message:   message,
+	})
+
+	attempted := len(aj.products) - aj.start
+	if aj.errors > 0 && aj.errors == attempted {
+		errMsg := fmt.Sprintf("All %d products failed", aj.errors)
+		r.setStatus(aj.job.ID, "failed", &errMsg)
+	} else {
+		r.setStatus(aj.job.ID, "completed", nil)
+	}
+
+	fmt.Printf("Audit %s completed: %d/%d products, %d proposals, %d errors\n",
+		aj.label(), aj.processed, len(aj.products), aj.proposals, aj.errors)
+}
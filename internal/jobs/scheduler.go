@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrJobActive is returned when a dataset already has an active job for the group
+var ErrJobActive = errors.New("dataset already has an active job for this group")
+
+// RefreshConfig marks an audit job that re-enriches stale products
+type RefreshConfig struct {
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// StaleProducts lists products of a dataset due for a new run of group: their data changed
+// since the last run, or that run is older than maxAgeDays
+func (r *Runner) StaleProducts(ctx context.Context, datasetID uuid.UUID, group agent.OptimizationGroup, maxAgeDays, limit int) ([]models.StaleProduct, error) {
+	if maxAgeDays <= 0 {
+		maxAgeDays = r.config.Schedule.MaxAgeDays
+	}
+	if limit <= 0 {
+		limit = r.config.Schedule.BatchSize
+	}
+	runBefore := time.Now().AddDate(0, 0, -maxAgeDays)
+	return r.queries.ListStaleProducts(ctx, datasetID, string(group), runBefore, limit)
+}
+
+// ScheduleRefresh queues a job re-enriching the stale products of a dataset for a group.
+// It returns a nil job when nothing is stale, and ErrJobActive when the group already has
+// a job pending, running or paused on the dataset.
+func (r *Runner) ScheduleRefresh(ctx context.Context, datasetID uuid.UUID, group agent.OptimizationGroup, maxAgeDays, priority int) (*models.JobWithDetails, error) {
+	if maxAgeDays <= 0 {
+		maxAgeDays = r.config.Schedule.MaxAgeDays
+	}
+	active, err := r.queries.HasActiveJob(ctx, datasetID, string(group))
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, ErrJobActive
+	}
+
+	stale, err := r.StaleProducts(ctx, datasetID, group, maxAgeDays, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	products := make([]models.Product, len(stale))
+	changed := 0
+	for i, s := range stale {
+		products[i] = s.Product
+		if s.Reason == "data_changed" {
+			changed++
+		}
+	}
+
+	thresholds, err := r.Thresholds(ctx, datasetID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.JobWithDetails{
+		Job: models.Job{
+			ID:        uuid.New(),
+			DatasetID: datasetID,
+			Type:      "Re-enrich: " + groupName(group),
+			Status:    "pending",
+			CreatedAt: time.Now(),
+		},
+		Module:     string(group),
+		Priority:   ClampPriority(priority),
+		TotalItems: len(products),
+		Logs:       []models.JobLog{},
+	}
+	job.Config, _ = json.Marshal(AuditConfig{Group: group, Refresh: &RefreshConfig{MaxAgeDays: maxAgeDays}, Thresholds: &thresholds})
+	if err := r.queries.CreateJobWithDetails(ctx, job); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Scheduled re-enrichment %s for dataset %s: %d products (%d changed, %d older than %d days)\n",
+		group, datasetID, len(products), changed, len(products)-changed, maxAgeDays)
+	r.StartAudit(job, products)
+	return &job, nil
+}
+
+func groupName(group agent.OptimizationGroup) string {
+	for _, g := range agent.GetAllGroups() {
+		if g.ID == group {
+			return g.Name
+		}
+	}
+	return string(group)
+}
+
+// StartScheduler periodically queues re-enrichment of stale products on every dataset
+// until ctx is cancelled
+func (r *Runner) StartScheduler(ctx context.Context) {
+	if !r.config.Schedule.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(r.config.Schedule.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.scheduleStale(ctx); err != nil {
+			fmt.Printf("Re-enrichment scheduling failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) scheduleStale(ctx context.Context) error {
+	datasets, err := r.queries.ListDatasets(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, ds := range datasets {
+		for _, g := range r.config.Schedule.Groups {
+			_, err := r.ScheduleRefresh(ctx, ds.ID, agent.OptimizationGroup(g), r.config.Schedule.MaxAgeDays, r.config.Schedule.Priority)
+			if err != nil && !errors.Is(err, ErrJobActive) {
+				fmt.Printf("Re-enrichment of dataset %s (%s) not scheduled: %v\n", ds.ID, g, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"math"
+
+	"github.com/benjamincozon/feedenrich/internal/agent/tools"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/google/uuid"
+)
+
+// scoreBatch is how many products are scored per round trip
+const scoreBatch = 500
+
+// RuleScore is the rule compliance of product data, 0-1 with two decimals like the score
+// columns of products
+func RuleScore(validator *tools.HardRuleValidator, data []byte) float64 {
+	return math.Round(validator.Validate(data).ComplianceScore()*100) / 100
+}
+
+// ScoreDataset scores the products of a dataset whose data changed since they were last scored
+// (imported, re-imported, a proposal applied or reverted), then refreshes the dataset
+// statistics when any was. It returns how many products were scored.
+func ScoreDataset(ctx context.Context, queries *db.Queries, datasetID uuid.UUID) (int, error) {
+	validator := tools.NewHardRuleValidator()
+	scored := 0
+	for {
+		products, err := queries.ListUnscoredProducts(ctx, datasetID, scoreBatch)
+		if err != nil {
+			return scored, err
+		}
+		if len(products) == 0 {
+			break
+		}
+		scores := make([]db.ProductScore, len(products))
+		for i := range products {
+			p := &products[i]
+			current := p.CurrentData
+			if len(current) == 0 {
+				current = p.RawData
+			}
+			scores[i] = db.ProductScore{
+				ProductID: p.ID,
+				Version:   p.Version,
+				Baseline:  RuleScore(validator, p.RawData),
+				Current:   RuleScore(validator, current),
+			}
+		}
+		if err := queries.SaveProductScores(ctx, scores); err != nil {
+			return scored, err
+		}
+		scored += len(products)
+		if len(products) < scoreBatch {
+			break
+		}
+	}
+	if scored == 0 {
+		return 0, nil
+	}
+	return scored, queries.RefreshDatasetStats(ctx)
+}
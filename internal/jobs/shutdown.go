@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// abortGrace is how long aborted products get to record their state once the
+// shutdown deadline has passed
+const abortGrace = 5 * time.Second
+
+// Shutdown stops scheduling products and pauses every queued job at its checkpoint,
+// so it can be resumed once the server is back (POST /jobs/:id/resume). Products in
+// flight get until ctx is done to finish and persist; after that they are aborted and
+// not counted, and the resumed job runs them again.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.draining = true
+	var idle, all []*activeJob
+	for _, aj := range r.queue.jobs {
+		aj.stop = "paused"
+		all = append(all, aj)
+		if !aj.inFlight {
+			idle = append(idle, aj)
+			r.queue.remove(aj)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, aj := range all {
+		r.pauseForShutdown(aj)
+	}
+	// Jobs with a product in flight are finalized by their worker
+	for _, aj := range idle {
+		r.finishStopped(aj)
+	}
+
+	if r.waitIdle(ctx) {
+		return nil
+	}
+
+	r.mu.Lock()
+	aborted := len(r.queue.jobs)
+	for _, aj := range r.queue.jobs {
+		aj.cancel()
+	}
+	r.mu.Unlock()
+
+	grace, cancel := context.WithTimeout(context.Background(), abortGrace)
+	defer cancel()
+	r.waitIdle(grace)
+	return fmt.Errorf("shutdown deadline reached: aborted in-flight products of %d jobs", aborted)
+}
+
+// pauseForShutdown moves a job to paused so it is resumable after restart
+func (r *Runner) pauseForShutdown(aj *activeJob) {
+	bg := context.Background()
+	if ok, err := r.queries.TransitionJobStatus(bg, aj.job.ID, []string{"pending", "running"}, "paused"); err != nil || !ok {
+		return
+	}
+	r.updateProgress(aj.job.ID, aj.processed, aj.proposals, &models.JobLog{
+		Timestamp: time.Now(),
+		Level:     "warning",
+		Message:   "Paused by server shutdown, resume to continue from the checkpoint",
+	})
+	fmt.Printf("Shutdown: paused job %s at %d/%d products\n", aj.job.ID, aj.processed, len(aj.products))
+}
+
+// waitIdle waits until no job is left in the queue; false if ctx ended first
+func (r *Runner) waitIdle(ctx context.Context) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		n := len(r.queue.jobs)
+		r.mu.Unlock()
+		if n == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// VariantProposals returns the proposals of a run on product copied to its variants
+// (see agent.VariantProposals), leaving out fields a variant already has a proposal
+// awaiting review for
+func (r *Runner) VariantProposals(ctx context.Context, product *models.Product, proposals []models.Proposal) []models.Proposal {
+	group := agent.ItemGroupID(product)
+	if group == "" || len(proposals) == 0 || len(r.config.Agent.VariantFields) == 0 {
+		return nil
+	}
+	siblings, err := r.queries.ListVariantSiblings(ctx, product.DatasetID, group, product.ID)
+	if err != nil {
+		fmt.Printf("Failed to list variants of %s: %v\n", product.ID, err)
+		return nil
+	}
+	if len(siblings) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(siblings))
+	for i, s := range siblings {
+		ids[i] = s.ID
+	}
+	pending, err := r.queries.ListPendingProposalFields(ctx, ids)
+	if err != nil {
+		fmt.Printf("Failed to list pending proposals of the variants of %s: %v\n", product.ID, err)
+		return nil
+	}
+
+	var kept []models.Proposal
+	for _, p := range r.agent.VariantProposals(product, proposals, siblings) {
+		if pending[p.ProductID][p.Field] {
+			continue
+		}
+		if pending[p.ProductID] == nil {
+			pending[p.ProductID] = map[string]bool{}
+		}
+		pending[p.ProductID][p.Field] = true
+		kept = append(kept, p)
+	}
+	return kept
+}
@@ -0,0 +1,234 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+)
+
+// anthropic implements the provider with the Claude Messages API
+type anthropic struct {
+	http      *http.Client
+	baseURL   string
+	apiKey    string
+	maxTokens int
+}
+
+func newAnthropic(cfg *config.Config, httpClient *http.Client) *anthropic {
+	base := cfg.Anthropic.BaseURL
+	if base == "" {
+		base = anthropicBaseURL
+	}
+	return &anthropic{
+		http:      httpClient,
+		baseURL:   strings.TrimSuffix(base, "/"),
+		apiKey:    cfg.Anthropic.APIKey,
+		maxTokens: cfg.Anthropic.MaxTokens,
+	}
+}
+
+type anthropicBlock struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text,omitempty"`
+	Source    *anthropicImageSource `json:"source,omitempty"`
+	ID        string                `json:"id,omitempty"`          // tool_use
+	Name      string                `json:"name,omitempty"`        // tool_use
+	Input     json.RawMessage       `json:"input,omitempty"`       // tool_use
+	ToolUseID string                `json:"tool_use_id,omitempty"` // tool_result
+	Content   string                `json:"content,omitempty"`     // tool_result
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"` // url, base64
+	URL       string `json:"url,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature *float32           `json:"temperature,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice  any                `json:"tool_choice,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// structuredTool is the tool Claude is forced to call to answer against a schema
+const structuredTool = "structured_output"
+
+func (a *anthropic) complete(ctx context.Context, req Request) (*Response, error) {
+	areq := anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		System:    systemPrompt(req.Messages),
+	}
+	if areq.MaxTokens <= 0 {
+		areq.MaxTokens = a.maxTokens
+	}
+	if req.Temperature > 0 {
+		areq.Temperature = &req.Temperature
+	}
+	for _, t := range req.Tools {
+		areq.Tools = append(areq.Tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: objectSchema(t.Parameters)})
+	}
+
+	// No JSON mode: a schema becomes a forced tool call, plain JSON an instruction plus
+	// a prefilled "{" the answer continues
+	prefill := ""
+	switch {
+	case req.Schema != nil:
+		areq.Tools = append(areq.Tools, anthropicTool{Name: structuredTool, Description: "Return the answer.", InputSchema: req.Schema.Schema})
+		areq.ToolChoice = map[string]string{"type": "tool", "name": structuredTool}
+	case req.JSON:
+		areq.System = strings.TrimSpace(areq.System + "\n\n" + jsonInstruction)
+		prefill = "{"
+	}
+
+	for _, m := range req.Messages {
+		msg, err := a.message(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			continue
+		}
+		// Consecutive tool results must share one user turn
+		if n := len(areq.Messages); n > 0 && areq.Messages[n-1].Role == msg.Role {
+			areq.Messages[n-1].Content = append(areq.Messages[n-1].Content, msg.Content...)
+			continue
+		}
+		areq.Messages = append(areq.Messages, *msg)
+	}
+	if prefill != "" {
+		areq.Messages = append(areq.Messages, anthropicMessage{Role: "assistant", Content: []anthropicBlock{{Type: "text", Text: prefill}}})
+	}
+
+	var aresp anthropicResponse
+	err := postJSON(ctx, a.http, a.baseURL+"/v1/messages", map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": anthropicVersion,
+	}, areq, &aresp)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{
+		Usage: Usage{
+			PromptTokens:     aresp.Usage.InputTokens,
+			CompletionTokens: aresp.Usage.OutputTokens,
+			TotalTokens:      aresp.Usage.InputTokens + aresp.Usage.OutputTokens,
+		},
+	}
+	var text strings.Builder
+	for _, b := range aresp.Content {
+		switch b.Type {
+		case "text":
+			text.WriteString(b.Text)
+		case "tool_use":
+			if b.Name == structuredTool && req.Schema != nil {
+				text.Write(b.Input)
+				continue
+			}
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: b.ID, Name: b.Name, Arguments: string(b.Input)})
+		}
+	}
+	if text.Len() == 0 && len(resp.ToolCalls) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	resp.Content = prefill + text.String()
+
+	switch aresp.StopReason {
+	case "tool_use":
+		resp.FinishReason = FinishToolCalls
+		if len(resp.ToolCalls) == 0 {
+			resp.FinishReason = FinishStop // the forced structured_output call
+		}
+	case "max_tokens":
+		resp.FinishReason = FinishLength
+	default:
+		resp.FinishReason = FinishStop
+	}
+	return resp, nil
+}
+
+// message converts one message; system messages are sent apart (nil)
+func (a *anthropic) message(ctx context.Context, m Message) (*anthropicMessage, error) {
+	switch m.Role {
+	case RoleSystem:
+		return nil, nil
+	case RoleTool:
+		return &anthropicMessage{Role: "user", Content: []anthropicBlock{{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content}}}, nil
+	case RoleAssistant:
+		msg := &anthropicMessage{Role: "assistant"}
+		if m.Content != "" {
+			msg.Content = append(msg.Content, anthropicBlock{Type: "text", Text: m.Content})
+		}
+		for _, tc := range m.ToolCalls {
+			msg.Content = append(msg.Content, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Name, Input: schemaOrEmpty(json.RawMessage(tc.Arguments))})
+		}
+		return msg, nil
+	}
+
+	msg := &anthropicMessage{Role: "user"}
+	for _, img := range m.Images {
+		source := &anthropicImageSource{Type: "url", URL: img.URL}
+		if strings.HasPrefix(img.URL, "data:") {
+			mediaType, data, err := imageData(ctx, nil, img)
+			if err != nil {
+				return nil, err
+			}
+			source = &anthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}
+		}
+		msg.Content = append(msg.Content, anthropicBlock{Type: "image", Source: source})
+	}
+	if m.Content != "" || len(msg.Content) == 0 {
+		msg.Content = append(msg.Content, anthropicBlock{Type: "text", Text: m.Content})
+	}
+	return msg, nil
+}
+
+// schemaOrEmpty returns raw, or an empty JSON object when raw is empty
+func schemaOrEmpty(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage(`{}`)
+	}
+	return raw
+}
+
+// objectSchema returns a tool's parameter schema, or one without parameters
+func objectSchema(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 || string(raw) == "null" {
+		return json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return raw
+}
@@ -0,0 +1,212 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/retry"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ErrBatchUnsupported is returned by NewBatcher for providers without a batch API
+var ErrBatchUnsupported = errors.New("llm: batch API requires LLM_PROVIDER=openai")
+
+// MaxBatchRequests is the most requests a single batch accepts
+const MaxBatchRequests = 50000
+
+// BatchItem is one request of a batch; ID comes back with its result
+type BatchItem struct {
+	ID      string
+	Request Request
+}
+
+// BatchStatus is the progress of a submitted batch
+type BatchStatus struct {
+	ID        string
+	State     string // validating, in_progress, finalizing, completed, failed, expired, cancelling, cancelled
+	Total     int
+	Completed int
+	Failed    int
+	Error     string // why the whole batch failed
+
+	outputFileID string
+	errorFileID  string
+}
+
+// Done reports whether the batch reached a final state. Expired and cancelled batches
+// still return the results of the requests that completed.
+func (s *BatchStatus) Done() bool {
+	switch s.State {
+	case "completed", "failed", "expired", "cancelled":
+		return true
+	}
+	return false
+}
+
+// BatchResult is the outcome of a batch item: a response, or the error it failed with
+type BatchResult struct {
+	ID       string
+	Response *Response
+	Error    string
+}
+
+// Batcher runs requests asynchronously through a provider's batch API (OpenAI: half
+// the price of synchronous calls, results within 24 hours). The fallback chain and
+// per-call timeout don't apply.
+type Batcher interface {
+	// Submit uploads the requests and starts a batch, returning its ID
+	Submit(ctx context.Context, items []BatchItem) (string, error)
+	// Status returns the progress of a batch
+	Status(ctx context.Context, id string) (*BatchStatus, error)
+	// Results returns the results of a finished batch, unordered
+	Results(ctx context.Context, status *BatchStatus) ([]BatchResult, error)
+	// Cancel stops a batch; requests already completed keep their results
+	Cancel(ctx context.Context, id string) error
+}
+
+// NewBatcher returns the batcher of the configured provider, ErrBatchUnsupported when it has none
+func NewBatcher(cfg *config.Config) (Batcher, error) {
+	if cfg.LLM.Provider != "openai" || cfg.OpenAI.APIType == "azure" {
+		return nil, ErrBatchUnsupported
+	}
+	httpClient := &http.Client{Transport: &retry.Transport{Policy: retry.PolicyFromConfig(cfg)}}
+	return &openAIBatcher{client: newOpenAI(cfg, httpClient).client, stages: StagesFor(cfg)}, nil
+}
+
+type openAIBatcher struct {
+	client *openai.Client
+	stages *Stages
+}
+
+func (b *openAIBatcher) Submit(ctx context.Context, items []BatchItem) (string, error) {
+	if len(items) > MaxBatchRequests {
+		return "", fmt.Errorf("llm: %d requests, a batch takes at most %d", len(items), MaxBatchRequests)
+	}
+	file := openai.UploadBatchFileRequest{FileName: "feedenrich-batch.jsonl"}
+	for _, it := range items {
+		b.stages.apply(ctx, &it.Request)
+		file.AddChatCompletion(it.ID, openAIRequest(it.Request))
+	}
+	resp, err := b.client.CreateBatchWithUploadFile(ctx, openai.CreateBatchWithUploadFileRequest{
+		Endpoint:               openai.BatchEndpointChatCompletions,
+		CompletionWindow:       "24h",
+		UploadBatchFileRequest: file,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (b *openAIBatcher) Status(ctx context.Context, id string) (*BatchStatus, error) {
+	resp, err := b.client.RetrieveBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	st := &BatchStatus{
+		ID:        resp.ID,
+		State:     resp.Status,
+		Total:     resp.RequestCounts.Total,
+		Completed: resp.RequestCounts.Completed,
+		Failed:    resp.RequestCounts.Failed,
+	}
+	if resp.OutputFileID != nil {
+		st.outputFileID = *resp.OutputFileID
+	}
+	if resp.ErrorFileID != nil {
+		st.errorFileID = *resp.ErrorFileID
+	}
+	if resp.Errors != nil {
+		var msgs []string
+		for _, e := range resp.Errors.Data {
+			msgs = append(msgs, e.Message)
+		}
+		st.Error = strings.Join(msgs, "; ")
+	}
+	return st, nil
+}
+
+// batchLine is a line of the output or error file of a batch
+type batchLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int                           `json:"status_code"`
+		Body       openai.ChatCompletionResponse `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (b *openAIBatcher) Results(ctx context.Context, status *BatchStatus) ([]BatchResult, error) {
+	var results []BatchResult
+	for _, fileID := range []string{status.outputFileID, status.errorFileID} {
+		if fileID == "" {
+			continue
+		}
+		lines, err := b.readFile(ctx, fileID)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range lines {
+			results = append(results, l.result())
+		}
+	}
+	return results, nil
+}
+
+func (b *openAIBatcher) readFile(ctx context.Context, fileID string) ([]batchLine, error) {
+	content, err := b.client.GetFileContent(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	var lines []batchLine
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // one completion per line
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var l batchLine
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("llm: batch file %s: %w", fileID, err)
+		}
+		lines = append(lines, l)
+	}
+	return lines, scanner.Err()
+}
+
+func (l batchLine) result() BatchResult {
+	r := BatchResult{ID: l.CustomID}
+	switch {
+	case l.Error != nil:
+		r.Error = fmt.Sprintf("%s: %s", l.Error.Code, l.Error.Message)
+	case l.Response == nil:
+		r.Error = "no response"
+	case l.Response.StatusCode < 200 || l.Response.StatusCode > 299:
+		r.Error = fmt.Sprintf("status %d", l.Response.StatusCode)
+	default:
+		resp, err := openAIResponse(l.Response.Body)
+		if err != nil {
+			r.Error = err.Error()
+			break
+		}
+		resp.Model = l.Response.Body.Model
+		r.Response = resp
+	}
+	return r
+}
+
+func (b *openAIBatcher) Cancel(ctx context.Context, id string) error {
+	_, err := b.client.CancelBatch(ctx, id)
+	return err
+}
@@ -0,0 +1,187 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+)
+
+// Call is one model attempt as recorded in a call log: a call that fell back to another
+// model is logged once per model tried. Request, Response and Error are redacted.
+type Call struct {
+	Model     string
+	Request   json.RawMessage
+	Response  string
+	Error     string
+	LatencyMs int
+	Usage     Usage
+	CreatedAt time.Time
+}
+
+// CallLog collects the calls made with a context carrying it (see WithCallLog).
+// Safe for concurrent use.
+type CallLog struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+type callLogKey struct{}
+
+// WithCallLog returns a context whose LLM calls are recorded in l
+func WithCallLog(ctx context.Context, l *CallLog) context.Context {
+	return context.WithValue(ctx, callLogKey{}, l)
+}
+
+func callLogFrom(ctx context.Context) *CallLog {
+	l, _ := ctx.Value(callLogKey{}).(*CallLog)
+	return l
+}
+
+// Calls returns the calls recorded so far, oldest first
+func (l *CallLog) Calls() []Call {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Call(nil), l.calls...)
+}
+
+func (l *CallLog) add(c Call) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, c)
+}
+
+// loggedRequest is the JSON form of a request in the call log. Images are kept as URLs,
+// data: URLs reduced to their size; schemas and tools to their names.
+type loggedRequest struct {
+	Model       string          `json:"model"`
+	Temperature float32         `json:"temperature,omitempty"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	JSON        bool            `json:"json,omitempty"`
+	Schema      string          `json:"schema,omitempty"`
+	Tools       []string        `json:"tools,omitempty"`
+	Messages    []loggedMessage `json:"messages"`
+}
+
+type loggedMessage struct {
+	Role       Role       `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	Images     []string   `json:"images,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// record adds an attempt to the call log of ctx, if any
+func (c *client) record(ctx context.Context, req Request, resp *Response, err error, started time.Time) {
+	l := callLogFrom(ctx)
+	if l == nil {
+		return
+	}
+	lr := loggedRequest{Model: req.Model, Temperature: req.Temperature, MaxTokens: req.MaxTokens, JSON: req.JSON}
+	if req.Schema != nil {
+		lr.Schema = req.Schema.Name
+	}
+	for _, t := range req.Tools {
+		lr.Tools = append(lr.Tools, t.Name)
+	}
+	for _, m := range req.Messages {
+		lm := loggedMessage{Role: m.Role, Content: m.Content, ToolCalls: m.ToolCalls, ToolCallID: m.ToolCallID}
+		for _, img := range m.Images {
+			if strings.HasPrefix(img.URL, "data:") {
+				lm.Images = append(lm.Images, fmt.Sprintf("data: (%d bytes)", len(img.URL)))
+			} else {
+				lm.Images = append(lm.Images, img.URL)
+			}
+		}
+		lr.Messages = append(lr.Messages, lm)
+	}
+	raw, _ := json.Marshal(lr)
+
+	secrets := c.secrets
+	if extra, _ := ctx.Value(secretsKey{}).([]string); len(extra) > 0 {
+		secrets = append(slices.Clip(secrets), extra...)
+	}
+	call := Call{
+		Model:     req.Model,
+		Request:   json.RawMessage(redact(string(raw), secrets)),
+		LatencyMs: int(time.Since(started).Milliseconds()),
+		CreatedAt: started,
+	}
+	if err != nil {
+		call.Error = redact(err.Error(), secrets)
+	}
+	if resp != nil {
+		out := resp.Content
+		if len(resp.ToolCalls) > 0 {
+			calls, _ := json.Marshal(resp.ToolCalls)
+			out = strings.TrimSpace(out + "\n" + string(calls))
+		}
+		call.Response = redact(out, secrets)
+		call.Usage = resp.Usage
+	}
+	l.add(call)
+}
+
+// secretPatterns match credentials that can end up in prompts or provider errors:
+// OpenAI/Anthropic and Google API keys, bearer tokens, key query parameters
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}`),
+	regexp.MustCompile(`AIza[0-9A-Za-z_\-]{30,}`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._\-]+`),
+	regexp.MustCompile(`(?i)([?&](?:api_?)?key=)[^&\s"]+`),
+}
+
+const redacted = "[REDACTED]"
+
+// secretsFrom returns the configured credentials, redacted wherever they appear
+func secretsFrom(cfg *config.Config) []string {
+	var secrets []string
+	for _, s := range []string{cfg.OpenAI.APIKey, cfg.Anthropic.APIKey, cfg.Gemini.APIKey, cfg.Local.APIKey, cfg.WebSearch.BraveAPIKey,
+		cfg.WebSearch.SerpAPIKey, cfg.WebSearch.BingAPIKey, cfg.WebSearch.GoogleAPIKey,
+		cfg.GTINRegistry.GS1APIKey, cfg.GTINRegistry.UPCitemdbKey, cfg.WebSearch.RenderToken} {
+		if len(s) >= 8 { // too short to be a real key, and would redact ordinary words
+			secrets = append(secrets, s)
+		}
+	}
+	return secrets
+}
+
+type secretsKey struct{}
+
+// WithSecrets returns a context whose call logs also redact secrets, e.g. the provider
+// keys of the organization a run is for
+func WithSecrets(ctx context.Context, secrets ...string) context.Context {
+	all, _ := ctx.Value(secretsKey{}).([]string)
+	all = slices.Clip(all)
+	for _, s := range secrets {
+		if len(s) >= 8 {
+			all = append(all, s)
+		}
+	}
+	return context.WithValue(ctx, secretsKey{}, all)
+}
+
+// redact blanks secrets and anything looking like a credential in s. The result stays
+// valid JSON when s is: redactions never touch quotes.
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	for _, p := range secretPatterns {
+		if p.NumSubexp() > 0 {
+			s = p.ReplaceAllString(s, "${1}"+redacted)
+		} else {
+			s = p.ReplaceAllString(s, redacted)
+		}
+	}
+	return s
+}
@@ -0,0 +1,214 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+)
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com"
+
+// gemini implements the provider with the Gemini generateContent API
+type gemini struct {
+	http    *http.Client
+	images  *imageproxy.Proxy
+	baseURL string
+	apiKey  string
+}
+
+func newGemini(cfg *config.Config, httpClient *http.Client, images *imageproxy.Proxy) *gemini {
+	base := cfg.Gemini.BaseURL
+	if base == "" {
+		base = geminiBaseURL
+	}
+	return &gemini{http: httpClient, images: images, baseURL: strings.TrimSuffix(base, "/"), apiKey: cfg.Gemini.APIKey}
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"` // user, model
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature        *float32        `json:"temperature,omitempty"`
+	MaxOutputTokens    int             `json:"maxOutputTokens,omitempty"`
+	Seed               *int            `json:"seed,omitempty"`
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	Tools             []map[string]any       `json:"tools,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func (g *gemini) complete(ctx context.Context, req Request) (*Response, error) {
+	greq := geminiRequest{GenerationConfig: geminiGenerationConfig{MaxOutputTokens: req.MaxTokens, Seed: req.Seed}}
+	if req.Temperature > 0 {
+		greq.GenerationConfig.Temperature = &req.Temperature
+	}
+	if sys := systemPrompt(req.Messages); sys != "" {
+		greq.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: sys}}}
+	}
+	if req.Schema != nil || req.JSON {
+		greq.GenerationConfig.ResponseMimeType = "application/json"
+	}
+	if req.Schema != nil {
+		greq.GenerationConfig.ResponseJSONSchema = req.Schema.Schema
+	}
+	if len(req.Tools) > 0 {
+		var decls []geminiFunctionDeclaration
+		for _, t := range req.Tools {
+			decl := geminiFunctionDeclaration{Name: t.Name, Description: t.Description}
+			if len(t.Parameters) > 0 && string(t.Parameters) != "null" {
+				decl.Parameters = t.Parameters
+			}
+			decls = append(decls, decl)
+		}
+		greq.Tools = []map[string]any{{"functionDeclarations": decls}}
+	}
+
+	for _, m := range req.Messages {
+		content, err := g.content(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+		if n := len(greq.Contents); n > 0 && greq.Contents[n-1].Role == content.Role {
+			greq.Contents[n-1].Parts = append(greq.Contents[n-1].Parts, content.Parts...)
+			continue
+		}
+		greq.Contents = append(greq.Contents, *content)
+	}
+
+	var gresp geminiResponse
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:generateContent", g.baseURL, url.PathEscape(req.Model))
+	if err := postJSON(ctx, g.http, endpoint, map[string]string{"x-goog-api-key": g.apiKey}, greq, &gresp); err != nil {
+		return nil, err
+	}
+	if len(gresp.Candidates) == 0 {
+		return nil, ErrEmptyResponse
+	}
+
+	candidate := gresp.Candidates[0]
+	resp := &Response{
+		Usage: Usage{
+			PromptTokens:     gresp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: gresp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      gresp.UsageMetadata.TotalTokenCount,
+		},
+		FinishReason: FinishStop,
+	}
+	var text strings.Builder
+	for i, p := range candidate.Content.Parts {
+		if p.FunctionCall != nil {
+			// Gemini has no call IDs; results are matched by name
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{
+				ID:        fmt.Sprintf("call_%d_%s", i, p.FunctionCall.Name),
+				Name:      p.FunctionCall.Name,
+				Arguments: string(schemaOrEmpty(p.FunctionCall.Args)),
+			})
+			continue
+		}
+		text.WriteString(p.Text)
+	}
+	resp.Content = text.String()
+	if resp.Content == "" && len(resp.ToolCalls) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	switch {
+	case len(resp.ToolCalls) > 0:
+		resp.FinishReason = FinishToolCalls
+	case candidate.FinishReason == "MAX_TOKENS":
+		resp.FinishReason = FinishLength
+	}
+	return resp, nil
+}
+
+// content converts one message; system messages are sent apart (nil)
+func (g *gemini) content(ctx context.Context, m Message) (*geminiContent, error) {
+	switch m.Role {
+	case RoleSystem:
+		return nil, nil
+	case RoleTool:
+		// The response must be an object; tool outputs that aren't are wrapped
+		response := json.RawMessage(m.Content)
+		if !json.Valid(response) || !strings.HasPrefix(strings.TrimSpace(m.Content), "{") {
+			response, _ = json.Marshal(map[string]string{"output": m.Content})
+		}
+		return &geminiContent{Role: "user", Parts: []geminiPart{{FunctionResponse: &geminiFunctionResponse{Name: m.ToolName, Response: response}}}}, nil
+	case RoleAssistant:
+		c := &geminiContent{Role: "model"}
+		if m.Content != "" {
+			c.Parts = append(c.Parts, geminiPart{Text: m.Content})
+		}
+		for _, tc := range m.ToolCalls {
+			c.Parts = append(c.Parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: tc.Name, Args: schemaOrEmpty(json.RawMessage(tc.Arguments))}})
+		}
+		return c, nil
+	}
+
+	c := &geminiContent{Role: "user"}
+	if m.Content != "" || len(m.Images) == 0 {
+		c.Parts = append(c.Parts, geminiPart{Text: m.Content})
+	}
+	for _, img := range m.Images {
+		// Gemini only reads uploaded files, so images are sent inline
+		mediaType, data, err := imageData(ctx, g.images, img)
+		if err != nil {
+			return nil, fmt.Errorf("llm: image %s: %w", img.URL, err)
+		}
+		c.Parts = append(c.Parts, geminiPart{InlineData: &geminiBlob{MimeType: mediaType, Data: data}})
+	}
+	return c, nil
+}
@@ -0,0 +1,19 @@
+package llm
+
+import "context"
+
+// Guard is checked before every call made with a context carrying it (see WithGuard).
+// An error aborts the call and is returned as is, e.g. once the caller's budget is spent.
+type Guard func(ctx context.Context) error
+
+type guardKey struct{}
+
+// WithGuard returns a context whose LLM calls are checked by g first
+func WithGuard(ctx context.Context, g Guard) context.Context {
+	return context.WithValue(ctx, guardKey{}, g)
+}
+
+func guardFrom(ctx context.Context) Guard {
+	g, _ := ctx.Value(guardKey{}).(Guard)
+	return g
+}
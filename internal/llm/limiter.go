@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+)
+
+// Limiter caps the outbound calls of every client built from the same config (see
+// LimiterFor): calls in flight, and requests and tokens per minute as token buckets, so
+// parallel jobs stay under the provider's organization rate limits instead of failing
+// on 429s. A call reserves its estimated tokens up front; the difference with the usage
+// it reports is settled when it returns. Zero limits are not enforced.
+type Limiter struct {
+	slots chan struct{} // nil = no concurrency cap
+
+	mu       sync.Mutex
+	requests *bucket
+	tokens   *bucket
+}
+
+var limitersByConfig sync.Map // *config.Config -> *Limiter
+
+// LimiterFor returns the limiter shared by the clients of cfg
+func LimiterFor(cfg *config.Config) *Limiter {
+	if l, ok := limitersByConfig.Load(cfg); ok {
+		return l.(*Limiter)
+	}
+	l, _ := limitersByConfig.LoadOrStore(cfg, newLimiter(cfg.LLM.MaxConcurrent, cfg.LLM.RequestsPerMinute, cfg.LLM.TokensPerMinute))
+	return l.(*Limiter)
+}
+
+func newLimiter(concurrent, requestsPerMinute, tokensPerMinute int) *Limiter {
+	l := &Limiter{requests: newBucket(requestsPerMinute), tokens: newBucket(tokensPerMinute)}
+	if concurrent > 0 {
+		l.slots = make(chan struct{}, concurrent)
+	}
+	return l
+}
+
+// acquire waits until a call of about tokens can be sent, or ctx is done. The returned
+// func must be called once the call returns, with the tokens it used (0 when unknown).
+func (l *Limiter) acquire(ctx context.Context, tokens int) (func(used int), error) {
+	if l.tokens != nil {
+		// A call larger than the whole bucket waits for it to be full rather than forever
+		tokens = min(tokens, int(l.tokens.capacity))
+	}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func(used int) {
+		if used > 0 {
+			l.mu.Lock()
+			l.tokens.settle(float64(used - tokens))
+			l.mu.Unlock()
+		}
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+	if err := l.reserve(ctx, tokens); err != nil {
+		release(0)
+		return nil, err
+	}
+	return release, nil
+}
+
+// reserve takes one request and tokens from the buckets, waiting for them to refill
+func (l *Limiter) reserve(ctx context.Context, tokens int) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		wait := max(l.requests.wait(now, 1), l.tokens.wait(now, float64(tokens)))
+		if wait == 0 {
+			l.requests.take(1)
+			l.tokens.take(float64(tokens))
+		}
+		l.mu.Unlock()
+		if wait == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// bucket holds up to a minute of capacity and refills continuously; nil = unlimited
+type bucket struct {
+	perSecond float64
+	capacity  float64
+	level     float64 // negative after calls used more than they reserved
+	at        time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{perSecond: float64(perMinute) / 60, capacity: float64(perMinute), level: float64(perMinute), at: time.Now()}
+}
+
+// wait returns how long until n can be taken, refilling first
+func (b *bucket) wait(now time.Time, n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.level = math.Min(b.capacity, b.level+now.Sub(b.at).Seconds()*b.perSecond)
+	b.at = now
+	if b.level >= n {
+		return 0
+	}
+	return time.Duration((n - b.level) / b.perSecond * float64(time.Second))
+}
+
+func (b *bucket) take(n float64) {
+	if b != nil {
+		b.level -= n
+	}
+}
+
+// settle corrects the level by the difference between the tokens used and reserved
+func (b *bucket) settle(delta float64) {
+	if b != nil {
+		b.level = math.Min(b.capacity, b.level-delta)
+	}
+}
@@ -0,0 +1,354 @@
+// Package llm is the provider-agnostic model client used by the agent, the pipeline
+// agents and the tools. The provider (OpenAI, Anthropic Claude, Google Gemini, or a
+// local OpenAI-compatible server) is selected by LLM_PROVIDER; every implementation goes through the shared retry policy.
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/retry"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Role of a message author
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool" // result of a tool call
+)
+
+// Finish reasons, normalized across providers
+const (
+	FinishStop      = "stop"
+	FinishToolCalls = "tool_calls"
+	FinishLength    = "length"
+)
+
+// ErrEmptyResponse is returned when the provider answered without any content
+var ErrEmptyResponse = errors.New("llm: empty response")
+
+// ErrVisionUnsupported is returned for requests with images when the model can't read them
+var ErrVisionUnsupported = errors.New("llm: vision not supported by the configured model")
+
+// Message is one turn of a conversation
+type Message struct {
+	Role       Role
+	Content    string
+	Images     []Image    // user messages
+	ToolCalls  []ToolCall // assistant messages requesting tools
+	ToolCallID string     // tool messages: the call answered
+	ToolName   string     // tool messages: the tool that answered (Gemini matches on name)
+}
+
+// Image attached to a user message: an http(s) or data: URL
+type Image struct {
+	URL    string
+	Detail string // low, high, auto; only OpenAI uses it
+}
+
+// Tool is a function the model may call; Parameters is a JSON schema
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+// ToolCall is a function call requested by the model; Arguments is a JSON object
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// Schema constrains structured output to a JSON schema
+type Schema struct {
+	Name   string
+	Schema json.RawMessage
+	Strict bool // the provider must match it exactly (OpenAI strict mode), see SchemaFor
+}
+
+// Request is a chat completion request
+type Request struct {
+	Model       string
+	Stage       Stage // call site: its configured parameters replace Temperature, MaxTokens and Seed
+	Messages    []Message
+	Temperature float32 // 0 = provider default
+	MaxTokens   int     // 0 = provider default
+	Seed        *int    // nil = random; ignored by Anthropic
+	JSON        bool    // answer with a single JSON object
+	Schema      *Schema // answer matching this schema (implies JSON)
+	Tools       []Tool
+}
+
+// Usage is the token count of a call
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Response is the first choice of a completion
+type Response struct {
+	Model        string // model that served the request, a fallback when the requested one failed
+	Content      string
+	ToolCalls    []ToolCall
+	FinishReason string
+	Usage        Usage
+}
+
+// Client talks to a model provider
+type Client interface {
+	// Chat runs a completion
+	Chat(ctx context.Context, req Request) (*Response, error)
+	// ChatWithVision runs a completion with images attached to the last user message
+	ChatWithVision(ctx context.Context, req Request, images ...Image) (*Response, error)
+	// Structured runs a completion in JSON mode (or against req.Schema) and decodes the
+	// answer into out. The response is returned with decode errors so callers can use the raw content.
+	Structured(ctx context.Context, req Request, out any) (*Response, error)
+}
+
+// provider is the single call each implementation provides
+type provider interface {
+	complete(ctx context.Context, req Request) (*Response, error)
+}
+
+// client adds the vision and structured helpers on top of a provider
+type client struct {
+	p         provider
+	vision    bool          // false = requests with images fail fast with ErrVisionUnsupported
+	fallbacks []string      // models tried in order when the requested one fails
+	timeout   time.Duration // per model attempt, retries included; 0 = none
+	secrets   []string      // configured credentials, redacted from the call log
+	stages    *Stages
+	limiter   *Limiter // shared by all clients, see LimiterFor
+	maxPrompt int      // estimated prompt tokens per call, longer prompts are truncated; 0 = no ceiling
+}
+
+// New returns the client of the configured provider (config.Load validated it)
+func New(cfg *config.Config) Client {
+	httpClient := &http.Client{Transport: &retry.Transport{Policy: retry.PolicyFromConfig(cfg)}}
+	c := &client{vision: true, fallbacks: cfg.LLM.Fallbacks, timeout: cfg.LLM.Timeout, secrets: secretsFrom(cfg), stages: StagesFor(cfg), limiter: LimiterFor(cfg), maxPrompt: cfg.LLM.MaxPromptTokens}
+	switch cfg.LLM.Provider {
+	case "anthropic":
+		c.p = newAnthropic(cfg, httpClient)
+	case "gemini":
+		c.p = newGemini(cfg, httpClient, imageproxy.New(cfg))
+	case "local":
+		c.p, c.vision = newLocal(cfg, httpClient), cfg.Local.Vision
+	default:
+		c.p = newOpenAI(cfg, httpClient)
+	}
+	return c
+}
+
+func (c *client) Chat(ctx context.Context, req Request) (*Response, error) {
+	return c.complete(ctx, req)
+}
+
+func (c *client) complete(ctx context.Context, req Request) (*Response, error) {
+	if !c.vision {
+		for _, m := range req.Messages {
+			if len(m.Images) > 0 {
+				return nil, ErrVisionUnsupported
+			}
+		}
+	}
+	c.stages.apply(ctx, &req)
+	fit(&req, c.maxPrompt)
+	if Simulated(ctx) {
+		return simulate(req), nil
+	}
+	if guard := guardFrom(ctx); guard != nil {
+		if err := guard(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// The requested model, then the fallback chain. The retry transport already retried
+	// each one; a failure here means the model is down or keeps timing out. Each attempt
+	// waits for the shared limiter first.
+	estimate := estimateUsage(req).TotalTokens
+	chain := []string{req.Model}
+	for _, m := range c.fallbacks {
+		if !slices.Contains(chain, m) {
+			chain = append(chain, m)
+		}
+	}
+	var err error
+	for i, model := range chain {
+		if i > 0 {
+			fmt.Printf("LLM: %s failed, falling back to %s: %v\n", chain[i-1], model, err)
+		}
+		req.Model = model
+		release, waitErr := c.limiter.acquire(ctx, estimate)
+		if waitErr != nil {
+			err = waitErr
+			break
+		}
+		started := time.Now()
+		resp, attemptErr := c.attempt(ctx, req)
+		release(usedTokens(resp))
+		c.record(ctx, req, resp, attemptErr, started)
+		if err = attemptErr; err == nil {
+			resp.Model = model
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+func usedTokens(resp *Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.Usage.TotalTokens
+}
+
+// attempt runs one model, bounded by the per-model timeout
+func (c *client) attempt(ctx context.Context, req Request) (*Response, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return c.p.complete(ctx, req)
+}
+
+func (c *client) ChatWithVision(ctx context.Context, req Request, images ...Image) (*Response, error) {
+	msgs := append([]Message(nil), req.Messages...)
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == RoleUser {
+			msgs[i].Images = append(append([]Image(nil), msgs[i].Images...), images...)
+			break
+		}
+	}
+	req.Messages = msgs
+	return c.complete(ctx, req)
+}
+
+func (c *client) Structured(ctx context.Context, req Request, out any) (*Response, error) {
+	req.JSON = true
+	resp, err := c.complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(extractJSON(resp.Content)), out); err != nil {
+		return resp, fmt.Errorf("llm: decode structured output: %w", err)
+	}
+	return resp, nil
+}
+
+// extractJSON strips a markdown code fence some models wrap JSON answers in
+func extractJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```json")
+	s = strings.TrimPrefix(s, "```")
+	return strings.TrimSpace(strings.TrimSuffix(s, "```"))
+}
+
+// systemPrompt joins the system messages, which Anthropic and Gemini take apart
+func systemPrompt(msgs []Message) string {
+	var parts []string
+	for _, m := range msgs {
+		if m.Role == RoleSystem {
+			parts = append(parts, m.Content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// jsonInstruction is appended to the system prompt of providers without a JSON mode flag
+const jsonInstruction = "Respond with a single valid JSON object and nothing else."
+
+// imageData returns the media type and base64 data of an image, decoding data: URLs and
+// downloading http(s) ones through the image proxy (private hosts refused, results cached)
+// for providers that don't fetch URLs themselves
+func imageData(ctx context.Context, images *imageproxy.Proxy, img Image) (mediaType, data string, err error) {
+	if rest, ok := strings.CutPrefix(img.URL, "data:"); ok {
+		meta, payload, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return "", "", fmt.Errorf("llm: unsupported data URL")
+		}
+		return strings.TrimSuffix(meta, ";base64"), payload, nil
+	}
+
+	fetched, err := images.Get(ctx, img.URL, 0, 0)
+	if err != nil {
+		return "", "", err
+	}
+	return fetched.ContentType, base64.StdEncoding.EncodeToString(fetched.Data), nil
+}
+
+// postJSON sends body to url and decodes a 2xx answer into out; other statuses
+// become errors carrying the provider's message
+func postJSON(ctx context.Context, hc *http.Client, url string, headers map[string]string, body, out any) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(raw)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// APIError is a non-2xx answer from a provider
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("llm: status %d: %s", e.StatusCode, e.Body)
+}
+
+// StatusCode returns the HTTP status of a provider's error answer, whichever the provider,
+// 0 when err isn't one
+func StatusCode(err error) int {
+	var apiErr *APIError
+	var openaiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.StatusCode
+	case errors.As(err, &openaiErr):
+		return openaiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		return requestErr.HTTPStatusCode
+	}
+	return 0
+}
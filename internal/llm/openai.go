@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// openAI implements the provider with go-openai (also OpenAI-compatible gateways)
+type openAI struct {
+	client *openai.Client
+}
+
+func newOpenAI(cfg *config.Config, httpClient *http.Client) *openAI {
+	clientConfig := openai.DefaultConfig(cfg.OpenAI.APIKey)
+	clientConfig.HTTPClient = &http.Client{Transport: &keyTransport{next: httpClient.Transport}}
+	if cfg.OpenAI.APIType == "azure" {
+		clientConfig = azureConfig(cfg)
+		clientConfig.HTTPClient = httpClient
+	} else if cfg.OpenAI.BaseURL != "" {
+		clientConfig.BaseURL = cfg.OpenAI.BaseURL
+	}
+	return &openAI{client: openai.NewClientWithConfig(clientConfig)}
+}
+
+type apiKeyKey struct{}
+
+// WithAPIKey returns a context whose OpenAI calls (batches included) authenticate with
+// key instead of OPENAI_API_KEY, e.g. the key of the organization a run is for. Azure
+// and the other providers ignore it.
+func WithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// keyTransport swaps the configured key for the one of the request's context, if any
+type keyTransport struct {
+	next http.RoundTripper
+}
+
+func (t *keyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if key, _ := req.Context().Value(apiKeyKey{}).(string); key != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// newLocal targets a local OpenAI-compatible server (Ollama, vLLM); the key is
+// optional since most of them don't check it
+func newLocal(cfg *config.Config, httpClient *http.Client) *openAI {
+	clientConfig := openai.DefaultConfig(cfg.Local.APIKey)
+	clientConfig.BaseURL = cfg.Local.BaseURL
+	clientConfig.HTTPClient = httpClient
+	return &openAI{client: openai.NewClientWithConfig(clientConfig)}
+}
+
+// azureConfig targets an Azure OpenAI resource: requests go to the deployment of the
+// model, configured in OPENAI_DEPLOYMENTS or named after the model as Azure suggests
+func azureConfig(cfg *config.Config) openai.ClientConfig {
+	clientConfig := openai.DefaultAzureConfig(cfg.OpenAI.APIKey, cfg.OpenAI.BaseURL)
+	clientConfig.APIVersion = cfg.OpenAI.APIVersion
+	defaultDeployment := clientConfig.AzureModelMapperFunc
+	deployments := cfg.OpenAI.Deployments
+	clientConfig.AzureModelMapperFunc = func(model string) string {
+		if d, ok := deployments[model]; ok {
+			return d
+		}
+		return defaultDeployment(model)
+	}
+	return clientConfig
+}
+
+func (o *openAI) complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := o.client.CreateChatCompletion(ctx, openAIRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return openAIResponse(resp)
+}
+
+// openAIRequest converts a request to the chat completions API (also the body of a batch line)
+func openAIRequest(req Request) openai.ChatCompletionRequest {
+	creq := openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Seed:        req.Seed,
+	}
+	for _, m := range req.Messages {
+		creq.Messages = append(creq.Messages, openAIMessage(m))
+	}
+	switch {
+	case req.Schema != nil:
+		creq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   req.Schema.Name,
+				Schema: req.Schema.Schema,
+				Strict: req.Schema.Strict,
+			},
+		}
+	case req.JSON:
+		creq.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	for _, t := range req.Tools {
+		creq.Tools = append(creq.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return creq
+}
+
+// openAIResponse converts the first choice of a chat completion
+func openAIResponse(resp openai.ChatCompletionResponse) (*Response, error) {
+	if len(resp.Choices) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	choice := resp.Choices[0]
+	out := &Response{
+		Content:      choice.Message.Content,
+		FinishReason: string(choice.FinishReason),
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}
+	for _, tc := range choice.Message.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+	}
+	return out, nil
+}
+
+func openAIMessage(m Message) openai.ChatCompletionMessage {
+	msg := openai.ChatCompletionMessage{Role: string(m.Role), ToolCallID: m.ToolCallID}
+	if len(m.Images) == 0 {
+		msg.Content = m.Content
+	} else {
+		msg.MultiContent = []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: m.Content}}
+		for _, img := range m.Images {
+			msg.MultiContent = append(msg.MultiContent, openai.ChatMessagePart{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: img.URL, Detail: openai.ImageURLDetail(img.Detail)},
+			})
+		}
+	}
+	for _, tc := range m.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+			ID:       tc.ID,
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: tc.Name, Arguments: tc.Arguments},
+		})
+	}
+	return msg
+}
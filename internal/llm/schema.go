@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaFor derives a strict JSON schema from the Go type of v, so the model's answer
+// is guaranteed to decode into it: every field is required and no other property is
+// allowed (what OpenAI's strict mode demands). Fields follow their json tags; pointers
+// become nullable. Maps and interfaces have no strict equivalent and panic, so a bad
+// output type fails at startup rather than on the first call.
+func SchemaFor(name string, v any) *Schema {
+	raw, err := json.Marshal(typeSchema(reflect.TypeOf(v)))
+	if err != nil {
+		panic("llm: schema for " + name + ": " + err.Error())
+	}
+	return &Schema{Name: name, Schema: raw, Strict: true}
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		panic("llm: no strict schema for json.RawMessage")
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := typeSchema(t.Elem())
+		s["type"] = []any{s["type"], "null"}
+		return s
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Name
+			if tag := f.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				if n, _, _ := strings.Cut(tag, ","); n != "" {
+					name = n
+				}
+			}
+			props[name] = typeSchema(f.Type)
+			required = append(required, name)
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	panic("llm: no strict schema for " + t.String())
+}
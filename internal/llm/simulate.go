@@ -0,0 +1,84 @@
+package llm
+
+import "context"
+
+// Token estimates of simulated calls. Text is counted at about four characters per token
+// (English and French prompts, JSON); images at one 512px tile, what vision calls send
+// once downscaled (AGENT_VISION_MAX_SIZE), or the flat low detail cost.
+const (
+	charsPerToken    = 4
+	imageTokens      = 255
+	imageTokensLow   = 85
+	defaultAnswerLen = 500
+)
+
+// typicalAnswers is the usual answer length of a stage, in tokens, capped by its max tokens
+var typicalAnswers = map[Stage]int{
+	StageFastMode:    900,
+	StageFocusedMode: 600,
+	StageVision:      350,
+	StageJudge:       200,
+}
+
+type simulationKey struct{}
+
+// WithSimulation returns a context whose LLM calls are not sent: each returns an empty
+// JSON answer with an estimated usage, so a run can be priced for free. Callers must not
+// keep what such a run produces.
+func WithSimulation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, simulationKey{}, true)
+}
+
+// Simulated reports whether the LLM calls made with ctx are simulated
+func Simulated(ctx context.Context) bool {
+	v, _ := ctx.Value(simulationKey{}).(bool)
+	return v
+}
+
+// simulate answers a request without sending it
+func simulate(req Request) *Response {
+	usage := estimateUsage(req)
+	return &Response{
+		Model:        req.Model,
+		Content:      "{}",
+		FinishReason: "stop",
+		Usage:        usage,
+	}
+}
+
+// EstimateTokens estimates the tokens of a text the way simulated calls count them
+func EstimateTokens(text string) int {
+	return len(text) / charsPerToken
+}
+
+// estimateUsage predicts the usage of a request from its size and stage
+func estimateUsage(req Request) Usage {
+	chars := 0
+	prompt := 0
+	for _, m := range req.Messages {
+		chars += len(m.Content)
+		for _, img := range m.Images {
+			if img.Detail == "low" {
+				prompt += imageTokensLow
+			} else {
+				prompt += imageTokens
+			}
+		}
+	}
+	if req.Schema != nil {
+		chars += len(req.Schema.Schema)
+	}
+	for _, t := range req.Tools {
+		chars += len(t.Name) + len(t.Description) + len(t.Parameters)
+	}
+	prompt += chars / charsPerToken
+
+	answer, ok := typicalAnswers[req.Stage]
+	if !ok {
+		answer = defaultAnswerLen
+	}
+	if req.MaxTokens > 0 && req.MaxTokens < answer {
+		answer = req.MaxTokens
+	}
+	return Usage{PromptTokens: prompt, CompletionTokens: answer, TotalTokens: prompt + answer}
+}
@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/models"
+)
+
+// Stage names a call site whose generation parameters can be tuned
+type Stage string
+
+const (
+	StageFastMode           Stage = "fast_mode"            // agent, fast mode optimization
+	StageFocusedMode        Stage = "focused_mode"         // agent, one optimization group
+	StageAgentStep          Stage = "agent_step"           // agent, tool-calling step
+	StageVision             Stage = "vision"               // agent, image analysis
+	StageFastPipeline       Stage = "fast_pipeline"        // fast pipeline optimization
+	StageFastPipelineVision Stage = "fast_pipeline_vision" // fast pipeline image description
+	StageJudge              Stage = "judge"                // LLM judge of proposal quality
+	StageAuditor            Stage = "auditor"
+	StagePlanner            Stage = "planner"
+	StageWriter             Stage = "writer"
+	StageController         Stage = "controller"
+	StageEvidence           Stage = "evidence"
+	StageRetrieval          Stage = "retrieval"
+	StageAnalyzeProduct     Stage = "analyze_product" // tools
+	StageAnalyzeImage       Stage = "analyze_image"
+	StageOptimizeField      Stage = "optimize_field"
+	StageValidateProposal   Stage = "validate_proposal"
+)
+
+// AllStages lists every stage, in display order
+var AllStages = []Stage{
+	StageFastMode, StageFocusedMode, StageAgentStep, StageVision,
+	StageFastPipeline, StageFastPipelineVision, StageJudge,
+	StageAuditor, StagePlanner, StageWriter, StageController, StageEvidence, StageRetrieval,
+	StageAnalyzeProduct, StageAnalyzeImage, StageOptimizeField, StageValidateProposal,
+}
+
+// KnownStage reports whether s names a stage
+func KnownStage(s string) bool {
+	for _, st := range AllStages {
+		if string(st) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Params are the generation parameters of a stage; nil fields keep the provider default
+type Params struct {
+	Temperature *float32 `json:"temperature"`
+	MaxTokens   *int     `json:"max_tokens"`
+	Seed        *int     `json:"seed"` // reproducible sampling where supported (OpenAI, Gemini)
+}
+
+// merge returns p with the fields set in o replaced
+func (p Params) merge(o Params) Params {
+	if o.Temperature != nil {
+		p.Temperature = o.Temperature
+	}
+	if o.MaxTokens != nil {
+		p.MaxTokens = o.MaxTokens
+	}
+	if o.Seed != nil {
+		p.Seed = o.Seed
+	}
+	return p
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// builtinParams are the values the call sites used before they were configurable
+var builtinParams = map[Stage]Params{
+	StageFastMode:           {Temperature: ptr[float32](0.3)},
+	StageFocusedMode:        {Temperature: ptr[float32](0.3)},
+	StageVision:             {Temperature: ptr[float32](0.1), MaxTokens: ptr(500)},
+	StageFastPipeline:       {Temperature: ptr[float32](0.3)},
+	StageFastPipelineVision: {Temperature: ptr[float32](0.1), MaxTokens: ptr(300)},
+}
+
+// StageSource provides the stage parameters set through the admin API (the stage_params table)
+type StageSource interface {
+	ListStageParams(ctx context.Context) ([]models.StageParams, error)
+}
+
+// stagesTTL is how long loaded overrides are used before being read again
+const stagesTTL = 5 * time.Minute
+
+// Stages resolves the parameters of each stage: built-in defaults, then LLM_STAGE_*, then
+// the overrides of its source, cached for stagesTTL. Every client built from the same
+// config shares one (see StagesFor), so a change reaches them all.
+type Stages struct {
+	defaults map[Stage]Params
+
+	mu        sync.Mutex
+	source    StageSource
+	overrides map[Stage]Params
+	loadedAt  time.Time
+}
+
+var stagesByConfig sync.Map // *config.Config -> *Stages
+
+// StagesFor returns the stage parameters shared by the clients of cfg
+func StagesFor(cfg *config.Config) *Stages {
+	if s, ok := stagesByConfig.Load(cfg); ok {
+		return s.(*Stages)
+	}
+	s, _ := stagesByConfig.LoadOrStore(cfg, newStages(cfg))
+	return s.(*Stages)
+}
+
+func newStages(cfg *config.Config) *Stages {
+	s := &Stages{defaults: map[Stage]Params{}}
+	for _, st := range AllStages {
+		p := builtinParams[st]
+		if t, ok := cfg.LLM.StageTemperatures[string(st)]; ok {
+			p.Temperature = &t
+		}
+		if n, ok := cfg.LLM.StageMaxTokens[string(st)]; ok {
+			p.MaxTokens = &n
+		}
+		if seed, ok := cfg.LLM.StageSeeds[string(st)]; ok {
+			p.Seed = &seed
+		}
+		s.defaults[st] = p
+	}
+	return s
+}
+
+// SetSource makes the stages use the overrides of src on top of their defaults
+func (s *Stages) SetSource(src StageSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.source = src
+	s.loadedAt = time.Time{}
+}
+
+// Reload makes the next call read the overrides again (after they were edited)
+func (s *Stages) Reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Time{}
+}
+
+// Defaults returns the parameters of a stage before overrides (built-in and LLM_STAGE_*)
+func (s *Stages) Defaults(stage Stage) Params {
+	return s.defaults[stage]
+}
+
+// Get returns the parameters a stage runs with
+func (s *Stages) Get(ctx context.Context, stage Stage) Params {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.source != nil && time.Since(s.loadedAt) > stagesTTL {
+		s.load(ctx)
+	}
+	return s.defaults[stage].merge(s.overrides[stage])
+}
+
+// load reads the overrides; on failure the previous ones are kept and retried after the TTL.
+// s.mu must be held.
+func (s *Stages) load(ctx context.Context) {
+	s.loadedAt = time.Now()
+	rows, err := s.source.ListStageParams(ctx)
+	if err != nil {
+		fmt.Printf("Stage params: load failed, keeping previous: %v\n", err)
+		return
+	}
+	overrides := make(map[Stage]Params, len(rows))
+	for _, r := range rows {
+		overrides[Stage(r.Stage)] = Params{Temperature: r.Temperature, MaxTokens: r.MaxTokens, Seed: r.Seed}
+	}
+	s.overrides = overrides
+}
+
+// apply sets the parameters of req.Stage on req; requests without a stage are left as is
+func (s *Stages) apply(ctx context.Context, req *Request) {
+	if req.Stage == "" {
+		return
+	}
+	p := s.Get(ctx, req.Stage)
+	if p.Temperature != nil {
+		req.Temperature = *p.Temperature
+		if req.Temperature == 0 {
+			// 0 means provider default in a Request: send the smallest positive value instead
+			req.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	if p.MaxTokens != nil {
+		req.MaxTokens = *p.MaxTokens
+	}
+	if p.Seed != nil {
+		req.Seed = p.Seed
+	}
+}
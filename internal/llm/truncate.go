@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+const truncatedMarker = "\n[…truncated to fit the prompt limit]"
+
+// fit enforces the per-call prompt ceiling (LLM_MAX_PROMPT_TOKENS): while the request's
+// estimated prompt is above maxTokens, the longest user or tool message is cut from its
+// end. System prompts, tool definitions, schemas and images are never cut, so the same
+// request is always truncated the same way. Returns whether anything was cut.
+func fit(req *Request, maxTokens int) bool {
+	if maxTokens <= 0 {
+		return false
+	}
+	before := estimateUsage(*req).PromptTokens
+	if before <= maxTokens {
+		return false
+	}
+
+	// The caller's messages are not ours to modify
+	req.Messages = slices.Clone(req.Messages)
+	for est := before; est > maxTokens; est = estimateUsage(*req).PromptTokens {
+		i := longestCuttable(req.Messages)
+		if i < 0 {
+			break
+		}
+		m := &req.Messages[i]
+		keep := len(m.Content) - (est-maxTokens)*charsPerToken - len(truncatedMarker)
+		m.Content = cutBytes(m.Content, max(keep, 0)) + truncatedMarker
+	}
+	fmt.Printf("LLM: %s prompt truncated from ~%d to ~%d tokens (limit %d)\n",
+		req.Stage, before, estimateUsage(*req).PromptTokens, maxTokens)
+	return true
+}
+
+// longestCuttable returns the index of the longest user or tool message that can still
+// be shortened, -1 when none can
+func longestCuttable(messages []Message) int {
+	best := -1
+	for i, m := range messages {
+		if m.Role != RoleUser && m.Role != RoleTool {
+			continue
+		}
+		if len(m.Content) <= len(truncatedMarker) {
+			continue
+		}
+		if best < 0 || len(m.Content) > len(messages[best].Content) {
+			best = i
+		}
+	}
+	return best
+}
+
+// cutBytes cuts s to at most n bytes without splitting a character
+func cutBytes(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -10,6 +10,7 @@ import (
 // Dataset represents an imported TSV/CSV file
 type Dataset struct {
 	ID            uuid.UUID       `json:"id" db:"id"`
+	OrgID         uuid.UUID       `json:"org_id" db:"org_id"`
 	Name          string          `json:"name" db:"name"`
 	SourceFileURL string          `json:"source_file_url" db:"source_file_url"`
 	RowCount      int             `json:"row_count" db:"row_count"`
@@ -28,6 +29,9 @@ type Product struct {
 	Version             int             `json:"version" db:"version"`
 	Status              string          `json:"status" db:"status"` // pending, processing, enriched, needs_review
 	AgentReadinessScore *float64        `json:"agent_readiness_score" db:"agent_readiness_score"`
+	BaselineScore       *float64        `json:"baseline_score" db:"baseline_score"` // rule compliance of the feed data at import, 0-1
+	Tags                []string        `json:"tags" db:"tags"`                     // free-form labels, e.g. "hero SKU", "summer-2025"
+	Notes               string          `json:"notes" db:"notes"`                   // free-form notes, e.g. "data owner: Julie"
 	CreatedAt           time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt           time.Time       `json:"updated_at" db:"updated_at"`
 }
@@ -40,8 +44,66 @@ type AgentSession struct {
 	Status      string     `json:"status" db:"status"` // running, completed, failed, paused
 	TotalSteps  int        `json:"total_steps" db:"total_steps"`
 	TokensUsed  int        `json:"tokens_used" db:"tokens_used"`
+	CostUSD     float64    `json:"cost_usd" db:"cost_usd"`
+	Engine      string     `json:"engine" db:"engine"` // agent, pipeline, fast_pipeline, tools
 	StartedAt   time.Time  `json:"started_at" db:"started_at"`
 	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+	Thresholds  *Thresholds `json:"thresholds,omitempty" db:"thresholds"`
+	Checkpoint  *SessionCheckpoint `json:"checkpoint,omitempty" db:"checkpoint"` // set while paused
+}
+
+// SessionCheckpoint is the state of a paused tools session that its traces don't carry
+type SessionCheckpoint struct {
+	Proposals      []Proposal `json:"proposals"` // made before the pause, already saved
+	Sources        []Source   `json:"sources"`
+	AwaitingReview *uuid.UUID `json:"awaiting_review,omitempty"` // human review the run waits for
+	PausedAt       time.Time  `json:"paused_at"`
+}
+
+// HumanReview is an item the agent escalated to a human instead of proposing a change
+type HumanReview struct {
+	ID         uuid.UUID       `json:"id" db:"id"`
+	DatasetID  uuid.UUID       `json:"dataset_id" db:"dataset_id"`
+	ProductID  uuid.UUID       `json:"product_id" db:"product_id"`
+	SessionID  *uuid.UUID      `json:"session_id,omitempty" db:"session_id"`
+	Source     string          `json:"source" db:"source"` // tool, issue, pipeline, contradiction
+	Field      string          `json:"field,omitempty" db:"field"`
+	Question   string          `json:"question" db:"question"`
+	RiskLevel  string          `json:"risk_level,omitempty" db:"risk_level"`
+	Context    json.RawMessage `json:"context,omitempty" db:"context"`
+	Options    []string        `json:"options,omitempty" db:"options"`
+	Status     string          `json:"status" db:"status"` // pending, resolved, dismissed
+	Resolution string          `json:"resolution,omitempty" db:"resolution"`
+	ResolvedBy string          `json:"resolved_by,omitempty" db:"resolved_by"`
+	ResolvedAt *time.Time      `json:"resolved_at,omitempty" db:"resolved_at"`
+	Assignee    string         `json:"assignee,omitempty" db:"assignee"`
+	AssignedAt  *time.Time     `json:"assigned_at,omitempty" db:"assigned_at"`
+	ReviewState string         `json:"review_state" db:"review_state"` // see ReviewState*
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+
+	ProductExternalID string `json:"product_external_id,omitempty"`
+	ProductTitle      string `json:"product_title,omitempty"`
+}
+
+// Review states shared by proposals and human reviews. Approved and rejected follow the
+// decision; the others are set by reviewers while they work on an item.
+const (
+	ReviewStatePending   = "pending"
+	ReviewStateInReview  = "in_review"
+	ReviewStateNeedsInfo = "needs_info"
+	ReviewStateApproved  = "approved"
+	ReviewStateRejected  = "rejected"
+)
+
+// ReviewComment is a reviewer note on a proposal or a human review
+type ReviewComment struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	ProposalID    *uuid.UUID `json:"proposal_id,omitempty" db:"proposal_id"`
+	HumanReviewID *uuid.UUID `json:"human_review_id,omitempty" db:"human_review_id"`
+	Author        string     `json:"author" db:"author"`
+	Body          string     `json:"body" db:"body"`
+	ReviewState   string     `json:"review_state,omitempty" db:"review_state"` // state set with the comment
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
 }
 
 // AgentTrace represents a single step in the agent's reasoning
@@ -55,9 +117,75 @@ type AgentTrace struct {
 	ToolOutput json.RawMessage `json:"tool_output" db:"tool_output"`
 	TokensUsed int             `json:"tokens_used" db:"tokens_used"`
 	DurationMs int             `json:"duration_ms" db:"duration_ms"`
+	Retries    int             `json:"retries" db:"retries"` // outbound calls retried during this step
+	Model      string          `json:"model,omitempty" db:"model"` // model that served the step's call
 	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
 }
 
+// LLMCall is a model call of a session kept for debugging (LLM_CALL_LOG), secrets redacted
+type LLMCall struct {
+	ID               uuid.UUID       `json:"id" db:"id"`
+	SessionID        uuid.UUID       `json:"session_id" db:"session_id"`
+	Model            string          `json:"model" db:"model"`
+	Request          json.RawMessage `json:"request" db:"request"`
+	Response         string          `json:"response,omitempty" db:"response"`
+	Error            string          `json:"error,omitempty" db:"error"`
+	LatencyMs        int             `json:"latency_ms" db:"latency_ms"`
+	PromptTokens     int             `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens" db:"completion_tokens"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+}
+
+// PipelineRun is the audit trail of a pipeline or fast_pipeline session: what each stage
+// returned, the changes the pipeline rejected and the evidence it collected
+type PipelineRun struct {
+	ID            uuid.UUID           `json:"id" db:"id"`
+	SessionID     uuid.UUID           `json:"session_id" db:"session_id"`
+	ProductID     uuid.UUID           `json:"product_id" db:"product_id"`
+	Engine        string              `json:"engine" db:"engine"`
+	Vertical      string              `json:"vertical" db:"vertical"` // apparel, electronics, home, beauty, generic
+	StartedAt     time.Time           `json:"started_at" db:"started_at"`
+	CompletedAt   time.Time           `json:"completed_at" db:"completed_at"`
+	Summary       json.RawMessage     `json:"summary,omitempty" db:"summary"`
+	EvidenceTrail json.RawMessage     `json:"evidence_trail,omitempty" db:"evidence_trail"`
+	Stages        []PipelineStage     `json:"stages"`
+	Rejections    []PipelineRejection `json:"rejections"`
+	RetrievedFacts []RetrievedFact    `json:"retrieved_facts"`
+	CreatedAt     time.Time           `json:"created_at" db:"created_at"`
+}
+
+// PipelineStage is one stage of a pipeline run
+type PipelineStage struct {
+	Stage      string          `json:"stage" db:"stage"`
+	StartedAt  time.Time       `json:"started_at" db:"started_at"`
+	EndedAt    time.Time       `json:"ended_at" db:"ended_at"`
+	DurationMs int64           `json:"duration_ms" db:"duration_ms"`
+	Retries    int             `json:"retries,omitempty" db:"retries"`
+	Output     json.RawMessage `json:"output,omitempty" db:"output"`
+	Error      string          `json:"error,omitempty" db:"error"`
+}
+
+// PipelineRejection is a change the pipeline refused, and the stage that refused it
+type PipelineRejection struct {
+	Field    string `json:"field" db:"field"`
+	Reason   string `json:"reason" db:"reason"`
+	Evidence string `json:"evidence,omitempty" db:"evidence"`
+	Stage    string `json:"stage" db:"stage"`
+}
+
+// RetrievedFact is a fact the retrieval stage of a pipeline run found, with the page and
+// snippet it was read from; ProposalID is the proposal it backs, nil when none used it
+type RetrievedFact struct {
+	ProposalID *uuid.UUID `json:"proposal_id,omitempty" db:"proposal_id"`
+	Field      string     `json:"field" db:"field"`
+	Value      string     `json:"value" db:"value"`
+	Source     string     `json:"source" db:"source"` // gtin_registry, product_page, structured_data, datasheet...
+	URL        string     `json:"url" db:"url"`
+	Evidence   string     `json:"evidence" db:"evidence"`
+	Confidence float64    `json:"confidence" db:"confidence"`
+	Trusted    bool       `json:"trusted,omitempty" db:"trusted"` // read on a trusted domain
+}
+
 // Proposal represents a suggested change to a product field
 type Proposal struct {
 	ID         uuid.UUID       `json:"id" db:"id"`
@@ -66,13 +194,23 @@ type Proposal struct {
 	Field      string          `json:"field" db:"field"`
 	BeforeValue *string        `json:"before_value" db:"before_value"`
 	AfterValue string          `json:"after_value" db:"after_value"`
+	EditedValue *string        `json:"edited_value,omitempty" db:"edited_value"` // reviewer's replacement for after_value
 	Rationale  []string        `json:"rationale" db:"rationale"`
 	Sources    json.RawMessage `json:"sources" db:"sources"`
 	Confidence float64         `json:"confidence" db:"confidence"`
 	RiskLevel  string          `json:"risk_level" db:"risk_level"` // low, medium, high
-	Status     string          `json:"status" db:"status"`         // proposed, accepted, rejected, edited
+	Status     string          `json:"status" db:"status"`         // proposed, accepted, rejected, edited, reverted, superseded
+	Module     string          `json:"module" db:"module"`         // optimization group of the run that proposed it
+	QualityScore     *int            `json:"quality_score" db:"quality_score"` // 0-100, nil until scored
+	QualityBreakdown json.RawMessage `json:"quality_breakdown,omitempty" db:"quality_breakdown"`
 	ReviewedBy *string         `json:"reviewed_by" db:"reviewed_by"`
 	ReviewedAt *time.Time      `json:"reviewed_at" db:"reviewed_at"`
+	AppliedAt  *time.Time      `json:"applied_at,omitempty" db:"applied_at"` // set while the value is in current_data
+	SupersededBy *uuid.UUID    `json:"superseded_by,omitempty" db:"superseded_by"` // accepted competing proposal
+	Assignee    *string        `json:"assignee,omitempty" db:"assignee"`
+	AssignedAt  *time.Time     `json:"assigned_at,omitempty" db:"assigned_at"`
+	ReviewState string         `json:"review_state" db:"review_state"` // see ReviewState*
+	GroupChangeID *uuid.UUID   `json:"group_change_id,omitempty" db:"group_change_id"` // change_log entry of a group accept
 	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
 }
 
@@ -82,12 +220,40 @@ type Source struct {
 	Reference  string  `json:"reference"`  // URL or field name
 	Evidence   string  `json:"evidence"`   // snippet or observation
 	Confidence float64 `json:"confidence"`
+	Verified   bool    `json:"verified,omitempty"` // confidence reached the auto-verify threshold
+}
+
+// Thresholds control which proposals a run keeps and which evidence it auto-verifies
+type Thresholds struct {
+	MinConfidence        float64 `json:"min_confidence"`         // proposals below are dropped
+	AutoVerifyConfidence float64 `json:"auto_verify_confidence"` // sources at or above are marked verified
+	RiskTolerance        string  `json:"risk_tolerance"`         // highest risk level kept: low, medium, high
+}
+
+// ThresholdOverrides are partial thresholds (dataset or request level); nil fields inherit
+type ThresholdOverrides struct {
+	MinConfidence        *float64 `json:"min_confidence,omitempty"`
+	AutoVerifyConfidence *float64 `json:"auto_verify_confidence,omitempty"`
+	RiskTolerance        *string  `json:"risk_tolerance,omitempty"`
+}
+
+// Budget caps LLM spend in USD; nil fields are unlimited
+type Budget struct {
+	DailyUSD   *float64 `json:"daily_usd,omitempty"`
+	MonthlyUSD *float64 `json:"monthly_usd,omitempty"`
+}
+
+// BudgetSpend is the LLM spend of the current day and month in USD
+type BudgetSpend struct {
+	TodayUSD float64 `json:"today_usd"`
+	MonthUSD float64 `json:"month_usd"`
 }
 
 // Rule represents a validation rule
 type Rule struct {
 	ID        uuid.UUID       `json:"id" db:"id"`
-	DatasetID *uuid.UUID      `json:"dataset_id" db:"dataset_id"` // nil = global
+	OrgID     uuid.UUID       `json:"org_id" db:"org_id"`
+	DatasetID *uuid.UUID      `json:"dataset_id" db:"dataset_id"` // nil = every dataset of the organization
 	Name      string          `json:"name" db:"name"`
 	Type      string          `json:"type" db:"type"` // hard, smart
 	Field     string          `json:"field" db:"field"`
@@ -104,7 +270,7 @@ type Job struct {
 	ID          uuid.UUID       `json:"id" db:"id"`
 	DatasetID   uuid.UUID       `json:"dataset_id" db:"dataset_id"`
 	Type        string          `json:"type" db:"type"` // enrich_all, enrich_batch, single_product
-	Status      string          `json:"status" db:"status"` // pending, running, completed, failed
+	Status      string          `json:"status" db:"status"` // pending, running, paused, completed, failed, cancelled
 	Progress    json.RawMessage `json:"progress" db:"progress"`
 	Config      json.RawMessage `json:"config" db:"config"`
 	Error       *string         `json:"error" db:"error"`
@@ -146,6 +312,15 @@ type Prompt struct {
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
+// StageParams overrides the generation parameters of an LLM stage; nil keeps the configured value
+type StageParams struct {
+	Stage       string    `json:"stage" db:"stage"`
+	Temperature *float32  `json:"temperature" db:"temperature"`
+	MaxTokens   *int      `json:"max_tokens" db:"max_tokens"`
+	Seed        *int      `json:"seed" db:"seed"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
 // TokenUsage tracks API token consumption and costs
 type TokenUsage struct {
 	ID               uuid.UUID `json:"id" db:"id"`
@@ -160,6 +335,17 @@ type TokenUsage struct {
 	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// ModelPrice is a model's token price from a date on (USD per 1M tokens)
+type ModelPrice struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	Model            string    `json:"model" db:"model"`
+	InputPerMillion  float64   `json:"input_per_million" db:"input_per_million"`
+	OutputPerMillion float64   `json:"output_per_million" db:"output_per_million"`
+	EffectiveFrom    time.Time `json:"effective_from" db:"effective_from"`
+	Note             string    `json:"note,omitempty" db:"note"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
 // TokenUsageStats aggregated statistics
 type TokenUsageStats struct {
 	TotalPromptTokens     int     `json:"total_prompt_tokens"`
@@ -169,6 +355,30 @@ type TokenUsageStats struct {
 	TotalAPICalls         int     `json:"total_api_calls"`
 	ByModel               []TokenUsage `json:"by_model,omitempty"`
 	ByDay                 []TokenUsage `json:"by_day,omitempty"`
+	GroupBy               string            `json:"group_by,omitempty"` // dataset, product or module
+	Groups                []TokenUsageGroup `json:"groups,omitempty"`
+}
+
+// TokenAttribution is who a call's token usage is attributed to
+type TokenAttribution struct {
+	SessionID *uuid.UUID // nil outside a session
+	DatasetID uuid.UUID
+	ProductID *uuid.UUID
+	Module    string // optimization group of the run
+	Model     string
+}
+
+// TokenUsageGroup is the usage of a dataset, product or module (see TokenUsageStats)
+type TokenUsageGroup struct {
+	Key              string     `json:"key"`                  // dataset or product ID, or module
+	Label            string     `json:"label"`                // dataset name, product external ID, or module
+	DatasetID        *uuid.UUID `json:"dataset_id,omitempty"` // product groups
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+	TotalTokens      int        `json:"total_tokens"`
+	CostUSD          float64    `json:"cost_usd"`
+	APICalls         int        `json:"api_calls"`
+	Sessions         int        `json:"sessions"`
 }
 
 // AnalysisResult from analyze_product tool
@@ -196,6 +406,17 @@ type AnalysisResult struct {
 	} `json:"improvement_opportunities"`
 }
 
+// DatasetScoreStats aggregates the rule compliance scores of a dataset's products: before on
+// the feed data as imported, after with the applied changes. The distributions count the
+// products per 0.1-wide score range, lowest first.
+type DatasetScoreStats struct {
+	Scored             int     `json:"scored"`
+	Before             float64 `json:"before"`
+	After              float64 `json:"after"`
+	BeforeDistribution []int   `json:"before_distribution"`
+	AfterDistribution  []int   `json:"after_distribution"`
+}
+
 // ===== DATA FEEDS MODELS =====
 
 // DatasetVersion represents an import version of a dataset
@@ -235,7 +456,7 @@ type ChangeLogEntry struct {
 	ID        uuid.UUID  `json:"id" db:"id"`
 	DatasetID *uuid.UUID `json:"dataset_id" db:"dataset_id"`
 	ProductID *uuid.UUID `json:"product_id" db:"product_id"`
-	Action    string     `json:"action" db:"action"` // import, proposal_accepted, proposal_rejected, manual_edit, export, restore
+	Action    string     `json:"action" db:"action"` // import, proposal_accepted, proposal_edited, proposal_rejected, proposal_reverted, proposal_group_accepted, proposal_sample_approved, manual_edit, export, restore
 	Field     string     `json:"field" db:"field"`
 	OldValue  string     `json:"old_value" db:"old_value"`
 	NewValue  string     `json:"new_value" db:"new_value"`
@@ -248,7 +469,8 @@ type ChangeLogEntry struct {
 // ApprovalRule defines auto-approval/rejection criteria
 type ApprovalRule struct {
 	ID            uuid.UUID  `json:"id" db:"id"`
-	DatasetID     *uuid.UUID `json:"dataset_id" db:"dataset_id"` // nil = global
+	OrgID         uuid.UUID  `json:"org_id" db:"org_id"`
+	DatasetID     *uuid.UUID `json:"dataset_id" db:"dataset_id"` // nil = every dataset of the organization
 	Name          string     `json:"name" db:"name"`
 	Field         string     `json:"field" db:"field"`   // empty = all fields
 	Module        string     `json:"module" db:"module"` // empty = all modules
@@ -273,23 +495,259 @@ type JobLog struct {
 type JobWithDetails struct {
 	Job
 	Module             string    `json:"module" db:"module"`
+	Priority           int       `json:"priority" db:"priority"` // 1 (lowest) - 10 (highest)
 	TotalItems         int       `json:"total_items" db:"total_items"`
 	ProcessedItems     int       `json:"processed_items" db:"processed_items"`
 	ProposalsGenerated int       `json:"proposals_generated" db:"proposals_generated"`
 	Logs               []JobLog  `json:"logs"`
+	CheckpointProductID *uuid.UUID `json:"checkpoint_product_id,omitempty" db:"checkpoint_product_id"`
 	UpdatedAt          *time.Time `json:"updated_at" db:"updated_at"`
+	Queue              *QueueInfo `json:"queue,omitempty"` // set while the job is queued in this process
+	Report             *JobReport `json:"report,omitempty"` // set when the job completes or stops
+}
+
+// RunUsage is the token usage and cost of a set of LLM calls
+type RunUsage struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+
+	// Vision: calls made, analyses reused instead of a new call, and the tokens
+	// (and cost) saved by reuse and by downscaling images before sending them
+	VisionCalls       int     `json:"vision_calls,omitempty"`
+	VisionReused      int     `json:"vision_reused,omitempty"`
+	VisionTokensSaved int     `json:"vision_tokens_saved,omitempty"`
+	VisionSavedUSD    float64 `json:"vision_saved_usd,omitempty"`
+}
+
+// JobReport summarizes what a job run did and what it cost
+type JobReport struct {
+	Products  int        `json:"products"` // products processed by this run
+	Proposals int        `json:"proposals"`
+	Errors    int        `json:"errors"`
+	Usage     RunUsage `json:"usage"`
+	DryRun    bool     `json:"dry_run"`
+
+	// Dry runs only: what enriching the whole scope would produce and cost
+	ProposalsByField map[string]int `json:"proposals_by_field,omitempty"`
+	ProposalsByRisk  map[string]int `json:"proposals_by_risk,omitempty"`
+	Projection       *JobProjection `json:"projection,omitempty"`
+
+	// Image audits only: the scorecard of the images of every product audited so far
+	Images *ImageScorecard `json:"images,omitempty"`
+}
+
+// JobProjection extrapolates a dry run sample to every product in scope
+type JobProjection struct {
+	Products  int     `json:"products"`
+	Proposals int     `json:"proposals"`
+	Tokens    int     `json:"tokens"`
+	CostUSD   float64 `json:"cost_usd"`
+}
+
+// CostEstimate projects what enriching a scope would cost, from a simulated sample
+type CostEstimate struct {
+	ScopeProducts   int             `json:"scope_products"`
+	SampleSize      int             `json:"sample_size"`
+	Groups          []GroupEstimate `json:"groups"`
+	Calls           int             `json:"calls"`
+	Tokens          int             `json:"tokens"`
+	CostUSD         float64         `json:"cost_usd"`
+	DurationSeconds float64         `json:"duration_seconds"` // on JOB_WORKERS in parallel
+	DurationBasis   string          `json:"duration_basis"`   // observed (recent jobs) or assumed (none ran yet)
+}
+
+// GroupEstimate is the projection of one optimization group over the scope
+type GroupEstimate struct {
+	Group             string  `json:"group"`
+	Calls             int     `json:"calls"`
+	PromptTokens      int     `json:"prompt_tokens"`
+	CompletionTokens  int     `json:"completion_tokens"`
+	CostUSD           float64 `json:"cost_usd"`
+	CostPerProductUSD float64 `json:"cost_per_product_usd"`
+	Errors            int     `json:"errors,omitempty"` // sample products whose simulated run failed
+}
+
+// QueueInfo describes a job's position in the scheduler
+type QueueInfo struct {
+	Remaining           int        `json:"remaining"`             // products left for this job
+	QueueDepth          int        `json:"queue_depth"`           // products left across all queued jobs
+	ActiveJobs          int        `json:"active_jobs"`           // jobs currently queued or running
+	Share               float64    `json:"share"`                 // workers expected to serve this job's dataset
+	ETASeconds          *float64   `json:"eta_seconds"`           // nil until a duration sample exists
+	EstimatedCompletion *time.Time `json:"estimated_completion"`
 }
 
 // ProposalWithProduct extends Proposal with product context
 type ProposalWithProduct struct {
 	Proposal
-	Module            string `json:"module" db:"module"`
 	ProductExternalID string `json:"product_external_id" db:"product_external_id"`
 	ProductTitle      string `json:"product_title" db:"product_title"`
 	DatasetID         uuid.UUID `json:"dataset_id" db:"dataset_id"`
 	DatasetName       string `json:"dataset_name" db:"dataset_name"`
 }
 
+// ProposalGroup is a set of pending proposals writing the same value into the same field
+// across products, reviewed at once
+type ProposalGroup struct {
+	Field          string   `json:"field"`
+	Value          string   `json:"value"`
+	Proposals      int      `json:"proposals"`
+	Products       int      `json:"products"`
+	Conflicts      int      `json:"conflicts"` // products with a competing pending value, left out of a group accept
+	AvgConfidence  float64  `json:"avg_confidence"`
+	MinConfidence  float64  `json:"min_confidence"`
+	RiskLevels     []string `json:"risk_levels"`
+	Modules        []string `json:"modules"`
+	SampleProducts []string `json:"sample_products"` // external IDs
+}
+
+// ReviewSample is a random sample of one module+field stratum reviewed by humans to
+// decide whether the rest can be auto-approved
+type ReviewSample struct {
+	ID         uuid.UUID  `json:"id"`
+	DatasetID  uuid.UUID  `json:"dataset_id"`
+	Module     string     `json:"module"`
+	Field      string     `json:"field"`
+	Population int        `json:"population"`
+	SampleSize int        `json:"sample_size"`
+	Confidence float64    `json:"confidence"`
+	Margin     float64    `json:"margin"`
+	Threshold  float64    `json:"threshold"`
+	Status     string     `json:"status"` // open, auto_approved, closed
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DecidedBy  string     `json:"decided_by,omitempty"`
+	DecidedAt  *time.Time `json:"decided_at,omitempty"`
+	AutoApproved *int     `json:"auto_approved,omitempty"`
+	ChangeID   *uuid.UUID `json:"change_id,omitempty"`
+
+	// Live results of the sampled proposals
+	Accepted       int     `json:"accepted"` // accepted as proposed
+	Edited         int     `json:"edited"`   // accepted with a reviewer edit (counts against the rate)
+	Rejected       int     `json:"rejected"`
+	Pending        int     `json:"pending"`
+	AcceptanceRate float64 `json:"acceptance_rate"` // accepted / (accepted + edited + rejected)
+	Eligible       bool    `json:"eligible"`        // fully reviewed and at or above threshold
+
+	ProposalIDs []uuid.UUID `json:"proposal_ids,omitempty"`
+}
+
+// FieldFeedback is how reviewers decided the proposals of one field of a dataset over a
+// recent window; the prompts are told about it (AGENT_FEEDBACK_WINDOW)
+type FieldFeedback struct {
+	Field    string `json:"field"`
+	Accepted int    `json:"accepted"` // accepted as proposed
+	Edited   int    `json:"edited"`   // accepted with a reviewer edit
+	Rejected int    `json:"rejected"` // rejected or reverted
+}
+
+// BrandAlias makes a brand spelling canonical for a dataset ("adidas originals" → "Adidas")
+type BrandAlias struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	DatasetID uuid.UUID `json:"dataset_id" db:"dataset_id"`
+	Alias     string    `json:"alias" db:"alias"`
+	Brand     string    `json:"brand" db:"brand"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TrustedDomain is a site trusted as a source for a dataset: facts read there are preferred
+// and carry less risk. An empty Brand trusts it for every brand.
+type TrustedDomain struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	DatasetID uuid.UUID `json:"dataset_id" db:"dataset_id"`
+	Domain    string    `json:"domain" db:"domain"` // nike.com also covers www.nike.com
+	Brand     string    `json:"brand" db:"brand"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Problems an image check can find; Google can't show the image in all of them
+const (
+	ImageProblemMissing        = "missing"           // no image_link
+	ImageProblemInvalidURL     = "invalid_url"       // not an absolute http(s) URL
+	ImageProblemUnreachable    = "unreachable"       // DNS, connection or timeout error
+	ImageProblemNotFound       = "not_found"         // 404 or 410
+	ImageProblemHTTPError      = "http_error"        // any other status but 2xx
+	ImageProblemNotImage       = "not_image"         // answers with HTML or another non-image type
+	ImageProblemCrawlerBlocked = "crawler_blocked"   // refused to Google's user agent, served to a browser
+	ImageProblemHotlink        = "hotlink_protected" // refused when requested from another site
+)
+
+// ImageCheck is the last check of a product's image_link
+type ImageCheck struct {
+	ProductID   uuid.UUID `json:"product_id" db:"product_id"`
+	ExternalID  string    `json:"external_id,omitempty" db:"external_id"`
+	DatasetID   uuid.UUID `json:"dataset_id" db:"dataset_id"`
+	URL         string    `json:"url" db:"url"`
+	StatusCode  int       `json:"status_code,omitempty" db:"status_code"`
+	ContentType string    `json:"content_type,omitempty" db:"content_type"`
+	FinalURL    string    `json:"final_url,omitempty" db:"final_url"` // after redirects
+	Redirects   int       `json:"redirects" db:"redirects"`
+	Problem     string    `json:"problem,omitempty" db:"problem"` // empty = an image Google can fetch
+	Detail      string    `json:"detail,omitempty" db:"detail"`
+	CheckedAt   time.Time `json:"checked_at" db:"checked_at"`
+}
+
+// Broken reports whether the image can't be fetched at all, so analyzing it is pointless.
+// Crawler and hotlink blocks still serve the image to the image proxy.
+func (c *ImageCheck) Broken() bool {
+	return c.Problem != "" && c.Problem != ImageProblemCrawlerBlocked && c.Problem != ImageProblemHotlink
+}
+
+// ImageCheckReport is the progress and result of the image checks of a dataset
+type ImageCheckReport struct {
+	DatasetID uuid.UUID      `json:"dataset_id"`
+	Running   bool           `json:"running"`
+	Total     int            `json:"total,omitempty"`   // products of the running check
+	Checked   int            `json:"checked,omitempty"` // of them, checked so far
+	Products  int            `json:"products"`          // products with a recorded check
+	ByProblem map[string]int `json:"by_problem"`
+	Problems  []ImageCheck   `json:"problems"`
+}
+
+// ImageScorecard rates the main images of a dataset, from an image audit job. Percentages
+// are of the images measured (resolution, background) or analyzed by the vision model.
+type ImageScorecard struct {
+	Products    int `json:"products"`    // products audited
+	NoImage     int `json:"no_image"`    // without image_link
+	Unavailable int `json:"unavailable"` // image failed its check or couldn't be downloaded
+	Measured    int `json:"measured"`    // images downloaded and measured
+
+	// Products per range of the shortest side of the image, in pixels
+	Resolution []ResolutionBucket `json:"resolution"`
+
+	Backgrounds        map[string]int `json:"backgrounds"` // white, transparent, colored, lifestyle
+	WhiteBackgroundPct float64        `json:"white_background_pct"`
+	ProductFillOK      int            `json:"product_fill_ok"` // product spanning 75-90% of the image
+	ProductFillOKPct   float64        `json:"product_fill_ok_pct"`
+
+	Analyzed            int     `json:"analyzed"` // images read by the vision model
+	Watermarks          int     `json:"watermarks"`
+	WatermarkPct        float64 `json:"watermark_pct"`
+	TextOverlays        int     `json:"text_overlays"`
+	TextOverlayPct      float64 `json:"text_overlay_pct"`
+	PromoOverlays       int     `json:"promo_overlays"` // overlays GMC disapproves: prices, discounts, "sale"...
+	AverageQualityScore float64 `json:"average_quality_score"`
+}
+
+// ResolutionBucket counts the images whose shortest side is in a range
+type ResolutionBucket struct {
+	Range    string `json:"range"` // "<100", "100-249", ... or "unknown" (webp, avif)
+	Products int    `json:"products"`
+}
+
+// ProposalConflict groups pending proposals that disagree on the same product field
+type ProposalConflict struct {
+	ProductID         uuid.UUID  `json:"product_id"`
+	ProductExternalID string     `json:"product_external_id"`
+	ProductTitle      string     `json:"product_title"`
+	DatasetID         uuid.UUID  `json:"dataset_id"`
+	Field             string     `json:"field"`
+	CurrentValue      *string    `json:"current_value"`
+	Proposals         []Proposal `json:"proposals"` // newest first
+}
+
 // ProposalsByModule groups proposals by optimization module
 type ProposalsByModule struct {
 	Module       string `json:"module"`
@@ -299,3 +757,259 @@ type ProposalsByModule struct {
 	Rejected     int    `json:"rejected"`
 	AutoApproved int    `json:"auto_approved"`
 }
+
+// ===== AUDIT RETENTION MODELS =====
+
+// AuditPartition is a monthly partition of an audit table (change_log, agent_traces)
+type AuditPartition struct {
+	Table      string    `json:"table"`
+	Name       string    `json:"name"`
+	MonthStart time.Time `json:"month_start"`
+	RowCount   int64     `json:"row_count"` // planner estimate
+}
+
+// AuditArchive records a partition export written before purge
+type AuditArchive struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	TableName     string    `json:"table_name" db:"table_name"`
+	PartitionName string    `json:"partition_name" db:"partition_name"`
+	MonthStart    time.Time `json:"month_start" db:"month_start"`
+	RowCount      int64     `json:"row_count" db:"row_count"`
+	Location      string    `json:"location" db:"location"` // file path or object URL
+	Purged        bool      `json:"purged" db:"purged"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// JobFailure is a product moved to the dead-letter of a job after repeated failures
+type JobFailure struct {
+	ID            uuid.UUID  `json:"id"`
+	JobID         uuid.UUID  `json:"job_id"`
+	ProductID     uuid.UUID  `json:"product_id"`
+	ExternalID    string     `json:"external_id"`
+	Attempts      int        `json:"attempts"`
+	Error         string     `json:"error"`
+	ErrorKind     string     `json:"error_kind"` // timeout, parse, api, error
+	Status        string     `json:"status"`     // dead_letter, requeued, resolved
+	RequeuedJobID *uuid.UUID `json:"requeued_job_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ===== ENRICHMENT SCHEDULING MODELS =====
+
+// StaleProduct is a previously enriched product due for a new run
+type StaleProduct struct {
+	Product   Product   `json:"product"`
+	Module    string    `json:"module"`     // module of the last run (the requested one or "all")
+	LastRunAt time.Time `json:"last_run_at"`
+	Reason    string    `json:"reason"` // data_changed, expired
+}
+
+// EnrichmentOutcome is the verdict of the last run of a module on a product
+type EnrichmentOutcome struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Module    string    `json:"module"`
+	Outcome   string    `json:"outcome"` // clean (nothing to improve), changes_proposed
+	Score     *float64  `json:"score"`   // hard rule compliance (0-1) when the run ended
+	Proposals int       `json:"proposals"`
+	LastRunAt time.Time `json:"last_run_at"`
+}
+
+// ModuleOutcomeStats counts products of a dataset by outcome for one module
+type ModuleOutcomeStats struct {
+	Module          string   `json:"module"`
+	Clean           int      `json:"clean"`
+	ChangesProposed int      `json:"changes_proposed"`
+	NotRun          int      `json:"not_run"`
+	AvgCleanScore   *float64 `json:"avg_clean_score"`
+}
+
+// ===== SEGMENT MODELS =====
+
+// Segment is a saved, reusable product filter scoped to a dataset
+// e.g. "apparel items missing color under €50"
+type Segment struct {
+	ID          uuid.UUID     `json:"id" db:"id"`
+	DatasetID   uuid.UUID     `json:"dataset_id" db:"dataset_id"`
+	Name        string        `json:"name" db:"name"`
+	Description string        `json:"description" db:"description"`
+	Filter      SegmentFilter `json:"filter" db:"filter"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// SegmentFilter combines conditions with AND (match=all) or OR (match=any)
+type SegmentFilter struct {
+	Match      string             `json:"match"` // all, any
+	Conditions []SegmentCondition `json:"conditions"`
+}
+
+// SegmentCondition tests a single product field.
+// Field is a feed attribute (e.g. "color", "price") or one of the built-ins
+// "status", "score", "tag", "external_id".
+type SegmentCondition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"` // eq, neq, contains, not_contains, in, missing, present, lt, lte, gt, gte
+	Value any    `json:"value,omitempty"`
+}
+
+// ===== SHADOW EVALUATION MODELS =====
+
+// ShadowRun is one candidate model/prompt run on a sampled product, compared with
+// the primary run. Shadow output is never surfaced as reviewable proposals.
+type ShadowRun struct {
+	ID               uuid.UUID        `json:"id" db:"id"`
+	ProductID        uuid.UUID        `json:"product_id" db:"product_id"`
+	JobID            *uuid.UUID       `json:"job_id" db:"job_id"`
+	Module           string           `json:"module" db:"module"`
+	Variant          string           `json:"variant" db:"variant"`
+	Model            string           `json:"model" db:"model"`
+	Status           string           `json:"status" db:"status"` // completed, failed
+	Error            string           `json:"error,omitempty" db:"error"`
+	PrimaryProposals json.RawMessage  `json:"primary_proposals" db:"primary_proposals"`
+	ShadowCount      int              `json:"shadow_count" db:"shadow_count"`
+	PrimaryCount     int              `json:"primary_count" db:"primary_count"`
+	FieldOverlap     int              `json:"field_overlap" db:"field_overlap"`
+	Agreed           int              `json:"agreed" db:"agreed"`
+	DurationMs       int              `json:"duration_ms" db:"duration_ms"`
+	CreatedAt        time.Time        `json:"created_at" db:"created_at"`
+	Proposals        []ShadowProposal `json:"proposals,omitempty"`
+}
+
+// ShadowProposal is a proposal produced by a shadow run
+type ShadowProposal struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	RunID          uuid.UUID `json:"run_id" db:"run_id"`
+	Field          string    `json:"field" db:"field"`
+	BeforeValue    *string   `json:"before_value" db:"before_value"`
+	AfterValue     string    `json:"after_value" db:"after_value"`
+	Rationale      []string  `json:"rationale" db:"rationale"`
+	Confidence     float64   `json:"confidence" db:"confidence"`
+	RiskLevel      string    `json:"risk_level" db:"risk_level"`
+	MatchesPrimary bool      `json:"matches_primary" db:"matches_primary"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// ShadowSummary aggregates shadow runs of a variant against the primary model
+type ShadowSummary struct {
+	Variant          string  `json:"variant"`
+	Model            string  `json:"model"`
+	Module           string  `json:"module"`
+	Runs             int     `json:"runs"`
+	Failed           int     `json:"failed"`
+	ShadowProposals  int     `json:"shadow_proposals"`
+	PrimaryProposals int     `json:"primary_proposals"`
+	FieldOverlap     int     `json:"field_overlap"`
+	Agreed           int     `json:"agreed"`
+	AgreementRate    float64 `json:"agreement_rate"` // agreed / field_overlap
+	AvgDurationMs    float64 `json:"avg_duration_ms"`
+}
+
+// IdempotencyRecord is the stored outcome of a request sent with an Idempotency-Key
+type IdempotencyRecord struct {
+	Scope       string    `json:"scope" db:"scope"`
+	Key         string    `json:"key" db:"key"`
+	RequestHash string    `json:"request_hash" db:"request_hash"`
+	Status      string    `json:"status" db:"status"` // in_progress, completed
+	StatusCode  int       `json:"status_code" db:"status_code"`
+	Response    []byte    `json:"-" db:"response"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// ===== API KEY MODELS =====
+
+// API key scopes. Admin grants every other scope.
+const (
+	ScopeRead   = "read"   // GET routes
+	ScopeReview = "review" // review proposals, human reviews and samples, edit products
+	ScopeEnrich = "enrich" // import feeds, run enrichment, audits and checks, control jobs
+	ScopeAdmin  = "admin"  // settings, deletions, retention and API keys
+)
+
+// APIScopes lists the valid scopes
+var APIScopes = []string{ScopeRead, ScopeReview, ScopeEnrich, ScopeAdmin}
+
+// APIKey is a key clients authenticate with; only its hash is stored
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	OrgID      uuid.UUID  `json:"org_id" db:"org_id"`
+	UserID     *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"` // first characters of the key
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Allows reports whether the key grants a scope
+func (k *APIKey) Allows(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// ===== ORGANIZATION MODELS =====
+
+// Organization is a tenant: the datasets, rules and API keys of a merchant or client
+type Organization struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	Name         string    `json:"name" db:"name"`
+	Slug         string    `json:"slug" db:"slug"`
+	OpenAIAPIKey string    `json:"-" db:"openai_api_key"` // empty = OPENAI_API_KEY
+	BraveAPIKey  string    `json:"-" db:"brave_api_key"`  // empty = BRAVE_API_KEY
+	Budget       Budget    `json:"budget" db:"budget"`    // caps over all its datasets
+	// Whether the organization has its own keys (never returned)
+	HasOpenAIKey bool      `json:"openai_api_key_set" db:"-"`
+	HasBraveKey  bool      `json:"brave_api_key_set" db:"-"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// User is a member of an organization
+type User struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	OrgID     uuid.UUID `json:"org_id" db:"org_id"`
+	Email     string    `json:"email" db:"email"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ===== RESPONSE CACHE MODELS =====
+
+// CachedResponse is a stored optimization answer, reused while the inputs it was
+// generated from are unchanged
+type CachedResponse struct {
+	Key              string     `json:"key" db:"key"` // hash of prompts, model, group and product data
+	Group            string     `json:"group" db:"group_name"`
+	Model            string     `json:"model" db:"model"`
+	Content          string     `json:"-" db:"content"`
+	PromptTokens     int        `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens" db:"completion_tokens"`
+	CostUSD          float64    `json:"cost_usd" db:"cost_usd"` // what the call cost, saved again by every hit
+	Hits             int        `json:"hits" db:"hits"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at" db:"expires_at"`
+	LastHitAt        *time.Time `json:"last_hit_at,omitempty" db:"last_hit_at"`
+}
+
+// ResponseCacheStats are the hit statistics of the response cache, overall and per group
+type ResponseCacheStats struct {
+	ResponseCacheGroup
+	Expired int                  `json:"expired"` // entries awaiting purge
+	Groups  []ResponseCacheGroup `json:"groups"`
+}
+
+// ResponseCacheGroup is the cache usage of an optimization group
+type ResponseCacheGroup struct {
+	Group       string  `json:"group,omitempty"`
+	Entries     int     `json:"entries"` // live entries
+	Hits        int     `json:"hits"`
+	TokensSaved int     `json:"tokens_saved"`
+	SavedUSD    float64 `json:"saved_usd"`
+}
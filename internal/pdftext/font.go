@@ -0,0 +1,144 @@
+package pdftext
+
+import "strings"
+
+// font maps the codes of shown strings to text
+type font struct {
+	codeLen int               // bytes per code
+	unicode map[uint32]string // from the ToUnicode CMap; nil = Latin-1
+}
+
+// font returns the font of a resource name, cached per font object
+func (d *document) font(resources dict, n name) *font {
+	fonts := d.dict(resources["Font"])
+	r, isRef := fonts[n].(ref)
+	if isRef {
+		if f, ok := d.fonts[r]; ok {
+			return f
+		}
+	}
+	fd := d.dict(fonts[n])
+	if fd == nil {
+		return nil
+	}
+	f := &font{codeLen: 1}
+	if fd["Subtype"] == name("Type0") {
+		f.codeLen = 2
+	}
+	if cmap := d.streamOf(fd["ToUnicode"]); cmap != nil {
+		f.unicode = map[uint32]string{}
+		if codeLen := parseCMap(cmap, f.unicode); codeLen > 0 {
+			f.codeLen = codeLen
+		}
+	}
+	if isRef {
+		d.fonts[r] = f
+	}
+	return f
+}
+
+// decode returns the text of a shown string. Composite fonts without ToUnicode give no text:
+// their codes are glyph ids.
+func (f *font) decode(v any) string {
+	b, _ := v.([]byte)
+	if f == nil || f.unicode == nil {
+		if f != nil && f.codeLen == 2 {
+			return ""
+		}
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	}
+	var sb strings.Builder
+	for i := 0; i+f.codeLen <= len(b); i += f.codeLen {
+		if text, ok := f.unicode[code(b[i:i+f.codeLen])]; ok {
+			sb.WriteString(text)
+		}
+	}
+	return sb.String()
+}
+
+func code(b []byte) uint32 {
+	var c uint32
+	for _, x := range b {
+		c = c<<8 | uint32(x)
+	}
+	return c
+}
+
+// parseCMap reads the bfchar and bfrange mappings of a ToUnicode CMap and returns the code
+// length of its codespace range (0 when it has none)
+func parseCMap(data []byte, unicode map[uint32]string) int {
+	l := &lexer{data: data}
+	codeLen := 0
+	var operands []any
+	section := ""
+	for {
+		v, err := l.object()
+		if err != nil {
+			return codeLen
+		}
+		op, ok := v.(keyword)
+		if !ok {
+			operands = append(operands, v)
+			if section == "bfchar" && len(operands) == 2 {
+				src, _ := operands[0].([]byte)
+				dst, _ := operands[1].([]byte)
+				unicode[code(src)] = utf16Text(dst)
+				operands = operands[:0]
+			}
+			if section == "bfrange" && len(operands) == 3 {
+				bfrange(operands, unicode)
+				operands = operands[:0]
+			}
+			if section == "codespacerange" && len(operands) == 2 {
+				if lo, _ := operands[0].([]byte); codeLen == 0 {
+					codeLen = len(lo)
+				}
+				operands = operands[:0]
+			}
+			continue
+		}
+		switch op {
+		case "begincodespacerange":
+			section = "codespacerange"
+		case "beginbfchar":
+			section = "bfchar"
+		case "beginbfrange":
+			section = "bfrange"
+		default:
+			section = ""
+		}
+		operands = operands[:0]
+	}
+}
+
+// bfrange maps a range of codes: to consecutive characters from a start, or to an array
+func bfrange(operands []any, unicode map[uint32]string) {
+	lo, _ := operands[0].([]byte)
+	hi, _ := operands[1].([]byte)
+	start, end := code(lo), code(hi)
+	if end < start || end-start > 0xFFFF {
+		return
+	}
+	switch dst := operands[2].(type) {
+	case []byte:
+		runes := []rune(utf16Text(dst))
+		if len(runes) == 0 {
+			return
+		}
+		for c := start; c <= end; c++ {
+			runes[len(runes)-1] += rune(c - start)
+			unicode[c] = string(runes)
+			runes[len(runes)-1] -= rune(c - start)
+		}
+	case []any:
+		for i, d := range dst {
+			if b, ok := d.([]byte); ok && start+uint32(i) <= end {
+				unicode[start+uint32(i)] = utf16Text(b)
+			}
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// PDF object types; numbers are float64, strings []byte, arrays []any
+type (
+	name    string
+	keyword string
+	dict    map[name]any
+	ref     struct{ num, gen int }
+)
+
+// end markers of dictionaries and arrays
+const (
+	dictEnd  keyword = ">>"
+	arrayEnd keyword = "]"
+)
+
+const maxStreamBytes = 16 << 20
+
+// maxNesting bounds how deeply arrays and dictionaries nest, so a hostile file can't
+// exhaust the stack
+const maxNesting = 64
+
+var errSyntax = errors.New("pdf syntax error")
+
+// lexer reads PDF objects. refs enables "N G R" references, which content streams don't have.
+type lexer struct {
+	data  []byte
+	pos   int
+	refs  bool
+	depth int // arrays and dictionaries being read
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (l *lexer) skipSpace() {
+	l.pos = min(max(l.pos, 0), len(l.data))
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// object reads the next object, or a keyword (operators, "obj", "R", ">>", "]")
+func (l *lexer) object() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		return l.name(), nil
+	case c == '(':
+		return l.literalString(), nil
+	case c == '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return l.dict()
+		}
+		return l.hexString(), nil
+	case c == '>':
+		l.pos++
+		if l.pos < len(l.data) && l.data[l.pos] == '>' {
+			l.pos++
+		}
+		return dictEnd, nil
+	case c == '[':
+		l.pos++
+		return l.array()
+	case c == ']':
+		l.pos++
+		return arrayEnd, nil
+	case c == '{' || c == '}' || c == ')':
+		l.pos++
+		return keyword(c), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.number(), nil
+	default:
+		start := l.pos
+		for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		switch word := string(l.data[start:l.pos]); word {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			return keyword(word), nil
+		}
+	}
+}
+
+func (l *lexer) name() name {
+	l.pos++
+	var b []byte
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		l.pos++
+	}
+	return name(b)
+}
+
+func (l *lexer) number() any {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.data) && (l.data[l.pos] == '.' || (l.data[l.pos] >= '0' && l.data[l.pos] <= '9')) {
+		l.pos++
+	}
+	n, _ := strconv.ParseFloat(string(l.data[start:l.pos]), 64)
+	if !l.refs || n != float64(int(n)) || n < 0 {
+		return n
+	}
+	// "N G R" is a reference
+	save := l.pos
+	l.skipSpace()
+	genStart := l.pos
+	for l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+		l.pos++
+	}
+	if l.pos > genStart {
+		gen, _ := strconv.Atoi(string(l.data[genStart:l.pos]))
+		l.skipSpace()
+		if l.pos < len(l.data) && l.data[l.pos] == 'R' && (l.pos+1 == len(l.data) || isSpace(l.data[l.pos+1]) || isDelimiter(l.data[l.pos+1])) {
+			l.pos++
+			return ref{num: int(n), gen: gen}
+		}
+	}
+	l.pos = save
+	return n
+}
+
+func (l *lexer) literalString() []byte {
+	l.pos++
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return b
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+func (l *lexer) hexString() []byte {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b, _ := hex.DecodeString(string(digits))
+	return b
+}
+
+func (l *lexer) dict() (any, error) {
+	if err := l.nest(); err != nil {
+		return nil, err
+	}
+	defer l.unnest()
+	d := dict{}
+	for {
+		key, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if key == dictEnd {
+			return d, nil
+		}
+		k, ok := key.(name)
+		if !ok {
+			continue
+		}
+		value, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if value == dictEnd {
+			return d, nil
+		}
+		d[k] = value
+	}
+}
+
+func (l *lexer) array() (any, error) {
+	if err := l.nest(); err != nil {
+		return nil, err
+	}
+	defer l.unnest()
+	a := []any{}
+	for {
+		v, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if v == arrayEnd {
+			return a, nil
+		}
+		a = append(a, v)
+	}
+}
+
+// nest enters an array or dictionary, errSyntax past maxNesting levels
+func (l *lexer) nest() error {
+	if l.depth >= maxNesting {
+		return errSyntax
+	}
+	l.depth++
+	return nil
+}
+
+func (l *lexer) unnest() {
+	l.depth--
+}
+
+// stream reads the stream following a dictionary, when there is one: its /Length when it
+// is direct and right, up to "endstream" otherwise
+func (l *lexer) stream(d dict) []byte {
+	save := l.pos
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		l.pos = save
+		return nil
+	}
+	l.pos += len("stream")
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+	if length, ok := d["Length"].(float64); ok && length >= 0 && start+int(length) <= len(l.data) {
+		end := start + int(length)
+		rest := bytes.TrimLeft(l.data[end:min(end+32, len(l.data))], " \r\n\t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			l.pos = end + bytes.Index(l.data[end:], []byte("endstream")) + len("endstream")
+			return l.data[start:end]
+		}
+	}
+	i := bytes.Index(l.data[start:], []byte("endstream"))
+	if i < 0 {
+		l.pos = len(l.data)
+		return l.data[start:]
+	}
+	l.pos = start + i + len("endstream")
+	return bytes.TrimRight(l.data[start:start+i], "\r\n")
+}
+
+// skipInlineImage skips the data of an inline image, after its ID operator, up to EI
+func (l *lexer) skipInlineImage() {
+	for i := l.pos; i+2 < len(l.data); i++ {
+		if l.data[i] == 'E' && l.data[i+1] == 'I' && isSpace(l.data[i-1]) && (i+2 == len(l.data) || isSpace(l.data[i+2])) {
+			l.pos = i + 2
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
+
+// decodeStream applies the stream's filters; only FlateDecode without predictor is supported
+func decodeStream(d dict, raw []byte) ([]byte, error) {
+	var filters []any
+	switch f := d["Filter"].(type) {
+	case nil:
+		return raw, nil
+	case name:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+	if params, ok := d["DecodeParms"].(dict); ok && number(params["Predictor"]) > 1 {
+		return nil, errors.New("unsupported predictor")
+	}
+	data := raw
+	for _, filter := range filters {
+		switch filter {
+		case name("FlateDecode"), name("Fl"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// a truncated or damaged stream still gives what it could inflate
+			decoded, err := io.ReadAll(io.LimitReader(r, maxStreamBytes))
+			if len(decoded) == 0 && err != nil {
+				return nil, err
+			}
+			data = decoded
+		default:
+			return nil, errSyntax
+		}
+	}
+	return data, nil
+}
+
+func number(v any) float64 {
+	n, _ := v.(float64)
+	return n
+}
@@ -0,0 +1,464 @@
+// Package pdftext extracts the text of PDF documents, page by page, so spec sheets found
+// during retrieval can be read and cited with their page number.
+//
+// It reads what datasheets need and nothing more: objects are found by scanning the file
+// (not through the xref table, so truncated or damaged files still give their first
+// pages), object streams and FlateDecode are supported, and glyphs are mapped to text
+// through the fonts' ToUnicode CMaps, or Latin-1 for simple fonts without one. Text
+// positioning is kept loosely: a vertical move starts a new line and a horizontal jump
+// inside a line becomes a tab, so table rows come out as one line with tab-separated cells.
+// Encrypted documents and scanned pages (images only) have no text to give.
+package pdftext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Page is the text of a PDF page, numbered from 1
+type Page struct {
+	Number int    `json:"page"`
+	Text   string `json:"text"`
+}
+
+var (
+	ErrNotPDF    = errors.New("not a PDF document")
+	ErrEncrypted = errors.New("encrypted PDF")
+	ErrNoText    = errors.New("PDF has no extractable text")
+)
+
+const (
+	maxPages     = 100
+	maxFormDepth = 5
+)
+
+// IsPDF reports whether data is a PDF document: the header must be in its first 1024 bytes
+func IsPDF(data []byte) bool {
+	return bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-"))
+}
+
+// Extract returns the text of the pages of a PDF document, in reading order; pages without
+// text are left out. At most the first 100 pages are read.
+func Extract(data []byte) (pages []Page, err error) {
+	// Documents come from the web: a parser bug on a malformed one fails that document
+	// only, not the job reading it
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+	if !IsPDF(data) {
+		return nil, ErrNotPDF
+	}
+	d := parseDocument(data)
+	if d.encrypted {
+		return nil, ErrEncrypted
+	}
+	for i, page := range d.pages() {
+		if i == maxPages {
+			break
+		}
+		text := d.pageText(page)
+		if text != "" {
+			pages = append(pages, Page{Number: i + 1, Text: text})
+		}
+	}
+	if len(pages) == 0 {
+		return nil, ErrNoText
+	}
+	return pages, nil
+}
+
+// Format joins pages as "[page N]" sections, cut at maxChars (0 = no limit)
+func Format(pages []Page, maxChars int) string {
+	var sb strings.Builder
+	for _, p := range pages {
+		fmt.Fprintf(&sb, "[page %d]\n%s\n\n", p.Number, p.Text)
+		if maxChars > 0 && sb.Len() >= maxChars {
+			break
+		}
+	}
+	text := strings.TrimSpace(sb.String())
+	if maxChars > 0 && len(text) > maxChars {
+		text = strings.ToValidUTF8(text[:maxChars], "")
+	}
+	return text
+}
+
+// object is an indirect object, with its decoded-on-demand stream
+type object struct {
+	value  any
+	stream []byte // raw, still encoded
+}
+
+type document struct {
+	objects   map[int]*object
+	root      any
+	encrypted bool
+	fonts     map[ref]*font
+}
+
+var objectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// parseDocument reads every "N G obj" of the file, later definitions (incremental updates)
+// replacing earlier ones, then the objects packed in object streams
+func parseDocument(data []byte) *document {
+	d := &document{objects: map[int]*object{}, fonts: map[ref]*font{}}
+	cursor := 0
+	for _, m := range objectHeader.FindAllSubmatchIndex(data, -1) {
+		if m[0] < cursor {
+			continue // inside the previous object's stream
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &lexer{data: data, pos: m[1], refs: true}
+		value, err := l.object()
+		if err != nil {
+			continue
+		}
+		obj := &object{value: value}
+		if header, ok := value.(dict); ok {
+			obj.stream = l.stream(header)
+			d.trailer(header)
+		}
+		d.objects[num] = obj
+		cursor = l.pos
+	}
+	for _, i := range bytesIndexes(data, []byte("trailer")) {
+		l := &lexer{data: data, pos: i + len("trailer"), refs: true}
+		if value, err := l.object(); err == nil {
+			if trailer, ok := value.(dict); ok {
+				d.trailer(trailer)
+			}
+		}
+	}
+
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		obj := d.objects[num]
+		if header, ok := obj.value.(dict); ok && header["Type"] == name("ObjStm") {
+			d.unpackObjectStream(header, obj.stream)
+		}
+	}
+	return d
+}
+
+// trailer reads the root and encryption of a trailer or cross-reference stream dictionary
+func (d *document) trailer(trailer dict) {
+	if _, ok := trailer["Root"]; !ok {
+		return
+	}
+	d.root = trailer["Root"]
+	if _, ok := trailer["Encrypt"]; ok {
+		d.encrypted = true
+	}
+}
+
+func (d *document) unpackObjectStream(header dict, raw []byte) {
+	data, err := decodeStream(header, raw)
+	if err != nil {
+		return
+	}
+	n, first := int(number(header["N"])), int(number(header["First"]))
+	if first <= 0 || first > len(data) {
+		return
+	}
+	offsets := &lexer{data: data[:first]}
+	for i := 0; i < n; i++ {
+		numValue, err1 := offsets.object()
+		offsetValue, err2 := offsets.object()
+		if err1 != nil || err2 != nil {
+			return
+		}
+		num, offset := int(number(numValue)), int(number(offsetValue))
+		if num < 0 || offset < 0 {
+			return
+		}
+		if _, ok := d.objects[num]; ok || first+offset >= len(data) {
+			continue
+		}
+		l := &lexer{data: data, pos: first + offset, refs: true}
+		if value, err := l.object(); err == nil {
+			d.objects[num] = &object{value: value}
+		}
+	}
+}
+
+// resolve follows references
+func (d *document) resolve(v any) any {
+	for i := 0; i < 10; i++ {
+		r, ok := v.(ref)
+		if !ok {
+			return v
+		}
+		obj := d.objects[r.num]
+		if obj == nil {
+			return nil
+		}
+		v = obj.value
+	}
+	return nil
+}
+
+func (d *document) dict(v any) dict {
+	resolved, _ := d.resolve(v).(dict)
+	return resolved
+}
+
+// streamOf returns the decoded stream of a referenced object
+func (d *document) streamOf(v any) []byte {
+	r, ok := v.(ref)
+	if !ok {
+		return nil
+	}
+	obj := d.objects[r.num]
+	if obj == nil || obj.stream == nil {
+		return nil
+	}
+	header, _ := obj.value.(dict)
+	data, err := decodeStream(header, obj.stream)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// pageNode is a page with the resources it inherits
+type pageNode struct {
+	dict      dict
+	resources dict
+}
+
+// pages walks the page tree from the catalog; without one, pages are taken in object order
+func (d *document) pages() []pageNode {
+	var pages []pageNode
+	seen := map[int]bool{}
+	var walk func(v any, resources dict, depth int)
+	walk = func(v any, resources dict, depth int) {
+		if r, ok := v.(ref); ok {
+			if seen[r.num] {
+				return
+			}
+			seen[r.num] = true
+		}
+		node := d.dict(v)
+		if node == nil || depth > 50 {
+			return
+		}
+		if res := d.dict(node["Resources"]); res != nil {
+			resources = res
+		}
+		if kids, ok := d.resolve(node["Kids"]).([]any); ok {
+			for _, kid := range kids {
+				walk(kid, resources, depth+1)
+			}
+			return
+		}
+		if node["Type"] == name("Page") || node["Contents"] != nil {
+			pages = append(pages, pageNode{dict: node, resources: resources})
+		}
+	}
+	if catalog := d.dict(d.root); catalog != nil {
+		walk(catalog["Pages"], nil, 0)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	nums := make([]int, 0, len(d.objects))
+	for num, obj := range d.objects {
+		if page, ok := obj.value.(dict); ok && page["Type"] == name("Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		page := d.objects[num].value.(dict)
+		pages = append(pages, pageNode{dict: page, resources: d.dict(page["Resources"])})
+	}
+	return pages
+}
+
+func (d *document) pageText(page pageNode) string {
+	var content []byte
+	switch contents := d.resolve(page.dict["Contents"]).(type) {
+	case []any:
+		for _, part := range contents {
+			content = append(append(content, d.streamOf(part)...), '\n')
+		}
+	default:
+		content = d.streamOf(page.dict["Contents"])
+	}
+	t := &textWriter{}
+	d.runContent(content, page.resources, t, 0)
+	return t.String()
+}
+
+// runContent interprets the text operators of a content stream, and of the forms it draws
+func (d *document) runContent(content []byte, resources dict, t *textWriter, depth int) {
+	l := &lexer{data: content}
+	var operands []any
+	var current *font
+	for {
+		v, err := l.object()
+		if err != nil {
+			return
+		}
+		op, ok := v.(keyword)
+		if !ok {
+			operands = append(operands, v)
+			continue
+		}
+		switch op {
+		case "BT":
+			t.newLine()
+		case "Tf":
+			if len(operands) >= 2 {
+				if n, ok := operands[len(operands)-2].(name); ok {
+					current = d.font(resources, n)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				t.move(number(operands[len(operands)-2]), number(operands[len(operands)-1]))
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				t.moveTo(number(operands[len(operands)-1]))
+			}
+		case "T*":
+			t.newLine()
+		case "Tj":
+			if len(operands) >= 1 {
+				t.show(current.decode(operands[len(operands)-1]))
+			}
+		case "'", "\"":
+			t.newLine()
+			if len(operands) >= 1 {
+				t.show(current.decode(operands[len(operands)-1]))
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				parts, _ := operands[len(operands)-1].([]any)
+				for _, part := range parts {
+					if n, ok := part.(float64); ok {
+						if n < -150 { // a kerning this wide is a space
+							t.show(" ")
+						}
+						continue
+					}
+					t.show(current.decode(part))
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 && depth < maxFormDepth {
+				n, _ := operands[len(operands)-1].(name)
+				xobject := d.dict(resources["XObject"])
+				if r, ok := xobject[n].(ref); ok {
+					form := d.dict(r)
+					if form["Subtype"] == name("Form") {
+						formResources := d.dict(form["Resources"])
+						if formResources == nil {
+							formResources = resources
+						}
+						d.runContent(d.streamOf(r), formResources, t, depth+1)
+					}
+				}
+			}
+		case "ID":
+			l.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// textWriter assembles the shown text into lines
+type textWriter struct {
+	lines []string
+	line  strings.Builder
+	y     float64
+	hasY  bool
+}
+
+func (t *textWriter) show(s string) {
+	t.line.WriteString(s)
+}
+
+func (t *textWriter) newLine() {
+	if line := strings.Join(strings.Fields(t.line.String()), " "); line != "" {
+		t.lines = append(t.lines, line)
+	}
+	t.line.Reset()
+}
+
+func (t *textWriter) cell() {
+	if t.line.Len() > 0 {
+		t.line.WriteString(" \u0000 ") // a tab once the line's spaces are collapsed
+	}
+}
+
+func (t *textWriter) move(tx, ty float64) {
+	switch {
+	case ty != 0:
+		t.newLine()
+		t.y += ty
+	case tx != 0:
+		t.cell()
+	}
+}
+
+func (t *textWriter) moveTo(y float64) {
+	if t.hasY && y == t.y {
+		t.cell()
+	} else {
+		t.newLine()
+	}
+	t.y, t.hasY = y, true
+}
+
+func (t *textWriter) String() string {
+	t.newLine()
+	var lines []string
+	for _, line := range t.lines {
+		var cells []string
+		for _, cell := range strings.Split(line, "\u0000") {
+			if cell = strings.TrimSpace(cell); cell != "" {
+				cells = append(cells, cell)
+			}
+		}
+		if len(cells) > 0 {
+			lines = append(lines, strings.Join(cells, "\t"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func bytesIndexes(data, sep []byte) []int {
+	var indexes []int
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], sep)
+		if i < 0 {
+			return indexes
+		}
+		indexes = append(indexes, offset+i)
+		offset += i + len(sep)
+	}
+}
+
+// utf16Text decodes UTF-16BE bytes
+func utf16Text(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
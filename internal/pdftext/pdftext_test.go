@@ -0,0 +1,121 @@
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF writes a PDF of the given objects, numbered from 1, with object 1 as the root
+func buildPDF(objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func stream(header string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", header, len(data), data)
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// onePage is a document with one page showing content with a simple font
+func onePage(content []byte, filter string) []byte {
+	return buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		stream(filter, content),
+	)
+}
+
+func TestExtract(t *testing.T) {
+	pages, err := Extract(onePage([]byte("BT /F1 12 Tf 72 720 Td (Weight 1.2 kg) Tj ET"), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0].Number != 1 || !strings.Contains(pages[0].Text, "Weight 1.2 kg") {
+		t.Fatalf("got %+v", pages)
+	}
+
+	pages, err = Extract(onePage(deflate([]byte("BT /F1 12 Tf (Deflated) Tj ET")), "/Filter /FlateDecode"))
+	if err != nil || len(pages) != 1 || !strings.Contains(pages[0].Text, "Deflated") {
+		t.Fatalf("got %+v, %v", pages, err)
+	}
+}
+
+func TestExtractMalformed(t *testing.T) {
+	deep := bytes.Repeat([]byte("["), 1<<20)
+	valid := onePage([]byte("BT /F1 12 Tf (Weight) Tj ET"), "")
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a PDF", []byte("<html>not a pdf</html>")},
+		{"header only", []byte("%PDF-1.7")},
+		{"truncated", valid[:len(valid)/2]},
+		{"garbage after header", append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("<<]>>(\\"), 1000)...)},
+		{"deeply nested content stream", onePage(deflate(deep), "/Filter /FlateDecode")},
+		{"deeply nested dictionaries", buildPDF(strings.Repeat("<< /A ", 100000))},
+		{"deeply nested object", buildPDF("<< /Type /Catalog /Pages 2 0 R >>", string(deep))},
+		{"negative object stream offset", buildPDF(
+			"<< /Type /Catalog /Pages 3 0 R >>",
+			stream("/Type /ObjStm /N 1 /First 8", []byte("3 -91   << /Type /Pages >>")),
+		)},
+		{"negative object number", buildPDF(
+			"<< /Type /Catalog >>",
+			stream("/Type /ObjStm /N 1 /First 8", []byte("-3 0    << /Type /Pages >>")),
+		)},
+		{"object stream offset past the end", buildPDF(
+			"<< /Type /Catalog >>",
+			stream("/Type /ObjStm /N 2 /First 10", []byte("3 0 4 999 << /A 1 >>")),
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := Extract(tt.data)
+			if err == nil {
+				t.Fatalf("expected an error, got %+v", pages)
+			}
+		})
+	}
+}
+
+func TestLexerNesting(t *testing.T) {
+	l := &lexer{data: []byte(strings.Repeat("[", maxNesting+1) + strings.Repeat("]", maxNesting+1))}
+	if _, err := l.object(); !errors.Is(err, errSyntax) {
+		t.Fatalf("err = %v, want errSyntax", err)
+	}
+
+	l = &lexer{data: []byte(strings.Repeat("[", maxNesting) + "1" + strings.Repeat("]", maxNesting))}
+	if _, err := l.object(); err != nil {
+		t.Fatalf("%d levels: %v", maxNesting, err)
+	}
+	if l.depth != 0 {
+		t.Fatalf("depth = %d after the object, want 0", l.depth)
+	}
+}
+
+func TestLexerClampsPosition(t *testing.T) {
+	for _, pos := range []int{-91, 1000} {
+		l := &lexer{data: []byte("<< /A 1 >>"), pos: pos}
+		if _, err := l.object(); err != nil && pos < 0 {
+			t.Errorf("pos %d: %v", pos, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package productdata
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// blockTagPattern matches the tags that end a line of text: line breaks, paragraphs,
+// list items, table cells and headings
+var blockTagPattern = regexp.MustCompile(`(?i)<\s*(br|/p|/li|/div|/tr|/td|/h[1-6])\b[^>]*>`)
+
+// invisibleChars are removed from text: zero-width spaces and joiners, the byte order mark
+// and soft hyphens
+var invisibleChars = strings.NewReplacer("\u200b", "", "\u200c", "", "\u200d", "", "\ufeff", "", "\u00ad", "")
+
+// CleanText strips HTML tags and entities from a value and normalizes its whitespace:
+// non-breaking and repeated spaces become one space and invisible characters are removed.
+// Lines ended by block tags (<br>, </p>, </li>...) are joined as sentences ("Coton bio<br>
+// Lavable" → "Coton bio. Lavable") so list items don't run together.
+func CleanText(s string) string {
+	if strings.ContainsRune(s, '<') {
+		s = blockTagPattern.ReplaceAllString(s, "\n")
+		s = tagPattern.ReplaceAllString(s, " ")
+	}
+	if strings.ContainsRune(s, '&') {
+		s = html.UnescapeString(s)
+	}
+	s = invisibleChars.Replace(s)
+
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.FieldsFunc(line, unicode.IsSpace), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	for i := 0; i < len(lines)-1; i++ {
+		if !strings.ContainsRune(".!?:;,", rune(lines[i][len(lines[i])-1])) {
+			lines[i] += "."
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// shoutMinRun is the number of consecutive upper-case words read as shouting; fewer are
+// taken for acronyms or model names ("LED TV")
+const shoutMinRun = 3
+
+// acronyms stay upper case in shouted text
+var acronyms = map[string]bool{
+	"usb": true, "led": true, "lcd": true, "oled": true, "hd": true, "uhd": true, "tv": true,
+	"gps": true, "ssd": true, "hdd": true, "hdmi": true, "nfc": true, "ram": true, "cpu": true,
+	"gpu": true, "pc": true, "uv": true, "xs": true, "xl": true, "xxl": true, "eu": true,
+	"uk": true, "us": true, "usa": true, "diy": true, "ce": true, "spf": true,
+}
+
+// Deshout writes ALL-CAPS shouting in sentence case: runs of shoutMinRun or more upper-case
+// words, or every upper-case word when most letters of the text are upper case. Words with
+// digits (USB3, 4K) and known acronyms stay as they are, the keep words (the brand) are
+// written as given. Text without shouting is returned unchanged.
+func Deshout(s string, keep ...string) string {
+	words := strings.Split(s, " ")
+	shouted := make([]bool, len(words))
+	for i, w := range words {
+		shouted[i] = isShouted(w)
+	}
+
+	letters, upper := 0, 0
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	minRun := shoutMinRun
+	if letters >= 10 && float64(upper)/float64(letters) > 0.6 {
+		minRun = 1
+	}
+
+	kept := map[string]string{} // lower case → spelling
+	for _, k := range keep {
+		for _, w := range strings.Fields(k) {
+			kept[strings.ToLower(trimPunct(w))] = trimPunct(w)
+		}
+	}
+
+	changed := false
+	sentenceStart := true
+	for i := 0; i < len(words); {
+		if !shouted[i] {
+			if trimPunct(words[i]) != "" {
+				sentenceStart = endsSentence(words[i])
+			}
+			i++
+			continue
+		}
+		j := i
+		for j < len(words) && shouted[j] {
+			j++
+		}
+		if j-i < minRun {
+			sentenceStart = endsSentence(words[j-1])
+			i = j
+			continue
+		}
+		for ; i < j; i++ {
+			w := words[i]
+			core := strings.ToLower(trimPunct(w))
+			if spelling, ok := kept[core]; ok {
+				w = strings.Replace(w, trimPunct(w), spelling, 1)
+				words[i], changed = w, changed || w != words[i]
+			} else if !acronyms[core] && !strings.ContainsFunc(w, unicode.IsDigit) {
+				w = strings.ToLower(w)
+				if sentenceStart {
+					w = capitalize(w)
+				}
+				words[i], changed = w, true
+			}
+			sentenceStart = endsSentence(w)
+		}
+	}
+	if !changed {
+		return s
+	}
+	return strings.Join(words, " ")
+}
+
+// isShouted reports an upper-case word of at least two letters
+func isShouted(w string) bool {
+	letters := 0
+	for _, r := range w {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters >= 2
+}
+
+func trimPunct(w string) string {
+	return strings.TrimFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// endsSentence reports a word ending with . ! or ?, before any closing quote or bracket
+func endsSentence(w string) bool {
+	w = strings.TrimRight(w, `"')»`)
+	return w != "" && strings.ContainsRune(".!?", rune(w[len(w)-1]))
+}
+
+// capitalize upper-cases the first letter of a word, after any opening punctuation
+func capitalize(w string) string {
+	for i, r := range w {
+		if unicode.IsLetter(r) {
+			return w[:i] + string(unicode.ToUpper(r)) + w[i+len(string(r)):]
+		}
+	}
+	return w
+}
@@ -0,0 +1,161 @@
+// Package productdata prepares product feed data for prompts. Wide feeds carry hundreds
+// of columns, most of them empty or irrelevant to enrichment, and descriptions full of
+// HTML; sent as is they make every call expensive or overflow the context.
+package productdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/llm"
+)
+
+// OmittedKey lists the fields dropped to fit the token budget, so the model doesn't
+// take them for missing
+const OmittedKey = "_omitted_fields"
+
+// essentialFields are never dropped for the budget: the enrichment reads or fills them
+var essentialFields = []string{
+	"id", "title", "description", "brand", "gtin", "mpn", "price", "sale_price", "availability",
+	"condition", "link", "image_link", "google_product_category", "product_type", "item_group_id",
+	"color", "size", "size_system", "gender", "age_group", "material", "pattern",
+}
+
+// shrinkOrder is the order long essential values are cut in when dropping other fields
+// was not enough
+var shrinkOrder = []string{"description", "product_type", "title"}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Slim returns product data fit for a prompt. The rules are deterministic, so the same
+// data always gives the same prompt:
+//   - empty values (null, blank strings, empty lists and objects) and keys starting with
+//     "_" are removed;
+//   - HTML tags and entities are stripped from text and whitespace normalized (CleanText),
+//     and ALL-CAPS shouting in the description written in sentence case (Deshout);
+//   - text longer than maxChars is cut at a word boundary;
+//   - while the result is above maxTokens (estimated), the largest non-essential field is
+//     dropped (ties by name) and listed under OmittedKey; then the description, product
+//     type and title are halved in turn.
+//
+// Zero limits disable the matching rule. Data that is not a JSON object is returned as is.
+func Slim(data json.RawMessage, maxChars, maxTokens int) json.RawMessage {
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return data
+	}
+
+	for k, v := range fields {
+		if strings.HasPrefix(k, "_") || empty(v) {
+			delete(fields, k)
+			continue
+		}
+		if s, ok := v.(string); ok {
+			s = CleanText(s)
+			if strings.EqualFold(k, "description") {
+				s = Deshout(s, stringField(fields, "brand"))
+			}
+			fields[k] = truncate(s, maxChars)
+		}
+	}
+
+	var omitted []string
+	for maxTokens > 0 && tokens(fields, omitted) > maxTokens {
+		key := largestDroppable(fields)
+		if key == "" {
+			break
+		}
+		delete(fields, key)
+		omitted = append(omitted, key)
+	}
+	for _, key := range shrinkOrder {
+		for maxTokens > 0 && tokens(fields, omitted) > maxTokens {
+			s, ok := fields[key].(string)
+			if !ok || utf8.RuneCountInString(s) < 64 {
+				break
+			}
+			fields[key] = truncate(s, utf8.RuneCountInString(s)/2)
+		}
+	}
+
+	if len(omitted) > 0 {
+		slices.Sort(omitted)
+		fields[OmittedKey] = omitted
+	}
+	// No HTML escaping: "&" and "<" stay readable in the prompt
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fields); err != nil {
+		return data
+	}
+	return bytes.TrimSpace(out.Bytes())
+}
+
+// ForPrompt returns product data slimmed with the configured limits
+// (AGENT_PRODUCT_FIELD_MAX_CHARS, AGENT_PRODUCT_MAX_TOKENS)
+func ForPrompt(cfg *config.Config, data json.RawMessage) string {
+	return string(Slim(data, cfg.Agent.ProductFieldMaxChars, cfg.Agent.ProductMaxTokens))
+}
+
+func empty(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(t) == ""
+	case []any:
+		return len(t) == 0
+	case map[string]any:
+		return len(t) == 0
+	}
+	return false
+}
+
+func stringField(fields map[string]any, key string) string {
+	s, _ := fields[key].(string)
+	return s
+}
+
+// truncate cuts s to at most max runes, at the last word boundary when there is one
+func truncate(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	cut := string([]rune(s)[:max])
+	if i := strings.LastIndexByte(cut, ' '); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
+
+func tokens(fields map[string]any, omitted []string) int {
+	raw, _ := json.Marshal(fields)
+	n := llm.EstimateTokens(string(raw))
+	if len(omitted) > 0 {
+		names, _ := json.Marshal(omitted)
+		n += llm.EstimateTokens(string(names))
+	}
+	return n
+}
+
+// largestDroppable returns the non-essential field with the largest value, "" when only
+// essential fields are left
+func largestDroppable(fields map[string]any) string {
+	best, bestSize := "", -1
+	for k, v := range fields {
+		if slices.Contains(essentialFields, strings.ToLower(k)) {
+			continue
+		}
+		raw, _ := json.Marshal(v)
+		if len(raw) > bestSize || (len(raw) == bestSize && k < best) {
+			best, bestSize = k, len(raw)
+		}
+	}
+	return best
+}
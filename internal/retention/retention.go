@@ -0,0 +1,264 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// partitionsAhead is how many future monthly partitions are kept pre-created
+const partitionsAhead = 2
+
+// partitionsInterval is how often KeepPartitions checks the upcoming partitions
+const partitionsInterval = 24 * time.Hour
+
+// Archiver writes an exported partition to durable storage and returns its location
+type Archiver interface {
+	Archive(ctx context.Context, table, partition string, write func(w io.Writer) (int64, error)) (location string, rows int64, err error)
+}
+
+// Service archives and purges audit partitions older than the retention window, and prunes
+// old token usage and snapshots
+type Service struct {
+	config   *config.Config
+	queries  *db.Queries
+	archiver Archiver
+}
+
+// Report summarizes a retention run
+type Report struct {
+	CutoffMonth time.Time             `json:"cutoff_month"`
+	Cutoffs     map[string]time.Time  `json:"cutoffs"` // per audit table
+	Archived    []models.AuditArchive `json:"archived"`
+	Purged      []string              `json:"purged"`
+	// Rows of token_usage and token_usage_attribution, and snapshots, pruned
+	TokenUsage int64       `json:"token_usage"`
+	Snapshots  []uuid.UUID `json:"snapshots"`
+	DryRun     bool        `json:"dry_run"`
+}
+
+// New creates a retention service using the configured storage backend
+func New(cfg *config.Config, queries *db.Queries) (*Service, error) {
+	archiver, err := newArchiver(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{config: cfg, queries: queries, archiver: archiver}, nil
+}
+
+func newArchiver(cfg *config.Config) (Archiver, error) {
+	switch cfg.Storage.Type {
+	case "", "local":
+		return &LocalArchiver{Root: filepath.Join(cfg.Storage.Path, "archive")}, nil
+	default:
+		// Object storage buckets are expected to be mounted (gcsfuse, s3fs) under STORAGE_PATH
+		return nil, fmt.Errorf("retention: storage type %q has no archiver, mount the bucket and use STORAGE_TYPE=local", cfg.Storage.Type)
+	}
+}
+
+// Start runs retention periodically until ctx is cancelled
+func (s *Service) Start(ctx context.Context) {
+	if !s.config.Retention.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(s.config.Retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		if report, err := s.Run(ctx, false); err != nil {
+			log.Printf("Retention run failed: %v", err)
+		} else {
+			if len(report.Purged) > 0 {
+				log.Printf("Retention: purged %d audit partitions older than %s", len(report.Purged), report.CutoffMonth.Format("2006-01"))
+			}
+			if report.TokenUsage > 0 || len(report.Snapshots) > 0 {
+				log.Printf("Retention: pruned %d token usage rows and %d snapshots", report.TokenUsage, len(report.Snapshots))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// KeepPartitions pre-creates the upcoming monthly audit partitions now, then every day until
+// ctx is cancelled. It runs whether retention is enabled or not: rows of a month without its
+// partition land in the DEFAULT partition, and that month's partition can then no longer be
+// created.
+func KeepPartitions(ctx context.Context, queries *db.Queries) {
+	ticker := time.NewTicker(partitionsInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := queries.EnsureAuditPartitions(ctx, partitionsAhead); err != nil {
+			log.Printf("Retention: creating audit partitions failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// auditMonths is the retention window of an audit table, at least the current month
+func (s *Service) auditMonths(table string) int {
+	months := s.config.Retention.AuditMonths
+	switch {
+	case table == "agent_traces" && s.config.Retention.TracesMonths > 0:
+		months = s.config.Retention.TracesMonths
+	case table == "change_log" && s.config.Retention.ChangeLogMonths > 0:
+		months = s.config.Retention.ChangeLogMonths
+	}
+	return max(months, 1)
+}
+
+// Run pre-creates upcoming partitions, archives and drops partitions older than their table's
+// retention window, then prunes old token usage and snapshots. With dryRun, nothing is written
+// or dropped and the report lists what would be purged.
+func (s *Service) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	if err := s.queries.EnsureAuditPartitions(ctx, partitionsAhead); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	report := &Report{
+		CutoffMonth: month.AddDate(0, -max(s.config.Retention.AuditMonths, 1), 0),
+		Cutoffs:     map[string]time.Time{},
+		DryRun:      dryRun,
+		Archived:    []models.AuditArchive{},
+		Purged:      []string{},
+		Snapshots:   []uuid.UUID{},
+	}
+
+	for _, table := range db.AuditTables {
+		cutoff := month.AddDate(0, -s.auditMonths(table), 0)
+		report.Cutoffs[table] = cutoff
+		partitions, err := s.queries.ListAuditPartitions(ctx, table)
+		if err != nil {
+			return report, fmt.Errorf("list partitions %s: %w", table, err)
+		}
+
+		for _, p := range partitions {
+			if !p.MonthStart.Before(cutoff) {
+				continue
+			}
+			if dryRun {
+				report.Purged = append(report.Purged, p.Name)
+				continue
+			}
+
+			if s.config.Retention.Archive {
+				archive, err := s.archivePartition(ctx, p)
+				if err != nil {
+					// Never drop data that could not be exported
+					return report, fmt.Errorf("archive %s: %w", p.Name, err)
+				}
+				report.Archived = append(report.Archived, *archive)
+			}
+
+			if err := s.queries.DropAuditPartition(ctx, p.Table, p.Name); err != nil {
+				return report, fmt.Errorf("drop %s: %w", p.Name, err)
+			}
+			report.Purged = append(report.Purged, p.Name)
+		}
+	}
+
+	if months := s.config.Retention.TokenUsageMonths; months > 0 {
+		n, err := s.queries.PurgeTokenUsage(ctx, month.AddDate(0, -months, 0), dryRun)
+		if err != nil {
+			return report, fmt.Errorf("prune token usage: %w", err)
+		}
+		report.TokenUsage = n
+	}
+	if days := s.config.Retention.SnapshotDays; days > 0 {
+		ids, err := s.queries.PurgeSnapshots(ctx, now.AddDate(0, 0, -days), max(s.config.Retention.SnapshotsKept, 0), dryRun)
+		if err != nil {
+			return report, fmt.Errorf("prune snapshots: %w", err)
+		}
+		report.Snapshots = append(report.Snapshots, ids...)
+	}
+
+	return report, nil
+}
+
+func (s *Service) archivePartition(ctx context.Context, p models.AuditPartition) (*models.AuditArchive, error) {
+	location, rows, err := s.archiver.Archive(ctx, p.Table, p.Name, func(w io.Writer) (int64, error) {
+		return s.queries.ExportAuditPartition(ctx, p.Name, w)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	archive := models.AuditArchive{
+		ID:            uuid.New(),
+		TableName:     p.Table,
+		PartitionName: p.Name,
+		MonthStart:    p.MonthStart,
+		RowCount:      rows,
+		Location:      location,
+		Purged:        true,
+		CreatedAt:     time.Now(),
+	}
+	if err := s.queries.CreateAuditArchive(ctx, archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// LocalArchiver writes gzipped JSON-lines files under Root/<table>/<partition>.jsonl.gz
+type LocalArchiver struct {
+	Root string
+}
+
+// Archive exports a partition to a local gzip file, replacing any previous export atomically
+func (l *LocalArchiver) Archive(ctx context.Context, table, partition string, write func(w io.Writer) (int64, error)) (string, int64, error) {
+	dir := filepath.Join(l.Root, table)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, err
+	}
+
+	path := filepath.Join(dir, partition+".jsonl.gz")
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp)
+
+	gz := gzip.NewWriter(f)
+	rows, err := write(gz)
+	if err != nil {
+		f.Close()
+		return "", rows, err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return "", rows, err
+	}
+	if err := f.Close(); err != nil {
+		return "", rows, err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return "", rows, err
+	}
+	return path, rows, nil
+}
@@ -0,0 +1,219 @@
+// Package retry provides the shared retry/backoff policy for outbound calls
+// (LLM providers, web search providers, page fetches). It works at the HTTP transport level so
+// every client built here gets the same behaviour: exponential backoff with
+// jitter on 429/5xx and network errors, honouring Retry-After headers.
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+)
+
+// Policy controls how many times and how patiently a request is retried
+type Policy struct {
+	MaxAttempts int           // total attempts including the first one
+	BaseDelay   time.Duration // delay before the first retry, doubled each time
+	MaxDelay    time.Duration // cap on a single delay (also on Retry-After)
+}
+
+// PolicyFromConfig builds the policy from RETRY_* settings
+func PolicyFromConfig(cfg *config.Config) Policy {
+	return Policy{
+		MaxAttempts: cfg.Retry.MaxAttempts,
+		BaseDelay:   cfg.Retry.BaseDelay,
+		MaxDelay:    cfg.Retry.MaxDelay,
+	}
+}
+
+// Backoff returns the delay before retry n (1-based) using exponential backoff
+// with equal jitter: half the window is fixed, the other half random.
+func (p Policy) Backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// ===== RETRY COUNTERS =====
+
+// Counter accumulates the retries performed by requests made with its context.
+// Counters nest: a retry is also counted by every parent counter.
+type Counter struct {
+	n      atomic.Int64
+	parent *Counter
+}
+
+type counterKey struct{}
+
+// WithCounter returns a context whose outbound retries are counted
+func WithCounter(ctx context.Context) (context.Context, *Counter) {
+	c := &Counter{parent: counterFrom(ctx)}
+	return context.WithValue(ctx, counterKey{}, c), c
+}
+
+func counterFrom(ctx context.Context) *Counter {
+	c, _ := ctx.Value(counterKey{}).(*Counter)
+	return c
+}
+
+// Value returns the number of retries counted so far
+func (c *Counter) Value() int {
+	if c == nil {
+		return 0
+	}
+	return int(c.n.Load())
+}
+
+func (c *Counter) add() {
+	for ; c != nil; c = c.parent {
+		c.n.Add(1)
+	}
+}
+
+// Count returns the retries counted on the context's counter (0 if none)
+func Count(ctx context.Context) int {
+	return counterFrom(ctx).Value()
+}
+
+// ===== TRANSPORT =====
+
+// Transport is an http.RoundTripper that retries transient failures
+type Transport struct {
+	Base   http.RoundTripper
+	Policy Policy
+}
+
+// RoundTrip sends req, then a clone of it for each retry with its body rewound by GetBody:
+// the caller's request is never modified, as http.RoundTripper requires
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx := req.Context()
+	// A body without GetBody is consumed by the first attempt and can't be sent again
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(ctx)
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := base.RoundTrip(attemptReq)
+		if attempt >= t.Policy.MaxAttempts || !rewindable || ctx.Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+
+		delay := t.Policy.Backoff(attempt)
+		if wait, ok := retryAfter(resp); ok {
+			if t.Policy.MaxDelay > 0 && wait > t.Policy.MaxDelay {
+				// The server asks for longer than we are willing to wait
+				return resp, err
+			}
+			delay = wait
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		counterFrom(ctx).add()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// statusOverloaded is Anthropic's "overloaded_error", sent when the API is at capacity
+const statusOverloaded = 529
+
+// retryable reports whether a response or transport error is worth retrying
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return !quotaExhausted(resp)
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+		statusOverloaded:
+		return true
+	}
+	return false
+}
+
+// quotaExhausted detects OpenAI's "insufficient_quota" 429, which never clears on retry.
+// The body is restored so the caller can still read the error.
+func quotaExhausted(resp *http.Response) bool {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return bytes.Contains(body, []byte("insufficient_quota"))
+}
+
+// retryAfter parses Retry-After (seconds or HTTP date) and OpenAI's retry-after-ms
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	if ms := resp.Header.Get("Retry-After-Ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v >= 0 {
+			return time.Duration(v * float64(time.Millisecond)), true
+		}
+	}
+	ra := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if ra == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(ra); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(ra); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// ===== CLIENTS =====
+
+// NewHTTPClient returns an http.Client with the shared retry policy.
+// The timeout applies to the whole call, retries included.
+func NewHTTPClient(cfg *config.Config, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &Transport{Policy: PolicyFromConfig(cfg)},
+	}
+}
@@ -0,0 +1,77 @@
+package retry
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc answers requests with a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func answer(status int) *http.Response {
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+func TestTransportRetriesWithClones(t *testing.T) {
+	var sent []*http.Request
+	var bodies []string
+	transport := &Transport{
+		Policy: Policy{MaxAttempts: 3},
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			sent, bodies = append(sent, req), append(bodies, string(body))
+			if len(sent) < 3 {
+				return answer(http.StatusServiceUnavailable), nil
+			}
+			return answer(http.StatusOK), nil
+		}),
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.example.com/v1", strings.NewReader(`{"a":1}`))
+	body := req.Body
+	resp, err := transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %v, %v", resp, err)
+	}
+	if len(sent) != 3 {
+		t.Fatalf("%d attempts, want 3", len(sent))
+	}
+	for i, b := range bodies {
+		if b != `{"a":1}` {
+			t.Errorf("attempt %d sent %q", i+1, b)
+		}
+	}
+	if sent[1] == req || sent[2] == req {
+		t.Error("retries reused the caller's request")
+	}
+	if req.Body != body {
+		t.Error("the caller's request body was replaced")
+	}
+}
+
+func TestTransportBodyNotRewindable(t *testing.T) {
+	attempts := 0
+	transport := &Transport{
+		Policy: Policy{MaxAttempts: 3},
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			io.Copy(io.Discard, req.Body)
+			return nil, errors.New("connection reset")
+		}),
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.example.com/v1", io.NopCloser(strings.NewReader("x")))
+	if _, err := transport.RoundTrip(req); err == nil || err.Error() != "connection reset" {
+		t.Fatalf("err = %v, want the attempt's error", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("%d attempts, want 1", attempts)
+	}
+}
@@ -0,0 +1,213 @@
+// Package shadow runs a candidate model/prompt alongside the primary agent on a
+// sample of products. Candidate proposals go to a separate evaluation bucket
+// (shadow_runs / shadow_proposals) and are compared with the primary output,
+// so a model upgrade can be validated on real traffic without reviewers ever
+// seeing its proposals.
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/agent"
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/db"
+	"github.com/benjamincozon/feedenrich/internal/models"
+	"github.com/google/uuid"
+)
+
+// Evaluator samples products and runs the shadow variant on them
+type Evaluator struct {
+	config  *config.Config
+	queries *db.Queries
+	agent   *agent.Agent
+	variant string
+	slots   chan struct{} // bounds concurrent shadow runs
+}
+
+// New creates an evaluator from SHADOW_* settings. It returns nil when shadow mode is
+// disabled; a nil *Evaluator is safe to use and never samples.
+func New(cfg *config.Config, queries *db.Queries, agnt *agent.Agent) *Evaluator {
+	if !cfg.Shadow.Enabled || (cfg.Shadow.Model == "" && cfg.Shadow.Instructions == "") {
+		return nil
+	}
+
+	candidate := agnt.Variant(cfg.Shadow.Model, cfg.Shadow.Instructions)
+	variant := cfg.Shadow.Variant
+	if variant == "" {
+		variant = candidate.Model()
+		if cfg.Shadow.Instructions != "" {
+			variant += "+instructions"
+		}
+	}
+	slots := cfg.Shadow.MaxConcurrent
+	if slots < 1 {
+		slots = 1
+	}
+	return &Evaluator{
+		config:  cfg,
+		queries: queries,
+		agent:   candidate,
+		variant: variant,
+		slots:   make(chan struct{}, slots),
+	}
+}
+
+// Variant returns the label shadow runs are stored under ("" when disabled)
+func (e *Evaluator) Variant() string {
+	if e == nil {
+		return ""
+	}
+	return e.variant
+}
+
+// Run is an in-flight shadow evaluation of one product
+type Run struct {
+	primary chan primaryResult
+}
+
+type primaryResult struct {
+	proposals []models.Proposal
+	err       error
+}
+
+// Start samples the product and, if selected, launches the shadow run in parallel
+// with the primary one. It never blocks: when all slots are busy the product is
+// simply not sampled. Returns nil when not sampled.
+func (e *Evaluator) Start(product *models.Product, group agent.OptimizationGroup, jobID *uuid.UUID) *Run {
+	if e == nil || rand.Float64() >= e.config.Shadow.SampleRate {
+		return nil
+	}
+	select {
+	case e.slots <- struct{}{}:
+	default:
+		return nil
+	}
+
+	run := &Run{primary: make(chan primaryResult, 1)}
+	go func() {
+		defer func() { <-e.slots }()
+		e.execute(product, group, jobID, run)
+	}()
+	return run
+}
+
+// Complete hands the primary result to the shadow run for comparison. Safe on nil.
+func (r *Run) Complete(proposals []models.Proposal, err error) {
+	if r == nil {
+		return
+	}
+	r.primary <- primaryResult{proposals: proposals, err: err}
+}
+
+func (e *Evaluator) execute(product *models.Product, group agent.OptimizationGroup, jobID *uuid.UUID, run *Run) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Agent.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	session, err := e.agent.RunWithGroup(ctx, product, "Shadow: "+string(group), group)
+	duration := time.Since(started)
+
+	// Wait for the primary run; give up if it never reports back
+	var primary primaryResult
+	select {
+	case primary = <-run.primary:
+	case <-time.After(e.config.Agent.Timeout + time.Minute):
+		primary.err = fmt.Errorf("primary result not received")
+	}
+	if primary.err != nil {
+		// Nothing to compare against: don't pollute the evaluation bucket
+		fmt.Printf("Shadow %s: skipping product %s, primary run failed: %v\n", e.variant, product.ID, primary.err)
+		return
+	}
+
+	result := models.ShadowRun{
+		ID:           uuid.New(),
+		ProductID:    product.ID,
+		JobID:        jobID,
+		Module:       string(group),
+		Variant:      e.variant,
+		Model:        e.agent.Model(),
+		Status:       "completed",
+		PrimaryCount: len(primary.proposals),
+		DurationMs:   int(duration.Milliseconds()),
+		CreatedAt:    time.Now(),
+	}
+	result.PrimaryProposals, _ = json.Marshal(summarize(primary.proposals))
+
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	} else {
+		compare(&result, session.Proposals, primary.proposals)
+	}
+
+	if err := e.queries.CreateShadowRun(context.Background(), result); err != nil {
+		fmt.Printf("Failed to save shadow run for product %s: %v\n", product.ID, err)
+		return
+	}
+	fmt.Printf("Shadow %s: product %s - %d shadow / %d primary proposals, %d agreed\n",
+		e.variant, product.ID, result.ShadowCount, result.PrimaryCount, result.Agreed)
+}
+
+type proposalSummary struct {
+	Field      string  `json:"field"`
+	AfterValue string  `json:"after_value"`
+	Confidence float64 `json:"confidence"`
+}
+
+func summarize(proposals []models.Proposal) []proposalSummary {
+	out := make([]proposalSummary, 0, len(proposals))
+	for _, p := range proposals {
+		out = append(out, proposalSummary{Field: p.Field, AfterValue: p.AfterValue, Confidence: p.Confidence})
+	}
+	return out
+}
+
+// compare fills the shadow proposals and agreement counters of a run
+func compare(run *models.ShadowRun, shadow, primary []models.Proposal) {
+	primaryValues := map[string][]string{}
+	for _, p := range primary {
+		primaryValues[p.Field] = append(primaryValues[p.Field], normalize(p.AfterValue))
+	}
+
+	overlap := map[string]bool{}
+	agreed := map[string]bool{}
+	for _, p := range shadow {
+		matches := false
+		if values, ok := primaryValues[p.Field]; ok {
+			overlap[p.Field] = true
+			for _, v := range values {
+				if v == normalize(p.AfterValue) {
+					matches = true
+					agreed[p.Field] = true
+					break
+				}
+			}
+		}
+		run.Proposals = append(run.Proposals, models.ShadowProposal{
+			ID:             uuid.New(),
+			RunID:          run.ID,
+			Field:          p.Field,
+			BeforeValue:    p.BeforeValue,
+			AfterValue:     p.AfterValue,
+			Rationale:      p.Rationale,
+			Confidence:     p.Confidence,
+			RiskLevel:      p.RiskLevel,
+			MatchesPrimary: matches,
+			CreatedAt:      run.CreatedAt,
+		})
+	}
+
+	run.ShadowCount = len(shadow)
+	run.FieldOverlap = len(overlap)
+	run.Agreed = len(agreed)
+}
+
+func normalize(v string) string {
+	return strings.ToLower(strings.Join(strings.Fields(v), " "))
+}
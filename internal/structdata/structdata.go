@@ -0,0 +1,395 @@
+// Package structdata reads the product facts a page publishes as structured data: JSON-LD
+// (schema.org Product blocks), microdata and OpenGraph product tags. Merchants write them for
+// search engines, so they are exact where an LLM reading the page text can misread or invent;
+// every fact keeps where it was read.
+package structdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Fact is a product attribute read from a page
+type Fact struct {
+	Field  string `json:"field"`  // GMC attribute: brand, gtin, price, color...
+	Value  string `json:"value"`  // prices are "29.99 EUR"
+	Source string `json:"source"` // json-ld, microdata, opengraph
+	Path   string `json:"path"`   // where it was read: "Product.offers.price", "itemprop=gtin13", "og:title"
+}
+
+// Sources in priority order: when several give a field, the first one wins
+const (
+	SourceJSONLD    = "json-ld"
+	SourceMicrodata = "microdata"
+	SourceOpenGraph = "opengraph"
+)
+
+// Confidence is how reliable the facts of a source are: JSON-LD is written for search
+// engines, OpenGraph for link previews
+func Confidence(source string) float64 {
+	switch source {
+	case SourceJSONLD:
+		return 0.95
+	case SourceMicrodata:
+		return 0.9
+	}
+	return 0.85
+}
+
+// Extract returns the product facts of an HTML page, one per field, by source priority.
+// Pages without structured data give none.
+func Extract(body []byte) []Fact {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	var jsonLD, micro []Fact
+	metas := map[string]string{}
+	walk(doc, func(n *html.Node) bool {
+		switch {
+		case n.Data == "script" && strings.EqualFold(attr(n, "type"), "application/ld+json"):
+			jsonLD = append(jsonLD, readJSONLD(text(n))...)
+			return false
+		case n.Data == "meta":
+			property := attr(n, "property")
+			if property == "" {
+				property = attr(n, "name")
+			}
+			property = strings.ToLower(strings.TrimSpace(property))
+			if _, ok := metas[property]; !ok && property != "" {
+				metas[property] = attr(n, "content")
+			}
+		case hasAttr(n, "itemscope") && isProductType(attr(n, "itemtype")):
+			micro = append(micro, readMicrodata(n, "")...)
+			return false
+		}
+		return true
+	})
+
+	var facts []Fact
+	seen := map[string]bool{}
+	for _, group := range [][]Fact{jsonLD, micro, readOpenGraph(metas)} {
+		for _, f := range group {
+			f.Value = strings.TrimSpace(f.Value)
+			if f.Value == "" || seen[f.Field] {
+				continue
+			}
+			seen[f.Field] = true
+			facts = append(facts, f)
+		}
+	}
+	return facts
+}
+
+// productFields maps schema.org Product properties to GMC attributes
+var productFields = map[string]string{
+	"name": "title", "description": "description", "image": "image_link", "color": "color",
+	"material": "material", "size": "size", "mpn": "mpn", "sku": "sku", "category": "product_type",
+	"gtin": "gtin", "gtin8": "gtin", "gtin12": "gtin", "gtin13": "gtin", "gtin14": "gtin",
+	"itemCondition": "condition", "pattern": "pattern", "audience": "age_group",
+}
+
+// offerFields maps schema.org Offer properties to GMC attributes; the price is read with
+// its currency
+var offerFields = map[string]string{"availability": "availability", "itemCondition": "condition"}
+
+// readJSONLD reads the Product items of a JSON-LD block, in @graph lists and arrays too
+func readJSONLD(raw string) []Fact {
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil
+	}
+	var facts []Fact
+	var visit func(v any)
+	visit = func(v any) {
+		switch t := v.(type) {
+		case []any:
+			for _, item := range t {
+				visit(item)
+			}
+		case map[string]any:
+			if isProductType(typeOf(t)) {
+				facts = append(facts, productFacts(t)...)
+				return
+			}
+			visit(t["@graph"])
+			visit(t["mainEntity"])
+		}
+	}
+	visit(v)
+	return facts
+}
+
+func productFacts(p map[string]any) []Fact {
+	fact := func(field, value, path string) Fact {
+		return Fact{Field: field, Value: value, Source: SourceJSONLD, Path: "Product." + path}
+	}
+	var facts []Fact
+	for _, prop := range sortedKeys(p) {
+		if field, ok := productFields[prop]; ok {
+			if s := scalar(p[prop]); s != "" {
+				facts = append(facts, fact(field, s, prop))
+			}
+		}
+	}
+	for _, prop := range []string{"brand", "manufacturer"} {
+		if brand := scalar(p[prop]); brand != "" {
+			if _, ok := p[prop].(map[string]any); ok {
+				prop += ".name"
+			}
+			facts = append(facts, fact("brand", brand, prop))
+			break
+		}
+	}
+
+	offers := p["offers"]
+	if list, ok := offers.([]any); ok && len(list) > 0 {
+		offers = list[0]
+	}
+	if offer, ok := offers.(map[string]any); ok {
+		price, path := scalar(offer["price"]), "offers.price"
+		if price == "" {
+			price, path = scalar(offer["lowPrice"]), "offers.lowPrice"
+		}
+		if price == "" {
+			if spec, ok := offer["priceSpecification"].(map[string]any); ok {
+				price, path = scalar(spec["price"]), "offers.priceSpecification.price"
+				if offer["priceCurrency"] == nil {
+					offer["priceCurrency"] = spec["priceCurrency"]
+				}
+			}
+		}
+		if price != "" {
+			facts = append(facts, fact("price", withCurrency(price, scalar(offer["priceCurrency"])), path))
+		}
+		for _, prop := range sortedKeys(offer) {
+			if field, ok := offerFields[prop]; ok {
+				if s := scalar(offer[prop]); s != "" {
+					facts = append(facts, fact(field, s, "offers."+prop))
+				}
+			}
+		}
+	}
+	return facts
+}
+
+// readMicrodata reads the itemprops of a Product itemscope, and of its brand and offers
+// scopes
+func readMicrodata(scope *html.Node, prefix string) []Fact {
+	var facts []Fact
+	var price, currency, pricePath string
+	walk(scope, func(n *html.Node) bool {
+		if n == scope {
+			return true
+		}
+		prop := attr(n, "itemprop")
+		nested := hasAttr(n, "itemscope")
+		switch {
+		case prop == "brand" || prop == "manufacturer":
+			value := itemValue(n)
+			if nested {
+				value = nestedProp(n, "name")
+			}
+			facts = append(facts, Fact{Field: "brand", Value: value, Source: SourceMicrodata, Path: "itemprop=" + prefix + prop})
+		case prop == "offers" && nested:
+			facts = append(facts, readMicrodata(n, "offers.")...)
+		case prop == "price" || prop == "lowPrice":
+			if price == "" {
+				price, pricePath = itemValue(n), prop
+			}
+		case prop == "priceCurrency":
+			currency = itemValue(n)
+		case prefix != "" && offerFields[prop] != "":
+			facts = append(facts, Fact{Field: offerFields[prop], Value: itemValue(n), Source: SourceMicrodata, Path: "itemprop=" + prefix + prop})
+		case prefix == "" && productFields[prop] != "":
+			facts = append(facts, Fact{Field: productFields[prop], Value: itemValue(n), Source: SourceMicrodata, Path: "itemprop=" + prop})
+		}
+		// the properties of a nested item are its own
+		return !nested
+	})
+	if price != "" {
+		facts = append(facts, Fact{Field: "price", Value: withCurrency(price, currency), Source: SourceMicrodata, Path: "itemprop=" + prefix + pricePath})
+	}
+	return facts
+}
+
+// nestedProp is the value of an itemprop inside an item ("name" of a Brand)
+func nestedProp(scope *html.Node, prop string) string {
+	var value string
+	walk(scope, func(n *html.Node) bool {
+		if value == "" && n != scope && attr(n, "itemprop") == prop {
+			value = itemValue(n)
+		}
+		return value == ""
+	})
+	return value
+}
+
+// itemValue is the value of an itemprop element: its content, the link of links and
+// media, its text otherwise
+func itemValue(n *html.Node) string {
+	if hasAttr(n, "content") {
+		return attr(n, "content")
+	}
+	switch n.Data {
+	case "a", "link":
+		return attr(n, "href")
+	case "img", "source":
+		return attr(n, "src")
+	case "meta":
+		return attr(n, "content")
+	case "time":
+		if hasAttr(n, "datetime") {
+			return attr(n, "datetime")
+		}
+	}
+	return strings.Join(strings.Fields(text(n)), " ")
+}
+
+// ogFields maps OpenGraph and product meta properties to GMC attributes
+var ogFields = map[string]string{
+	"og:title": "title", "og:description": "description", "og:image": "image_link",
+	"product:brand": "brand", "og:brand": "brand", "product:availability": "availability",
+	"og:availability": "availability", "product:condition": "condition", "product:color": "color",
+	"product:material": "material", "product:size": "size", "product:pattern": "pattern",
+	"product:ean": "gtin", "product:upc": "gtin", "product:gtin": "gtin", "product:mfr_part_no": "mpn",
+	"product:age_group": "age_group", "product:gender": "gender",
+}
+
+// ogPrices are the price tags of OpenGraph and their currency tags
+var ogPrices = [][2]string{
+	{"product:price:amount", "product:price:currency"},
+	{"og:price:amount", "og:price:currency"},
+}
+
+// readOpenGraph reads the product facts of a page's meta tags (property → content)
+func readOpenGraph(metas map[string]string) []Fact {
+	var facts []Fact
+	for _, property := range sortedKeys(metas) {
+		if field, ok := ogFields[property]; ok {
+			facts = append(facts, Fact{Field: field, Value: metas[property], Source: SourceOpenGraph, Path: property})
+		}
+	}
+	for _, tags := range ogPrices {
+		if price := metas[tags[0]]; strings.TrimSpace(price) != "" {
+			facts = append(facts, Fact{Field: "price", Value: withCurrency(price, metas[tags[1]]), Source: SourceOpenGraph, Path: tags[0]})
+		}
+	}
+	return facts
+}
+
+// withCurrency writes a price with its currency, "29.99 EUR"
+func withCurrency(price, currency string) string {
+	price = strings.TrimSpace(price)
+	if currency = strings.ToUpper(strings.TrimSpace(currency)); currency != "" && !strings.Contains(strings.ToUpper(price), currency) {
+		return price + " " + currency
+	}
+	return price
+}
+
+// isProductType reports a schema.org Product type: "Product", "schema:Product",
+// "https://schema.org/Product", or a list holding one
+func isProductType(t string) bool {
+	for _, s := range strings.Fields(t) {
+		s = s[strings.LastIndexAny(s, "/:")+1:]
+		if s == "Product" || s == "ProductModel" || s == "IndividualProduct" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf reads the @type of a JSON-LD item, a string or a list
+func typeOf(item map[string]any) string {
+	switch t := item["@type"].(type) {
+	case string:
+		return t
+	case []any:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return strings.Join(types, " ")
+	}
+	return ""
+}
+
+// scalar reads a JSON-LD value as text: strings and numbers as they are, the name, @id or
+// url of an item, the first element of a list
+func scalar(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case []any:
+		if len(t) > 0 {
+			return scalar(t[0])
+		}
+	case map[string]any:
+		for _, key := range []string{"name", "value", "url", "@id"} {
+			if s := scalar(t[key]); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// sortedKeys lists the keys of a map in order, so facts come out the same on every read
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// walk visits the nodes of a tree depth first; children are skipped when visit returns false
+func walk(n *html.Node, visit func(*html.Node) bool) {
+	if n.Type == html.ElementNode && !visit(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, visit)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// text is the text content of a node
+func text(n *html.Node) string {
+	var b strings.Builder
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(n)
+	return strings.TrimSpace(b.String())
+}
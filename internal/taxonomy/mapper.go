@@ -0,0 +1,252 @@
+package taxonomy
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Input is what the mapper reads from a product
+type Input struct {
+	Category    string // current google_product_category, if any
+	ProductType string // merchant category path ("Femme > Vêtements > Robes")
+	Title       string
+}
+
+// Match is the category found for a product
+type Match struct {
+	Category Category `json:"category"`
+	Score    float64  `json:"score"` // 1 for feed and product_type matches, the similarity otherwise
+	// feed: the current value is a valid category; product_type: a segment of the product
+	// type names a category; similarity: closest category by vector similarity
+	Method string `json:"method"`
+}
+
+// Map finds the google_product_category of a product, deterministically:
+//  1. the current value, when it is an ID or path of the taxonomy;
+//  2. the deepest product_type segment naming a category ("Robes" → Dresses), French
+//     or English; when several branches have that name, the one sharing most words with
+//     the other segments;
+//  3. the category whose path vector is closest to the product type and title vector,
+//     when the cosine similarity is at least minScore.
+//
+// Vectors are local embeddings (words and character trigrams), no model is called.
+func (t *Taxonomy) Map(in Input, minScore float64) (Match, bool) {
+	if c, ok := t.Lookup(in.Category); ok && in.Category != "" {
+		return Match{Category: c, Score: 1, Method: "feed"}, true
+	}
+
+	segments := splitPath(strings.NewReplacer("/", ">", "|", ">").Replace(in.ProductType))
+	for i := len(segments) - 1; i >= 0; i-- {
+		candidates := t.byLeaf[strings.Join(terms(segments[i]), " ")]
+		if len(candidates) == 0 {
+			continue
+		}
+		context := terms(strings.Join(segments[:i], " ") + " " + in.Title)
+		best, bestShared := candidates[0], -1
+		for _, c := range candidates {
+			if shared := sharedTerms(categoryTerms(t.categories[c]), context); shared > bestShared {
+				best, bestShared = c, shared
+			}
+		}
+		return Match{Category: t.categories[best], Score: 1, Method: "product_type"}, true
+	}
+
+	product := vector{}
+	for i, segment := range segments {
+		product.add(terms(segment), math.Pow(0.5, float64(len(segments)-1-i)))
+	}
+	product.add(terms(in.Title), 0.6)
+	product.normalize()
+	if len(product) == 0 {
+		return Match{}, false
+	}
+
+	best, bestScore := -1, 0.0
+	for i, v := range t.vectors {
+		score := product.dot(v)
+		// ties go to the deeper, more precise category
+		if score > bestScore+1e-9 || (best >= 0 && math.Abs(score-bestScore) <= 1e-9 && len(t.categories[i].Path) > len(t.categories[best].Path)) {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 || bestScore < minScore {
+		return Match{}, false
+	}
+	return Match{Category: t.categories[best], Score: math.Round(bestScore*100) / 100, Method: "similarity"}, true
+}
+
+// leafKeys are the names a category answers to in product types: its leaf and each of
+// its alternatives ("Coats & Jackets" → "coat jacket", "coat", "jacket")
+func leafKeys(c Category) []string {
+	leaf := c.Path[len(c.Path)-1]
+	keys := []string{strings.Join(terms(leaf), " ")}
+	for _, alt := range strings.FieldsFunc(leaf, func(r rune) bool { return r == '&' || r == ',' }) {
+		if key := strings.Join(terms(alt), " "); key != "" && key != keys[0] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func categoryTerms(c Category) []string {
+	return terms(c.Name())
+}
+
+func sharedTerms(a, b []string) int {
+	set := map[string]bool{}
+	for _, t := range b {
+		set[t] = true
+	}
+	n := 0
+	for _, t := range a {
+		if set[t] {
+			n++
+		}
+	}
+	return n
+}
+
+// vector is a sparse embedding: word and character trigram features
+type vector map[string]float64
+
+// categoryVector weighs the leaf of a category most, then each ancestor half as much
+func categoryVector(c Category) vector {
+	v := vector{}
+	for i, segment := range c.Path {
+		v.add(terms(segment), math.Pow(0.5, float64(len(c.Path)-1-i)))
+	}
+	v.normalize()
+	return v
+}
+
+func (v vector) add(words []string, weight float64) {
+	for _, w := range words {
+		v["w:"+w] += weight
+		padded := " " + w + " "
+		for i := 0; i+3 <= len(padded); i++ {
+			v[padded[i:i+3]] += weight * 0.3
+		}
+	}
+}
+
+func (v vector) normalize() {
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for k := range v {
+		v[k] /= norm
+	}
+}
+
+func (v vector) dot(o vector) float64 {
+	if len(o) < len(v) {
+		v, o = o, v
+	}
+	sum := 0.0
+	for k, x := range v {
+		sum += x * o[k]
+	}
+	return sum
+}
+
+// stopWords are left out of terms, in English and French
+var stopWords = map[string]bool{
+	"and": true, "for": true, "the": true, "with": true, "of": true, "other": true,
+	"de": true, "du": true, "des": true, "la": true, "le": true, "les": true, "et": true,
+	"pour": true, "en": true, "au": true, "aux": true, "avec": true, "un": true, "une": true,
+}
+
+// frenchPhrases are translated before single words, on singular forms
+var frenchPhrases = []struct{ from, to string }{
+	{"sac a dos", "backpacks"}, {"sac a main", "handbags"}, {"lunette de soleil", "sunglasses"},
+	{"ordinateur portable", "laptops"}, {"telephone portable", "mobile phones"},
+	{"maillot de bain", "swimwear"}, {"machine a cafe", "coffee makers"},
+	{"appareil photo", "cameras"}, {"console de jeu", "video game consoles"},
+	{"boucle d oreille", "earrings"}, {"linge de lit", "bedding"}, {"eau de toilette", "perfume"},
+	{"eau de parfum", "perfume"}, {"sous vetement", "underwear"}, {"soin visage", "skin care"},
+	{"soin cheveux", "hair care"},
+}
+
+// frenchWords translates the common product words of French feeds, on singular forms
+var frenchWords = map[string]string{
+	"robe": "dresses", "chemise": "shirts", "chemisier": "shirts", "haut": "tops", "pantalon": "pants",
+	"jean": "pants", "jupe": "skirts", "manteau": "coats", "veste": "jackets", "blouson": "jackets",
+	"doudoune": "coats", "pyjama": "sleepwear", "chaussure": "shoes", "basket": "shoes", "sneaker": "shoes",
+	"botte": "shoes", "bottine": "shoes", "sandale": "shoes", "escarpin": "shoes", "sac": "handbags",
+	"pochette": "handbags", "montre": "watches", "collier": "necklaces", "bague": "rings",
+	"ceinture": "belts", "chapeau": "hats", "casquette": "hats", "bonnet": "hats", "echarpe": "scarves",
+	"foulard": "scarves", "scarf": "scarves", "gant": "gloves", "chaussette": "socks", "lingerie": "underwear",
+	"costume": "suits", "vetement": "clothing", "accessoire": "accessories", "bijou": "jewelry", "bijoux": "jewelry",
+	"ordinateur": "computers", "smartphone": "mobile phones", "telephone": "phones", "tablette": "tablet",
+	"televiseur": "televisions", "television": "televisions", "tv": "televisions", "enceinte": "audio",
+	"cafetiere": "coffee makers", "expresso": "espresso", "aspirateur": "vacuums", "chaise": "chairs",
+	"fauteuil": "chairs", "canape": "sofas", "lampe": "lighting", "luminaire": "lighting",
+	"decoration": "decor", "literie": "bedding", "parfum": "perfume", "maquillage": "cosmetics",
+	"cosmetique": "cosmetics", "shampoing": "hair care", "jouet": "toys", "jeu": "games", "velo": "cycling",
+	"chien": "dog", "chat": "cat", "livre": "books", "outil": "tools", "valise": "luggage",
+	"jardin": "garden", "maison": "home", "boisson": "beverages", "cuisine": "kitchen", "meuble": "furniture",
+	"femme": "women", "homme": "men", "enfant": "kids",
+}
+
+// terms are the normalized words of a text: lower-case without accents, singular,
+// French translated to the taxonomy's English, stop words and single letters left out
+func terms(text string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(foldAccents(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words = append(words, singular(w))
+	}
+	joined := " " + strings.Join(words, " ") + " "
+	for _, p := range frenchPhrases {
+		joined = strings.ReplaceAll(joined, " "+p.from+" ", " "+p.to+" ")
+	}
+
+	var out []string
+	for _, w := range strings.Fields(joined) {
+		if en, ok := frenchWords[w]; ok {
+			w = en
+		}
+		for _, word := range strings.Fields(w) {
+			word = singular(word)
+			if len(word) > 1 && !stopWords[word] {
+				out = append(out, word)
+			}
+		}
+	}
+	return out
+}
+
+// singular strips English and French plural endings; it only needs to be consistent
+// between product types and category names
+func singular(w string) string {
+	switch {
+	case len(w) <= 3:
+		return w
+	case strings.HasSuffix(w, "eaux"), strings.HasSuffix(w, "eux"):
+		return strings.TrimSuffix(w, "x")
+	case strings.HasSuffix(w, "ies"):
+		return strings.TrimSuffix(w, "ies") + "y"
+	case strings.HasSuffix(w, "sses"), strings.HasSuffix(w, "shes"), strings.HasSuffix(w, "ches"), strings.HasSuffix(w, "xes"):
+		return strings.TrimSuffix(w, "es")
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us"):
+		return strings.TrimSuffix(w, "s")
+	}
+	return w
+}
+
+var accentFolder = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "á", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "í", "i", "ô", "o", "ö", "o", "ó", "o", "ù", "u", "û", "u", "ü", "u", "ú", "u",
+	"ÿ", "y", "ñ", "n", "œ", "oe", "æ", "ae",
+)
+
+func foldAccents(s string) string {
+	return accentFolder.Replace(s)
+}
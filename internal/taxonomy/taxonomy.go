@@ -0,0 +1,168 @@
+// Package taxonomy maps products to the Google product taxonomy without a model call.
+// Models asked for a google_product_category often invent paths that don't exist; the
+// mapper only ever returns categories of the loaded taxonomy file.
+package taxonomy
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The repository has an excerpt; builds of the Docker image replace it with the full file
+//go:generate curl -fsSL -o taxonomy-with-ids.en-US.txt https://www.google.com/basepages/producttype/taxonomy-with-ids.en-US.txt
+
+//go:embed taxonomy-with-ids.en-US.txt
+var bundled string
+
+// Category is an entry of the taxonomy: "5598 - Apparel & Accessories > Clothing > Outerwear > Coats & Jackets"
+type Category struct {
+	ID   int      `json:"id"`
+	Path []string `json:"path"`
+}
+
+// Name is the full path of the category, as GMC writes it
+func (c Category) Name() string {
+	return strings.Join(c.Path, " > ")
+}
+
+// Taxonomy is a loaded taxonomy file with its lookup indexes and the vectors of the mapper
+type Taxonomy struct {
+	categories []Category
+	byID       map[int]int
+	byName     map[string]int   // lower-case full path
+	byLeaf     map[string][]int // leafKeys, several categories when a name appears in many branches
+	vectors    []vector
+	excerpt    bool // the file is marked "# Excerpt", see Excerpt
+}
+
+// Bundled returns the taxonomy compiled into the binary, parsed once
+var Bundled = sync.OnceValue(func() *Taxonomy {
+	t, err := Parse(strings.NewReader(bundled))
+	if err != nil {
+		panic(fmt.Sprintf("bundled taxonomy: %v", err))
+	}
+	return t
+})
+
+// Open loads the taxonomy file at path, the bundled one when path is empty or the file
+// can't be read
+func Open(path string) *Taxonomy {
+	if path == "" {
+		return Bundled()
+	}
+	t, err := Load(path)
+	if err != nil {
+		fmt.Printf("Taxonomy: %s can't be loaded, using the bundled taxonomy: %v\n", path, err)
+		return Bundled()
+	}
+	return t
+}
+
+// Load reads a taxonomy file in Google's taxonomy-with-ids format, e.g. the full
+// https://www.google.com/basepages/producttype/taxonomy-with-ids.en-US.txt
+func Load(path string) (*Taxonomy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads "ID - Path > To > Category" lines; blank lines and "#" comments are skipped
+func Parse(r io.Reader) (*Taxonomy, error) {
+	t := &Taxonomy{byID: map[int]int{}, byName: map[string]int{}, byLeaf: map[string][]int{}}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "# Excerpt") {
+			t.excerpt = true
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		idText, path, ok := strings.Cut(text, " - ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"ID - Category\"", line)
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(idText))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid ID %q", line, idText)
+		}
+		c := Category{ID: id, Path: splitPath(path)}
+		i := len(t.categories)
+		t.categories = append(t.categories, c)
+		t.byID[id] = i
+		t.byName[strings.ToLower(c.Name())] = i
+		for _, key := range leafKeys(c) {
+			t.byLeaf[key] = append(t.byLeaf[key], i)
+		}
+		t.vectors = append(t.vectors, categoryVector(c))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.categories) == 0 {
+		return nil, fmt.Errorf("no categories")
+	}
+	return t, nil
+}
+
+func splitPath(path string) []string {
+	parts := strings.Split(path, ">")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return parts
+}
+
+// Len is the number of categories
+func (t *Taxonomy) Len() int {
+	return len(t.categories)
+}
+
+// Excerpt reports whether the file is a partial copy of the taxonomy, marked with a
+// "# Excerpt" comment line: categories missing from it may exist, and the deepest match
+// in it may be coarser than the product
+func (t *Taxonomy) Excerpt() bool {
+	return t.excerpt
+}
+
+// Plausible reports whether a google_product_category value may be a category: one of
+// the taxonomy, or for an excerpt any ID and any path under one of its top-level categories
+func (t *Taxonomy) Plausible(value string) bool {
+	if _, ok := t.Lookup(value); ok || !t.excerpt {
+		return ok
+	}
+	if _, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		return true
+	}
+	_, ok := t.Lookup(splitPath(value)[0])
+	return ok
+}
+
+// Lookup resolves a google_product_category value, an ID ("5598") or a full path
+// ("Apparel & Accessories > Clothing > Outerwear > Coats & Jackets"), case-insensitively
+func (t *Taxonomy) Lookup(value string) (Category, bool) {
+	value = strings.TrimSpace(value)
+	if id, err := strconv.Atoi(value); err == nil {
+		i, ok := t.byID[id]
+		if !ok {
+			return Category{}, false
+		}
+		return t.categories[i], true
+	}
+	i, ok := t.byName[strings.ToLower(strings.Join(splitPath(value), " > "))]
+	if !ok {
+		return Category{}, false
+	}
+	return t.categories[i], true
+}
@@ -0,0 +1,55 @@
+package taxonomy
+
+import (
+	"strings"
+	"testing"
+)
+
+const sample = `# Google_Product_Taxonomy_Version: 2021-09-21
+1 - Animals & Pet Supplies
+166 - Apparel & Accessories
+1604 - Apparel & Accessories > Clothing
+2271 - Apparel & Accessories > Clothing > Dresses
+`
+
+func TestExcerpt(t *testing.T) {
+	full, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	excerpt, err := Parse(strings.NewReader("# Excerpt of the taxonomy\n" + sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Excerpt() || !excerpt.Excerpt() {
+		t.Fatalf("Excerpt() = %v, %v, want false, true", full.Excerpt(), excerpt.Excerpt())
+	}
+
+	tests := []struct {
+		value         string
+		full, excerpt bool
+	}{
+		{"2271", true, true},
+		{"Apparel & Accessories > Clothing > Dresses", true, true},
+		{"5598", false, true},
+		{"Apparel & Accessories > Clothing > Outerwear > Coats & Jackets", false, true},
+		{"Clothing > Dresses", false, false},
+		{"Invented > Category", false, false},
+	}
+	for _, tt := range tests {
+		if got := full.Plausible(tt.value); got != tt.full {
+			t.Errorf("full.Plausible(%q) = %v, want %v", tt.value, got, tt.full)
+		}
+		if got := excerpt.Plausible(tt.value); got != tt.excerpt {
+			t.Errorf("excerpt.Plausible(%q) = %v, want %v", tt.value, got, tt.excerpt)
+		}
+	}
+}
+
+func TestBundled(t *testing.T) {
+	b := Bundled()
+	// The repository has the excerpt; the Docker build replaces it with the full file
+	if b.Excerpt() != (b.Len() < 5000) {
+		t.Fatalf("%d categories, Excerpt() = %v", b.Len(), b.Excerpt())
+	}
+}
@@ -0,0 +1,60 @@
+//go:build integration
+
+// Package testdb runs a migrated Postgres in a container (testcontainers) for the
+// integration tests and benchmarks. Needs Docker.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/db"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Start runs Postgres 16, applies the migrations and returns its connection URL with a
+// function terminating the container
+func Start(ctx context.Context) (url string, stop func(), err error) {
+	defer func() {
+		// testcontainers panics when no Docker daemon can be found
+		if r := recover(); r != nil {
+			err = fmt.Errorf("docker unavailable: %v", r)
+		}
+	}()
+	pg, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("feedenrich"),
+		postgres.WithUsername("feedenrich"),
+		postgres.WithPassword("feedenrich"),
+		testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).WithStartupTimeout(60*time.Second)),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	stop = func() { pg.Terminate(context.Background()) }
+
+	url, err = pg.ConnectionString(ctx, "sslmode=disable")
+	if err == nil {
+		err = migrate(ctx, url)
+	}
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+	return url, stop, nil
+}
+
+func migrate(ctx context.Context, url string) error {
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = db.Migrate(ctx, conn)
+	return err
+}
@@ -0,0 +1,54 @@
+package websearch
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// bingProvider searches with the Bing Web Search API (BING_SEARCH_ENDPOINT); the snippets
+// of a result's deep links are its extra snippets
+type bingProvider struct {
+	client   *http.Client
+	apiKey   string
+	endpoint string
+}
+
+func (p *bingProvider) Name() string { return "bing" }
+
+func (p *bingProvider) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(count)}, "responseFilter": {"Webpages"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", p.apiKey)
+
+	var bingResp struct {
+		WebPages struct {
+			Value []struct {
+				Name      string `json:"name"`
+				URL       string `json:"url"`
+				Snippet   string `json:"snippet"`
+				DeepLinks []struct {
+					Snippet string `json:"snippet"`
+				} `json:"deepLinks"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	if err := getJSON(p.client, req, p.Name(), &bingResp); err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(bingResp.WebPages.Value))
+	for _, r := range bingResp.WebPages.Value {
+		var extra []string
+		for _, d := range r.DeepLinks {
+			if d.Snippet != "" {
+				extra = append(extra, d.Snippet)
+			}
+		}
+		results = append(results, Result{Title: r.Name, URL: r.URL, Description: r.Snippet, ExtraSnippets: extra})
+	}
+	return results, nil
+}
@@ -0,0 +1,83 @@
+package websearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const braveURL = "https://api.search.brave.com/res/v1/web/search"
+
+// braveProvider searches with the Brave Search API, extra snippets included
+type braveProvider struct {
+	client *http.Client
+	apiKey string
+	quota  *quotaTracker
+}
+
+// newBrave returns the Brave provider; apiKey may be empty when every search carries
+// its own key (see WithAPIKey)
+func newBrave(client *http.Client, apiKey string, quota *quotaTracker) *braveProvider {
+	return &braveProvider{client: client, apiKey: apiKey, quota: quota}
+}
+
+func (p *braveProvider) Name() string { return "brave" }
+
+func (p *braveProvider) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	u := fmt.Sprintf("%s?q=%s&count=%d&extra_snippets=true", braveURL, url.QueryEscape(query), count)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := apiKeyFrom(ctx)
+	if apiKey == "" {
+		apiKey = p.apiKey
+	}
+	req.Header.Set("X-Subscription-Token", apiKey)
+
+	var braveResp struct {
+		Web struct {
+			Results []struct {
+				Title         string   `json:"title"`
+				URL           string   `json:"url"`
+				Description   string   `json:"description"`
+				ExtraSnippets []string `json:"extra_snippets,omitempty"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	header, err := doJSON(p.client, req, p.Name(), &braveResp)
+	// The quota tracked is the one of the configured key
+	if apiKey == p.apiKey {
+		p.reportQuota(header)
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(braveResp.Web.Results))
+	for _, r := range braveResp.Web.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Description: r.Description, ExtraSnippets: r.ExtraSnippets})
+	}
+	return results, nil
+}
+
+// reportQuota reads the monthly window of Brave's rate limit headers: each holds the
+// per-second value, then the per-month one ("X-RateLimit-Remaining: 1, 14230"), and
+// X-RateLimit-Reset the seconds until each window resets
+func (p *braveProvider) reportQuota(header http.Header) {
+	limit, ok1 := lastHeaderInt(header, "X-RateLimit-Limit")
+	remaining, ok2 := lastHeaderInt(header, "X-RateLimit-Remaining")
+	reset, ok3 := lastHeaderInt(header, "X-RateLimit-Reset")
+	if ok1 && ok2 && ok3 {
+		p.quota.report(limit, remaining, time.Duration(reset)*time.Second)
+	}
+}
+
+func lastHeaderInt(header http.Header, key string) (int, bool) {
+	values := strings.Split(header.Get(key), ",")
+	n, err := strconv.Atoi(strings.TrimSpace(values[len(values)-1]))
+	return n, err == nil
+}
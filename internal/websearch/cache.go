@@ -0,0 +1,48 @@
+package websearch
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache keeps values for a TTL, up to limit entries; a zero TTL caches nothing
+type ttlCache[V any] struct {
+	mu      sync.Mutex
+	limit   int
+	entries map[string]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](limit int) *ttlCache[V] {
+	return &ttlCache[V]{limit: limit, entries: map[string]ttlEntry[V]{}}
+}
+
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expires) {
+		return e.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// put stores a value, dropping expired entries first and arbitrary ones when full
+func (c *ttlCache[V]) put(key string, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, old := range c.entries {
+		if now.After(old.expires) || len(c.entries) >= c.limit {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expires: now.Add(ttl)}
+}
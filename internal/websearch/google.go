@@ -0,0 +1,57 @@
+package websearch
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const googleURL = "https://www.googleapis.com/customsearch/v1"
+
+// googleMaxResults is the most results the Custom Search JSON API returns per request
+const googleMaxResults = 10
+
+// googleProvider searches with the Google Custom Search JSON API and a Programmable Search
+// Engine (GOOGLE_SEARCH_CX); the og:description of a result is its extra snippet
+type googleProvider struct {
+	client *http.Client
+	apiKey string
+	cx     string
+}
+
+func (p *googleProvider) Name() string { return "google" }
+
+func (p *googleProvider) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	count = min(count, googleMaxResults)
+	params := url.Values{"key": {p.apiKey}, "cx": {p.cx}, "q": {query}, "num": {strconv.Itoa(count)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var googleResp struct {
+		Items []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+			Pagemap struct {
+				Metatags []map[string]string `json:"metatags"`
+			} `json:"pagemap"`
+		} `json:"items"`
+	}
+	if err := getJSON(p.client, req, p.Name(), &googleResp); err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(googleResp.Items))
+	for _, r := range googleResp.Items {
+		var extra []string
+		for _, tags := range r.Pagemap.Metatags {
+			if d := tags["og:description"]; d != "" && d != r.Snippet {
+				extra = append(extra, d)
+			}
+		}
+		results = append(results, Result{Title: r.Title, URL: r.Link, Description: r.Snippet, ExtraSnippets: extra})
+	}
+	return results, nil
+}
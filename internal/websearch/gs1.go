@@ -0,0 +1,86 @@
+package websearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// gs1Registry looks GTINs up in Verified by GS1, where brand owners register the data of
+// the GTINs they were allocated: the most reliable source of brand and product name
+type gs1Registry struct {
+	client   *http.Client
+	apiKey   string
+	endpoint string
+}
+
+func (r *gs1Registry) Name() string { return "gs1" }
+
+// gs1Value is a GS1 value in one language
+type gs1Value struct {
+	Language string `json:"language"`
+	Value    string `json:"value"`
+}
+
+func (r *gs1Registry) Lookup(ctx context.Context, gtin string) (*GTINProduct, error) {
+	// Verified by GS1 takes a list of GTIN-14
+	gtin14 := strings.Repeat("0", max(14-len(gtin), 0)) + gtin
+	body, _ := json.Marshal([]string{gtin14})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("APIKey", r.apiKey)
+
+	var gs1Resp []struct {
+		GTIN               string     `json:"gtin"`
+		BrandName          []gs1Value `json:"brandName"`
+		ProductDescription []gs1Value `json:"productDescription"`
+		ProductImageURL    []gs1Value `json:"productImageUrl"`
+		GPCCategoryCode    string     `json:"gpcCategoryCode"`
+		NetContent         []struct {
+			UnitCode string `json:"unitCode"`
+			Value    string `json:"value"`
+		} `json:"netContent"`
+	}
+	if err := getJSON(r.client, req, r.Name(), &gs1Resp); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(gs1Resp) == 0 {
+		return nil, nil
+	}
+	item := gs1Resp[0]
+	p := &GTINProduct{
+		URL:        r.endpoint,
+		Confidence: 0.98,
+		Brand:      gs1Text(item.BrandName),
+		Title:      gs1Text(item.ProductDescription),
+		ImageLink:  gs1Text(item.ProductImageURL),
+	}
+	if len(item.NetContent) > 0 {
+		p.Weight = strings.TrimSpace(item.NetContent[0].Value + " " + item.NetContent[0].UnitCode)
+	}
+	if p.Brand == "" && p.Title == "" {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// gs1Text picks a GS1 value: English when given, the first one otherwise
+func gs1Text(values []gs1Value) string {
+	for _, v := range values {
+		if strings.HasPrefix(strings.ToLower(v.Language), "en") {
+			return v.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package websearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxCrawlDelay caps the Crawl-delay a robots.txt can ask for
+const maxCrawlDelay = 30 * time.Second
+
+// hostLimiter spaces the page requests to each host: at most the configured number at a
+// time, each started at least the delay after the previous one
+type hostLimiter struct {
+	concurrency int
+	delay       time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlot
+}
+
+type hostSlot struct {
+	sem  chan struct{}
+	mu   sync.Mutex
+	next time.Time // earliest start of the next request
+}
+
+func newHostLimiter(concurrency int, delay time.Duration) *hostLimiter {
+	return &hostLimiter{concurrency: max(concurrency, 1), delay: delay, hosts: map[string]*hostSlot{}}
+}
+
+// wait blocks until a request to host may start, delay apart from the previous one (the
+// larger of the configured delay and the site's crawlDelay). The returned release must be
+// called when the request is done.
+func (l *hostLimiter) wait(ctx context.Context, host string, crawlDelay time.Duration) (release func(), err error) {
+	l.mu.Lock()
+	slot := l.hosts[host]
+	if slot == nil {
+		slot = &hostSlot{sem: make(chan struct{}, l.concurrency)}
+		l.hosts[host] = slot
+	}
+	l.mu.Unlock()
+
+	select {
+	case slot.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release = func() { <-slot.sem }
+
+	delay := max(l.delay, min(crawlDelay, maxCrawlDelay))
+	slot.mu.Lock()
+	now := time.Now()
+	start := now
+	if slot.next.After(now) {
+		start = slot.next
+	}
+	slot.next = start.Add(delay)
+	slot.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// maxRedirects is how many redirects a page fetch follows, like net/http's default
+const maxRedirects = 10
+
+// hostHold is the host slot held by a page fetch, moved to each host it is redirected to
+type hostHold struct {
+	release func()
+}
+
+type hostHoldKey struct{}
+
+// swap releases the slot held and holds release instead (nil = none)
+func (h *hostHold) swap(release func()) {
+	if h.release != nil {
+		h.release()
+	}
+	h.release = release
+}
+
+// checkRedirect applies the page fetch rules to each redirect: the target must be allowed
+// by its site's robots.txt and waits for its turn on its host, so redirects can't get
+// around either. Requests without a hostHold (robots.txt) follow redirects as usual.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	ctx := req.Context()
+	hold, _ := ctx.Value(hostHoldKey{}).(*hostHold)
+	if hold == nil {
+		return nil
+	}
+	// The previous hop is answered: its slot is freed before robots.txt of the target,
+	// which may be on the same host, is read
+	hold.swap(nil)
+
+	var crawlDelay time.Duration
+	if c.config.WebSearch.RespectRobots {
+		rules, err := c.robotsFor(ctx, req.URL)
+		if err != nil {
+			return err
+		}
+		if !rules.allowed(req.URL.RequestURI()) {
+			return ErrDisallowed
+		}
+		crawlDelay = rules.crawlDelay
+	}
+	release, err := c.hosts.wait(ctx, req.URL.Host, crawlDelay)
+	if err != nil {
+		return err
+	}
+	hold.swap(release)
+	return nil
+}
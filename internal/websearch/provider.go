@@ -0,0 +1,86 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+)
+
+// Provider is a web search API. Implementations map their answer to Result; the client
+// caches and deduplicates their searches, so they only run the request.
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, query string, count int) ([]Result, error)
+}
+
+type apiKeyKey struct{}
+
+// WithAPIKey returns a context whose Brave searches use key instead of the configured
+// one, e.g. the key of the organization a run is for. Other providers ignore it.
+func WithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+func apiKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyKey{}).(string)
+	return key
+}
+
+// newProvider returns the provider of WEBSEARCH_PROVIDER, nil when it has no API key
+// (config.Load rejects unknown providers)
+func newProvider(cfg *config.Config, client *http.Client, quota *quotaTracker) Provider {
+	ws := cfg.WebSearch
+	if ws.APIKey == "" {
+		return nil
+	}
+	switch ws.Provider {
+	case "", "brave":
+		return newBrave(client, ws.APIKey, quota)
+	case "serpapi":
+		return &serpAPIProvider{client: client, apiKey: ws.APIKey}
+	case "bing":
+		return &bingProvider{client: client, apiKey: ws.APIKey, endpoint: ws.BingEndpoint}
+	case "google":
+		return &googleProvider{client: client, apiKey: ws.APIKey, cx: ws.GoogleCX}
+	}
+	return nil
+}
+
+// statusError is a non-200 answer of a search or registry API
+type statusError struct {
+	provider string
+	status   int
+	body     string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s error %d: %s", e.provider, e.status, e.body)
+}
+
+// getJSON runs a search or registry request and decodes its JSON answer into out
+func getJSON(client *http.Client, req *http.Request, provider string, out any) error {
+	_, err := doJSON(client, req, provider, out)
+	return err
+}
+
+// doJSON is getJSON returning the answer's headers, also with a non-200 answer
+func doJSON(client *http.Client, req *http.Request, provider string, out any) (http.Header, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.Header, &statusError{provider: provider, status: resp.StatusCode, body: string(body)}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.Header, fmt.Errorf("parse %s response: %w", provider, err)
+	}
+	return resp.Header, nil
+}
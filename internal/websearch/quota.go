@@ -0,0 +1,199 @@
+package websearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+)
+
+var (
+	// ErrQuotaExhausted is returned, without calling the provider, while its quota is used up
+	ErrQuotaExhausted = errors.New("web search quota exhausted")
+	// ErrCircuitOpen is returned, without calling the provider, after repeated failures
+	ErrCircuitOpen = errors.New("web search paused after repeated failures")
+)
+
+// Unavailable reports whether a search was refused up front (quota or breaker): callers go
+// on without results instead of failing the product
+func Unavailable(err error) bool {
+	return errors.Is(err, ErrQuotaExhausted) || errors.Is(err, ErrCircuitOpen)
+}
+
+// Breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open" // the cooldown is over: the next search tries the provider
+)
+
+// QuotaStatus is the provider's quota as last known and the state of the circuit breaker
+type QuotaStatus struct {
+	Provider  string     `json:"provider"`
+	Enabled   bool       `json:"enabled"`             // the provider's API key is configured
+	Reported  bool       `json:"reported"`            // limit and remaining come from the provider (Brave)
+	Limit     int        `json:"limit,omitempty"`     // searches per period; 0 = unknown
+	Remaining *int       `json:"remaining,omitempty"` // nil = unknown
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+	Used      int        `json:"used"` // searches this process sent this period
+	Exhausted bool       `json:"exhausted"`
+	// Searches refused up front since the start, for the quota or the breaker
+	ShortCircuited int        `json:"short_circuited"`
+	Breaker        string     `json:"breaker"`
+	Failures       int        `json:"consecutive_failures"`
+	OpenUntil      *time.Time `json:"open_until,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// quotaTracker follows the provider's quota and opens a breaker after repeated failures.
+// Without a reporting provider the quota is WEBSEARCH_MONTHLY_QUOTA, counted by this
+// process over the calendar month (UTC).
+type quotaTracker struct {
+	mu        sync.Mutex
+	monthly   int
+	threshold int
+	cooldown  time.Duration
+
+	reported  bool
+	known     bool // remaining is known
+	limit     int
+	remaining int
+	resetAt   time.Time
+	used      int
+	refused   int
+
+	failures  int
+	openUntil time.Time
+	probing   bool // half open: a search is trying the provider
+	lastError string
+}
+
+func newQuotaTracker(cfg *config.Config) *quotaTracker {
+	return &quotaTracker{
+		monthly:   cfg.WebSearch.MonthlyQuota,
+		threshold: max(cfg.WebSearch.BreakerThreshold, 1),
+		cooldown:  cfg.WebSearch.BreakerCooldown,
+	}
+}
+
+// roll starts a new quota period once the current one is over
+func (t *quotaTracker) roll(now time.Time) {
+	if t.resetAt.IsZero() && (t.reported || t.monthly == 0) {
+		return
+	}
+	if !t.resetAt.IsZero() && now.Before(t.resetAt) {
+		return
+	}
+	t.used = 0
+	if t.reported {
+		t.known = false // until the provider's next answer
+		t.resetAt = time.Time{}
+		return
+	}
+	y, m, _ := now.UTC().Date()
+	t.limit, t.remaining, t.known = t.monthly, t.monthly, true
+	t.resetAt = time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// allow tells whether a search may call the provider; each allowed search must be
+// followed by done
+func (t *quotaTracker) allow(now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.roll(now)
+	if t.known && t.remaining <= 0 {
+		t.refused++
+		return ErrQuotaExhausted
+	}
+	if t.failures >= t.threshold {
+		if now.Before(t.openUntil) || t.probing {
+			t.refused++
+			return ErrCircuitOpen
+		}
+		t.probing = true
+	}
+	return nil
+}
+
+// done records the outcome of an allowed search
+func (t *quotaTracker) done(now time.Time, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probing = false
+	if err == nil {
+		t.failures, t.lastError = 0, ""
+		t.used++
+		if !t.reported && t.known {
+			t.remaining--
+		}
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return // the caller gave up, not the provider
+	}
+	t.lastError = err.Error()
+	var status *statusError
+	if errors.As(err, &status) && status.status == http.StatusPaymentRequired {
+		// the subscription refuses searches: hold them until the cooldown at least
+		t.known, t.remaining = true, 0
+		if !t.resetAt.After(now) {
+			t.resetAt = now.Add(t.cooldown)
+		}
+		fmt.Printf("Web search quota exhausted: %s\n", t.lastError)
+		return
+	}
+	t.failures++
+	if t.failures >= t.threshold {
+		t.openUntil = now.Add(t.cooldown)
+		fmt.Printf("Web search paused for %s after %d failures in a row: %s\n", t.cooldown, t.failures, t.lastError)
+	}
+}
+
+// report records the quota the provider answered with
+func (t *quotaTracker) report(limit, remaining int, reset time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if remaining <= 0 && (!t.known || t.remaining > 0) {
+		fmt.Printf("Web search quota exhausted: resets in %s\n", reset)
+	}
+	t.reported, t.known = true, true
+	t.limit, t.remaining = limit, remaining
+	t.resetAt = time.Now().Add(reset)
+}
+
+func (t *quotaTracker) status(now time.Time) QuotaStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.roll(now)
+	s := QuotaStatus{
+		Reported:       t.reported,
+		Limit:          t.limit,
+		Used:           t.used,
+		Exhausted:      t.known && t.remaining <= 0,
+		ShortCircuited: t.refused,
+		Breaker:        BreakerClosed,
+		Failures:       t.failures,
+		LastError:      t.lastError,
+	}
+	if t.known {
+		remaining := max(t.remaining, 0)
+		s.Remaining = &remaining
+	}
+	if !t.resetAt.IsZero() {
+		resetAt := t.resetAt
+		s.ResetAt = &resetAt
+	}
+	if t.failures >= t.threshold {
+		s.Breaker = BreakerHalfOpen
+		if now.Before(t.openUntil) {
+			s.Breaker = BreakerOpen
+			openUntil := t.openUntil
+			s.OpenUntil = &openUntil
+		}
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package websearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/retry"
+)
+
+const maxCachedGTINs = 5000
+
+// GTINProduct is what a GTIN registry knows of a product. Fields the registry doesn't
+// give are empty.
+type GTINProduct struct {
+	GTIN        string  `json:"gtin"`
+	Registry    string  `json:"registry"`   // gs1, openfoodfacts, upcitemdb
+	URL         string  `json:"url"`        // the registry's page or API answer for the GTIN
+	Confidence  float64 `json:"confidence"` // trust in the registry's data
+	Title       string  `json:"title,omitempty"`
+	Brand       string  `json:"brand,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Category    string  `json:"category,omitempty"`
+	Color       string  `json:"color,omitempty"`
+	Size        string  `json:"size,omitempty"`
+	Weight      string  `json:"weight,omitempty"`
+	ImageLink   string  `json:"image_link,omitempty"`
+}
+
+// Fields lists the values of the product by GMC attribute
+func (p *GTINProduct) Fields() map[string]string {
+	fields := map[string]string{}
+	for field, value := range map[string]string{
+		"title": p.Title, "brand": p.Brand, "description": p.Description, "product_type": p.Category,
+		"color": p.Color, "size": p.Size, "product_weight": p.Weight, "image_link": p.ImageLink,
+	} {
+		if value = strings.TrimSpace(value); value != "" {
+			fields[field] = value
+		}
+	}
+	return fields
+}
+
+// Registry is a GTIN registry API. Lookup returns nil, nil for GTINs it doesn't know.
+type Registry interface {
+	Name() string
+	Lookup(ctx context.Context, gtin string) (*GTINProduct, error)
+}
+
+// newRegistries returns the registries of GTIN_REGISTRIES, in order (config.Load rejects
+// unknown ones)
+func newRegistries(cfg *config.Config) []Registry {
+	client := retry.NewHTTPClient(cfg, 10*time.Second)
+	var registries []Registry
+	for _, name := range strings.Split(cfg.GTINRegistry.Registries, ",") {
+		switch strings.TrimSpace(name) {
+		case "gs1":
+			registries = append(registries, &gs1Registry{client: client, apiKey: cfg.GTINRegistry.GS1APIKey, endpoint: cfg.GTINRegistry.GS1Endpoint})
+		case "openfoodfacts":
+			registries = append(registries, &openFoodFactsRegistry{client: client})
+		case "upcitemdb":
+			registries = append(registries, &upcItemDBRegistry{client: client, apiKey: cfg.GTINRegistry.UPCitemdbKey})
+		}
+	}
+	return registries
+}
+
+// RegistriesEnabled reports whether GTIN lookups can run
+func (c *Client) RegistriesEnabled() bool {
+	return len(c.registries) > 0
+}
+
+// LookupGTIN asks the registries in order for a GTIN and returns the first answer, nil
+// when none knows it. Answers and misses are cached for GTIN_REGISTRY_CACHE_TTL; a
+// registry that fails is skipped, and its error returned only when no registry answered.
+func (c *Client) LookupGTIN(ctx context.Context, gtin string) (*GTINProduct, error) {
+	if !c.RegistriesEnabled() || gtin == "" || strings.Trim(gtin, "0123456789") != "" {
+		return nil, nil
+	}
+	if product, ok := c.gtins.get(gtin); ok {
+		return product, nil
+	}
+	v, err, _ := c.group.Do("gtin\x00"+gtin, func() (any, error) {
+		var errs []error
+		for _, registry := range c.registries {
+			product, err := registry.Lookup(ctx, gtin)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if product != nil {
+				product.GTIN, product.Registry = gtin, registry.Name()
+				c.gtins.put(gtin, product, c.config.GTINRegistry.CacheTTL)
+				return product, nil
+			}
+		}
+		if len(errs) == len(c.registries) {
+			return nil, errors.Join(errs...)
+		}
+		// a miss is only final when every registry answered
+		if len(errs) == 0 {
+			c.gtins.put(gtin, nil, c.config.GTINRegistry.CacheTTL)
+		}
+		return (*GTINProduct)(nil), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*GTINProduct), nil
+}
+
+// isNotFound reports a 404 answer, which registries give for unknown GTINs
+func isNotFound(err error) bool {
+	var status *statusError
+	return errors.As(err, &status) && status.status == http.StatusNotFound
+}
+
+// firstValue returns the first of comma-separated values ("Nutella, Ferrero" → "Nutella")
+func firstValue(list string) string {
+	first, _, _ := strings.Cut(list, ",")
+	return strings.TrimSpace(first)
+}
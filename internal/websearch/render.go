@@ -0,0 +1,127 @@
+package websearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/retry"
+)
+
+// Renderer loads a page in a headless browser and returns its HTML once scripts ran
+type Renderer interface {
+	Name() string
+	Render(ctx context.Context, pageURL, userAgent string) ([]byte, error)
+}
+
+// newRenderer returns the renderer of WEBSEARCH_RENDER_PROVIDER, nil when rendering is off
+// (config.Load rejects unknown providers)
+func newRenderer(cfg *config.Config) Renderer {
+	ws := cfg.WebSearch
+	client := retry.NewHTTPClient(cfg, ws.RenderTimeout)
+	endpoint := strings.TrimSuffix(ws.RenderEndpoint, "/")
+	switch ws.RenderProvider {
+	case "browserless":
+		return &browserlessRenderer{client: client, endpoint: endpoint, token: ws.RenderToken}
+	case "splash":
+		return &splashRenderer{client: client, endpoint: endpoint, timeout: ws.RenderTimeout}
+	}
+	return nil
+}
+
+// rendersHost reports whether the pages of a host are rendered: the host or one of its
+// parent domains is in WEBSEARCH_RENDER_DOMAINS
+func (c *Client) rendersHost(host string) bool {
+	if c.renderer == nil {
+		return false
+	}
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, domain := range strings.Split(c.config.WebSearch.RenderDomains, ",") {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// browserlessRenderer renders pages with the /content API of Browserless
+type browserlessRenderer struct {
+	client   *http.Client
+	endpoint string
+	token    string
+}
+
+func (r *browserlessRenderer) Name() string { return "browserless" }
+
+func (r *browserlessRenderer) Render(ctx context.Context, pageURL, userAgent string) ([]byte, error) {
+	body, _ := json.Marshal(map[string]any{
+		"url":       pageURL,
+		"userAgent": userAgent,
+		"gotoOptions": map[string]any{
+			"waitUntil": "networkidle2",
+		},
+	})
+	endpoint := r.endpoint + "/content"
+	if r.token != "" {
+		endpoint += "?" + url.Values{"token": {r.token}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return readRendered(r.client, req, r.Name())
+}
+
+// splashRenderer renders pages with the render.html endpoint of Splash
+type splashRenderer struct {
+	client   *http.Client
+	endpoint string
+	timeout  time.Duration
+}
+
+func (r *splashRenderer) Name() string { return "splash" }
+
+func (r *splashRenderer) Render(ctx context.Context, pageURL, userAgent string) ([]byte, error) {
+	params := url.Values{"url": {pageURL}, "wait": {"1"}, "timeout": {strconv.Itoa(int(r.timeout.Seconds()))}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.endpoint+"/render.html?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Splash passes the request's headers on to the page
+	req.Header.Set("User-Agent", userAgent)
+	return readRendered(r.client, req, r.Name())
+}
+
+// readRendered runs a render request and returns the HTML, at most maxPageBytes
+func readRendered(client *http.Client, req *http.Request, renderer string) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s render request: %w", renderer, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{provider: renderer, status: resp.StatusCode, body: string(body)}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+}
+
+// runRender fetches a page through the renderer. The renderer doesn't report the page's
+// own status: a rendered page is taken as 200.
+func (c *Client) runRender(ctx context.Context, pageURL string) (*Page, error) {
+	body, err := c.renderer.Render(ctx, pageURL, c.config.WebSearch.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{URL: pageURL, StatusCode: http.StatusOK, Body: body, ContentType: "text/html", FetchedAt: time.Now(), Rendered: true}, nil
+}
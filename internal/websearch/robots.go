@@ -0,0 +1,179 @@
+package websearch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDisallowed is returned by Fetch for pages the site's robots.txt closes to us
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+const (
+	robotsTTL       = 24 * time.Hour   // robots.txt files are read once a day per site
+	robotsErrorTTL  = 10 * time.Minute // unreachable ones are tried again sooner
+	maxRobotsBytes  = 512 * 1024
+	maxCachedRobots = 1000
+)
+
+// robots are the rules of a robots.txt that apply to us
+type robots struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	closed     bool // the file couldn't be read: the whole site is closed (RFC 9309)
+}
+
+type robotsRule struct {
+	allow bool
+	path  string // may hold * wildcards and end with $
+}
+
+// allowed reports whether a path (with its query) may be fetched: the longest matching rule
+// wins, Allow on ties, and paths no rule matches are allowed
+func (r *robots) allowed(path string) bool {
+	if r.closed {
+		return false
+	}
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if len(rule.path) < longest || !rule.matches(path) {
+			continue
+		}
+		if len(rule.path) > longest || rule.allow {
+			allow, longest = rule.allow, len(rule.path)
+		}
+	}
+	return allow
+}
+
+func (rule robotsRule) matches(path string) bool {
+	pattern, anchored := strings.CutSuffix(rule.path, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
+
+// parseRobots reads the group of a robots.txt naming userAgent (the longest user-agent
+// found in it), or the * group when none does
+func parseRobots(body io.Reader, userAgent string) *robots {
+	ua := strings.ToLower(userAgent)
+	type group struct {
+		agents []string
+		robots robots
+	}
+	var groups []*group
+	var current *group
+	inAgents := false
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+			continue
+		case "allow", "disallow":
+			if current != nil && value != "" {
+				current.robots.rules = append(current.robots.rules, robotsRule{allow: key == "allow", path: value})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); current != nil && err == nil && seconds > 0 {
+				current.robots.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		inAgents = false
+	}
+
+	var best *robots
+	bestLen := -1
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			n := len(agent)
+			switch {
+			case agent == "*":
+				n = 0
+			case !strings.Contains(ua, agent):
+				continue
+			}
+			if n > bestLen {
+				best, bestLen = &g.robots, n
+			}
+		}
+	}
+	if best == nil {
+		return &robots{}
+	}
+	return best
+}
+
+// robotsFor returns the robots.txt rules of a page's site, read through the host limiter
+// and cached. Missing files (4xx) allow everything; unreachable ones (5xx, network errors)
+// close the site until they are tried again.
+func (c *Client) robotsFor(ctx context.Context, page *url.URL) (*robots, error) {
+	site := page.Scheme + "://" + page.Host
+	if r, ok := c.robots.get(site); ok {
+		return r, nil
+	}
+	// robots.txt is read outside the slot of a redirected page fetch (checkRedirect)
+	ctx = context.WithValue(ctx, hostHoldKey{}, (*hostHold)(nil))
+	v, err, _ := c.group.Do("robots\x00"+site, func() (any, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", c.config.WebSearch.UserAgent)
+		release, err := c.hosts.wait(ctx, page.Host, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		r, ttl := &robots{closed: true}, robotsErrorTTL
+		resp, err := c.fetch.Do(req)
+		if err == nil {
+			defer resp.Body.Close()
+			switch {
+			case resp.StatusCode == http.StatusOK:
+				r, ttl = parseRobots(io.LimitReader(resp.Body, maxRobotsBytes), c.config.WebSearch.UserAgent), robotsTTL
+			case resp.StatusCode >= 400 && resp.StatusCode < 500:
+				r, ttl = &robots{}, robotsTTL
+			}
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		c.robots.put(site, r, ttl)
+		return r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*robots), nil
+}
@@ -0,0 +1,51 @@
+package websearch
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const serpAPIURL = "https://serpapi.com/search.json"
+
+// serpAPIProvider searches Google through SerpAPI; the rich snippet lines of a result are
+// its extra snippets
+type serpAPIProvider struct {
+	client *http.Client
+	apiKey string
+}
+
+func (p *serpAPIProvider) Name() string { return "serpapi" }
+
+func (p *serpAPIProvider) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	params := url.Values{"engine": {"google"}, "q": {query}, "num": {strconv.Itoa(count)}, "api_key": {p.apiKey}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serpAPIURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var serpResp struct {
+		OrganicResults []struct {
+			Title       string `json:"title"`
+			Link        string `json:"link"`
+			Snippet     string `json:"snippet"`
+			RichSnippet struct {
+				Top struct {
+					Extensions []string `json:"extensions"`
+				} `json:"top"`
+			} `json:"rich_snippet"`
+		} `json:"organic_results"`
+	}
+	if err := getJSON(p.client, req, p.Name(), &serpResp); err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(serpResp.OrganicResults))
+	for _, r := range serpResp.OrganicResults {
+		results = append(results, Result{Title: r.Title, URL: r.Link, Description: r.Snippet, ExtraSnippets: r.RichSnippet.Top.Extensions})
+		if len(results) == count {
+			break
+		}
+	}
+	return results, nil
+}
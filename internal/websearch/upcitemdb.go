@@ -0,0 +1,74 @@
+package websearch
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const (
+	upcItemDBTrialURL = "https://api.upcitemdb.com/prod/trial/lookup"
+	upcItemDBURL      = "https://api.upcitemdb.com/prod/v1/lookup"
+)
+
+// upcItemDBRegistry looks GTINs up in UPCitemdb, a database of retail products built from
+// merchant listings; without a key it uses the free trial endpoint
+type upcItemDBRegistry struct {
+	client *http.Client
+	apiKey string
+}
+
+func (r *upcItemDBRegistry) Name() string { return "upcitemdb" }
+
+func (r *upcItemDBRegistry) Lookup(ctx context.Context, gtin string) (*GTINProduct, error) {
+	endpoint := upcItemDBTrialURL
+	if r.apiKey != "" {
+		endpoint = upcItemDBURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+url.Values{"upc": {gtin}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.apiKey != "" {
+		req.Header.Set("user_key", r.apiKey)
+		req.Header.Set("key_type", "3scale")
+	}
+
+	var upcResp struct {
+		Items []struct {
+			Title       string   `json:"title"`
+			Description string   `json:"description"`
+			Brand       string   `json:"brand"`
+			Color       string   `json:"color"`
+			Size        string   `json:"size"`
+			Weight      string   `json:"weight"`
+			Category    string   `json:"category"`
+			Images      []string `json:"images"`
+		} `json:"items"`
+	}
+	if err := getJSON(r.client, req, r.Name(), &upcResp); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(upcResp.Items) == 0 {
+		return nil, nil
+	}
+	item := upcResp.Items[0]
+	p := &GTINProduct{
+		URL:         "https://www.upcitemdb.com/upc/" + gtin,
+		Confidence:  0.85,
+		Title:       item.Title,
+		Brand:       item.Brand,
+		Description: item.Description,
+		Category:    item.Category,
+		Color:       item.Color,
+		Size:        item.Size,
+		Weight:      item.Weight,
+	}
+	if len(item.Images) > 0 {
+		p.ImageLink = item.Images[0]
+	}
+	return p, nil
+}
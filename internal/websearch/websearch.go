@@ -0,0 +1,241 @@
+// Package websearch runs web searches (Brave, SerpAPI, Bing or Google Custom Search, see
+// WEBSEARCH_PROVIDER), looks GTINs up in product registries (GTIN_REGISTRIES) and fetches
+// pages for the agent,
+// the web_search and fetch_page tools and the retrieval agent. Results and pages are
+// cached per query and URL for WEBSEARCH_CACHE_TTL, shared by every caller of the same
+// config, so batch runs don't pay for the same search twice or hammer merchant sites.
+// Page fetches are polite: they follow each site's robots.txt and are spaced per host
+// (WEBSEARCH_HOST_CONCURRENCY, WEBSEARCH_HOST_DELAY) so merchants don't block our IPs.
+// Pages of WEBSEARCH_RENDER_DOMAINS are loaded by a headless browser service instead.
+package websearch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benjamincozon/feedenrich/internal/config"
+	"github.com/benjamincozon/feedenrich/internal/imageproxy"
+	"github.com/benjamincozon/feedenrich/internal/retry"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	maxPageBytes      = 512 * 1024 // pages are truncated to this before caching
+	maxPDFBytes       = 4 << 20    // PDF datasheets, read page by page: a cut file keeps its first pages
+	maxCachedSearches = 5000
+	maxCachedPages    = 500
+)
+
+// Result is one web search hit, whatever the provider
+type Result struct {
+	Title         string
+	URL           string
+	Description   string
+	ExtraSnippets []string
+}
+
+// Page is a fetched page; Body is empty unless StatusCode is 200
+type Page struct {
+	URL         string
+	StatusCode  int
+	Body        []byte // at most maxPageBytes, maxPDFBytes for PDF documents
+	ContentType string
+	FetchedAt   time.Time
+	Rendered    bool // loaded by the headless browser service (WEBSEARCH_RENDER_DOMAINS)
+}
+
+// Client searches and fetches through the shared caches
+type Client struct {
+	config     *config.Config
+	provider   Provider // nil = searches off
+	registries []Registry
+	renderer   Renderer // nil = no rendering
+	fetch      *http.Client
+	searches   *ttlCache[[]Result]
+	pages      *ttlCache[*Page]
+	robots     *ttlCache[*robots] // per site (scheme://host)
+	gtins      *ttlCache[*GTINProduct]
+	hosts      *hostLimiter
+	quota      *quotaTracker
+	brave      *braveProvider // searches with an organization's key (WithAPIKey), nil for other providers
+	group      singleflight.Group
+}
+
+var clientsByConfig sync.Map // *config.Config -> *Client
+
+// For returns the client shared by the callers of cfg
+func For(cfg *config.Config) *Client {
+	if c, ok := clientsByConfig.Load(cfg); ok {
+		return c.(*Client)
+	}
+	quota := newQuotaTracker(cfg)
+	searchClient := retry.NewHTTPClient(cfg, 10*time.Second)
+	var brave *braveProvider
+	if cfg.WebSearch.Provider == "" || cfg.WebSearch.Provider == "brave" {
+		brave = newBrave(searchClient, cfg.WebSearch.APIKey, quota)
+	}
+	client := &Client{
+		config:     cfg,
+		provider:   newProvider(cfg, searchClient, quota),
+		searches:   newTTLCache[[]Result](maxCachedSearches),
+		pages:      newTTLCache[*Page](maxCachedPages),
+		robots:     newTTLCache[*robots](maxCachedRobots),
+		registries: newRegistries(cfg),
+		renderer:   newRenderer(cfg),
+		gtins:      newTTLCache[*GTINProduct](maxCachedGTINs),
+		hosts:      newHostLimiter(cfg.WebSearch.HostConcurrency, cfg.WebSearch.HostDelay),
+		quota:      quota,
+		brave:      brave,
+	}
+	// Page URLs come from feeds and search results: like images, they can't reach private
+	// addresses, and redirects go through robots.txt and the host limiter again
+	client.fetch = &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &retry.Transport{
+			Base:   imageproxy.SafeTransport(cfg.ImageProxy.AllowPrivate),
+			Policy: retry.PolicyFromConfig(cfg),
+		},
+		CheckRedirect: client.checkRedirect,
+	}
+	c, _ := clientsByConfig.LoadOrStore(cfg, client)
+	return c.(*Client)
+}
+
+// Enabled reports whether searches can run (the provider's API key is configured)
+func (c *Client) Enabled() bool {
+	return c.provider != nil
+}
+
+// EnabledFor reports whether the searches made with ctx can run: the provider's API key
+// is configured, or ctx carries an organization's Brave key
+func (c *Client) EnabledFor(ctx context.Context) bool {
+	return c.providerFor(ctx) != nil
+}
+
+// providerFor returns the provider searching for ctx, nil when there is no key
+func (c *Client) providerFor(ctx context.Context) Provider {
+	if c.brave != nil && apiKeyFrom(ctx) != "" {
+		return c.brave
+	}
+	return c.provider
+}
+
+// QuotaStatus returns the search provider's quota and the state of the circuit breaker
+func (c *Client) QuotaStatus() QuotaStatus {
+	status := c.quota.status(time.Now())
+	status.Provider = c.config.WebSearch.Provider
+	status.Enabled = c.Enabled()
+	return status
+}
+
+// Search returns up to count results for query; none without an API key. Errors are
+// not cached, and concurrent identical searches share one request. Cached results are
+// served even while searches are refused up front: ErrQuotaExhausted, ErrCircuitOpen.
+func (c *Client) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	provider := c.providerFor(ctx)
+	if provider == nil {
+		return []Result{}, nil
+	}
+	key := strconv.Itoa(count) + "\x00" + query
+	if results, ok := c.searches.get(key); ok {
+		return results, nil
+	}
+	v, err, _ := c.group.Do("search\x00"+key, func() (any, error) {
+		// The quota and circuit breaker are the configured key's, not an organization's
+		shared := provider == c.provider
+		if shared {
+			if err := c.quota.allow(time.Now()); err != nil {
+				return nil, err
+			}
+		}
+		results, err := provider.Search(ctx, query, count)
+		if shared {
+			c.quota.done(time.Now(), err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.searches.put(key, results, c.config.WebSearch.CacheTTL)
+		return results, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]Result), nil
+}
+
+// Fetch downloads a page, unless the site's robots.txt disallows it (ErrDisallowed), waiting
+// for its turn on the host. Only 200 answers are cached: a page that failed is tried again
+// next time.
+func (c *Client) Fetch(ctx context.Context, pageURL string) (*Page, error) {
+	if page, ok := c.pages.get(pageURL); ok {
+		return page, nil
+	}
+	v, err, _ := c.group.Do("page\x00"+pageURL, func() (any, error) {
+		page, err := c.runFetch(ctx, pageURL)
+		if err != nil {
+			return nil, err
+		}
+		if page.StatusCode == http.StatusOK {
+			c.pages.put(pageURL, page, c.config.WebSearch.CacheTTL)
+		}
+		return page, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Page), nil
+}
+
+func (c *Client) runFetch(ctx context.Context, pageURL string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.config.WebSearch.UserAgent)
+
+	var crawlDelay time.Duration
+	if c.config.WebSearch.RespectRobots {
+		rules, err := c.robotsFor(ctx, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		if !rules.allowed(req.URL.RequestURI()) {
+			return nil, ErrDisallowed
+		}
+		crawlDelay = rules.crawlDelay
+	}
+	release, err := c.hosts.wait(ctx, req.URL.Host, crawlDelay)
+	if err != nil {
+		return nil, err
+	}
+	hold := &hostHold{release: release}
+	defer hold.swap(nil)
+	// PDF documents have no scripts to run
+	if c.rendersHost(req.URL.Hostname()) && !strings.HasSuffix(strings.ToLower(req.URL.Path), ".pdf") {
+		return c.runRender(ctx, pageURL)
+	}
+
+	resp, err := c.fetch.Do(req.WithContext(context.WithValue(ctx, hostHoldKey{}, hold)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	page := &Page{URL: pageURL, StatusCode: resp.StatusCode, ContentType: resp.Header.Get("Content-Type"), FetchedAt: time.Now()}
+	if resp.StatusCode != http.StatusOK {
+		return page, nil
+	}
+	limit := int64(maxPageBytes)
+	if strings.HasPrefix(page.ContentType, "application/pdf") || strings.HasSuffix(strings.ToLower(req.URL.Path), ".pdf") {
+		limit = maxPDFBytes
+	}
+	if page.Body, err = io.ReadAll(io.LimitReader(resp.Body, limit)); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,144 @@
+-- +goose Up
+-- Migration: Monthly partitioning for audit tables (change_log, agent_traces)
+-- Old months can then be archived and dropped partition by partition.
+
+-- Helper: create the monthly partition of an audit table (idempotent)
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION create_monthly_partition(parent TEXT, month_start DATE)
+RETURNS TEXT AS $$
+DECLARE
+    start_date DATE := date_trunc('month', month_start)::date;
+    end_date DATE := (date_trunc('month', month_start) + INTERVAL '1 month')::date;
+    partition_name TEXT := parent || '_p' || to_char(start_date, 'YYYY_MM');
+BEGIN
+    EXECUTE format(
+        'CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+        partition_name, parent, start_date, end_date
+    );
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Change log
+ALTER TABLE change_log RENAME TO change_log_legacy;
+ALTER TABLE change_log_legacy RENAME CONSTRAINT change_log_pkey TO change_log_legacy_pkey;
+
+CREATE TABLE change_log (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    dataset_id UUID REFERENCES datasets(id) ON DELETE SET NULL,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,
+    action VARCHAR(50) NOT NULL, -- 'import', 'proposal_accepted', 'proposal_rejected', 'manual_edit', 'export', 'restore'
+    field VARCHAR(100),
+    old_value TEXT,
+    new_value TEXT,
+    source VARCHAR(50), -- 'user', 'agent', 'rule'
+    module VARCHAR(100), -- optimization module if applicable
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_by VARCHAR(255),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE change_log_default PARTITION OF change_log DEFAULT;
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    m DATE;
+BEGIN
+    SELECT date_trunc('month', COALESCE(MIN(created_at), NOW()))::date INTO m FROM change_log_legacy;
+    WHILE m <= (date_trunc('month', NOW()) + INTERVAL '2 months')::date LOOP
+        PERFORM create_monthly_partition('change_log', m);
+        m := (m + INTERVAL '1 month')::date;
+    END LOOP;
+END $$;
+-- +goose StatementEnd
+
+INSERT INTO change_log (id, dataset_id, product_id, action, field, old_value, new_value, source, module, created_at, created_by)
+SELECT id, dataset_id, product_id, action, field, old_value, new_value, source, module, COALESCE(created_at, NOW()), created_by
+FROM change_log_legacy;
+
+DROP TABLE change_log_legacy;
+
+CREATE INDEX idx_changelog_dataset ON change_log(dataset_id);
+CREATE INDEX idx_changelog_product ON change_log(product_id);
+CREATE INDEX idx_changelog_created ON change_log(created_at);
+
+-- Agent traces
+ALTER TABLE agent_traces RENAME TO agent_traces_legacy;
+ALTER TABLE agent_traces_legacy RENAME CONSTRAINT agent_traces_pkey TO agent_traces_legacy_pkey;
+DROP INDEX IF EXISTS idx_traces_session;
+
+CREATE TABLE agent_traces (
+    id UUID NOT NULL DEFAULT uuid_generate_v4(),
+    session_id UUID REFERENCES agent_sessions(id) ON DELETE CASCADE,
+    step_number INT NOT NULL,
+    thought TEXT,
+    tool_name VARCHAR(100),
+    tool_input JSONB,
+    tool_output JSONB,
+    tokens_used INT DEFAULT 0,
+    duration_ms INT DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE agent_traces_default PARTITION OF agent_traces DEFAULT;
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    m DATE;
+BEGIN
+    SELECT date_trunc('month', COALESCE(MIN(created_at), NOW()))::date INTO m FROM agent_traces_legacy;
+    WHILE m <= (date_trunc('month', NOW()) + INTERVAL '2 months')::date LOOP
+        PERFORM create_monthly_partition('agent_traces', m);
+        m := (m + INTERVAL '1 month')::date;
+    END LOOP;
+END $$;
+-- +goose StatementEnd
+
+INSERT INTO agent_traces (id, session_id, step_number, thought, tool_name, tool_input, tool_output, tokens_used, duration_ms, created_at)
+SELECT id, session_id, step_number, thought, tool_name, tool_input, tool_output, tokens_used, duration_ms, COALESCE(created_at, NOW())
+FROM agent_traces_legacy;
+
+DROP TABLE agent_traces_legacy;
+
+CREATE INDEX idx_traces_session ON agent_traces(session_id, step_number);
+
+-- Archive runs (exports written before a partition is dropped)
+CREATE TABLE IF NOT EXISTS audit_archives (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    table_name VARCHAR(100) NOT NULL,
+    partition_name VARCHAR(100) NOT NULL,
+    month_start DATE NOT NULL,
+    row_count BIGINT NOT NULL DEFAULT 0,
+    location TEXT NOT NULL,
+    purged BOOLEAN DEFAULT false,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE(table_name, month_start)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_archives;
+
+ALTER TABLE change_log RENAME TO change_log_partitioned;
+CREATE TABLE change_log (LIKE change_log_partitioned INCLUDING DEFAULTS);
+INSERT INTO change_log SELECT * FROM change_log_partitioned;
+DROP TABLE change_log_partitioned;
+ALTER TABLE change_log ADD PRIMARY KEY (id);
+ALTER TABLE change_log ADD FOREIGN KEY (dataset_id) REFERENCES datasets(id) ON DELETE SET NULL;
+ALTER TABLE change_log ADD FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE SET NULL;
+CREATE INDEX idx_changelog_dataset ON change_log(dataset_id);
+CREATE INDEX idx_changelog_product ON change_log(product_id);
+CREATE INDEX idx_changelog_created ON change_log(created_at);
+
+ALTER TABLE agent_traces RENAME TO agent_traces_partitioned;
+CREATE TABLE agent_traces (LIKE agent_traces_partitioned INCLUDING DEFAULTS);
+INSERT INTO agent_traces SELECT * FROM agent_traces_partitioned;
+DROP TABLE agent_traces_partitioned;
+ALTER TABLE agent_traces ADD PRIMARY KEY (id);
+ALTER TABLE agent_traces ADD FOREIGN KEY (session_id) REFERENCES agent_sessions(id) ON DELETE CASCADE;
+CREATE INDEX idx_traces_session ON agent_traces(session_id, step_number);
+
+DROP FUNCTION IF EXISTS create_monthly_partition(TEXT, DATE);
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Migration: Job checkpoints for pause/resume
+
+-- Last product fully processed by the worker; resume restarts right after it
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS checkpoint_product_id UUID;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN IF EXISTS checkpoint_product_id;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Migration: Free-form tags and notes on products
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS notes TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_products_tags ON products USING GIN (tags);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_products_tags;
+ALTER TABLE products DROP COLUMN IF EXISTS notes;
+ALTER TABLE products DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Migration: Job priority (1 = lowest, 10 = highest)
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 5;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN IF EXISTS priority;
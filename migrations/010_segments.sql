@@ -0,0 +1,18 @@
+-- +goose Up
+-- Migration: Saved product segments (reusable filter expressions per dataset)
+
+CREATE TABLE IF NOT EXISTS segments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT,
+    filter JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(dataset_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_segments_dataset ON segments(dataset_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS segments;
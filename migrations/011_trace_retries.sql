@@ -0,0 +1,7 @@
+-- +goose Up
+-- Migration: Record outbound call retries on agent traces
+
+ALTER TABLE agent_traces ADD COLUMN IF NOT EXISTS retries INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE agent_traces DROP COLUMN IF EXISTS retries;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Migration: Shadow-mode evaluation bucket
+-- Candidate models/prompts run on a sample of products; their output lives here
+-- and never reaches the proposals table, so reviewers never see it.
+
+CREATE TABLE IF NOT EXISTS shadow_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    job_id UUID,
+    module VARCHAR(50) NOT NULL,
+    variant VARCHAR(100) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL,            -- completed, failed
+    error TEXT,
+    primary_proposals JSONB NOT NULL DEFAULT '[]',
+    shadow_count INT NOT NULL DEFAULT 0,
+    primary_count INT NOT NULL DEFAULT 0,
+    field_overlap INT NOT NULL DEFAULT 0,   -- fields proposed by both
+    agreed INT NOT NULL DEFAULT 0,          -- same field and same value
+    duration_ms INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_shadow_runs_variant ON shadow_runs(variant, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_shadow_runs_product ON shadow_runs(product_id);
+
+CREATE TABLE IF NOT EXISTS shadow_proposals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    run_id UUID NOT NULL REFERENCES shadow_runs(id) ON DELETE CASCADE,
+    field VARCHAR(100) NOT NULL,
+    before_value TEXT,
+    after_value TEXT,
+    rationale TEXT[],
+    confidence DECIMAL(3,2),
+    risk_level VARCHAR(20),
+    matches_primary BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_shadow_proposals_run ON shadow_proposals(run_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS shadow_proposals;
+DROP TABLE IF EXISTS shadow_runs;
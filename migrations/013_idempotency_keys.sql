@@ -0,0 +1,19 @@
+-- +goose Up
+-- Migration: Idempotency keys for enrichment triggers
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope TEXT NOT NULL,                 -- method + path, e.g. "POST /api/products/<id>/enrich"
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,         -- in_progress, completed
+    status_code INT,
+    response BYTEA,
+    created_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_keys;
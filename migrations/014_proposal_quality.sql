@@ -0,0 +1,12 @@
+-- +goose Up
+-- Migration: Automated quality score per proposal (0-100)
+
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS quality_score SMALLINT;
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS quality_breakdown JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_proposals_quality ON proposals(status, quality_score DESC NULLS LAST);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_proposals_quality;
+ALTER TABLE proposals DROP COLUMN IF EXISTS quality_breakdown;
+ALTER TABLE proposals DROP COLUMN IF EXISTS quality_score;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Migration: Last enrichment run per product and module, used to re-enrich stale products
+
+CREATE TABLE IF NOT EXISTS product_enrichment_runs (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    module VARCHAR(100) NOT NULL,
+    last_run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    data_hash TEXT NOT NULL, -- md5 of the product data the run saw
+    proposals INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (product_id, module)
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrichment_runs_last_run ON product_enrichment_runs(module, last_run_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS product_enrichment_runs;
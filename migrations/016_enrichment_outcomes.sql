@@ -0,0 +1,14 @@
+-- +goose Up
+-- Migration: Outcome of the last enrichment run, so "run, nothing to change" is distinct from "not run"
+
+ALTER TABLE product_enrichment_runs ADD COLUMN IF NOT EXISTS outcome VARCHAR(20) NOT NULL DEFAULT 'changes_proposed'; -- clean, changes_proposed
+ALTER TABLE product_enrichment_runs ADD COLUMN IF NOT EXISTS score DECIMAL(3,2); -- hard rule compliance when the run ended
+
+UPDATE product_enrichment_runs SET outcome = 'clean' WHERE proposals = 0;
+
+CREATE INDEX IF NOT EXISTS idx_enrichment_runs_outcome ON product_enrichment_runs(module, outcome);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_enrichment_runs_outcome;
+ALTER TABLE product_enrichment_runs DROP COLUMN IF EXISTS score;
+ALTER TABLE product_enrichment_runs DROP COLUMN IF EXISTS outcome;
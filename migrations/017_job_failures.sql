@@ -0,0 +1,22 @@
+-- +goose Up
+-- Migration: Dead-letter of products that repeatedly failed within a job
+
+CREATE TABLE IF NOT EXISTS job_failures (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    attempts INT NOT NULL DEFAULT 1,
+    error TEXT NOT NULL,
+    error_kind VARCHAR(20) NOT NULL DEFAULT 'error', -- timeout, parse, api, error
+    status VARCHAR(20) NOT NULL DEFAULT 'dead_letter', -- dead_letter, requeued, resolved
+    requeued_job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(job_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_failures_job ON job_failures(job_id, status);
+CREATE INDEX IF NOT EXISTS idx_job_failures_product ON job_failures(product_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS job_failures;
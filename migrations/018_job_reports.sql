@@ -0,0 +1,11 @@
+-- +goose Up
+-- Migration: Job run reports (token usage, dry-run projections)
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS report JSONB;
+
+-- Proposals of dry runs are stored as 'simulated' and kept out of review queues
+CREATE INDEX IF NOT EXISTS idx_proposals_simulated ON proposals(product_id) WHERE status = 'simulated';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_proposals_simulated;
+ALTER TABLE jobs DROP COLUMN IF EXISTS report;
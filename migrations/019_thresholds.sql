@@ -0,0 +1,10 @@
+-- +goose Up
+-- Dataset-level defaults for confidence/risk thresholds (partial: unset keys inherit AGENT_*)
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS thresholds JSONB;
+
+-- Thresholds a single-product run was made with
+ALTER TABLE agent_sessions ADD COLUMN IF NOT EXISTS thresholds JSONB;
+
+-- +goose Down
+ALTER TABLE agent_sessions DROP COLUMN IF EXISTS thresholds;
+ALTER TABLE datasets DROP COLUMN IF EXISTS thresholds;
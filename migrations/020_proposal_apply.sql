@@ -0,0 +1,6 @@
+-- +goose Up
+-- When an accepted proposal was written to current_data (NULL = status change only, nothing to revert)
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS applied_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE proposals DROP COLUMN IF EXISTS applied_at;
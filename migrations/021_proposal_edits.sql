@@ -0,0 +1,6 @@
+-- +goose Up
+-- Value a reviewer substituted for the agent's after_value (applied instead of it; after_value kept for audit)
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS edited_value TEXT;
+
+-- +goose Down
+ALTER TABLE proposals DROP COLUMN IF EXISTS edited_value;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Accepted proposal that closed this one (same product+field); cleared again on revert
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS superseded_by UUID REFERENCES proposals(id) ON DELETE SET NULL;
+
+-- Conflict lookups: pending proposals per product+field
+CREATE INDEX IF NOT EXISTS idx_proposals_pending_field ON proposals(product_id, field) WHERE status = 'proposed';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_proposals_pending_field;
+ALTER TABLE proposals DROP COLUMN IF EXISTS superseded_by;
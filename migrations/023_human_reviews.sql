@@ -0,0 +1,26 @@
+-- +goose Up
+-- Items the agent explicitly escalated to a human (request_human_review, critical issues),
+-- reviewed separately from proposals
+CREATE TABLE IF NOT EXISTS human_reviews (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dataset_id UUID REFERENCES datasets(id) ON DELETE CASCADE,
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE,
+    session_id UUID,           -- agent session or job run that escalated
+    source VARCHAR(30) NOT NULL, -- 'tool', 'issue', 'pipeline'
+    field VARCHAR(100),
+    question TEXT NOT NULL,
+    risk_level VARCHAR(20),
+    context JSONB,
+    options TEXT[],
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'resolved', 'dismissed'
+    resolution TEXT,
+    resolved_by VARCHAR(255),
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_human_reviews_queue ON human_reviews(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_human_reviews_product ON human_reviews(product_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS human_reviews;
@@ -0,0 +1,49 @@
+-- +goose Up
+-- Reviewer workflow shared by proposals and human reviews: who holds the item and where
+-- it stands ('pending', 'in_review', 'needs_info', 'approved', 'rejected').
+-- approved/rejected follow the decision (proposal status, human review status).
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS assignee VARCHAR(255);
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMPTZ;
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS review_state VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+ALTER TABLE human_reviews ADD COLUMN IF NOT EXISTS assignee VARCHAR(255);
+ALTER TABLE human_reviews ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMPTZ;
+ALTER TABLE human_reviews ADD COLUMN IF NOT EXISTS review_state VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+UPDATE proposals SET review_state = CASE
+    WHEN status IN ('accepted', 'edited') THEN 'approved'
+    WHEN status = 'rejected' THEN 'rejected'
+    ELSE 'pending' END;
+UPDATE human_reviews SET review_state = CASE
+    WHEN status = 'resolved' THEN 'approved'
+    WHEN status = 'dismissed' THEN 'rejected'
+    ELSE 'pending' END;
+
+CREATE INDEX IF NOT EXISTS idx_proposals_assignee ON proposals(assignee, review_state) WHERE assignee IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_human_reviews_assignee ON human_reviews(assignee, review_state) WHERE assignee IS NOT NULL;
+
+-- Reviewer discussion on a proposal or a human review (exactly one of the two)
+CREATE TABLE IF NOT EXISTS review_comments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    proposal_id UUID REFERENCES proposals(id) ON DELETE CASCADE,
+    human_review_id UUID REFERENCES human_reviews(id) ON DELETE CASCADE,
+    author VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    review_state VARCHAR(20), -- state set together with the comment, if any
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((proposal_id IS NULL) <> (human_review_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_comments_proposal ON review_comments(proposal_id, created_at) WHERE proposal_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_review_comments_human_review ON review_comments(human_review_id, created_at) WHERE human_review_id IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS review_comments;
+DROP INDEX IF EXISTS idx_human_reviews_assignee;
+DROP INDEX IF EXISTS idx_proposals_assignee;
+ALTER TABLE human_reviews DROP COLUMN IF EXISTS review_state;
+ALTER TABLE human_reviews DROP COLUMN IF EXISTS assigned_at;
+ALTER TABLE human_reviews DROP COLUMN IF EXISTS assignee;
+ALTER TABLE proposals DROP COLUMN IF EXISTS review_state;
+ALTER TABLE proposals DROP COLUMN IF EXISTS assigned_at;
+ALTER TABLE proposals DROP COLUMN IF EXISTS assignee;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- change_log entry recording the group accept that applied this proposal (one entry per
+-- group instead of one per product; before_value keeps each product's previous value)
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS group_change_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_proposals_group_change ON proposals(group_change_id) WHERE group_change_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_proposals_group_change;
+ALTER TABLE proposals DROP COLUMN IF EXISTS group_change_id;
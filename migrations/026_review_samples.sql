@@ -0,0 +1,39 @@
+-- +goose Up
+-- Sampling review of large proposal sets: a random sample of one module+field stratum is
+-- reviewed by humans; when its acceptance rate reaches the threshold the rest of the
+-- stratum can be auto-approved, with the sample results as justification
+CREATE TABLE IF NOT EXISTS review_samples (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    module VARCHAR(100) NOT NULL DEFAULT '',
+    field VARCHAR(100) NOT NULL,
+    population INT NOT NULL,        -- pending proposals in the stratum when the sample was drawn
+    sample_size INT NOT NULL,
+    confidence DECIMAL(4,3) NOT NULL,
+    margin DECIMAL(4,3) NOT NULL,
+    threshold DECIMAL(4,3) NOT NULL, -- acceptance rate required to auto-approve the rest
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- 'open', 'auto_approved', 'closed'
+    created_by VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- frozen when auto-approved
+    sample_accepted INT,
+    sample_reviewed INT,
+    auto_approved INT,
+    change_id UUID,                 -- change_log entry of the auto-approval
+    decided_by VARCHAR(255),
+    decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_samples_dataset ON review_samples(dataset_id, created_at);
+
+CREATE TABLE IF NOT EXISTS review_sample_items (
+    sample_id UUID NOT NULL REFERENCES review_samples(id) ON DELETE CASCADE,
+    proposal_id UUID NOT NULL REFERENCES proposals(id) ON DELETE CASCADE,
+    PRIMARY KEY (sample_id, proposal_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_sample_items_proposal ON review_sample_items(proposal_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS review_sample_items;
+DROP TABLE IF EXISTS review_samples;
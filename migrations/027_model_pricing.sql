@@ -0,0 +1,25 @@
+-- +goose Up
+-- Per-model token prices (USD per 1M tokens). A price applies from effective_from until
+-- the next one of the same model, so price changes keep past costs accurate.
+CREATE TABLE IF NOT EXISTS model_pricing (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    model VARCHAR(100) NOT NULL,  -- exact name, or a prefix matching dated variants (gpt-4o-mini-2024-07-18)
+    input_per_million DECIMAL(12, 4) NOT NULL,
+    output_per_million DECIMAL(12, 4) NOT NULL,
+    effective_from DATE NOT NULL,
+    note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(model, effective_from)
+);
+
+INSERT INTO model_pricing (model, input_per_million, output_per_million, effective_from, note) VALUES
+    ('gpt-4o-mini', 0.15, 0.60, '2024-07-18', 'OpenAI list price'),
+    ('gpt-4o', 2.50, 10.00, '2024-10-01', 'OpenAI list price'),
+    ('claude-sonnet-4-5', 3.00, 15.00, '2025-09-29', 'Anthropic list price'),
+    ('claude-haiku-4-5', 1.00, 5.00, '2025-10-15', 'Anthropic list price'),
+    ('gemini-2.5-pro', 1.25, 10.00, '2025-06-17', 'Google list price, prompts up to 200k tokens'),
+    ('gemini-2.5-flash', 0.30, 2.50, '2025-06-17', 'Google list price')
+ON CONFLICT (model, effective_from) DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS model_pricing;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Migration: Record the model that served each agent step (a fallback when the primary failed)
+
+ALTER TABLE agent_traces ADD COLUMN IF NOT EXISTS model VARCHAR(100);
+
+-- +goose Down
+ALTER TABLE agent_traces DROP COLUMN IF EXISTS model;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Migration: Debug log of the LLM calls of a session (LLM_CALL_LOG), API keys redacted
+
+CREATE TABLE IF NOT EXISTS llm_calls (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    request JSONB NOT NULL,
+    response TEXT,
+    error TEXT,
+    latency_ms INT NOT NULL DEFAULT 0,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_calls_session ON llm_calls(session_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS llm_calls;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Migration: Generation parameters per LLM stage set through the prompts admin API
+-- (NULL keeps the built-in / LLM_STAGE_* value)
+
+CREATE TABLE IF NOT EXISTS stage_params (
+    stage VARCHAR(50) PRIMARY KEY,
+    temperature REAL,
+    max_tokens INT,
+    seed INT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS stage_params;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Dataset-level LLM spend caps in USD ({"daily_usd": 20, "monthly_usd": 300}; unset = unlimited)
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS budget JSONB;
+
+-- Token usage per dataset and day, what dataset budgets are checked against
+CREATE TABLE IF NOT EXISTS dataset_token_usage (
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    date DATE NOT NULL DEFAULT CURRENT_DATE,
+    model VARCHAR(100) NOT NULL,
+    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    cost_usd DECIMAL(12, 6) NOT NULL DEFAULT 0,
+    api_calls INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (dataset_id, date, model)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS dataset_token_usage;
+ALTER TABLE datasets DROP COLUMN IF EXISTS budget;
@@ -0,0 +1,48 @@
+-- +goose Up
+-- Token usage per agent session, product, dataset and module (optimization group). Replaces
+-- dataset_token_usage: dataset budgets are checked against it.
+CREATE TABLE IF NOT EXISTS token_usage_attribution (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID,  -- NULL for calls outside a session (batch preparation), one row per call
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,  -- spend still counts once the product is gone
+    module VARCHAR(50) NOT NULL DEFAULT '',
+    model VARCHAR(100) NOT NULL,
+    date DATE NOT NULL DEFAULT CURRENT_DATE,
+    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    cost_usd DECIMAL(12, 6) NOT NULL DEFAULT 0,
+    api_calls INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(session_id, model)
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_usage_attribution_dataset ON token_usage_attribution(dataset_id, date);
+CREATE INDEX IF NOT EXISTS idx_token_usage_attribution_product ON token_usage_attribution(product_id, date);
+CREATE INDEX IF NOT EXISTS idx_token_usage_attribution_date ON token_usage_attribution(date);
+
+INSERT INTO token_usage_attribution (dataset_id, model, date, prompt_tokens, completion_tokens, cost_usd, api_calls, updated_at)
+SELECT dataset_id, model, date, prompt_tokens, completion_tokens, cost_usd, api_calls, updated_at
+FROM dataset_token_usage;
+
+DROP TABLE IF EXISTS dataset_token_usage;
+
+-- +goose Down
+CREATE TABLE IF NOT EXISTS dataset_token_usage (
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    date DATE NOT NULL DEFAULT CURRENT_DATE,
+    model VARCHAR(100) NOT NULL,
+    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    cost_usd DECIMAL(12, 6) NOT NULL DEFAULT 0,
+    api_calls INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (dataset_id, date, model)
+);
+
+INSERT INTO dataset_token_usage (dataset_id, date, model, prompt_tokens, completion_tokens, cost_usd, api_calls, updated_at)
+SELECT dataset_id, date, model, SUM(prompt_tokens), SUM(completion_tokens), SUM(cost_usd), SUM(api_calls), MAX(updated_at)
+FROM token_usage_attribution
+GROUP BY dataset_id, date, model;
+
+DROP TABLE IF EXISTS token_usage_attribution;
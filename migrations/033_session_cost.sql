@@ -0,0 +1,6 @@
+-- +goose Up
+-- Cost of a single-product run, next to its tokens_used
+ALTER TABLE agent_sessions ADD COLUMN IF NOT EXISTS cost_usd DECIMAL(12, 6) NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE agent_sessions DROP COLUMN IF EXISTS cost_usd;
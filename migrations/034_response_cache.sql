@@ -0,0 +1,21 @@
+-- +goose Up
+-- Optimization answers by hash of their inputs (prompt version, model, group, product data),
+-- reused while the product is unchanged so a re-run costs nothing
+CREATE TABLE IF NOT EXISTS llm_response_cache (
+    key TEXT PRIMARY KEY,
+    group_name VARCHAR(100) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    cost_usd DECIMAL(12, 6) NOT NULL DEFAULT 0, -- cost of the call, saved again by every hit
+    hits INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    last_hit_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_response_cache_expires ON llm_response_cache(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS llm_response_cache;
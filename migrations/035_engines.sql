@@ -0,0 +1,9 @@
+-- +goose Up
+-- Execution path of a run: agent, pipeline or fast_pipeline
+ALTER TABLE agent_sessions ADD COLUMN IF NOT EXISTS engine VARCHAR(20) NOT NULL DEFAULT 'agent';
+-- Dataset-level default engine (NULL = agent)
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS engine VARCHAR(20);
+
+-- +goose Down
+ALTER TABLE datasets DROP COLUMN IF EXISTS engine;
+ALTER TABLE agent_sessions DROP COLUMN IF EXISTS engine;
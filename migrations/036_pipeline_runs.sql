@@ -0,0 +1,43 @@
+-- +goose Up
+-- Audit trail of pipeline / fast_pipeline sessions: stages, rejected changes and evidence
+CREATE TABLE IF NOT EXISTS pipeline_runs (
+    id UUID PRIMARY KEY,
+    session_id UUID NOT NULL, -- no foreign key: job sessions are not stored
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    engine VARCHAR(20) NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ NOT NULL,
+    summary JSONB,
+    evidence_trail JSONB,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pipeline_runs_session ON pipeline_runs(session_id);
+CREATE INDEX IF NOT EXISTS idx_pipeline_runs_product ON pipeline_runs(product_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS pipeline_stages (
+    run_id UUID NOT NULL REFERENCES pipeline_runs(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    stage VARCHAR(50) NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    retries INT NOT NULL DEFAULT 0,
+    output JSONB,
+    error TEXT,
+    PRIMARY KEY (run_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS pipeline_rejections (
+    run_id UUID NOT NULL REFERENCES pipeline_runs(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    field VARCHAR(100) NOT NULL,
+    reason TEXT NOT NULL,
+    evidence TEXT,
+    stage VARCHAR(50) NOT NULL,
+    PRIMARY KEY (run_id, position)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS pipeline_rejections;
+DROP TABLE IF EXISTS pipeline_stages;
+DROP TABLE IF EXISTS pipeline_runs;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- A job can run several groups; its module lists them ("title_optimization+description_optimization")
+ALTER TABLE jobs ALTER COLUMN module TYPE VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE jobs ALTER COLUMN module TYPE VARCHAR(100);
@@ -0,0 +1,6 @@
+-- +goose Up
+-- State of a paused tools session that its traces don't carry (proposals, sources, awaited review)
+ALTER TABLE agent_sessions ADD COLUMN IF NOT EXISTS checkpoint JSONB;
+
+-- +goose Down
+ALTER TABLE agent_sessions DROP COLUMN IF EXISTS checkpoint;
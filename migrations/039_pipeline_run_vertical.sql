@@ -0,0 +1,6 @@
+-- +goose Up
+-- Product vertical a pipeline run routed on (apparel, electronics, home, beauty, generic)
+ALTER TABLE pipeline_runs ADD COLUMN IF NOT EXISTS vertical TEXT NOT NULL DEFAULT 'generic';
+
+-- +goose Down
+ALTER TABLE pipeline_runs DROP COLUMN IF EXISTS vertical;
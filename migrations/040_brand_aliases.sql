@@ -0,0 +1,13 @@
+-- +goose Up
+-- Brand aliases set by admins, canonicalized before the brands found in the feed
+CREATE TABLE IF NOT EXISTS brand_aliases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    alias TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_brand_aliases_dataset_alias ON brand_aliases(dataset_id, LOWER(alias));
+
+-- +goose Down
+DROP TABLE IF EXISTS brand_aliases;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Domains trusted as sources for a dataset, for one brand or (brand '') every brand
+CREATE TABLE IF NOT EXISTS trusted_domains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    domain TEXT NOT NULL,
+    brand TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_trusted_domains_dataset_domain ON trusted_domains(dataset_id, LOWER(domain), LOWER(brand));
+
+-- +goose Down
+DROP TABLE IF EXISTS trusted_domains;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Facts the retrieval stage of a pipeline run found, with where they were read. A fact
+-- backing several proposals has a row per proposal; unused facts have no proposal.
+CREATE TABLE IF NOT EXISTS retrieved_facts (
+    run_id UUID NOT NULL REFERENCES pipeline_runs(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    proposal_id UUID REFERENCES proposals(id) ON DELETE SET NULL,
+    field VARCHAR(100) NOT NULL,
+    value TEXT NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    url TEXT NOT NULL DEFAULT '',
+    evidence TEXT NOT NULL DEFAULT '',
+    confidence DOUBLE PRECISION NOT NULL DEFAULT 0,
+    trusted BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (run_id, position)
+);
+CREATE INDEX IF NOT EXISTS idx_retrieved_facts_proposal ON retrieved_facts(proposal_id) WHERE proposal_id IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS retrieved_facts;
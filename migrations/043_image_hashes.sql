@@ -0,0 +1,16 @@
+-- +goose Up
+-- Perceptual hashes of the images the image analysis group downloaded, one row per product
+-- image. The same hash on products of other item groups flags a placeholder image.
+CREATE TABLE IF NOT EXISTS image_hashes (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    item_group_id VARCHAR(255) NOT NULL DEFAULT '',
+    hash BIGINT NOT NULL,
+    hashed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (product_id, url)
+);
+CREATE INDEX IF NOT EXISTS idx_image_hashes_dataset_hash ON image_hashes(dataset_id, hash);
+
+-- +goose Down
+DROP TABLE IF EXISTS image_hashes;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Last check of each product's image_link: status, content type and redirects as Google's
+-- crawler would get them, and the problem found (NULL = a fetchable image)
+CREATE TABLE IF NOT EXISTS image_checks (
+    product_id UUID PRIMARY KEY REFERENCES products(id) ON DELETE CASCADE,
+    dataset_id UUID NOT NULL REFERENCES datasets(id) ON DELETE CASCADE,
+    url TEXT NOT NULL DEFAULT '',
+    status_code INT,
+    content_type VARCHAR(255),
+    final_url TEXT,
+    redirects INT NOT NULL DEFAULT 0,
+    problem VARCHAR(30),
+    detail TEXT,
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_image_checks_dataset ON image_checks(dataset_id, problem);
+
+-- +goose Down
+DROP TABLE IF EXISTS image_checks;
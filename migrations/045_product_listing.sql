@@ -0,0 +1,7 @@
+-- +goose Up
+-- Migration: Paged product listing of a dataset (ordered by creation, then id)
+
+CREATE INDEX IF NOT EXISTS idx_products_dataset_created ON products(dataset_id, created_at, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_products_dataset_created;
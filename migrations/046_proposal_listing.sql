@@ -0,0 +1,7 @@
+-- +goose Up
+-- Migration: Keyset pagination of the proposal list (newest first)
+
+CREATE INDEX IF NOT EXISTS idx_proposals_created ON proposals(created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_proposals_created;
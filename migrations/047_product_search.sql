@@ -0,0 +1,16 @@
+-- +goose Up
+-- Product search: exact GTIN lookups through current_data containment, full-text search over
+-- title and description ('simple' configuration: feeds mix languages, nothing is stemmed) and
+-- trigram word similarity on the title for partial words and typos
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_products_current_data ON products USING GIN (current_data jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_products_text ON products USING GIN (
+    to_tsvector('simple', COALESCE(current_data->>'title', '') || ' ' || COALESCE(current_data->>'description', ''))
+);
+CREATE INDEX IF NOT EXISTS idx_products_title_trgm ON products USING GIN ((COALESCE(current_data->>'title', '')) gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_products_title_trgm;
+DROP INDEX IF EXISTS idx_products_text;
+DROP INDEX IF EXISTS idx_products_current_data;
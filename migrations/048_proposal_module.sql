@@ -0,0 +1,17 @@
+-- +goose Up
+-- Proposals saved without their module are grouped as 'unknown' by module: backfill it from the
+-- session's token attribution when the session ran a single module.
+UPDATE proposals p SET module = a.module
+FROM (
+    SELECT session_id, MIN(module) AS module FROM token_usage_attribution
+    WHERE session_id IS NOT NULL AND module <> ''
+    GROUP BY session_id HAVING COUNT(DISTINCT module) = 1
+) a
+WHERE p.module IS NULL AND p.session_id = a.session_id;
+
+-- Proposals saved with their session had no rationale
+UPDATE proposals SET rationale = '{}' WHERE rationale IS NULL;
+ALTER TABLE proposals ALTER COLUMN rationale SET DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE proposals ALTER COLUMN rationale DROP DEFAULT;
@@ -0,0 +1,47 @@
+-- +goose Up
+-- Compliance scores of the products (share of the GMC rules passed, see HardRuleValidator):
+-- baseline_score on the feed data as imported, current_score on the data with its applied
+-- changes as of version scored_version. Products are scored again when their version moves.
+ALTER TABLE products
+    ADD COLUMN baseline_score DECIMAL(3,2),
+    ADD COLUMN current_score DECIMAL(3,2),
+    ADD COLUMN scored_version INT;
+
+-- Score aggregates of each dataset, refreshed when products were scored. The distributions
+-- count the products per 0.1-wide score range, keyed by range ("0" = 0-0.1, ..., "9" = 0.9-1).
+CREATE MATERIALIZED VIEW dataset_score_stats AS
+SELECT s.dataset_id, s.scored, s.avg_before, s.avg_after,
+    COALESCE((
+        SELECT jsonb_object_agg(bin, n) FROM (
+            SELECT LEAST(FLOOR(baseline_score * 10), 9)::int AS bin, COUNT(*) AS n
+            FROM products
+            WHERE dataset_id = s.dataset_id AND scored_version IS NOT NULL AND baseline_score IS NOT NULL
+            GROUP BY 1
+        ) b
+    ), '{}') AS before_distribution,
+    COALESCE((
+        SELECT jsonb_object_agg(bin, n) FROM (
+            SELECT LEAST(FLOOR(current_score * 10), 9)::int AS bin, COUNT(*) AS n
+            FROM products
+            WHERE dataset_id = s.dataset_id AND scored_version IS NOT NULL AND current_score IS NOT NULL
+            GROUP BY 1
+        ) a
+    ), '{}') AS after_distribution
+FROM (
+    SELECT dataset_id, COUNT(*)::int AS scored,
+        COALESCE(AVG(baseline_score), 0)::float8 AS avg_before,
+        COALESCE(AVG(current_score), 0)::float8 AS avg_after
+    FROM products
+    WHERE scored_version IS NOT NULL
+    GROUP BY dataset_id
+) s;
+
+-- Required by REFRESH MATERIALIZED VIEW CONCURRENTLY
+CREATE UNIQUE INDEX idx_dataset_score_stats_dataset ON dataset_score_stats(dataset_id);
+
+-- +goose Down
+DROP MATERIALIZED VIEW IF EXISTS dataset_score_stats;
+ALTER TABLE products
+    DROP COLUMN IF EXISTS scored_version,
+    DROP COLUMN IF EXISTS current_score,
+    DROP COLUMN IF EXISTS baseline_score;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Snapshot product data is stored once per distinct content: a product unchanged between
+-- two snapshots (or identical in two datasets) references the same row.
+CREATE TABLE snapshot_data (
+    hash TEXT PRIMARY KEY, -- md5 of [raw_data, current_data]
+    raw_data JSONB NOT NULL,
+    current_data JSONB
+);
+
+INSERT INTO snapshot_data (hash, raw_data, current_data)
+SELECT DISTINCT ON (hash) hash, raw_data, current_data FROM (
+    SELECT md5(jsonb_build_array(raw_data, current_data)::text) AS hash, raw_data, current_data
+    FROM snapshot_products
+) s;
+
+ALTER TABLE snapshot_products ADD COLUMN data_hash TEXT;
+UPDATE snapshot_products SET data_hash = md5(jsonb_build_array(raw_data, current_data)::text);
+ALTER TABLE snapshot_products
+    ALTER COLUMN data_hash SET NOT NULL,
+    ADD CONSTRAINT snapshot_products_data_hash_fkey FOREIGN KEY (data_hash) REFERENCES snapshot_data(hash),
+    DROP COLUMN raw_data,
+    DROP COLUMN current_data;
+
+-- Finds the data no snapshot references anymore
+CREATE INDEX idx_snapshot_products_data_hash ON snapshot_products(data_hash);
+
+-- +goose Down
+ALTER TABLE snapshot_products ADD COLUMN raw_data JSONB, ADD COLUMN current_data JSONB;
+UPDATE snapshot_products sp SET raw_data = d.raw_data, current_data = d.current_data
+FROM snapshot_data d WHERE d.hash = sp.data_hash;
+ALTER TABLE snapshot_products ALTER COLUMN raw_data SET NOT NULL, DROP COLUMN data_hash;
+DROP TABLE snapshot_data;
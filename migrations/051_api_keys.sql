@@ -0,0 +1,17 @@
+-- +goose Up
+-- API keys: only the SHA-256 of the key is stored, the key itself is shown once at creation.
+-- prefix is its first characters, to tell keys apart in lists and logs.
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL, -- read, review, enrich, admin
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
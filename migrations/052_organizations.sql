@@ -0,0 +1,61 @@
+-- +goose Up
+-- Organizations (tenants): each merchant or client of an agency. Datasets, rules, approval
+-- rules and API keys belong to one; everything else hangs off its datasets. The existing
+-- data moves to the "default" organization.
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(100) NOT NULL UNIQUE,
+    openai_api_key TEXT, -- NULL = OPENAI_API_KEY
+    brave_api_key TEXT,  -- NULL = BRAVE_API_KEY
+    budget JSONB,        -- daily_usd / monthly_usd caps over all its datasets
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO organizations (name, slug) VALUES ('Default', 'default');
+
+-- Members of an organization, API keys can be issued to them
+CREATE TABLE users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_users_org ON users(org_id);
+
+ALTER TABLE datasets ADD COLUMN org_id UUID REFERENCES organizations(id);
+ALTER TABLE rules ADD COLUMN org_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE approval_rules ADD COLUMN org_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE api_keys
+    ADD COLUMN org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    ADD COLUMN user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE datasets SET org_id = (SELECT id FROM organizations WHERE slug = 'default');
+-- Rules of a dataset follow it; rules without one (global) go to the default organization
+UPDATE rules r SET org_id = COALESCE((SELECT org_id FROM datasets WHERE id = r.dataset_id),
+    (SELECT id FROM organizations WHERE slug = 'default'));
+UPDATE approval_rules r SET org_id = COALESCE((SELECT org_id FROM datasets WHERE id = r.dataset_id),
+    (SELECT id FROM organizations WHERE slug = 'default'));
+UPDATE api_keys SET org_id = (SELECT id FROM organizations WHERE slug = 'default');
+
+ALTER TABLE datasets ALTER COLUMN org_id SET NOT NULL;
+ALTER TABLE rules ALTER COLUMN org_id SET NOT NULL;
+ALTER TABLE approval_rules ALTER COLUMN org_id SET NOT NULL;
+ALTER TABLE api_keys ALTER COLUMN org_id SET NOT NULL;
+
+CREATE INDEX idx_datasets_org ON datasets(org_id);
+CREATE INDEX idx_rules_org ON rules(org_id);
+CREATE INDEX idx_approval_rules_org ON approval_rules(org_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_approval_rules_org;
+DROP INDEX IF EXISTS idx_rules_org;
+DROP INDEX IF EXISTS idx_datasets_org;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS user_id, DROP COLUMN IF EXISTS org_id;
+ALTER TABLE approval_rules DROP COLUMN IF EXISTS org_id;
+ALTER TABLE rules DROP COLUMN IF EXISTS org_id;
+ALTER TABLE datasets DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS organizations;
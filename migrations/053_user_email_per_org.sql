@@ -0,0 +1,8 @@
+-- +goose Up
+-- An email is unique within its organization: the same person can be a member of several
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_org_email_key UNIQUE (org_id, email);
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_org_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
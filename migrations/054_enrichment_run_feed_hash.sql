@@ -0,0 +1,12 @@
+-- +goose Up
+-- Runs are compared to the feed data (raw_data), no longer to the data with applied proposals,
+-- which made every product with an applied change look stale. Runs the data hasn't changed
+-- since get the hash of the feed data.
+UPDATE product_enrichment_runs er SET data_hash = md5(p.raw_data::text)
+FROM products p
+WHERE p.id = er.product_id AND er.data_hash = md5(COALESCE(p.current_data, p.raw_data)::text);
+
+-- +goose Down
+UPDATE product_enrichment_runs er SET data_hash = md5(COALESCE(p.current_data, p.raw_data)::text)
+FROM products p
+WHERE p.id = er.product_id AND er.data_hash = md5(p.raw_data::text);
@@ -0,0 +1,10 @@
+// Package migrations embeds the goose SQL migrations, so the binary applies them from any
+// working directory
+package migrations
+
+import "embed"
+
+// FS holds the migration files at its root
+//
+//go:embed *.sql
+var FS embed.FS